@@ -30,11 +30,22 @@ interface ForecastData {
 
 
 
+interface ForecastMeta {
+  source: 'llm' | 'fallback' | 'deterministic'
+  provider: string
+  model?: string
+  promptVersion?: string
+  fallbackReason?: string
+  durationMs: number
+  cacheHit: boolean
+}
+
 interface ForecastResponse {
   forecast: ForecastData[]
   timePeriod: string
   message: string
   rawResponse?: string
+  meta?: ForecastMeta
 }
 
 type TimePeriod = 'day' | 'week' | 'month'
@@ -173,9 +184,11 @@ function App() {
       const timeSeriesData = prepareTimeSeriesData()
 
       // Prepare data for forecasting
+      // Tracked so the forecast can be compared with actual revenue later
       const forecastRequest = {
         timeSeriesData: timeSeriesData,
-        timePeriod: timePeriod
+        timePeriod: timePeriod,
+        track: true
       }
 
       console.log('Sending forecast request:', forecastRequest)
@@ -184,11 +197,14 @@ function App() {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
+          // Pin the flat forecast schema the dashboard is written against
+          'X-Schema-Version': '2',
         },
         body: JSON.stringify(forecastRequest)
       })
 
-      if (!response.ok) {
+      // A 504 still carries the statistical fallback forecast
+      if (!response.ok && response.status !== 504) {
         throw new Error(`Forecast request failed: ${response.status}`)
       }
 
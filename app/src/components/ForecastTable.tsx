@@ -9,11 +9,22 @@ interface ForecastData {
   total: number
 }
 
+interface ForecastMeta {
+  source: 'llm' | 'fallback' | 'deterministic'
+  provider: string
+  model?: string
+  promptVersion?: string
+  fallbackReason?: string
+  durationMs: number
+  cacheHit: boolean
+}
+
 interface ForecastResponse {
   forecast: ForecastData[]
   timePeriod: string
   message: string
   rawResponse?: string
+  meta?: ForecastMeta
 }
 
 interface ForecastTableProps {
@@ -56,7 +67,13 @@ export const ForecastTable: React.FC<ForecastTableProps> = ({
                         {formatCurrency(forecastPoint.total)}
                       </td>
                       <td>
-                        <span className="badge bg-success">Forecasted</span>
+                        {forecastCache[timePeriod].meta?.source === 'fallback' ? (
+                          <span className="badge bg-warning text-dark" title={forecastCache[timePeriod].meta?.fallbackReason}>
+                            Forecasted (statistical fallback)
+                          </span>
+                        ) : (
+                          <span className="badge bg-success">Forecasted</span>
+                        )}
                       </td>
                     </tr>
                   ))
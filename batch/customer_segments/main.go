@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/batch"
+	"github.com/bokor/craft-demo/internal/database"
+)
+
+func main() {
+	asOf := flag.String("as-of", time.Now().Format("2006-01-02"), "date recency is measured from (YYYY-MM-DD)")
+	flag.Parse()
+
+	asOfDate, err := time.Parse("2006-01-02", *asOf)
+	if err != nil {
+		log.Fatalf("Invalid -as-of date: %v", err)
+	}
+
+	// open database
+	db, err := database.GetDBConnection()
+	if err != nil {
+		log.Fatalf("Error connecting to the database: %v", err)
+	}
+	defer db.Close()
+
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Failed to ping database: %v", err)
+	}
+
+	bands, err := batch.RFMBandsFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load RFM bands: %v", err)
+	}
+
+	if err := batch.GenerateCustomerSegments(db, bands, asOfDate); err != nil {
+		log.Fatalf("Failed to generate customer segments: %v", err)
+	}
+
+	log.Println("Customer segment generation completed successfully")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/database"
+	"github.com/bokor/craft-demo/internal/export"
+)
+
+func main() {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	start := flag.String("start", today.AddDate(0, 0, -90).Format("2006-01-02"), "first date to export (YYYY-MM-DD)")
+	end := flag.String("end", today.Format("2006-01-02"), "last date to export (YYYY-MM-DD)")
+	out := flag.String("out", "", "output directory (defaults to snapshot-<start>-<end>)")
+	flag.Parse()
+
+	startDate, err := time.Parse("2006-01-02", *start)
+	if err != nil {
+		log.Fatalf("Invalid -start date: %v", err)
+	}
+	endDate, err := time.Parse("2006-01-02", *end)
+	if err != nil {
+		log.Fatalf("Invalid -end date: %v", err)
+	}
+	if endDate.Before(startDate) {
+		log.Fatalf("-end must not be before -start")
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("snapshot-%s-%s", *start, *end)
+	}
+
+	// open database
+	db, err := database.GetDBConnection()
+	if err != nil {
+		log.Fatalf("Error connecting to the database: %v", err)
+	}
+	defer db.Close()
+
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Failed to ping database: %v", err)
+	}
+
+	// The end date is inclusive
+	counts, err := export.WriteParquetDir(db, *out, export.SnapshotDatasets(startDate, endDate.AddDate(0, 0, 1)))
+	if err != nil {
+		log.Fatalf("Failed to export snapshot: %v", err)
+	}
+
+	for name, count := range counts {
+		log.Printf("Exported %d rows of %s", count, name)
+	}
+	log.Printf("Snapshot written to %s; load it into DuckDB with: cd %s && duckdb snapshot.duckdb < load.sql", *out, *out)
+}
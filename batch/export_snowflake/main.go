@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/bokor/craft-demo/internal/database"
+	"github.com/bokor/craft-demo/internal/export"
+)
+
+func main() {
+	config, enabled, err := export.SnowflakeConfigFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid Snowflake configuration: %v", err)
+	}
+	if !enabled {
+		log.Fatalf("SNOWFLAKE_ACCOUNT is not set")
+	}
+
+	// open database
+	db, err := database.GetDBConnection()
+	if err != nil {
+		log.Fatalf("Error connecting to the database: %v", err)
+	}
+	defer db.Close()
+
+	// Test the connection
+	if err := db.Ping(); err != nil {
+		log.Fatalf("Failed to ping database: %v", err)
+	}
+
+	if err := export.ExportToSnowflake(context.Background(), db, config); err != nil {
+		log.Fatalf("Failed to export to Snowflake: %v", err)
+	}
+
+	log.Println("Snowflake export completed successfully")
+}
@@ -1,23 +1,59 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
-	"strings"
+	"os"
+	"strconv"
+	"time"
 
+	"github.com/bokor/craft-demo/internal/batch"
 	"github.com/bokor/craft-demo/internal/database"
 )
 
-// SalesTotal represents a record for the sales_totals_by_category_dw table
-type SalesTotal struct {
-	DateRecorded      string
-	SaleTransactionID int
-	CategoryID        int
-	TotalAmount       float64
-}
+// Exit codes. A partial failure regenerated the sales totals but failed to
+// record the run or push its metrics, so the data needn't be regenerated.
+const (
+	exitFailed  = 1
+	exitPartial = 2
+)
 
 func main() {
+	options, err := batch.LockOptionsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid lock configuration: %v", err)
+	}
+	flag.StringVar((*string)(&options.Mode), "lock-mode", string(options.Mode), "what to do if another run is in progress: wait or skip")
+	flag.DurationVar(&options.Timeout, "lock-timeout", options.Timeout, "how long to wait for another run in wait mode")
+	var regenerate batch.RegenerateOptions
+	flag.StringVar(&regenerate.StartDate, "start-date", "", "first date to regenerate (YYYY-MM-DD, defaults to all history)")
+	flag.StringVar(&regenerate.EndDate, "end-date", "", "last date to regenerate (YYYY-MM-DD, defaults to all history)")
+	category := flag.String("category", "", "ID or name of the only category to regenerate (defaults to every category)")
+	flag.BoolVar(&regenerate.DryRun, "dry-run", false, "log what would change without writing")
+	flag.BoolVar(&regenerate.Verbose, "verbose", false, "log each date and category whose records or total change")
+	flag.IntVar(&regenerate.Workers, "workers", 0, "partitions to aggregate at once (defaults to SALES_TOTALS_WORKERS or 4)")
+	flag.IntVar(&regenerate.PartitionSize, "partition-size", 0, "sale transaction IDs per partition (defaults to SALES_TOTALS_PARTITION_SIZE or 10000)")
+	flag.Parse()
+
+	if options.Mode != batch.LockWait && options.Mode != batch.LockSkip {
+		log.Fatalf("Invalid -lock-mode %q, use wait or skip", options.Mode)
+	}
+	for _, date := range []struct{ name, value string }{{"start-date", regenerate.StartDate}, {"end-date", regenerate.EndDate}} {
+		if _, err := time.Parse("2006-01-02", date.value); date.value != "" && err != nil {
+			log.Fatalf("Invalid -%s %q, use YYYY-MM-DD", date.name, date.value)
+		}
+	}
+	if regenerate.StartDate != "" && regenerate.EndDate != "" && regenerate.EndDate < regenerate.StartDate {
+		log.Fatalf("-end-date must not be before -start-date")
+	}
+	if regenerate.Workers < 0 || regenerate.PartitionSize < 0 {
+		log.Fatalf("-workers and -partition-size must be positive")
+	}
+
 	// open database
 	db, err := database.GetDBConnection()
 	if err != nil {
@@ -32,169 +68,60 @@ func main() {
 
 	log.Println("Connected to database successfully")
 
-	// Clear existing data from sales_totals_by_category_dw table
-	if err := clearExistingData(db); err != nil {
-		log.Fatalf("Failed to clear existing data: %v", err)
-	}
-
-	// Generate and insert sales totals data
-	if err := generateSalesTotals(db); err != nil {
-		log.Fatalf("Failed to generate sales totals: %v", err)
-	}
-
-	log.Println("Sales totals generation completed successfully")
-}
-
-func clearExistingData(db *sql.DB) error {
-	query := "DELETE FROM sales_totals_by_category_dw"
-	_, err := db.Exec(query)
-	if err != nil {
-		return fmt.Errorf("failed to clear existing data: %v", err)
-	}
-	log.Println("Cleared existing data from sales_totals_by_category_dw table")
-	return nil
-}
-
-func generateSalesTotals(db *sql.DB) error {
-	// Query to get sales data with category information
-	query := `
-		SELECT
-			st.date_recorded,
-			st.id as sale_transaction_id,
-			p.category_id,
-			sti.quantity,
-			sti.total_amount,
-			st.status
-		FROM sale_transactions st
-		JOIN sale_transaction_items sti ON st.id = sti.sale_transaction_id
-		JOIN products p ON sti.product_id = p.id
-		ORDER BY st.date_recorded, st.id, p.category_id
-	`
-
-	rows, err := db.Query(query)
-	if err != nil {
-		return fmt.Errorf("failed to query sales data: %v", err)
-	}
-	defer rows.Close()
-
-	// Map to aggregate totals by date, transaction, and category
-	totalsMap := make(map[string]float64)
-	var records []SalesTotal
-
-	for rows.Next() {
-		var (
-			dateRecorded      string
-			saleTransactionID int
-			categoryID        int
-			quantity          int
-			totalAmount       float64
-			status            string
-		)
-
-		if err := rows.Scan(&dateRecorded, &saleTransactionID, &categoryID, &quantity, &totalAmount, &status); err != nil {
-			return fmt.Errorf("failed to scan row: %v", err)
-		}
-
-		// Calculate the actual total for this item
-		itemTotal := totalAmount
-
-		// If it's a refund, make the amount negative
-		if strings.ToLower(status) == "refund" {
-			itemTotal = -itemTotal
+	if *category != "" {
+		if regenerate.CategoryID, err = categoryID(db, *category); err != nil {
+			log.Fatalf("Invalid -category: %v", err)
 		}
-
-		// Create a unique key for this combination
-		key := fmt.Sprintf("%s_%d_%d", dateRecorded, saleTransactionID, categoryID)
-
-		// Aggregate totals by category for each transaction
-		totalsMap[key] += itemTotal
 	}
 
-	if err := rows.Err(); err != nil {
-		return fmt.Errorf("error iterating rows: %v", err)
+	// Regenerate the sales_totals_by_category_dw rows in range
+	started := time.Now()
+	result, err := batch.RunSalesTotals(db, batch.TriggerCLI, options, regenerate)
+	metrics := batch.NewMetrics(batch.SalesTotalsJob, started, regenerate, result, err)
+	if metrics.Outcome == batch.OutcomeSkipped && options.Mode != batch.LockSkip {
+		// Waiting for the lock timed out, which fails a run in wait mode
+		metrics.Outcome = batch.OutcomeFailed
 	}
-
-	// Convert aggregated data to records
-	for key, totalAmount := range totalsMap {
-		parts := strings.Split(key, "_")
-		if len(parts) != 3 {
-			log.Printf("Warning: Invalid key format: %s", key)
-			continue
+	if pushErr := batch.PushMetrics(context.Background(), metrics); pushErr != nil {
+		log.Printf("Failed to push metrics: %v", pushErr)
+		if metrics.Outcome == batch.OutcomeSucceeded {
+			metrics.Outcome = batch.OutcomePartial
+			metrics.Error = pushErr.Error()
 		}
-
-		dateRecorded := parts[0]
-		var saleTransactionID, categoryID int
-		fmt.Sscanf(parts[1], "%d", &saleTransactionID)
-		fmt.Sscanf(parts[2], "%d", &categoryID)
-
-		record := SalesTotal{
-			DateRecorded:      dateRecorded,
-			SaleTransactionID: saleTransactionID,
-			CategoryID:        categoryID,
-			TotalAmount:       totalAmount,
-		}
-		records = append(records, record)
 	}
-
-	// Insert records into the sales_totals_by_category_dw table
-	if err := insertSalesTotals(db, records); err != nil {
-		return fmt.Errorf("failed to insert sales totals: %v", err)
+	if err := json.NewEncoder(os.Stdout).Encode(metrics); err != nil {
+		log.Printf("Failed to write metrics: %v", err)
 	}
 
-	log.Printf("Generated %d sales total records", len(records))
-	return nil
-}
-
-func insertSalesTotals(db *sql.DB, records []SalesTotal) error {
-	// Prepare the insert statement
-	query := `
-		INSERT INTO sales_totals_by_category_dw
-		(date_recorded, sale_transaction_id, category_id, total_amount)
-		VALUES ($1, $2, $3, $4)
-	`
-
-	// Begin transaction for batch insert
-	tx, err := db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
+	switch metrics.Outcome {
+	case batch.OutcomeSkipped:
+		log.Println("Another sales totals run is in progress, skipping")
+	case batch.OutcomePartial:
+		log.Printf("Sales totals were regenerated, but: %s", metrics.Error)
+		os.Exit(exitPartial)
+	case batch.OutcomeFailed:
+		log.Printf("Failed to generate sales totals: %v", err)
+		os.Exit(exitFailed)
+	default:
+		log.Println("Sales totals generation completed successfully")
 	}
-	defer tx.Rollback()
+}
 
-	// Prepare the statement
-	stmt, err := tx.Prepare(query)
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %v", err)
+// categoryID returns the ID of the category value names, by ID or by name
+// ignoring case
+func categoryID(db *sql.DB, value string) (int, error) {
+	var id int
+	query := "SELECT id FROM categories WHERE LOWER(name) = LOWER($1)"
+	args := []any{value}
+	if n, err := strconv.Atoi(value); err == nil {
+		query, args = "SELECT id FROM categories WHERE id = $1", []any{n}
 	}
-	defer stmt.Close()
-
-	// Insert records in batches
-	batchSize := 100
-	for i := 0; i < len(records); i += batchSize {
-		end := i + batchSize
-		if end > len(records) {
-			end = len(records)
-		}
-
-		batch := records[i:end]
-		for _, record := range batch {
-			_, err := stmt.Exec(
-				record.DateRecorded,
-				record.SaleTransactionID,
-				record.CategoryID,
-				record.TotalAmount,
-			)
-			if err != nil {
-				return fmt.Errorf("failed to insert record: %v", err)
-			}
-		}
-
-		log.Printf("Inserted batch %d-%d of %d records", i+1, end, len(records))
+	err := db.QueryRow(query, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("no category %q", value)
 	}
-
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %v", err)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up category: %v", err)
 	}
-
-	return nil
+	return id, nil
 }
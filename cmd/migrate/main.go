@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bokor/craft-demo/internal/database"
+)
+
+// Applies the migrations embedded from db/migrations to the database from the
+// DB_* environment variables, recording them in the Goose version table
+// (GOOSE_TABLE, default goose_db_version) so goose and this command can be
+// used interchangeably.
+//
+//	migrate [-to VERSION] up    apply pending migrations (the default)
+//	migrate [-to VERSION] down  roll back the newest migration, or every one newer than VERSION
+//	migrate status              list migrations and when they were applied
+func main() {
+	to := flag.Int64("to", 0, "version to migrate up or down to")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-to VERSION] [up|down|status]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	command := "up"
+	if flag.NArg() == 1 {
+		command = flag.Arg(0)
+	}
+	if flag.NArg() > 1 || (command != "up" && command != "down" && command != "status") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// open database
+	db, err := database.GetDBConnection()
+	if err != nil {
+		log.Fatalf("Error connecting to the database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		err = database.MigrateUp(ctx, db, *to)
+	case "down":
+		err = migrateDown(ctx, db, *to)
+	case "status":
+		err = printStatus(ctx, db)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+}
+
+// migrateDown rolls back to version to, or only the newest applied migration
+// when to isn't set
+func migrateDown(ctx context.Context, db *sql.DB, to int64) error {
+	if to == 0 {
+		states, err := database.MigrationStatus(ctx, db)
+		if err != nil {
+			return err
+		}
+		newest := -1
+		for i, state := range states {
+			if state.AppliedAt != nil {
+				newest = i
+			}
+		}
+		if newest < 0 {
+			log.Println("No migrations to roll back")
+			return nil
+		}
+		// Roll back to the applied migration before the newest one
+		for _, state := range states[:newest] {
+			if state.AppliedAt != nil {
+				to = state.Version
+			}
+		}
+	}
+	return database.MigrateDown(ctx, db, to)
+}
+
+// printStatus lists every migration and when it was applied
+func printStatus(ctx context.Context, db *sql.DB) error {
+	states, err := database.MigrationStatus(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		applied := "Pending"
+		if state.AppliedAt != nil {
+			applied = state.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-20s %s\n", applied, state.File)
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// A small stand-in for the OpenAI chat completions API so the full stack can
+// run offline. Point the server at it with OPENAI_BASE_URL=http://localhost:8081/v1.
+//
+// Failures can be injected with:
+//
+//	POST   /mock/failures {"status": 429, "count": 3, "latencyMs": 2000}
+//	DELETE /mock/failures
+func main() {
+	port := os.Getenv("MOCK_OPENAI_PORT")
+	if port == "" {
+		port = "8081"
+	}
+
+	e := echo.New()
+	e.HideBanner = true
+	e.Use(middleware.Logger())
+	e.Use(middleware.Recover())
+
+	e.POST("/v1/chat/completions", chatCompletions)
+	// Azure OpenAI-style route, used with OPENAI_API_TYPE=azure
+	e.POST("/openai/deployments/:deployment/chat/completions", chatCompletions)
+	e.GET("/v1/models", listModels)
+	e.GET("/mock/failures", getFailures)
+	e.POST("/mock/failures", setFailures)
+	e.DELETE("/mock/failures", resetFailures)
+
+	if err := e.Start(":" + port); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+// FailureConfig describes the failures injected into chat completion calls
+type FailureConfig struct {
+	// Status is the HTTP status returned instead of a completion (0 disables)
+	Status int `json:"status"`
+	// Count is the number of requests to fail; negative fails every request
+	Count int `json:"count"`
+	// LatencyMs delays every completion by the given number of milliseconds
+	LatencyMs int `json:"latencyMs"`
+	// Malformed returns a completion whose content contains no JSON array
+	Malformed bool `json:"malformed"`
+}
+
+var (
+	failuresMu sync.Mutex
+	failures   FailureConfig
+)
+
+// ChatRequest is the subset of the chat completions request the mock reads
+type ChatRequest struct {
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+}
+
+// ChatMessage represents a message in the chat conversation
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+var (
+	periodPattern = regexp.MustCompile(`<period>([^<]+)</period>\s*<total>([^<]+)</total>`)
+	countPattern  = regexp.MustCompile(`next (\d+) periods`)
+	labelPattern  = regexp.MustCompile(`historical (daily|weekly|monthly) sales`)
+)
+
+func chatCompletions(c echo.Context) error {
+	failure, fail := nextFailure()
+	if failure.LatencyMs > 0 {
+		time.Sleep(time.Duration(failure.LatencyMs) * time.Millisecond)
+	}
+	if fail {
+		return c.JSON(failure.Status, map[string]any{
+			"error": map[string]any{
+				"message": fmt.Sprintf("Injected failure with status %d", failure.Status),
+				"type":    "mock_injected_error",
+				"code":    strconv.Itoa(failure.Status),
+			},
+		})
+	}
+
+	var request ChatRequest
+	if err := c.Bind(&request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]any{
+			"error": map[string]any{
+				"message": "Invalid request body",
+				"type":    "invalid_request_error",
+			},
+		})
+	}
+
+	var prompt string
+	for _, message := range request.Messages {
+		if message.Role == "user" {
+			prompt = message.Content
+		}
+	}
+
+	content := "Here is the forecast:\n```json\n" + forecastContent(prompt) + "\n```"
+	if failure.Malformed {
+		content = "I'm sorry, I can't produce a forecast for this data."
+	}
+
+	model := request.Model
+	if model == "" {
+		model = "mock-model"
+	}
+
+	promptTokens := len(strings.Fields(prompt))
+	completionTokens := len(strings.Fields(content))
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"id":      fmt.Sprintf("chatcmpl-mock-%d", time.Now().UnixNano()),
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []map[string]any{
+			{
+				"index":         0,
+				"message":       map[string]string{"role": "assistant", "content": content},
+				"finish_reason": "stop",
+			},
+		},
+		"usage": map[string]int{
+			"prompt_tokens":     promptTokens,
+			"completion_tokens": completionTokens,
+			"total_tokens":      promptTokens + completionTokens,
+		},
+	})
+}
+
+// forecastContent builds a forecast-shaped JSON array that continues the
+// historical data found in the prompt at its average level
+func forecastContent(prompt string) string {
+	count := 6
+	if match := countPattern.FindStringSubmatch(prompt); match != nil {
+		count, _ = strconv.Atoi(match[1])
+	}
+
+	label := "monthly"
+	if match := labelPattern.FindStringSubmatch(prompt); match != nil {
+		label = match[1]
+	}
+
+	var (
+		last  time.Time
+		sum   float64
+		total int
+	)
+	for _, match := range periodPattern.FindAllStringSubmatch(prompt, -1) {
+		date, err := time.Parse("2006-01-02", match[1])
+		if err != nil {
+			date, err = time.Parse("2006-01", match[1])
+			if err != nil {
+				continue
+			}
+		}
+		value, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			continue
+		}
+		if date.After(last) {
+			last = date
+		}
+		sum += value
+		total++
+	}
+
+	average := 1000.0
+	if total > 0 {
+		average = sum / float64(total)
+	}
+	if last.IsZero() {
+		last = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+
+	type point struct {
+		Period string  `json:"period"`
+		Total  float64 `json:"total"`
+	}
+
+	points := make([]point, 0, count)
+	for i := 1; i <= count; i++ {
+		var period time.Time
+		switch label {
+		case "daily":
+			period = last.AddDate(0, 0, i)
+		case "weekly":
+			period = last.AddDate(0, 0, 7*i)
+		default:
+			period = last.AddDate(0, i, 0)
+		}
+		// Gentle deterministic wave so charts show some movement
+		factor := 1 + 0.05*float64((i%4)-1)
+		points = append(points, point{
+			Period: period.Format("2006-01-02"),
+			Total:  float64(int(average*factor*100)) / 100,
+		})
+	}
+
+	data, _ := json.MarshalIndent(points, "", "  ")
+	return string(data)
+}
+
+func listModels(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]any{
+		"object": "list",
+		"data": []map[string]string{
+			{"id": "gpt-3.5-turbo", "object": "model", "owned_by": "mock"},
+			{"id": "gpt-4o-mini", "object": "model", "owned_by": "mock"},
+		},
+	})
+}
+
+// nextFailure returns the current failure config and whether this request
+// should fail, consuming one failure from the count
+func nextFailure() (FailureConfig, bool) {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+
+	current := failures
+	if failures.Status == 0 || failures.Count == 0 {
+		return current, false
+	}
+	if failures.Count > 0 {
+		failures.Count--
+	}
+	return current, true
+}
+
+func getFailures(c echo.Context) error {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	return c.JSON(http.StatusOK, failures)
+}
+
+func setFailures(c echo.Context) error {
+	var config FailureConfig
+	if err := c.Bind(&config); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Invalid failure config",
+		})
+	}
+
+	failuresMu.Lock()
+	failures = config
+	failuresMu.Unlock()
+
+	return c.JSON(http.StatusOK, config)
+}
+
+func resetFailures(c echo.Context) error {
+	failuresMu.Lock()
+	failures = FailureConfig{}
+	failuresMu.Unlock()
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/lifecycle"
+	"github.com/bokor/craft-demo/internal/services"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// canceledRequestGrace is how long requests get to respond once their
+	// context is canceled, e.g. with a statistical fallback forecast
+	canceledRequestGrace = 5 * time.Second
+	// hooksTimeout bounds the shutdown hooks
+	hooksTimeout = 10 * time.Second
+)
+
+// shutdownConfig sets how the server drains on SIGTERM or SIGINT
+type shutdownConfig struct {
+	// delay keeps serving while readiness reports draining, so the load
+	// balancer stops sending requests before the listener closes
+	delay time.Duration
+	// timeout bounds the wait for requests in flight to finish
+	timeout time.Duration
+}
+
+// shutdownConfigFromEnv reads SHUTDOWN_DELAY (default 0) and
+// SHUTDOWN_TIMEOUT (default 30s)
+func shutdownConfigFromEnv() shutdownConfig {
+	config := shutdownConfig{timeout: 30 * time.Second}
+	for _, setting := range []struct {
+		env   string
+		value *time.Duration
+	}{{"SHUTDOWN_DELAY", &config.delay}, {"SHUTDOWN_TIMEOUT", &config.timeout}} {
+		if value := os.Getenv(setting.env); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed < 0 {
+				log.Fatalf("Invalid %s: %q", setting.env, value)
+			}
+			*setting.value = parsed
+		}
+	}
+	return config
+}
+
+// inFlight counts the requests being handled. An HTTP/2 cleartext
+// connection is served in the handler of the request that opened it, which
+// http.Server.Shutdown doesn't wait for, so draining waits for the count.
+type inFlight struct {
+	count atomic.Int64
+}
+
+// handler counts requests while next handles them
+func (f *inFlight) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.count.Add(1)
+		defer f.count.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// drain stops the server from accepting requests and waits within ctx for
+// the ones in flight to finish
+func (f *inFlight) drain(ctx context.Context, e *echo.Echo) error {
+	if err := e.Shutdown(ctx); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for f.count.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// run drains the server: readiness fails at once, the listener closes after
+// the delay, and requests in flight get the timeout to finish. Requests
+// still running then have their contexts canceled, which aborts their LLM
+// calls, and get a short grace to respond before their connections are
+// closed. The hooks run last.
+func (c shutdownConfig) run(e *echo.Echo, requests *inFlight, cancelRequests context.CancelFunc, hooks *lifecycle.Hooks) {
+	log.Printf("Shutting down: draining requests for up to %s", c.delay+c.timeout)
+	services.StartDraining()
+	time.Sleep(c.delay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	if err := requests.drain(ctx, e); err != nil {
+		log.Printf("%d requests still running after %s, canceling them", requests.count.Load(), c.timeout)
+		cancelRequests()
+
+		graceCtx, cancel := context.WithTimeout(context.Background(), canceledRequestGrace)
+		defer cancel()
+		if err := requests.drain(graceCtx, e); err != nil {
+			log.Printf("Closing the connections of %d requests that didn't finish", requests.count.Load())
+			e.Close()
+		}
+	}
+	cancelRequests()
+
+	hooksCtx, cancel := context.WithTimeout(context.Background(), hooksTimeout)
+	defer cancel()
+	if err := hooks.Shutdown(hooksCtx); err != nil {
+		log.Printf("Shutdown hooks failed: %v", err)
+	}
+	log.Printf("Shutdown complete")
+}
@@ -0,0 +1,28 @@
+// Package config embeds the default prompt template, holiday calendar, store
+// closures, alert rules, fiscal calendar and FX rates so the server works when
+// the files aren't next to the binary.
+package config
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// Defaults holds forecast_prompt.tmpl, holidays.yaml, store_closures.yaml,
+// alert_rules.yaml, fiscal_calendar.yaml and fx_rates.yaml
+//
+//go:embed forecast_prompt.tmpl holidays.yaml store_closures.yaml alert_rules.yaml fiscal_calendar.yaml fx_rates.yaml
+var Defaults embed.FS
+
+// Read returns the contents of the named file, or of the embedded default
+// with the same base name when the file doesn't exist
+func Read(name string) ([]byte, error) {
+	contents, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Defaults.ReadFile(path.Base(name))
+	}
+	return contents, err
+}
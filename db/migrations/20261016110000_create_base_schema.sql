@@ -0,0 +1,79 @@
+-- +goose Up
+-- The source tables and the data warehouse table the later migrations alter.
+-- Databases created before this migration already have them, so every table
+-- is created only when missing.
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    parent_id INTEGER REFERENCES categories(id)
+);
+
+CREATE TABLE IF NOT EXISTS companies (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS customers (
+    id SERIAL PRIMARY KEY,
+    first_name VARCHAR(100),
+    last_name VARCHAR(100),
+    email VARCHAR(255),
+    phone_number VARCHAR(50)
+);
+
+-- status is 1 for active and 0 for archived products
+CREATE TABLE IF NOT EXISTS products (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    price DECIMAL(12,2) NOT NULL,
+    category_id INTEGER NOT NULL REFERENCES categories(id),
+    company_id INTEGER NOT NULL REFERENCES companies(id),
+    sku VARCHAR(100) UNIQUE,
+    quantity DECIMAL(12,2) NOT NULL DEFAULT 0,
+    status SMALLINT NOT NULL DEFAULT 1
+);
+
+-- status is invoice, or refund for sales netted out of the totals
+CREATE TABLE IF NOT EXISTS sale_transactions (
+    id SERIAL PRIMARY KEY,
+    customer_id INTEGER REFERENCES customers(id),
+    company_id INTEGER REFERENCES companies(id),
+    date_recorded TIMESTAMP NOT NULL,
+    total_amount DECIMAL(12,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'invoice'
+);
+
+CREATE INDEX IF NOT EXISTS idx_sale_transactions_date_recorded ON sale_transactions (date_recorded);
+
+CREATE TABLE IF NOT EXISTS sale_transaction_items (
+    id SERIAL PRIMARY KEY,
+    sale_transaction_id INTEGER NOT NULL REFERENCES sale_transactions(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES products(id),
+    quantity INTEGER NOT NULL,
+    total_amount DECIMAL(12,2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sale_transaction_items_sale_transaction_id ON sale_transaction_items (sale_transaction_id);
+CREATE INDEX IF NOT EXISTS idx_sale_transaction_items_product_id ON sale_transaction_items (product_id);
+
+-- Sales totals per transaction and category, rebuilt by the sales totals
+-- batch. It has no foreign keys so reloading the source tables doesn't
+-- truncate it.
+CREATE TABLE IF NOT EXISTS sales_totals_by_category_dw (
+    date_recorded DATE NOT NULL,
+    sale_transaction_id INTEGER NOT NULL,
+    category_id INTEGER NOT NULL,
+    total_amount DECIMAL(12,2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sales_totals_by_category_dw_date_recorded ON sales_totals_by_category_dw (date_recorded, category_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS sales_totals_by_category_dw;
+DROP TABLE IF EXISTS sale_transaction_items;
+DROP TABLE IF EXISTS sale_transactions;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS customers;
+DROP TABLE IF EXISTS companies;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE sales_totals_by_category_dw ADD COLUMN deleted_at TIMESTAMP NULL;
+CREATE INDEX idx_sales_totals_by_category_dw_deleted_at ON sales_totals_by_category_dw (deleted_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_sales_totals_by_category_dw_deleted_at;
+ALTER TABLE sales_totals_by_category_dw DROP COLUMN deleted_at;
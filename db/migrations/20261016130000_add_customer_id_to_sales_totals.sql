@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE sales_totals_by_category_dw ADD COLUMN customer_id INTEGER NULL;
+
+-- Backfill from the source transactions so existing rows don't need a batch rerun
+UPDATE sales_totals_by_category_dw dw
+SET customer_id = st.customer_id
+FROM sale_transactions st
+WHERE dw.sale_transaction_id = st.id;
+
+CREATE INDEX idx_sales_totals_by_category_dw_customer_id ON sales_totals_by_category_dw (customer_id, date_recorded);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_sales_totals_by_category_dw_customer_id;
+ALTER TABLE sales_totals_by_category_dw DROP COLUMN customer_id;
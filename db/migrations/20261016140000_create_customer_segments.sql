@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE customer_segments (
+    customer_id INTEGER PRIMARY KEY,
+    recency_days INTEGER NOT NULL,
+    frequency INTEGER NOT NULL,
+    monetary NUMERIC(14, 2) NOT NULL,
+    recency_score SMALLINT NOT NULL,
+    frequency_score SMALLINT NOT NULL,
+    monetary_score SMALLINT NOT NULL,
+    segment VARCHAR(50) NOT NULL,
+    computed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_customer_segments_segment ON customer_segments (segment);
+
+-- +goose Down
+DROP TABLE IF EXISTS customer_segments;
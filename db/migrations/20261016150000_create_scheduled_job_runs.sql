@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE scheduled_job_runs (
+    job_name VARCHAR(100) PRIMARY KEY,
+    last_run_at TIMESTAMP NOT NULL,
+    run_by VARCHAR(255) NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS scheduled_job_runs;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE export_watermarks (
+    sink VARCHAR(100) PRIMARY KEY,
+    exported_through DATE NOT NULL,
+    exported_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS export_watermarks;
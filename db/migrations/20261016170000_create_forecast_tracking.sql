@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE forecasts (
+    id BIGSERIAL PRIMARY KEY,
+    category_id INTEGER REFERENCES categories(id) ON DELETE CASCADE,
+    time_period VARCHAR(10) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    method VARCHAR(50),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE forecast_points (
+    forecast_id BIGINT NOT NULL REFERENCES forecasts(id) ON DELETE CASCADE,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    predicted DECIMAL(12,2) NOT NULL,
+    actual DECIMAL(12,2),
+    matched_at TIMESTAMP,
+    PRIMARY KEY (forecast_id, period_start)
+);
+
+CREATE INDEX idx_forecast_points_unmatched ON forecast_points (period_end) WHERE actual IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS forecast_points;
+DROP TABLE IF EXISTS forecasts;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE alert_firings (
+    rule_name VARCHAR(100) NOT NULL,
+    fired_for DATE NOT NULL,
+    fired_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (rule_name, fired_for)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS alert_firings;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE revenue_targets (
+    month DATE NOT NULL,
+    category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    target DECIMAL(12,2) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (month, category_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS revenue_targets;
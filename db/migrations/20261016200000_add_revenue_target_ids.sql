@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE revenue_targets ADD COLUMN id BIGSERIAL;
+ALTER TABLE revenue_targets ADD CONSTRAINT revenue_targets_id_key UNIQUE (id);
+
+-- +goose Down
+ALTER TABLE revenue_targets DROP COLUMN IF EXISTS id;
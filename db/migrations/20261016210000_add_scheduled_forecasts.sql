@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE forecasts ADD COLUMN scheduled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE forecasts ADD COLUMN superseded_at TIMESTAMP;
+
+CREATE INDEX idx_forecasts_current ON forecasts (category_id, time_period) WHERE scheduled AND superseded_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_forecasts_current;
+ALTER TABLE forecasts DROP COLUMN IF EXISTS superseded_at;
+ALTER TABLE forecasts DROP COLUMN IF EXISTS scheduled;
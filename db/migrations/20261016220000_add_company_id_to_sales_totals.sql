@@ -0,0 +1,15 @@
+-- +goose Up
+-- The company (store) that recorded the sale, so reports can group by store
+ALTER TABLE sales_totals_by_category_dw ADD COLUMN company_id INTEGER NULL;
+
+-- Backfill from the source transactions so existing rows don't need a batch rerun
+UPDATE sales_totals_by_category_dw dw
+SET company_id = st.company_id
+FROM sale_transactions st
+WHERE dw.sale_transaction_id = st.id;
+
+CREATE INDEX idx_sales_totals_by_category_dw_company_id ON sales_totals_by_category_dw (company_id, date_recorded);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_sales_totals_by_category_dw_company_id;
+ALTER TABLE sales_totals_by_category_dw DROP COLUMN company_id;
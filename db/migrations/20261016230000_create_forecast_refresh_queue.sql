@@ -0,0 +1,15 @@
+-- +goose Up
+-- Scheduled forecasts waiting to be regenerated because they went stale
+CREATE TABLE forecast_refresh_queue (
+    category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    time_period VARCHAR(10) NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    enqueued_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (category_id, time_period)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS forecast_refresh_queue;
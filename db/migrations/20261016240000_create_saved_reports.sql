@@ -0,0 +1,25 @@
+-- +goose Up
+-- Named report configurations that can be run by ID
+CREATE TABLE saved_reports (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    report VARCHAR(20) NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    last_days INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Email recipients of a saved report
+CREATE TABLE saved_report_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    saved_report_id BIGINT NOT NULL REFERENCES saved_reports(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    frequency VARCHAR(10) NOT NULL,
+    last_sent_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (saved_report_id, email)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS saved_report_subscriptions;
+DROP TABLE IF EXISTS saved_reports;
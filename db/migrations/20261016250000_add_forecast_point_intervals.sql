@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE forecast_points ADD COLUMN lower_80 DECIMAL(12,2);
+ALTER TABLE forecast_points ADD COLUMN upper_80 DECIMAL(12,2);
+ALTER TABLE forecast_points ADD COLUMN lower_95 DECIMAL(12,2);
+ALTER TABLE forecast_points ADD COLUMN upper_95 DECIMAL(12,2);
+
+-- +goose Down
+ALTER TABLE forecast_points DROP COLUMN IF EXISTS upper_95;
+ALTER TABLE forecast_points DROP COLUMN IF EXISTS lower_95;
+ALTER TABLE forecast_points DROP COLUMN IF EXISTS upper_80;
+ALTER TABLE forecast_points DROP COLUMN IF EXISTS lower_80;
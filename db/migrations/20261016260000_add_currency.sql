@@ -0,0 +1,15 @@
+-- +goose Up
+-- The ISO 4217 currency each sale was recorded in. Existing sales were all
+-- recorded in US dollars.
+ALTER TABLE sale_transactions ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+ALTER TABLE sales_totals_by_category_dw ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+-- Backfill from the source transactions so existing rows don't need a batch rerun
+UPDATE sales_totals_by_category_dw dw
+SET currency = st.currency
+FROM sale_transactions st
+WHERE dw.sale_transaction_id = st.id;
+
+-- +goose Down
+ALTER TABLE sales_totals_by_category_dw DROP COLUMN currency;
+ALTER TABLE sale_transactions DROP COLUMN currency;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- The status of the sale transaction each row totals (invoice, refund, or
+-- pending), so reports can filter and split by it. Refund rows stay negative.
+ALTER TABLE sales_totals_by_category_dw ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'invoice';
+
+-- Backfill from the source transactions so existing rows don't need a batch rerun
+UPDATE sales_totals_by_category_dw dw
+SET status = LOWER(st.status)
+FROM sale_transactions st
+WHERE dw.sale_transaction_id = st.id;
+
+-- +goose Down
+ALTER TABLE sales_totals_by_category_dw DROP COLUMN status;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- The parts of each row's net total_amount: the list value of the items sold,
+-- how much less than list price they sold for, and the value refunded, so
+-- total_amount = gross_amount - discount_amount - refund_amount.
+ALTER TABLE sales_totals_by_category_dw ADD COLUMN gross_amount DECIMAL(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE sales_totals_by_category_dw ADD COLUMN discount_amount DECIMAL(12,2) NOT NULL DEFAULT 0;
+ALTER TABLE sales_totals_by_category_dw ADD COLUMN refund_amount DECIMAL(12,2) NOT NULL DEFAULT 0;
+
+-- Rows whose transaction no longer exists can only be split by sign
+UPDATE sales_totals_by_category_dw
+SET gross_amount = GREATEST(total_amount, 0),
+    refund_amount = GREATEST(-total_amount, 0);
+
+-- Backfill the rest from the source items so existing rows don't need a
+-- batch rerun
+UPDATE sales_totals_by_category_dw dw
+SET gross_amount = CASE WHEN dw.status = 'refund' THEN 0 ELSE items.gross_amount END,
+    discount_amount = CASE WHEN dw.status = 'refund' THEN 0 ELSE items.discount_amount END,
+    refund_amount = CASE WHEN dw.status = 'refund' THEN items.total_amount ELSE 0 END
+FROM (
+    SELECT
+        sti.sale_transaction_id,
+        p.category_id,
+        SUM(GREATEST(p.price * sti.quantity, sti.total_amount)) AS gross_amount,
+        SUM(GREATEST(p.price * sti.quantity - sti.total_amount, 0)) AS discount_amount,
+        SUM(sti.total_amount) AS total_amount
+    FROM sale_transaction_items sti
+    JOIN products p ON sti.product_id = p.id
+    GROUP BY sti.sale_transaction_id, p.category_id
+) items
+WHERE dw.sale_transaction_id = items.sale_transaction_id
+    AND dw.category_id = items.category_id;
+
+-- +goose Down
+ALTER TABLE sales_totals_by_category_dw DROP COLUMN refund_amount;
+ALTER TABLE sales_totals_by_category_dw DROP COLUMN discount_amount;
+ALTER TABLE sales_totals_by_category_dw DROP COLUMN gross_amount;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- The units of each row's items, net of refunds like total_amount
+ALTER TABLE sales_totals_by_category_dw ADD COLUMN quantity INTEGER NOT NULL DEFAULT 0;
+
+-- Backfill from the source items so existing rows don't need a batch rerun
+UPDATE sales_totals_by_category_dw dw
+SET quantity = CASE WHEN dw.status = 'refund' THEN -items.quantity ELSE items.quantity END
+FROM (
+    SELECT sti.sale_transaction_id, p.category_id, SUM(sti.quantity) AS quantity
+    FROM sale_transaction_items sti
+    JOIN products p ON sti.product_id = p.id
+    GROUP BY sti.sale_transaction_id, p.category_id
+) items
+WHERE dw.sale_transaction_id = items.sale_transaction_id
+    AND dw.category_id = items.category_id;
+
+-- +goose Down
+ALTER TABLE sales_totals_by_category_dw DROP COLUMN quantity;
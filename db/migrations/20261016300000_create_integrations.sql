@@ -0,0 +1,24 @@
+-- +goose Up
+-- The rows records synced from external systems such as Shopify were saved
+-- as, keyed by the system (source), the kind of record (order, refund,
+-- product, customer, ...), and its ID there, so later syncs update the rows
+-- instead of duplicating them
+CREATE TABLE integration_links (
+    source VARCHAR(50) NOT NULL,
+    kind VARCHAR(50) NOT NULL,
+    external_id VARCHAR(255) NOT NULL,
+    record_id INTEGER NOT NULL,
+    synced_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source, kind, external_id)
+);
+
+-- Where each source's incremental sync resumes from, in the source's terms
+CREATE TABLE integration_cursors (
+    source VARCHAR(50) PRIMARY KEY,
+    sync_cursor TEXT NOT NULL,
+    synced_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS integration_cursors;
+DROP TABLE IF EXISTS integration_links;
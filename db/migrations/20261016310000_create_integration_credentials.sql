@@ -0,0 +1,14 @@
+-- +goose Up
+-- The OAuth tokens of sources connected by an admin, such as Square, and the
+-- account they were granted for
+CREATE TABLE integration_credentials (
+    source VARCHAR(50) PRIMARY KEY,
+    account_id VARCHAR(255) NOT NULL,
+    access_token TEXT NOT NULL,
+    refresh_token TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS integration_credentials;
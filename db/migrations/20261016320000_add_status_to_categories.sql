@@ -0,0 +1,7 @@
+-- +goose Up
+-- status is 1 for active and 0 for archived categories, like products'.
+-- Archived categories keep their history in reports but take no new products.
+ALTER TABLE categories ADD COLUMN status SMALLINT NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE categories DROP COLUMN status;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- The history of batch job runs, whether started from the API, the scheduler,
+-- or the command line, with the part of the data each one covered
+CREATE TABLE batch_runs (
+    id SERIAL PRIMARY KEY,
+    job VARCHAR(100) NOT NULL,
+    triggered_by VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    start_date DATE NULL,
+    end_date DATE NULL,
+    category_id INTEGER NULL,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP NULL,
+    rows_replaced INTEGER NULL,
+    rows_written INTEGER NULL,
+    error TEXT NULL
+);
+
+CREATE INDEX idx_batch_runs_job_started_at ON batch_runs (job, started_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS batch_runs;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- Daily totals per product and hourly totals per category, rebuilt by the
+-- sales totals batch in the same pass as sales_totals_by_category_dw so
+-- reports can read them instead of aggregating raw transactions. Like that
+-- table they have no foreign keys. Rows are split by currency and by the
+-- status of the transactions totalled (invoice, refund, or pending); refund
+-- rows stay negative. transactions counts the transactions in each row.
+CREATE TABLE sales_totals_by_product_dw (
+    date_recorded DATE NOT NULL,
+    product_id INTEGER NOT NULL,
+    category_id INTEGER NOT NULL,
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    transactions INTEGER NOT NULL,
+    quantity INTEGER NOT NULL,
+    total_amount DECIMAL(12,2) NOT NULL,
+    gross_amount DECIMAL(12,2) NOT NULL,
+    discount_amount DECIMAL(12,2) NOT NULL,
+    refund_amount DECIMAL(12,2) NOT NULL,
+    PRIMARY KEY (date_recorded, product_id, currency, status)
+);
+
+CREATE INDEX idx_sales_totals_by_product_dw_category ON sales_totals_by_product_dw (category_id, date_recorded);
+
+CREATE TABLE sales_totals_by_hour_dw (
+    hour TIMESTAMP NOT NULL,
+    category_id INTEGER NOT NULL,
+    currency CHAR(3) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    transactions INTEGER NOT NULL,
+    quantity INTEGER NOT NULL,
+    total_amount DECIMAL(12,2) NOT NULL,
+    gross_amount DECIMAL(12,2) NOT NULL,
+    discount_amount DECIMAL(12,2) NOT NULL,
+    refund_amount DECIMAL(12,2) NOT NULL,
+    PRIMARY KEY (hour, category_id, currency, status)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS sales_totals_by_hour_dw;
+DROP TABLE IF EXISTS sales_totals_by_product_dw;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Problems in the source data found by the sales totals batch before it
+-- aggregates, such as items whose product is missing. Each run replaces the
+-- issues recorded for the dates it covers, so the table lists what is still
+-- wrong. Like the DW tables it has no foreign keys, as it records references
+-- that are broken.
+CREATE TABLE data_quality_issues (
+    id SERIAL PRIMARY KEY,
+    check_name VARCHAR(50) NOT NULL,
+    sale_transaction_id INTEGER NOT NULL,
+    sale_transaction_item_id INTEGER NULL,
+    product_id INTEGER NULL,
+    category_id INTEGER NULL,
+    date_recorded DATE NOT NULL,
+    detail TEXT NOT NULL,
+    detected_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_data_quality_issues_date_recorded ON data_quality_issues (date_recorded, check_name);
+
+-- +goose Down
+DROP TABLE IF EXISTS data_quality_issues;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- OAuth states of connections an admin has started, each consumed by the
+-- first callback that returns it
+CREATE TABLE integration_oauth_states (
+    state VARCHAR(64) PRIMARY KEY,
+    source VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS integration_oauth_states;
@@ -0,0 +1,131 @@
+// Package migrations embeds the Goose migration files so the binaries can
+// apply them and check which schema version they expect.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FS holds the migration files
+//
+//go:embed *.sql
+var FS embed.FS
+
+// Migration is one migration file, split into its Up and Down sections
+type Migration struct {
+	// Version is the timestamp prefix of the file name
+	Version int64
+	File    string
+	Up      string
+	Down    string
+}
+
+// All returns the migrations in version order
+func All() ([]Migration, error) {
+	files, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Migration, 0, len(files))
+	seen := make(map[int64]string, len(files))
+	for _, file := range files {
+		version, err := fileVersion(file)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", other, file)
+		}
+		seen[version] = file
+
+		data, err := FS.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		migration, err := parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: %v", file, err)
+		}
+		migration.Version, migration.File = version, file
+		result = append(result, migration)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// LatestVersion returns the version of the newest migration, taken from the
+// timestamp prefix of its file name
+func LatestVersion() (int64, error) {
+	files, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest int64
+	for _, file := range files {
+		version, err := fileVersion(file)
+		if err != nil {
+			return 0, err
+		}
+		if version > latest {
+			latest = version
+		}
+	}
+
+	return latest, nil
+}
+
+// fileVersion reads the version prefix of a migration file name
+func fileVersion(file string) (int64, error) {
+	prefix, _, ok := strings.Cut(file, "_")
+	if !ok {
+		return 0, fmt.Errorf("migration %s has no version prefix", file)
+	}
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("migration %s has an invalid version: %v", file, err)
+	}
+	return version, nil
+}
+
+// parse splits a migration at its "-- +goose Up" and "-- +goose Down"
+// annotations. Each section runs as one script, so StatementBegin and
+// StatementEnd annotations aren't needed and are ignored.
+func parse(data string) (Migration, error) {
+	var (
+		migration Migration
+		section   *string
+		hasUp     bool
+	)
+	for _, line := range strings.SplitAfter(data, "\n") {
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose ")
+		if !ok {
+			if section != nil {
+				*section += line
+			}
+			continue
+		}
+		switch strings.TrimSpace(annotation) {
+		case "Up":
+			section, hasUp = &migration.Up, true
+		case "Down":
+			section = &migration.Down
+		case "StatementBegin", "StatementEnd":
+		default:
+			return Migration{}, fmt.Errorf("unsupported annotation %q", strings.TrimSpace(line))
+		}
+	}
+	if !hasUp {
+		return Migration{}, fmt.Errorf("no -- +goose Up section")
+	}
+
+	migration.Up, migration.Down = strings.TrimSpace(migration.Up), strings.TrimSpace(migration.Down)
+	return migration, nil
+}
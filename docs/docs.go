@@ -15,9 +15,191 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/sales/forecast": {
+        "/admin/batch/runs": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns recorded batch runs, newest first, with their trigger, status, duration, and row counts. Runs are recorded whether started through the API, by the scheduler, or from the command line.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List batch runs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only runs of this job, e.g. sales-totals",
+                        "name": "job",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "running",
+                            "succeeded",
+                            "failed",
+                            "skipped"
+                        ],
+                        "type": "string",
+                        "description": "Only runs with this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of runs (default 50, max 500)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Batch runs",
+                        "schema": {
+                            "$ref": "#/definitions/services.BatchRunsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/batch/sales-totals": {
             "post": {
-                "description": "Sends time series data to ChatGPT for forecasting and returns predicted values for daily, weekly, and monthly periods",
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Starts regenerating sales_totals_by_category_dw in the background, optionally narrowed to a date range and category, and returns the run recorded in batch_runs. The run is skipped if another regeneration holds the batch lock. Poll GET /admin/batch/runs for its result.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Start a sales totals regeneration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First date regenerated, YYYY-MM-DD",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last date regenerated, YYYY-MM-DD",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only regenerate this category",
+                        "name": "category_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Run started",
+                        "schema": {
+                            "$ref": "#/definitions/batch.Run"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid range or category",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/chaos": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns whether chaos mode is enabled and the faults configured per dependency",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get failure-injection configuration",
+                "responses": {
+                    "200": {
+                        "description": "Current chaos configuration",
+                        "schema": {
+                            "$ref": "#/definitions/services.ChaosConfigResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Removes all configured faults",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reset failure injection",
+                "responses": {
+                    "204": {
+                        "description": "Faults removed"
+                    }
+                }
+            }
+        },
+        "/admin/chaos/{target}": {
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Sets the latency and error rates injected into the db or llm dependency. Requires CHAOS_ENABLED=true.",
                 "consumes": [
                     "application/json"
                 ],
@@ -25,167 +207,5008 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "sales"
+                    "admin"
                 ],
-                "summary": "Generate sales forecast using ChatGPT",
+                "summary": "Configure failure injection for a dependency",
                 "parameters": [
                     {
-                        "description": "Forecast request with time series data",
-                        "name": "request",
+                        "type": "string",
+                        "description": "Dependency to inject faults into (db or llm)",
+                        "name": "target",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fault configuration",
+                        "name": "fault",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/services.ForecastRequest"
+                            "$ref": "#/definitions/chaos.Fault"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Forecast data with predicted values for all time periods",
+                        "description": "Updated chaos configuration",
                         "schema": {
-                            "$ref": "#/definitions/services.ForecastResponse"
+                            "$ref": "#/definitions/services.ChaosConfigResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad request - invalid data",
+                        "description": "Bad request - invalid fault",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/httperror.Envelope"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "409": {
+                        "description": "Chaos mode is disabled",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/httperror.Envelope"
                         }
                     }
                 }
             }
         },
-        "/sales/report/category": {
+        "/admin/data-quality/issues": {
             "get": {
-                "description": "Returns aggregated sales data by date and category with calculated total amounts",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Returns the problems the sales totals batch found in the source data before aggregating, newest transactions first, with the number found by each check. Each batch run replaces the issues for the dates it covers, so fixed data drops out after the next run.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "sales"
+                    "admin"
                 ],
-                "summary": "Get sales report by category",
+                "summary": "List data quality issues",
                 "parameters": [
                     {
+                        "enum": [
+                            "missing_product",
+                            "orphaned_category",
+                            "negative_quantity",
+                            "future_date"
+                        ],
                         "type": "string",
-                        "description": "Start date in YYYY-MM-DD format (defaults to 30 days ago)",
+                        "description": "Only issues found by this check",
+                        "name": "check",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only transactions recorded on or after this date, YYYY-MM-DD",
                         "name": "start_date",
                         "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "End date in YYYY-MM-DD format (defaults to today)",
+                        "description": "Only transactions recorded on or before this date, YYYY-MM-DD",
                         "name": "end_date",
                         "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of issues (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Sales report data with dates as keys and category arrays as values",
+                        "description": "Data quality issues",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "array",
-                                "items": {
-                                    "$ref": "#/definitions/services.CategoryTotal"
-                                }
-                            }
+                            "$ref": "#/definitions/services.DataQualityIssuesResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad request - invalid date format",
+                        "description": "Bad request - invalid filter",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/httperror.Envelope"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/httperror.Envelope"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "services.CategoryTotal": {
-            "type": "object",
-            "properties": {
-                "category_name": {
-                    "type": "string"
-                },
-                "total_amount": {
-                    "type": "number"
-                }
-            }
         },
-        "services.ForecastRequest": {
-            "type": "object",
-            "properties": {
-                "timePeriod": {
-                    "description": "TimePeriod is now optional - if not specified, all periods will be generated",
-                    "type": "string"
-                },
-                "timeSeriesData": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/services.TimeSeriesPoint"
+        "/admin/prompts": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the version and source of the forecast prompt template and holiday calendar currently in use",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Get prompt configuration",
+                "responses": {
+                    "200": {
+                        "description": "Loaded prompt configuration",
+                        "schema": {
+                            "$ref": "#/definitions/prompts.Status"
+                        }
+                    },
+                    "500": {
+                        "description": "Prompt configuration failed to load",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
                     }
                 }
             }
         },
-        "services.ForecastResponse": {
-            "type": "object",
-            "properties": {
-                "forecast": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/services.TimeSeriesPoint"
+        "/admin/prompts/reload": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Reloads the forecast prompt template and holiday calendar from disk. If either file is invalid the previous configuration stays in use.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reload prompt configuration",
+                "responses": {
+                    "200": {
+                        "description": "Reloaded prompt configuration",
+                        "schema": {
+                            "$ref": "#/definitions/prompts.Status"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid prompt template or holiday calendar",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Prompt configuration failed to load",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
                     }
-                },
-                "message": {
-                    "type": "string"
-                },
-                "rawResponse": {
-                    "type": "string"
-                },
-                "timePeriod": {
-                    "type": "string"
                 }
             }
         },
-        "services.TimeSeriesPoint": {
-            "type": "object",
-            "properties": {
-                "period": {
-                    "type": "string"
-                },
-                "total": {
-                    "type": "number"
-                }
-            }
+        "/admin/sales-totals": {
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Marks sales_totals_by_category_dw rows in the date range as deleted so they are excluded from reports. Deleted rows survive batch regeneration until restored.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Soft delete warehouse rows",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start date in YYYY-MM-DD format",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date in YYYY-MM-DD format",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only rows for this category",
+                        "name": "category_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only rows for this sale transaction",
+                        "name": "sale_transaction_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Number of rows soft deleted",
+                        "schema": {
+                            "$ref": "#/definitions/services.SalesTotalsChangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/sales-totals/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Clears the deleted flag on sales_totals_by_category_dw rows in the date range so they are included in reports again",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Restore soft-deleted warehouse rows",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start date in YYYY-MM-DD format",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date in YYYY-MM-DD format",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only rows for this category",
+                        "name": "category_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only rows for this sale transaction",
+                        "name": "sale_transaction_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Number of rows restored",
+                        "schema": {
+                            "$ref": "#/definitions/services.SalesTotalsChangeResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories": {
+            "get": {
+                "description": "Returns product categories ordered by name, optionally only those whose name contains q (case-insensitive). Archived categories are excluded unless include_archived is set.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "List categories",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only categories whose name contains this text",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include archived categories (defaults to false)",
+                        "name": "include_archived",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Categories",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.Category"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates a product category. Names must be unique (case-insensitive) and the parent must exist.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Create a category",
+                "parameters": [
+                    {
+                        "description": "Category to create",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.CategoryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created category",
+                        "schema": {
+                            "$ref": "#/definitions/services.Category"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid category",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Category name already exists",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories/{id}": {
+            "get": {
+                "description": "Returns a single product category, including archived ones",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Get a category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Category",
+                        "schema": {
+                            "$ref": "#/definitions/services.Category"
+                        }
+                    },
+                    "404": {
+                        "description": "Category not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Renames or re-parents a product category. A category can't become its own ancestor.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Update a category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated category",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.CategoryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated category",
+                        "schema": {
+                            "$ref": "#/definitions/services.Category"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid category",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Category not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Name already exists or parent would create a cycle",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Deletes a product category. Categories still referenced by products or warehouse rows can only be deleted with reassign_to, which moves those products and warehouse rows to another category. Child categories are moved to the deleted category's parent.",
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Delete a category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Category to move products and warehouse rows to",
+                        "name": "reassign_to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Category deleted"
+                    },
+                    "400": {
+                        "description": "Bad request - invalid category",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Category not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Category is still in use",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories/{id}/archive": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Archives a category so it is hidden from category lists and takes no new products, while its history stays in reports. Categories with active products or active child categories can't be archived; archive or move those first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Archive a category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Archived category",
+                        "schema": {
+                            "$ref": "#/definitions/services.Category"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid category",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Category not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Category is still in use",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Makes an archived category active again. Its parent must be active.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "categories"
+                ],
+                "summary": "Restore a category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Restored category",
+                        "schema": {
+                            "$ref": "#/definitions/services.Category"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid category",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Category not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Parent category is archived",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/customers/segments": {
+            "get": {
+                "description": "Returns the recency/frequency/monetary scores and segment of each customer, as computed by the customer segments batch job, plus a per-segment summary",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "customers"
+                ],
+                "summary": "Get RFM customer segments",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only customers in this segment (e.g. Champions, At Risk)",
+                        "name": "segment",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Customer segments",
+                        "schema": {
+                            "$ref": "#/definitions/services.CustomerSegmentsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/forecasts/accuracy": {
+            "get": {
+                "description": "Returns the MAPE and bias of tracked forecasts (sent with track set) and scheduled forecasts against actual revenue from the DW table, newest first, plus a summary per category and a rolling summary per forecaster (the LLM or a statistical method) ranked by MAPE over the periods that ended in the last window_days. Actuals are filled in by the forecast-accuracy job once each forecast period has ended. Also served at /sales/forecast/accuracy.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get forecast accuracy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only forecasts for this category",
+                        "name": "category_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only forecasts of this time period (day, week, iso_week, month, fiscal)",
+                        "name": "time_period",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only forecasts from this source (llm, fallback, deterministic)",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of forecasts (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Days of ended periods the method summary covers (default 90, max 3650)",
+                        "name": "window_days",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Forecast accuracy",
+                        "schema": {
+                            "$ref": "#/definitions/services.ForecastAccuracyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/forecasts/freshness": {
+            "get": {
+                "description": "Lists every category's precomputed forecast for each time period with its age, whether it is stale, and whether it is queued for regeneration (reason age or actuals).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get the freshness of precomputed forecasts",
+                "responses": {
+                    "200": {
+                        "description": "Forecast freshness per category and time period",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.ForecastFreshness"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/forecasts/latest": {
+            "get": {
+                "description": "Returns the current forecast made by the scheduled forecast-refresh job for a category and time period, without calling the LLM. The forecast ID can be looked up in the accuracy endpoint.",
+                "produces": [
+                    "application/json",
+                    "application/x-protobuf",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get the precomputed forecast for a category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "category_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time period: day, week, or month (defaults to month)",
+                        "name": "time_period",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Response schema version: 1 (deprecated) or 2 (default)",
+                        "name": "schema",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Response schema version, when schema isn't set",
+                        "name": "X-Schema-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Precomputed forecast",
+                        "schema": {
+                            "$ref": "#/definitions/services.ForecastResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "No precomputed forecast",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/health": {
+            "get": {
+                "description": "Checks that the database is reachable, the newest migration has been applied, and every table and column the services need exists",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Get service health",
+                "responses": {
+                    "200": {
+                        "description": "Service is healthy",
+                        "schema": {
+                            "$ref": "#/definitions/services.HealthResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service is degraded",
+                        "schema": {
+                            "$ref": "#/definitions/services.HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics": {
+            "get": {
+                "description": "Exposes business KPIs as Prometheus gauges: yesterday's net revenue per category and in total, the baseline forecast of that revenue and the relative deviation from it, and the refund rate. Values are cached for KPI_CACHE_SECONDS. LLM requests, prompt and completion tokens, and estimated cost since startup follow as counters per provider and model. The database pool's connection counts and waits close the output.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Get business KPI metrics",
+                "responses": {
+                    "200": {
+                        "description": "Metrics in the Prometheus text exposition format",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/products": {
+            "get": {
+                "description": "Returns products ordered by name, optionally filtered by category and by q, which matches text in the name or SKU (case-insensitive). Archived products are excluded unless include_archived is set.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "List products",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only products in this category",
+                        "name": "category_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only products whose name or SKU contains this text",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include archived products (defaults to false)",
+                        "name": "include_archived",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Products",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.Product"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates an active product. The category must exist and be active, and the SKU must be unique.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Create a product",
+                "parameters": [
+                    {
+                        "description": "Product to create",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ProductRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created product",
+                        "schema": {
+                            "$ref": "#/definitions/services.Product"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid product",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Unknown or archived category, or duplicate SKU",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/replenishment": {
+            "get": {
+                "description": "Forecasts each active product's unit demand over the lead time from its recent weekly sales and suggests an order quantity that covers that demand plus safety stock, net of current stock. Use format=csv or send Accept: text/csv to download the report, or format=arrow to stream it as Arrow IPC record batches.",
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/vnd.apache.arrow.stream"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Get replenishment suggestions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Days between ordering and receiving stock (defaults to REPLENISHMENT_LEAD_TIME_DAYS or 14)",
+                        "name": "lead_time_days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Days of average demand to hold as safety stock (defaults to REPLENISHMENT_SAFETY_STOCK_DAYS or 7)",
+                        "name": "safety_stock_days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Days of sales history to forecast from (defaults to 90)",
+                        "name": "history_days",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only products in this category",
+                        "name": "category_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json, csv, or arrow (defaults to json)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Suggested orders",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.ReplenishmentSuggestion"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/{id}": {
+            "get": {
+                "description": "Returns a single product, including archived ones",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Get a product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Product",
+                        "schema": {
+                            "$ref": "#/definitions/services.Product"
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Updates a product. If the category changes, the warehouse rows of every transaction containing the product are re-aggregated in the same transaction.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Update a product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated product",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ProductRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated product",
+                        "schema": {
+                            "$ref": "#/definitions/services.Product"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid product",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Unknown or archived category, or duplicate SKU",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Archives a product so it is hidden from product lists. Products are never hard deleted because sale transactions reference them; archived products keep their history in reports and can be restored.",
+                "tags": [
+                    "products"
+                ],
+                "summary": "Archive a product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Product archived"
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/{id}/category": {
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Moves a product to another category and re-aggregates the warehouse rows of every transaction containing it, so reports reflect the new category",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Reassign a product's category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New category",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ProductCategoryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated product",
+                        "schema": {
+                            "$ref": "#/definitions/services.Product"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid category",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Unknown or archived category",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Makes an archived product active again. Its category must be active.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Restore a product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Restored product",
+                        "schema": {
+                            "$ref": "#/definitions/services.Product"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid product",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Category is archived",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/readyz": {
+            "get": {
+                "description": "Checks that the database is reachable and reports the cached LLM health from the background probe. The LLM is never called by this check. Reports draining with 503 once the server is shutting down.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Get service readiness",
+                "responses": {
+                    "200": {
+                        "description": "Service is ready",
+                        "schema": {
+                            "$ref": "#/definitions/services.ReadinessResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service is not ready",
+                        "schema": {
+                            "$ref": "#/definitions/services.ReadinessResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/saved": {
+            "get": {
+                "description": "Returns all saved report definitions ordered by name",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "List saved reports",
+                "responses": {
+                    "200": {
+                        "description": "Saved reports",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.SavedReport"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Saves a named report configuration: the report (category, customers, compare, comparison, or variance) and its query parameters, such as group_by, period, fill, and format. Set last_days instead of fixed dates to always cover the last N days. Parameter values are checked when the report runs.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Save a report definition",
+                "parameters": [
+                    {
+                        "description": "Report definition",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.SavedReportRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Saved report",
+                        "schema": {
+                            "$ref": "#/definitions/services.SavedReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid definition",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Name already exists",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/saved/{id}": {
+            "get": {
+                "description": "Returns a saved report definition",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Get a saved report",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Saved report ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Saved report",
+                        "schema": {
+                            "$ref": "#/definitions/services.SavedReport"
+                        }
+                    },
+                    "404": {
+                        "description": "Saved report not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Deletes a saved report definition and its subscriptions",
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Delete a saved report",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Saved report ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Saved report deleted"
+                    },
+                    "404": {
+                        "description": "Saved report not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/saved/{id}/run": {
+            "get": {
+                "description": "Runs a saved report with its saved parameters and returns the report's own response. Query parameters given here override the saved ones, e.g. format=arrow.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Run a saved report",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Saved report ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The report's response",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid saved parameter",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Saved report not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/saved/{id}/subscriptions": {
+            "get": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the email subscriptions of a saved report",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "List a saved report's subscriptions",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Saved report ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Subscriptions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.ReportSubscription"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Saved report not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Emails a link to run the saved report to an address daily or weekly. Delivery needs ALERT_SMTP_ADDR and ALERT_EMAIL_FROM.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Subscribe to a saved report",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Saved report ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Subscription",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ReportSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created subscription",
+                        "schema": {
+                            "$ref": "#/definitions/services.ReportSubscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid email or frequency",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Saved report not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "409": {
+                        "description": "Address already subscribed",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/reports/saved/{id}/subscriptions/{subscription_id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stops emailing a saved report to an address",
+                "tags": [
+                    "reports"
+                ],
+                "summary": "Delete a report subscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Saved report ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Subscription ID",
+                        "name": "subscription_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Subscription deleted"
+                    },
+                    "404": {
+                        "description": "Subscription not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/forecast": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Forecasts the time series with the LLM and returns the predicted values with 80% and 95% confidence bands. LLM failures are errors unless FORECAST_FALLBACK is statistical, which falls back to a statistical forecast; deterministic always uses it. LLM forecasts are checked against the statistical forecast's periods, with repairs listed in meta.repairs. The request fields are described in ForecastRequest.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-protobuf",
+                    "application/msgpack",
+                    "text/event-stream",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Generate sales forecast using ChatGPT",
+                "parameters": [
+                    {
+                        "description": "Forecast request with time series data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ForecastRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Response schema version: 2 (default) is the flat forecast array, 1 (deprecated) keys the forecast by daily, weekly, or monthly",
+                        "name": "schema",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Stream Server-Sent Events instead: a point event per forecast point as the LLM writes it, then a forecast event with the finished response, which replaces the points",
+                        "name": "stream",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json or xlsx (defaults to json, or the Accept header); the workbook has a summary sheet and a sheet for the horizon, or one per category, each with a Total row",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "application/x-protobuf for the craftdemo.v1.ForecastResponse message, or application/msgpack for the JSON body as MessagePack",
+                        "name": "Accept",
+                        "in": "header"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Response schema version, when schema isn't set",
+                        "name": "X-Schema-Version",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "With categories, the forecast of each category",
+                        "schema": {
+                            "$ref": "#/definitions/services.CategoryForecasts"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid data",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error, or the LLM forecast failed and FORECAST_FALLBACK isn't statistical",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "504": {
+                        "description": "Deadline exceeded - an error, or the statistical fallback forecast when FORECAST_FALLBACK is statistical",
+                        "schema": {
+                            "$ref": "#/definitions/services.ForecastResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/forecast/accuracy": {
+            "get": {
+                "description": "Returns the MAPE and bias of tracked forecasts (sent with track set) and scheduled forecasts against actual revenue from the DW table, newest first, plus a summary per category and a rolling summary per forecaster (the LLM or a statistical method) ranked by MAPE over the periods that ended in the last window_days. Actuals are filled in by the forecast-accuracy job once each forecast period has ended. Also served at /sales/forecast/accuracy.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get forecast accuracy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only forecasts for this category",
+                        "name": "category_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only forecasts of this time period (day, week, iso_week, month, fiscal)",
+                        "name": "time_period",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only forecasts from this source (llm, fallback, deterministic)",
+                        "name": "source",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of forecasts (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Days of ended periods the method summary covers (default 90, max 3650)",
+                        "name": "window_days",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Forecast accuracy",
+                        "schema": {
+                            "$ref": "#/definitions/services.ForecastAccuracyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid parameter",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/forecast/backtest": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Splits the time series into expanding train windows, each followed by a holdout window of the forecast horizon, forecasts every holdout with each method (llm for ChatGPT or a statistical method such as moving_average or arima), and returns the MAE, RMSE, and MAPE per horizon and overall. Statistical methods use a fixed seed. LLM folds that fail are counted in failedFolds.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Backtest forecasters against held-out data",
+                "parameters": [
+                    {
+                        "description": "Time series, methods, and folds",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.BacktestRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Errors per method and horizon",
+                        "schema": {
+                            "$ref": "#/definitions/services.BacktestResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid data or too little history",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/forecast/category/{categoryId}": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Forecasts a category from its complete periods in the DW table, the same history the scheduled forecast-refresh job uses: 90 days, 52 weeks, or 2 years. The body is optional and takes the same settings as POST /sales/forecast except timeSeriesData and categoryId; timePeriod must be day, week, or month. The same forecast is made by POST /sales/forecast with categoryId and no timeSeriesData. Use format=xlsx for an Excel workbook.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-protobuf",
+                    "application/msgpack",
+                    "text/event-stream",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Forecast a category from its sales history",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "categoryId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Forecast settings",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/services.ForecastRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Response schema version: 1 (deprecated) or 2 (default)",
+                        "name": "schema",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Response schema version, when schema isn't set",
+                        "name": "X-Schema-Version",
+                        "in": "header"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Stream the forecast as Server-Sent Events",
+                        "name": "stream",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json or xlsx (defaults to json, or the Accept header)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Forecast of the category",
+                        "schema": {
+                            "$ref": "#/definitions/services.ForecastResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request, or no history for the category",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Category not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error, or the LLM forecast failed and FORECAST_FALLBACK isn't statistical",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "504": {
+                        "description": "Deadline exceeded - an error, or the statistical fallback forecast when FORECAST_FALLBACK is statistical",
+                        "schema": {
+                            "$ref": "#/definitions/services.ForecastResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/goals/{id}/progress": {
+            "get": {
+                "description": "Combines month-to-date actual revenue with a baseline forecast of the remaining days to project end-of-month attainment of a sales goal, and the daily run-rate still needed to reach it. Goals are the monthly revenue targets; the id is the one returned by GET /targets.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get sales goal progress",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Goal (revenue target) ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date in YYYY-MM-DD format; days before it are actual, it and later days are forecast (defaults to today)",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Goal progress",
+                        "schema": {
+                            "$ref": "#/definitions/services.GoalProgress"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid id or date",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Goal not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/promotions/impact": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Compares actual daily sales of a category during a promotion window with a counterfactual forecast trained on the days before it, and asks ChatGPT for a structured summary of the lift. The numeric lift is returned even if ChatGPT is unavailable.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Analyze the sales impact of a promotion",
+                "parameters": [
+                    {
+                        "description": "Promotion window and category",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.PromotionImpactRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Actual vs counterfactual sales with lift and analysis",
+                        "schema": {
+                            "$ref": "#/definitions/services.PromotionImpactResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid data",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Category not found or no training data",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "504": {
+                        "description": "Deadline exceeded - lift without analysis",
+                        "schema": {
+                            "$ref": "#/definitions/services.PromotionImpactResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/report/category": {
+            "get": {
+                "description": "Returns aggregated sales data by date and category with calculated total amounts: the net revenue in total_amount, the gross sales at list price, discounts, and refunds it's made of, and the units sold, transactions, and average order value. Set period to group by week or month (keyed by first day), ISO week (keyed like 2024-W37), or fiscal period of the configured fiscal calendar (keyed like FY2025-P01). Sales are dated in UTC unless tz, the zoneinfo claim of the token, or REPORT_TIMEZONE names another time zone, in which case they are dated by the local time of their transaction. Set currency to convert totals recorded in other currencies into it at each day's exchange rate; the X-Report-Currency header names the currency converted into. Without currency or REPORT_CURRENCY, a range whose sales were recorded in several currencies is rejected with 400 unless grouped by currency. Set status to total only invoices, refunds (negative totals), or pending sales instead of netting them all. Set rollup=true to total each category and its subcategories under its top-level category (department). Set group_by to group by any combination of category, store, customer, currency (which keeps each currency's totals unconverted), and status, returned flat (an entry per combination) or nested by each dimension in turn. Use format=csv (or Accept: text/csv) to download one row per period and category, or per period and combination with group_by, format=xlsx for an Excel workbook with a summary sheet and a sheet per category, each with a Total row, format=arrow to stream one row per date and category as Arrow IPC record batches, format=ndjson to stream one JSON object per period and category as the rows are read, for exports too large to build in memory, or send Accept: application/x-protobuf for the craftdemo.v1.SalesReportByCategory message or Accept: application/msgpack for the JSON body as MessagePack.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+                    "application/vnd.apache.arrow.stream",
+                    "application/x-ndjson",
+                    "application/x-protobuf",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get sales report by category",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start date in YYYY-MM-DD format (defaults to 30 days ago)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date in YYYY-MM-DD format (defaults to today)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted warehouse rows (defaults to false)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ISO 4217 currency to convert totals into at each day's exchange rate (defaults to REPORT_CURRENCY; without either, amounts are summed as recorded)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated transaction statuses to total: invoice, refund, pending (defaults to all, netting refunds against sales)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone sales are dated in, e.g. Australia/Sydney (defaults to the token's zoneinfo, then REPORT_TIMEZONE, then UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Period to group by: day, week, iso_week, month, quarter, or fiscal (defaults to day)",
+                        "name": "period",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Same as period, for clients that name it granularity",
+                        "name": "granularity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "zero to include every period and category, with zero totals where there were no sales, or none (default)",
+                        "name": "fill",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Total subcategories under their top-level category, reporting departments only (defaults to false)",
+                        "name": "rollup",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma-separated dimensions to group by: category, store, customer, currency, status (defaults to category)",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Shape of reports grouped by other than category alone: flat (default) or nested",
+                        "name": "shape",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json, csv, xlsx, ndjson, or arrow (defaults to json); arrow supports day, week, and month periods",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Periods per page, 1 to 1000 (defaults to 100 with cursor); the response is then a ReportPage",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "With limit or cursor, a page of periods and the cursor of the next one",
+                        "schema": {
+                            "$ref": "#/definitions/services.ReportPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid parameters, a missing exchange rate, or sales in several currencies without currency",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/report/category/{date}/{category}/transactions": {
+            "get": {
+                "description": "Returns the sale transactions that make up one date and category of the category report, oldest first, with each transaction's line items in the category. Pass the same period and include_deleted as the report; date is then the report's period key (e.g. 2024-11-04 for a week, 2024-W45, or FY2025-P02).",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Drill down from a category report cell to its transactions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Report key: a date in YYYY-MM-DD format, or the period key for the given period",
+                        "name": "date",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Category name, as in the report",
+                        "name": "category",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Period of the report: day, week, iso_week, month, quarter, or fiscal (defaults to day)",
+                        "name": "period",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted warehouse rows (defaults to false)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number (defaults to 1)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Transactions per page (default 50, max 500)",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Transactions making up the report cell",
+                        "schema": {
+                            "$ref": "#/definitions/services.CategoryDrillDown"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid date, period, or page",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "Category not found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/report/compare": {
+            "get": {
+                "description": "Returns each category's revenue from the DW table in two arbitrary date ranges, such as this promo week and the last, with the change from range B to range A. Every category with revenue in either range is listed, with zero where it had none. The ranges may differ in length and overlap. Use format=csv or send Accept: text/csv to download one row per category followed by a Total row.",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Compare category revenue between two date ranges",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Range compared, as YYYY-MM-DD/YYYY-MM-DD (inclusive)",
+                        "name": "range_a",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Range compared against, as YYYY-MM-DD/YYYY-MM-DD (inclusive)",
+                        "name": "range_b",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted warehouse rows (defaults to false)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Total subcategories under their top-level category, comparing departments only (defaults to false)",
+                        "name": "rollup",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json or csv (defaults to json)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Category totals in both ranges with deltas",
+                        "schema": {
+                            "$ref": "#/definitions/services.PeriodComparison"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid range",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/report/comparison": {
+            "get": {
+                "description": "Returns each category's revenue from the DW table in the selected range and in the equivalent earlier range, with the change from the earlier range. prior_period compares with the same number of days just before the range, and prior_year with the same dates a year earlier (February 29 maps to February 28). The earlier range is returned as range_b. Use format=csv or send Accept: text/csv to download one row per category followed by a Total row.",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Compare category revenue with the prior period or prior year",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start date in YYYY-MM-DD format (defaults to 6 months ago)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date in YYYY-MM-DD format (defaults to today)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "prior_period (default) or prior_year",
+                        "name": "compare_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted warehouse rows (defaults to false)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Total subcategories under their top-level category, comparing departments only (defaults to false)",
+                        "name": "rollup",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json or csv (defaults to json)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Category totals in the range and the earlier range with deltas",
+                        "schema": {
+                            "$ref": "#/definitions/services.PeriodComparison"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/report/customers": {
+            "get": {
+                "description": "Returns revenue split between new and returning customers per period and category. A customer is new in the period containing their first purchase and returning afterwards. Sales without a customer are excluded. Use format=csv (or Accept: text/csv) to download one row per period and category, format=arrow to stream the same rows as Arrow IPC record batches, format=ndjson to stream them as JSON lines as they are read, or send Accept: application/x-protobuf for the craftdemo.v1.SalesReportByCustomerType message or Accept: application/msgpack for the JSON body as MessagePack.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/csv",
+                    "application/vnd.apache.arrow.stream",
+                    "application/x-ndjson",
+                    "application/x-protobuf",
+                    "application/msgpack"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get new vs returning customer sales report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start date in YYYY-MM-DD format (defaults to 6 months ago)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date in YYYY-MM-DD format (defaults to today)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Period to group by: day, week, iso_week, month, quarter, or fiscal (defaults to month)",
+                        "name": "period",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "zero to include every period and category, with zero totals where there were no sales, or none (default)",
+                        "name": "fill",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Total subcategories under their top-level category, reporting departments only (defaults to false)",
+                        "name": "rollup",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json, csv, ndjson, or arrow (defaults to json); arrow supports day, week, and month periods",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Periods per page, 1 to 1000 (defaults to 100 with cursor); the response is then a ReportPage",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "With limit or cursor, a page of periods and the cursor of the next one",
+                        "schema": {
+                            "$ref": "#/definitions/services.ReportPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "No sales data found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/report/monthly-pack": {
+            "get": {
+                "description": "Builds an Excel workbook for a month with a summary sheet, a formatted table of daily revenue per category, a forecast vs actual sheet that highlights categories missing the forecast by more than MONTHLY_PACK_MISS_THRESHOLD percent, and a line chart per category. The forecast for each category is made from the six months before.",
+                "produces": [
+                    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Download the monthly reporting pack",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Month in YYYY-MM format (defaults to last month)",
+                        "name": "month",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Monthly pack workbook",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid month",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "No sales data found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/report/summary.pdf": {
+            "get": {
+                "description": "Renders a one-page PDF for executives: revenue in the date range and the change from the same number of days before, a sparkline of daily revenue, the top categories with their share and change, and a statistical forecast of the three months after the range from the six complete months before them. Totals come from the DW table, without soft-deleted rows.",
+                "produces": [
+                    "application/pdf"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Download the sales summary PDF",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Start date in YYYY-MM-DD format (defaults to 6 months ago)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date in YYYY-MM-DD format (defaults to today)",
+                        "name": "end_date",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Summary PDF",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid date range",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "404": {
+                        "description": "No sales data found",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/report/top": {
+            "get": {
+                "description": "Returns the products or categories with the highest sales totals in the date range, net of refunds, with each one's share of the total of all of them. Product totals come from the sale transactions and category totals from the DW table. Use format=csv or send Accept: text/csv to download the sellers.",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get top selling products or categories",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Rank product or category (defaults to product)",
+                        "name": "dimension",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of sellers to return, 1 to 100 (defaults to 10)",
+                        "name": "n",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start date in YYYY-MM-DD format (defaults to 6 months ago)",
+                        "name": "start_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date in YYYY-MM-DD format (defaults to today)",
+                        "name": "end_date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include soft-deleted warehouse rows in category totals (defaults to false)",
+                        "name": "include_deleted",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Rank top-level categories with their subcategories' sales, with dimension=category (defaults to false)",
+                        "name": "rollup",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json or csv (defaults to json)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Top sellers, highest total first",
+                        "schema": {
+                            "$ref": "#/definitions/services.TopSellersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid parameters",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/sales/report/variance": {
+            "get": {
+                "description": "Compares each category's monthly revenue target with actual revenue from the DW table and the baseline forecast made from the six months before, with the variance and attainment against target. Use format=csv or send Accept: text/csv to download the report.",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "sales"
+                ],
+                "summary": "Get target vs actual vs forecast variance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First month in YYYY-MM format (defaults to January of the current year)",
+                        "name": "start_month",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last month in YYYY-MM format (defaults to the current month)",
+                        "name": "end_month",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Response format: json or csv (defaults to json)",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Variance per month and category",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.VarianceRow"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid month range",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        },
+        "/targets": {
+            "get": {
+                "description": "Returns the monthly revenue targets per category, optionally for one month",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "targets"
+                ],
+                "summary": "List revenue targets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only targets for this month (YYYY-MM)",
+                        "name": "month",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Targets ordered by month and category",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.RevenueTarget"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid month",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BasicAuth": []
+                    },
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates or replaces monthly revenue targets per category. Send a JSON array, or text/csv with a month,category,target header row where category is the category name. Months are YYYY-MM. Targets not in the upload are left unchanged.",
+                "consumes": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "targets"
+                ],
+                "summary": "Upload revenue targets",
+                "parameters": [
+                    {
+                        "description": "Targets",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/services.RevenueTarget"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Number of targets saved",
+                        "schema": {
+                            "$ref": "#/definitions/services.TargetUploadResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request - invalid target or unknown category",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/httperror.Envelope"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "batch.CustomerSegment": {
+            "type": "object",
+            "properties": {
+                "customer_id": {
+                    "type": "integer"
+                },
+                "frequency": {
+                    "type": "integer"
+                },
+                "frequency_score": {
+                    "type": "integer"
+                },
+                "monetary": {
+                    "type": "number"
+                },
+                "monetary_score": {
+                    "type": "integer"
+                },
+                "recency_days": {
+                    "type": "integer"
+                },
+                "recency_score": {
+                    "type": "integer"
+                },
+                "segment": {
+                    "type": "string"
+                }
+            }
+        },
+        "batch.DataQualityIssue": {
+            "type": "object",
+            "properties": {
+                "category_id": {
+                    "type": "integer"
+                },
+                "check": {
+                    "type": "string"
+                },
+                "date_recorded": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "detected_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "sale_transaction_id": {
+                    "type": "integer"
+                },
+                "sale_transaction_item_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "batch.Run": {
+            "type": "object",
+            "properties": {
+                "category_id": {
+                    "type": "integer"
+                },
+                "duration_ms": {
+                    "description": "DurationMS is how long a finished run took, in milliseconds",
+                    "type": "integer"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "job": {
+                    "type": "string"
+                },
+                "rows_replaced": {
+                    "type": "integer"
+                },
+                "rows_written": {
+                    "type": "integer"
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "started_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "triggered_by": {
+                    "type": "string"
+                }
+            }
+        },
+        "chaos.Fault": {
+            "type": "object",
+            "properties": {
+                "errorRate": {
+                    "description": "ErrorRate is the fraction (0-1) of calls that fail with ErrInjected",
+                    "type": "number"
+                },
+                "latencyMs": {
+                    "description": "LatencyMs is the delay added to a call when latency is injected",
+                    "type": "integer"
+                },
+                "latencyRate": {
+                    "description": "LatencyRate is the fraction (0-1) of calls that get the added latency",
+                    "type": "number"
+                }
+            }
+        },
+        "database.SchemaReport": {
+            "type": "object",
+            "properties": {
+                "applied_version": {
+                    "type": "integer"
+                },
+                "expected_version": {
+                    "type": "integer"
+                },
+                "problems": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "forecast.DemandPattern": {
+            "type": "object",
+            "properties": {
+                "adi": {
+                    "description": "ADI is the average number of periods between non-zero values",
+                    "type": "number"
+                },
+                "class": {
+                    "description": "Class is smooth, erratic, intermittent, or lumpy",
+                    "type": "string"
+                },
+                "cv2": {
+                    "description": "CV2 is the squared coefficient of variation of the non-zero values",
+                    "type": "number"
+                },
+                "intermittent": {
+                    "description": "Intermittent is set for intermittent and lumpy series, which\ntrend-based methods forecast poorly",
+                    "type": "boolean"
+                },
+                "method": {
+                    "description": "Method is the method recommended for the series when none is given:\ncroston for intermittent series, tsb for lumpy ones, and empty\notherwise",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/forecast.Method"
+                        }
+                    ]
+                },
+                "zeroShare": {
+                    "description": "ZeroShare is the fraction of periods with no demand",
+                    "type": "number"
+                }
+            }
+        },
+        "forecast.Method": {
+            "type": "string",
+            "enum": [
+                "arima",
+                "moving_average",
+                "naive",
+                "seasonal_naive",
+                "weighted_moving_average",
+                "exponential_smoothing",
+                "double_exponential_smoothing",
+                "triple_exponential_smoothing",
+                "additive",
+                "croston",
+                "tsb"
+            ],
+            "x-enum-varnames": [
+                "MethodARIMA",
+                "MethodMovingAverage",
+                "MethodNaive",
+                "MethodSeasonalNaive",
+                "MethodWeightedMovingAverage",
+                "MethodExponential",
+                "MethodDoubleExponential",
+                "MethodTripleExponential",
+                "MethodAdditive",
+                "MethodCroston",
+                "MethodTSB"
+            ]
+        },
+        "httperror.Body": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "Code is a stable, machine-readable error code derived from the status,\ne.g. bad_request or not_found",
+                    "type": "string"
+                },
+                "message": {
+                    "description": "Message is a human-readable description of the error",
+                    "type": "string"
+                },
+                "request_id": {
+                    "description": "RequestID matches the X-Request-Id response header",
+                    "type": "string"
+                }
+            }
+        },
+        "httperror.Envelope": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "$ref": "#/definitions/httperror.Body"
+                }
+            }
+        },
+        "prompts.Status": {
+            "type": "object",
+            "properties": {
+                "holidays": {
+                    "type": "integer"
+                },
+                "holidays_source": {
+                    "type": "string"
+                },
+                "loaded_at": {
+                    "type": "string"
+                },
+                "prompt_source": {
+                    "type": "string"
+                },
+                "prompt_version": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.BacktestFold": {
+            "type": "object",
+            "properties": {
+                "holdoutEnd": {
+                    "type": "string"
+                },
+                "holdoutStart": {
+                    "type": "string"
+                },
+                "trainEnd": {
+                    "type": "string"
+                },
+                "trainPeriods": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.BacktestMetrics": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Count is the number of forecast points compared",
+                    "type": "integer"
+                },
+                "mae": {
+                    "type": "number"
+                },
+                "mape": {
+                    "description": "MAPE is the mean absolute percentage error over non-zero actuals,\nnull when every actual is zero",
+                    "type": "number"
+                },
+                "rmse": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.BacktestRequest": {
+            "type": "object",
+            "properties": {
+                "alpha": {
+                    "description": "Alpha, Beta and Gamma are the smoothing factors in (0, 1]",
+                    "type": "number"
+                },
+                "arOrder": {
+                    "description": "AROrder is the number of lagged periods the arima method uses,\nchosen by AIC when unset",
+                    "type": "integer"
+                },
+                "beta": {
+                    "type": "number"
+                },
+                "categories": {
+                    "description": "Categories forecasts several series in one request, keyed by category\nname, with the other settings applied to each. It replaces\nTimeSeriesData. They are forecast FORECAST_CONCURRENCY at a time.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/services.TimeSeriesPoint"
+                        }
+                    }
+                },
+                "categoryId": {
+                    "description": "CategoryID is the category the tracked series covers; without it the\nseries is taken to be revenue across all categories. Without\nTimeSeriesData, the category's history is loaded from the DW table.",
+                    "type": "integer"
+                },
+                "deterministic": {
+                    "description": "Deterministic skips the LLM and uses a fixed-seed statistical forecast\nso the same input always produces the same output",
+                    "type": "boolean"
+                },
+                "differencing": {
+                    "description": "Differencing is how many times the arima method differences the\nseries (0-2), chosen from the series when unset",
+                    "type": "integer"
+                },
+                "folds": {
+                    "description": "Folds is the number of holdout windows, each starting a period later\n(default 3, max 12)",
+                    "type": "integer"
+                },
+                "gamma": {
+                    "type": "number"
+                },
+                "gapFill": {
+                    "description": "GapFill imputes missing periods before forecasting: zero (default),\nlinear, seasonal, or none",
+                    "type": "string"
+                },
+                "horizonDays": {
+                    "description": "HorizonDays, HorizonWeeks and HorizonMonths are how many periods to\nforecast for daily, weekly (and ISO-week), and monthly (and fiscal)\nseries, defaulting to 14, 4 and 6 when they're 0 or unset",
+                    "type": "integer"
+                },
+                "horizonMonths": {
+                    "type": "integer"
+                },
+                "horizonWeeks": {
+                    "type": "integer"
+                },
+                "maxTokens": {
+                    "type": "integer"
+                },
+                "method": {
+                    "description": "Method selects the statistical forecast used for deterministic and\nfallback forecasts: moving_average (default), naive, seasonal_naive,\nweighted_moving_average, exponential_smoothing,\ndouble_exponential_smoothing, triple_exponential_smoothing, additive,\ncroston, tsb, or arima. Without one, intermittent series use croston\nor tsb.",
+                    "type": "string"
+                },
+                "methods": {
+                    "description": "Methods are the forecasters compared: llm or any statistical method,\ndefaulting to llm and moving_average",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "model": {
+                    "description": "Model overrides the provider's model, e.g. gpt-4o-mini",
+                    "type": "string"
+                },
+                "outliers": {
+                    "description": "Outliers flags, and optionally winsorizes, outliers in the history\nbefore it reaches the prompt or the statistical forecast",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.OutlierOptions"
+                        }
+                    ]
+                },
+                "provider": {
+                    "description": "Provider is the LLM asked for the forecast: openai or anthropic,\ndefaulting to LLM_PROVIDER",
+                    "type": "string"
+                },
+                "scenario": {
+                    "description": "Scenario lists planned changes, such as promotions, to forecast on top\nof the baseline. The LLM is told about them; statistical forecasts are\nscaled by them.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ScenarioAdjustment"
+                    }
+                },
+                "seasonLength": {
+                    "description": "SeasonLength is the number of periods per season, defaulting to 7\ndays, 52 weeks or 12 months",
+                    "type": "integer"
+                },
+                "temperature": {
+                    "description": "Temperature (0-2) and MaxTokens (up to 16384) override LLM_TEMPERATURE\nand LLM_MAX_TOKENS",
+                    "type": "number"
+                },
+                "timePeriod": {
+                    "description": "TimePeriod is now optional - if not specified, all periods will be generated",
+                    "type": "string"
+                },
+                "timeSeriesData": {
+                    "description": "TimeSeriesData is the history to forecast. Missing periods are filled\naccording to GapFill and reported in imputed.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TimeSeriesPoint"
+                    }
+                },
+                "track": {
+                    "description": "Track stores the forecast so the forecast-accuracy job can match it\nagainst actuals from the DW table, for GET /forecasts/accuracy",
+                    "type": "boolean"
+                },
+                "tradingDays": {
+                    "description": "TradingDays normalizes monthly totals for their weighted trading days\nbefore forecasting and applies each forecast month's trading days to\nthe result",
+                    "type": "boolean"
+                },
+                "weekendWeight": {
+                    "description": "WeekendWeight is how much a weekend day counts relative to a weekday\nfor TradingDays (default 1)",
+                    "type": "number"
+                },
+                "window": {
+                    "description": "Window is the number of periods the weighted moving average covers",
+                    "type": "integer"
+                }
+            }
+        },
+        "services.BacktestResponse": {
+            "type": "object",
+            "properties": {
+                "best": {
+                    "description": "Best is the method with the lowest overall RMSE",
+                    "type": "string"
+                },
+                "folds": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.BacktestFold"
+                    }
+                },
+                "horizon": {
+                    "type": "integer"
+                },
+                "methods": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.MethodBacktest"
+                    }
+                },
+                "timePeriod": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.BatchRunsResponse": {
+            "type": "object",
+            "properties": {
+                "runs": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/batch.Run"
+                    }
+                }
+            }
+        },
+        "services.Category": {
+            "type": "object",
+            "properties": {
+                "archived": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parent_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.CategoryAccuracy": {
+            "type": "object",
+            "properties": {
+                "bias": {
+                    "type": "number"
+                },
+                "category_id": {
+                    "type": "integer"
+                },
+                "category_name": {
+                    "type": "string"
+                },
+                "forecasts": {
+                    "type": "integer"
+                },
+                "mape": {
+                    "type": "number"
+                },
+                "matched_periods": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.CategoryComparison": {
+            "type": "object",
+            "properties": {
+                "category_name": {
+                    "type": "string"
+                },
+                "delta": {
+                    "description": "Delta is total_a - total_b",
+                    "type": "number"
+                },
+                "percent_change": {
+                    "description": "PercentChange is the delta as a percentage of total_b, null when\ntotal_b is zero",
+                    "type": "number"
+                },
+                "total_a": {
+                    "type": "number"
+                },
+                "total_b": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.CategoryDrillDown": {
+            "type": "object",
+            "properties": {
+                "category_name": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "page_size": {
+                    "type": "integer"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "total_amount": {
+                    "description": "TotalAmount is the cell's total over every page, as in the report",
+                    "type": "number"
+                },
+                "total_transactions": {
+                    "type": "integer"
+                },
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.DrillDownTransaction"
+                    }
+                }
+            }
+        },
+        "services.CategoryForecasts": {
+            "type": "object",
+            "properties": {
+                "forecasts": {
+                    "description": "Forecasts holds each category's ForecastResponse in the requested\nschema version, keyed by category name",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.CategoryReportLine": {
+            "type": "object",
+            "properties": {
+                "average_order_value": {
+                    "description": "AverageOrderValue is what the transactions sold for on average, after\ndiscounts and before refunds",
+                    "type": "number"
+                },
+                "category_name": {
+                    "type": "string"
+                },
+                "discounts": {
+                    "description": "Discounts is how much less than list price the items sold for",
+                    "type": "number"
+                },
+                "gross_sales": {
+                    "description": "GrossSales is the list price of the items sold",
+                    "type": "number"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "refunds": {
+                    "description": "Refunds is the amount refunded, as a positive amount",
+                    "type": "number"
+                },
+                "total_amount": {
+                    "description": "TotalAmount is the net revenue: GrossSales less Discounts and Refunds",
+                    "type": "number"
+                },
+                "transactions": {
+                    "description": "Transactions is the number of sale transactions, not counting refunds",
+                    "type": "integer"
+                },
+                "units_sold": {
+                    "description": "UnitsSold is the units sold, net of refunded units",
+                    "type": "integer"
+                }
+            }
+        },
+        "services.CategoryRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "parent_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.CategoryTotal": {
+            "type": "object",
+            "properties": {
+                "average_order_value": {
+                    "description": "AverageOrderValue is what the transactions sold for on average, after\ndiscounts and before refunds",
+                    "type": "number"
+                },
+                "category_name": {
+                    "type": "string"
+                },
+                "discounts": {
+                    "description": "Discounts is how much less than list price the items sold for",
+                    "type": "number"
+                },
+                "gross_sales": {
+                    "description": "GrossSales is the list price of the items sold",
+                    "type": "number"
+                },
+                "refunds": {
+                    "description": "Refunds is the amount refunded, as a positive amount",
+                    "type": "number"
+                },
+                "total_amount": {
+                    "description": "TotalAmount is the net revenue: GrossSales less Discounts and Refunds",
+                    "type": "number"
+                },
+                "transactions": {
+                    "description": "Transactions is the number of sale transactions, not counting refunds",
+                    "type": "integer"
+                },
+                "units_sold": {
+                    "description": "UnitsSold is the units sold, net of refunded units",
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ChaosConfigResponse": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "faults": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/chaos.Fault"
+                    }
+                }
+            }
+        },
+        "services.ComparisonRange": {
+            "type": "object",
+            "properties": {
+                "days": {
+                    "type": "integer"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ComparisonTotals": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "description": "Delta is total_a - total_b",
+                    "type": "number"
+                },
+                "percent_change": {
+                    "description": "PercentChange is the delta as a percentage of total_b, null when\ntotal_b is zero",
+                    "type": "number"
+                },
+                "total_a": {
+                    "type": "number"
+                },
+                "total_b": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.ConfidenceInterval": {
+            "type": "object",
+            "properties": {
+                "level": {
+                    "description": "Level is the confidence level in percent: 80 or 95",
+                    "type": "integer"
+                },
+                "lower": {
+                    "type": "number"
+                },
+                "upper": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.CustomerSegmentsResponse": {
+            "type": "object",
+            "properties": {
+                "customers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/batch.CustomerSegment"
+                    }
+                },
+                "summary": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.SegmentSummary"
+                    }
+                }
+            }
+        },
+        "services.CustomerTypeReportLine": {
+            "type": "object",
+            "properties": {
+                "category_name": {
+                    "type": "string"
+                },
+                "new_amount": {
+                    "type": "number"
+                },
+                "new_customers": {
+                    "type": "integer"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "returning_amount": {
+                    "type": "number"
+                },
+                "returning_customers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.CustomerTypeTotal": {
+            "type": "object",
+            "properties": {
+                "category_name": {
+                    "type": "string"
+                },
+                "new_amount": {
+                    "type": "number"
+                },
+                "new_customers": {
+                    "type": "integer"
+                },
+                "returning_amount": {
+                    "type": "number"
+                },
+                "returning_customers": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.DataQualityIssuesResponse": {
+            "type": "object",
+            "properties": {
+                "counts": {
+                    "description": "Counts is the number of matching issues found by each check",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "issues": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/batch.DataQualityIssue"
+                    }
+                }
+            }
+        },
+        "services.DrillDownItem": {
+            "type": "object",
+            "properties": {
+                "product_id": {
+                    "type": "integer"
+                },
+                "product_name": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "total_amount": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.DrillDownTransaction": {
+            "type": "object",
+            "properties": {
+                "customer_id": {
+                    "type": "integer"
+                },
+                "customer_name": {
+                    "type": "string"
+                },
+                "date_recorded": {
+                    "type": "string"
+                },
+                "deleted": {
+                    "description": "Deleted is true for soft-deleted DW rows, listed with include_deleted",
+                    "type": "boolean"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.DrillDownItem"
+                    }
+                },
+                "sale_transaction_id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "store_id": {
+                    "type": "integer"
+                },
+                "store_name": {
+                    "type": "string"
+                },
+                "total_amount": {
+                    "description": "TotalAmount is the transaction's DW total in the category, negative\nfor refunds",
+                    "type": "number"
+                }
+            }
+        },
+        "services.ForecastAccuracy": {
+            "type": "object",
+            "properties": {
+                "bias": {
+                    "description": "Bias is the total forecast error as a percentage of total actuals:\npositive when the forecast ran high",
+                    "type": "number"
+                },
+                "category_id": {
+                    "description": "CategoryID and CategoryName are null for forecasts of total revenue",
+                    "type": "integer"
+                },
+                "category_name": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "forecast_id": {
+                    "type": "integer"
+                },
+                "mape": {
+                    "description": "MAPE is the mean absolute percentage error over matched periods with\nnon-zero actuals, and null when there are none",
+                    "type": "number"
+                },
+                "matched_periods": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string"
+                },
+                "periods": {
+                    "type": "integer"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "time_period": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ForecastAccuracyResponse": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.CategoryAccuracy"
+                    }
+                },
+                "forecasts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ForecastAccuracy"
+                    }
+                },
+                "methods": {
+                    "description": "Methods ranks the forecasters by MAPE over the last WindowDays days",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.MethodAccuracy"
+                    }
+                },
+                "window_days": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ForecastFreshness": {
+            "type": "object",
+            "properties": {
+                "age_seconds": {
+                    "type": "integer"
+                },
+                "attempts": {
+                    "type": "integer"
+                },
+                "category_id": {
+                    "type": "integer"
+                },
+                "category_name": {
+                    "type": "string"
+                },
+                "forecast_id": {
+                    "type": "integer"
+                },
+                "generated_at": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "queue_reason": {
+                    "type": "string"
+                },
+                "queued": {
+                    "type": "boolean"
+                },
+                "queued_at": {
+                    "type": "string"
+                },
+                "stale": {
+                    "description": "Stale is true when the forecast is missing or older than FORECAST_MAX_AGE",
+                    "type": "boolean"
+                },
+                "time_period": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ForecastMeta": {
+            "type": "object",
+            "properties": {
+                "cacheHit": {
+                    "description": "CacheHit reports whether the forecast was served from a cache",
+                    "type": "boolean"
+                },
+                "demand": {
+                    "description": "Demand classifies the history; intermittent series default to\nCroston's or TSB's method",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/forecast.DemandPattern"
+                        }
+                    ]
+                },
+                "durationMs": {
+                    "type": "integer"
+                },
+                "fallbackReason": {
+                    "description": "FallbackReason explains why the LLM forecast wasn't used",
+                    "type": "string"
+                },
+                "llmStatus": {
+                    "description": "LLMStatus is the cached LLM health when the forecast was requested;\nthe LLM is skipped while it is down",
+                    "type": "string"
+                },
+                "method": {
+                    "description": "Method is the statistical method used for fallback and deterministic\nforecasts",
+                    "type": "string"
+                },
+                "model": {
+                    "description": "Model and PromptVersion are set when the LLM was asked for a forecast",
+                    "type": "string"
+                },
+                "promptVersion": {
+                    "type": "string"
+                },
+                "provider": {
+                    "description": "Provider is openai, azure, ollama, or anthropic for LLM forecasts and\nstatistical otherwise",
+                    "type": "string"
+                },
+                "repairs": {
+                    "description": "Repairs lists the LLM forecast points replaced by the statistical\nforecast or dropped because they failed validation",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ForecastRepair"
+                    }
+                },
+                "source": {
+                    "description": "Source is llm, fallback, or deterministic",
+                    "type": "string"
+                },
+                "tradingDays": {
+                    "description": "TradingDays reports whether the forecast was adjusted for trading days",
+                    "type": "boolean"
+                },
+                "usage": {
+                    "description": "Usage is the token usage and estimated cost of the LLM request, when\nthe provider reported it",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.LLMUsage"
+                        }
+                    ]
+                }
+            }
+        },
+        "services.ForecastRepair": {
+            "type": "object",
+            "properties": {
+                "period": {
+                    "description": "Period is the forecast period, or the period as the LLM wrote it for a\ndropped point",
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "Reason is missing, invalid (not a number), or negative for periods\ntaken from the statistical forecast, and duplicate or unexpected (not\na period of the forecast) for dropped points",
+                    "type": "string"
+                }
+            }
+        },
+        "services.ForecastRequest": {
+            "type": "object",
+            "properties": {
+                "alpha": {
+                    "description": "Alpha, Beta and Gamma are the smoothing factors in (0, 1]",
+                    "type": "number"
+                },
+                "arOrder": {
+                    "description": "AROrder is the number of lagged periods the arima method uses,\nchosen by AIC when unset",
+                    "type": "integer"
+                },
+                "beta": {
+                    "type": "number"
+                },
+                "categories": {
+                    "description": "Categories forecasts several series in one request, keyed by category\nname, with the other settings applied to each. It replaces\nTimeSeriesData. They are forecast FORECAST_CONCURRENCY at a time.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/services.TimeSeriesPoint"
+                        }
+                    }
+                },
+                "categoryId": {
+                    "description": "CategoryID is the category the tracked series covers; without it the\nseries is taken to be revenue across all categories. Without\nTimeSeriesData, the category's history is loaded from the DW table.",
+                    "type": "integer"
+                },
+                "deterministic": {
+                    "description": "Deterministic skips the LLM and uses a fixed-seed statistical forecast\nso the same input always produces the same output",
+                    "type": "boolean"
+                },
+                "differencing": {
+                    "description": "Differencing is how many times the arima method differences the\nseries (0-2), chosen from the series when unset",
+                    "type": "integer"
+                },
+                "gamma": {
+                    "type": "number"
+                },
+                "gapFill": {
+                    "description": "GapFill imputes missing periods before forecasting: zero (default),\nlinear, seasonal, or none",
+                    "type": "string"
+                },
+                "horizonDays": {
+                    "description": "HorizonDays, HorizonWeeks and HorizonMonths are how many periods to\nforecast for daily, weekly (and ISO-week), and monthly (and fiscal)\nseries, defaulting to 14, 4 and 6 when they're 0 or unset",
+                    "type": "integer"
+                },
+                "horizonMonths": {
+                    "type": "integer"
+                },
+                "horizonWeeks": {
+                    "type": "integer"
+                },
+                "maxTokens": {
+                    "type": "integer"
+                },
+                "method": {
+                    "description": "Method selects the statistical forecast used for deterministic and\nfallback forecasts: moving_average (default), naive, seasonal_naive,\nweighted_moving_average, exponential_smoothing,\ndouble_exponential_smoothing, triple_exponential_smoothing, additive,\ncroston, tsb, or arima. Without one, intermittent series use croston\nor tsb.",
+                    "type": "string"
+                },
+                "model": {
+                    "description": "Model overrides the provider's model, e.g. gpt-4o-mini",
+                    "type": "string"
+                },
+                "outliers": {
+                    "description": "Outliers flags, and optionally winsorizes, outliers in the history\nbefore it reaches the prompt or the statistical forecast",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.OutlierOptions"
+                        }
+                    ]
+                },
+                "provider": {
+                    "description": "Provider is the LLM asked for the forecast: openai or anthropic,\ndefaulting to LLM_PROVIDER",
+                    "type": "string"
+                },
+                "scenario": {
+                    "description": "Scenario lists planned changes, such as promotions, to forecast on top\nof the baseline. The LLM is told about them; statistical forecasts are\nscaled by them.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ScenarioAdjustment"
+                    }
+                },
+                "seasonLength": {
+                    "description": "SeasonLength is the number of periods per season, defaulting to 7\ndays, 52 weeks or 12 months",
+                    "type": "integer"
+                },
+                "temperature": {
+                    "description": "Temperature (0-2) and MaxTokens (up to 16384) override LLM_TEMPERATURE\nand LLM_MAX_TOKENS",
+                    "type": "number"
+                },
+                "timePeriod": {
+                    "description": "TimePeriod is now optional - if not specified, all periods will be generated",
+                    "type": "string"
+                },
+                "timeSeriesData": {
+                    "description": "TimeSeriesData is the history to forecast. Missing periods are filled\naccording to GapFill and reported in imputed.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TimeSeriesPoint"
+                    }
+                },
+                "track": {
+                    "description": "Track stores the forecast so the forecast-accuracy job can match it\nagainst actuals from the DW table, for GET /forecasts/accuracy",
+                    "type": "boolean"
+                },
+                "tradingDays": {
+                    "description": "TradingDays normalizes monthly totals for their weighted trading days\nbefore forecasting and applies each forecast month's trading days to\nthe result",
+                    "type": "boolean"
+                },
+                "weekendWeight": {
+                    "description": "WeekendWeight is how much a weekend day counts relative to a weekday\nfor TradingDays (default 1)",
+                    "type": "number"
+                },
+                "window": {
+                    "description": "Window is the number of periods the weighted moving average covers",
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ForecastResponse": {
+            "type": "object",
+            "properties": {
+                "baseline": {
+                    "description": "Baseline is the statistical forecast without the request's scenario",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TimeSeriesPoint"
+                    }
+                },
+                "forecast": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TimeSeriesPoint"
+                    }
+                },
+                "forecastId": {
+                    "description": "ForecastID identifies a tracked forecast in the accuracy endpoint",
+                    "type": "integer"
+                },
+                "imputed": {
+                    "description": "Imputed lists the missing history periods filled in by gap filling",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TimeSeriesPoint"
+                    }
+                },
+                "message": {
+                    "type": "string"
+                },
+                "meta": {
+                    "$ref": "#/definitions/services.ForecastMeta"
+                },
+                "outliers": {
+                    "description": "Outliers lists the history points flagged by outlier detection",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.OutlierPoint"
+                    }
+                },
+                "rawResponse": {
+                    "type": "string"
+                },
+                "timePeriod": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.GoalProgress": {
+            "type": "object",
+            "properties": {
+                "actual": {
+                    "description": "Actual and Forecast are the daily series for a pacing chart",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TimeSeriesPoint"
+                    }
+                },
+                "as_of": {
+                    "description": "AsOf is the first day not counted as actual; it and the days after it\nare forecast",
+                    "type": "string"
+                },
+                "category_name": {
+                    "type": "string"
+                },
+                "current_daily_run_rate": {
+                    "type": "number"
+                },
+                "days_elapsed": {
+                    "type": "integer"
+                },
+                "days_in_month": {
+                    "type": "integer"
+                },
+                "days_remaining": {
+                    "type": "integer"
+                },
+                "forecast": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TimeSeriesPoint"
+                    }
+                },
+                "goal_id": {
+                    "type": "integer"
+                },
+                "month": {
+                    "type": "string"
+                },
+                "month_to_date": {
+                    "type": "number"
+                },
+                "on_track": {
+                    "type": "boolean"
+                },
+                "projected_attainment": {
+                    "description": "ProjectedAttainment is the projected total as a percentage of target,\nand null when the target is zero",
+                    "type": "number"
+                },
+                "projected_total": {
+                    "type": "number"
+                },
+                "remaining_forecast": {
+                    "description": "RemainingForecast is the baseline forecast of the remaining days",
+                    "type": "number"
+                },
+                "required_daily_run_rate": {
+                    "description": "RequiredDailyRunRate is the daily revenue needed over the remaining\ndays to reach the target",
+                    "type": "number"
+                },
+                "target": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.GroupedTotal": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "customer": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "store": {
+                    "type": "string"
+                },
+                "total_amount": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.HealthResponse": {
+            "type": "object",
+            "properties": {
+                "schema": {
+                    "$ref": "#/definitions/database.SchemaReport"
+                },
+                "status": {
+                    "description": "Status is ok, or degraded when the schema check found problems",
+                    "type": "string"
+                }
+            }
+        },
+        "services.HorizonMetrics": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "description": "Count is the number of forecast points compared",
+                    "type": "integer"
+                },
+                "horizon": {
+                    "type": "integer"
+                },
+                "mae": {
+                    "type": "number"
+                },
+                "mape": {
+                    "description": "MAPE is the mean absolute percentage error over non-zero actuals,\nnull when every actual is zero",
+                    "type": "number"
+                },
+                "rmse": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.LLMHealth": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "latency_ms": {
+                    "type": "integer"
+                },
+                "status": {
+                    "description": "Status is unknown, up, down, or unconfigured",
+                    "type": "string"
+                }
+            }
+        },
+        "services.LLMUsage": {
+            "type": "object",
+            "properties": {
+                "completionTokens": {
+                    "type": "integer"
+                },
+                "costUsd": {
+                    "description": "CostUSD is estimated from the model's price per token, null when the\nprice isn't known",
+                    "type": "number"
+                },
+                "promptTokens": {
+                    "type": "integer"
+                },
+                "totalTokens": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.MethodAccuracy": {
+            "type": "object",
+            "properties": {
+                "bias": {
+                    "type": "number"
+                },
+                "forecasts": {
+                    "type": "integer"
+                },
+                "mape": {
+                    "type": "number"
+                },
+                "matched_periods": {
+                    "type": "integer"
+                },
+                "method": {
+                    "description": "Method is the statistical method of fallback and deterministic\nforecasts",
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.MethodBacktest": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "failedFolds": {
+                    "description": "FailedFolds counts folds the forecaster returned no forecast for,\nwith the last error",
+                    "type": "integer"
+                },
+                "horizons": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.HorizonMetrics"
+                    }
+                },
+                "method": {
+                    "type": "string"
+                },
+                "overall": {
+                    "description": "Overall is null when every fold failed",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.BacktestMetrics"
+                        }
+                    ]
+                }
+            }
+        },
+        "services.OutlierOptions": {
+            "type": "object",
+            "properties": {
+                "method": {
+                    "description": "Method is zscore, iqr, or hampel",
+                    "type": "string"
+                },
+                "threshold": {
+                    "description": "Threshold defaults to 3 standard deviations for zscore, 1.5\ninterquartile ranges for iqr, and 3 scaled MADs for hampel",
+                    "type": "number"
+                },
+                "window": {
+                    "description": "Window is the number of periods in a hampel window (default 7)",
+                    "type": "integer"
+                },
+                "winsorize": {
+                    "description": "Winsorize clips outliers to the nearest bound; otherwise they are\nonly reported",
+                    "type": "boolean"
+                }
+            }
+        },
+        "services.OutlierPoint": {
+            "type": "object",
+            "properties": {
+                "lower": {
+                    "type": "number"
+                },
+                "period": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "number"
+                },
+                "treated": {
+                    "description": "Treated is the total used for forecasting",
+                    "type": "number"
+                },
+                "upper": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.PeriodComparison": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.CategoryComparison"
+                    }
+                },
+                "compare_to": {
+                    "description": "CompareTo is prior_period or prior_year when range B was derived from\nrange A",
+                    "type": "string"
+                },
+                "range_a": {
+                    "$ref": "#/definitions/services.ComparisonRange"
+                },
+                "range_b": {
+                    "$ref": "#/definitions/services.ComparisonRange"
+                },
+                "total": {
+                    "$ref": "#/definitions/services.ComparisonTotals"
+                }
+            }
+        },
+        "services.Product": {
+            "type": "object",
+            "properties": {
+                "archived": {
+                    "type": "boolean"
+                },
+                "category_id": {
+                    "type": "integer"
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "quantity": {
+                    "type": "number"
+                },
+                "sku": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ProductCategoryRequest": {
+            "type": "object",
+            "properties": {
+                "category_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ProductRequest": {
+            "type": "object",
+            "properties": {
+                "category_id": {
+                    "type": "integer"
+                },
+                "company_id": {
+                    "type": "integer"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "quantity": {
+                    "type": "number"
+                },
+                "sku": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.PromotionImpactRequest": {
+            "type": "object",
+            "properties": {
+                "categoryId": {
+                    "type": "integer"
+                },
+                "endDate": {
+                    "type": "string"
+                },
+                "startDate": {
+                    "type": "string"
+                },
+                "trainingDays": {
+                    "description": "TrainingDays is the number of days before the promotion used to build\nthe counterfactual (defaults to 90)",
+                    "type": "integer"
+                }
+            }
+        },
+        "services.PromotionImpactResponse": {
+            "type": "object",
+            "properties": {
+                "actual": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TimeSeriesPoint"
+                    }
+                },
+                "actualTotal": {
+                    "type": "number"
+                },
+                "analysis": {
+                    "$ref": "#/definitions/services.PromotionImpactSummary"
+                },
+                "baselineTotal": {
+                    "type": "number"
+                },
+                "categoryName": {
+                    "type": "string"
+                },
+                "counterfactual": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TimeSeriesPoint"
+                    }
+                },
+                "lift": {
+                    "type": "number"
+                },
+                "liftPercent": {
+                    "type": "number"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.PromotionImpactSummary": {
+            "type": "object",
+            "properties": {
+                "confidence": {
+                    "type": "string"
+                },
+                "drivers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "recommendation": {
+                    "type": "string"
+                },
+                "summary": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ReadinessResponse": {
+            "type": "object",
+            "properties": {
+                "database": {
+                    "type": "string"
+                },
+                "llm": {
+                    "description": "LLM is the cached LLM health; forecasts fall back to statistical\nmodels while it is down, so it doesn't affect readiness",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/services.LLMHealth"
+                        }
+                    ]
+                },
+                "status": {
+                    "description": "Status is ready, not_ready when the database is unreachable, or\ndraining while the server shuts down",
+                    "type": "string"
+                }
+            }
+        },
+        "services.ReplenishmentSuggestion": {
+            "type": "object",
+            "properties": {
+                "average_daily_units": {
+                    "type": "number"
+                },
+                "category_name": {
+                    "type": "string"
+                },
+                "lead_time_demand": {
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "safety_stock": {
+                    "type": "number"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "stock": {
+                    "type": "number"
+                },
+                "suggested_order_qty": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ReportPage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "description": "Data holds the page's periods, keyed as in the unpaginated report"
+                },
+                "next_cursor": {
+                    "description": "NextCursor is the cursor parameter of the next page, null on the last\npage",
+                    "type": "string"
+                }
+            }
+        },
+        "services.ReportSubscription": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "frequency": {
+                    "description": "Frequency is daily or weekly",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_sent_at": {
+                    "type": "string"
+                },
+                "saved_report_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ReportSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "frequency": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.RevenueTarget": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "description": "Category is the category name; CategoryID may be given instead when\nuploading",
+                    "type": "string"
+                },
+                "category_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "description": "ID identifies the target as a sales goal; it is ignored when uploading",
+                    "type": "integer"
+                },
+                "month": {
+                    "description": "Month is YYYY-MM",
+                    "type": "string"
+                },
+                "target": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.SalesTotalsChangeResponse": {
+            "type": "object",
+            "properties": {
+                "affected": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.SavedReport": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "last_days": {
+                    "description": "LastDays, when set, makes the range the last N days up to the day the\nreport runs; compare reports compare them with the N days before",
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "params": {
+                    "description": "Params are the report's query parameters",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "report": {
+                    "description": "Report is category, customers, compare, comparison, or variance",
+                    "type": "string"
+                }
+            }
+        },
+        "services.SavedReportRequest": {
+            "type": "object",
+            "properties": {
+                "last_days": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "params": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "report": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.ScenarioAdjustment": {
+            "type": "object",
+            "properties": {
+                "end": {
+                    "type": "string"
+                },
+                "multiplier": {
+                    "description": "Multiplier scales sales on the covered days, e.g. 1.15 for +15% or 0.9\nfor -10%",
+                    "type": "number"
+                },
+                "name": {
+                    "description": "Name describes the adjustment in the prompt, e.g. Black Friday promo",
+                    "type": "string"
+                },
+                "start": {
+                    "description": "Start and End are the first and last day as YYYY-MM-DD, inclusive",
+                    "type": "string"
+                }
+            }
+        },
+        "services.SegmentSummary": {
+            "type": "object",
+            "properties": {
+                "customers": {
+                    "type": "integer"
+                },
+                "monetary": {
+                    "type": "number"
+                },
+                "segment": {
+                    "type": "string"
+                }
+            }
+        },
+        "services.TargetUploadResponse": {
+            "type": "object",
+            "properties": {
+                "saved": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.TimeSeriesPoint": {
+            "type": "object",
+            "properties": {
+                "intervals": {
+                    "description": "Intervals are the 80% and 95% confidence bands of forecast points",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ConfidenceInterval"
+                    }
+                },
+                "period": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.TopSeller": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "rank": {
+                    "type": "integer"
+                },
+                "share_percent": {
+                    "description": "SharePercent is the seller's percentage of the total of every product\nor category, null when that total isn't positive",
+                    "type": "number"
+                },
+                "total_amount": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.TopSellersResponse": {
+            "type": "object",
+            "properties": {
+                "dimension": {
+                    "type": "string"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "sellers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.TopSeller"
+                    }
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "total_amount": {
+                    "description": "TotalAmount is the total of every product or category in the range,\nnot only the top ones",
+                    "type": "number"
+                }
+            }
+        },
+        "services.VarianceRow": {
+            "type": "object",
+            "properties": {
+                "actual": {
+                    "type": "number"
+                },
+                "attainment": {
+                    "description": "Attainment is actual as a percentage of target",
+                    "type": "number"
+                },
+                "category_name": {
+                    "type": "string"
+                },
+                "complete": {
+                    "description": "Complete is false for the current month, whose actual is month-to-date",
+                    "type": "boolean"
+                },
+                "forecast": {
+                    "description": "Forecast is the baseline forecast of the month from the six months before",
+                    "type": "number"
+                },
+                "forecast_variance": {
+                    "description": "ForecastVariance is forecast - target",
+                    "type": "number"
+                },
+                "month": {
+                    "type": "string"
+                },
+                "target": {
+                    "description": "Target and the values derived from it are null without a target",
+                    "type": "number"
+                },
+                "variance": {
+                    "description": "Variance is actual - target",
+                    "type": "number"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BasicAuth": {
+            "type": "basic"
+        },
+        "BearerAuth": {
+            "description": "JWT from the SSO provider as \"Bearer \u003ctoken\u003e\", when JWT authentication is enabled",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -0,0 +1,254 @@
+// Package alerts evaluates deviation alert rules against the DW table and
+// sends notifications when they fire
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/config"
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/notify"
+	"gopkg.in/yaml.v3"
+)
+
+// baselineDays is the history the baseline forecast of each day is built from
+const baselineDays = 28
+
+// Conditions a rule can check
+const (
+	ConditionBelow = "below"
+	ConditionAbove = "above"
+)
+
+// Rule fires when daily revenue is below (or above) Threshold times the
+// baseline forecast for Days consecutive days ending yesterday
+type Rule struct {
+	Name string `yaml:"name"`
+	// Category limits the rule to one category name; empty means all
+	Category  string  `yaml:"category"`
+	Condition string  `yaml:"condition"`
+	Threshold float64 `yaml:"threshold"`
+	Days      int     `yaml:"days"`
+	// Channels are the notification channels; empty means all configured
+	Channels []string `yaml:"channels"`
+}
+
+// DayResult is the revenue and baseline forecast of one day
+type DayResult struct {
+	Day      time.Time
+	Actual   float64
+	Forecast float64
+}
+
+// Alert is a rule that fired
+type Alert struct {
+	Rule Rule
+	Days []DayResult
+}
+
+// RulesFromEnv loads the rules from ALERT_RULES_PATH, which defaults to
+// config/alert_rules.yaml
+func RulesFromEnv() ([]Rule, error) {
+	name := os.Getenv("ALERT_RULES_PATH")
+	if name == "" {
+		name = "config/alert_rules.yaml"
+	}
+	contents, err := config.Read(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert rules: %v", err)
+	}
+	return ParseRules(contents)
+}
+
+// ParseRules parses and validates YAML alert rules, defaulting Days to 1
+func ParseRules(contents []byte) ([]Rule, error) {
+	var rules []Rule
+	if err := yaml.Unmarshal(contents, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules: %v", err)
+	}
+
+	names := map[string]bool{}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("alert rule %d has no name", i+1)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate alert rule %q", rule.Name)
+		}
+		names[rule.Name] = true
+
+		rule.Condition = strings.ToLower(rule.Condition)
+		if rule.Condition != ConditionBelow && rule.Condition != ConditionAbove {
+			return nil, fmt.Errorf("alert rule %q: condition must be below or above", rule.Name)
+		}
+		if rule.Threshold <= 0 {
+			return nil, fmt.Errorf("alert rule %q: threshold must be positive", rule.Name)
+		}
+		if rule.Days == 0 {
+			rule.Days = 1
+		}
+		if rule.Days < 0 {
+			return nil, fmt.Errorf("alert rule %q: days must be positive", rule.Name)
+		}
+	}
+	return rules, nil
+}
+
+// Evaluate checks a rule against the days ending the day before now and
+// returns the alert when every day breaches it
+func Evaluate(db *sql.DB, rule Rule, now time.Time) (*Alert, error) {
+	today := now.UTC().Truncate(24 * time.Hour)
+	first := today.AddDate(0, 0, -rule.Days)
+	revenue, err := dailyRevenue(db, rule.Category, first.AddDate(0, 0, -baselineDays), today)
+	if err != nil {
+		return nil, err
+	}
+
+	alert := &Alert{Rule: rule}
+	for day := first; day.Before(today); day = day.AddDate(0, 0, 1) {
+		var history []forecast.Point
+		for d := day.AddDate(0, 0, -baselineDays); d.Before(day); d = d.AddDate(0, 0, 1) {
+			history = append(history, forecast.Point{Period: d.Format("2006-01-02"), Total: revenue[d.Format("2006-01-02")]})
+		}
+
+		// A fixed seed keeps the baseline the same on every evaluation
+		forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(1)), nil)
+		result := DayResult{Day: day, Actual: revenue[day.Format("2006-01-02")]}
+		if predicted := forecaster.Forecast(history, "day", 1); len(predicted) == 1 {
+			result.Forecast = predicted[0].Total
+		}
+		if result.Forecast <= 0 || !breaches(rule, result) {
+			return nil, nil
+		}
+		alert.Days = append(alert.Days, result)
+	}
+	return alert, nil
+}
+
+func breaches(rule Rule, day DayResult) bool {
+	if rule.Condition == ConditionAbove {
+		return day.Actual > rule.Threshold*day.Forecast
+	}
+	return day.Actual < rule.Threshold*day.Forecast
+}
+
+// dailyRevenue returns the net revenue per day from start up to but not
+// including end, for one category or all categories when category is empty
+func dailyRevenue(db *sql.DB, category string, start, end time.Time) (map[string]float64, error) {
+	rows, err := db.Query(`
+		SELECT DATE(st.date_recorded), SUM(st.total_amount)
+		FROM sales_totals_by_category_dw st
+		JOIN categories c ON c.id = st.category_id
+		WHERE st.date_recorded >= $1 AND st.date_recorded < $2 AND st.deleted_at IS NULL
+			AND ($3 = '' OR c.name = $3)
+		GROUP BY DATE(st.date_recorded)
+	`, start, end, category)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily revenue: %v", err)
+	}
+	defer rows.Close()
+
+	revenue := make(map[string]float64)
+	for rows.Next() {
+		var (
+			date  time.Time
+			total float64
+		)
+		if err := rows.Scan(&date, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		revenue[date.Format("2006-01-02")] = math.Round(total*100) / 100
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return revenue, nil
+}
+
+// Run evaluates every rule and notifies the rule's channels of each alert.
+// A rule fires at most once per day: firings are recorded in alert_firings,
+// and a firing whose notification fails is removed so the next run retries.
+func Run(ctx context.Context, db *sql.DB, rules []Rule, channels notify.Channels, now time.Time) error {
+	day := now.UTC().Truncate(24 * time.Hour)
+
+	var failed []string
+	for _, rule := range rules {
+		alert, err := Evaluate(db, rule, now)
+		if err != nil {
+			log.Printf("Failed to evaluate alert rule %s: %v", rule.Name, err)
+			failed = append(failed, rule.Name)
+			continue
+		}
+		if alert == nil {
+			continue
+		}
+
+		result, err := db.Exec(`
+			INSERT INTO alert_firings (rule_name, fired_for, fired_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (rule_name, fired_for) DO NOTHING
+		`, rule.Name, day, now)
+		if err != nil {
+			return fmt.Errorf("failed to record alert firing: %v", err)
+		}
+		if claimed, _ := result.RowsAffected(); claimed == 0 {
+			continue
+		}
+
+		if err := channels.Send(ctx, rule.Channels, Message(*alert)); err != nil {
+			failed = append(failed, rule.Name)
+			if _, err := db.Exec("DELETE FROM alert_firings WHERE rule_name = $1 AND fired_for = $2", rule.Name, day); err != nil {
+				log.Printf("Failed to clear alert firing for %s: %v", rule.Name, err)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("alert rules failed: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// Message describes an alert, linking to the category report for its days.
+// The link is relative to ALERT_LINK_BASE_URL (default http://localhost:8080).
+func Message(alert Alert) notify.Message {
+	scope := "All categories"
+	if alert.Rule.Category != "" {
+		scope = alert.Rule.Category
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s revenue was %s %.0f%% of the baseline forecast for %d consecutive day(s):",
+		scope, alert.Rule.Condition, alert.Rule.Threshold*100, alert.Rule.Days)
+	for _, day := range alert.Days {
+		fmt.Fprintf(&text, "\n%s: %.2f actual vs %.2f forecast (%.0f%%)",
+			day.Day.Format("2006-01-02"), day.Actual, day.Forecast, day.Actual/day.Forecast*100)
+	}
+
+	base := os.Getenv("ALERT_LINK_BASE_URL")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	query := url.Values{}
+	if len(alert.Days) > 0 {
+		query.Set("start_date", alert.Days[0].Day.Format("2006-01-02"))
+		query.Set("end_date", alert.Days[len(alert.Days)-1].Day.Format("2006-01-02"))
+	}
+
+	return notify.Message{
+		Title: fmt.Sprintf("Alert %s: %s", alert.Rule.Name, scope),
+		Text:  text.String(),
+		Link:  strings.TrimRight(base, "/") + "/api/v1/sales/report/category?" + query.Encode(),
+	}
+}
@@ -0,0 +1,210 @@
+// Package auth verifies JWT bearer tokens issued by the SSO provider and
+// checks the roles they grant
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// Role is what the holder of a token may do. Admins can do everything
+// viewers can.
+type Role string
+
+// Roles granted by the roles claim
+const (
+	RoleViewer Role = "viewer"
+	RoleAdmin  Role = "admin"
+)
+
+// Principal is the verified holder of a token
+type Principal struct {
+	Subject string
+	Roles   []Role
+	// TimeZone is the IANA time zone of the holder's tenant, from the time
+	// zone claim, or empty
+	TimeZone string
+}
+
+// Has reports whether the principal was granted role, or admin
+func (p Principal) Has(role Role) bool {
+	for _, granted := range p.Roles {
+		if granted == role || granted == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// principalKey is the echo context key of the authenticated Principal
+const principalKey = "auth.principal"
+
+// FromContext returns the principal authenticated by the middleware
+func FromContext(c echo.Context) (Principal, bool) {
+	principal, ok := c.Get(principalKey).(Principal)
+	return principal, ok
+}
+
+// WithPrincipal sets the principal of to from from, for handlers run on a
+// new echo context for the same request
+func WithPrincipal(to, from echo.Context) echo.Context {
+	if principal, ok := FromContext(from); ok {
+		to.Set(principalKey, principal)
+	}
+	return to
+}
+
+// Verifier verifies JWT bearer tokens and reads their roles
+type Verifier struct {
+	key        any
+	methods    []string
+	issuer     string
+	audience   string
+	rolesClaim string
+	zoneClaim  string
+}
+
+// FromEnv returns a Verifier for tokens signed with JWT_SECRET (HMAC) or
+// with the key of the RSA or ECDSA public key PEM file at
+// JWT_PUBLIC_KEY_FILE, or nil when neither is set and JWT authentication is
+// off. JWT_ISSUER and JWT_AUDIENCE are required of tokens when set. Roles
+// are read from the JWT_ROLES_CLAIM claim (default roles), a list or a
+// space-separated string, and the tenant's time zone from the
+// JWT_TIMEZONE_CLAIM claim (default zoneinfo, as in OpenID Connect).
+func FromEnv() (*Verifier, error) {
+	verifier := &Verifier{
+		issuer:     os.Getenv("JWT_ISSUER"),
+		audience:   os.Getenv("JWT_AUDIENCE"),
+		rolesClaim: os.Getenv("JWT_ROLES_CLAIM"),
+		zoneClaim:  os.Getenv("JWT_TIMEZONE_CLAIM"),
+	}
+	if verifier.rolesClaim == "" {
+		verifier.rolesClaim = "roles"
+	}
+	if verifier.zoneClaim == "" {
+		verifier.zoneClaim = "zoneinfo"
+	}
+
+	secret, keyFile := os.Getenv("JWT_SECRET"), os.Getenv("JWT_PUBLIC_KEY_FILE")
+	switch {
+	case secret != "" && keyFile != "":
+		return nil, fmt.Errorf("set JWT_SECRET or JWT_PUBLIC_KEY_FILE, not both")
+	case secret != "":
+		verifier.key = []byte(secret)
+		verifier.methods = []string{"HS256", "HS384", "HS512"}
+	case keyFile != "":
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWT_PUBLIC_KEY_FILE: %v", err)
+		}
+		if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+			verifier.key = key
+			verifier.methods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+		} else if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+			verifier.key = key
+			verifier.methods = []string{"ES256", "ES384", "ES512"}
+		} else {
+			return nil, fmt.Errorf("JWT_PUBLIC_KEY_FILE is not an RSA or ECDSA public key")
+		}
+	default:
+		return nil, nil
+	}
+	return verifier, nil
+}
+
+// Verify checks a token's signature, expiry, issuer, and audience and
+// returns its holder
+func (v *Verifier) Verify(token string) (Principal, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods(v.methods), jwt.WithExpirationRequired()}
+	if v.issuer != "" {
+		options = append(options, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		options = append(options, jwt.WithAudience(v.audience))
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+		return v.key, nil
+	}, options...); err != nil {
+		return Principal{}, err
+	}
+
+	subject, _ := claims.GetSubject()
+	timeZone, _ := claims[v.zoneClaim].(string)
+	return Principal{Subject: subject, Roles: roles(claims[v.rolesClaim]), TimeZone: timeZone}, nil
+}
+
+// roles returns the known roles in a roles claim, ignoring any others
+func roles(claim any) []Role {
+	var names []string
+	switch value := claim.(type) {
+	case string:
+		names = strings.Fields(value)
+	case []any:
+		for _, name := range value {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var result []Role
+	for _, name := range names {
+		switch role := Role(strings.ToLower(name)); role {
+		case RoleViewer, RoleAdmin:
+			result = append(result, role)
+		}
+	}
+	return result
+}
+
+// Middleware authenticates requests with a bearer token in the
+// Authorization header, except those skipper lets through, and stores the
+// Principal in the context. Requests without a valid token get 401, and
+// tokens granting neither role get 403.
+func (v *Verifier) Middleware(skipper middleware.Skipper) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if skipper(c) {
+				return next(c)
+			}
+
+			token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !ok || token == "" {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+				return echo.NewHTTPError(http.StatusUnauthorized, "Missing bearer token")
+			}
+			principal, err := v.Verify(strings.TrimSpace(token))
+			if err != nil {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return echo.NewHTTPError(http.StatusUnauthorized, fmt.Sprintf("Invalid bearer token: %v", err))
+			}
+			if !principal.Has(RoleViewer) {
+				return echo.NewHTTPError(http.StatusForbidden, "Token grants no role")
+			}
+
+			c.Set(principalKey, principal)
+			return next(c)
+		}
+	}
+}
+
+// Require responds 403 unless the principal authenticated by the
+// middleware has role
+func Require(role Role) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if principal, ok := FromContext(c); !ok || !principal.Has(role) {
+				return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("The %s role is required", role))
+			}
+			return next(c)
+		}
+	}
+}
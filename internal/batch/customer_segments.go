@@ -0,0 +1,201 @@
+package batch
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RFMBands holds the ascending thresholds used to turn recency, frequency,
+// and monetary values into 1-5 scores. Each dimension has four thresholds.
+type RFMBands struct {
+	// RecencyDays scores lower values higher: a purchase within the first
+	// threshold scores 5, beyond the last scores 1
+	RecencyDays []float64
+	// Frequency and Monetary score higher values higher: below the first
+	// threshold scores 1, at or above the last scores 5
+	Frequency []float64
+	Monetary  []float64
+}
+
+// DefaultRFMBands are used for any dimension not configured in the environment
+var DefaultRFMBands = RFMBands{
+	RecencyDays: []float64{30, 90, 180, 365},
+	Frequency:   []float64{2, 4, 8, 16},
+	Monetary:    []float64{250, 1000, 5000, 20000},
+}
+
+// CustomerSegment represents a customer's RFM values, scores, and segment
+type CustomerSegment struct {
+	CustomerID     int     `json:"customer_id"`
+	RecencyDays    int     `json:"recency_days"`
+	Frequency      int     `json:"frequency"`
+	Monetary       float64 `json:"monetary"`
+	RecencyScore   int     `json:"recency_score"`
+	FrequencyScore int     `json:"frequency_score"`
+	MonetaryScore  int     `json:"monetary_score"`
+	Segment        string  `json:"segment"`
+}
+
+// RFMBandsFromEnv reads comma-separated thresholds from RFM_RECENCY_BANDS,
+// RFM_FREQUENCY_BANDS, and RFM_MONETARY_BANDS, falling back to the defaults
+func RFMBandsFromEnv() (RFMBands, error) {
+	bands := DefaultRFMBands
+	for env, target := range map[string]*[]float64{
+		"RFM_RECENCY_BANDS":   &bands.RecencyDays,
+		"RFM_FREQUENCY_BANDS": &bands.Frequency,
+		"RFM_MONETARY_BANDS":  &bands.Monetary,
+	} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		thresholds, err := parseBands(value)
+		if err != nil {
+			return bands, fmt.Errorf("invalid %s: %v", env, err)
+		}
+		*target = thresholds
+	}
+	return bands, nil
+}
+
+func parseBands(value string) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("expected 4 thresholds, got %d", len(parts))
+	}
+
+	thresholds := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, err
+		}
+		thresholds = append(thresholds, threshold)
+	}
+
+	if !sort.Float64sAreSorted(thresholds) {
+		return nil, fmt.Errorf("thresholds must be ascending")
+	}
+	return thresholds, nil
+}
+
+// GenerateCustomerSegments computes RFM scores for every customer with sales
+// in the DW as of the given date and replaces the customer_segments table
+func GenerateCustomerSegments(db *sql.DB, bands RFMBands, asOf time.Time) error {
+	// Transactions are counted once each; refunds are already negative in the DW
+	rows, err := db.Query(`
+		SELECT
+			customer_id,
+			MAX(date_recorded)::date AS last_purchase,
+			COUNT(DISTINCT sale_transaction_id) AS frequency,
+			SUM(total_amount) AS monetary
+		FROM sales_totals_by_category_dw
+		WHERE deleted_at IS NULL AND customer_id IS NOT NULL AND date_recorded <= $1
+		GROUP BY customer_id
+	`, asOf.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("failed to query customer activity: %v", err)
+	}
+	defer rows.Close()
+
+	var segments []CustomerSegment
+	for rows.Next() {
+		var (
+			segment      CustomerSegment
+			lastPurchase time.Time
+		)
+		if err := rows.Scan(&segment.CustomerID, &lastPurchase, &segment.Frequency, &segment.Monetary); err != nil {
+			return fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		segment.RecencyDays = int(asOf.Sub(lastPurchase).Hours() / 24)
+		ScoreCustomer(&segment, bands)
+		segments = append(segments, segment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM customer_segments"); err != nil {
+		return fmt.Errorf("failed to clear customer segments: %v", err)
+	}
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO customer_segments
+		(customer_id, recency_days, frequency, monetary, recency_score, frequency_score, monetary_score, segment, computed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %v", err)
+	}
+	defer stmt.Close()
+
+	for _, segment := range segments {
+		if _, err := stmt.Exec(
+			segment.CustomerID, segment.RecencyDays, segment.Frequency, segment.Monetary,
+			segment.RecencyScore, segment.FrequencyScore, segment.MonetaryScore, segment.Segment,
+		); err != nil {
+			return fmt.Errorf("failed to insert customer segment: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	log.Printf("Generated segments for %d customers", len(segments))
+	return nil
+}
+
+// ScoreCustomer fills in the RFM scores and segment name from the customer's values
+func ScoreCustomer(segment *CustomerSegment, bands RFMBands) {
+	// Recency is inverted: the more recent the purchase, the higher the score
+	segment.RecencyScore = 6 - score(float64(segment.RecencyDays), bands.RecencyDays)
+	segment.FrequencyScore = score(float64(segment.Frequency), bands.Frequency)
+	segment.MonetaryScore = score(segment.Monetary, bands.Monetary)
+	segment.Segment = segmentName(segment.RecencyScore, segment.FrequencyScore, segment.MonetaryScore)
+}
+
+// score returns 1 plus the number of thresholds the value reaches
+func score(value float64, thresholds []float64) int {
+	result := 1
+	for _, threshold := range thresholds {
+		if value >= threshold {
+			result++
+		}
+	}
+	return result
+}
+
+// segmentName maps RFM scores to a named marketing segment
+func segmentName(recency, frequency, monetary int) string {
+	switch {
+	case recency >= 4 && frequency >= 4 && monetary >= 4:
+		return "Champions"
+	case recency >= 3 && frequency >= 4:
+		return "Loyal"
+	case recency >= 4 && frequency <= 1:
+		return "New"
+	case recency >= 3 && frequency >= 2:
+		return "Potential Loyalist"
+	case recency <= 2 && frequency >= 4:
+		return "Cannot Lose"
+	case recency <= 2 && frequency >= 2:
+		return "At Risk"
+	default:
+		return "Hibernating"
+	}
+}
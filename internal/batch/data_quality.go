@@ -0,0 +1,213 @@
+package batch
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Data quality checks run before aggregation
+const (
+	// CheckMissingProduct flags items whose product doesn't exist, which
+	// aggregation drops
+	CheckMissingProduct = "missing_product"
+	// CheckOrphanedCategory flags items whose product is in a category that
+	// doesn't exist, which reports can't name
+	CheckOrphanedCategory = "orphaned_category"
+	// CheckNegativeQuantity flags negative quantities on transactions other
+	// than refunds
+	CheckNegativeQuantity = "negative_quantity"
+	// CheckFutureDate flags transactions recorded in the future
+	CheckFutureDate = "future_date"
+)
+
+// DataQualityChecks are the names of the checks, in the order they run
+var DataQualityChecks = []string{CheckMissingProduct, CheckOrphanedCategory, CheckNegativeQuantity, CheckFutureDate}
+
+// dataQualityQueries select the issues each check finds among the sale
+// transactions st in range, as the columns of data_quality_issues after
+// check_name
+var dataQualityQueries = map[string]string{
+	CheckMissingProduct: `
+		SELECT st.id, sti.id, sti.product_id, NULL::integer, st.date_recorded::date,
+			'product ' || sti.product_id || ' does not exist'
+		FROM sale_transactions st
+		JOIN sale_transaction_items sti ON sti.sale_transaction_id = st.id
+		LEFT JOIN products p ON p.id = sti.product_id
+		WHERE p.id IS NULL AND ` + saleTransactionsInRange,
+	CheckOrphanedCategory: `
+		SELECT st.id, sti.id, p.id, p.category_id, st.date_recorded::date,
+			'product ' || p.id || ' is in category ' || p.category_id || ', which does not exist'
+		FROM sale_transactions st
+		JOIN sale_transaction_items sti ON sti.sale_transaction_id = st.id
+		JOIN products p ON p.id = sti.product_id
+		LEFT JOIN categories c ON c.id = p.category_id
+		WHERE c.id IS NULL AND ` + saleTransactionsInRange,
+	CheckNegativeQuantity: `
+		SELECT st.id, sti.id, sti.product_id, p.category_id, st.date_recorded::date,
+			'quantity ' || sti.quantity || ' on a transaction with status ' || LOWER(st.status)
+		FROM sale_transactions st
+		JOIN sale_transaction_items sti ON sti.sale_transaction_id = st.id
+		LEFT JOIN products p ON p.id = sti.product_id
+		WHERE sti.quantity < 0 AND LOWER(st.status) <> 'refund' AND ` + saleTransactionsInRange,
+	CheckFutureDate: `
+		SELECT st.id, NULL::integer, NULL::integer, NULL::integer, st.date_recorded::date,
+			'recorded at ' || st.date_recorded || ', which is in the future'
+		FROM sale_transactions st
+		WHERE st.date_recorded > NOW() AND ` + saleTransactionsInRange,
+}
+
+// DataQualityIssue is a problem found in the source data
+type DataQualityIssue struct {
+	ID                    int       `json:"id"`
+	Check                 string    `json:"check"`
+	SaleTransactionID     int       `json:"sale_transaction_id"`
+	SaleTransactionItemID *int      `json:"sale_transaction_item_id"`
+	ProductID             *int      `json:"product_id"`
+	CategoryID            *int      `json:"category_id"`
+	DateRecorded          string    `json:"date_recorded"`
+	Detail                string    `json:"detail"`
+	DetectedAt            time.Time `json:"detected_at"`
+}
+
+// ValidateSaleTransactions runs the data quality checks on the sale
+// transactions in options' date range and replaces the issues recorded for
+// those dates with the ones found, in a single transaction. The category
+// isn't narrowed, as an issue may be why an item has no category. Issues
+// are only flagged; aggregation still runs. A dry run logs them without
+// recording them. It returns the number of issues found.
+func ValidateSaleTransactions(db *sql.DB, options RegenerateOptions) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	args := options.args()[:2]
+	if _, err := tx.Exec(`
+		DELETE FROM data_quality_issues
+		WHERE ($1::date IS NULL OR date_recorded >= $1::date)
+			AND ($2::date IS NULL OR date_recorded <= $2::date)
+	`, args...); err != nil {
+		return 0, fmt.Errorf("failed to clear data quality issues: %v", err)
+	}
+
+	found := 0
+	for _, check := range DataQualityChecks {
+		inserted, err := tx.Exec(`
+			INSERT INTO data_quality_issues
+				(check_name, sale_transaction_id, sale_transaction_item_id, product_id, category_id, date_recorded, detail)
+			SELECT $3::text, issues.* FROM (`+dataQualityQueries[check]+`) issues
+		`, args[0], args[1], check)
+		if err != nil {
+			return 0, fmt.Errorf("failed to run data quality check %s: %v", check, err)
+		}
+		count, err := inserted.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if count > 0 {
+			log.Printf("Data quality: %d %s issues %s", count, check, options)
+		}
+		found += int(count)
+	}
+
+	// A dry run only reports the issues
+	if options.DryRun {
+		return found, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return found, nil
+}
+
+// DataQualityFilter narrows the issues listed. Empty fields match every
+// issue.
+type DataQualityFilter struct {
+	Check     string
+	StartDate string
+	EndDate   string
+}
+
+// where returns the condition selecting the filter's issues and its
+// arguments
+func (f DataQualityFilter) where() (string, []any) {
+	return `($1 = '' OR check_name = $1)
+			AND ($2::date IS NULL OR date_recorded >= $2::date)
+			AND ($3::date IS NULL OR date_recorded <= $3::date)`, []any{
+		f.Check,
+		sql.NullString{String: f.StartDate, Valid: f.StartDate != ""},
+		sql.NullString{String: f.EndDate, Valid: f.EndDate != ""},
+	}
+}
+
+// ListDataQualityIssues returns the limit most recent issues matching
+// filter, newest transactions first
+func ListDataQualityIssues(db *sql.DB, filter DataQualityFilter, limit int) ([]DataQualityIssue, error) {
+	where, args := filter.where()
+	rows, err := db.Query(`
+		SELECT id, check_name, sale_transaction_id, sale_transaction_item_id, product_id, category_id,
+			TO_CHAR(date_recorded, 'YYYY-MM-DD'), detail, detected_at
+		FROM data_quality_issues
+		WHERE `+where+`
+		ORDER BY date_recorded DESC, sale_transaction_id DESC, id
+		LIMIT $4
+	`, append(args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query data quality issues: %v", err)
+	}
+	defer rows.Close()
+
+	issues := []DataQualityIssue{}
+	for rows.Next() {
+		var issue DataQualityIssue
+		if err := rows.Scan(&issue.ID, &issue.Check, &issue.SaleTransactionID, &issue.SaleTransactionItemID, &issue.ProductID,
+			&issue.CategoryID, &issue.DateRecorded, &issue.Detail, &issue.DetectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		issues = append(issues, issue)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return issues, nil
+}
+
+// CountDataQualityIssues returns the number of issues matching filter found
+// by each check, including checks that found none
+func CountDataQualityIssues(db *sql.DB, filter DataQualityFilter) (map[string]int, error) {
+	where, args := filter.where()
+	rows, err := db.Query(`
+		SELECT check_name, COUNT(*)
+		FROM data_quality_issues
+		WHERE `+where+`
+		GROUP BY check_name
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count data quality issues: %v", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int, len(DataQualityChecks))
+	for _, check := range DataQualityChecks {
+		counts[check] = 0
+	}
+	for rows.Next() {
+		var (
+			check string
+			count int
+		)
+		if err := rows.Scan(&check, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		counts[check] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return counts, nil
+}
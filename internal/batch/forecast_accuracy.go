@@ -0,0 +1,38 @@
+package batch
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// MatchForecastActuals fills in the actual revenue from the DW table for
+// every tracked forecast period that ended before now and hasn't been
+// matched yet, and returns the number of periods matched. Forecasts without
+// a category are matched against revenue across all categories.
+func MatchForecastActuals(db *sql.DB, now time.Time) (int, error) {
+	result, err := db.Exec(`
+		UPDATE forecast_points fp
+		SET actual = COALESCE((
+				SELECT SUM(st.total_amount)
+				FROM sales_totals_by_category_dw st
+				WHERE st.date_recorded >= fp.period_start AND st.date_recorded < fp.period_end
+					AND st.deleted_at IS NULL
+					AND (f.category_id IS NULL OR st.category_id = f.category_id)
+			), 0),
+			matched_at = $1
+		FROM forecasts f
+		WHERE f.id = fp.forecast_id AND fp.actual IS NULL AND fp.period_end <= $2
+	`, now, now.Truncate(24*time.Hour))
+	if err != nil {
+		return 0, fmt.Errorf("failed to match forecast actuals: %v", err)
+	}
+
+	matched, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count matched forecast periods: %v", err)
+	}
+	log.Printf("Matched %d forecast periods against actuals", matched)
+	return int(matched), nil
+}
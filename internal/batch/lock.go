@@ -0,0 +1,99 @@
+package batch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SalesTotalsLockKey is the Postgres advisory lock key guarding
+// sales_totals_by_category_dw. Full regenerations hold it exclusively and
+// per-transaction regenerations hold it shared.
+const SalesTotalsLockKey int64 = 0x5a1e5707a15
+
+// LockMode decides what a job does when another run holds its lock
+type LockMode string
+
+const (
+	// LockWait waits up to the lock timeout for the other run to finish
+	LockWait LockMode = "wait"
+	// LockSkip gives up immediately
+	LockSkip LockMode = "skip"
+)
+
+// ErrLockHeld is returned when the lock couldn't be acquired
+var ErrLockHeld = errors.New("another run holds the lock")
+
+// LockOptions configures how a job acquires its lock
+type LockOptions struct {
+	Mode    LockMode
+	Timeout time.Duration
+}
+
+// LockOptionsFromEnv reads BATCH_LOCK_MODE (wait or skip, default wait) and
+// BATCH_LOCK_TIMEOUT_SECONDS (default 300)
+func LockOptionsFromEnv() (LockOptions, error) {
+	options := LockOptions{Mode: LockWait, Timeout: 5 * time.Minute}
+
+	if mode := os.Getenv("BATCH_LOCK_MODE"); mode != "" {
+		options.Mode = LockMode(mode)
+		if options.Mode != LockWait && options.Mode != LockSkip {
+			return options, fmt.Errorf("invalid BATCH_LOCK_MODE %q, use wait or skip", mode)
+		}
+	}
+
+	if value := os.Getenv("BATCH_LOCK_TIMEOUT_SECONDS"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return options, fmt.Errorf("invalid BATCH_LOCK_TIMEOUT_SECONDS %q", value)
+		}
+		options.Timeout = time.Duration(seconds) * time.Second
+	}
+
+	return options, nil
+}
+
+// lockPollInterval is how often a waiting run retries the lock
+const lockPollInterval = time.Second
+
+// WithAdvisoryLock runs fn while holding the exclusive advisory lock for key.
+// The lock is held on a dedicated connection so it's released even if the
+// process dies. If another run holds the lock, it returns ErrLockHeld right
+// away with LockSkip, or once the timeout passes with LockWait.
+func WithAdvisoryLock(db *sql.DB, key int64, options LockOptions, fn func() error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open lock connection: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(options.Timeout)
+	for {
+		var acquired bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+			return fmt.Errorf("failed to acquire advisory lock: %v", err)
+		}
+		if acquired {
+			break
+		}
+		if options.Mode == LockSkip || time.Now().After(deadline) {
+			return ErrLockHeld
+		}
+		log.Printf("Waiting for another run to release advisory lock %d", key)
+		time.Sleep(lockPollInterval)
+	}
+
+	defer func() {
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key); err != nil {
+			log.Printf("Warning: failed to release advisory lock %d: %v", key, err)
+		}
+	}()
+
+	return fn()
+}
@@ -0,0 +1,144 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Outcomes of a batch job. A partial run changed the data but a follow-up,
+// such as recording the run or pushing its metrics, failed.
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomePartial   = "partial"
+	OutcomeFailed    = "failed"
+	OutcomeSkipped   = "skipped"
+)
+
+// Metrics summarize a batch job run for the scheduler that started it
+type Metrics struct {
+	Job          string    `json:"job"`
+	Outcome      string    `json:"outcome"`
+	StartedAt    time.Time `json:"started_at"`
+	DurationMS   int64     `json:"duration_ms"`
+	DryRun       bool      `json:"dry_run"`
+	RowsRead     int       `json:"rows_read"`
+	RefundRows   int       `json:"refund_rows"`
+	RowsWritten  int       `json:"rows_written"`
+	RowsReplaced int       `json:"rows_replaced"`
+	ProductRows  int       `json:"product_rows"`
+	HourlyRows   int       `json:"hourly_rows"`
+	Issues       int       `json:"data_quality_issues"`
+	Partitions   int       `json:"partitions"`
+	// StagesMS is the time spent in each stage, in milliseconds
+	StagesMS map[string]int64 `json:"stages_ms"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// NewMetrics summarizes a run of job that started at started and returned
+// result and err
+func NewMetrics(job string, started time.Time, options RegenerateOptions, result RegenerateResult, err error) Metrics {
+	metrics := Metrics{
+		Job:          job,
+		Outcome:      OutcomeSucceeded,
+		StartedAt:    started,
+		DurationMS:   time.Since(started).Milliseconds(),
+		DryRun:       options.DryRun,
+		RowsRead:     result.Read,
+		RefundRows:   result.Refunds,
+		RowsWritten:  result.Written,
+		RowsReplaced: result.Replaced,
+		ProductRows:  result.ProductRows,
+		HourlyRows:   result.HourlyRows,
+		Issues:       result.Issues,
+		Partitions:   result.Partitions,
+		StagesMS:     make(map[string]int64, len(result.Stages)),
+	}
+	for stage, elapsed := range result.Stages {
+		metrics.StagesMS[stage] = elapsed.Milliseconds()
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrRunNotRecorded):
+		metrics.Outcome = OutcomePartial
+	case errors.Is(err, ErrLockHeld):
+		metrics.Outcome = OutcomeSkipped
+	default:
+		metrics.Outcome = OutcomeFailed
+	}
+	if err != nil {
+		metrics.Error = err.Error()
+	}
+	return metrics
+}
+
+// PushMetrics pushes metrics to the Prometheus pushgateway at
+// PUSHGATEWAY_URL, if set, grouped under the job. Each push replaces the
+// job's metrics of the same names, so the last success timestamp survives
+// failed runs.
+func PushMetrics(ctx context.Context, metrics Metrics) error {
+	gateway := strings.TrimSuffix(os.Getenv("PUSHGATEWAY_URL"), "/")
+	if gateway == "" {
+		return nil
+	}
+
+	var body bytes.Buffer
+	gauge := func(name, help string, value float64) {
+		fmt.Fprintf(&body, "# HELP batch_%[1]s %[2]s\n# TYPE batch_%[1]s gauge\nbatch_%[1]s %[3]g\n", name, help, value)
+	}
+	success := 0.0
+	if metrics.Outcome == OutcomeSucceeded {
+		success = 1
+	}
+	gauge("success", "Whether the last run succeeded", success)
+	gauge("duration_seconds", "Duration of the last run", float64(metrics.DurationMS)/1000)
+	gauge("rows_read", "Source rows read by the last run", float64(metrics.RowsRead))
+	gauge("refund_rows", "Refund rows read by the last run", float64(metrics.RefundRows))
+	gauge("rows_written", "Rows written by the last run", float64(metrics.RowsWritten))
+	gauge("rows_replaced", "Rows replaced by the last run", float64(metrics.RowsReplaced))
+	gauge("product_rows", "Daily product rollup rows written by the last run", float64(metrics.ProductRows))
+	gauge("hourly_rows", "Hourly rollup rows written by the last run", float64(metrics.HourlyRows))
+	gauge("data_quality_issues", "Data quality issues found by the last run", float64(metrics.Issues))
+
+	stages := make([]string, 0, len(metrics.StagesMS))
+	for stage := range metrics.StagesMS {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	fmt.Fprintf(&body, "# HELP batch_stage_duration_seconds Time the last run spent in each stage\n# TYPE batch_stage_duration_seconds gauge\n")
+	for _, stage := range stages {
+		fmt.Fprintf(&body, "batch_stage_duration_seconds{stage=%q} %g\n", stage, float64(metrics.StagesMS[stage])/1000)
+	}
+
+	if metrics.Outcome == OutcomeSucceeded && !metrics.DryRun {
+		gauge("last_success_timestamp_seconds", "When the last successful run finished", float64(time.Now().Unix()))
+	}
+
+	// POST replaces only the metrics pushed, unlike PUT
+	target := fmt.Sprintf("%s/metrics/job/%s", gateway, url.PathEscape(metrics.Job))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("pushgateway returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package batch
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// partition is a range of sale transaction IDs, from first up to but not
+// including end
+type partition struct {
+	first int
+	end   int
+}
+
+// reservedConns is the connections of the pool held while the workers run:
+// the scheduler's leader lock, the run's advisory lock, and the swap
+// transaction
+const reservedConns = 3
+
+// poolWorkers returns workers, clamped so the workers have connections of
+// db's pool left over by the connections held for the whole run. A bounded
+// pool without one for a worker would leave them waiting forever while the
+// run holds the lock.
+func poolWorkers(db *sql.DB, workers int) (int, error) {
+	limit := db.Stats().MaxOpenConnections
+	if limit == 0 {
+		return workers, nil
+	}
+	if limit <= reservedConns {
+		return 0, fmt.Errorf("the database pool allows %d connections; aggregating sales totals needs at least %d", limit, reservedConns+1)
+	}
+	return min(workers, limit-reservedConns), nil
+}
+
+// partitionResult is the records a worker aggregated for a partition
+type partitionResult struct {
+	partition partition
+	records   []SalesTotal
+	rollups   *rollups
+	counts    itemCounts
+	elapsed   time.Duration
+	err       error
+}
+
+// aggregatePartitions aggregates the sale items in options' range on a pool
+// of workers, each querying and aggregating one partition of sale
+// transaction IDs at a time, and passes each partition's records to sink.
+// A transaction's items all fall in one partition, so partitions aggregate
+// independently, and only the partitions in flight are held in memory rather
+// than every record at once. sink is called from one goroutine at a time;
+// the first error stops the workers. Rows soft-deleted as corrections, keyed
+// in deleted, are skipped. Unless nil, the items are also totalled into
+// totals. It returns the items read, records written, and time spent
+// aggregating and in sink.
+func aggregatePartitions(db *sql.DB, options RegenerateOptions, deleted map[recordKey]bool, totals *rollups, sink func([]SalesTotal) error) (RegenerateResult, error) {
+	result := RegenerateResult{Stages: make(map[string]time.Duration)}
+	workers, partitionSize, err := options.pool()
+	if err != nil {
+		return result, err
+	}
+	if workers, err = poolWorkers(db, workers); err != nil {
+		return result, err
+	}
+
+	first, last, err := saleTransactionIDRange(db, options)
+	if err != nil {
+		return result, err
+	}
+	if last < first {
+		return result, nil
+	}
+	count := (last-first)/partitionSize + 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	partitions := make(chan partition)
+	go func() {
+		defer close(partitions)
+		for start := first; start <= last; start += partitionSize {
+			select {
+			case partitions <- partition{first: start, end: start + partitionSize}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	results := make(chan partitionResult)
+	for range min(workers, count) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range partitions {
+				started := time.Now()
+				var partitionRollups *rollups
+				if totals != nil {
+					partitionRollups = newRollups()
+				}
+				records, counts, err := aggregatePartition(ctx, db, options, p, deleted, partitionRollups)
+				select {
+				case results <- partitionResult{partition: p, records: records, rollups: partitionRollups, counts: counts, elapsed: time.Since(started), err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for aggregated := range results {
+		p := aggregated.partition
+		if aggregated.err != nil {
+			return result, fmt.Errorf("failed to aggregate sale transactions %d-%d: %v", p.first, p.end-1, aggregated.err)
+		}
+		started := time.Now()
+		if err := sink(aggregated.records); err != nil {
+			return result, err
+		}
+		result.Stages[StageLoad] += time.Since(started)
+		if totals != nil {
+			totals.merge(aggregated.rollups)
+		}
+		result.Stages[StageAggregate] += aggregated.elapsed
+		result.Partitions++
+		result.Read += aggregated.counts.read
+		result.Refunds += aggregated.counts.refunds
+		result.Written += len(aggregated.records)
+		if options.Verbose {
+			log.Printf("Aggregated %d records for sale transactions %d-%d", len(aggregated.records), p.first, p.end-1)
+		}
+	}
+
+	log.Printf("Aggregated %d partitions of up to %d sale transactions with %d workers", count, partitionSize, min(workers, count))
+	return result, nil
+}
+
+// saleTransactionIDRange returns the lowest and highest IDs of the sale
+// transactions in options' range, or a last below first when there are none
+func saleTransactionIDRange(db *sql.DB, options RegenerateOptions) (int, int, error) {
+	var first, last int
+	err := db.QueryRow(`
+		SELECT COALESCE(MIN(st.id), 0), COALESCE(MAX(st.id), -1)
+		FROM sale_transactions st
+		WHERE `+saleTransactionsInRange, options.args()[:2]...).Scan(&first, &last)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query sale transaction range: %v", err)
+	}
+	return first, last, nil
+}
+
+// aggregatePartition queries and aggregates the sale items in options' range
+// of the sale transactions in p
+func aggregatePartition(ctx context.Context, db *sql.DB, options RegenerateOptions, p partition, deleted map[recordKey]bool, totals *rollups) ([]SalesTotal, itemCounts, error) {
+	where, args := options.saleItemsWhere()
+	rows, err := db.QueryContext(ctx, saleItemsQuery+where+" AND st.id >= $4 AND st.id < $5 ORDER BY st.date_recorded, st.id, p.category_id",
+		append(args, p.first, p.end)...)
+	if err != nil {
+		return nil, itemCounts{}, fmt.Errorf("failed to query sales data: %v", err)
+	}
+	defer rows.Close()
+
+	return aggregateRows(rows, deleted, totals)
+}
@@ -0,0 +1,52 @@
+package batch
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+// unusedConnector is a connector for pools whose connections are never opened
+type unusedConnector struct{}
+
+func (unusedConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("not connected")
+}
+
+func (unusedConnector) Driver() driver.Driver { return nil }
+
+func TestPoolWorkers(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		maxOpen  int
+		workers  int
+		want     int
+		rejected bool
+	}{
+		{"unbounded pool", 0, 4, 4, false},
+		{"large pool", 25, 4, 4, false},
+		{"clamped to the spare connections", 5, 4, 2, false},
+		{"one spare connection", 4, 4, 1, false},
+		{"no spare connection", 3, 4, 0, true},
+		{"single connection", 1, 4, 0, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			db := sql.OpenDB(unusedConnector{})
+			defer db.Close()
+			db.SetMaxOpenConns(test.maxOpen)
+
+			workers, err := poolWorkers(db, test.workers)
+			if test.rejected {
+				if err == nil {
+					t.Fatalf("got %d workers, want an error", workers)
+				}
+				return
+			}
+			if err != nil || workers != test.want {
+				t.Fatalf("got %d workers, %v; want %d", workers, err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package batch
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RegenerateOptions narrows a sales totals regeneration to part of the DW
+// table, such as the window a data correction touched, and controls what it
+// writes and logs. The zero value regenerates the whole table.
+type RegenerateOptions struct {
+	// StartDate and EndDate bound the dates regenerated, inclusive, as
+	// YYYY-MM-DD, or are empty to leave the range open on that side
+	StartDate string
+	EndDate   string
+	// CategoryID is the category regenerated, or 0 for every category
+	CategoryID int
+	// DryRun logs what would change without writing
+	DryRun bool
+	// Verbose logs the row count and total of each date and category before
+	// and after
+	Verbose bool
+	// Workers is the number of partitions aggregated at once, and
+	// PartitionSize the number of sale transaction IDs in each. Zero uses
+	// SALES_TOTALS_WORKERS (default 4) and SALES_TOTALS_PARTITION_SIZE
+	// (default 10000).
+	Workers       int
+	PartitionSize int
+}
+
+// pool returns the worker count and partition size, falling back to the
+// environment and then the defaults for those left zero
+func (o RegenerateOptions) pool() (int, int, error) {
+	workers, partitionSize := o.Workers, o.PartitionSize
+	for _, setting := range []struct {
+		env      string
+		value    *int
+		fallback int
+	}{{"SALES_TOTALS_WORKERS", &workers, 4}, {"SALES_TOTALS_PARTITION_SIZE", &partitionSize, 10000}} {
+		if *setting.value != 0 {
+			continue
+		}
+		*setting.value = setting.fallback
+		if value := os.Getenv(setting.env); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 {
+				return 0, 0, fmt.Errorf("invalid %s %q", setting.env, value)
+			}
+			*setting.value = parsed
+		}
+	}
+	if workers < 1 || partitionSize < 1 {
+		return 0, 0, fmt.Errorf("workers and partition size must be positive")
+	}
+	return workers, partitionSize, nil
+}
+
+// String describes the dates and category regenerated, for logs
+func (o RegenerateOptions) String() string {
+	var parts []string
+	switch {
+	case o.StartDate != "" && o.EndDate != "":
+		parts = append(parts, fmt.Sprintf("from %s to %s", o.StartDate, o.EndDate))
+	case o.StartDate != "":
+		parts = append(parts, "from "+o.StartDate)
+	case o.EndDate != "":
+		parts = append(parts, "up to "+o.EndDate)
+	}
+	if o.CategoryID != 0 {
+		parts = append(parts, fmt.Sprintf("in category %d", o.CategoryID))
+	}
+	if len(parts) == 0 {
+		return "for all history"
+	}
+	return strings.Join(parts, " ")
+}
+
+// args returns the query arguments of the conditions below: the dates, NULL
+// when open, and the category
+func (o RegenerateOptions) args() []any {
+	return []any{
+		sql.NullString{String: o.StartDate, Valid: o.StartDate != ""},
+		sql.NullString{String: o.EndDate, Valid: o.EndDate != ""},
+		o.CategoryID,
+	}
+}
+
+// saleItemsWhere returns the WHERE clause selecting the sale items in range,
+// for saleItemsQuery, and its arguments. Sale transactions are timestamped,
+// so the end date includes the whole day.
+func (o RegenerateOptions) saleItemsWhere() (string, []any) {
+	return `WHERE ` + saleTransactionsInRange + `
+		AND ($3 = 0 OR p.category_id = $3)`, o.args()
+}
+
+// saleTransactionsInRange is the condition on the sale transactions st in
+// range, taking the dates from the arguments of args
+const saleTransactionsInRange = `($1::date IS NULL OR st.date_recorded >= $1::date)
+		AND ($2::date IS NULL OR st.date_recorded < $2::date + 1)`
+
+// salesTotalsWhere returns the condition selecting the DW rows in range and
+// its arguments
+func (o RegenerateOptions) salesTotalsWhere() (string, []any) {
+	return o.rangeWhere("date_recorded")
+}
+
+// rangeWhere returns the condition selecting the rows of a DW table in range,
+// by the date of dateColumn and category_id, and its arguments
+func (o RegenerateOptions) rangeWhere(dateColumn string) (string, []any) {
+	return `($1::date IS NULL OR ` + dateColumn + ` >= $1::date)
+		AND ($2::date IS NULL OR ` + dateColumn + ` <= $2::date)
+		AND ($3 = 0 OR category_id = $3)`, o.args()
+}
+
+// salesTotalsSummary is the row count and total of a date and category's DW
+// rows
+type salesTotalsSummary struct {
+	rows  int
+	total float64
+}
+
+// summaryKey identifies a date and category
+type summaryKey struct {
+	date       string
+	categoryID int
+}
+
+// salesTotalsSummaries are the row count and total of each date and category
+type salesTotalsSummaries map[summaryKey]salesTotalsSummary
+
+// add counts records into the summaries
+func (s salesTotalsSummaries) add(records []SalesTotal) {
+	for _, record := range records {
+		key := summaryKey{date: dateOnly(record.DateRecorded), categoryID: record.CategoryID}
+		summary := s[key]
+		summary.rows++
+		summary.total += record.TotalAmount
+		s[key] = summary
+	}
+}
+
+// querySalesTotalsSummaries returns the summaries of the live DW rows in
+// range
+func querySalesTotalsSummaries(db *sql.DB, options RegenerateOptions) (salesTotalsSummaries, error) {
+	where, args := options.salesTotalsWhere()
+	rows, err := db.Query(`
+		SELECT date_recorded, category_id, COUNT(*), SUM(total_amount)
+		FROM sales_totals_by_category_dw
+		WHERE deleted_at IS NULL AND `+where+`
+		GROUP BY date_recorded, category_id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query existing sales totals: %v", err)
+	}
+	defer rows.Close()
+
+	summaries := make(salesTotalsSummaries)
+	for rows.Next() {
+		var (
+			key     summaryKey
+			summary salesTotalsSummary
+		)
+		if err := rows.Scan(&key.date, &key.categoryID, &summary.rows, &summary.total); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		key.date = dateOnly(key.date)
+		summaries[key] = summary
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return summaries, nil
+}
+
+// logSalesTotalsChanges logs how the regenerated rows, summarized in after,
+// change the live DW rows in range, summarized in before: the number of rows
+// replaced and, when verbose, each date and category whose rows or total
+// change
+func logSalesTotalsChanges(options RegenerateOptions, before, after salesTotalsSummaries) {
+	existing, regenerated := 0, 0
+	for _, summary := range before {
+		existing += summary.rows
+	}
+	for _, summary := range after {
+		regenerated += summary.rows
+	}
+	log.Printf("%d live sales total records %s, regenerated as %d", existing, options, regenerated)
+	if !options.Verbose {
+		return
+	}
+
+	keys := make([]summaryKey, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].date != keys[j].date {
+			return keys[i].date < keys[j].date
+		}
+		return keys[i].categoryID < keys[j].categoryID
+	})
+
+	unchanged := 0
+	for _, key := range keys {
+		old, updated := before[key], after[key]
+		if old.rows == updated.rows && fmt.Sprintf("%.2f", old.total) == fmt.Sprintf("%.2f", updated.total) {
+			unchanged++
+			continue
+		}
+		log.Printf("%s category %d: %d records totalling %.2f -> %d records totalling %.2f",
+			key.date, key.categoryID, old.rows, old.total, updated.rows, updated.total)
+	}
+	log.Printf("%d dates and categories unchanged", unchanged)
+}
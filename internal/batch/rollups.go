@@ -0,0 +1,171 @@
+package batch
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// The rollup DW tables, populated from the same sale items as the category
+// table so reports can read them instead of aggregating raw transactions
+const (
+	productRollupTable = "sales_totals_by_product_dw"
+	hourlyRollupTable  = "sales_totals_by_hour_dw"
+)
+
+// Columns of the rollup tables. The first is the period, a date or an hour.
+var (
+	productRollupColumns = []string{"date_recorded", "product_id", "category_id", "currency", "status", "transactions", "quantity", "total_amount", "gross_amount", "discount_amount", "refund_amount"}
+	hourlyRollupColumns  = []string{"hour", "category_id", "currency", "status", "transactions", "quantity", "total_amount", "gross_amount", "discount_amount", "refund_amount"}
+)
+
+// rollupKey identifies a rollup row: a period, the product for the daily
+// product table or 0 for the hourly table, and the category, currency, and
+// status of the transactions totalled
+type rollupKey struct {
+	period     string
+	productID  int
+	categoryID int
+	currency   string
+	status     string
+}
+
+// rollupTotals are the running totals of a rollup row. Sale items arrive in
+// transaction order, so a transaction is counted the first time in a row it
+// appears.
+type rollupTotals struct {
+	saleAmounts
+	transactions    int
+	lastTransaction int
+}
+
+// rollups accumulates sale items into the daily product and hourly category
+// totals
+type rollups struct {
+	products map[rollupKey]*rollupTotals
+	hours    map[rollupKey]*rollupTotals
+}
+
+func newRollups() *rollups {
+	return &rollups{
+		products: make(map[rollupKey]*rollupTotals),
+		hours:    make(map[rollupKey]*rollupTotals),
+	}
+}
+
+// add adds a sale item to the day's product and the hour's category totals
+func (r *rollups) add(item SaleItem) {
+	status := strings.ToLower(item.Status)
+	day := rollupKey{period: dateOnly(item.DateRecorded), productID: item.ProductID, categoryID: item.CategoryID, currency: item.Currency, status: status}
+	hour := rollupKey{period: hourOnly(item.DateRecorded), categoryID: item.CategoryID, currency: item.Currency, status: status}
+	for _, rollup := range []struct {
+		totals map[rollupKey]*rollupTotals
+		key    rollupKey
+	}{{r.products, day}, {r.hours, hour}} {
+		totals, ok := rollup.totals[rollup.key]
+		if !ok {
+			totals = &rollupTotals{}
+			rollup.totals[rollup.key] = totals
+		}
+		totals.add(item, status)
+		if !ok || totals.lastTransaction != item.SaleTransactionID {
+			totals.transactions++
+			totals.lastTransaction = item.SaleTransactionID
+		}
+	}
+}
+
+// merge adds other's totals, which must cover other transactions, to r
+func (r *rollups) merge(other *rollups) {
+	for _, rollup := range []struct{ into, from map[rollupKey]*rollupTotals }{{r.products, other.products}, {r.hours, other.hours}} {
+		for key, from := range rollup.from {
+			into, ok := rollup.into[key]
+			if !ok {
+				rollup.into[key] = from
+				continue
+			}
+			into.total += from.total
+			into.gross += from.gross
+			into.discount += from.discount
+			into.refund += from.refund
+			into.quantity += from.quantity
+			into.transactions += from.transactions
+		}
+	}
+}
+
+// hourOnly trims a scanned timestamp to the start of its hour, as
+// YYYY-MM-DD HH:00:00
+func hourOnly(timestamp string) string {
+	if len(timestamp) >= 13 {
+		return timestamp[:10] + " " + timestamp[11:13] + ":00:00"
+	}
+	return dateOnly(timestamp) + " 00:00:00"
+}
+
+// replace replaces the rollup rows in options' range and category with the
+// totals, using the given transaction, and returns the number of product and
+// hourly rows written
+func (r *rollups) replace(tx *sql.Tx, options RegenerateOptions) (int, int, error) {
+	for table, column := range map[string]string{productRollupTable: "date_recorded", hourlyRollupTable: "hour::date"} {
+		where, args := options.rangeWhere(column)
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE "+where, args...); err != nil {
+			return 0, 0, fmt.Errorf("failed to clear %s: %v", table, err)
+		}
+	}
+	return r.insert(tx)
+}
+
+// replaceDates replaces the rollup rows of the given dates with the totals,
+// using the given transaction
+func (r *rollups) replaceDates(tx *sql.Tx, dates []string) (int, int, error) {
+	for table, column := range map[string]string{productRollupTable: "date_recorded", hourlyRollupTable: "hour::date"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE "+column+" = ANY($1::date[])", pq.Array(dates)); err != nil {
+			return 0, 0, fmt.Errorf("failed to clear %s: %v", table, err)
+		}
+	}
+	return r.insert(tx)
+}
+
+// insert copies the totals into the rollup tables
+func (r *rollups) insert(tx *sql.Tx) (int, int, error) {
+	batchSize, err := insertBatchSize()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	products := rollupRows(r.products, func(key rollupKey) []any {
+		return []any{key.period, key.productID, key.categoryID, key.currency, key.status}
+	})
+	hours := rollupRows(r.hours, func(key rollupKey) []any {
+		return []any{key.period, key.categoryID, key.currency, key.status}
+	})
+	for _, rollup := range []struct {
+		table   string
+		columns []string
+		rows    [][]any
+	}{{productRollupTable, productRollupColumns, products}, {hourlyRollupTable, hourlyRollupColumns, hours}} {
+		for i := 0; i < len(rollup.rows); i += batchSize {
+			end := min(i+batchSize, len(rollup.rows))
+			if err := copyIn(tx, rollup.table, rollup.columns, rollup.rows[i:end]); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	log.Printf("Wrote %d daily product and %d hourly rollup records", len(products), len(hours))
+	return len(products), len(hours), nil
+}
+
+// rollupRows returns the rows of totals, each the values of its key followed
+// by its totals
+func rollupRows(totals map[rollupKey]*rollupTotals, key func(rollupKey) []any) [][]any {
+	rows := make([][]any, 0, len(totals))
+	for k, t := range totals {
+		rows = append(rows, append(key(k), t.transactions, t.quantity, t.total, t.gross, t.discount, t.refund))
+	}
+	return rows
+}
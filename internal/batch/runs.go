@@ -0,0 +1,164 @@
+package batch
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SalesTotalsJob is the batch_runs job name of sales totals regenerations
+const SalesTotalsJob = "sales-totals"
+
+// What started a batch run
+const (
+	TriggerAPI       = "api"
+	TriggerScheduler = "scheduler"
+	TriggerCLI       = "cli"
+	TriggerScenario  = "scenario"
+)
+
+// Statuses of a batch run. A run is skipped when another held its lock.
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunSkipped   = "skipped"
+)
+
+// ErrRunNotRecorded is returned when a run succeeded but recording its
+// result in batch_runs failed
+var ErrRunNotRecorded = errors.New("run succeeded but wasn't recorded")
+
+// Run is a recorded batch run
+type Run struct {
+	ID          int        `json:"id"`
+	Job         string     `json:"job"`
+	TriggeredBy string     `json:"triggered_by"`
+	Status      string     `json:"status"`
+	StartDate   *string    `json:"start_date"`
+	EndDate     *string    `json:"end_date"`
+	CategoryID  *int       `json:"category_id"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	// DurationMS is how long a finished run took, in milliseconds
+	DurationMS   *int64 `json:"duration_ms"`
+	RowsReplaced *int   `json:"rows_replaced"`
+	RowsWritten  *int   `json:"rows_written"`
+	Error        string `json:"error,omitempty"`
+}
+
+// StartRun records a run of job covering options' range and category as
+// running, and returns its ID
+func StartRun(db *sql.DB, job, triggeredBy string, options RegenerateOptions) (int, error) {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO batch_runs (job, triggered_by, status, start_date, end_date, category_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))
+		RETURNING id
+	`, job, triggeredBy, RunRunning, sql.NullString{String: options.StartDate, Valid: options.StartDate != ""},
+		sql.NullString{String: options.EndDate, Valid: options.EndDate != ""}, options.CategoryID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record batch run: %v", err)
+	}
+	return id, nil
+}
+
+// FinishRun records how a run ended: succeeded with result, skipped when
+// runErr is ErrLockHeld, or failed with runErr
+func FinishRun(db *sql.DB, id int, result RegenerateResult, runErr error) error {
+	status, message := RunSucceeded, ""
+	switch {
+	case errors.Is(runErr, ErrLockHeld):
+		status, message = RunSkipped, runErr.Error()
+	case runErr != nil:
+		status, message = RunFailed, runErr.Error()
+	}
+
+	_, err := db.Exec(`
+		UPDATE batch_runs
+		SET status = $2, finished_at = NOW(), rows_replaced = $3, rows_written = $4, error = NULLIF($5, '')
+		WHERE id = $1
+	`, id, status, sql.NullInt64{Int64: int64(result.Replaced), Valid: status == RunSucceeded},
+		sql.NullInt64{Int64: int64(result.Written), Valid: status == RunSucceeded}, message)
+	if err != nil {
+		return fmt.Errorf("failed to record batch run result: %v", err)
+	}
+	return nil
+}
+
+// RunSalesTotals regenerates sales totals like RegenerateSalesTotals and
+// records the run in batch_runs. Dry runs change nothing and aren't recorded.
+func RunSalesTotals(db *sql.DB, triggeredBy string, lock LockOptions, options RegenerateOptions) (RegenerateResult, error) {
+	if options.DryRun {
+		return RegenerateSalesTotals(db, lock, options)
+	}
+
+	id, err := StartRun(db, SalesTotalsJob, triggeredBy, options)
+	if err != nil {
+		return RegenerateResult{}, err
+	}
+	result, runErr := RegenerateSalesTotals(db, lock, options)
+	if err := FinishRun(db, id, result, runErr); err != nil {
+		if runErr != nil {
+			return result, errors.Join(runErr, err)
+		}
+		return result, fmt.Errorf("%w: %v", ErrRunNotRecorded, err)
+	}
+	return result, runErr
+}
+
+// GetRun returns a run, or sql.ErrNoRows when there is none with id
+func GetRun(db *sql.DB, id int) (Run, error) {
+	runs, err := queryRuns(db, "WHERE id = $1", id)
+	if err != nil {
+		return Run{}, err
+	}
+	if len(runs) == 0 {
+		return Run{}, sql.ErrNoRows
+	}
+	return runs[0], nil
+}
+
+// ListRuns returns the limit most recent runs, newest first, of job and with
+// status, each unless empty
+func ListRuns(db *sql.DB, job, status string, limit int) ([]Run, error) {
+	return queryRuns(db, `
+		WHERE ($1 = '' OR job = $1) AND ($2 = '' OR status = $2)
+		ORDER BY started_at DESC, id DESC
+		LIMIT $3
+	`, job, status, limit)
+}
+
+// queryRuns returns the runs selected by the where clause, which may also
+// order and limit them
+func queryRuns(db *sql.DB, where string, args ...any) ([]Run, error) {
+	rows, err := db.Query(`
+		SELECT id, job, triggered_by, status, TO_CHAR(start_date, 'YYYY-MM-DD'), TO_CHAR(end_date, 'YYYY-MM-DD'),
+			category_id, started_at, finished_at, rows_replaced, rows_written, COALESCE(error, '')
+		FROM batch_runs
+	`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batch runs: %v", err)
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		var run Run
+		if err := rows.Scan(&run.ID, &run.Job, &run.TriggeredBy, &run.Status, &run.StartDate, &run.EndDate,
+			&run.CategoryID, &run.StartedAt, &run.FinishedAt, &run.RowsReplaced, &run.RowsWritten, &run.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if run.FinishedAt != nil {
+			duration := run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+			run.DurationMS = &duration
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return runs, nil
+}
@@ -0,0 +1,656 @@
+package batch
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SalesTotal represents a record for the sales_totals_by_category_dw table
+type SalesTotal struct {
+	DateRecorded      string
+	SaleTransactionID int
+	CategoryID        int
+	CustomerID        sql.NullInt64
+	CompanyID         sql.NullInt64
+	Currency          string
+	Status            string
+	// TotalAmount is the net total, GrossAmount less DiscountAmount and
+	// RefundAmount
+	TotalAmount    float64
+	GrossAmount    float64
+	DiscountAmount float64
+	RefundAmount   float64
+	// Quantity is the units of the items, net of refunds
+	Quantity int
+}
+
+// SaleItem represents a single sale transaction item joined with its category
+type SaleItem struct {
+	DateRecorded      string
+	SaleTransactionID int
+	ProductID         int
+	CategoryID        int
+	CustomerID        sql.NullInt64
+	CompanyID         sql.NullInt64
+	Currency          string
+	Quantity          int
+	TotalAmount       float64
+	Status            string
+	// ListPrice is the product's unit price, which the item sold at or below
+	ListPrice float64
+}
+
+// saleAmounts are the running totals of a transaction's items in a category
+type saleAmounts struct {
+	total    float64
+	gross    float64
+	discount float64
+	refund   float64
+	quantity int
+}
+
+// add adds a sale item, of a transaction with the lowercase status, to the
+// totals. Refunds are subtracted from the net total; items sold below list
+// price count the difference as a discount.
+func (a *saleAmounts) add(item SaleItem, status string) {
+	if status == "refund" {
+		a.total -= item.TotalAmount
+		a.refund += item.TotalAmount
+		a.quantity -= item.Quantity
+		return
+	}
+	listAmount := item.ListPrice * float64(item.Quantity)
+	a.total += item.TotalAmount
+	a.gross += max(listAmount, item.TotalAmount)
+	a.discount += max(listAmount-item.TotalAmount, 0)
+	a.quantity += item.Quantity
+}
+
+// saleTransaction is what a transaction's records share. A transaction
+// belongs to a single customer and company, in a single currency and status.
+type saleTransaction struct {
+	customerID sql.NullInt64
+	companyID  sql.NullInt64
+	currency   string
+	status     string
+}
+
+// Aggregator accumulates sale items into per-transaction category totals
+type Aggregator struct {
+	totals       map[recordKey]saleAmounts
+	transactions map[int]saleTransaction
+}
+
+// NewAggregator returns an empty Aggregator
+func NewAggregator() *Aggregator {
+	return &Aggregator{
+		totals:       make(map[recordKey]saleAmounts),
+		transactions: make(map[int]saleTransaction),
+	}
+}
+
+// Add adds a sale item to the running totals
+func (a *Aggregator) Add(item SaleItem) {
+	key := recordKey{date: item.DateRecorded, saleTransactionID: item.SaleTransactionID, categoryID: item.CategoryID}
+
+	// Aggregate totals by category for each transaction
+	status := strings.ToLower(item.Status)
+	amounts := a.totals[key]
+	amounts.add(item, status)
+	a.totals[key] = amounts
+
+	a.transactions[item.SaleTransactionID] = saleTransaction{
+		customerID: item.CustomerID,
+		companyID:  item.CompanyID,
+		currency:   item.Currency,
+		status:     status,
+	}
+}
+
+// Records converts the aggregated totals into DW records
+func (a *Aggregator) Records() []SalesTotal {
+	records := make([]SalesTotal, 0, len(a.totals))
+	for key, amounts := range a.totals {
+		transaction := a.transactions[key.saleTransactionID]
+		record := SalesTotal{
+			DateRecorded:      key.date,
+			SaleTransactionID: key.saleTransactionID,
+			CategoryID:        key.categoryID,
+			CustomerID:        transaction.customerID,
+			CompanyID:         transaction.companyID,
+			Currency:          transaction.currency,
+			Status:            transaction.status,
+			TotalAmount:       amounts.total,
+			GrossAmount:       amounts.gross,
+			DiscountAmount:    amounts.discount,
+			RefundAmount:      amounts.refund,
+			Quantity:          amounts.quantity,
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// ClearExistingData removes all live rows from the sales_totals_by_category_dw
+// table. Soft-deleted rows are kept so corrections survive regeneration.
+func ClearExistingData(db *sql.DB) error {
+	query := "DELETE FROM sales_totals_by_category_dw WHERE deleted_at IS NULL"
+	_, err := db.Exec(query)
+	if err != nil {
+		return fmt.Errorf("failed to clear existing data: %v", err)
+	}
+	log.Println("Cleared existing data from sales_totals_by_category_dw table")
+	return nil
+}
+
+// DBTX is satisfied by both *sql.DB and *sql.Tx so aggregation can run on
+// its own or inside a caller's transaction
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// saleItemsQuery selects sale items with their category; callers append a
+// WHERE clause and ordering
+const saleItemsQuery = `
+	SELECT
+		st.date_recorded,
+		st.id as sale_transaction_id,
+		p.category_id,
+		st.customer_id,
+		st.company_id,
+		st.currency,
+		sti.quantity,
+		sti.total_amount,
+		st.status,
+		p.price,
+		sti.product_id
+	FROM sale_transactions st
+	JOIN sale_transaction_items sti ON st.id = sti.sale_transaction_id
+	JOIN products p ON sti.product_id = p.id
+`
+
+// RegenerateResult counts the DW rows a regeneration changed and the work it
+// took
+type RegenerateResult struct {
+	// Replaced is the number of live rows removed and Written the number of
+	// rows regenerated in their place
+	Replaced int
+	Written  int
+	// Read is the number of sale items aggregated, Refunds the number of
+	// those that were refunds, and Partitions the number of partitions of
+	// sale transactions they were read in
+	Read       int
+	Refunds    int
+	Partitions int
+	// ProductRows and HourlyRows are the number of rows written to the daily
+	// product and hourly rollup tables
+	ProductRows int
+	HourlyRows  int
+	// Issues is the number of data quality issues found in the source data
+	Issues int
+	// Stages is the time spent in each stage. Partitions are aggregated in
+	// parallel, so StageAggregate can exceed the run's duration.
+	Stages map[string]time.Duration
+}
+
+// Stages of a regeneration
+const (
+	// StageValidate runs the data quality checks
+	StageValidate = "validate"
+	// StagePrepare reads the soft-deleted rows and, to log changes, the
+	// current totals
+	StagePrepare = "prepare"
+	// StageAggregate queries and aggregates sale items, summed across workers
+	StageAggregate = "aggregate"
+	// StageLoad writes the aggregated records to the staging table
+	StageLoad = "load"
+	// StageRollups writes the daily product and hourly rollup tables
+	StageRollups = "rollups"
+	// StageSwap replaces the live rows with the staged ones and commits
+	StageSwap = "swap"
+)
+
+// itemCounts counts the sale items an aggregation read
+type itemCounts struct {
+	read    int
+	refunds int
+}
+
+// RegenerateSalesTotals regenerates the DW rows in options' range and
+// category, or the whole table, while holding SalesTotalsLockKey, so
+// overlapping runs can't interleave their deletes and inserts. The source
+// data is validated first and the issues found recorded. Partitions of
+// sale transactions are aggregated on a pool of workers and loaded as they
+// finish, so memory is bounded by the partitions in flight rather than the
+// size of the table. The same pass totals the daily product and hourly
+// rollup tables. The new rows replace the live ones in a single transaction,
+// so readers see the previous totals until it commits and never a
+// half-loaded table. A dry run only logs what would change.
+func RegenerateSalesTotals(db *sql.DB, lock LockOptions, options RegenerateOptions) (RegenerateResult, error) {
+	var result RegenerateResult
+	err := WithAdvisoryLock(db, SalesTotalsLockKey, lock, func() error {
+		started := time.Now()
+		issues, err := ValidateSaleTransactions(db, options)
+		if err != nil {
+			return err
+		}
+		validated := time.Since(started)
+
+		started = time.Now()
+		deleted, err := querySoftDeletedKeys(db)
+		if err != nil {
+			return err
+		}
+
+		var before, after salesTotalsSummaries
+		if options.Verbose || options.DryRun {
+			if before, err = querySalesTotalsSummaries(db, options); err != nil {
+				return err
+			}
+			after = make(salesTotalsSummaries)
+		}
+		prepared := time.Since(started)
+
+		// load aggregates the rows in range into sink, summarizing them
+		// when they're logged, and replaces the rollups on tx. A dry run
+		// has no tx and skips the rollups.
+		load := func(tx *sql.Tx, sink func([]SalesTotal) error) (RegenerateResult, error) {
+			var totals *rollups
+			if tx != nil {
+				totals = newRollups()
+			}
+			result, err := aggregatePartitions(db, options, deleted, totals, func(records []SalesTotal) error {
+				if after != nil {
+					after.add(records)
+				}
+				return sink(records)
+			})
+			if err != nil || tx == nil {
+				return result, err
+			}
+
+			started := time.Now()
+			result.ProductRows, result.HourlyRows, err = totals.replace(tx, options)
+			result.Stages[StageRollups] = time.Since(started)
+			return result, err
+		}
+
+		if options.DryRun {
+			result, err = load(nil, func([]SalesTotal) error { return nil })
+			if err != nil {
+				return err
+			}
+			result.Issues = issues
+			result.Stages[StageValidate] = validated
+			result.Stages[StagePrepare] = prepared
+			logSalesTotalsChanges(options, before, after)
+			log.Printf("Dry run: would regenerate %d sales total records %s", result.Written, options)
+			return nil
+		}
+
+		result, err = ReplaceSalesTotals(db, options, load)
+		if err != nil {
+			return fmt.Errorf("failed to replace sales totals: %v", err)
+		}
+		result.Issues = issues
+		result.Stages[StageValidate] = validated
+		result.Stages[StagePrepare] = prepared
+		if after != nil {
+			logSalesTotalsChanges(options, before, after)
+		}
+
+		log.Printf("Regenerated %d sales total records %s", result.Written, options)
+		return nil
+	})
+	return result, err
+}
+
+// RegenerateTransactions re-aggregates the DW rows for the given sale
+// transactions, e.g. after a product moved to another category. It runs on
+// the caller's transaction so the change and the DW stay consistent.
+func RegenerateTransactions(tx *sql.Tx, saleTransactionIDs []int) error {
+	if len(saleTransactionIDs) == 0 {
+		return nil
+	}
+
+	// Wait for any full regeneration to finish; it holds the lock exclusively
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock_shared($1)", SalesTotalsLockKey); err != nil {
+		return fmt.Errorf("failed to acquire advisory lock: %v", err)
+	}
+
+	ids := pq.Array(saleTransactionIDs)
+	records, err := aggregateSaleItems(tx, nil, saleItemsQuery+"WHERE st.id = ANY($1) ORDER BY st.date_recorded, st.id, p.category_id", ids)
+	if err != nil {
+		return err
+	}
+	if err := regenerateRollupDates(tx, ids); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM sales_totals_by_category_dw WHERE deleted_at IS NULL AND sale_transaction_id = ANY($1)", ids); err != nil {
+		return fmt.Errorf("failed to clear transaction totals: %v", err)
+	}
+
+	if err := insertSalesTotals(tx, records); err != nil {
+		return fmt.Errorf("failed to insert sales totals: %v", err)
+	}
+
+	log.Printf("Regenerated %d sales total records for %d transactions", len(records), len(saleTransactionIDs))
+	return nil
+}
+
+// regenerateRollupDates re-totals the rollup rows of every date the given
+// sale transactions were or are recorded on, since the rollups total many
+// transactions, using the caller's transaction
+func regenerateRollupDates(tx *sql.Tx, ids any) error {
+	rows, err := tx.Query(`
+		SELECT date_recorded::date::text FROM sale_transactions WHERE id = ANY($1)
+		UNION
+		SELECT date_recorded::text FROM sales_totals_by_category_dw WHERE sale_transaction_id = ANY($1)
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("failed to query transaction dates: %v", err)
+	}
+	var dates []string
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan row: %v", err)
+		}
+		dates = append(dates, date)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %v", err)
+	}
+	if len(dates) == 0 {
+		return nil
+	}
+
+	totals := newRollups()
+	query := saleItemsQuery + `JOIN unnest($1::date[]) AS days(day) ON st.date_recorded >= days.day AND st.date_recorded < days.day + 1
+		ORDER BY st.date_recorded, st.id, p.category_id`
+	if _, err := aggregateSaleItems(tx, totals, query, pq.Array(dates)); err != nil {
+		return err
+	}
+	_, _, err = totals.replaceDates(tx, dates)
+	return err
+}
+
+// aggregateSaleItems runs a sale items query and aggregates the results,
+// skipping rows that were soft-deleted as corrections so they aren't
+// recreated. Unless nil, the items are also totalled into totals.
+func aggregateSaleItems(db DBTX, totals *rollups, query string, args ...any) ([]SalesTotal, error) {
+	deleted, err := querySoftDeletedKeys(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sales data: %v", err)
+	}
+	defer rows.Close()
+
+	records, _, err := aggregateRows(rows, deleted, totals)
+	return records, err
+}
+
+// aggregateRows aggregates the sale items rows of saleItemsQuery, skipping
+// the records keyed in deleted, and counts the items read. Unless nil, the
+// items not skipped are also totalled into rollups.
+func aggregateRows(rows *sql.Rows, deleted map[recordKey]bool, totals *rollups) ([]SalesTotal, itemCounts, error) {
+	// Aggregate totals by date, transaction, and category
+	aggregator := NewAggregator()
+
+	var counts itemCounts
+	for rows.Next() {
+		var item SaleItem
+		if err := rows.Scan(&item.DateRecorded, &item.SaleTransactionID, &item.CategoryID, &item.CustomerID, &item.CompanyID, &item.Currency, &item.Quantity, &item.TotalAmount, &item.Status, &item.ListPrice, &item.ProductID); err != nil {
+			return nil, counts, fmt.Errorf("failed to scan row: %v", err)
+		}
+		aggregator.Add(item)
+		key := recordKey{date: dateOnly(item.DateRecorded), saleTransactionID: item.SaleTransactionID, categoryID: item.CategoryID}
+		if totals != nil && !deleted[key] {
+			totals.add(item)
+		}
+		counts.read++
+		if strings.EqualFold(item.Status, "refund") {
+			counts.refunds++
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, counts, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	var records []SalesTotal
+	for _, record := range aggregator.Records() {
+		if deleted[record.key()] {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, counts, nil
+}
+
+// recordKey identifies a DW row, or the items aggregated into one, by date,
+// transaction, and category
+type recordKey struct {
+	date              string
+	saleTransactionID int
+	categoryID        int
+}
+
+func (r SalesTotal) key() recordKey {
+	return recordKey{
+		date:              dateOnly(r.DateRecorded),
+		saleTransactionID: r.SaleTransactionID,
+		categoryID:        r.CategoryID,
+	}
+}
+
+// querySoftDeletedKeys returns the keys of all soft-deleted DW rows
+func querySoftDeletedKeys(db DBTX) (map[recordKey]bool, error) {
+	rows, err := db.Query(`
+		SELECT date_recorded, sale_transaction_id, category_id
+		FROM sales_totals_by_category_dw
+		WHERE deleted_at IS NOT NULL
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query soft-deleted rows: %v", err)
+	}
+	defer rows.Close()
+
+	keys := make(map[recordKey]bool)
+	for rows.Next() {
+		var record SalesTotal
+		if err := rows.Scan(&record.DateRecorded, &record.SaleTransactionID, &record.CategoryID); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		keys[record.key()] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return keys, nil
+}
+
+// dateOnly trims a scanned date or timestamp to its YYYY-MM-DD part
+func dateOnly(date string) string {
+	if len(date) >= 10 {
+		return date[:10]
+	}
+	return date
+}
+
+// InsertSalesTotals inserts records into the sales_totals_by_category_dw table
+// in a single transaction
+func InsertSalesTotals(db *sql.DB, records []SalesTotal) error {
+	// Begin transaction for batch insert
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertSalesTotals(tx, records); err != nil {
+		return err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// salesTotalsStagingTable is the temporary table ReplaceSalesTotals loads
+// records into before swapping them in
+const salesTotalsStagingTable = "sales_totals_staging"
+
+// salesTotalsColumnNames are the DW columns the batch writes, and
+// salesTotalsColumns the same as a column list
+var (
+	salesTotalsColumnNames = []string{"date_recorded", "sale_transaction_id", "category_id", "customer_id", "company_id", "currency", "status", "total_amount", "gross_amount", "discount_amount", "refund_amount", "quantity"}
+	salesTotalsColumns     = strings.Join(salesTotalsColumnNames, ", ")
+)
+
+// ReplaceSalesTotals replaces the live rows of the sales_totals_by_category_dw
+// table in options' range and category with the records load passes to its
+// sink, in a single transaction that load can also write to, and returns
+// load's result with the rows replaced. Soft-deleted rows are kept so corrections survive regeneration.
+// Records are loaded into a staging table first, so the live rows are only
+// locked, against soft deletes and restores, for the swap rather than the
+// whole load.
+func ReplaceSalesTotals(db *sql.DB, options RegenerateOptions, load func(tx *sql.Tx, sink func([]SalesTotal) error) (RegenerateResult, error)) (RegenerateResult, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return RegenerateResult{}, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	staging := fmt.Sprintf("CREATE TEMPORARY TABLE %s (LIKE sales_totals_by_category_dw INCLUDING DEFAULTS) ON COMMIT DROP", salesTotalsStagingTable)
+	if _, err := tx.Exec(staging); err != nil {
+		return RegenerateResult{}, fmt.Errorf("failed to create staging table: %v", err)
+	}
+	result, err := load(tx, func(records []SalesTotal) error {
+		return insertSalesTotalsInto(tx, salesTotalsStagingTable, records)
+	})
+	if err != nil {
+		return RegenerateResult{}, err
+	}
+
+	started := time.Now()
+
+	where, args := options.salesTotalsWhere()
+	deleted, err := tx.Exec("DELETE FROM sales_totals_by_category_dw WHERE deleted_at IS NULL AND "+where, args...)
+	if err != nil {
+		return RegenerateResult{}, fmt.Errorf("failed to clear existing data: %v", err)
+	}
+	replaced, err := deleted.RowsAffected()
+	if err != nil {
+		return RegenerateResult{}, err
+	}
+	swap := fmt.Sprintf("INSERT INTO sales_totals_by_category_dw (%[1]s) SELECT %[1]s FROM %[2]s", salesTotalsColumns, salesTotalsStagingTable)
+	if _, err := tx.Exec(swap); err != nil {
+		return RegenerateResult{}, fmt.Errorf("failed to swap in sales totals: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return RegenerateResult{}, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	result.Replaced = int(replaced)
+	result.Stages[StageSwap] = time.Since(started)
+	return result, nil
+}
+
+// insertSalesTotals inserts records into the DW table using the given
+// transaction
+func insertSalesTotals(tx *sql.Tx, records []SalesTotal) error {
+	return insertSalesTotalsInto(tx, "sales_totals_by_category_dw", records)
+}
+
+// insertSalesTotalsInto inserts records into table, the DW table or one
+// shaped like it, using the given transaction. Records are streamed with
+// COPY in batches of insertBatchSize.
+func insertSalesTotalsInto(tx *sql.Tx, table string, records []SalesTotal) error {
+	batchSize, err := insertBatchSize()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < len(records); i += batchSize {
+		end := min(i+batchSize, len(records))
+		rows := make([][]any, 0, end-i)
+		for _, record := range records[i:end] {
+			rows = append(rows, []any{
+				record.DateRecorded,
+				record.SaleTransactionID,
+				record.CategoryID,
+				record.CustomerID,
+				record.CompanyID,
+				record.Currency,
+				record.Status,
+				record.TotalAmount,
+				record.GrossAmount,
+				record.DiscountAmount,
+				record.RefundAmount,
+				record.Quantity,
+			})
+		}
+		if err := copyIn(tx, table, salesTotalsColumnNames, rows); err != nil {
+			return err
+		}
+		log.Printf("Inserted batch %d-%d of %d records", i+1, end, len(records))
+	}
+
+	return nil
+}
+
+// insertBatchSize returns the number of rows written per COPY statement,
+// SALES_TOTALS_INSERT_BATCH_SIZE (default 5000)
+func insertBatchSize() (int, error) {
+	if value := os.Getenv("SALES_TOTALS_INSERT_BATCH_SIZE"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return 0, fmt.Errorf("invalid SALES_TOTALS_INSERT_BATCH_SIZE %q", value)
+		}
+		return parsed, nil
+	}
+	return 5000, nil
+}
+
+// copyIn copies rows of values for columns into table in one COPY statement
+func copyIn(tx *sql.Tx, table string, columns []string, rows [][]any) error {
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy into %s: %v", table, err)
+	}
+	defer stmt.Close()
+
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			return fmt.Errorf("failed to copy record: %v", err)
+		}
+	}
+
+	// Flush the buffered rows
+	if _, err := stmt.Exec(); err != nil {
+		return fmt.Errorf("failed to copy records: %v", err)
+	}
+	return nil
+}
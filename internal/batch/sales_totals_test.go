@@ -0,0 +1,38 @@
+package batch_test
+
+import (
+	"testing"
+
+	"github.com/bokor/craft-demo/internal/batch"
+	"github.com/bokor/craft-demo/internal/benchdata"
+)
+
+func BenchmarkAggregator(b *testing.B) {
+	items := benchdata.SaleItems(benchdata.Size(b))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		aggregator := batch.NewAggregator()
+		for _, item := range items {
+			aggregator.Add(item)
+		}
+		aggregator.Records()
+	}
+}
+
+// BenchmarkReplaceSalesTotals loads generated records through the staging
+// table and swaps them in, as a regeneration of the whole table does
+func BenchmarkReplaceSalesTotals(b *testing.B) {
+	db := benchdata.Open(b)
+	records := benchdata.SalesTotals(benchdata.Size(b))
+	benchdata.Quiet(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := batch.ReplaceSalesTotals(db, batch.RegenerateOptions{}, benchdata.Sink(records)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
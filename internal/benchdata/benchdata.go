@@ -0,0 +1,148 @@
+// Package benchdata generates the datasets the batch and report benchmarks
+// run on and opens the scratch schema they write to.
+package benchdata
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/batch"
+	"github.com/bokor/craft-demo/internal/database"
+)
+
+// Shape of the generated data
+const (
+	// Categories is the number of categories items are spread across
+	Categories = 16
+	// ItemsPerTransaction is the average number of items in a transaction
+	ItemsPerTransaction = 30
+)
+
+// Start is the first day of the generated data
+var Start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Size returns the number of sale items to generate and the days they span,
+// BENCH_ITEMS (default 100000) and BENCH_DAYS (default 365)
+func Size(tb testing.TB) (int, int) {
+	items, days := 100000, 365
+	for _, setting := range []struct {
+		env   string
+		value *int
+	}{{"BENCH_ITEMS", &items}, {"BENCH_DAYS", &days}} {
+		if value := os.Getenv(setting.env); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 {
+				tb.Fatalf("invalid %s %q", setting.env, value)
+			}
+			*setting.value = parsed
+		}
+	}
+	return items, days
+}
+
+// End is the last day of data generated over days
+func End(days int) time.Time {
+	return Start.AddDate(0, 0, days-1)
+}
+
+// SaleItems generates items sale items spread over days from Start. The
+// same size always generates the same items.
+func SaleItems(items, days int) []batch.SaleItem {
+	rng := rand.New(rand.NewSource(1))
+	transactions := max(items/ItemsPerTransaction, 1)
+
+	dataset := make([]batch.SaleItem, 0, items)
+	for i := 0; i < items; i++ {
+		status := "invoice"
+		if rng.Intn(20) == 0 {
+			status = "refund"
+		}
+		dataset = append(dataset, batch.SaleItem{
+			DateRecorded:      Start.AddDate(0, 0, rng.Intn(days)).Format("2006-01-02"),
+			SaleTransactionID: rng.Intn(transactions) + 1,
+			CategoryID:        rng.Intn(Categories) + 1,
+			ProductID:         rng.Intn(Categories*10) + 1,
+			CustomerID:        sql.NullInt64{Int64: int64(rng.Intn(3) + 1), Valid: true},
+			CompanyID:         sql.NullInt64{Int64: int64(rng.Intn(3) + 1), Valid: true},
+			Currency:          "USD",
+			Quantity:          rng.Intn(5) + 1,
+			TotalAmount:       float64(rng.Intn(100000)) / 100,
+			Status:            status,
+			ListPrice:         float64(rng.Intn(20000)) / 100,
+		})
+	}
+	return dataset
+}
+
+// SalesTotals aggregates the generated sale items into DW records
+func SalesTotals(items, days int) []batch.SalesTotal {
+	aggregator := batch.NewAggregator()
+	for _, item := range SaleItems(items, days) {
+		aggregator.Add(item)
+	}
+	return aggregator.Records()
+}
+
+// Open connects to the benchmark schema BENCH_SCHEMA, migrated and holding
+// only the generated categories, and closes it when tb ends. tb is skipped
+// when BENCH_SCHEMA isn't set, and the live public schema is refused.
+func Open(tb testing.TB) *sql.DB {
+	schema := os.Getenv("BENCH_SCHEMA")
+	switch schema {
+	case "":
+		tb.Skip("set BENCH_SCHEMA to run the database benchmarks")
+	case "public":
+		tb.Fatal("BENCH_SCHEMA must not be the public schema")
+	}
+
+	db, err := database.GetSchemaConnection(schema)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { db.Close() })
+
+	if err := database.MigrateUp(context.Background(), db, 0); err != nil {
+		tb.Fatalf("failed to migrate %s: %v", schema, err)
+	}
+	if _, err := db.Exec("TRUNCATE TABLE sales_totals_by_category_dw, categories RESTART IDENTITY CASCADE"); err != nil {
+		tb.Fatalf("failed to empty %s: %v", schema, err)
+	}
+	for i := 1; i <= Categories; i++ {
+		if _, err := db.Exec("INSERT INTO categories (name) VALUES ($1)", fmt.Sprintf("Category %d", i)); err != nil {
+			tb.Fatalf("failed to insert categories: %v", err)
+		}
+	}
+	return db
+}
+
+// Load replaces the DW rows in db with records
+func Load(tb testing.TB, db *sql.DB, records []batch.SalesTotal) {
+	if _, err := batch.ReplaceSalesTotals(db, batch.RegenerateOptions{}, Sink(records)); err != nil {
+		tb.Fatalf("failed to load sales totals: %v", err)
+	}
+}
+
+// Sink returns a ReplaceSalesTotals load function passing records to its
+// sink
+func Sink(records []batch.SalesTotal) func(*sql.Tx, func([]batch.SalesTotal) error) (batch.RegenerateResult, error) {
+	return func(_ *sql.Tx, sink func([]batch.SalesTotal) error) (batch.RegenerateResult, error) {
+		result := batch.RegenerateResult{Written: len(records), Stages: make(map[string]time.Duration)}
+		return result, sink(records)
+	}
+}
+
+// Quiet discards the log output until tb ends, so per-batch logging doesn't
+// dominate the timings
+func Quiet(tb testing.TB) {
+	previous := log.Writer()
+	log.SetOutput(io.Discard)
+	tb.Cleanup(func() { log.SetOutput(previous) })
+}
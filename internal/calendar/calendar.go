@@ -0,0 +1,193 @@
+// Package calendar labels dates with ISO-8601 weeks and fiscal periods
+package calendar
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bokor/craft-demo/config"
+	"gopkg.in/yaml.v3"
+)
+
+// ISOWeekKey returns the ISO-8601 week of t, e.g. 2024-W37
+func ISOWeekKey(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", year, week)
+}
+
+// ParseISOWeek returns the Monday starting an ISO-8601 week key
+func ParseISOWeek(key string) (time.Time, error) {
+	var year, week int
+	if _, err := fmt.Sscanf(key, "%4d-W%2d", &year, &week); err != nil || len(key) != len("2006-W01") {
+		return time.Time{}, fmt.Errorf("invalid ISO week %q, use YYYY-Www", key)
+	}
+
+	// January 4th is always in week 1
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monday := jan4.AddDate(0, 0, -daysSinceMonday(jan4)+7*(week-1))
+	if y, w := monday.ISOWeek(); y != year || w != week {
+		return time.Time{}, fmt.Errorf("invalid ISO week %q: %d has no week %d", key, year, week)
+	}
+	return monday, nil
+}
+
+func daysSinceMonday(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// periodsPerYear is the number of fiscal periods in a fiscal year
+const periodsPerYear = 12
+
+// Fiscal is a fiscal calendar of twelve periods per year. Fiscal years are
+// named after the calendar year they end in. Without a pattern the periods
+// are calendar months from StartMonth; with a pattern such as 4-4-5 each
+// quarter is that many weeks, years start on the Monday nearest the 1st of
+// StartMonth, and a 53rd week joins the last period.
+type Fiscal struct {
+	StartMonth time.Month `yaml:"start_month"`
+	// Pattern is the weeks in each period of a quarter, summing to 13
+	Pattern []int `yaml:"pattern"`
+}
+
+// Validate checks the start month and pattern
+func (f Fiscal) Validate() error {
+	if f.StartMonth < time.January || f.StartMonth > time.December {
+		return fmt.Errorf("fiscal start_month must be between 1 and 12")
+	}
+	if len(f.Pattern) == 0 {
+		return nil
+	}
+	weeks := 0
+	for _, w := range f.Pattern {
+		if w <= 0 {
+			return fmt.Errorf("fiscal pattern weeks must be positive")
+		}
+		weeks += w
+	}
+	if len(f.Pattern) != 3 || weeks != 13 {
+		return fmt.Errorf("fiscal pattern must be three periods of 13 weeks in total, e.g. [4, 4, 5]")
+	}
+	return nil
+}
+
+// yearStart returns the first day of fiscal year fy
+func (f Fiscal) yearStart(fy int) time.Time {
+	year := fy
+	if f.StartMonth > time.January {
+		year--
+	}
+	start := time.Date(year, f.StartMonth, 1, 0, 0, 0, 0, time.UTC)
+	if len(f.Pattern) == 0 {
+		return start
+	}
+
+	// Snap to the nearest Monday so periods are whole weeks
+	offset := daysSinceMonday(start)
+	if offset > 3 {
+		return start.AddDate(0, 0, 7-offset)
+	}
+	return start.AddDate(0, 0, -offset)
+}
+
+// periodStarts returns the first day of each period of fiscal year fy
+func (f Fiscal) periodStarts(fy int) []time.Time {
+	start := f.yearStart(fy)
+	starts := make([]time.Time, 0, periodsPerYear)
+	for i := 0; i < periodsPerYear; i++ {
+		if len(f.Pattern) == 0 {
+			starts = append(starts, start.AddDate(0, i, 0))
+			continue
+		}
+		starts = append(starts, start)
+		start = start.AddDate(0, 0, 7*f.Pattern[i%len(f.Pattern)])
+	}
+	return starts
+}
+
+// Period returns the fiscal year and 1-based period containing t, and the
+// first day of the period
+func (f Fiscal) Period(t time.Time) (int, int, time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	fy := day.Year() + 1
+	for day.Before(f.yearStart(fy)) {
+		fy--
+	}
+
+	starts := f.periodStarts(fy)
+	period := len(starts)
+	for period > 1 && day.Before(starts[period-1]) {
+		period--
+	}
+	return fy, period, starts[period-1]
+}
+
+// Key returns the fiscal period containing t, e.g. FY2025-P01
+func (f Fiscal) Key(t time.Time) string {
+	fy, period, _ := f.Period(t)
+	return fmt.Sprintf("FY%04d-P%02d", fy, period)
+}
+
+// Parse returns the first day of a fiscal period key
+func (f Fiscal) Parse(key string) (time.Time, error) {
+	var fy, period int
+	if _, err := fmt.Sscanf(key, "FY%4d-P%2d", &fy, &period); err != nil || len(key) != len("FY2006-P01") ||
+		period < 1 || period > periodsPerYear {
+		return time.Time{}, fmt.Errorf("invalid fiscal period %q, use FYyyyy-Ppp", key)
+	}
+	return f.periodStarts(fy)[period-1], nil
+}
+
+// Step returns the first day of the fiscal period n periods after the one
+// containing t
+func (f Fiscal) Step(t time.Time, n int) time.Time {
+	fy, period, _ := f.Period(t)
+	index := fy*periodsPerYear + period - 1 + n
+	year, offset := index/periodsPerYear, index%periodsPerYear
+	if offset < 0 {
+		year, offset = year-1, offset+periodsPerYear
+	}
+	return f.periodStarts(year)[offset]
+}
+
+// ParseFiscal parses and validates a YAML fiscal calendar
+func ParseFiscal(contents []byte) (Fiscal, error) {
+	var fiscal Fiscal
+	if err := yaml.Unmarshal(contents, &fiscal); err != nil {
+		return Fiscal{}, fmt.Errorf("failed to parse fiscal calendar: %v", err)
+	}
+	if err := fiscal.Validate(); err != nil {
+		return Fiscal{}, err
+	}
+	return fiscal, nil
+}
+
+var (
+	defaultFiscalOnce sync.Once
+	defaultFiscal     Fiscal
+	defaultFiscalErr  error
+)
+
+// DefaultFiscal returns the fiscal calendar from FISCAL_CALENDAR_PATH, which
+// defaults to config/fiscal_calendar.yaml. It is loaded once; if it is
+// invalid the error is returned with a calendar of months from January.
+func DefaultFiscal() (Fiscal, error) {
+	defaultFiscalOnce.Do(func() {
+		name := os.Getenv("FISCAL_CALENDAR_PATH")
+		if name == "" {
+			name = "config/fiscal_calendar.yaml"
+		}
+
+		contents, err := config.Read(name)
+		if err == nil {
+			defaultFiscal, err = ParseFiscal(contents)
+		}
+		if err != nil {
+			log.Printf("Warning: invalid fiscal calendar %s, using calendar months: %v", name, err)
+			defaultFiscal, defaultFiscalErr = Fiscal{StartMonth: time.January}, err
+		}
+	})
+	return defaultFiscal, defaultFiscalErr
+}
@@ -0,0 +1,105 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Targets that faults can be injected into
+const (
+	TargetDB  = "db"
+	TargetLLM = "llm"
+)
+
+// ErrInjected is returned by Inject when a failure was injected
+var ErrInjected = errors.New("chaos: injected failure")
+
+// Fault describes the latency and errors injected into a dependency
+type Fault struct {
+	// LatencyMs is the delay added to a call when latency is injected
+	LatencyMs int `json:"latencyMs"`
+	// LatencyRate is the fraction (0-1) of calls that get the added latency
+	LatencyRate float64 `json:"latencyRate"`
+	// ErrorRate is the fraction (0-1) of calls that fail with ErrInjected
+	ErrorRate float64 `json:"errorRate"`
+}
+
+var (
+	mu     sync.Mutex
+	faults = map[string]Fault{}
+	rng    = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// Enabled reports whether chaos mode is turned on with CHAOS_ENABLED=true.
+// Faults are never injected unless it is.
+func Enabled() bool {
+	return os.Getenv("CHAOS_ENABLED") == "true"
+}
+
+// Set configures the fault injected into the given target
+func Set(target string, fault Fault) error {
+	if target != TargetDB && target != TargetLLM {
+		return fmt.Errorf("unknown chaos target: %s", target)
+	}
+	if fault.ErrorRate < 0 || fault.ErrorRate > 1 || fault.LatencyRate < 0 || fault.LatencyRate > 1 {
+		return fmt.Errorf("rates must be between 0 and 1")
+	}
+	if fault.LatencyMs < 0 {
+		return fmt.Errorf("latencyMs must not be negative")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	faults[target] = fault
+	return nil
+}
+
+// Faults returns a copy of the configured faults keyed by target
+func Faults() map[string]Fault {
+	mu.Lock()
+	defer mu.Unlock()
+
+	result := make(map[string]Fault, len(faults))
+	for target, fault := range faults {
+		result[target] = fault
+	}
+	return result
+}
+
+// Reset removes all configured faults
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	faults = map[string]Fault{}
+}
+
+// Inject applies the fault configured for target, sleeping and/or returning
+// ErrInjected according to its rates. It is a no-op unless chaos mode is enabled.
+func Inject(target string) error {
+	if !Enabled() {
+		return nil
+	}
+
+	mu.Lock()
+	fault, ok := faults[target]
+	addLatency := ok && rng.Float64() < fault.LatencyRate
+	fail := ok && rng.Float64() < fault.ErrorRate
+	mu.Unlock()
+
+	if addLatency && fault.LatencyMs > 0 {
+		log.Printf("Chaos: injecting %dms latency into %s", fault.LatencyMs, target)
+		time.Sleep(time.Duration(fault.LatencyMs) * time.Millisecond)
+	}
+
+	if fail {
+		log.Printf("Chaos: injecting failure into %s", target)
+		return fmt.Errorf("%s: %w", target, ErrInjected)
+	}
+
+	return nil
+}
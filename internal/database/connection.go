@@ -4,14 +4,39 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
 
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // GetDBConnection returns a database connection using environment variables
 func GetDBConnection() (*sql.DB, error) {
+	return sql.Open("postgres", connectionURL())
+}
+
+// GetSchemaConnection returns a database connection like GetDBConnection
+// whose unqualified table names resolve to schema, creating the schema if it
+// doesn't exist. Benchmarks use it to keep their rows out of the live tables.
+func GetSchemaConnection(schema string) (*sql.DB, error) {
+	db, err := GetDBConnection()
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.Exec("CREATE SCHEMA IF NOT EXISTS " + pq.QuoteIdentifier(schema))
+	db.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema %s: %v", schema, err)
+	}
+
+	// lib/pq passes settings it doesn't know to the server
+	return sql.Open("postgres", connectionURL()+"&search_path="+url.QueryEscape(schema))
+}
+
+// connectionURL returns the Postgres URL of the database the environment
+// variables name
+func connectionURL() string {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: .env file not found, using system environment variables")
@@ -23,7 +48,5 @@ func GetDBConnection() (*sql.DB, error) {
 	dbPassword := os.Getenv("DB_PASSWORD")
 	dbName := os.Getenv("DB_NAME")
 
-	psqlconn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", dbUser, dbPassword, dbHost, dbPort, dbName)
-
-	return sql.Open("postgres", psqlconn)
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", dbUser, dbPassword, dbHost, dbPort, dbName)
 }
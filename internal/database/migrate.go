@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bokor/craft-demo/db/migrations"
+	"github.com/lib/pq"
+)
+
+// migrateLockKey is the Postgres advisory lock key held while migrating, so
+// replicas and deploy jobs starting together migrate one at a time
+const migrateLockKey int64 = 0x3a16a7e5
+
+// MigrationState is an embedded migration and when it was applied
+type MigrationState struct {
+	Version int64
+	File    string
+	// AppliedAt is nil for pending migrations
+	AppliedAt *time.Time
+}
+
+// MigrationStatus returns every embedded migration in version order with
+// when it was applied
+func MigrationStatus(ctx context.Context, db *sql.DB) ([]MigrationState, error) {
+	all, err := migrations.All()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %v", err)
+	}
+
+	var states []MigrationState
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range all {
+			state := MigrationState{Version: migration.Version, File: migration.File}
+			if at, ok := applied[migration.Version]; ok {
+				state.AppliedAt = &at
+			}
+			states = append(states, state)
+		}
+		return nil
+	})
+	return states, err
+}
+
+// MigrateUp applies the pending migrations up to version to, or all of them
+// when to is 0, oldest first. Each runs in its own transaction with its row
+// in the Goose version table, so a failed migration leaves nothing behind.
+// Pending migrations older than applied ones are applied too; Goose refuses
+// to, so databases migrated by this command stay ahead of goose up.
+func MigrateUp(ctx context.Context, db *sql.DB, to int64) error {
+	all, err := migrations.All()
+	if err != nil {
+		return fmt.Errorf("failed to read embedded migrations: %v", err)
+	}
+
+	return withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range all {
+			if to > 0 && migration.Version > to {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, migration.Version, migration.Up, true); err != nil {
+				return fmt.Errorf("failed to apply %s: %v", migration.File, err)
+			}
+			log.Printf("Applied migration %s", migration.File)
+		}
+		return nil
+	})
+}
+
+// MigrateDown rolls back the applied migrations newer than version to,
+// newest first, each in its own transaction
+func MigrateDown(ctx context.Context, db *sql.DB, to int64) error {
+	all, err := migrations.All()
+	if err != nil {
+		return fmt.Errorf("failed to read embedded migrations: %v", err)
+	}
+
+	return withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(all) - 1; i >= 0 && all[i].Version > to; i-- {
+			migration := all[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, migration.Version, migration.Down, false); err != nil {
+				return fmt.Errorf("failed to roll back %s: %v", migration.File, err)
+			}
+			log.Printf("Rolled back migration %s", migration.File)
+		}
+		return nil
+	})
+}
+
+// withMigrationLock runs fn on a dedicated connection holding the migration
+// lock, creating the Goose version table first if it doesn't exist
+func withMigrationLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrateLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %v", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrateLockKey); err != nil {
+			log.Printf("Failed to release migration lock: %v", err)
+		}
+	}()
+
+	if err := createMigrationTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// createMigrationTable creates the Goose version table with its version 0
+// row, as goose does on its first run
+func createMigrationTable(ctx context.Context, conn *sql.Conn) error {
+	table := pq.QuoteIdentifier(migrationTable())
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id SERIAL PRIMARY KEY,
+			version_id BIGINT NOT NULL,
+			is_applied BOOLEAN NOT NULL,
+			tstamp TIMESTAMP DEFAULT NOW()
+		)
+	`, table)); err != nil {
+		return fmt.Errorf("failed to create %s: %v", migrationTable(), err)
+	}
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %[1]s (version_id, is_applied)
+		SELECT 0, TRUE WHERE NOT EXISTS (SELECT 1 FROM %[1]s)
+	`, table)); err != nil {
+		return fmt.Errorf("failed to initialize %s: %v", migrationTable(), err)
+	}
+	return nil
+}
+
+// appliedMigrations returns when each applied migration was applied. The
+// newest row of a version decides, as older goose versions recorded
+// rollbacks as rows that aren't applied.
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf(`
+		SELECT DISTINCT ON (version_id) version_id, is_applied, COALESCE(tstamp, NOW())
+		FROM %s
+		WHERE version_id > 0
+		ORDER BY version_id, id DESC
+	`, pq.QuoteIdentifier(migrationTable())))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+			at        time.Time
+		)
+		if err := rows.Scan(&version, &isApplied, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if isApplied {
+			applied[version] = at
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return applied, nil
+}
+
+// runMigration runs one section of a migration and records it in the Goose
+// version table in a single transaction
+func runMigration(ctx context.Context, conn *sql.Conn, version int64, script string, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if script != "" {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return err
+		}
+	}
+
+	table := pq.QuoteIdentifier(migrationTable())
+	if up {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (version_id, is_applied) VALUES ($1, TRUE)", table), version)
+	} else {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE version_id = $1", table), version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration version: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
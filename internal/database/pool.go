@@ -0,0 +1,75 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// PoolConfig sizes the connection pool shared by the server's handlers and
+// jobs
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// PoolConfigFromEnv reads the pool settings from DB_MAX_OPEN_CONNS (default
+// 25), DB_MAX_IDLE_CONNS (default 10), DB_CONN_MAX_LIFETIME (default 30m),
+// and DB_CONN_MAX_IDLE_TIME (default 5m). Zero lifetimes keep connections
+// forever.
+func PoolConfigFromEnv() (PoolConfig, error) {
+	config := PoolConfig{
+		MaxOpenConns:    25,
+		MaxIdleConns:    10,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+	}
+
+	for _, setting := range []struct {
+		env   string
+		value *int
+	}{{"DB_MAX_OPEN_CONNS", &config.MaxOpenConns}, {"DB_MAX_IDLE_CONNS", &config.MaxIdleConns}} {
+		if value := os.Getenv(setting.env); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 {
+				return PoolConfig{}, fmt.Errorf("invalid %s: %q", setting.env, value)
+			}
+			*setting.value = parsed
+		}
+	}
+	if config.MaxIdleConns > config.MaxOpenConns {
+		return PoolConfig{}, fmt.Errorf("DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", config.MaxIdleConns, config.MaxOpenConns)
+	}
+
+	for _, setting := range []struct {
+		env   string
+		value *time.Duration
+	}{{"DB_CONN_MAX_LIFETIME", &config.ConnMaxLifetime}, {"DB_CONN_MAX_IDLE_TIME", &config.ConnMaxIdleTime}} {
+		if value := os.Getenv(setting.env); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed < 0 {
+				return PoolConfig{}, fmt.Errorf("invalid %s: %q", setting.env, value)
+			}
+			*setting.value = parsed
+		}
+	}
+	return config, nil
+}
+
+// OpenPool returns a connection pool to the database from the environment,
+// sized by config. Connections are opened as they are needed.
+func OpenPool(config PoolConfig) (*sql.DB, error) {
+	db, err := GetDBConnection()
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	return db, nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bokor/craft-demo/db/migrations"
+	"github.com/lib/pq"
+)
+
+// RequiredColumns lists the tables and columns the services query
+var RequiredColumns = map[string][]string{
+	"categories":                  {"id", "name", "parent_id", "status"},
+	"products":                    {"id", "name", "description", "price", "category_id", "company_id", "sku", "quantity", "status"},
+	"sale_transactions":           {"id", "date_recorded", "customer_id", "company_id", "status", "currency"},
+	"sale_transaction_items":      {"sale_transaction_id", "product_id", "quantity", "total_amount"},
+	"sales_totals_by_category_dw": {"date_recorded", "sale_transaction_id", "category_id", "customer_id", "company_id", "currency", "status", "total_amount", "gross_amount", "discount_amount", "refund_amount", "quantity", "deleted_at"},
+	"sales_totals_by_product_dw":  {"date_recorded", "product_id", "category_id", "currency", "status", "transactions", "quantity", "total_amount", "gross_amount", "discount_amount", "refund_amount"},
+	"sales_totals_by_hour_dw":     {"hour", "category_id", "currency", "status", "transactions", "quantity", "total_amount", "gross_amount", "discount_amount", "refund_amount"},
+	"scheduled_job_runs":          {"job_name", "last_run_at", "run_by"},
+	"export_watermarks":           {"sink", "exported_through", "exported_at"},
+	"forecasts":                   {"id", "category_id", "time_period", "source", "method", "created_at", "scheduled", "superseded_at"},
+	"forecast_points":             {"forecast_id", "period_start", "period_end", "predicted", "lower_80", "upper_80", "lower_95", "upper_95", "actual", "matched_at"},
+	"forecast_refresh_queue":      {"category_id", "time_period", "reason", "enqueued_at", "attempts", "last_error", "next_attempt_at"},
+	"alert_firings":               {"rule_name", "fired_for", "fired_at"},
+	"revenue_targets":             {"id", "month", "category_id", "target", "updated_at"},
+	"saved_reports":               {"id", "name", "report", "params", "last_days", "created_at"},
+	"saved_report_subscriptions":  {"id", "saved_report_id", "email", "frequency", "last_sent_at", "created_at"},
+	"integration_links":           {"source", "kind", "external_id", "record_id", "synced_at"},
+	"integration_cursors":         {"source", "sync_cursor", "synced_at"},
+	"integration_credentials":     {"source", "account_id", "access_token", "refresh_token", "expires_at", "updated_at"},
+	"integration_oauth_states":    {"state", "source", "created_at"},
+	"data_quality_issues":         {"id", "check_name", "sale_transaction_id", "sale_transaction_item_id", "product_id", "category_id", "date_recorded", "detail", "detected_at"},
+	"batch_runs":                  {"id", "job", "triggered_by", "status", "start_date", "end_date", "category_id", "started_at", "finished_at", "rows_replaced", "rows_written", "error"},
+	"customer_segments":           {"customer_id", "recency_days", "frequency", "monetary", "recency_score", "frequency_score", "monetary_score", "segment", "computed_at"},
+}
+
+// SchemaReport is the result of a schema compatibility check
+type SchemaReport struct {
+	ExpectedVersion int64    `json:"expected_version"`
+	AppliedVersion  int64    `json:"applied_version"`
+	Problems        []string `json:"problems,omitempty"`
+}
+
+// OK reports whether the schema is compatible
+func (r SchemaReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// CheckSchema verifies that the newest embedded migration has been applied
+// and that every required table and column exists. Problems are collected
+// rather than returned one at a time so they can all be fixed at once.
+func CheckSchema(db *sql.DB) SchemaReport {
+	var report SchemaReport
+
+	expected, err := migrations.LatestVersion()
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("failed to read embedded migrations: %v", err))
+	}
+	report.ExpectedVersion = expected
+
+	if err := db.Ping(); err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("database unreachable: %v", err))
+		return report
+	}
+
+	table := migrationTable()
+	err = db.QueryRow(fmt.Sprintf(
+		"SELECT COALESCE(MAX(version_id), 0) FROM %s WHERE is_applied", pq.QuoteIdentifier(table),
+	)).Scan(&report.AppliedVersion)
+	if err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("failed to read migration version from %s: %v", table, err))
+	} else if report.AppliedVersion < expected {
+		report.Problems = append(report.Problems, fmt.Sprintf(
+			"database is at migration %d but %d is expected; run make migrate-db", report.AppliedVersion, expected,
+		))
+	}
+
+	missing, err := missingColumns(db)
+	if err != nil {
+		report.Problems = append(report.Problems, err.Error())
+	}
+	report.Problems = append(report.Problems, missing...)
+
+	return report
+}
+
+// migrationTable returns the Goose version table, set with GOOSE_TABLE
+func migrationTable() string {
+	if table := os.Getenv("GOOSE_TABLE"); table != "" {
+		return table
+	}
+	return "goose_db_version"
+}
+
+// missingColumns describes every required table or column that doesn't exist
+func missingColumns(db *sql.DB) ([]string, error) {
+	tables := make([]string, 0, len(RequiredColumns))
+	for table := range RequiredColumns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	rows, err := db.Query(`
+		SELECT table_name, column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ANY($1)
+	`, pq.Array(tables))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema columns: %v", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if existing[table] == nil {
+			existing[table] = make(map[string]bool)
+		}
+		existing[table][column] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	var problems []string
+	for _, table := range tables {
+		if existing[table] == nil {
+			problems = append(problems, fmt.Sprintf("missing table %s", table))
+			continue
+		}
+		var columns []string
+		for _, column := range RequiredColumns[table] {
+			if !existing[table][column] {
+				columns = append(columns, column)
+			}
+		}
+		if len(columns) > 0 {
+			problems = append(problems, fmt.Sprintf("table %s is missing columns: %s", table, strings.Join(columns, ", ")))
+		}
+	}
+
+	return problems, nil
+}
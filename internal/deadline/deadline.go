@@ -0,0 +1,87 @@
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stages of a request pipeline that can be given their own timeout
+const (
+	StagePrompt = "prompt"
+	StageLLM    = "llm"
+	StageParse  = "parse"
+	StageDB     = "db"
+)
+
+// Timeouts holds the total deadline for a request and the timeout of each stage
+type Timeouts struct {
+	Total  time.Duration
+	Stages map[string]time.Duration
+}
+
+// DefaultTimeouts are used for any timeout not configured in the environment
+var DefaultTimeouts = Timeouts{
+	Total: 45 * time.Second,
+	Stages: map[string]time.Duration{
+		StagePrompt: 2 * time.Second,
+		StageLLM:    30 * time.Second,
+		StageParse:  2 * time.Second,
+		StageDB:     10 * time.Second,
+	},
+}
+
+// FromEnv reads timeouts in milliseconds from TIMEOUT_TOTAL_MS and
+// TIMEOUT_<STAGE>_MS (e.g. TIMEOUT_LLM_MS), falling back to the defaults.
+// Invalid values are logged and ignored.
+func FromEnv() Timeouts {
+	timeouts := Timeouts{
+		Total:  envDuration("TIMEOUT_TOTAL_MS", DefaultTimeouts.Total),
+		Stages: make(map[string]time.Duration),
+	}
+	for stage, fallback := range DefaultTimeouts.Stages {
+		timeouts.Stages[stage] = envDuration("TIMEOUT_"+strings.ToUpper(stage)+"_MS", fallback)
+	}
+	return timeouts
+}
+
+func envDuration(env string, fallback time.Duration) time.Duration {
+	value := os.Getenv(env)
+	if value == "" {
+		return fallback
+	}
+	ms, err := strconv.Atoi(value)
+	if err != nil || ms <= 0 {
+		log.Printf("Warning: invalid %s %q, using %v", env, value, fallback)
+		return fallback
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// WithTotal returns a context bounded by the total deadline
+func (t Timeouts) WithTotal(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, t.Total)
+}
+
+// Run runs a stage with its own timeout inside ctx and waits for it to
+// return, so a stage never outlives Run and may write to its caller's
+// variables. Stages should watch ctx to stop when it expires. A stage that
+// returns after its timeout or the total deadline returns an error wrapping
+// context.DeadlineExceeded, and its result is discarded.
+func (t Timeouts) Run(ctx context.Context, stage string, fn func(ctx context.Context) error) error {
+	if timeout, ok := t.Stages[stage]; ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := fn(ctx)
+	if ctx.Err() != nil {
+		return fmt.Errorf("%s stage: %w", stage, ctx.Err())
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package errortracker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Event describes an error reported to the tracker
+type Event struct {
+	Message   string            `json:"message"`
+	Stack     string            `json:"stack,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// Reporter sends error events to an error tracker
+type Reporter interface {
+	Report(event Event)
+}
+
+// FromEnv returns a Reporter that posts events as JSON to ERROR_TRACKER_URL,
+// or one that only logs them when it isn't set
+func FromEnv() Reporter {
+	if url := os.Getenv("ERROR_TRACKER_URL"); url != "" {
+		return &webhookReporter{url: url, client: &http.Client{Timeout: 5 * time.Second}}
+	}
+	return logReporter{}
+}
+
+type logReporter struct{}
+
+func (logReporter) Report(event Event) {
+	log.Printf("Error [request %s]: %s\n%s", event.RequestID, event.Message, event.Stack)
+}
+
+type webhookReporter struct {
+	url    string
+	client *http.Client
+}
+
+// Report logs the event and posts it in the background so reporting never
+// slows down or fails the request
+func (r *webhookReporter) Report(event Event) {
+	logReporter{}.Report(event)
+
+	go func() {
+		if err := r.post(event); err != nil {
+			log.Printf("Failed to report error to tracker: %v", err)
+		}
+	}()
+}
+
+func (r *webhookReporter) post(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Post(r.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error tracker returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
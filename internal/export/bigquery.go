@@ -0,0 +1,323 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"cloud.google.com/go/bigquery/storage/managedwriter/adapt"
+	"cloud.google.com/go/civil"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// BigQuerySink is the export_watermarks key of the BigQuery export
+const BigQuerySink = "bigquery"
+
+// bigQueryAppendBatch is the number of rows per storage write API append
+const bigQueryAppendBatch = 500
+
+// bigQuerySchema is the schema of the exported table. Columns may be added
+// to the end; existing tables gain them on the next run.
+var bigQuerySchema = bigquery.Schema{
+	{Name: "date_recorded", Type: bigquery.DateFieldType, Required: true},
+	{Name: "sale_transaction_id", Type: bigquery.IntegerFieldType, Required: true},
+	{Name: "category_id", Type: bigquery.IntegerFieldType, Required: true},
+	{Name: "customer_id", Type: bigquery.IntegerFieldType},
+	{Name: "total_amount", Type: bigquery.FloatFieldType, Required: true},
+	{Name: "deleted_at", Type: bigquery.TimestampFieldType},
+	{Name: "exported_at", Type: bigquery.TimestampFieldType, Required: true},
+}
+
+// BigQueryConfig is the destination of the BigQuery export
+type BigQueryConfig struct {
+	Project string
+	Dataset string
+	Table   string
+	// LookbackDays is how many days before the watermark each run re-exports
+	LookbackDays int
+}
+
+// BigQueryConfigFromEnv reads BIGQUERY_PROJECT, BIGQUERY_DATASET and
+// BIGQUERY_TABLE (default sales_totals_by_category). It reports false when
+// BIGQUERY_PROJECT isn't set and the export is disabled. Credentials come from
+// Application Default Credentials.
+func BigQueryConfigFromEnv() (BigQueryConfig, bool, error) {
+	config := BigQueryConfig{
+		Project: os.Getenv("BIGQUERY_PROJECT"),
+		Dataset: os.Getenv("BIGQUERY_DATASET"),
+		Table:   os.Getenv("BIGQUERY_TABLE"),
+	}
+	if config.Project == "" {
+		return config, false, nil
+	}
+	if config.Dataset == "" {
+		return config, false, fmt.Errorf("BIGQUERY_DATASET is required when BIGQUERY_PROJECT is set")
+	}
+	if config.Table == "" {
+		config.Table = "sales_totals_by_category"
+	}
+
+	lookback, err := LookbackFromEnv()
+	if err != nil {
+		return config, false, err
+	}
+	config.LookbackDays = lookback
+
+	return config, true, nil
+}
+
+// ExportToBigQuery loads the DW rows changed since the last run into
+// BigQuery. Rows are written to a staging table with the storage write API
+// and then merged into the target table keyed on date, transaction and
+// category, so reloading overlapping dates never duplicates rows. Target rows
+// in the exported window that no longer exist in the DW are deleted.
+func ExportToBigQuery(ctx context.Context, db *sql.DB, config BigQueryConfig) error {
+	start, err := StartDate(db, BigQuerySink, config.LookbackDays)
+	if err != nil {
+		return err
+	}
+
+	rows, window, err := QueryRows(db, start)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		log.Printf("BigQuery export: no DW rows since %s", start.Format("2006-01-02"))
+		return nil
+	}
+
+	client, err := bigquery.NewClient(ctx, config.Project)
+	if err != nil {
+		return fmt.Errorf("failed to create BigQuery client: %v", err)
+	}
+	defer client.Close()
+
+	dataset := client.Dataset(config.Dataset)
+	target := dataset.Table(config.Table)
+	if err := ensureBigQueryTable(ctx, target); err != nil {
+		return err
+	}
+
+	staging := dataset.Table(fmt.Sprintf("%s_staging_%d", config.Table, time.Now().UnixNano()))
+	err = staging.Create(ctx, &bigquery.TableMetadata{
+		Schema: bigQuerySchema,
+		// Expire the staging table even if this run dies before dropping it
+		ExpirationTime: time.Now().Add(24 * time.Hour),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create staging table: %v", err)
+	}
+	defer func() {
+		if err := staging.Delete(context.Background()); err != nil {
+			log.Printf("Warning: failed to drop BigQuery staging table %s: %v", staging.TableID, err)
+		}
+	}()
+
+	if err := writeBigQueryRows(ctx, staging, rows); err != nil {
+		return err
+	}
+	if err := mergeBigQueryStaging(ctx, client, target, staging, window); err != nil {
+		return err
+	}
+
+	log.Printf("BigQuery export: merged %d rows from %s through %s into %s.%s",
+		len(rows), start.Format("2006-01-02"), window.Through.Format("2006-01-02"), config.Dataset, config.Table)
+	return SaveWatermark(db, BigQuerySink, window.Through)
+}
+
+// ensureBigQueryTable creates the target table partitioned by date, or adds
+// any columns of bigQuerySchema it's missing
+func ensureBigQueryTable(ctx context.Context, table *bigquery.Table) error {
+	metadata, err := table.Metadata(ctx)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		err = table.Create(ctx, &bigquery.TableMetadata{
+			Schema:           bigQuerySchema,
+			TimePartitioning: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType, Field: "date_recorded"},
+			Clustering:       &bigquery.Clustering{Fields: []string{"category_id"}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create BigQuery table: %v", err)
+		}
+		log.Printf("BigQuery export: created table %s", table.FullyQualifiedName())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read BigQuery table metadata: %v", err)
+	}
+
+	existing := make(map[string]bool, len(metadata.Schema))
+	for _, field := range metadata.Schema {
+		existing[field.Name] = true
+	}
+
+	schema := metadata.Schema
+	for _, field := range bigQuerySchema {
+		if !existing[field.Name] {
+			// BigQuery only allows adding nullable columns
+			added := *field
+			added.Required = false
+			schema = append(schema, &added)
+		}
+	}
+	if len(schema) == len(metadata.Schema) {
+		return nil
+	}
+
+	if _, err := table.Update(ctx, bigquery.TableMetadataToUpdate{Schema: schema}, metadata.ETag); err != nil {
+		return fmt.Errorf("failed to add columns to BigQuery table: %v", err)
+	}
+	log.Printf("BigQuery export: added %d columns to %s", len(schema)-len(metadata.Schema), table.FullyQualifiedName())
+	return nil
+}
+
+// writeBigQueryRows appends rows to table through the storage write API's
+// default stream
+func writeBigQueryRows(ctx context.Context, table *bigquery.Table, rows []Row) error {
+	storageSchema, err := adapt.BQSchemaToStorageTableSchema(bigQuerySchema)
+	if err != nil {
+		return fmt.Errorf("failed to convert BigQuery schema: %v", err)
+	}
+	descriptor, err := adapt.StorageSchemaToProto2Descriptor(storageSchema, "root")
+	if err != nil {
+		return fmt.Errorf("failed to build row descriptor: %v", err)
+	}
+	messageDescriptor, ok := descriptor.(protoreflect.MessageDescriptor)
+	if !ok {
+		return fmt.Errorf("row descriptor is not a message descriptor")
+	}
+	descriptorProto, err := adapt.NormalizeDescriptor(messageDescriptor)
+	if err != nil {
+		return fmt.Errorf("failed to normalize row descriptor: %v", err)
+	}
+
+	client, err := managedwriter.NewClient(ctx, table.ProjectID)
+	if err != nil {
+		return fmt.Errorf("failed to create storage write client: %v", err)
+	}
+	defer client.Close()
+
+	stream, err := client.NewManagedStream(ctx,
+		managedwriter.WithDestinationTable(managedwriter.TableParentFromParts(table.ProjectID, table.DatasetID, table.TableID)),
+		managedwriter.WithType(managedwriter.DefaultStream),
+		managedwriter.WithSchemaDescriptor(descriptorProto),
+		managedwriter.EnableWriteRetries(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to open write stream: %v", err)
+	}
+	defer stream.Close()
+
+	exportedAt := time.Now().UnixMicro()
+	var results []*managedwriter.AppendResult
+	for start := 0; start < len(rows); start += bigQueryAppendBatch {
+		end := min(start+bigQueryAppendBatch, len(rows))
+
+		encoded := make([][]byte, 0, end-start)
+		for _, row := range rows[start:end] {
+			data, err := encodeBigQueryRow(messageDescriptor, row, exportedAt)
+			if err != nil {
+				return err
+			}
+			encoded = append(encoded, data)
+		}
+
+		result, err := stream.AppendRows(ctx, encoded)
+		if err != nil {
+			return fmt.Errorf("failed to append rows: %v", err)
+		}
+		results = append(results, result)
+	}
+
+	for _, result := range results {
+		if _, err := result.GetResult(ctx); err != nil {
+			return fmt.Errorf("failed to append rows: %v", err)
+		}
+	}
+	return nil
+}
+
+// encodeBigQueryRow serializes a row as the storage write API's proto message
+func encodeBigQueryRow(descriptor protoreflect.MessageDescriptor, row Row, exportedAt int64) ([]byte, error) {
+	message := dynamicpb.NewMessage(descriptor)
+	fields := descriptor.Fields()
+	set := func(name string, value protoreflect.Value) {
+		message.Set(fields.ByName(protoreflect.Name(name)), value)
+	}
+
+	// DATE is days since the epoch and TIMESTAMP is microseconds
+	set("date_recorded", protoreflect.ValueOfInt32(int32(row.DateRecorded.Unix()/86400)))
+	set("sale_transaction_id", protoreflect.ValueOfInt64(row.SaleTransactionID))
+	set("category_id", protoreflect.ValueOfInt64(row.CategoryID))
+	if row.CustomerID.Valid {
+		set("customer_id", protoreflect.ValueOfInt64(row.CustomerID.Int64))
+	}
+	set("total_amount", protoreflect.ValueOfFloat64(row.TotalAmount))
+	if row.DeletedAt.Valid {
+		set("deleted_at", protoreflect.ValueOfInt64(row.DeletedAt.Time.UnixMicro()))
+	}
+	set("exported_at", protoreflect.ValueOfInt64(exportedAt))
+
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode row: %v", err)
+	}
+	return data, nil
+}
+
+// mergeBigQueryStaging upserts the staged rows into target and deletes
+// target rows in the window that weren't staged. Staged duplicates from
+// retried appends are collapsed first.
+func mergeBigQueryStaging(ctx context.Context, client *bigquery.Client, target, staging *bigquery.Table, window Window) error {
+	query := client.Query(fmt.Sprintf(`
+		MERGE %s T
+		USING (
+			SELECT * FROM %s
+			WHERE TRUE
+			QUALIFY ROW_NUMBER() OVER (PARTITION BY date_recorded, sale_transaction_id, category_id) = 1
+		) S
+		ON T.date_recorded = S.date_recorded
+			AND T.sale_transaction_id = S.sale_transaction_id
+			AND T.category_id = S.category_id
+		WHEN MATCHED THEN UPDATE SET
+			customer_id = S.customer_id,
+			total_amount = S.total_amount,
+			deleted_at = S.deleted_at,
+			exported_at = S.exported_at
+		WHEN NOT MATCHED THEN
+			INSERT (date_recorded, sale_transaction_id, category_id, customer_id, total_amount, deleted_at, exported_at)
+			VALUES (S.date_recorded, S.sale_transaction_id, S.category_id, S.customer_id, S.total_amount, S.deleted_at, S.exported_at)
+		WHEN NOT MATCHED BY SOURCE AND T.date_recorded >= @start THEN DELETE
+	`, bigQueryTableRef(target), bigQueryTableRef(staging)))
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "start", Value: civil.DateOf(window.Start)},
+	}
+
+	job, err := query.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start merge: %v", err)
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to merge staged rows: %v", err)
+	}
+	if err := status.Err(); err != nil {
+		return fmt.Errorf("failed to merge staged rows: %v", err)
+	}
+	return nil
+}
+
+// bigQueryTableRef quotes a table for use in standard SQL
+func bigQueryTableRef(table *bigquery.Table) string {
+	return fmt.Sprintf("`%s.%s.%s`", table.ProjectID, table.DatasetID, table.TableID)
+}
@@ -0,0 +1,113 @@
+package export
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Row is a sales_totals_by_category_dw row as exported to a warehouse
+type Row struct {
+	DateRecorded      time.Time
+	SaleTransactionID int64
+	CategoryID        int64
+	CustomerID        sql.NullInt64
+	TotalAmount       float64
+	DeletedAt         sql.NullTime
+}
+
+// Window is the range of DW dates an export run covers. The sink replaces
+// everything it holds from Start onwards with the exported rows.
+type Window struct {
+	// Start is the first date exported, or the zero time for a full export
+	Start time.Time
+	// Through is the latest date in the exported rows
+	Through time.Time
+}
+
+// Full reports whether the window covers the whole DW table
+func (w Window) Full() bool {
+	return w.Start.IsZero()
+}
+
+// LookbackFromEnv reads EXPORT_LOOKBACK_DAYS (default 3), how many days
+// before the last export's watermark each run re-exports so late corrections
+// and soft deletes reach the warehouse
+func LookbackFromEnv() (int, error) {
+	value := os.Getenv("EXPORT_LOOKBACK_DAYS")
+	if value == "" {
+		return 3, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid EXPORT_LOOKBACK_DAYS %q", value)
+	}
+	return days, nil
+}
+
+// StartDate returns the first date the next export to sink should cover:
+// lookbackDays before its watermark, or the zero time if it has never run
+func StartDate(db *sql.DB, sink string, lookbackDays int) (time.Time, error) {
+	var through time.Time
+	err := db.QueryRow("SELECT exported_through FROM export_watermarks WHERE sink = $1", sink).Scan(&through)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read export watermark: %v", err)
+	}
+	return through.AddDate(0, 0, -lookbackDays), nil
+}
+
+// QueryRows returns the DW rows dated on or after start, including
+// soft-deleted rows so the warehouse sees deletions. When a soft-deleted row
+// was regenerated, only the live copy is returned.
+func QueryRows(db *sql.DB, start time.Time) ([]Row, Window, error) {
+	window := Window{Start: start}
+
+	rows, err := db.Query(`
+		SELECT DISTINCT ON (DATE(date_recorded), sale_transaction_id, category_id)
+			DATE(date_recorded), sale_transaction_id, category_id, customer_id, total_amount, deleted_at
+		FROM sales_totals_by_category_dw
+		WHERE date_recorded >= $1
+		ORDER BY DATE(date_recorded), sale_transaction_id, category_id, deleted_at IS NOT NULL
+	`, start)
+	if err != nil {
+		return nil, window, fmt.Errorf("failed to query DW rows: %v", err)
+	}
+	defer rows.Close()
+
+	var result []Row
+	for rows.Next() {
+		var row Row
+		if err := rows.Scan(&row.DateRecorded, &row.SaleTransactionID, &row.CategoryID, &row.CustomerID, &row.TotalAmount, &row.DeletedAt); err != nil {
+			return nil, window, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if row.DateRecorded.After(window.Through) {
+			window.Through = row.DateRecorded
+		}
+		result = append(result, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, window, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return result, window, nil
+}
+
+// SaveWatermark records that sink holds the DW rows up to through
+func SaveWatermark(db *sql.DB, sink string, through time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO export_watermarks (sink, exported_through, exported_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (sink) DO UPDATE
+			SET exported_through = EXCLUDED.exported_through, exported_at = EXCLUDED.exported_at
+	`, sink, through)
+	if err != nil {
+		return fmt.Errorf("failed to save export watermark: %v", err)
+	}
+	return nil
+}
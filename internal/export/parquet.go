@@ -0,0 +1,251 @@
+package export
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+)
+
+// parquetBatchSize is the number of rows per Parquet row group
+const parquetBatchSize = 64 * 1024
+
+// Dataset is a query materialized into one Parquet file
+type Dataset struct {
+	Name  string
+	Query string
+	Args  []any
+}
+
+// SnapshotDatasets returns the tables an offline snapshot contains: the DW
+// rows and daily category report for the date range, plus the dimension
+// tables and customer segments in full
+func SnapshotDatasets(start, end time.Time) []Dataset {
+	return []Dataset{
+		{
+			Name: "sales_totals_by_category_dw",
+			Query: `
+				SELECT DATE(date_recorded) AS date_recorded, sale_transaction_id, category_id, customer_id, total_amount, deleted_at
+				FROM sales_totals_by_category_dw
+				WHERE date_recorded >= $1 AND date_recorded < $2
+				ORDER BY date_recorded, sale_transaction_id, category_id
+			`,
+			Args: []any{start, end},
+		},
+		{
+			Name: "sales_report_by_category",
+			Query: `
+				SELECT DATE(st.date_recorded) AS date, c.name AS category_name, SUM(st.total_amount) AS total_amount
+				FROM sales_totals_by_category_dw st
+				JOIN categories c ON st.category_id = c.id
+				WHERE st.date_recorded >= $1 AND st.date_recorded < $2 AND st.deleted_at IS NULL
+				GROUP BY DATE(st.date_recorded), c.name
+				ORDER BY DATE(st.date_recorded), c.name
+			`,
+			Args: []any{start, end},
+		},
+		{Name: "categories", Query: "SELECT id, name, parent_id FROM categories ORDER BY id"},
+		{Name: "products", Query: "SELECT id, name, description, price, category_id, company_id, sku, quantity, status FROM products ORDER BY id"},
+		{Name: "customer_segments", Query: "SELECT * FROM customer_segments ORDER BY customer_id"},
+	}
+}
+
+// WriteParquetDir writes every dataset to <dir>/<name>.parquet, plus a
+// load.sql that imports them into DuckDB, and returns the number of rows
+// written per dataset
+func WriteParquetDir(db *sql.DB, dir string, datasets []Dataset) (map[string]int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+
+	counts := make(map[string]int, len(datasets))
+	var load strings.Builder
+	for _, dataset := range datasets {
+		file := dataset.Name + ".parquet"
+		count, err := writeQueryParquet(db, filepath.Join(dir, file), dataset.Query, dataset.Args...)
+		if err != nil {
+			return counts, fmt.Errorf("failed to export %s: %v", dataset.Name, err)
+		}
+		counts[dataset.Name] = count
+		fmt.Fprintf(&load, "CREATE OR REPLACE TABLE %s AS SELECT * FROM read_parquet('%s');\n", dataset.Name, file)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "load.sql"), []byte(load.String()), 0o644); err != nil {
+		return counts, fmt.Errorf("failed to write load.sql: %v", err)
+	}
+	return counts, nil
+}
+
+// writeQueryParquet streams the results of a query into a Parquet file,
+// mapping each Postgres column type to the closest Arrow type
+func writeQueryParquet(db *sql.DB, path, query string, args ...any) (int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query rows: %v", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read column types: %v", err)
+	}
+
+	fields := make([]arrow.Field, len(columns))
+	for i, column := range columns {
+		fields[i] = arrow.Field{Name: column.Name(), Type: arrowType(column.DatabaseTypeName()), Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %v", path, err)
+	}
+	defer file.Close()
+
+	writer, err := pqarrow.NewFileWriter(schema, file,
+		parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy)),
+		pqarrow.DefaultWriterProps())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create Parquet writer: %v", err)
+	}
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+
+	flush := func() error {
+		record := builder.NewRecord()
+		defer record.Release()
+		if record.NumRows() == 0 {
+			return nil
+		}
+		return writer.Write(record)
+	}
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			writer.Close()
+			return count, fmt.Errorf("failed to scan row: %v", err)
+		}
+		for i, value := range values {
+			appendArrowValue(builder.Field(i), value)
+		}
+
+		count++
+		if count%parquetBatchSize == 0 {
+			if err := flush(); err != nil {
+				writer.Close()
+				return count, fmt.Errorf("failed to write Parquet rows: %v", err)
+			}
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		writer.Close()
+		return count, fmt.Errorf("error iterating rows: %v", err)
+	}
+	if err := flush(); err != nil {
+		writer.Close()
+		return count, fmt.Errorf("failed to write Parquet rows: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return count, fmt.Errorf("failed to write Parquet file: %v", err)
+	}
+	return count, nil
+}
+
+// arrowType returns the Arrow type for a Postgres type name. Unknown types
+// are exported as strings.
+func arrowType(databaseType string) arrow.DataType {
+	switch strings.ToUpper(databaseType) {
+	case "INT2", "INT4", "INT8":
+		return arrow.PrimitiveTypes.Int64
+	case "FLOAT4", "FLOAT8", "NUMERIC":
+		return arrow.PrimitiveTypes.Float64
+	case "BOOL":
+		return arrow.FixedWidthTypes.Boolean
+	case "DATE":
+		return arrow.FixedWidthTypes.Date32
+	case "TIMESTAMP", "TIMESTAMPTZ":
+		return &arrow.TimestampType{Unit: arrow.Microsecond}
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// appendArrowValue appends a scanned database value to the column builder,
+// converting it to the builder's type
+func appendArrowValue(builder array.Builder, value any) {
+	if value == nil {
+		builder.AppendNull()
+		return
+	}
+
+	switch b := builder.(type) {
+	case *array.Int64Builder:
+		if v, ok := value.(int64); ok {
+			b.Append(v)
+		} else {
+			b.AppendNull()
+		}
+	case *array.Float64Builder:
+		switch v := value.(type) {
+		case float64:
+			b.Append(v)
+		case []byte:
+			// NUMERIC is scanned as text
+			var f float64
+			if _, err := fmt.Sscan(string(v), &f); err != nil {
+				b.AppendNull()
+				return
+			}
+			b.Append(f)
+		default:
+			b.AppendNull()
+		}
+	case *array.BooleanBuilder:
+		if v, ok := value.(bool); ok {
+			b.Append(v)
+		} else {
+			b.AppendNull()
+		}
+	case *array.Date32Builder:
+		if v, ok := value.(time.Time); ok {
+			b.Append(arrow.Date32FromTime(v))
+		} else {
+			b.AppendNull()
+		}
+	case *array.TimestampBuilder:
+		if v, ok := value.(time.Time); ok {
+			b.Append(arrow.Timestamp(v.UnixMicro()))
+		} else {
+			b.AppendNull()
+		}
+	case *array.StringBuilder:
+		switch v := value.(type) {
+		case []byte:
+			b.Append(string(v))
+		case string:
+			b.Append(v)
+		default:
+			b.Append(fmt.Sprint(v))
+		}
+	default:
+		builder.AppendNull()
+	}
+}
@@ -0,0 +1,300 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/snowflakedb/gosnowflake"
+)
+
+// SnowflakeSink is the export_watermarks key of the Snowflake export
+const SnowflakeSink = "snowflake"
+
+// snowflakeColumns are the exported table's columns. Columns may be added to
+// the end; existing tables gain them on the next run.
+var snowflakeColumns = []struct {
+	name       string
+	definition string
+}{
+	{"date_recorded", "DATE NOT NULL"},
+	{"sale_transaction_id", "NUMBER(38, 0) NOT NULL"},
+	{"category_id", "NUMBER(38, 0) NOT NULL"},
+	{"customer_id", "NUMBER(38, 0)"},
+	{"total_amount", "FLOAT NOT NULL"},
+	{"deleted_at", "TIMESTAMP_NTZ"},
+	{"exported_at", "TIMESTAMP_NTZ NOT NULL"},
+}
+
+// snowflakeParquetSchema is the schema of the staged Parquet files, matching
+// snowflakeColumns by name
+var snowflakeParquetSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "date_recorded", Type: arrow.FixedWidthTypes.Date32},
+	{Name: "sale_transaction_id", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "category_id", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "customer_id", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+	{Name: "total_amount", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "deleted_at", Type: &arrow.TimestampType{Unit: arrow.Microsecond}, Nullable: true},
+	{Name: "exported_at", Type: &arrow.TimestampType{Unit: arrow.Microsecond}},
+}, nil)
+
+// snowflakeIdentifier matches an unquoted, optionally qualified Snowflake name
+var snowflakeIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*)*$`)
+
+// SnowflakeConfig is the destination of the Snowflake export
+type SnowflakeConfig struct {
+	Connection gosnowflake.Config
+	Table      string
+	// Stage is the stage the Parquet files are uploaded to
+	Stage string
+	// LookbackDays is how many days before the watermark each run re-exports
+	LookbackDays int
+}
+
+// SnowflakeConfigFromEnv reads SNOWFLAKE_ACCOUNT, SNOWFLAKE_USER,
+// SNOWFLAKE_PASSWORD, SNOWFLAKE_WAREHOUSE, SNOWFLAKE_DATABASE,
+// SNOWFLAKE_SCHEMA (default PUBLIC), SNOWFLAKE_ROLE, SNOWFLAKE_TABLE (default
+// sales_totals_by_category) and SNOWFLAKE_STAGE (default
+// craft_export_stage). It reports false when SNOWFLAKE_ACCOUNT isn't set and
+// the export is disabled.
+func SnowflakeConfigFromEnv() (SnowflakeConfig, bool, error) {
+	config := SnowflakeConfig{
+		Connection: gosnowflake.Config{
+			Account:   os.Getenv("SNOWFLAKE_ACCOUNT"),
+			User:      os.Getenv("SNOWFLAKE_USER"),
+			Password:  os.Getenv("SNOWFLAKE_PASSWORD"),
+			Warehouse: os.Getenv("SNOWFLAKE_WAREHOUSE"),
+			Database:  os.Getenv("SNOWFLAKE_DATABASE"),
+			Schema:    os.Getenv("SNOWFLAKE_SCHEMA"),
+			Role:      os.Getenv("SNOWFLAKE_ROLE"),
+		},
+		Table: os.Getenv("SNOWFLAKE_TABLE"),
+		Stage: os.Getenv("SNOWFLAKE_STAGE"),
+	}
+	if config.Connection.Account == "" {
+		return config, false, nil
+	}
+
+	for env, value := range map[string]string{
+		"SNOWFLAKE_USER":      config.Connection.User,
+		"SNOWFLAKE_WAREHOUSE": config.Connection.Warehouse,
+		"SNOWFLAKE_DATABASE":  config.Connection.Database,
+	} {
+		if value == "" {
+			return config, false, fmt.Errorf("%s is required when SNOWFLAKE_ACCOUNT is set", env)
+		}
+	}
+	if config.Connection.Schema == "" {
+		config.Connection.Schema = "PUBLIC"
+	}
+	if config.Table == "" {
+		config.Table = "sales_totals_by_category"
+	}
+	if config.Stage == "" {
+		config.Stage = "craft_export_stage"
+	}
+
+	for env, value := range map[string]string{"SNOWFLAKE_TABLE": config.Table, "SNOWFLAKE_STAGE": config.Stage} {
+		if !snowflakeIdentifier.MatchString(value) {
+			return config, false, fmt.Errorf("invalid %s %q", env, value)
+		}
+	}
+
+	lookback, err := LookbackFromEnv()
+	if err != nil {
+		return config, false, err
+	}
+	config.LookbackDays = lookback
+
+	return config, true, nil
+}
+
+// ExportToSnowflake loads the DW rows changed since the last run into
+// Snowflake. Rows are written to a Parquet file, uploaded to the stage with
+// PUT and copied into a temporary table with COPY INTO. The target's rows in
+// the exported window are then replaced in one transaction, so reloading
+// overlapping dates never duplicates rows and rows removed from the DW
+// disappear from Snowflake too.
+func ExportToSnowflake(ctx context.Context, db *sql.DB, config SnowflakeConfig) error {
+	start, err := StartDate(db, SnowflakeSink, config.LookbackDays)
+	if err != nil {
+		return err
+	}
+
+	rows, window, err := QueryRows(db, start)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		log.Printf("Snowflake export: no DW rows since %s", start.Format("2006-01-02"))
+		return nil
+	}
+
+	file, err := writeParquetFile(rows)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+
+	snowflake := sql.OpenDB(gosnowflake.NewConnector(gosnowflake.SnowflakeDriver{}, config.Connection))
+	defer snowflake.Close()
+
+	// The temporary staging table only exists on the connection that created it
+	conn, err := snowflake.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Snowflake: %v", err)
+	}
+	defer conn.Close()
+
+	if err := ensureSnowflakeTable(ctx, conn, config); err != nil {
+		return err
+	}
+
+	staging := config.Table + "_staging"
+	statements := []string{
+		fmt.Sprintf("CREATE STAGE IF NOT EXISTS %s FILE_FORMAT = (TYPE = PARQUET)", config.Stage),
+		fmt.Sprintf("PUT 'file://%s' @%s/sales_totals/ AUTO_COMPRESS = FALSE OVERWRITE = TRUE", filepath.ToSlash(file), config.Stage),
+		fmt.Sprintf("CREATE OR REPLACE TEMPORARY TABLE %s LIKE %s", staging, config.Table),
+		fmt.Sprintf(`COPY INTO %s FROM @%s/sales_totals/%s
+			FILE_FORMAT = (TYPE = PARQUET)
+			MATCH_BY_COLUMN_NAME = CASE_INSENSITIVE
+			PURGE = TRUE`, staging, config.Stage, filepath.Base(file)),
+	}
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to stage rows: %v", err)
+		}
+	}
+
+	if err := replaceSnowflakeWindow(ctx, conn, config.Table, staging, window); err != nil {
+		return err
+	}
+
+	log.Printf("Snowflake export: loaded %d rows from %s through %s into %s",
+		len(rows), start.Format("2006-01-02"), window.Through.Format("2006-01-02"), config.Table)
+	return SaveWatermark(db, SnowflakeSink, window.Through)
+}
+
+// ensureSnowflakeTable creates the target table, or adds any of
+// snowflakeColumns it's missing
+func ensureSnowflakeTable(ctx context.Context, conn *sql.Conn, config SnowflakeConfig) error {
+	definitions := make([]string, 0, len(snowflakeColumns))
+	for _, column := range snowflakeColumns {
+		definitions = append(definitions, column.name+" "+column.definition)
+	}
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) CLUSTER BY (date_recorded)",
+		config.Table, strings.Join(definitions, ", "))
+	if _, err := conn.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create Snowflake table: %v", err)
+	}
+
+	for _, column := range snowflakeColumns {
+		// Snowflake only allows adding nullable columns to a populated table
+		definition := strings.TrimSuffix(column.definition, " NOT NULL")
+		alter := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", config.Table, column.name, definition)
+		if _, err := conn.ExecContext(ctx, alter); err != nil {
+			return fmt.Errorf("failed to add column %s to Snowflake table: %v", column.name, err)
+		}
+	}
+	return nil
+}
+
+// replaceSnowflakeWindow deletes the target's rows from the window start
+// onwards and inserts the staged rows in one transaction. Staged duplicates
+// are collapsed first.
+func replaceSnowflakeWindow(ctx context.Context, conn *sql.Conn, table, staging string, window Window) error {
+	columns := make([]string, 0, len(snowflakeColumns))
+	for _, column := range snowflakeColumns {
+		columns = append(columns, column.name)
+	}
+	columnList := strings.Join(columns, ", ")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE date_recorded >= ?", table), window.Start.Format("2006-01-02")); err != nil {
+		return fmt.Errorf("failed to delete exported window: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		SELECT %s FROM %s
+		QUALIFY ROW_NUMBER() OVER (PARTITION BY date_recorded, sale_transaction_id, category_id ORDER BY exported_at DESC) = 1
+	`, table, columnList, columnList, staging))
+	if err != nil {
+		return fmt.Errorf("failed to insert staged rows: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// writeParquetFile writes rows to a temporary Parquet file and returns its path
+func writeParquetFile(rows []Row) (string, error) {
+	file, err := os.CreateTemp("", fmt.Sprintf("sales_totals_%d_*.parquet", time.Now().Unix()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create Parquet file: %v", err)
+	}
+	defer file.Close()
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, snowflakeParquetSchema)
+	defer builder.Release()
+
+	exportedAt := arrow.Timestamp(time.Now().UnixMicro())
+	for _, row := range rows {
+		builder.Field(0).(*array.Date32Builder).Append(arrow.Date32FromTime(row.DateRecorded))
+		builder.Field(1).(*array.Int64Builder).Append(row.SaleTransactionID)
+		builder.Field(2).(*array.Int64Builder).Append(row.CategoryID)
+		if row.CustomerID.Valid {
+			builder.Field(3).(*array.Int64Builder).Append(row.CustomerID.Int64)
+		} else {
+			builder.Field(3).AppendNull()
+		}
+		builder.Field(4).(*array.Float64Builder).Append(row.TotalAmount)
+		if row.DeletedAt.Valid {
+			builder.Field(5).(*array.TimestampBuilder).Append(arrow.Timestamp(row.DeletedAt.Time.UnixMicro()))
+		} else {
+			builder.Field(5).AppendNull()
+		}
+		builder.Field(6).(*array.TimestampBuilder).Append(exportedAt)
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+
+	writer, err := pqarrow.NewFileWriter(snowflakeParquetSchema, file,
+		parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy)),
+		pqarrow.DefaultWriterProps())
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to create Parquet writer: %v", err)
+	}
+	if err := writer.Write(record); err != nil {
+		writer.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write Parquet file: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write Parquet file: %v", err)
+	}
+
+	return file.Name(), nil
+}
@@ -0,0 +1,260 @@
+package forecast
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// Holiday is a named date whose effect the additive model estimates
+type Holiday struct {
+	Date time.Time
+	Name string
+}
+
+// Additive model settings
+const (
+	// additiveChangepoints is the most trend changepoints placed in the
+	// first 80% of the history
+	additiveChangepoints = 10
+	// additiveWeeklyOrder and additiveYearlyOrder are the Fourier orders of
+	// the seasonalities
+	additiveWeeklyOrder = 3
+	additiveYearlyOrder = 10
+	// additiveRidge regularizes every coefficient but the intercept so that
+	// short histories with many features still have a stable fit
+	additiveRidge = 0.1
+)
+
+// additive is a decomposable model in the style of Prophet:
+//
+//	y(t) = trend(t) + weekly(t) + yearly(t) + holidays(t)
+//
+// The trend is piecewise linear with evenly spaced changepoints, the
+// seasonalities are Fourier series, and each holiday seen in the history
+// adds a constant to the periods containing it. All terms are fit at once by
+// ridge-regularized least squares.
+type additive struct {
+	timePeriod string
+	holidays   []Holiday
+}
+
+// features describes the design matrix of an additive fit
+type features struct {
+	origin       time.Time
+	span         float64
+	changepoints []float64
+	weekly       bool
+	yearly       bool
+	holidays     map[string][]time.Time
+	names        []string
+	timePeriod   string
+}
+
+// Predict forecasts an undated series with Holt's method
+func (a additive) Predict(values []float64, periods int) []float64 {
+	return doubleExponential{alpha: DefaultAlpha, beta: DefaultBeta}.Predict(values, periods)
+}
+
+// PredictDates fits the model to history and predicts the given dates. It
+// falls back to Holt's method when too few periods can be parsed.
+func (a additive) PredictDates(history []Point, dates []time.Time) []float64 {
+	var (
+		times  []time.Time
+		values []float64
+	)
+	for _, point := range history {
+		date, err := ParsePeriod(point.Period)
+		if err != nil {
+			continue
+		}
+		times = append(times, date)
+		values = append(values, point.Total)
+	}
+	if len(times) < 4 {
+		return doubleExponential{alpha: DefaultAlpha, beta: DefaultBeta}.Predict(values, len(dates))
+	}
+
+	f := a.features(times)
+	rows := make([][]float64, len(times))
+	for i, date := range times {
+		rows[i] = f.row(date)
+	}
+
+	// Scale the target so the ridge penalty doesn't depend on its magnitude
+	scale := 0.0
+	for _, value := range values {
+		scale = math.Max(scale, math.Abs(value))
+	}
+	if scale == 0 {
+		return repeat(0, len(dates))
+	}
+	scaled := make([]float64, len(values))
+	for i, value := range values {
+		scaled[i] = value / scale
+	}
+
+	coefficients, ok := ridgeLeastSquares(rows, scaled, additiveRidge)
+	if !ok {
+		return doubleExponential{alpha: DefaultAlpha, beta: DefaultBeta}.Predict(values, len(dates))
+	}
+
+	result := make([]float64, len(dates))
+	for i, date := range dates {
+		var total float64
+		for j, x := range f.row(date) {
+			total += coefficients[j] * x
+		}
+		result[i] = total * scale
+	}
+	return result
+}
+
+// features chooses the terms of the model for the observed dates: weekly
+// seasonality for daily data spanning two weeks, yearly seasonality for data
+// spanning a year, and the holidays that occur during the history
+func (a additive) features(times []time.Time) features {
+	first, last := times[0], times[0]
+	for _, date := range times {
+		if date.Before(first) {
+			first = date
+		}
+		if date.After(last) {
+			last = date
+		}
+	}
+	days := last.Sub(first).Hours() / 24
+
+	f := features{
+		origin:     first,
+		span:       math.Max(days, 1),
+		weekly:     a.timePeriod == "day" && days >= 14,
+		yearly:     days >= 365,
+		holidays:   map[string][]time.Time{},
+		timePeriod: a.timePeriod,
+	}
+
+	count := min(additiveChangepoints, len(times)/4)
+	for k := 1; k <= count; k++ {
+		f.changepoints = append(f.changepoints, 0.8*float64(k)/float64(count+1))
+	}
+
+	for _, holiday := range a.holidays {
+		f.holidays[holiday.Name] = append(f.holidays[holiday.Name], holiday.Date)
+	}
+	for name, dates := range f.holidays {
+		seen := false
+		for _, date := range times {
+			if f.holidayIn(dates, date) {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			delete(f.holidays, name)
+		}
+	}
+	f.names = slices.Sorted(maps.Keys(f.holidays))
+	return f
+}
+
+// row returns the feature values for a date. Holidays are visited in name
+// order so every row has the same layout.
+func (f features) row(date time.Time) []float64 {
+	t := date.Sub(f.origin).Hours() / 24 / f.span
+	row := []float64{1, t}
+	for _, changepoint := range f.changepoints {
+		row = append(row, math.Max(0, t-changepoint))
+	}
+
+	days := date.Sub(time.Unix(0, 0).UTC()).Hours() / 24
+	if f.weekly {
+		row = appendFourier(row, days, 7, additiveWeeklyOrder)
+	}
+	if f.yearly {
+		row = appendFourier(row, days, 365.25, additiveYearlyOrder)
+	}
+
+	for _, name := range f.names {
+		if f.holidayIn(f.holidays[name], date) {
+			row = append(row, 1)
+		} else {
+			row = append(row, 0)
+		}
+	}
+	return row
+}
+
+// holidayIn reports whether any of the holiday dates falls in the period
+// starting at date
+func (f features) holidayIn(dates []time.Time, date time.Time) bool {
+	end := Step(date, f.timePeriod, 1)
+	for _, holiday := range dates {
+		if !holiday.Before(date) && holiday.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendFourier appends the sine and cosine terms of a Fourier series with
+// the given period in days
+func appendFourier(row []float64, days, period float64, order int) []float64 {
+	for n := 1; n <= order; n++ {
+		angle := 2 * math.Pi * float64(n) * days / period
+		row = append(row, math.Sin(angle), math.Cos(angle))
+	}
+	return row
+}
+
+// ridgeLeastSquares solves (XᵀX + λI)β = Xᵀy, leaving the first (intercept)
+// column unpenalized. It reports false when the system is singular.
+func ridgeLeastSquares(rows [][]float64, y []float64, lambda float64) ([]float64, bool) {
+	n := len(rows[0])
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n+1)
+	}
+	for r, row := range rows {
+		for i := 0; i < n; i++ {
+			for j := 0; j < n; j++ {
+				a[i][j] += row[i] * row[j]
+			}
+			a[i][n] += row[i] * y[r]
+		}
+	}
+	for i := 1; i < n; i++ {
+		a[i][i] += lambda
+	}
+
+	// Gaussian elimination with partial pivoting
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for r := col + 1; r < n; r++ {
+			factor := a[r][col] / a[col][col]
+			for c := col; c <= n; c++ {
+				a[r][c] -= factor * a[col][c]
+			}
+		}
+	}
+
+	beta := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		sum := a[i][n]
+		for j := i + 1; j < n; j++ {
+			sum -= a[i][j] * beta[j]
+		}
+		beta[i] = sum / a[i][i]
+	}
+	return beta, true
+}
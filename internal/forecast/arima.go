@@ -0,0 +1,170 @@
+package forecast
+
+import "math"
+
+// MethodARIMA is an autoregressive model on a differenced series: ARIMA(p, d, 0)
+const MethodARIMA Method = "arima"
+
+// Limits of the autoregressive model
+const (
+	// MaxAROrder is the most lagged values the model may use
+	MaxAROrder = 24
+	// MaxDifferencing is the most times the series may be differenced
+	MaxDifferencing = 2
+	// arRidge keeps the least squares fit stable when lags are collinear
+	arRidge = 1e-6
+)
+
+// arima fits an AR(p) model by conditional least squares to the series
+// differenced d times and integrates its forecasts back. A zero order is
+// chosen by AIC and a nil differencing by the lowest variance.
+type arima struct {
+	order        int
+	differencing *int
+}
+
+func (a arima) Predict(values []float64, periods int) []float64 {
+	if len(values) == 0 {
+		return repeat(0, periods)
+	}
+
+	d := chooseDifferencing(values)
+	if a.differencing != nil {
+		d = *a.differencing
+	}
+	d = min(d, len(values)-1)
+
+	// Difference d times, keeping the last value of each level to integrate
+	series := values
+	lasts := make([]float64, d)
+	for i := 0; i < d; i++ {
+		lasts[i] = series[len(series)-1]
+		series = difference(series)
+	}
+
+	predicted, ok := predictAR(series, a.order, periods)
+	if !ok {
+		// Too short to fit: carry the differenced series' mean forward
+		predicted = repeat(mean(series), periods)
+	}
+
+	// Integrate from the most differenced level back to the original
+	for i := d - 1; i >= 0; i-- {
+		level := lasts[i]
+		for j := range predicted {
+			level += predicted[j]
+			predicted[j] = level
+		}
+	}
+	return predicted
+}
+
+// chooseDifferencing returns the number of differences, up to
+// MaxDifferencing, that gives the series the lowest variance, a common rule
+// for removing trend without over-differencing
+func chooseDifferencing(values []float64) int {
+	best, bestVariance := 0, variance(values)
+	series := values
+	for d := 1; d <= MaxDifferencing && len(series) > 2; d++ {
+		series = difference(series)
+		if v := variance(series); v < bestVariance {
+			best, bestVariance = d, v
+		} else {
+			break
+		}
+	}
+	return best
+}
+
+// predictAR fits an AR model with an intercept and forecasts periods ahead
+// recursively. With order zero the order is the one with the lowest AIC.
+// It reports false when the series is too short for any order.
+func predictAR(series []float64, order, periods int) ([]float64, bool) {
+	maxOrder := order
+	if order == 0 {
+		maxOrder = min(MaxAROrder, (len(series)-1)/3)
+	}
+	// Every order is fit to the same observations so their AICs compare
+	observations := len(series) - maxOrder
+	if maxOrder < 1 || observations < maxOrder+2 {
+		return nil, false
+	}
+
+	var (
+		best     []float64
+		bestAIC  = math.Inf(1)
+		minOrder = maxOrder
+	)
+	if order == 0 {
+		minOrder = 1
+	}
+	for p := minOrder; p <= maxOrder; p++ {
+		rows := make([][]float64, 0, observations)
+		y := make([]float64, 0, observations)
+		for t := maxOrder; t < len(series); t++ {
+			row := []float64{1}
+			for lag := 1; lag <= p; lag++ {
+				row = append(row, series[t-lag])
+			}
+			rows = append(rows, row)
+			y = append(y, series[t])
+		}
+		coefficients, ok := ridgeLeastSquares(rows, y, arRidge)
+		if !ok {
+			continue
+		}
+
+		var rss float64
+		for r, row := range rows {
+			residual := y[r] - dot(coefficients, row)
+			rss += residual * residual
+		}
+		aic := float64(observations)*math.Log(math.Max(rss, 1e-12)/float64(observations)) + 2*float64(p+1)
+		if aic < bestAIC {
+			best, bestAIC = coefficients, aic
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+
+	// Forecast recursively, feeding each prediction back in as a lag
+	p := len(best) - 1
+	extended := append([]float64(nil), series...)
+	predicted := make([]float64, periods)
+	for i := range predicted {
+		row := []float64{1}
+		for lag := 1; lag <= p; lag++ {
+			row = append(row, extended[len(extended)-lag])
+		}
+		predicted[i] = dot(best, row)
+		extended = append(extended, predicted[i])
+	}
+	return predicted, true
+}
+
+// difference returns the changes between consecutive values
+func difference(values []float64) []float64 {
+	result := make([]float64, 0, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		result = append(result, values[i]-values[i-1])
+	}
+	return result
+}
+
+func variance(values []float64) float64 {
+	m := mean(values)
+	var sum float64
+	for _, value := range values {
+		sum += (value - m) * (value - m)
+	}
+	return sum / float64(len(values))
+}
+
+func dot(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
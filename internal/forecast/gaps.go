@@ -0,0 +1,159 @@
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GapFill names how missing periods are imputed
+type GapFill string
+
+// Supported gap fills
+const (
+	// GapFillNone leaves gaps in the series
+	GapFillNone GapFill = "none"
+	// GapFillZero treats missing periods as having no sales
+	GapFillZero GapFill = "zero"
+	// GapFillLinear interpolates between the periods either side of a gap
+	GapFillLinear GapFill = "linear"
+	// GapFillSeasonal uses the average of the observed periods in the same
+	// position of the season, such as the same weekday for daily data
+	GapFillSeasonal GapFill = "seasonal"
+)
+
+// ParseGapFill validates a gap fill name. An empty name is GapFillZero,
+// matching how missing days are treated for warehouse-sourced series.
+func ParseGapFill(name string) (GapFill, error) {
+	switch fill := GapFill(strings.ToLower(name)); fill {
+	case "":
+		return GapFillZero, nil
+	case GapFillNone, GapFillZero, GapFillLinear, GapFillSeasonal:
+		return fill, nil
+	default:
+		return "", fmt.Errorf("unknown gap fill %q", name)
+	}
+}
+
+// FillGaps sorts history by period and inserts a point for every missing
+// period of the given time period between the first and last, returning the
+// filled series and the imputed points. The series is returned unchanged if
+// any period can't be parsed.
+func FillGaps(history []Point, timePeriod string, fill GapFill) ([]Point, []Point) {
+	if fill == GapFillNone || len(history) < 2 {
+		return history, nil
+	}
+
+	type dated struct {
+		date  time.Time
+		point Point
+	}
+	points := make([]dated, 0, len(history))
+	layout := "2006-01-02"
+	for _, point := range history {
+		date, err := ParsePeriod(point.Period)
+		if err != nil {
+			return history, nil
+		}
+		if len(point.Period) == len("2006-01") {
+			layout = "2006-01"
+		}
+		points = append(points, dated{date, point})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].date.Before(points[j].date) })
+
+	// Dates are only imputed at least half a period before the next observed
+	// one, so slightly irregular series aren't padded
+	var (
+		filled  []Point
+		imputed []int
+		dates   []time.Time
+	)
+	for i, current := range points {
+		filled = append(filled, current.point)
+		dates = append(dates, current.date)
+		if i == len(points)-1 {
+			break
+		}
+		next := points[i+1].date
+		for n := 1; ; n++ {
+			date := Step(current.date, timePeriod, n)
+			if !date.Before(next) || next.Sub(date) < Step(date, timePeriod, 1).Sub(date)/2 {
+				break
+			}
+			imputed = append(imputed, len(filled))
+			label := date.Format(layout)
+			if timePeriod == "iso_week" || timePeriod == "fiscal" {
+				label = FormatPeriod(date, timePeriod)
+			}
+			filled = append(filled, Point{Period: label})
+			dates = append(dates, date)
+		}
+	}
+	if len(imputed) == 0 {
+		return filled, nil
+	}
+
+	missing := make(map[int]bool, len(imputed))
+	for _, i := range imputed {
+		missing[i] = true
+	}
+
+	var seasonal map[int]float64
+	if fill == GapFillSeasonal {
+		seasonal = seasonalAverages(filled, missing, SeasonLength(timePeriod))
+	}
+
+	result := make([]Point, 0, len(imputed))
+	for _, i := range imputed {
+		switch fill {
+		case GapFillLinear:
+			filled[i].Total = interpolate(filled, dates, missing, i)
+		case GapFillSeasonal:
+			if average, ok := seasonal[i%SeasonLength(timePeriod)]; ok {
+				filled[i].Total = average
+			} else {
+				filled[i].Total = interpolate(filled, dates, missing, i)
+			}
+		}
+		result = append(result, filled[i])
+	}
+	return filled, result
+}
+
+// interpolate returns the linear interpolation at index i between the
+// nearest observed points either side, weighted by date
+func interpolate(points []Point, dates []time.Time, missing map[int]bool, i int) float64 {
+	before, after := i-1, i+1
+	for missing[before] {
+		before--
+	}
+	for missing[after] {
+		after++
+	}
+	span := dates[after].Sub(dates[before]).Hours()
+	weight := dates[i].Sub(dates[before]).Hours() / span
+	total := points[before].Total + (points[after].Total-points[before].Total)*weight
+	return math.Round(total*100) / 100
+}
+
+// seasonalAverages returns the average observed total at each position of
+// the season
+func seasonalAverages(points []Point, missing map[int]bool, season int) map[int]float64 {
+	sums := make(map[int]float64)
+	counts := make(map[int]int)
+	for i, point := range points {
+		if missing[i] {
+			continue
+		}
+		sums[i%season] += point.Total
+		counts[i%season]++
+	}
+	averages := make(map[int]float64, len(sums))
+	for position, sum := range sums {
+		averages[position] = math.Round(sum/float64(counts[position])*100) / 100
+	}
+	return averages
+}
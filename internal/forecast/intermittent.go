@@ -0,0 +1,154 @@
+package forecast
+
+import "math"
+
+// Intermittent demand methods
+const (
+	MethodCroston Method = "croston"
+	MethodTSB     Method = "tsb"
+)
+
+// Syntetos-Boylan cut-offs between demand classes
+const (
+	intermittentADI = 1.32
+	lumpyCV2        = 0.49
+)
+
+// Demand classes
+const (
+	DemandSmooth       = "smooth"
+	DemandErratic      = "erratic"
+	DemandIntermittent = "intermittent"
+	DemandLumpy        = "lumpy"
+)
+
+// DemandPattern classifies a series by how often and how evenly it has
+// demand, following Syntetos and Boylan
+type DemandPattern struct {
+	// ADI is the average number of periods between non-zero values
+	ADI float64 `json:"adi"`
+	// CV2 is the squared coefficient of variation of the non-zero values
+	CV2 float64 `json:"cv2"`
+	// ZeroShare is the fraction of periods with no demand
+	ZeroShare float64 `json:"zeroShare"`
+	// Class is smooth, erratic, intermittent, or lumpy
+	Class string `json:"class"`
+	// Intermittent is set for intermittent and lumpy series, which
+	// trend-based methods forecast poorly
+	Intermittent bool `json:"intermittent"`
+	// Method is the method recommended for the series when none is given:
+	// croston for intermittent series, tsb for lumpy ones, and empty
+	// otherwise
+	Method Method `json:"method,omitempty"`
+}
+
+// ClassifyDemand classifies a series. Series with fewer than two non-zero
+// values are intermittent when they contain any zero.
+func ClassifyDemand(values []float64) DemandPattern {
+	var (
+		demands []float64
+		zeros   int
+	)
+	for _, value := range values {
+		if value > 0 {
+			demands = append(demands, value)
+		} else {
+			zeros++
+		}
+	}
+
+	pattern := DemandPattern{}
+	if len(values) > 0 {
+		pattern.ZeroShare = float64(zeros) / float64(len(values))
+	}
+	if len(demands) > 0 {
+		pattern.ADI = float64(len(values)) / float64(len(demands))
+	}
+	if len(demands) > 1 {
+		m := mean(demands)
+		var variance float64
+		for _, demand := range demands {
+			variance += (demand - m) * (demand - m)
+		}
+		variance /= float64(len(demands))
+		pattern.CV2 = variance / (m * m)
+	}
+
+	intermittent := pattern.ADI >= intermittentADI || (len(demands) < 2 && zeros > 0)
+	switch {
+	case intermittent && pattern.CV2 >= lumpyCV2:
+		pattern.Class, pattern.Method = DemandLumpy, MethodTSB
+	case intermittent:
+		pattern.Class, pattern.Method = DemandIntermittent, MethodCroston
+	case pattern.CV2 >= lumpyCV2:
+		pattern.Class = DemandErratic
+	default:
+		pattern.Class = DemandSmooth
+	}
+	pattern.Intermittent = intermittent
+	pattern.ADI = math.Round(pattern.ADI*1000) / 1000
+	pattern.CV2 = math.Round(pattern.CV2*1000) / 1000
+	pattern.ZeroShare = math.Round(pattern.ZeroShare*1000) / 1000
+	return pattern
+}
+
+// croston smooths the size of non-zero demands and the interval between
+// them separately and forecasts their ratio
+type croston struct {
+	alpha float64
+}
+
+func (c croston) Predict(values []float64, periods int) []float64 {
+	var size, interval float64
+	since := 1.0
+	started := false
+	for _, value := range values {
+		if value <= 0 {
+			since++
+			continue
+		}
+		if !started {
+			size, interval, started = value, since, true
+		} else {
+			size = c.alpha*value + (1-c.alpha)*size
+			interval = c.alpha*since + (1-c.alpha)*interval
+		}
+		since = 1
+	}
+	if !started {
+		return repeat(0, periods)
+	}
+	return repeat(size/interval, periods)
+}
+
+// tsb is the Teunter-Syntetos-Babai method: it smooths the demand size when
+// demand occurs and the probability of demand every period, so the forecast
+// decays when demand stops
+type tsb struct {
+	alpha, beta float64
+}
+
+func (t tsb) Predict(values []float64, periods int) []float64 {
+	first := -1
+	for i, value := range values {
+		if value > 0 {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return repeat(0, periods)
+	}
+
+	// Start from the first demand and the overall demand frequency
+	size, probability := values[first], 1/ClassifyDemand(values).ADI
+	for _, value := range values[first+1:] {
+		if value > 0 {
+			size = t.alpha*value + (1-t.alpha)*size
+			probability = t.beta + (1-t.beta)*probability
+		} else {
+			probability = (1 - t.beta) * probability
+		}
+	}
+	return repeat(size*probability, periods)
+}
@@ -0,0 +1,76 @@
+package forecast
+
+import "math"
+
+// Interval is a confidence band around a forecast total
+type Interval struct {
+	// Level is the confidence level in percent
+	Level int
+	Lower float64
+	Upper float64
+}
+
+// IntervalLevels are the confidence levels of the bands, with the z-score
+// of each two-sided normal interval
+var IntervalLevels = []struct {
+	Level int
+	Z     float64
+}{
+	{80, 1.2816},
+	{95, 1.9600},
+}
+
+// minSpread is the spread, as a fraction of the latest total, assumed when
+// the history is too short to measure one
+const minSpread = 0.1
+
+// Spread estimates the standard deviation of a one-step forecast error as
+// the root mean square of the period-to-period changes in history
+func Spread(history []Point) float64 {
+	if len(history) < 2 {
+		if len(history) == 0 {
+			return 0
+		}
+		return math.Abs(history[len(history)-1].Total) * minSpread
+	}
+
+	var sum float64
+	for i := 1; i < len(history); i++ {
+		change := history[i].Total - history[i-1].Total
+		sum += change * change
+	}
+	return math.Sqrt(sum / float64(len(history)-1))
+}
+
+// Intervals returns the bands around total at step periods ahead: the
+// one-step spread widens with the square root of the step. Like forecast
+// totals, bounds are never below zero.
+func Intervals(total, spread float64, step int) []Interval {
+	width := spread * math.Sqrt(float64(step))
+	intervals := make([]Interval, len(IntervalLevels))
+	for i, level := range IntervalLevels {
+		intervals[i] = Interval{
+			Level: level.Level,
+			Lower: math.Round(math.Max(0, total-level.Z*width)*100) / 100,
+			Upper: math.Round(math.Max(0, total+level.Z*width)*100) / 100,
+		}
+	}
+	return intervals
+}
+
+// ValidIntervals reports whether intervals has a band for every level, each
+// containing total and nested inside the wider ones
+func ValidIntervals(total float64, intervals []Interval) bool {
+	if len(intervals) != len(IntervalLevels) {
+		return false
+	}
+	lower, upper := total, total
+	for i, level := range IntervalLevels {
+		interval := intervals[i]
+		if interval.Level != level.Level || interval.Lower > lower || interval.Upper < upper {
+			return false
+		}
+		lower, upper = interval.Lower, interval.Upper
+	}
+	return true
+}
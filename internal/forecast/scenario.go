@@ -0,0 +1,81 @@
+package forecast
+
+import (
+	"math"
+	"time"
+)
+
+// Adjustment is a planned change to sales over a range of days, such as a
+// promotion or a marketing push
+type Adjustment struct {
+	// Start and End are the first and last day, inclusive
+	Start time.Time
+	End   time.Time
+	// Multiplier scales sales on the covered days, e.g. 1.15 for +15%
+	Multiplier float64
+}
+
+// Scenario is a set of adjustments forecast on top of the baseline
+type Scenario []Adjustment
+
+// Factor returns how much the scenario scales the period of the given time
+// period starting at start. Each adjustment counts in proportion to the
+// days of the period it covers, and overlapping adjustments compound.
+func (s Scenario) Factor(start time.Time, timePeriod string) float64 {
+	end := Step(start, timePeriod, 1)
+	days := end.Sub(start).Hours() / 24
+	if days <= 0 {
+		return 1
+	}
+
+	factor := 1.0
+	for _, adjustment := range s {
+		from := maxTime(start, adjustment.Start)
+		to := minTime(end, adjustment.End.AddDate(0, 0, 1))
+		if covered := to.Sub(from).Hours() / 24; covered > 0 {
+			factor *= 1 + (adjustment.Multiplier-1)*covered/days
+		}
+	}
+	return factor
+}
+
+// Apply scales each forecast total and its bands by the scenario's factor
+// for its period, leaving points whose period can't be parsed unchanged
+func (s Scenario) Apply(points []Point, timePeriod string) []Point {
+	result := make([]Point, len(points))
+	for i, point := range points {
+		result[i] = point
+		date, err := ParsePeriod(point.Period)
+		if err != nil {
+			continue
+		}
+		f := s.Factor(date, timePeriod)
+		if f == 1 {
+			continue
+		}
+		result[i].Total = math.Round(point.Total*f*100) / 100
+		result[i].Intervals = make([]Interval, len(point.Intervals))
+		for j, interval := range point.Intervals {
+			result[i].Intervals[j] = Interval{
+				Level: interval.Level,
+				Lower: math.Round(interval.Lower*f*100) / 100,
+				Upper: math.Round(interval.Upper*f*100) / 100,
+			}
+		}
+	}
+	return result
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
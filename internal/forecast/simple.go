@@ -0,0 +1,210 @@
+package forecast
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/calendar"
+)
+
+// Point represents a single period/total pair in a time series
+type Point struct {
+	Period string
+	Total  float64
+	// Intervals are the confidence bands of a forecast point
+	Intervals []Interval
+}
+
+// Clock provides the current time so forecasts can be anchored deterministically
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is a Clock backed by time.Now
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock returns a Clock that reports the real current time
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+// FixedClock is a Clock that always reports the same time
+type FixedClock time.Time
+
+// Now returns the fixed time
+func (c FixedClock) Now() time.Time { return time.Time(c) }
+
+// SimpleForecaster produces a moving-average-with-trend forecast with a small
+// amount of random noise, or the forecast of another Strategy when one is
+// set. It is used when the LLM is unavailable.
+type SimpleForecaster struct {
+	rng      *rand.Rand
+	clock    Clock
+	strategy Strategy
+}
+
+// NewSimpleForecaster returns a SimpleForecaster using the given source of
+// randomness and clock. A nil rng is seeded from the current time and a nil
+// clock falls back to the system clock.
+func NewSimpleForecaster(rng *rand.Rand, clock Clock) *SimpleForecaster {
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if clock == nil {
+		clock = SystemClock()
+	}
+	return &SimpleForecaster{rng: rng, clock: clock}
+}
+
+// WithStrategy makes the forecaster use strategy instead of the moving
+// average. A nil strategy restores the moving average.
+func (f *SimpleForecaster) WithStrategy(strategy Strategy) *SimpleForecaster {
+	f.strategy = strategy
+	return f
+}
+
+// Forecast returns the next periods points after the latest period in
+// history, with confidence bands from the history's spread
+func (f *SimpleForecaster) Forecast(history []Point, timePeriod string, periods int) []Point {
+	// Anchor the forecast after the latest period, or now if none can be parsed
+	start, ok := LatestPeriod(history)
+	if !ok {
+		start = f.clock.Now().UTC().Truncate(24 * time.Hour)
+	}
+
+	var totals []float64
+	if dated, ok := f.strategy.(DatedStrategy); ok {
+		dates := make([]time.Time, periods)
+		for i := range dates {
+			dates[i] = Step(start, timePeriod, i+1)
+		}
+		totals = dated.PredictDates(history, dates)
+	} else if f.strategy != nil {
+		values := make([]float64, len(history))
+		for i, point := range history {
+			values[i] = point.Total
+		}
+		totals = f.strategy.Predict(values, periods)
+	} else {
+		totals = f.movingAverage(history, periods)
+	}
+
+	spread := Spread(history)
+	forecast := make([]Point, 0, periods)
+	for i, total := range totals {
+		total = math.Round(math.Max(0, total)*100) / 100
+		forecast = append(forecast, Point{
+			Period:    FormatPeriod(Step(start, timePeriod, i+1), timePeriod),
+			Total:     total,
+			Intervals: Intervals(total, spread, i+1),
+		})
+	}
+
+	return forecast
+}
+
+// movingAverage extends the trend between the latest two windows of history
+// with +/- 5% noise
+func (f *SimpleForecaster) movingAverage(history []Point, periods int) []float64 {
+	// Average of the most recent window and the one before it give the level and trend
+	window := 3
+	if len(history) < window {
+		window = len(history)
+	}
+
+	var level, trend float64
+	if window > 0 {
+		level = average(history[len(history)-window:])
+		if len(history) >= 2*window {
+			previous := average(history[len(history)-2*window : len(history)-window])
+			trend = (level - previous) / float64(window)
+		}
+	}
+
+	totals := make([]float64, 0, periods)
+	for i := 1; i <= periods; i++ {
+		// +/- 5% noise around the trended level
+		noise := 1 + (f.rng.Float64()*0.1 - 0.05)
+		totals = append(totals, (level+trend*float64(i))*noise)
+	}
+	return totals
+}
+
+// ParsePeriod parses a period in YYYY-MM-DD or YYYY-MM format, an ISO week
+// (2024-W37), or a fiscal period of the default fiscal calendar (FY2025-P01),
+// returning the first day of weeks and fiscal periods
+func ParsePeriod(period string) (time.Time, error) {
+	switch {
+	case strings.Contains(period, "-W"):
+		return calendar.ParseISOWeek(period)
+	case strings.HasPrefix(period, "FY"):
+		fiscal, _ := calendar.DefaultFiscal()
+		return fiscal.Parse(period)
+	}
+	date, err := time.Parse("2006-01-02", period)
+	if err != nil {
+		date, err = time.Parse("2006-01", period)
+	}
+	return date, err
+}
+
+// FormatPeriod labels a forecast period: ISO weeks as 2024-W37, fiscal
+// periods as FY2025-P01, and other periods as YYYY-MM-DD
+func FormatPeriod(date time.Time, timePeriod string) string {
+	switch timePeriod {
+	case "iso_week":
+		return calendar.ISOWeekKey(date)
+	case "fiscal":
+		fiscal, _ := calendar.DefaultFiscal()
+		return fiscal.Key(date)
+	default:
+		return date.Format("2006-01-02")
+	}
+}
+
+// LatestPeriod returns the latest parseable period in the series
+func LatestPeriod(points []Point) (time.Time, bool) {
+	var latest time.Time
+	found := false
+	for _, point := range points {
+		date, err := ParsePeriod(point.Period)
+		if err != nil {
+			continue
+		}
+		if !found || date.After(latest) {
+			latest = date
+			found = true
+		}
+	}
+	return latest, found
+}
+
+// Step returns the date n periods of the given time period after start
+func Step(start time.Time, timePeriod string, n int) time.Time {
+	switch timePeriod {
+	case "day":
+		return start.AddDate(0, 0, n)
+	case "week", "iso_week":
+		return start.AddDate(0, 0, 7*n)
+	case "fiscal":
+		fiscal, _ := calendar.DefaultFiscal()
+		return fiscal.Step(start, n)
+	default:
+		return start.AddDate(0, n, 0)
+	}
+}
+
+func average(points []Point) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, point := range points {
+		sum += point.Total
+	}
+	return sum / float64(len(points))
+}
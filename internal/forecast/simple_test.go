@@ -0,0 +1,51 @@
+package forecast
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSimpleForecasterReproducible(t *testing.T) {
+	clock := FixedClock(time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC))
+	history := []Point{
+		{Period: "2024-01-01", Total: 100},
+		{Period: "2024-01-02", Total: 120},
+		{Period: "2024-01-03", Total: 90},
+		{Period: "2024-01-04", Total: 130},
+		{Period: "2024-01-05", Total: 110},
+		{Period: "2024-01-06", Total: 140},
+	}
+
+	for _, test := range []struct {
+		name      string
+		history   []Point
+		wantFirst string
+	}{
+		// Anchored after the latest period
+		{"history", history, "2024-01-07"},
+		// Anchored on the clock when no period can be parsed
+		{"unparsable periods", []Point{{Period: "last week", Total: 100}}, "2024-03-16"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			forecast := func(seed int64) []Point {
+				return NewSimpleForecaster(rand.New(rand.NewSource(seed)), clock).Forecast(test.history, "day", 7)
+			}
+
+			first, second := forecast(42), forecast(42)
+			if !reflect.DeepEqual(first, second) {
+				t.Fatalf("same seed and clock gave different forecasts:\n%v\n%v", first, second)
+			}
+			if len(first) != 7 {
+				t.Fatalf("got %d points, want 7", len(first))
+			}
+			if first[0].Period != test.wantFirst {
+				t.Errorf("first period is %s, want %s", first[0].Period, test.wantFirst)
+			}
+			if reflect.DeepEqual(first, forecast(7)) {
+				t.Error("a different seed gave the same noise")
+			}
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package forecast
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Method names a forecasting strategy
+type Method string
+
+// Supported methods. MethodMovingAverage is the original moving average with
+// trend and noise and is used when no method is given.
+const (
+	MethodMovingAverage         Method = "moving_average"
+	MethodNaive                 Method = "naive"
+	MethodSeasonalNaive         Method = "seasonal_naive"
+	MethodWeightedMovingAverage Method = "weighted_moving_average"
+	MethodExponential           Method = "exponential_smoothing"
+	MethodDoubleExponential     Method = "double_exponential_smoothing"
+	MethodTripleExponential     Method = "triple_exponential_smoothing"
+	MethodAdditive              Method = "additive"
+)
+
+// Methods lists every supported method
+var Methods = []Method{
+	MethodMovingAverage,
+	MethodNaive,
+	MethodSeasonalNaive,
+	MethodWeightedMovingAverage,
+	MethodExponential,
+	MethodDoubleExponential,
+	MethodTripleExponential,
+	MethodAdditive,
+	MethodCroston,
+	MethodTSB,
+	MethodARIMA,
+}
+
+// Default smoothing parameters
+const (
+	DefaultAlpha  = 0.3
+	DefaultBeta   = 0.1
+	DefaultGamma  = 0.1
+	DefaultWindow = 3
+)
+
+// Options configures a strategy. Zero values select the defaults: the
+// smoothing factors above, a window of DefaultWindow, and the season length
+// of the forecast's time period.
+type Options struct {
+	Method Method
+	// Alpha, Beta and Gamma smooth the level, trend and season, in (0, 1]
+	Alpha float64
+	Beta  float64
+	Gamma float64
+	// Window is the number of periods the weighted moving average covers
+	Window int
+	// SeasonLength is the number of periods in a season
+	SeasonLength int
+	// Holidays are the holidays the additive model estimates effects for
+	Holidays []Holiday
+	// AROrder is the number of lagged values the ARIMA model uses; zero
+	// chooses it by AIC
+	AROrder int
+	// Differencing is how many times the ARIMA model differences the
+	// series; nil chooses it from the series
+	Differencing *int
+}
+
+// Strategy predicts the next periods values following a series
+type Strategy interface {
+	Predict(values []float64, periods int) []float64
+}
+
+// DatedStrategy is a Strategy that uses the dates of the history and of the
+// forecast periods. The forecaster prefers PredictDates when it's available.
+type DatedStrategy interface {
+	Strategy
+	PredictDates(history []Point, dates []time.Time) []float64
+}
+
+// NewStrategy returns the strategy for the options, or nil for
+// MethodMovingAverage, which the forecaster runs itself
+func NewStrategy(options Options, timePeriod string) (Strategy, error) {
+	for _, factor := range []struct {
+		name  string
+		value float64
+	}{{"alpha", options.Alpha}, {"beta", options.Beta}, {"gamma", options.Gamma}} {
+		if factor.value < 0 || factor.value > 1 {
+			return nil, fmt.Errorf("%s must be between 0 and 1", factor.name)
+		}
+	}
+	if options.Window < 0 {
+		return nil, fmt.Errorf("window must not be negative")
+	}
+	if options.SeasonLength < 0 {
+		return nil, fmt.Errorf("seasonLength must not be negative")
+	}
+	if options.AROrder < 0 || options.AROrder > MaxAROrder {
+		return nil, fmt.Errorf("arOrder must be between 0 and %d", MaxAROrder)
+	}
+	if d := options.Differencing; d != nil && (*d < 0 || *d > MaxDifferencing) {
+		return nil, fmt.Errorf("differencing must be between 0 and %d", MaxDifferencing)
+	}
+
+	alpha := orDefault(options.Alpha, DefaultAlpha)
+	beta := orDefault(options.Beta, DefaultBeta)
+	gamma := orDefault(options.Gamma, DefaultGamma)
+	window := options.Window
+	if window == 0 {
+		window = DefaultWindow
+	}
+	season := options.SeasonLength
+	if season == 0 {
+		season = SeasonLength(timePeriod)
+	}
+
+	switch Method(strings.ToLower(string(options.Method))) {
+	case "", MethodMovingAverage:
+		return nil, nil
+	case MethodNaive:
+		return naive{}, nil
+	case MethodSeasonalNaive:
+		return seasonalNaive{season: season}, nil
+	case MethodWeightedMovingAverage:
+		return weightedMovingAverage{window: window}, nil
+	case MethodExponential:
+		return exponential{alpha: alpha}, nil
+	case MethodDoubleExponential:
+		return doubleExponential{alpha: alpha, beta: beta}, nil
+	case MethodTripleExponential:
+		return tripleExponential{alpha: alpha, beta: beta, gamma: gamma, season: season}, nil
+	case MethodCroston:
+		return croston{alpha: alpha}, nil
+	case MethodTSB:
+		return tsb{alpha: alpha, beta: beta}, nil
+	case MethodAdditive:
+		return additive{timePeriod: timePeriod, holidays: options.Holidays}, nil
+	case MethodARIMA:
+		return arima{order: options.AROrder, differencing: options.Differencing}, nil
+	default:
+		return nil, fmt.Errorf("unknown forecast method %q", options.Method)
+	}
+}
+
+// SeasonLength returns the number of periods in a year-long season for day
+// (a week), week, month and fiscal periods
+func SeasonLength(timePeriod string) int {
+	switch timePeriod {
+	case "day":
+		return 7
+	case "week", "iso_week":
+		return 52
+	default:
+		return 12
+	}
+}
+
+func orDefault(value, fallback float64) float64 {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+// repeat returns periods copies of value
+func repeat(value float64, periods int) []float64 {
+	result := make([]float64, periods)
+	for i := range result {
+		result[i] = value
+	}
+	return result
+}
+
+// naive repeats the latest value
+type naive struct{}
+
+func (naive) Predict(values []float64, periods int) []float64 {
+	if len(values) == 0 {
+		return repeat(0, periods)
+	}
+	return repeat(values[len(values)-1], periods)
+}
+
+// seasonalNaive repeats the latest season, or the latest value when the
+// series is shorter than a season
+type seasonalNaive struct {
+	season int
+}
+
+func (s seasonalNaive) Predict(values []float64, periods int) []float64 {
+	if len(values) < s.season {
+		return naive{}.Predict(values, periods)
+	}
+	last := values[len(values)-s.season:]
+	result := make([]float64, periods)
+	for i := range result {
+		result[i] = last[i%s.season]
+	}
+	return result
+}
+
+// weightedMovingAverage repeats the average of the latest window, weighting
+// each value by its recency
+type weightedMovingAverage struct {
+	window int
+}
+
+func (w weightedMovingAverage) Predict(values []float64, periods int) []float64 {
+	window := min(w.window, len(values))
+	recent := values[len(values)-window:]
+	var sum, weights float64
+	for i, value := range recent {
+		weight := float64(i + 1)
+		sum += value * weight
+		weights += weight
+	}
+	if weights == 0 {
+		return repeat(0, periods)
+	}
+	return repeat(sum/weights, periods)
+}
+
+// exponential is single exponential smoothing: a flat forecast at the
+// smoothed level
+type exponential struct {
+	alpha float64
+}
+
+func (e exponential) Predict(values []float64, periods int) []float64 {
+	if len(values) == 0 {
+		return repeat(0, periods)
+	}
+	level := values[0]
+	for _, value := range values[1:] {
+		level = e.alpha*value + (1-e.alpha)*level
+	}
+	return repeat(level, periods)
+}
+
+// doubleExponential is Holt's linear trend method
+type doubleExponential struct {
+	alpha, beta float64
+}
+
+func (d doubleExponential) Predict(values []float64, periods int) []float64 {
+	if len(values) < 2 {
+		return naive{}.Predict(values, periods)
+	}
+	level, trend := values[0], values[1]-values[0]
+	for _, value := range values[1:] {
+		previous := level
+		level = d.alpha*value + (1-d.alpha)*(level+trend)
+		trend = d.beta*(level-previous) + (1-d.beta)*trend
+	}
+	result := make([]float64, periods)
+	for i := range result {
+		result[i] = level + trend*float64(i+1)
+	}
+	return result
+}
+
+// tripleExponential is the additive Holt-Winters method. It needs two full
+// seasons to initialize and falls back to Holt's method otherwise.
+type tripleExponential struct {
+	alpha, beta, gamma float64
+	season             int
+}
+
+func (t tripleExponential) Predict(values []float64, periods int) []float64 {
+	n := t.season
+	if n < 2 || len(values) < 2*n {
+		return doubleExponential{alpha: t.alpha, beta: t.beta}.Predict(values, periods)
+	}
+
+	// Initialize from the first two seasons
+	first, second := mean(values[:n]), mean(values[n:2*n])
+	level := first
+	trend := (second - first) / float64(n)
+	seasonal := make([]float64, n)
+	for i := range seasonal {
+		seasonal[i] = values[i] - first
+	}
+
+	for i := n; i < len(values); i++ {
+		value := values[i]
+		s := seasonal[i%n]
+		previous := level
+		level = t.alpha*(value-s) + (1-t.alpha)*(level+trend)
+		trend = t.beta*(level-previous) + (1-t.beta)*trend
+		seasonal[i%n] = t.gamma*(value-level) + (1-t.gamma)*s
+	}
+
+	result := make([]float64, periods)
+	for i := range result {
+		result[i] = level + trend*float64(i+1) + seasonal[(len(values)+i)%n]
+	}
+	return result
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,102 @@
+package forecast
+
+import (
+	"math"
+	"time"
+)
+
+// TradingCalendar weights the days of a month by how much trading they
+// allow: weekdays count fully, weekend days count WeekendWeight, and closure
+// dates don't count
+type TradingCalendar struct {
+	WeekendWeight float64
+	// Closures holds closure dates as YYYY-MM-DD
+	Closures map[string]bool
+}
+
+// Days returns the weighted number of trading days in the month containing
+// date
+func (c TradingCalendar) Days(date time.Time) float64 {
+	start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var days float64
+	for day := start; day.Month() == start.Month(); day = day.AddDate(0, 0, 1) {
+		switch {
+		case c.Closures[day.Format("2006-01-02")]:
+		case day.Weekday() == time.Saturday || day.Weekday() == time.Sunday:
+			days += c.WeekendWeight
+		default:
+			days++
+		}
+	}
+	return days
+}
+
+// TradingDayAdjustment converts monthly totals to and from an average month
+// so months with more trading days don't look like growth
+type TradingDayAdjustment struct {
+	calendar TradingCalendar
+	average  float64
+}
+
+// NewTradingDayAdjustment returns the adjustment for a monthly history and
+// the periods forecast after it, normalizing to the average trading days
+// over both
+func NewTradingDayAdjustment(calendar TradingCalendar, history []Point, periods int) TradingDayAdjustment {
+	var total float64
+	var months int
+	for _, point := range history {
+		if date, err := ParsePeriod(point.Period); err == nil {
+			total += calendar.Days(date)
+			months++
+		}
+	}
+	if latest, ok := LatestPeriod(history); ok {
+		for i := 1; i <= periods; i++ {
+			total += calendar.Days(Step(latest, "month", i))
+			months++
+		}
+	}
+
+	adjustment := TradingDayAdjustment{calendar: calendar}
+	if months > 0 {
+		adjustment.average = total / float64(months)
+	}
+	return adjustment
+}
+
+// Normalize scales each total to the average month
+func (a TradingDayAdjustment) Normalize(points []Point) []Point {
+	return a.scale(points, func(days float64) float64 { return a.average / days })
+}
+
+// Apply scales each normalized total back to its month's trading days
+func (a TradingDayAdjustment) Apply(points []Point) []Point {
+	return a.scale(points, func(days float64) float64 { return days / a.average })
+}
+
+// scale multiplies each total and its bands by factor(trading days), leaving
+// points whose period can't be parsed or whose month has no trading days
+// unchanged
+func (a TradingDayAdjustment) scale(points []Point, factor func(days float64) float64) []Point {
+	result := make([]Point, len(points))
+	for i, point := range points {
+		result[i] = point
+		date, err := ParsePeriod(point.Period)
+		if err != nil || a.average == 0 {
+			continue
+		}
+		if days := a.calendar.Days(date); days > 0 {
+			f := factor(days)
+			result[i].Total = math.Round(point.Total*f*100) / 100
+			result[i].Intervals = make([]Interval, len(point.Intervals))
+			for j, interval := range point.Intervals {
+				result[i].Intervals[j] = Interval{
+					Level: interval.Level,
+					Lower: math.Round(interval.Lower*f*100) / 100,
+					Upper: math.Round(interval.Upper*f*100) / 100,
+				}
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,120 @@
+package fx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// recentRatesTTL is how long the rates of today and yesterday are cached.
+// Rates services publish a day's rates during the day after, so until then
+// they can still change.
+const recentRatesTTL = time.Hour
+
+// Cache is a provider that keeps the rates of each base currency and day it
+// has fetched. Rates of earlier days don't change and are kept for as long as
+// the cache.
+type Cache struct {
+	provider Provider
+	now      func() time.Time
+
+	mu    sync.Mutex
+	rates map[cacheKey]cachedRates
+}
+
+// cacheKey identifies the rates of a base currency on a day
+type cacheKey struct {
+	base string
+	day  string
+}
+
+// cachedRates are rates and when they were fetched
+type cachedRates struct {
+	rates     map[string]float64
+	fetchedAt time.Time
+}
+
+// NewCache returns provider with its rates cached
+func NewCache(provider Provider) *Cache {
+	return &Cache{provider: provider, now: time.Now, rates: make(map[cacheKey]cachedRates)}
+}
+
+// Rates implements Provider
+func (c *Cache) Rates(ctx context.Context, base string, date time.Time) (map[string]float64, error) {
+	key := cacheKey{base: base, day: date.Format("2006-01-02")}
+	now := c.now()
+
+	c.mu.Lock()
+	cached, ok := c.rates[key]
+	c.mu.Unlock()
+	if ok && (settled(date, now) || now.Sub(cached.fetchedAt) < recentRatesTTL) {
+		return cached.rates, nil
+	}
+
+	rates, err := c.provider.Rates(ctx, base, date)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.rates[key] = cachedRates{rates: rates, fetchedAt: now}
+	c.mu.Unlock()
+	return rates, nil
+}
+
+// prefetchLookback is how many days before a prefetched range are fetched
+// with it, so the range's first days take the rates of the last day before
+// them with rates when they have none themselves
+const prefetchLookback = 7
+
+// Prefetch implements Prefetcher when the cached provider is a RangeProvider,
+// fetching the rates of every day from start to end in one request unless
+// they're all cached. Days without rates take those of the last day before
+// them with rates, as the rates service answers for a single day.
+func (c *Cache) Prefetch(ctx context.Context, base string, start, end time.Time) error {
+	ranged, ok := c.provider.(RangeProvider)
+	if !ok {
+		return nil
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	missing := false
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		cached, ok := c.rates[cacheKey{base: base, day: day.Format("2006-01-02")}]
+		if !ok || (!settled(day, now) && now.Sub(cached.fetchedAt) >= recentRatesTTL) {
+			missing = true
+			break
+		}
+	}
+	c.mu.Unlock()
+	if !missing {
+		return nil
+	}
+
+	from := start.AddDate(0, 0, -prefetchLookback)
+	days, err := ranged.RatesBetween(ctx, base, from, end)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var last map[string]float64
+	for day := from; !day.After(end); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		if rates, ok := days[key]; ok {
+			last = rates
+		}
+		if last != nil && !day.Before(start) {
+			c.rates[cacheKey{base: base, day: key}] = cachedRates{rates: last, fetchedAt: now}
+		}
+	}
+	return nil
+}
+
+// settled reports whether the rates of date can no longer change, as it was
+// before yesterday
+func settled(date, now time.Time) bool {
+	yesterday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+	return date.Before(yesterday)
+}
@@ -0,0 +1,48 @@
+package fx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConverterPrefetchesRange(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		// Friday's rates cover the weekend at the start of the range
+		w.Write([]byte(`{"base": "USD", "rates": {
+			"2026-03-06": {"eur": 0.5},
+			"2026-03-09": {"EUR": 0.8},
+			"2026-03-10": {"EUR": 0.4}
+		}}`))
+	}))
+	defer server.Close()
+
+	start := time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	converter := NewConverter(NewCache(NewHTTP(server.URL)), "USD").Prefetching(start, end)
+	for _, test := range []struct {
+		day  int
+		want float64
+	}{
+		{7, 20},
+		{8, 20},
+		{9, 12.5},
+		{10, 25},
+	} {
+		got, err := converter.Convert(context.Background(), 10, "EUR", time.Date(2026, 3, test.day, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("March %d: got %v, want %v", test.day, got, test.want)
+		}
+	}
+
+	if want := "/2026-02-28..2026-03-10"; len(requests) != 1 || requests[0] != want {
+		t.Errorf("got requests %v, want just %s", requests, want)
+	}
+}
@@ -0,0 +1,136 @@
+// Package fx provides the exchange rates reports convert sales amounts to a
+// reporting currency with. Providers are pluggable: a static table of rates
+// from a YAML file, or daily rates from an HTTP rates service, both behind a
+// cache of each day's rates.
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider returns exchange rates
+type Provider interface {
+	// Rates returns how many units of each currency one unit of base
+	// bought on date, including base itself at 1
+	Rates(ctx context.Context, base string, date time.Time) (map[string]float64, error)
+}
+
+// RangeProvider is a provider that can also fetch the rates of every day in
+// a range at once
+type RangeProvider interface {
+	Provider
+	// RatesBetween returns the rates of the days from start to end that have
+	// rates, keyed by YYYY-MM-DD. Days without rates, such as weekends, are
+	// left out.
+	RatesBetween(ctx context.Context, base string, start, end time.Time) (map[string]map[string]float64, error)
+}
+
+// Prefetcher is a provider that can fetch the rates of a range of days ahead
+// of their use in fewer requests than a day at a time
+type Prefetcher interface {
+	Prefetch(ctx context.Context, base string, start, end time.Time) error
+}
+
+// currencyCode matches ISO 4217 currency codes
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ParseCurrency returns code upper-cased, or an error when it isn't an ISO
+// 4217 currency code
+func ParseCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !currencyCode.MatchString(code) {
+		return "", fmt.Errorf("invalid currency %q, use an ISO 4217 code such as USD", code)
+	}
+	return code, nil
+}
+
+// ErrNoRate is returned when there's no rate to convert a currency with
+var ErrNoRate = errors.New("no exchange rate")
+
+// Converter converts amounts into one currency with a provider's rates. A
+// nil Converter leaves amounts as they are. It isn't safe for concurrent use.
+type Converter struct {
+	provider Provider
+	to       string
+	// start and end are the range of days prefetched before the first
+	// conversion, when the provider is a Prefetcher
+	start, end time.Time
+	prefetched bool
+}
+
+// NewConverter returns a converter into the currency to
+func NewConverter(provider Provider, to string) *Converter {
+	return &Converter{provider: provider, to: to}
+}
+
+// Prefetching makes the converter prefetch the rates of the days from start
+// to end the first time it needs a rate, when its provider is a Prefetcher,
+// and returns it
+func (c *Converter) Prefetching(start, end time.Time) *Converter {
+	if c != nil {
+		c.start, c.end = start, end
+	}
+	return c
+}
+
+// Convert converts an amount in the currency from on date
+func (c *Converter) Convert(ctx context.Context, amount float64, from string, date time.Time) (float64, error) {
+	if c == nil || from == c.to {
+		return amount, nil
+	}
+	if prefetcher, ok := c.provider.(Prefetcher); ok && !c.prefetched && !c.start.IsZero() {
+		c.prefetched = true
+		if err := prefetcher.Prefetch(ctx, c.to, c.start, c.end); err != nil {
+			return 0, err
+		}
+	}
+	rates, err := c.provider.Rates(ctx, c.to, date)
+	if err != nil {
+		return 0, err
+	}
+	rate, ok := rates[from]
+	if !ok || rate <= 0 {
+		return 0, fmt.Errorf("%w from %s to %s on %s", ErrNoRate, from, c.to, date.Format("2006-01-02"))
+	}
+	return amount / rate, nil
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultProvider Provider
+	defaultErr      error
+)
+
+// Default returns the provider of the server: daily rates from the service at
+// FX_RATES_URL when it is set, or otherwise the static rates of FX_RATES_PATH,
+// which defaults to config/fx_rates.yaml. It is cached with NewCache and
+// loaded once; if the rates file is invalid the error is returned with a
+// provider that only knows each currency's rate to itself.
+func Default() (Provider, error) {
+	defaultOnce.Do(func() {
+		if url := os.Getenv("FX_RATES_URL"); url != "" {
+			defaultProvider = NewCache(NewHTTP(url))
+			return
+		}
+
+		name := os.Getenv("FX_RATES_PATH")
+		if name == "" {
+			name = "config/fx_rates.yaml"
+		}
+		static, err := LoadStatic(name)
+		if err != nil {
+			log.Printf("Warning: invalid FX rates %s, converting no currencies: %v", name, err)
+			static, defaultErr = &Static{}, err
+		}
+		defaultProvider = NewCache(static)
+	})
+	return defaultProvider, defaultErr
+}
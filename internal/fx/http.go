@@ -0,0 +1,100 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTP is a provider of daily rates from a Frankfurter-compatible rates
+// service, which answers GET {url}/{YYYY-MM-DD}?base={base} with the rates of
+// that day, or of the last day before it with rates, and
+// GET {url}/{YYYY-MM-DD}..{YYYY-MM-DD}?base={base} with the rates of each day
+// in the range that has them
+type HTTP struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTP returns a provider of the rates service at url
+func NewHTTP(url string) *HTTP {
+	return &HTTP{url: strings.TrimSuffix(url, "/"), client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// ratesResponse is the body of a rates service response
+type ratesResponse struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// rangeResponse is the body of a rates service response for a range of days
+type rangeResponse struct {
+	Base  string                        `json:"base"`
+	Rates map[string]map[string]float64 `json:"rates"`
+}
+
+// Rates implements Provider
+func (h *HTTP) Rates(ctx context.Context, base string, date time.Time) (map[string]float64, error) {
+	day := date.Format("2006-01-02")
+	var body ratesResponse
+	if err := h.get(ctx, base, day, &body); err != nil {
+		return nil, err
+	}
+	if body.Base != "" && !strings.EqualFold(body.Base, base) {
+		return nil, fmt.Errorf("rates service returned %s rates for %s", body.Base, base)
+	}
+	return withBase(body.Rates, base), nil
+}
+
+// RatesBetween implements RangeProvider
+func (h *HTTP) RatesBetween(ctx context.Context, base string, start, end time.Time) (map[string]map[string]float64, error) {
+	dates := start.Format("2006-01-02") + ".." + end.Format("2006-01-02")
+	var body rangeResponse
+	if err := h.get(ctx, base, dates, &body); err != nil {
+		return nil, err
+	}
+	if body.Base != "" && !strings.EqualFold(body.Base, base) {
+		return nil, fmt.Errorf("rates service returned %s rates for %s", body.Base, base)
+	}
+
+	days := make(map[string]map[string]float64, len(body.Rates))
+	for day, rates := range body.Rates {
+		days[day] = withBase(rates, base)
+	}
+	return days, nil
+}
+
+// get decodes the service's base rates on dates, a day or a range of days,
+// into body
+func (h *HTTP) get(ctx context.Context, base, dates string, body any) error {
+	endpoint := fmt.Sprintf("%s/%s?base=%s", h.url, dates, url.QueryEscape(base))
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	response, err := h.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s rates: %v", base, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("rates service returned %s for %s rates on %s", response.Status, base, dates)
+	}
+	if err := json.NewDecoder(response.Body).Decode(body); err != nil {
+		return fmt.Errorf("failed to decode %s rates: %v", base, err)
+	}
+	return nil
+}
+
+// withBase returns rates with their currencies upper-cased and base at 1
+func withBase(rates map[string]float64, base string) map[string]float64 {
+	result := map[string]float64{base: 1}
+	for currency, rate := range rates {
+		result[strings.ToUpper(currency)] = rate
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package fx
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bokor/craft-demo/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Static is a provider with the same rates on every date, for deployments
+// without a rates service
+type Static struct {
+	// Base is the currency the rates are quoted against
+	Base string `yaml:"base"`
+	// Table holds how many units of each currency one unit of Base buys
+	Table map[string]float64 `yaml:"rates"`
+}
+
+// LoadStatic reads static rates from the named YAML file, using the embedded
+// copy when the file doesn't exist
+func LoadStatic(name string) (*Static, error) {
+	contents, err := config.Read(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FX rates: %v", err)
+	}
+	return ParseStatic(contents)
+}
+
+// ParseStatic parses and validates static rates
+func ParseStatic(contents []byte) (*Static, error) {
+	var static Static
+	if err := yaml.Unmarshal(contents, &static); err != nil {
+		return nil, fmt.Errorf("failed to parse FX rates: %v", err)
+	}
+
+	base, err := ParseCurrency(static.Base)
+	if err != nil {
+		return nil, fmt.Errorf("base: %v", err)
+	}
+	rates := map[string]float64{base: 1}
+	for code, rate := range static.Table {
+		currency, err := ParseCurrency(code)
+		if err != nil {
+			return nil, err
+		}
+		if rate <= 0 {
+			return nil, fmt.Errorf("the rate of %s must be positive", currency)
+		}
+		rates[currency] = rate
+	}
+	return &Static{Base: base, Table: rates}, nil
+}
+
+// Rates implements Provider, crossing the rates through Base
+func (s *Static) Rates(_ context.Context, base string, _ time.Time) (map[string]float64, error) {
+	baseRate, ok := s.Table[base]
+	if !ok {
+		return map[string]float64{base: 1}, nil
+	}
+
+	rates := make(map[string]float64, len(s.Table))
+	for currency, rate := range s.Table {
+		rates[currency] = rate / baseRate
+	}
+	return rates, nil
+}
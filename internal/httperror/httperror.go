@@ -0,0 +1,120 @@
+package httperror
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/errortracker"
+	"github.com/labstack/echo/v4"
+)
+
+// Envelope is the JSON body of every error response
+type Envelope struct {
+	Error Body `json:"error"`
+}
+
+// Body describes an error in the envelope
+type Body struct {
+	// Code is a stable, machine-readable error code derived from the status,
+	// e.g. bad_request or not_found
+	Code string `json:"code"`
+	// Message is a human-readable description of the error
+	Message string `json:"message"`
+	// RequestID matches the X-Request-Id response header
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// JSON writes an error response in the envelope
+func JSON(c echo.Context, status int, message string) error {
+	return c.JSON(status, New(c, status, message))
+}
+
+// New returns the envelope for an error response
+func New(c echo.Context, status int, message string) Envelope {
+	return Envelope{Error: Body{
+		Code:      Code(status),
+		Message:   message,
+		RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+	}}
+}
+
+// Code returns the error code for an HTTP status, e.g. 404 is not_found
+func Code(status int) string {
+	if status == http.StatusInternalServerError {
+		return "internal_error"
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// Handler is an echo.HTTPErrorHandler that renders errors returned by
+// handlers and middleware (unknown routes, failed basic auth, ...) in the envelope
+func Handler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	status := http.StatusInternalServerError
+	message := "Internal server error"
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		status = httpError.Code
+		message = fmt.Sprint(httpError.Message)
+	} else {
+		log.Printf("Unhandled error: %v", err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = JSON(c, status, message)
+	}
+	if err != nil {
+		log.Printf("Failed to write error response: %v", err)
+	}
+}
+
+// Recover returns middleware that turns panics into a 500 in the envelope
+// and reports them, with their stack, to the error tracker
+func Recover(reporter errortracker.Reporter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) (err error) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				request := c.Request()
+				reporter.Report(errortracker.Event{
+					Message:   fmt.Sprintf("panic: %v", recovered),
+					Stack:     string(debug.Stack()),
+					RequestID: c.Response().Header().Get(echo.HeaderXRequestID),
+					Tags: map[string]string{
+						"method": request.Method,
+						"path":   c.Path(),
+					},
+					Timestamp: time.Now().UTC(),
+				})
+
+				if c.Response().Committed {
+					return
+				}
+				err = JSON(c, http.StatusInternalServerError, "Internal server error")
+			}()
+
+			return next(c)
+		}
+	}
+}
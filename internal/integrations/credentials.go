@@ -0,0 +1,189 @@
+package integrations
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Credentials are the OAuth tokens a source's account granted
+type Credentials struct {
+	// AccountID is the account in the source the tokens were granted for,
+	// e.g. a merchant
+	AccountID    string
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// CredentialsKey encrypts the OAuth tokens stored in integration_credentials
+// with AES-256-GCM
+type CredentialsKey struct {
+	aead cipher.AEAD
+}
+
+// ParseCredentialsKey returns the key encoded in value, 32 bytes in base64
+// (e.g. from openssl rand -base64 32)
+func ParseCredentialsKey(value string) (*CredentialsKey, error) {
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("credentials key must be 32 bytes in base64")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &CredentialsKey{aead: aead}, nil
+}
+
+// seal encrypts a token of source, which it is bound to, as the base64 of
+// its nonce and ciphertext
+func (k *CredentialsKey) seal(source, token string) (string, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(k.aead.Seal(nonce, nonce, []byte(token), []byte(source))), nil
+}
+
+// open decrypts a token sealed for source
+func (k *CredentialsKey) open(source, sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < k.aead.NonceSize() {
+		return "", errors.New("malformed token")
+	}
+	nonce, ciphertext := data[:k.aead.NonceSize()], data[k.aead.NonceSize():]
+	token, err := k.aead.Open(nil, nonce, ciphertext, []byte(source))
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+// Credentials returns the source's OAuth tokens, decrypted with key,
+// reporting false before the source is connected
+func (s *Store) Credentials(ctx context.Context, key *CredentialsKey) (Credentials, bool, error) {
+	var credentials Credentials
+	var accessToken, refreshToken string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT account_id, access_token, refresh_token, expires_at
+		FROM integration_credentials WHERE source = $1
+	`, s.source).Scan(&credentials.AccountID, &accessToken, &refreshToken, &credentials.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return credentials, false, nil
+	}
+	if err != nil {
+		return credentials, false, fmt.Errorf("failed to read %s credentials: %v", s.source, err)
+	}
+
+	if credentials.AccessToken, err = key.open(s.source, accessToken); err == nil {
+		credentials.RefreshToken, err = key.open(s.source, refreshToken)
+	}
+	if err != nil {
+		return Credentials{}, false, fmt.Errorf("failed to decrypt %s credentials, connect %s again: %v", s.source, s.source, err)
+	}
+	return credentials, true, nil
+}
+
+// SaveCredentials records the source's OAuth tokens, encrypted with key,
+// replacing any before. Connecting another account starts its sync over, as
+// the cursor was the previous account's.
+func (s *Store) SaveCredentials(ctx context.Context, key *CredentialsKey, credentials Credentials) error {
+	accessToken, err := key.seal(s.source, credentials.AccessToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s credentials: %v", s.source, err)
+	}
+	refreshToken, err := key.seal(s.source, credentials.RefreshToken)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s credentials: %v", s.source, err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRowContext(ctx, "SELECT account_id FROM integration_credentials WHERE source = $1 FOR UPDATE", s.source).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read %s credentials: %v", s.source, err)
+	}
+	if err == nil && previous != credentials.AccountID {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM integration_cursors WHERE source = $1", s.source); err != nil {
+			return fmt.Errorf("failed to reset %s sync cursor: %v", s.source, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO integration_credentials (source, account_id, access_token, refresh_token, expires_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (source) DO UPDATE
+			SET account_id = EXCLUDED.account_id, access_token = EXCLUDED.access_token,
+				refresh_token = EXCLUDED.refresh_token, expires_at = EXCLUDED.expires_at,
+				updated_at = EXCLUDED.updated_at
+	`, s.source, credentials.AccountID, accessToken, refreshToken, credentials.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save %s credentials: %v", s.source, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// SaveOAuthState records the state of an OAuth connection an admin started,
+// for ConsumeOAuthState
+func (s *Store) SaveOAuthState(ctx context.Context, state string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO integration_oauth_states (state, source, created_at) VALUES ($1, $2, NOW())
+	`, state, s.source)
+	if err != nil {
+		return fmt.Errorf("failed to save %s OAuth state: %v", s.source, err)
+	}
+	return nil
+}
+
+// ConsumeOAuthState deletes a state saved within ttl, reporting whether
+// there was one, so each state completes at most one connection. Expired
+// states are deleted with it.
+func (s *Store) ConsumeOAuthState(ctx context.Context, state string, ttl time.Duration) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM integration_oauth_states
+		WHERE state = $1 AND source = $2 AND created_at > NOW() - $3 * INTERVAL '1 second'
+	`, state, s.source, ttl.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to consume %s OAuth state: %v", s.source, err)
+	}
+	consumed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to consume %s OAuth state: %v", s.source, err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM integration_oauth_states WHERE created_at <= NOW() - $1 * INTERVAL '1 second'
+	`, ttl.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to delete expired OAuth states: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return consumed == 1, nil
+}
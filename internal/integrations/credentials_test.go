@@ -0,0 +1,37 @@
+package integrations
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestCredentialsKey(t *testing.T) {
+	key, err := ParseCredentialsKey(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := key.seal("square", "EAAA-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "EAAA-token") {
+		t.Fatalf("sealed token %q holds the plaintext", sealed)
+	}
+	if token, err := key.open("square", sealed); err != nil || token != "EAAA-token" {
+		t.Fatalf("open = %q, %v; want EAAA-token", token, err)
+	}
+	if _, err := key.open("shopify", sealed); err == nil {
+		t.Error("opened a token sealed for another source")
+	}
+	if _, err := key.open("square", "plaintext"); err == nil {
+		t.Error("opened a plaintext token")
+	}
+
+	for _, value := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParseCredentialsKey(value); err == nil {
+			t.Errorf("ParseCredentialsKey(%q) succeeded", value)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+// Package integrations saves sales from external systems, such as online
+// stores and payment processors, into the transaction tables the reports are
+// built from. Each system's connector maps its records to Transactions; the
+// Store links them to the rows they were saved as, so syncing a record again
+// updates it, and regenerates their data warehouse rows.
+package integrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/batch"
+)
+
+// Kinds of linked records the Store creates itself; connectors name the kinds
+// of their transactions
+const (
+	kindProduct  = "product"
+	kindCustomer = "customer"
+)
+
+// uncategorized is the category of products synced without one
+const uncategorized = "Uncategorized"
+
+// Transaction is a sale or refund from an external system, in the shape of a
+// sale_transactions row and its items
+type Transaction struct {
+	// Kind and ExternalID identify the transaction in its source, e.g. an
+	// order or a refund and its ID
+	Kind       string
+	ExternalID string
+	Date       time.Time
+	// Status is invoice, refund, or pending. Refund amounts are positive; the
+	// sales totals batch subtracts them.
+	Status string
+	// Currency is the ISO 4217 code of the amounts
+	Currency string
+	// Customer is nil for anonymous sales
+	Customer *Customer
+	Items    []Item
+}
+
+// Customer is a customer in an external system
+type Customer struct {
+	ExternalID string
+	FirstName  string
+	LastName   string
+	Email      string
+	Phone      string
+}
+
+// Item is a line of a transaction
+type Item struct {
+	Product  Product
+	Quantity int
+	// TotalAmount is what the line sold or was refunded for, after discounts
+	TotalAmount float64
+}
+
+// Product is a product in an external system. Products are matched by their
+// external ID, then by SKU, and created when neither matches.
+type Product struct {
+	ExternalID string
+	SKU        string
+	Name       string
+	// Category is the name of the product's category, created when missing,
+	// or empty for Uncategorized
+	Category string
+	// Price is the list price of a unit
+	Price float64
+}
+
+// Store saves the transactions of one source, recorded as sales of one
+// company
+type Store struct {
+	db        *sql.DB
+	source    string
+	companyID int
+}
+
+// NewStore returns the store of source's transactions on db, which are
+// recorded as sales of the company companyID
+func NewStore(db *sql.DB, source string, companyID int) *Store {
+	return &Store{db: db, source: source, companyID: companyID}
+}
+
+// Save creates or replaces a transaction and its items, with any customer and
+// products it introduces, and regenerates its data warehouse rows, all in one
+// database transaction. It returns the sale transaction's ID.
+func (s *Store) Save(ctx context.Context, transaction Transaction) (int, error) {
+	if len(transaction.Items) == 0 {
+		return 0, fmt.Errorf("%s %s %s has no items", s.source, transaction.Kind, transaction.ExternalID)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Webhooks and scheduled syncs can deliver the same record at once
+	if err := s.lock(ctx, tx, transaction.Kind, transaction.ExternalID); err != nil {
+		return 0, err
+	}
+
+	var customerID sql.NullInt64
+	if transaction.Customer != nil {
+		id, err := s.customer(ctx, tx, *transaction.Customer)
+		if err != nil {
+			return 0, err
+		}
+		customerID = sql.NullInt64{Int64: int64(id), Valid: true}
+	}
+
+	productIDs := make([]int, len(transaction.Items))
+	var total float64
+	for i, item := range transaction.Items {
+		if productIDs[i], err = s.product(ctx, tx, item.Product); err != nil {
+			return 0, err
+		}
+		total += item.TotalAmount
+	}
+
+	id, found, err := s.link(ctx, tx, transaction.Kind, transaction.ExternalID)
+	if err != nil {
+		return 0, err
+	}
+	if found {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE sale_transactions
+			SET customer_id = $2, company_id = $3, date_recorded = $4, total_amount = $5, status = $6, currency = $7
+			WHERE id = $1
+		`, id, customerID, s.companyID, transaction.Date.UTC(), total, transaction.Status, transaction.Currency)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, "DELETE FROM sale_transaction_items WHERE sale_transaction_id = $1", id)
+		}
+	} else {
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO sale_transactions (customer_id, company_id, date_recorded, total_amount, status, currency)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, customerID, s.companyID, transaction.Date.UTC(), total, transaction.Status, transaction.Currency).Scan(&id)
+		if err == nil {
+			err = s.saveLink(ctx, tx, transaction.Kind, transaction.ExternalID, id)
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save %s %s %s: %v", s.source, transaction.Kind, transaction.ExternalID, err)
+	}
+
+	for i, item := range transaction.Items {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO sale_transaction_items (sale_transaction_id, product_id, quantity, total_amount)
+			VALUES ($1, $2, $3, $4)
+		`, id, productIDs[i], item.Quantity, item.TotalAmount)
+		if err != nil {
+			return 0, fmt.Errorf("failed to save items of %s %s %s: %v", s.source, transaction.Kind, transaction.ExternalID, err)
+		}
+	}
+
+	if err := batch.RegenerateTransactions(tx, []int{id}); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return id, nil
+}
+
+// Delete deletes a transaction saved before, e.g. an order voided in its
+// source, and its data warehouse rows. Transactions never saved are ignored.
+func (s *Store) Delete(ctx context.Context, kind, externalID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.lock(ctx, tx, kind, externalID); err != nil {
+		return err
+	}
+	id, found, err := s.link(ctx, tx, kind, externalID)
+	if err != nil || !found {
+		return err
+	}
+
+	// Items are deleted with the transaction
+	if _, err := tx.ExecContext(ctx, "DELETE FROM sale_transactions WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete %s %s %s: %v", s.source, kind, externalID, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM integration_links WHERE source = $1 AND kind = $2 AND external_id = $3", s.source, kind, externalID); err != nil {
+		return fmt.Errorf("failed to unlink %s %s %s: %v", s.source, kind, externalID, err)
+	}
+	// Without items, regenerating removes the transaction's live DW rows
+	if err := batch.RegenerateTransactions(tx, []int{id}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// Cursor returns where the source's last sync stopped, or "" before the first
+func (s *Store) Cursor(ctx context.Context) (string, error) {
+	var cursor string
+	err := s.db.QueryRowContext(ctx, "SELECT sync_cursor FROM integration_cursors WHERE source = $1", s.source).Scan(&cursor)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s sync cursor: %v", s.source, err)
+	}
+	return cursor, nil
+}
+
+// SaveCursor records where the next sync of the source resumes
+func (s *Store) SaveCursor(ctx context.Context, cursor string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO integration_cursors (source, sync_cursor, synced_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (source) DO UPDATE
+			SET sync_cursor = EXCLUDED.sync_cursor, synced_at = EXCLUDED.synced_at
+	`, s.source, cursor)
+	if err != nil {
+		return fmt.Errorf("failed to save %s sync cursor: %v", s.source, err)
+	}
+	return nil
+}
+
+// lock serializes saving a record of the source until tx ends
+func (s *Store) lock(ctx context.Context, tx *sql.Tx, kind, externalID string) error {
+	key := strings.Join([]string{s.source, kind, externalID}, "/")
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", key); err != nil {
+		return fmt.Errorf("failed to lock %s: %v", key, err)
+	}
+	return nil
+}
+
+// link returns the ID of the row a record of the source was saved as
+func (s *Store) link(ctx context.Context, tx *sql.Tx, kind, externalID string) (int, bool, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, `
+		SELECT record_id FROM integration_links
+		WHERE source = $1 AND kind = $2 AND external_id = $3
+	`, s.source, kind, externalID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to look up %s %s %s: %v", s.source, kind, externalID, err)
+	}
+	return id, true, nil
+}
+
+// saveLink records the row a record of the source was saved as
+func (s *Store) saveLink(ctx context.Context, tx *sql.Tx, kind, externalID string, id int) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO integration_links (source, kind, external_id, record_id, synced_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (source, kind, external_id) DO UPDATE
+			SET record_id = EXCLUDED.record_id, synced_at = EXCLUDED.synced_at
+	`, s.source, kind, externalID, id)
+	if err != nil {
+		return fmt.Errorf("failed to link %s %s %s: %v", s.source, kind, externalID, err)
+	}
+	return nil
+}
+
+// customer returns the ID of a customer, matched by link or email, creating
+// the customer when neither matches
+func (s *Store) customer(ctx context.Context, tx *sql.Tx, customer Customer) (int, error) {
+	if customer.ExternalID != "" {
+		// Keep concurrent syncs from creating the customer twice
+		if err := s.lock(ctx, tx, kindCustomer, customer.ExternalID); err != nil {
+			return 0, err
+		}
+		if id, found, err := s.link(ctx, tx, kindCustomer, customer.ExternalID); err != nil || found {
+			return id, err
+		}
+	}
+
+	var id int
+	err := sql.ErrNoRows
+	if customer.Email != "" {
+		err = tx.QueryRowContext(ctx, "SELECT id FROM customers WHERE LOWER(email) = LOWER($1) ORDER BY id LIMIT 1", customer.Email).Scan(&id)
+	}
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO customers (first_name, last_name, email, phone_number)
+			VALUES (NULLIF($1, ''), NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''))
+			RETURNING id
+		`, customer.FirstName, customer.LastName, customer.Email, customer.Phone).Scan(&id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save %s customer %s: %v", s.source, customer.ExternalID, err)
+	}
+
+	if customer.ExternalID != "" {
+		if err := s.saveLink(ctx, tx, kindCustomer, customer.ExternalID, id); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// product returns the ID of a product, matched by link or SKU, creating the
+// product and its category when neither matches
+func (s *Store) product(ctx context.Context, tx *sql.Tx, product Product) (int, error) {
+	if product.ExternalID == "" && product.SKU == "" {
+		return 0, fmt.Errorf("%s product %q has neither an ID nor a SKU", s.source, product.Name)
+	}
+	// Keep concurrent syncs from creating the product twice
+	if err := s.lock(ctx, tx, kindProduct, product.ExternalID+"/"+product.SKU); err != nil {
+		return 0, err
+	}
+	if product.ExternalID != "" {
+		if id, found, err := s.link(ctx, tx, kindProduct, product.ExternalID); err != nil || found {
+			return id, err
+		}
+	}
+
+	var id int
+	err := sql.ErrNoRows
+	if product.SKU != "" {
+		err = tx.QueryRowContext(ctx, "SELECT id FROM products WHERE sku = $1", product.SKU).Scan(&id)
+	}
+	if err == sql.ErrNoRows {
+		var categoryID int
+		if categoryID, err = s.category(ctx, tx, product.Category); err != nil {
+			return 0, err
+		}
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO products (name, price, category_id, company_id, sku)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+			RETURNING id
+		`, product.Name, product.Price, categoryID, s.companyID, product.SKU).Scan(&id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save %s product %q: %v", s.source, product.Name, err)
+	}
+
+	if product.ExternalID != "" {
+		if err := s.saveLink(ctx, tx, kindProduct, product.ExternalID, id); err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// category returns the ID of the category named name, creating it when
+// missing
+func (s *Store) category(ctx context.Context, tx *sql.Tx, name string) (int, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = uncategorized
+	}
+
+	if err := s.lock(ctx, tx, "category", strings.ToLower(name)); err != nil {
+		return 0, err
+	}
+	var id int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM categories WHERE LOWER(name) = LOWER($1) ORDER BY id LIMIT 1", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx, "INSERT INTO categories (name) VALUES ($1) RETURNING id", name).Scan(&id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to save category %q: %v", name, err)
+	}
+	return id, nil
+}
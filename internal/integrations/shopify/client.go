@@ -0,0 +1,158 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ordersPageSize is the number of orders requested per page, the API's
+// maximum
+const ordersPageSize = 250
+
+// maxRateLimitRetries is how many times a rate-limited request is retried
+const maxRateLimitRetries = 3
+
+// nextLink matches the next page's URL in a Link header
+var nextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// Client calls the Admin REST API of a store
+type Client struct {
+	apiURL string
+	token  string
+	client *http.Client
+}
+
+// NewClient returns a client of the store in config
+func NewClient(config Config) *Client {
+	return &Client{apiURL: config.APIURL, token: config.AccessToken, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Orders calls fn with each page of the orders updated since since, or of
+// every order when it's zero, in order of update, and stops at fn's first
+// error
+func (c *Client) Orders(ctx context.Context, since time.Time, fn func([]Order) error) error {
+	query := url.Values{
+		"status": {"any"},
+		"limit":  {strconv.Itoa(ordersPageSize)},
+		"order":  {"updated_at asc"},
+	}
+	if !since.IsZero() {
+		query.Set("updated_at_min", since.UTC().Format(time.RFC3339))
+	}
+
+	endpoint := c.apiURL + "/orders.json?" + query.Encode()
+	for endpoint != "" {
+		var page struct {
+			Orders []Order `json:"orders"`
+		}
+		header, err := c.get(ctx, endpoint, &page)
+		if err != nil {
+			return fmt.Errorf("failed to fetch Shopify orders: %v", err)
+		}
+		if err := fn(page.Orders); err != nil {
+			return err
+		}
+
+		// Later pages are only addressed by the page_info in the link
+		endpoint = ""
+		if match := nextLink.FindStringSubmatch(header.Get("Link")); match != nil {
+			endpoint = match[1]
+		}
+	}
+	return nil
+}
+
+// ProductTypes returns the product type of each product in ids, which order
+// line items don't include
+func (c *Client) ProductTypes(ctx context.Context, ids []int64) (map[int64]string, error) {
+	types := make(map[int64]string, len(ids))
+	for start := 0; start < len(ids); start += ordersPageSize {
+		end := min(start+ordersPageSize, len(ids))
+		values := make([]string, 0, end-start)
+		for _, id := range ids[start:end] {
+			values = append(values, strconv.FormatInt(id, 10))
+		}
+		query := url.Values{
+			"ids":    {strings.Join(values, ",")},
+			"fields": {"id,product_type"},
+			"limit":  {strconv.Itoa(ordersPageSize)},
+		}
+
+		var page struct {
+			Products []struct {
+				ID          int64  `json:"id"`
+				ProductType string `json:"product_type"`
+			} `json:"products"`
+		}
+		if _, err := c.get(ctx, c.apiURL+"/products.json?"+query.Encode(), &page); err != nil {
+			return nil, fmt.Errorf("failed to fetch Shopify products: %v", err)
+		}
+		for _, product := range page.Products {
+			types[product.ID] = product.ProductType
+		}
+	}
+	return types, nil
+}
+
+// get decodes the JSON response to a GET of endpoint into value and returns
+// its headers, waiting out rate limits
+func (c *Client) get(ctx context.Context, endpoint string, value any) (http.Header, error) {
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		request.Header.Set("X-Shopify-Access-Token", c.token)
+		request.Header.Set("Accept", "application/json")
+
+		response, err := c.client.Do(request)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries {
+			response.Body.Close()
+			if err := sleep(ctx, retryAfter(response.Header)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Shopify returned %s", response.Status)
+		}
+		if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %v", err)
+		}
+		return response.Header, nil
+	}
+}
+
+// retryAfter returns how long a rate-limited response asks to wait, 2s when
+// it doesn't say
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.ParseFloat(header.Get("Retry-After"), 64)
+	if err != nil || seconds <= 0 {
+		return 2 * time.Second
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// sleep waits for d unless ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,227 @@
+package shopify
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/integrations"
+)
+
+// Kinds of the transactions saved from Shopify
+const (
+	kindOrder  = "order"
+	kindRefund = "refund"
+)
+
+// Order is an order of the Admin REST API, with the fields the sync maps
+type Order struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ProcessedAt *time.Time `json:"processed_at"`
+	// FinancialStatus is pending, authorized, partially_paid, paid,
+	// partially_refunded, refunded, or voided
+	FinancialStatus string `json:"financial_status"`
+	// Currency is the store's currency, which the amounts are in
+	Currency  string     `json:"currency"`
+	Test      bool       `json:"test"`
+	Customer  *Customer  `json:"customer"`
+	LineItems []LineItem `json:"line_items"`
+	Refunds   []Refund   `json:"refunds"`
+}
+
+// Customer is the customer of an order
+type Customer struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+}
+
+// LineItem is a product variant sold in an order
+type LineItem struct {
+	ID int64 `json:"id"`
+	// ProductID and VariantID are nil for custom items and deleted products
+	ProductID *int64 `json:"product_id"`
+	VariantID *int64 `json:"variant_id"`
+	SKU       string `json:"sku"`
+	// Name is the product's title with the variant's
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	// Price is the list price of a unit
+	Price               amount               `json:"price"`
+	DiscountAllocations []DiscountAllocation `json:"discount_allocations"`
+}
+
+// DiscountAllocation is the part of a discount applied to a line item
+type DiscountAllocation struct {
+	Amount amount `json:"amount"`
+}
+
+// Refund is money returned for an order's items
+type Refund struct {
+	ID              int64            `json:"id"`
+	CreatedAt       time.Time        `json:"created_at"`
+	ProcessedAt     *time.Time       `json:"processed_at"`
+	RefundLineItems []RefundLineItem `json:"refund_line_items"`
+}
+
+// RefundLineItem is a line item refunded
+type RefundLineItem struct {
+	Quantity int `json:"quantity"`
+	// Subtotal is the amount refunded for the items, after discounts
+	Subtotal amount   `json:"subtotal"`
+	LineItem LineItem `json:"line_item"`
+}
+
+// amount is a decimal amount, which the API sends as a string or a number
+type amount float64
+
+// UnmarshalJSON parses a quoted or bare amount, or null as 0
+func (a *amount) UnmarshalJSON(data []byte) error {
+	data = bytes.Trim(data, `"`)
+	if len(data) == 0 || string(data) == "null" {
+		*a = 0
+		return nil
+	}
+	value, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	*a = amount(value)
+	return nil
+}
+
+// transactions maps an order to the invoice or pending sale of its items and
+// a refund transaction per refund of items. It reports true instead when the
+// order shouldn't be recorded at all, such as test and voided orders.
+func (o Order) transactions(types map[int64]string) ([]integrations.Transaction, bool, error) {
+	if o.Test || o.FinancialStatus == "voided" {
+		return nil, true, nil
+	}
+	if len(o.LineItems) == 0 {
+		return nil, false, fmt.Errorf("order has no line items")
+	}
+
+	// Orders not paid yet are pending; paid orders stay invoices when
+	// refunded, as their refunds are recorded separately
+	status := "invoice"
+	switch o.FinancialStatus {
+	case "pending", "authorized", "partially_paid":
+		status = "pending"
+	}
+
+	var customer *integrations.Customer
+	if o.Customer != nil {
+		customer = &integrations.Customer{
+			ExternalID: strconv.FormatInt(o.Customer.ID, 10),
+			FirstName:  o.Customer.FirstName,
+			LastName:   o.Customer.LastName,
+			Email:      o.Customer.Email,
+			Phone:      o.Customer.Phone,
+		}
+	}
+
+	order := integrations.Transaction{
+		Kind:       kindOrder,
+		ExternalID: strconv.FormatInt(o.ID, 10),
+		Date:       processed(o.ProcessedAt, o.CreatedAt),
+		Status:     status,
+		Currency:   o.Currency,
+		Customer:   customer,
+	}
+	for _, line := range o.LineItems {
+		product, err := line.product(types)
+		if err != nil {
+			return nil, false, err
+		}
+		total := float64(line.Price) * float64(line.Quantity)
+		for _, discount := range line.DiscountAllocations {
+			total -= float64(discount.Amount)
+		}
+		order.Items = append(order.Items, integrations.Item{
+			Product:     product,
+			Quantity:    line.Quantity,
+			TotalAmount: round(math.Max(total, 0)),
+		})
+	}
+	transactions := []integrations.Transaction{order}
+
+	for _, refund := range o.Refunds {
+		transaction := integrations.Transaction{
+			Kind:       kindRefund,
+			ExternalID: strconv.FormatInt(refund.ID, 10),
+			Date:       processed(refund.ProcessedAt, refund.CreatedAt),
+			Status:     "refund",
+			Currency:   o.Currency,
+			Customer:   customer,
+		}
+		for _, line := range refund.RefundLineItems {
+			product, err := line.LineItem.product(types)
+			if err != nil {
+				return nil, false, err
+			}
+			transaction.Items = append(transaction.Items, integrations.Item{
+				Product:     product,
+				Quantity:    line.Quantity,
+				TotalAmount: round(float64(line.Subtotal)),
+			})
+		}
+		// Refunds of shipping or of an amount alone don't change the sales
+		// of any category
+		if len(transaction.Items) > 0 {
+			transactions = append(transactions, transaction)
+		}
+	}
+	return transactions, false, nil
+}
+
+// product maps the variant a line item sold to a product. Variants are the
+// products recorded, as each has its own SKU and price; their product's type
+// is the category.
+func (l LineItem) product(types map[int64]string) (integrations.Product, error) {
+	product := integrations.Product{SKU: l.SKU, Name: l.Name, Price: float64(l.Price)}
+	if l.VariantID != nil {
+		product.ExternalID = strconv.FormatInt(*l.VariantID, 10)
+	}
+	if l.ProductID != nil {
+		product.Category = types[*l.ProductID]
+	}
+	if product.ExternalID == "" && product.SKU == "" {
+		return product, fmt.Errorf("line item %d %q has neither a variant nor a SKU", l.ID, l.Name)
+	}
+	return product, nil
+}
+
+// productIDs returns the IDs of the products orders sold, once each
+func productIDs(orders []Order) []int64 {
+	seen := map[int64]bool{}
+	var ids []int64
+	for _, order := range orders {
+		for _, line := range order.LineItems {
+			if line.ProductID != nil && !seen[*line.ProductID] {
+				seen[*line.ProductID] = true
+				ids = append(ids, *line.ProductID)
+			}
+		}
+	}
+	return ids
+}
+
+// processed returns when a record was processed, or created when the API
+// doesn't say
+func processed(processedAt *time.Time, createdAt time.Time) time.Time {
+	if processedAt != nil && !processedAt.IsZero() {
+		return *processedAt
+	}
+	return createdAt
+}
+
+// round rounds an amount to cents
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
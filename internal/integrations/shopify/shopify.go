@@ -0,0 +1,173 @@
+// Package shopify syncs the orders of a Shopify store into the transaction
+// tables through the Admin REST API, on a schedule and from order webhooks
+package shopify
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/integrations"
+)
+
+// Source is the integration_links and integration_cursors key of the
+// Shopify integration
+const Source = "shopify"
+
+// defaultAPIVersion is the Admin API version requested without
+// SHOPIFY_API_VERSION
+const defaultAPIVersion = "2024-10"
+
+// shopDomain matches a shop's myshopify.com domain
+var shopDomain = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*\.myshopify\.com$`)
+
+// Config is the Shopify store synced and how to reach it
+type Config struct {
+	// Shop is the store's myshopify.com domain
+	Shop string
+	// AccessToken is the Admin API access token of the store's custom app,
+	// which needs the read_orders and read_products scopes
+	AccessToken string
+	// APIURL is the base URL of the Admin API
+	APIURL string
+	// CompanyID is the company the store's sales are recorded as
+	CompanyID int
+	// WebhookSecret is the app's client secret webhooks are signed with.
+	// Webhooks are refused without it.
+	WebhookSecret string
+}
+
+// ConfigFromEnv reads SHOPIFY_SHOP, SHOPIFY_ACCESS_TOKEN, SHOPIFY_COMPANY_ID,
+// SHOPIFY_API_VERSION (default 2024-10), SHOPIFY_API_URL (default
+// https://{shop}/admin/api/{version}) and SHOPIFY_WEBHOOK_SECRET. It reports
+// false when SHOPIFY_SHOP isn't set and the integration is disabled.
+func ConfigFromEnv() (Config, bool, error) {
+	config := Config{
+		Shop:          strings.ToLower(os.Getenv("SHOPIFY_SHOP")),
+		AccessToken:   os.Getenv("SHOPIFY_ACCESS_TOKEN"),
+		APIURL:        strings.TrimSuffix(os.Getenv("SHOPIFY_API_URL"), "/"),
+		WebhookSecret: os.Getenv("SHOPIFY_WEBHOOK_SECRET"),
+	}
+	if config.Shop == "" {
+		return config, false, nil
+	}
+
+	if !shopDomain.MatchString(config.Shop) {
+		return config, false, fmt.Errorf("invalid SHOPIFY_SHOP %q, use the store's myshopify.com domain", config.Shop)
+	}
+	if config.AccessToken == "" {
+		return config, false, fmt.Errorf("SHOPIFY_ACCESS_TOKEN is required when SHOPIFY_SHOP is set")
+	}
+	value := os.Getenv("SHOPIFY_COMPANY_ID")
+	companyID, err := strconv.Atoi(value)
+	if err != nil || companyID < 1 {
+		return config, false, fmt.Errorf("invalid SHOPIFY_COMPANY_ID %q, use the ID of the company the store's sales are recorded as", value)
+	}
+	config.CompanyID = companyID
+
+	if config.APIURL == "" {
+		version := os.Getenv("SHOPIFY_API_VERSION")
+		if version == "" {
+			version = defaultAPIVersion
+		}
+		config.APIURL = fmt.Sprintf("https://%s/admin/api/%s", config.Shop, version)
+	}
+	return config, true, nil
+}
+
+// Syncer saves a store's orders
+type Syncer struct {
+	config Config
+	client *Client
+	store  *integrations.Store
+}
+
+// NewSyncer returns a syncer of the store in config into db
+func NewSyncer(db *sql.DB, config Config) *Syncer {
+	return &Syncer{config: config, client: NewClient(config), store: integrations.NewStore(db, Source, config.CompanyID)}
+}
+
+// Sync saves the orders updated since the last sync, oldest first, moving
+// the cursor past each page. The first sync saves every order.
+func (s *Syncer) Sync(ctx context.Context) error {
+	cursor, err := s.store.Cursor(ctx)
+	if err != nil {
+		return err
+	}
+	var since time.Time
+	if cursor != "" {
+		if since, err = time.Parse(time.RFC3339, cursor); err != nil {
+			return fmt.Errorf("invalid Shopify sync cursor %q: %v", cursor, err)
+		}
+	}
+
+	synced := 0
+	err = s.client.Orders(ctx, since, func(orders []Order) error {
+		if err := s.SaveOrders(ctx, orders); err != nil {
+			return err
+		}
+		// The next sync starts at the last order again, as updated_at_min
+		// is inclusive, so orders updated in the same second aren't missed
+		for _, order := range orders {
+			if order.UpdatedAt.After(since) {
+				since = order.UpdatedAt
+			}
+		}
+		synced += len(orders)
+		return s.store.SaveCursor(ctx, since.UTC().Format(time.RFC3339))
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Synced %d Shopify orders", synced)
+	return nil
+}
+
+// SaveOrders saves orders and their refunds. Orders that can't be mapped,
+// such as orders without items, are logged and skipped, so they don't hold
+// up the orders after them.
+func (s *Syncer) SaveOrders(ctx context.Context, orders []Order) error {
+	types, err := s.client.ProductTypes(ctx, productIDs(orders))
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		transactions, remove, err := order.transactions(types)
+		if err != nil {
+			log.Printf("Warning: skipping Shopify order %d: %v", order.ID, err)
+			continue
+		}
+		if remove {
+			if err := s.removeOrder(ctx, order); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, transaction := range transactions {
+			if _, err := s.store.Save(ctx, transaction); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeOrder deletes an order saved before and its refunds
+func (s *Syncer) removeOrder(ctx context.Context, order Order) error {
+	if err := s.store.Delete(ctx, kindOrder, strconv.FormatInt(order.ID, 10)); err != nil {
+		return err
+	}
+	for _, refund := range order.Refunds {
+		if err := s.store.Delete(ctx, kindRefund, strconv.FormatInt(refund.ID, 10)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
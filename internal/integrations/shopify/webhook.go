@@ -0,0 +1,74 @@
+package shopify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+)
+
+// maxWebhookSize is the largest webhook body read, well above any order's
+const maxWebhookSize = 5 << 20
+
+// WebhookHandler receives the store's order webhooks: orders/create,
+// orders/updated, orders/paid, and orders/cancelled. Refunds arrive as
+// orders/updated with the order's refunds, and canceled orders that weren't
+// paid are voided, which removes them. Webhooks are verified with the
+// WebhookSecret and refused without one.
+//
+// Shopify retries webhooks that don't get a 2xx response, so failures to
+// save respond 500 to have the order delivered again.
+func (s *Syncer) WebhookHandler() echo.HandlerFunc {
+	secret := []byte(s.config.WebhookSecret)
+	return func(c echo.Context) error {
+		request := c.Request()
+		body, err := io.ReadAll(io.LimitReader(request.Body, maxWebhookSize))
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, "Failed to read webhook")
+		}
+		if !validSignature(secret, body, request.Header.Get("X-Shopify-Hmac-Sha256")) {
+			return httperror.JSON(c, http.StatusUnauthorized, "Invalid webhook signature")
+		}
+		if shop := request.Header.Get("X-Shopify-Shop-Domain"); !strings.EqualFold(shop, s.config.Shop) {
+			return httperror.JSON(c, http.StatusForbidden, "Webhook from another shop")
+		}
+
+		topic := request.Header.Get("X-Shopify-Topic")
+		switch topic {
+		case "orders/create", "orders/updated", "orders/paid", "orders/cancelled":
+			var order Order
+			if err := json.Unmarshal(body, &order); err != nil || order.ID == 0 {
+				return httperror.JSON(c, http.StatusBadRequest, "Invalid order")
+			}
+			if err := s.SaveOrders(request.Context(), []Order{order}); err != nil {
+				log.Printf("Failed to save Shopify order %d from %s webhook: %v", order.ID, topic, err)
+				return httperror.JSON(c, http.StatusInternalServerError, "Failed to save order")
+			}
+		default:
+			// Acknowledge topics the app subscribes to that aren't synced
+			// so Shopify doesn't retry them
+			log.Printf("Ignoring Shopify %q webhook", topic)
+		}
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+// validSignature reports whether signature is the base64 HMAC-SHA256 of body
+// with secret. Nothing is valid without a secret.
+func validSignature(secret, body []byte, signature string) bool {
+	expected, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(secret) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,35 @@
+package shopify
+
+import "testing"
+
+func TestValidSignature(t *testing.T) {
+	const (
+		body = `{"id":820982911946154500,"name":"#1001"}`
+		// signed is the base64 HMAC-SHA256 of body with shpss_test, and
+		// unkeyed its HMAC with an empty key
+		signed  = "/DtV+F4NsFHruvgNOLHz8jBKBrLnRJUG1K1fUvjIaMQ="
+		unkeyed = "9XOnw4lPuQGoEMgB9cyZj9nxvXyMf/ryUQZWpYj7VsQ="
+	)
+
+	for _, test := range []struct {
+		name      string
+		secret    string
+		body      string
+		signature string
+		want      bool
+	}{
+		{"valid", "shpss_test", body, signed, true},
+		{"changed body", "shpss_test", body + " ", signed, false},
+		{"another secret", "shpss_other", body, signed, false},
+		{"not base64", "shpss_test", body, "not base64!", false},
+		{"hex instead of base64", "shpss_test", body, "fc3b55f85e0db051ebbaf80d38b1f3f2304a06b2e7449506d4ad5f52f8c868c4", false},
+		{"empty signature", "shpss_test", body, "", false},
+		{"empty secret", "", body, unkeyed, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := validSignature([]byte(test.secret), []byte(test.body), test.signature); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
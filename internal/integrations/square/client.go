@@ -0,0 +1,287 @@
+package square
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Request limits of the API
+const (
+	ordersPageSize        = 500
+	ordersSearchLocations = 10
+	catalogBatchSize      = 1000
+	customersBatchSize    = 100
+)
+
+// maxRateLimitRetries is how many times a rate-limited request is retried
+const maxRateLimitRetries = 3
+
+// Client calls the Square API
+type Client struct {
+	apiURL  string
+	version string
+	client  *http.Client
+}
+
+// NewClient returns a client of the API in config
+func NewClient(config Config) *Client {
+	return &Client{apiURL: config.APIURL, version: config.APIVersion, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// apiError is an error in the body of a failed response
+type apiError struct {
+	Category string `json:"category"`
+	Code     string `json:"code"`
+	Detail   string `json:"detail"`
+}
+
+// Locations returns the IDs of the seller's locations
+func (c *Client) Locations(ctx context.Context, token string) ([]string, error) {
+	var response struct {
+		Locations []struct {
+			ID string `json:"id"`
+		} `json:"locations"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/v2/locations", token, nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to fetch Square locations: %v", err)
+	}
+	ids := make([]string, 0, len(response.Locations))
+	for _, location := range response.Locations {
+		ids = append(ids, location.ID)
+	}
+	return ids, nil
+}
+
+// SearchOrders calls fn with each page of the orders of locations updated
+// since since, or of every order when it's zero, in order of update per
+// batch of locations, and stops at fn's first error
+func (c *Client) SearchOrders(ctx context.Context, token string, locations []string, since time.Time, fn func([]Order) error) error {
+	filter := map[string]any{
+		"state_filter": map[string]any{"states": []string{"OPEN", "COMPLETED", "CANCELED"}},
+	}
+	if !since.IsZero() {
+		filter["date_time_filter"] = map[string]any{
+			"updated_at": map[string]any{"start_at": since.UTC().Format(time.RFC3339)},
+		}
+	}
+
+	for start := 0; start < len(locations); start += ordersSearchLocations {
+		request := map[string]any{
+			"location_ids": locations[start:min(start+ordersSearchLocations, len(locations))],
+			"limit":        ordersPageSize,
+			"query": map[string]any{
+				"filter": filter,
+				"sort":   map[string]any{"sort_field": "UPDATED_AT", "sort_order": "ASC"},
+			},
+		}
+		for {
+			var page struct {
+				Orders []Order `json:"orders"`
+				Cursor string  `json:"cursor"`
+			}
+			if err := c.call(ctx, http.MethodPost, "/v2/orders/search", token, request, &page); err != nil {
+				return fmt.Errorf("failed to fetch Square orders: %v", err)
+			}
+			if err := fn(page.Orders); err != nil {
+				return err
+			}
+			if page.Cursor == "" {
+				break
+			}
+			request["cursor"] = page.Cursor
+		}
+	}
+	return nil
+}
+
+// Catalog returns the SKU and category of each item variation in ids, which
+// order line items don't include. Variations not found, such as those of
+// another seller's catalog, are missing.
+func (c *Client) Catalog(ctx context.Context, token string, ids []string) (map[string]CatalogEntry, error) {
+	objects, err := c.catalogObjects(ctx, token, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Categories aren't related to variations, only to their items, so
+	// those missing are fetched after
+	var missing []string
+	for _, object := range objects {
+		if object.Type == "ITEM" {
+			if id := object.ItemData.categoryID(); id != "" && objects[id].Type == "" {
+				missing = append(missing, id)
+			}
+		}
+	}
+	categories, err := c.catalogObjects(ctx, token, missing)
+	if err != nil {
+		return nil, err
+	}
+	for id, object := range categories {
+		objects[id] = object
+	}
+
+	entries := make(map[string]CatalogEntry, len(ids))
+	for _, id := range ids {
+		variation, ok := objects[id]
+		if !ok || variation.Type != "ITEM_VARIATION" {
+			continue
+		}
+		item := objects[variation.VariationData.ItemID]
+		entries[id] = CatalogEntry{
+			SKU:      variation.VariationData.SKU,
+			Category: objects[item.ItemData.categoryID()].CategoryData.Name,
+		}
+	}
+	return entries, nil
+}
+
+// Customers returns the customers in ids
+func (c *Client) Customers(ctx context.Context, token string, ids []string) (map[string]Customer, error) {
+	customers := make(map[string]Customer, len(ids))
+	for start := 0; start < len(ids); start += customersBatchSize {
+		request := map[string]any{"customer_ids": ids[start:min(start+customersBatchSize, len(ids))]}
+		var response struct {
+			Responses map[string]struct {
+				Customer *Customer `json:"customer"`
+			} `json:"responses"`
+		}
+		if err := c.call(ctx, http.MethodPost, "/v2/customers/bulk-retrieve", token, request, &response); err != nil {
+			return nil, fmt.Errorf("failed to fetch Square customers: %v", err)
+		}
+		// Customers deleted since are left out
+		for id, result := range response.Responses {
+			if result.Customer != nil {
+				customers[id] = *result.Customer
+			}
+		}
+	}
+	return customers, nil
+}
+
+// tokenResponse is the response of the token endpoint
+type tokenResponse struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	MerchantID   string    `json:"merchant_id"`
+}
+
+// ObtainToken exchanges an authorization code, or a refresh token when
+// grantType is refresh_token, for an access token
+func (c *Client) ObtainToken(ctx context.Context, config Config, grantType, value string) (tokenResponse, error) {
+	request := map[string]any{
+		"client_id":     config.ApplicationID,
+		"client_secret": config.ApplicationSecret,
+		"grant_type":    grantType,
+	}
+	if grantType == "refresh_token" {
+		request["refresh_token"] = value
+	} else {
+		request["code"] = value
+		if config.RedirectURL != "" {
+			request["redirect_uri"] = config.RedirectURL
+		}
+	}
+
+	var response tokenResponse
+	if err := c.call(ctx, http.MethodPost, "/oauth2/token", "", request, &response); err != nil {
+		return response, fmt.Errorf("failed to obtain Square token: %v", err)
+	}
+	return response, nil
+}
+
+// catalogObjects returns the catalog objects in ids with their related
+// objects, by ID
+func (c *Client) catalogObjects(ctx context.Context, token string, ids []string) (map[string]catalogObject, error) {
+	objects := map[string]catalogObject{}
+	for start := 0; start < len(ids); start += catalogBatchSize {
+		request := map[string]any{
+			"object_ids":              ids[start:min(start+catalogBatchSize, len(ids))],
+			"include_related_objects": true,
+			"include_deleted_objects": true,
+		}
+		var response struct {
+			Objects        []catalogObject `json:"objects"`
+			RelatedObjects []catalogObject `json:"related_objects"`
+		}
+		if err := c.call(ctx, http.MethodPost, "/v2/catalog/batch-retrieve", token, request, &response); err != nil {
+			return nil, fmt.Errorf("failed to fetch Square catalog: %v", err)
+		}
+		for _, object := range append(response.Objects, response.RelatedObjects...) {
+			objects[object.ID] = object
+		}
+	}
+	return objects, nil
+}
+
+// call sends a request with body as JSON, authorized with token unless it's
+// empty, and decodes the JSON response into value, waiting out rate limits
+func (c *Client) call(ctx context.Context, method, path, token string, body, value any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Square-Version", c.version)
+		request.Header.Set("Accept", "application/json")
+		request.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		response, err := c.client.Do(request)
+		if err != nil {
+			return err
+		}
+		if response.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries {
+			response.Body.Close()
+			if err := sleep(ctx, time.Duration(attempt+1)*2*time.Second); err != nil {
+				return err
+			}
+			continue
+		}
+
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			var failure struct {
+				Errors []apiError `json:"errors"`
+			}
+			json.NewDecoder(response.Body).Decode(&failure)
+			details := make([]string, 0, len(failure.Errors))
+			for _, e := range failure.Errors {
+				details = append(details, e.Code+": "+e.Detail)
+			}
+			return fmt.Errorf("Square returned %s %s", response.Status, strings.Join(details, "; "))
+		}
+		if err := json.NewDecoder(response.Body).Decode(value); err != nil {
+			return fmt.Errorf("failed to decode response: %v", err)
+		}
+		return nil
+	}
+}
+
+// sleep waits for d unless ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,167 @@
+package square
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/integrations"
+)
+
+// scopes are the permissions the sync needs from the seller
+var scopes = []string{"MERCHANT_PROFILE_READ", "ORDERS_READ", "ITEMS_READ", "CUSTOMERS_READ"}
+
+// stateTTL is how long an admin has to authorize the application after
+// starting to connect
+const stateTTL = 15 * time.Minute
+
+// stateCookie holds the OAuth state in the browser of the admin who started
+// connecting, so only that browser can complete the connection
+const stateCookie = "square_oauth_state"
+
+// refreshBefore is how long before they expire access tokens are refreshed.
+// Square issues tokens valid for 30 days.
+const refreshBefore = 7 * 24 * time.Hour
+
+// ConnectHandler redirects an admin to Square to authorize the application
+// to read the seller's orders, catalog and customers. Square sends them back
+// to CallbackHandler. The OAuth state is a random nonce, saved for the
+// callback to consume and set in a short-lived cookie.
+func (s *Syncer) ConnectHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		state, err := newState()
+		if err != nil {
+			log.Printf("Failed to start connecting Square: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to start connecting Square")
+		}
+		if err := s.store.SaveOAuthState(c.Request().Context(), state); err != nil {
+			log.Printf("Failed to start connecting Square: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to start connecting Square")
+		}
+		c.SetCookie(&http.Cookie{
+			Name:     stateCookie,
+			Value:    state,
+			Path:     "/",
+			MaxAge:   int(stateTTL.Seconds()),
+			Secure:   c.Scheme() == "https",
+			HttpOnly: true,
+			// Lax sends the cookie on Square's redirect back, a top-level GET
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		query := url.Values{
+			"client_id": {s.config.ApplicationID},
+			"scope":     {strings.Join(scopes, " ")},
+			"session":   {"false"},
+			"state":     {state},
+		}
+		if s.config.RedirectURL != "" {
+			query.Set("redirect_uri", s.config.RedirectURL)
+		}
+		return c.Redirect(http.StatusFound, s.config.APIURL+"/oauth2/authorize?"+query.Encode())
+	}
+}
+
+// CallbackHandler exchanges the authorization code Square redirects back with
+// for the seller's tokens and saves them. It needs no other authentication:
+// the state must match the cookie ConnectHandler set in the admin's browser,
+// and is consumed, so it completes one connection within stateTTL.
+func (s *Syncer) CallbackHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		state := c.QueryParam("state")
+		cookie, err := c.Cookie(stateCookie)
+		if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid or expired state, connect Square again")
+		}
+		c.SetCookie(&http.Cookie{Name: stateCookie, Path: "/", MaxAge: -1, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+
+		consumed, err := s.store.ConsumeOAuthState(c.Request().Context(), state, stateTTL)
+		if err != nil {
+			log.Printf("Failed to connect Square: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to check the state")
+		}
+		if !consumed {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid or expired state, connect Square again")
+		}
+		if reason := c.QueryParam("error"); reason != "" {
+			return httperror.JSON(c, http.StatusBadRequest, "Square authorization failed: "+reason)
+		}
+		code := c.QueryParam("code")
+		if code == "" {
+			return httperror.JSON(c, http.StatusBadRequest, "Missing authorization code")
+		}
+
+		ctx := c.Request().Context()
+		token, err := s.client.ObtainToken(ctx, s.config, "authorization_code", code)
+		if err != nil {
+			log.Printf("Failed to connect Square: %v", err)
+			return httperror.JSON(c, http.StatusBadGateway, "Failed to obtain Square token")
+		}
+		if err := s.saveToken(ctx, token); err != nil {
+			log.Printf("Failed to connect Square: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to save Square credentials")
+		}
+
+		log.Printf("Connected Square merchant %s", token.MerchantID)
+		return c.JSON(http.StatusOK, map[string]string{
+			"message":     "Square connected; orders are synced on the next scheduled run",
+			"merchant_id": token.MerchantID,
+		})
+	}
+}
+
+// token returns the seller's access token, refreshing it when it's about to
+// expire, or reports false before a seller is connected
+func (s *Syncer) token(ctx context.Context) (string, bool, error) {
+	credentials, connected, err := s.store.Credentials(ctx, s.config.CredentialsKey)
+	if err != nil || !connected {
+		return "", false, err
+	}
+	if time.Until(credentials.ExpiresAt) > refreshBefore {
+		return credentials.AccessToken, true, nil
+	}
+
+	token, err := s.client.ObtainToken(ctx, s.config, "refresh_token", credentials.RefreshToken)
+	if err != nil {
+		return "", false, err
+	}
+	// The refresh token stays valid when the response leaves it out
+	if token.RefreshToken == "" {
+		token.RefreshToken = credentials.RefreshToken
+	}
+	if token.MerchantID == "" {
+		token.MerchantID = credentials.AccountID
+	}
+	if err := s.saveToken(ctx, token); err != nil {
+		return "", false, err
+	}
+	return token.AccessToken, true, nil
+}
+
+// saveToken saves the credentials in a token response
+func (s *Syncer) saveToken(ctx context.Context, token tokenResponse) error {
+	return s.store.SaveCredentials(ctx, s.config.CredentialsKey, integrations.Credentials{
+		AccountID:    token.MerchantID,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    token.ExpiresAt,
+	})
+}
+
+// newState returns a random OAuth state
+func newState() (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
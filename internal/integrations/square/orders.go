@@ -0,0 +1,259 @@
+package square
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/integrations"
+)
+
+// Kinds of the transactions saved from Square. An order's returns are linked
+// by the order's ID, as Square records returns as orders of their own.
+const (
+	kindOrder  = "order"
+	kindReturn = "return"
+)
+
+// customAmountProduct is the product of line items not in the catalog, such
+// as custom amounts keyed in at the register
+var customAmountProduct = integrations.Product{ExternalID: "custom-amount", Name: "Square custom amounts"}
+
+// zeroDecimalCurrencies are the currencies whose amounts Square sends in
+// units rather than hundredths
+var zeroDecimalCurrencies = map[string]bool{"JPY": true, "KRW": true, "CLP": true, "VND": true}
+
+// Order is an order of the Orders API, with the fields the sync maps
+type Order struct {
+	ID         string    `json:"id"`
+	LocationID string    `json:"location_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	// ClosedAt is when the order was completed or canceled
+	ClosedAt *time.Time `json:"closed_at"`
+	// State is OPEN, COMPLETED, CANCELED, or DRAFT
+	State      string     `json:"state"`
+	CustomerID string     `json:"customer_id"`
+	LineItems  []LineItem `json:"line_items"`
+	// Returns are the items of other orders this order returned
+	Returns []Return `json:"returns"`
+}
+
+// LineItem is an item sold in an order
+type LineItem struct {
+	UID string `json:"uid"`
+	// CatalogObjectID is the item variation sold, empty for custom amounts
+	CatalogObjectID string `json:"catalog_object_id"`
+	Name            string `json:"name"`
+	VariationName   string `json:"variation_name"`
+	// Quantity is a decimal for items sold by measure
+	Quantity       string `json:"quantity"`
+	BasePriceMoney Money  `json:"base_price_money"`
+	// TotalMoney is after discounts and with taxes
+	TotalMoney    Money `json:"total_money"`
+	TotalTaxMoney Money `json:"total_tax_money"`
+}
+
+// Return is the items returned from an order
+type Return struct {
+	SourceOrderID   string     `json:"source_order_id"`
+	ReturnLineItems []LineItem `json:"return_line_items"`
+}
+
+// Money is an amount in the currency's smallest unit
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// Customer is a customer of the seller
+type Customer struct {
+	ID           string `json:"id"`
+	GivenName    string `json:"given_name"`
+	FamilyName   string `json:"family_name"`
+	EmailAddress string `json:"email_address"`
+	PhoneNumber  string `json:"phone_number"`
+}
+
+// CatalogEntry is what the catalog says about an item variation
+type CatalogEntry struct {
+	SKU string
+	// Category is the name of the category of the variation's item, or
+	// empty when it has none
+	Category string
+}
+
+// catalogObject is an object of the catalog, with the fields of variations,
+// items, and categories the sync maps
+type catalogObject struct {
+	Type          string `json:"type"`
+	ID            string `json:"id"`
+	VariationData struct {
+		ItemID string `json:"item_id"`
+		SKU    string `json:"sku"`
+	} `json:"item_variation_data"`
+	ItemData     itemData `json:"item_data"`
+	CategoryData struct {
+		Name string `json:"name"`
+	} `json:"category_data"`
+}
+
+// itemData is the part of a catalog item naming its categories
+type itemData struct {
+	ReportingCategory *struct {
+		ID string `json:"id"`
+	} `json:"reporting_category"`
+	CategoryID string `json:"category_id"`
+	Categories []struct {
+		ID string `json:"id"`
+	} `json:"categories"`
+}
+
+// categoryID returns the category an item is reported in: its reporting
+// category, or its first category in catalogs from before reporting
+// categories
+func (d itemData) categoryID() string {
+	switch {
+	case d.ReportingCategory != nil:
+		return d.ReportingCategory.ID
+	case d.CategoryID != "":
+		return d.CategoryID
+	case len(d.Categories) > 0:
+		return d.Categories[0].ID
+	}
+	return ""
+}
+
+// transactions maps an order to the invoice or pending sale of its line
+// items and a refund of the items it returned. Exchanges have both. It
+// reports true instead when the order shouldn't be recorded, as it was
+// canceled.
+func (o Order) transactions(catalog map[string]CatalogEntry, customers map[string]Customer) ([]integrations.Transaction, bool, error) {
+	if o.State == "CANCELED" || o.State == "DRAFT" {
+		return nil, true, nil
+	}
+
+	var customer *integrations.Customer
+	if o.CustomerID != "" {
+		found := customers[o.CustomerID]
+		customer = &integrations.Customer{
+			ExternalID: o.CustomerID,
+			FirstName:  found.GivenName,
+			LastName:   found.FamilyName,
+			Email:      found.EmailAddress,
+			Phone:      found.PhoneNumber,
+		}
+	}
+
+	// Open orders, such as open tickets, aren't paid yet
+	status := "invoice"
+	if o.State == "OPEN" {
+		status = "pending"
+	}
+	date := o.CreatedAt
+	if o.ClosedAt != nil && !o.ClosedAt.IsZero() {
+		date = *o.ClosedAt
+	}
+
+	sale := integrations.Transaction{Kind: kindOrder, ExternalID: o.ID, Date: date, Status: status, Customer: customer}
+	for _, line := range o.LineItems {
+		sale.Items = append(sale.Items, line.item(catalog))
+		sale.Currency = line.TotalMoney.Currency
+	}
+	refund := integrations.Transaction{Kind: kindReturn, ExternalID: o.ID, Date: date, Status: "refund", Customer: customer}
+	for _, r := range o.Returns {
+		for _, line := range r.ReturnLineItems {
+			refund.Items = append(refund.Items, line.item(catalog))
+			refund.Currency = line.TotalMoney.Currency
+		}
+	}
+
+	var transactions []integrations.Transaction
+	for _, transaction := range []integrations.Transaction{sale, refund} {
+		if len(transaction.Items) > 0 {
+			transactions = append(transactions, transaction)
+		}
+	}
+	if len(transactions) == 0 {
+		return nil, false, fmt.Errorf("order has no line items")
+	}
+	return transactions, false, nil
+}
+
+// item maps a line item to an item of its variation's product, totalling
+// what it sold or was returned for after discounts and before taxes
+func (l LineItem) item(catalog map[string]CatalogEntry) integrations.Item {
+	product := customAmountProduct
+	if l.CatalogObjectID != "" {
+		entry := catalog[l.CatalogObjectID]
+		product = integrations.Product{
+			ExternalID: l.CatalogObjectID,
+			SKU:        entry.SKU,
+			Name:       l.Name,
+			Category:   entry.Category,
+			Price:      amount(l.BasePriceMoney),
+		}
+		if l.VariationName != "" && !strings.EqualFold(l.VariationName, "Regular") {
+			product.Name += " - " + l.VariationName
+		}
+	}
+	return integrations.Item{
+		Product:     product,
+		Quantity:    quantity(l.Quantity),
+		TotalAmount: amount(l.TotalMoney) - amount(l.TotalTaxMoney),
+	}
+}
+
+// variationIDs returns the item variations orders sold or returned, once each
+func variationIDs(orders []Order) []string {
+	seen := map[string]bool{}
+	var ids []string
+	add := func(lines []LineItem) {
+		for _, line := range lines {
+			if line.CatalogObjectID != "" && !seen[line.CatalogObjectID] {
+				seen[line.CatalogObjectID] = true
+				ids = append(ids, line.CatalogObjectID)
+			}
+		}
+	}
+	for _, order := range orders {
+		add(order.LineItems)
+		for _, r := range order.Returns {
+			add(r.ReturnLineItems)
+		}
+	}
+	return ids
+}
+
+// customerIDs returns the customers of orders, once each
+func customerIDs(orders []Order) []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, order := range orders {
+		if order.CustomerID != "" && !seen[order.CustomerID] {
+			seen[order.CustomerID] = true
+			ids = append(ids, order.CustomerID)
+		}
+	}
+	return ids
+}
+
+// amount converts money to an amount in its currency
+func amount(money Money) float64 {
+	if zeroDecimalCurrencies[money.Currency] {
+		return float64(money.Amount)
+	}
+	return float64(money.Amount) / 100
+}
+
+// quantity parses a line item's quantity to whole units. Items sold by
+// measure, such as 0.5 kg, count as at least one unit.
+func quantity(value string) int {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed <= 0 {
+		return 0
+	}
+	return max(int(math.Round(parsed)), 1)
+}
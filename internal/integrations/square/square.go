@@ -0,0 +1,209 @@
+// Package square syncs the orders of a Square seller into the transaction
+// tables through the Orders API, with OAuth credentials an admin grants by
+// connecting the seller's account
+package square
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/integrations"
+)
+
+// Source is the integration_links, integration_cursors and
+// integration_credentials key of the Square integration
+const Source = "square"
+
+// defaultAPIVersion is the Square-Version requested without
+// SQUARE_API_VERSION
+const defaultAPIVersion = "2024-10-17"
+
+// Base URLs of the Square environments
+const (
+	productionURL = "https://connect.squareup.com"
+	sandboxURL    = "https://connect.squareupsandbox.com"
+)
+
+// Config is the Square application sellers connect to and how to reach it
+type Config struct {
+	// ApplicationID and ApplicationSecret are the OAuth credentials of the
+	// application in the Square Developer Console
+	ApplicationID     string
+	ApplicationSecret string
+	// RedirectURL is where Square sends the admin back after they connect
+	// the seller, which must be the application's OAuth redirect URL.
+	// Empty uses the one set in the console.
+	RedirectURL string
+	// APIURL is the base URL of the API, production or sandbox
+	APIURL     string
+	APIVersion string
+	// CompanyID is the company the seller's sales are recorded as
+	CompanyID int
+	// CredentialsKey encrypts the seller's tokens in the database
+	CredentialsKey *integrations.CredentialsKey
+}
+
+// ConfigFromEnv reads SQUARE_APPLICATION_ID, SQUARE_APPLICATION_SECRET,
+// SQUARE_COMPANY_ID, INTEGRATION_CREDENTIALS_KEY, SQUARE_REDIRECT_URL,
+// SQUARE_ENVIRONMENT (production or sandbox, default production),
+// SQUARE_API_URL (overriding the environment's) and SQUARE_API_VERSION
+// (default 2024-10-17). It reports false when
+// SQUARE_APPLICATION_ID isn't set and the integration is disabled.
+func ConfigFromEnv() (Config, bool, error) {
+	config := Config{
+		ApplicationID:     os.Getenv("SQUARE_APPLICATION_ID"),
+		ApplicationSecret: os.Getenv("SQUARE_APPLICATION_SECRET"),
+		RedirectURL:       os.Getenv("SQUARE_REDIRECT_URL"),
+		APIURL:            os.Getenv("SQUARE_API_URL"),
+		APIVersion:        os.Getenv("SQUARE_API_VERSION"),
+	}
+	if config.ApplicationID == "" {
+		return config, false, nil
+	}
+
+	if config.ApplicationSecret == "" {
+		return config, false, fmt.Errorf("SQUARE_APPLICATION_SECRET is required when SQUARE_APPLICATION_ID is set")
+	}
+	value := os.Getenv("SQUARE_COMPANY_ID")
+	companyID, err := strconv.Atoi(value)
+	if err != nil || companyID < 1 {
+		return config, false, fmt.Errorf("invalid SQUARE_COMPANY_ID %q, use the ID of the company the seller's sales are recorded as", value)
+	}
+	config.CompanyID = companyID
+	if config.CredentialsKey, err = integrations.ParseCredentialsKey(os.Getenv("INTEGRATION_CREDENTIALS_KEY")); err != nil {
+		return config, false, fmt.Errorf("invalid INTEGRATION_CREDENTIALS_KEY, required when SQUARE_APPLICATION_ID is set: %v", err)
+	}
+
+	if config.APIURL == "" {
+		switch environment := os.Getenv("SQUARE_ENVIRONMENT"); environment {
+		case "", "production":
+			config.APIURL = productionURL
+		case "sandbox":
+			config.APIURL = sandboxURL
+		default:
+			return config, false, fmt.Errorf("invalid SQUARE_ENVIRONMENT %q, use production or sandbox", environment)
+		}
+	}
+	if config.APIVersion == "" {
+		config.APIVersion = defaultAPIVersion
+	}
+	return config, true, nil
+}
+
+// Syncer saves a seller's orders
+type Syncer struct {
+	config Config
+	client *Client
+	store  *integrations.Store
+}
+
+// NewSyncer returns a syncer of the seller connected to the application in
+// config into db
+func NewSyncer(db *sql.DB, config Config) *Syncer {
+	return &Syncer{config: config, client: NewClient(config), store: integrations.NewStore(db, Source, config.CompanyID)}
+}
+
+// Sync saves the orders of every location updated since the last sync. The
+// cursor only moves once every location is synced, so a failed sync starts
+// over where the last one finished; saving an order again updates it. The
+// first sync saves every order. Before a seller is connected there is
+// nothing to sync.
+func (s *Syncer) Sync(ctx context.Context) error {
+	token, connected, err := s.token(ctx)
+	if err != nil {
+		return err
+	}
+	if !connected {
+		log.Printf("Square isn't connected, skipping sync")
+		return nil
+	}
+
+	cursor, err := s.store.Cursor(ctx)
+	if err != nil {
+		return err
+	}
+	var since time.Time
+	if cursor != "" {
+		if since, err = time.Parse(time.RFC3339, cursor); err != nil {
+			return fmt.Errorf("invalid Square sync cursor %q: %v", cursor, err)
+		}
+	}
+
+	locations, err := s.client.Locations(ctx, token)
+	if err != nil {
+		return err
+	}
+	latest := since
+	synced := 0
+	err = s.client.SearchOrders(ctx, token, locations, since, func(orders []Order) error {
+		if err := s.SaveOrders(ctx, token, orders); err != nil {
+			return err
+		}
+		for _, order := range orders {
+			if order.UpdatedAt.After(latest) {
+				latest = order.UpdatedAt
+			}
+		}
+		synced += len(orders)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The next sync starts at the last order again, as the filter is
+	// inclusive, so orders updated at the same time aren't missed
+	if latest.After(since) {
+		if err := s.store.SaveCursor(ctx, latest.UTC().Format(time.RFC3339)); err != nil {
+			return err
+		}
+	}
+	log.Printf("Synced %d Square orders from %d locations", synced, len(locations))
+	return nil
+}
+
+// SaveOrders saves orders and their returns. Orders that can't be mapped are
+// logged and skipped, so they don't hold up the orders after them.
+func (s *Syncer) SaveOrders(ctx context.Context, token string, orders []Order) error {
+	catalog, err := s.client.Catalog(ctx, token, variationIDs(orders))
+	if err != nil {
+		return err
+	}
+	customers, err := s.client.Customers(ctx, token, customerIDs(orders))
+	if err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		transactions, remove, err := order.transactions(catalog, customers)
+		if err != nil {
+			log.Printf("Warning: skipping Square order %s: %v", order.ID, err)
+			continue
+		}
+		if remove {
+			if err := s.removeOrder(ctx, order); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, transaction := range transactions {
+			if _, err := s.store.Save(ctx, transaction); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// removeOrder deletes an order saved before and its returns
+func (s *Syncer) removeOrder(ctx context.Context, order Order) error {
+	if err := s.store.Delete(ctx, kindOrder, order.ID); err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, kindReturn, order.ID)
+}
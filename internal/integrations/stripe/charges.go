@@ -0,0 +1,168 @@
+package stripe
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/integrations"
+)
+
+// Kinds of the transactions saved from Stripe. A charge's refund is linked by
+// the charge's ID, as all its refunds are saved as one transaction.
+const (
+	kindCharge = "charge"
+	kindRefund = "refund"
+)
+
+// unattributedProduct is the product of charges without a SKU in their
+// metadata
+var unattributedProduct = integrations.Product{ExternalID: "unattributed", Name: "Stripe payments"}
+
+// zeroDecimalCurrencies are the currencies whose amounts Stripe sends in
+// units rather than hundredths
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true,
+	"KRW": true, "MGA": true, "PYG": true, "RWF": true, "UGX": true, "VND": true,
+	"VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// threeDecimalCurrencies are the currencies whose amounts Stripe sends in
+// thousandths
+var threeDecimalCurrencies = map[string]bool{
+	"BHD": true, "JOD": true, "KWD": true, "OMR": true, "TND": true,
+}
+
+// Charge is a charge of the Stripe API, with the fields the ingester maps.
+// Amounts are in the currency's smallest unit.
+type Charge struct {
+	ID string `json:"id"`
+	// Created is a Unix time
+	Created int64 `json:"created"`
+	// Status is succeeded, pending, or failed
+	Status   string `json:"status"`
+	Captured bool   `json:"captured"`
+	// Amount is the amount charged and AmountRefunded how much of it was
+	// refunded so far
+	Amount         int64  `json:"amount"`
+	AmountRefunded int64  `json:"amount_refunded"`
+	Currency       string `json:"currency"`
+	// Customer is the ID of the Stripe customer charged, if any
+	Customer       string         `json:"customer"`
+	BillingDetails BillingDetails `json:"billing_details"`
+	ReceiptEmail   string         `json:"receipt_email"`
+	// Metadata describes what was sold: sku, product, category, and
+	// quantity
+	Metadata map[string]string `json:"metadata"`
+}
+
+// BillingDetails is who paid a charge
+type BillingDetails struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// transaction maps a charge to a sale of the product in its metadata: an
+// invoice once captured, pending before. It reports true instead when the
+// charge shouldn't be recorded, as it failed or was released uncaptured.
+func (c Charge) transaction() (integrations.Transaction, bool, error) {
+	if c.Status == "failed" || (c.Status == "succeeded" && !c.Captured && c.AmountRefunded >= c.Amount) {
+		return integrations.Transaction{}, true, nil
+	}
+	if c.Amount <= 0 {
+		return integrations.Transaction{}, false, fmt.Errorf("charge %s has no amount", c.ID)
+	}
+
+	status := "invoice"
+	if c.Status == "pending" || !c.Captured {
+		status = "pending"
+	}
+
+	quantity := 1
+	if value, err := strconv.Atoi(c.Metadata["quantity"]); err == nil && value > 0 {
+		quantity = value
+	}
+	total := c.amount(c.Amount)
+	product := unattributedProduct
+	if sku := strings.TrimSpace(c.Metadata["sku"]); sku != "" {
+		product = integrations.Product{
+			SKU:      sku,
+			Name:     c.Metadata["product"],
+			Category: c.Metadata["category"],
+			Price:    round(total / float64(quantity)),
+		}
+		if product.Name == "" {
+			product.Name = sku
+		}
+	}
+
+	return integrations.Transaction{
+		Kind:       kindCharge,
+		ExternalID: c.ID,
+		Date:       time.Unix(c.Created, 0),
+		Status:     status,
+		Currency:   strings.ToUpper(c.Currency),
+		Customer:   c.customer(),
+		Items:      []integrations.Item{{Product: product, Quantity: quantity, TotalAmount: total}},
+	}, false, nil
+}
+
+// refund maps the amount refunded of a charge so far to a refund of the
+// charge's sale, dated at the latest refund. Refunded units are prorated, so
+// a partial refund of one unit refunds none. It reports false when nothing
+// is refunded.
+func (c Charge) refund(sale integrations.Transaction, refunded time.Time) (integrations.Transaction, bool) {
+	if c.AmountRefunded <= 0 {
+		return integrations.Transaction{}, false
+	}
+	item := sale.Items[0]
+	item.Quantity = int(int64(item.Quantity) * min(c.AmountRefunded, c.Amount) / c.Amount)
+	item.TotalAmount = c.amount(c.AmountRefunded)
+
+	refund := sale
+	refund.Kind = kindRefund
+	refund.Date = refunded
+	refund.Status = "refund"
+	refund.Items = []integrations.Item{item}
+	return refund, true
+}
+
+// customer returns the customer who paid a charge, or nil when the charge
+// identifies no one
+func (c Charge) customer() *integrations.Customer {
+	email := c.BillingDetails.Email
+	if email == "" {
+		email = c.ReceiptEmail
+	}
+	if c.Customer == "" && email == "" {
+		return nil
+	}
+	firstName, lastName, _ := strings.Cut(strings.TrimSpace(c.BillingDetails.Name), " ")
+	return &integrations.Customer{
+		ExternalID: c.Customer,
+		FirstName:  firstName,
+		LastName:   strings.TrimSpace(lastName),
+		Email:      email,
+		Phone:      c.BillingDetails.Phone,
+	}
+}
+
+// amount converts an amount in the charge currency's smallest unit
+func (c Charge) amount(value int64) float64 {
+	currency := strings.ToUpper(c.Currency)
+	switch {
+	case zeroDecimalCurrencies[currency]:
+		return float64(value)
+	case threeDecimalCurrencies[currency]:
+		return float64(value) / 1000
+	}
+	return float64(value) / 100
+}
+
+// round rounds an amount to cents
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
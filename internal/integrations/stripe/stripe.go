@@ -0,0 +1,104 @@
+// Package stripe records the charges and refunds of a Stripe account as
+// sales, from the account's charge webhooks
+package stripe
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/integrations"
+)
+
+// Source is the integration_links key of the Stripe integration
+const Source = "stripe"
+
+// defaultTolerance is how old a webhook's signature may be without
+// STRIPE_WEBHOOK_TOLERANCE, as in Stripe's libraries
+const defaultTolerance = 5 * time.Minute
+
+// Config is the Stripe account whose charges are recorded
+type Config struct {
+	// WebhookSecret is the signing secret of the webhook endpoint
+	WebhookSecret string
+	// CompanyID is the company the account's sales are recorded as
+	CompanyID int
+	// Tolerance is how old a webhook's signature may be, against replays
+	Tolerance time.Duration
+	// TestMode records the events of test mode instead of live mode
+	TestMode bool
+}
+
+// ConfigFromEnv reads STRIPE_WEBHOOK_SECRET, STRIPE_COMPANY_ID,
+// STRIPE_WEBHOOK_TOLERANCE (default 5m) and STRIPE_TEST_MODE. It reports
+// false when STRIPE_WEBHOOK_SECRET isn't set and the integration is disabled.
+func ConfigFromEnv() (Config, bool, error) {
+	config := Config{
+		WebhookSecret: os.Getenv("STRIPE_WEBHOOK_SECRET"),
+		Tolerance:     defaultTolerance,
+		TestMode:      os.Getenv("STRIPE_TEST_MODE") == "true",
+	}
+	if config.WebhookSecret == "" {
+		return config, false, nil
+	}
+
+	value := os.Getenv("STRIPE_COMPANY_ID")
+	companyID, err := strconv.Atoi(value)
+	if err != nil || companyID < 1 {
+		return config, false, fmt.Errorf("invalid STRIPE_COMPANY_ID %q, use the ID of the company the account's sales are recorded as", value)
+	}
+	config.CompanyID = companyID
+
+	if value := os.Getenv("STRIPE_WEBHOOK_TOLERANCE"); value != "" {
+		config.Tolerance, err = time.ParseDuration(value)
+		if err != nil || config.Tolerance <= 0 {
+			return config, false, fmt.Errorf("invalid STRIPE_WEBHOOK_TOLERANCE %q", value)
+		}
+	}
+	return config, true, nil
+}
+
+// Ingester saves an account's charges and refunds
+type Ingester struct {
+	config Config
+	store  *integrations.Store
+}
+
+// NewIngester returns an ingester of the account in config into db
+func NewIngester(db *sql.DB, config Config) *Ingester {
+	return &Ingester{config: config, store: integrations.NewStore(db, Source, config.CompanyID)}
+}
+
+// SaveCharge saves a charge as a sale, or deletes it when it failed or
+// expired uncaptured. When refunded is set, the charge was just refunded at
+// that time, and the amount refunded so far is saved as its refund.
+func (i *Ingester) SaveCharge(ctx context.Context, charge Charge, refunded time.Time) error {
+	sale, remove, err := charge.transaction()
+	if err != nil {
+		return err
+	}
+	if remove {
+		if err := i.store.Delete(ctx, kindCharge, charge.ID); err != nil {
+			return err
+		}
+		return i.store.Delete(ctx, kindRefund, charge.ID)
+	}
+	if _, err := i.store.Save(ctx, sale); err != nil {
+		return err
+	}
+
+	if refunded.IsZero() {
+		return nil
+	}
+	// A refund reversed in full, e.g. one that failed, leaves nothing
+	// refunded
+	refund, ok := charge.refund(sale, refunded)
+	if !ok {
+		return i.store.Delete(ctx, kindRefund, charge.ID)
+	}
+	_, err = i.store.Save(ctx, refund)
+	return err
+}
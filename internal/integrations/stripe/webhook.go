@@ -0,0 +1,130 @@
+package stripe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+)
+
+// maxWebhookSize is the largest webhook body read, well above any event's
+const maxWebhookSize = 1 << 20
+
+// Event is a webhook event of the Stripe API
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Created is a Unix time
+	Created  int64 `json:"created"`
+	Livemode bool  `json:"livemode"`
+	Data     struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// WebhookHandler receives the account's charge webhooks: charge.succeeded,
+// charge.pending, charge.captured, charge.updated, charge.failed,
+// charge.expired, and charge.refunded. Webhooks are verified with the
+// WebhookSecret, and events of the other mode than the configured one are
+// acknowledged without being recorded.
+//
+// Stripe retries webhooks that don't get a 2xx response, so failures to save
+// respond 500 to have the event delivered again.
+func (i *Ingester) WebhookHandler() echo.HandlerFunc {
+	return func(c echo.Context) error {
+		request := c.Request()
+		body, err := io.ReadAll(io.LimitReader(request.Body, maxWebhookSize))
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, "Failed to read webhook")
+		}
+		if !validSignature([]byte(i.config.WebhookSecret), body, request.Header.Get("Stripe-Signature"), time.Now(), i.config.Tolerance) {
+			return httperror.JSON(c, http.StatusUnauthorized, "Invalid webhook signature")
+		}
+
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid event")
+		}
+		if event.Livemode == i.config.TestMode {
+			log.Printf("Ignoring Stripe event %s from the other mode", event.ID)
+			return c.NoContent(http.StatusOK)
+		}
+
+		switch event.Type {
+		case "charge.succeeded", "charge.pending", "charge.captured", "charge.updated",
+			"charge.failed", "charge.expired", "charge.refunded":
+		default:
+			// Acknowledge event types the endpoint is subscribed to that
+			// aren't recorded so Stripe doesn't retry them
+			log.Printf("Ignoring Stripe %q event %s", event.Type, event.ID)
+			return c.NoContent(http.StatusOK)
+		}
+
+		var charge Charge
+		if err := json.Unmarshal(event.Data.Object, &charge); err != nil || charge.ID == "" {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid charge")
+		}
+		var refunded time.Time
+		switch event.Type {
+		case "charge.refunded":
+			refunded = time.Unix(event.Created, 0)
+		case "charge.expired":
+			// Expired charges were never captured, whatever their status
+			// says
+			charge.Status = "failed"
+		}
+		if err := i.SaveCharge(request.Context(), charge, refunded); err != nil {
+			log.Printf("Failed to save Stripe charge %s from event %s: %v", charge.ID, event.ID, err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to save charge")
+		}
+		return c.NoContent(http.StatusOK)
+	}
+}
+
+// validSignature reports whether a Stripe-Signature header signs body with
+// secret, at a time within tolerance of now. The header holds the time as t
+// and one or more v1 signatures, the hex HMAC-SHA256 of "{t}.{body}".
+func validSignature(secret, body []byte, header string, now time.Time, tolerance time.Duration) bool {
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			if signature, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(secret) == 0 {
+		return false
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package stripe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidSignature(t *testing.T) {
+	const (
+		body = `{"id":"evt_1","type":"charge.succeeded"}`
+		// signed is the HMAC-SHA256 of "1760000000.{body}" with whsec_test,
+		// and other its HMAC with another secret
+		signed = "b700c5b906adaf068b9d56e5baae4279ff43342feb5da80dc6d21ee2549bdaff"
+		other  = "89e033d8db7f7f0414f6362eadcdaa9b8cafbfef4f3e6cef57785c9aa13c666c"
+	)
+	signedAt := time.Unix(1760000000, 0)
+
+	for _, test := range []struct {
+		name   string
+		secret string
+		header string
+		body   string
+		now    time.Time
+		want   bool
+	}{
+		{"valid", "whsec_test", "t=1760000000,v1=" + signed, body, signedAt, true},
+		{"spaces and unknown keys", "whsec_test", "t=1760000000, v0=abc, v1=" + signed, body, signedAt, true},
+		{"v1 after another v1", "whsec_test", "t=1760000000,v1=" + other + ",v1=" + signed, body, signedAt, true},
+		{"only another secret's v1", "whsec_test", "t=1760000000,v1=" + other, body, signedAt, false},
+		{"v1 that isn't hex", "whsec_test", "t=1760000000,v1=zz,v1=" + signed, body, signedAt, true},
+		{"changed body", "whsec_test", "t=1760000000,v1=" + signed, body + " ", signedAt, false},
+		{"changed timestamp", "whsec_test", "t=1760000001,v1=" + signed, body, signedAt, false},
+		{"at the tolerance", "whsec_test", "t=1760000000,v1=" + signed, body, signedAt.Add(5 * time.Minute), true},
+		{"too old", "whsec_test", "t=1760000000,v1=" + signed, body, signedAt.Add(5*time.Minute + time.Second), false},
+		{"from the future", "whsec_test", "t=1760000000,v1=" + signed, body, signedAt.Add(-5*time.Minute - time.Second), false},
+		{"no timestamp", "whsec_test", "v1=" + signed, body, signedAt, false},
+		{"no signature", "whsec_test", "t=1760000000", body, signedAt, false},
+		{"empty header", "whsec_test", "", body, signedAt, false},
+		{"empty secret", "", "t=1760000000,v1=" + signed, body, signedAt, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := validSignature([]byte(test.secret), []byte(test.body), test.header, test.now, 5*time.Minute)
+			if got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
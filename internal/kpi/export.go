@@ -0,0 +1,105 @@
+package kpi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// gauge is a single named KPI value with optional labels
+type gauge struct {
+	name   string
+	help   string
+	labels map[string]string
+	value  float64
+}
+
+// gauges flattens a snapshot into the exported gauges
+func (s Snapshot) gauges() []gauge {
+	categories := make([]string, 0, len(s.RevenueByCategory))
+	for category := range s.RevenueByCategory {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	gauges := make([]gauge, 0, len(categories)+5)
+	for _, category := range categories {
+		gauges = append(gauges, gauge{
+			name:   "craft_revenue_yesterday",
+			help:   "Net revenue for yesterday (UTC) per category",
+			labels: map[string]string{"category": category},
+			value:  s.RevenueByCategory[category],
+		})
+	}
+
+	return append(gauges,
+		gauge{name: "craft_revenue_yesterday_total", help: "Net revenue for yesterday (UTC) across all categories", value: s.Revenue},
+		gauge{name: "craft_forecast_revenue_yesterday", help: "Baseline forecast of yesterday's net revenue", value: s.ForecastRevenue},
+		gauge{name: "craft_forecast_deviation_ratio", help: "Relative deviation of yesterday's revenue from the baseline forecast", value: s.ForecastDeviation},
+		gauge{name: "craft_refund_rate_yesterday", help: "Refunded revenue divided by gross revenue for yesterday", value: s.RefundRate},
+		gauge{name: "craft_kpi_collected_timestamp_seconds", help: "When the KPIs were last computed", value: float64(s.CollectedAt.Unix())},
+	)
+}
+
+// WritePrometheus writes the snapshot in the Prometheus text exposition format
+func WritePrometheus(w io.Writer, s Snapshot) error {
+	var buf bytes.Buffer
+	previous := ""
+	for _, g := range s.gauges() {
+		if g.name != previous {
+			fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+			previous = g.name
+		}
+		buf.WriteString(g.name)
+		if category, ok := g.labels["category"]; ok {
+			fmt.Fprintf(&buf, `{category="%s"}`, escapeLabel(category))
+		}
+		fmt.Fprintf(&buf, " %g\n", g.value)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// escapeLabel escapes a Prometheus label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// PushStatsD sends the snapshot as StatsD gauges over UDP to addr. Names are
+// dotted and prefixed, with the category appended to the per-category gauge
+// (e.g. craft.revenue_yesterday.electronics).
+func PushStatsD(addr, prefix string, s Snapshot) error {
+	conn, err := net.DialTimeout("udp", addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to StatsD at %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	for _, g := range s.gauges() {
+		name := prefix + "." + strings.TrimPrefix(g.name, "craft_")
+		if category, ok := g.labels["category"]; ok {
+			name += "." + statsdSegment(category)
+		}
+		if _, err := fmt.Fprintf(conn, "%s:%g|g\n", name, g.value); err != nil {
+			return fmt.Errorf("failed to send %s to StatsD: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// statsdSegment lowercases a name and replaces characters StatsD treats
+// specially
+func statsdSegment(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ':', '|', '@', ' ', '/':
+			return '_'
+		}
+		return r
+	}, strings.ToLower(name))
+}
@@ -0,0 +1,140 @@
+package kpi
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/forecast"
+)
+
+// baselineDays is how many days before the KPI day the baseline forecast
+// is fitted on
+const baselineDays = 28
+
+// Snapshot holds the business KPIs for a single day, normally yesterday
+type Snapshot struct {
+	Day string
+	// RevenueByCategory is the net revenue per category name
+	RevenueByCategory map[string]float64
+	// Revenue is the net revenue across all categories
+	Revenue float64
+	// ForecastRevenue is the baseline forecast of Revenue made from the
+	// preceding days
+	ForecastRevenue float64
+	// ForecastDeviation is (Revenue - ForecastRevenue) / ForecastRevenue, or 0
+	// when nothing was forecast
+	ForecastDeviation float64
+	// RefundRate is refunded revenue divided by gross revenue
+	RefundRate  float64
+	CollectedAt time.Time
+}
+
+// Collect computes the KPIs for the day before now from the DW table
+func Collect(db *sql.DB, now time.Time) (Snapshot, error) {
+	day := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	snapshot := Snapshot{
+		Day:               day.Format("2006-01-02"),
+		RevenueByCategory: make(map[string]float64),
+		CollectedAt:       now,
+	}
+
+	rows, err := db.Query(`
+		SELECT c.name, SUM(st.total_amount)
+		FROM sales_totals_by_category_dw st
+		JOIN categories c ON st.category_id = c.id
+		WHERE DATE(st.date_recorded) = $1 AND st.deleted_at IS NULL
+		GROUP BY c.name
+	`, snapshot.Day)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to query category revenue: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			category string
+			revenue  float64
+		)
+		if err := rows.Scan(&category, &revenue); err != nil {
+			return snapshot, fmt.Errorf("failed to scan row: %v", err)
+		}
+		snapshot.RevenueByCategory[category] = revenue
+		snapshot.Revenue += revenue
+	}
+
+	if err := rows.Err(); err != nil {
+		return snapshot, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	// Refunds are stored as negative amounts in the DW
+	var gross, refunded float64
+	err = db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN total_amount > 0 THEN total_amount ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN total_amount < 0 THEN -total_amount ELSE 0 END), 0)
+		FROM sales_totals_by_category_dw
+		WHERE DATE(date_recorded) = $1 AND deleted_at IS NULL
+	`, snapshot.Day).Scan(&gross, &refunded)
+	if err != nil {
+		return snapshot, fmt.Errorf("failed to query refunds: %v", err)
+	}
+	if gross > 0 {
+		snapshot.RefundRate = refunded / gross
+	}
+
+	history, err := dailyRevenue(db, day.AddDate(0, 0, -baselineDays), day)
+	if err != nil {
+		return snapshot, err
+	}
+	// A fixed seed keeps the baseline stable between scrapes
+	forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(1)), nil)
+	if predicted := forecaster.Forecast(history, "day", 1); len(predicted) == 1 {
+		snapshot.ForecastRevenue = predicted[0].Total
+	}
+	if snapshot.ForecastRevenue > 0 {
+		snapshot.ForecastDeviation = (snapshot.Revenue - snapshot.ForecastRevenue) / snapshot.ForecastRevenue
+	}
+
+	return snapshot, nil
+}
+
+// dailyRevenue returns the net revenue for every day from start up to but not
+// including end, with zeros for days without sales
+func dailyRevenue(db *sql.DB, start, end time.Time) ([]forecast.Point, error) {
+	rows, err := db.Query(`
+		SELECT DATE(date_recorded), SUM(total_amount)
+		FROM sales_totals_by_category_dw
+		WHERE date_recorded >= $1 AND date_recorded < $2 AND deleted_at IS NULL
+		GROUP BY DATE(date_recorded)
+	`, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily revenue: %v", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]float64)
+	for rows.Next() {
+		var (
+			date    time.Time
+			revenue float64
+		)
+		if err := rows.Scan(&date, &revenue); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		totals[date.Format("2006-01-02")] = revenue
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	var history []forecast.Point
+	for date := start; date.Before(end); date = date.AddDate(0, 0, 1) {
+		period := date.Format("2006-01-02")
+		history = append(history, forecast.Point{Period: period, Total: math.Round(totals[period]*100) / 100})
+	}
+	return history, nil
+}
@@ -0,0 +1,48 @@
+// Package lifecycle runs the hooks that release the server's resources when
+// it shuts down
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// Hooks runs shutdown hooks in the reverse order they were added, so a
+// resource is released after everything added later that uses it
+type Hooks struct {
+	mu    sync.Mutex
+	hooks []hook
+}
+
+type hook struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// OnShutdown adds a hook named for the logs
+func (h *Hooks) OnShutdown(name string, run func(ctx context.Context) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, hook{name: name, run: run})
+}
+
+// Shutdown runs every hook once within ctx, newest first. A failing hook
+// doesn't stop the others; their errors are returned together.
+func (h *Hooks) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	hooks := h.hooks
+	h.hooks = nil
+	h.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		log.Printf("Shutdown: %s", hooks[i].name)
+		if err := hooks[i].run(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hooks[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,197 @@
+// Package notify sends notifications to Slack, email, and generic webhooks
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Channel names
+const (
+	ChannelSlack   = "slack"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Message is a notification
+type Message struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+	// Link points at the data the notification is about
+	Link string `json:"link,omitempty"`
+}
+
+// Notifier delivers messages to one channel
+type Notifier interface {
+	Notify(ctx context.Context, message Message) error
+}
+
+// Channels maps channel names to their notifiers
+type Channels map[string]Notifier
+
+// ChannelsFromEnv returns the channels configured in the environment:
+//
+//	ALERT_SLACK_WEBHOOK_URL  Slack incoming webhook
+//	ALERT_WEBHOOK_URL        URL that receives each message as JSON
+//	ALERT_SMTP_ADDR          SMTP server host:port for email, with
+//	                         ALERT_EMAIL_FROM, ALERT_EMAIL_TO (comma-separated)
+//	                         and optionally ALERT_SMTP_USERNAME and
+//	                         ALERT_SMTP_PASSWORD
+func ChannelsFromEnv() (Channels, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	channels := Channels{}
+
+	if url := os.Getenv("ALERT_SLACK_WEBHOOK_URL"); url != "" {
+		channels[ChannelSlack] = &slack{url: url, client: client}
+	}
+	if url := os.Getenv("ALERT_WEBHOOK_URL"); url != "" {
+		channels[ChannelWebhook] = &webhook{url: url, client: client}
+	}
+	if addr := os.Getenv("ALERT_SMTP_ADDR"); addr != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ALERT_SMTP_ADDR %q: %v", addr, err)
+		}
+		from := os.Getenv("ALERT_EMAIL_FROM")
+		var to []string
+		for _, address := range strings.Split(os.Getenv("ALERT_EMAIL_TO"), ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				to = append(to, address)
+			}
+		}
+		if from == "" || len(to) == 0 {
+			return nil, fmt.Errorf("ALERT_EMAIL_FROM and ALERT_EMAIL_TO are required with ALERT_SMTP_ADDR")
+		}
+
+		email := &email{addr: addr, from: from, to: to}
+		if username := os.Getenv("ALERT_SMTP_USERNAME"); username != "" {
+			email.auth = smtp.PlainAuth("", username, os.Getenv("ALERT_SMTP_PASSWORD"), host)
+		}
+		channels[ChannelEmail] = email
+	}
+
+	return channels, nil
+}
+
+// Send delivers message to the named channels, or to every channel when
+// names is empty. Unknown or unconfigured names are skipped with a warning.
+// When no channel is configured the message is only logged.
+func (c Channels) Send(ctx context.Context, names []string, message Message) error {
+	if len(c) == 0 {
+		log.Printf("Notification (no channels configured): %s: %s %s", message.Title, message.Text, message.Link)
+		return nil
+	}
+	if len(names) == 0 {
+		names = slices.Sorted(maps.Keys(c))
+	}
+
+	var failed []string
+	for _, name := range names {
+		notifier, ok := c[name]
+		if !ok {
+			log.Printf("Warning: notification channel %q is not configured", name)
+			continue
+		}
+		if err := notifier.Notify(ctx, message); err != nil {
+			log.Printf("Failed to send %s notification: %v", name, err)
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to notify %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// SendEmail delivers message by email to the given addresses instead of
+// ALERT_EMAIL_TO, using the email channel's server and sender
+func (c Channels) SendEmail(ctx context.Context, to []string, message Message) error {
+	channel, ok := c[ChannelEmail].(*email)
+	if !ok {
+		return fmt.Errorf("email is not configured, set ALERT_SMTP_ADDR")
+	}
+	recipients := *channel
+	recipients.to = to
+	return recipients.Notify(ctx, message)
+}
+
+// slack posts to a Slack incoming webhook
+type slack struct {
+	url    string
+	client *http.Client
+}
+
+func (s *slack) Notify(ctx context.Context, message Message) error {
+	text := fmt.Sprintf("*%s*\n%s", message.Title, message.Text)
+	if message.Link != "" {
+		text += fmt.Sprintf("\n<%s|View report>", message.Link)
+	}
+	return postJSON(ctx, s.client, s.url, map[string]string{"text": text})
+}
+
+// webhook posts the message as JSON
+type webhook struct {
+	url    string
+	client *http.Client
+}
+
+func (w *webhook) Notify(ctx context.Context, message Message) error {
+	return postJSON(ctx, w.client, w.url, message)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// email sends plain text mail over SMTP
+type email struct {
+	addr string
+	from string
+	to   []string
+	auth smtp.Auth
+}
+
+func (e *email) Notify(ctx context.Context, message Message) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", e.from)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(e.to, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", message.Title)
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	body.WriteString(message.Text)
+	if message.Link != "" {
+		fmt.Fprintf(&body, "\r\n\r\n%s", message.Link)
+	}
+	body.WriteString("\r\n")
+
+	return smtp.SendMail(e.addr, e.auth, e.from, e.to, []byte(body.String()))
+}
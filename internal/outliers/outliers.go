@@ -0,0 +1,179 @@
+// Package outliers flags and optionally winsorizes outliers in a time series
+// before it reaches the forecasting prompt or models
+package outliers
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Method names a detection method
+type Method string
+
+// Supported methods
+const (
+	// MethodZScore flags values more than Threshold standard deviations
+	// from the mean
+	MethodZScore Method = "zscore"
+	// MethodIQR flags values more than Threshold interquartile ranges
+	// outside the quartiles
+	MethodIQR Method = "iqr"
+	// MethodHampel flags values more than Threshold scaled median absolute
+	// deviations from the median of the Window values around them
+	MethodHampel Method = "hampel"
+)
+
+// Default thresholds and Hampel window
+const (
+	DefaultZScoreThreshold = 3
+	DefaultIQRThreshold    = 1.5
+	DefaultHampelThreshold = 3
+	DefaultHampelWindow    = 7
+)
+
+// madScale makes the median absolute deviation a consistent estimator of
+// the standard deviation for normal data
+const madScale = 1.4826
+
+// Options configures detection
+type Options struct {
+	Method Method
+	// Threshold defaults to the method's default threshold
+	Threshold float64
+	// Window is the number of values in a Hampel window, centered on the
+	// value being checked
+	Window int
+	// Winsorize clips outliers to the nearest bound instead of only
+	// flagging them
+	Winsorize bool
+}
+
+// Outlier is a flagged value with the bounds it fell outside
+type Outlier struct {
+	Index int
+	Value float64
+	Lower float64
+	Upper float64
+	// Treated is the value after treatment: the nearest bound when
+	// winsorizing, or Value otherwise
+	Treated float64
+}
+
+// Detect flags the outliers in values
+func Detect(values []float64, options Options) ([]Outlier, error) {
+	if options.Threshold < 0 {
+		return nil, fmt.Errorf("outlier threshold must not be negative")
+	}
+	if options.Window < 0 {
+		return nil, fmt.Errorf("outlier window must not be negative")
+	}
+
+	var bounds func(i int) (float64, float64)
+	switch Method(strings.ToLower(string(options.Method))) {
+	case MethodZScore:
+		lower, upper := zScoreBounds(values, orDefault(options.Threshold, DefaultZScoreThreshold))
+		bounds = func(int) (float64, float64) { return lower, upper }
+	case MethodIQR:
+		lower, upper := iqrBounds(values, orDefault(options.Threshold, DefaultIQRThreshold))
+		bounds = func(int) (float64, float64) { return lower, upper }
+	case MethodHampel:
+		window := options.Window
+		if window == 0 {
+			window = DefaultHampelWindow
+		}
+		threshold := orDefault(options.Threshold, DefaultHampelThreshold)
+		bounds = func(i int) (float64, float64) { return hampelBounds(values, i, window, threshold) }
+	default:
+		return nil, fmt.Errorf("unknown outlier method %q", options.Method)
+	}
+
+	var outliers []Outlier
+	for i, value := range values {
+		lower, upper := bounds(i)
+		if value >= lower && value <= upper {
+			continue
+		}
+		treated := value
+		if options.Winsorize {
+			treated = math.Min(math.Max(value, lower), upper)
+		}
+		outliers = append(outliers, Outlier{Index: i, Value: value, Lower: lower, Upper: upper, Treated: treated})
+	}
+	return outliers, nil
+}
+
+// Apply returns a copy of values with each outlier replaced by its treated
+// value
+func Apply(values []float64, outliers []Outlier) []float64 {
+	treated := slices.Clone(values)
+	for _, outlier := range outliers {
+		treated[outlier.Index] = outlier.Treated
+	}
+	return treated
+}
+
+func orDefault(value, fallback float64) float64 {
+	if value == 0 {
+		return fallback
+	}
+	return value
+}
+
+// zScoreBounds returns mean ± threshold standard deviations
+func zScoreBounds(values []float64, threshold float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+	var variance float64
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	sd := math.Sqrt(variance / float64(len(values)))
+	return mean - threshold*sd, mean + threshold*sd
+}
+
+// iqrBounds returns Tukey's fences at threshold interquartile ranges
+func iqrBounds(values []float64, threshold float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sorted := slices.Sorted(slices.Values(values))
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	iqr := q3 - q1
+	return q1 - threshold*iqr, q3 + threshold*iqr
+}
+
+// hampelBounds returns the median ± threshold scaled MADs of the window
+// centered on index i
+func hampelBounds(values []float64, i, window int, threshold float64) (float64, float64) {
+	half := window / 2
+	from, to := max(0, i-half), min(len(values), i+half+1)
+	neighbours := slices.Sorted(slices.Values(values[from:to]))
+	median := quantile(neighbours, 0.5)
+
+	deviations := make([]float64, len(neighbours))
+	for j, value := range neighbours {
+		deviations[j] = math.Abs(value - median)
+	}
+	slices.Sort(deviations)
+	mad := madScale * quantile(deviations, 0.5)
+	return median - threshold*mad, median + threshold*mad
+}
+
+// quantile interpolates the q quantile of sorted values
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	position := q * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	upper := int(math.Ceil(position))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(position-float64(lower))
+}
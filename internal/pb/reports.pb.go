@@ -0,0 +1,1517 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: craftdemo/v1/reports.proto
+
+// Protobuf encodings of the report and forecast responses, served when a
+// client sends Accept: application/x-protobuf. Field names and meanings match
+// the JSON responses; the date-keyed JSON maps become repeated periods sorted
+// by date.
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// CategoryTotal is a category's revenue for one date
+type CategoryTotal struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	CategoryName string                 `protobuf:"bytes,1,opt,name=category_name,json=categoryName,proto3" json:"category_name,omitempty"`
+	// total_amount is the net revenue: gross_sales less discounts and refunds
+	TotalAmount       float64 `protobuf:"fixed64,2,opt,name=total_amount,json=totalAmount,proto3" json:"total_amount,omitempty"`
+	GrossSales        float64 `protobuf:"fixed64,3,opt,name=gross_sales,json=grossSales,proto3" json:"gross_sales,omitempty"`
+	Discounts         float64 `protobuf:"fixed64,4,opt,name=discounts,proto3" json:"discounts,omitempty"`
+	Refunds           float64 `protobuf:"fixed64,5,opt,name=refunds,proto3" json:"refunds,omitempty"`
+	UnitsSold         int64   `protobuf:"varint,6,opt,name=units_sold,json=unitsSold,proto3" json:"units_sold,omitempty"`
+	Transactions      int64   `protobuf:"varint,7,opt,name=transactions,proto3" json:"transactions,omitempty"`
+	AverageOrderValue float64 `protobuf:"fixed64,8,opt,name=average_order_value,json=averageOrderValue,proto3" json:"average_order_value,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *CategoryTotal) Reset() {
+	*x = CategoryTotal{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CategoryTotal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CategoryTotal) ProtoMessage() {}
+
+func (x *CategoryTotal) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CategoryTotal.ProtoReflect.Descriptor instead.
+func (*CategoryTotal) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CategoryTotal) GetCategoryName() string {
+	if x != nil {
+		return x.CategoryName
+	}
+	return ""
+}
+
+func (x *CategoryTotal) GetTotalAmount() float64 {
+	if x != nil {
+		return x.TotalAmount
+	}
+	return 0
+}
+
+func (x *CategoryTotal) GetGrossSales() float64 {
+	if x != nil {
+		return x.GrossSales
+	}
+	return 0
+}
+
+func (x *CategoryTotal) GetDiscounts() float64 {
+	if x != nil {
+		return x.Discounts
+	}
+	return 0
+}
+
+func (x *CategoryTotal) GetRefunds() float64 {
+	if x != nil {
+		return x.Refunds
+	}
+	return 0
+}
+
+func (x *CategoryTotal) GetUnitsSold() int64 {
+	if x != nil {
+		return x.UnitsSold
+	}
+	return 0
+}
+
+func (x *CategoryTotal) GetTransactions() int64 {
+	if x != nil {
+		return x.Transactions
+	}
+	return 0
+}
+
+func (x *CategoryTotal) GetAverageOrderValue() float64 {
+	if x != nil {
+		return x.AverageOrderValue
+	}
+	return 0
+}
+
+// CategoryReportDay holds every category's revenue for one date
+type CategoryReportDay struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// date is YYYY-MM-DD
+	Date          string           `protobuf:"bytes,1,opt,name=date,proto3" json:"date,omitempty"`
+	Categories    []*CategoryTotal `protobuf:"bytes,2,rep,name=categories,proto3" json:"categories,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CategoryReportDay) Reset() {
+	*x = CategoryReportDay{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CategoryReportDay) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CategoryReportDay) ProtoMessage() {}
+
+func (x *CategoryReportDay) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CategoryReportDay.ProtoReflect.Descriptor instead.
+func (*CategoryReportDay) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CategoryReportDay) GetDate() string {
+	if x != nil {
+		return x.Date
+	}
+	return ""
+}
+
+func (x *CategoryReportDay) GetCategories() []*CategoryTotal {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+// SalesReportByCategory is GET /sales/report/category
+type SalesReportByCategory struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Days          []*CategoryReportDay   `protobuf:"bytes,1,rep,name=days,proto3" json:"days,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SalesReportByCategory) Reset() {
+	*x = SalesReportByCategory{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SalesReportByCategory) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SalesReportByCategory) ProtoMessage() {}
+
+func (x *SalesReportByCategory) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SalesReportByCategory.ProtoReflect.Descriptor instead.
+func (*SalesReportByCategory) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SalesReportByCategory) GetDays() []*CategoryReportDay {
+	if x != nil {
+		return x.Days
+	}
+	return nil
+}
+
+// CustomerTypeTotal is a category's revenue split between new and returning
+// customers for one period
+type CustomerTypeTotal struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	CategoryName       string                 `protobuf:"bytes,1,opt,name=category_name,json=categoryName,proto3" json:"category_name,omitempty"`
+	NewAmount          float64                `protobuf:"fixed64,2,opt,name=new_amount,json=newAmount,proto3" json:"new_amount,omitempty"`
+	ReturningAmount    float64                `protobuf:"fixed64,3,opt,name=returning_amount,json=returningAmount,proto3" json:"returning_amount,omitempty"`
+	NewCustomers       int64                  `protobuf:"varint,4,opt,name=new_customers,json=newCustomers,proto3" json:"new_customers,omitempty"`
+	ReturningCustomers int64                  `protobuf:"varint,5,opt,name=returning_customers,json=returningCustomers,proto3" json:"returning_customers,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *CustomerTypeTotal) Reset() {
+	*x = CustomerTypeTotal{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CustomerTypeTotal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CustomerTypeTotal) ProtoMessage() {}
+
+func (x *CustomerTypeTotal) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CustomerTypeTotal.ProtoReflect.Descriptor instead.
+func (*CustomerTypeTotal) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CustomerTypeTotal) GetCategoryName() string {
+	if x != nil {
+		return x.CategoryName
+	}
+	return ""
+}
+
+func (x *CustomerTypeTotal) GetNewAmount() float64 {
+	if x != nil {
+		return x.NewAmount
+	}
+	return 0
+}
+
+func (x *CustomerTypeTotal) GetReturningAmount() float64 {
+	if x != nil {
+		return x.ReturningAmount
+	}
+	return 0
+}
+
+func (x *CustomerTypeTotal) GetNewCustomers() int64 {
+	if x != nil {
+		return x.NewCustomers
+	}
+	return 0
+}
+
+func (x *CustomerTypeTotal) GetReturningCustomers() int64 {
+	if x != nil {
+		return x.ReturningCustomers
+	}
+	return 0
+}
+
+// CustomerTypeReportPeriod holds every category's split for one period
+type CustomerTypeReportPeriod struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// period is the YYYY-MM-DD start of the period
+	Period        string               `protobuf:"bytes,1,opt,name=period,proto3" json:"period,omitempty"`
+	Categories    []*CustomerTypeTotal `protobuf:"bytes,2,rep,name=categories,proto3" json:"categories,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CustomerTypeReportPeriod) Reset() {
+	*x = CustomerTypeReportPeriod{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CustomerTypeReportPeriod) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CustomerTypeReportPeriod) ProtoMessage() {}
+
+func (x *CustomerTypeReportPeriod) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CustomerTypeReportPeriod.ProtoReflect.Descriptor instead.
+func (*CustomerTypeReportPeriod) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CustomerTypeReportPeriod) GetPeriod() string {
+	if x != nil {
+		return x.Period
+	}
+	return ""
+}
+
+func (x *CustomerTypeReportPeriod) GetCategories() []*CustomerTypeTotal {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+// SalesReportByCustomerType is GET /sales/report/customers
+type SalesReportByCustomerType struct {
+	state         protoimpl.MessageState      `protogen:"open.v1"`
+	Periods       []*CustomerTypeReportPeriod `protobuf:"bytes,1,rep,name=periods,proto3" json:"periods,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SalesReportByCustomerType) Reset() {
+	*x = SalesReportByCustomerType{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SalesReportByCustomerType) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SalesReportByCustomerType) ProtoMessage() {}
+
+func (x *SalesReportByCustomerType) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SalesReportByCustomerType.ProtoReflect.Descriptor instead.
+func (*SalesReportByCustomerType) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SalesReportByCustomerType) GetPeriods() []*CustomerTypeReportPeriod {
+	if x != nil {
+		return x.Periods
+	}
+	return nil
+}
+
+// GroupedTotal is the revenue of one combination of group_by dimensions for
+// one period. Dimensions that aren't grouped by are empty.
+type GroupedTotal struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Category      string                 `protobuf:"bytes,1,opt,name=category,proto3" json:"category,omitempty"`
+	Store         string                 `protobuf:"bytes,2,opt,name=store,proto3" json:"store,omitempty"`
+	Customer      string                 `protobuf:"bytes,3,opt,name=customer,proto3" json:"customer,omitempty"`
+	TotalAmount   float64                `protobuf:"fixed64,4,opt,name=total_amount,json=totalAmount,proto3" json:"total_amount,omitempty"`
+	Currency      string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	Status        string                 `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GroupedTotal) Reset() {
+	*x = GroupedTotal{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupedTotal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupedTotal) ProtoMessage() {}
+
+func (x *GroupedTotal) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GroupedTotal.ProtoReflect.Descriptor instead.
+func (*GroupedTotal) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GroupedTotal) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *GroupedTotal) GetStore() string {
+	if x != nil {
+		return x.Store
+	}
+	return ""
+}
+
+func (x *GroupedTotal) GetCustomer() string {
+	if x != nil {
+		return x.Customer
+	}
+	return ""
+}
+
+func (x *GroupedTotal) GetTotalAmount() float64 {
+	if x != nil {
+		return x.TotalAmount
+	}
+	return 0
+}
+
+func (x *GroupedTotal) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *GroupedTotal) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+// GroupedReportPeriod holds every combination's revenue for one period
+type GroupedReportPeriod struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Period        string                 `protobuf:"bytes,1,opt,name=period,proto3" json:"period,omitempty"`
+	Totals        []*GroupedTotal        `protobuf:"bytes,2,rep,name=totals,proto3" json:"totals,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GroupedReportPeriod) Reset() {
+	*x = GroupedReportPeriod{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupedReportPeriod) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupedReportPeriod) ProtoMessage() {}
+
+func (x *GroupedReportPeriod) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GroupedReportPeriod.ProtoReflect.Descriptor instead.
+func (*GroupedReportPeriod) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *GroupedReportPeriod) GetPeriod() string {
+	if x != nil {
+		return x.Period
+	}
+	return ""
+}
+
+func (x *GroupedReportPeriod) GetTotals() []*GroupedTotal {
+	if x != nil {
+		return x.Totals
+	}
+	return nil
+}
+
+// SalesReportGrouped is GET /sales/report/category with group_by, always in
+// the flat shape
+type SalesReportGrouped struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Periods       []*GroupedReportPeriod `protobuf:"bytes,1,rep,name=periods,proto3" json:"periods,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SalesReportGrouped) Reset() {
+	*x = SalesReportGrouped{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SalesReportGrouped) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SalesReportGrouped) ProtoMessage() {}
+
+func (x *SalesReportGrouped) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SalesReportGrouped.ProtoReflect.Descriptor instead.
+func (*SalesReportGrouped) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SalesReportGrouped) GetPeriods() []*GroupedReportPeriod {
+	if x != nil {
+		return x.Periods
+	}
+	return nil
+}
+
+// TimeSeriesPoint is a single period/total pair
+type TimeSeriesPoint struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Period string                 `protobuf:"bytes,1,opt,name=period,proto3" json:"period,omitempty"`
+	Total  float64                `protobuf:"fixed64,2,opt,name=total,proto3" json:"total,omitempty"`
+	// intervals are the 80% and 95% confidence bands of forecast points
+	Intervals     []*ConfidenceInterval `protobuf:"bytes,3,rep,name=intervals,proto3" json:"intervals,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimeSeriesPoint) Reset() {
+	*x = TimeSeriesPoint{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimeSeriesPoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimeSeriesPoint) ProtoMessage() {}
+
+func (x *TimeSeriesPoint) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimeSeriesPoint.ProtoReflect.Descriptor instead.
+func (*TimeSeriesPoint) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TimeSeriesPoint) GetPeriod() string {
+	if x != nil {
+		return x.Period
+	}
+	return ""
+}
+
+func (x *TimeSeriesPoint) GetTotal() float64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *TimeSeriesPoint) GetIntervals() []*ConfidenceInterval {
+	if x != nil {
+		return x.Intervals
+	}
+	return nil
+}
+
+// ConfidenceInterval is the range a forecast total falls in with the given
+// confidence
+type ConfidenceInterval struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// level is the confidence level in percent: 80 or 95
+	Level         int32   `protobuf:"varint,1,opt,name=level,proto3" json:"level,omitempty"`
+	Lower         float64 `protobuf:"fixed64,2,opt,name=lower,proto3" json:"lower,omitempty"`
+	Upper         float64 `protobuf:"fixed64,3,opt,name=upper,proto3" json:"upper,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfidenceInterval) Reset() {
+	*x = ConfidenceInterval{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfidenceInterval) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfidenceInterval) ProtoMessage() {}
+
+func (x *ConfidenceInterval) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfidenceInterval.ProtoReflect.Descriptor instead.
+func (*ConfidenceInterval) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ConfidenceInterval) GetLevel() int32 {
+	if x != nil {
+		return x.Level
+	}
+	return 0
+}
+
+func (x *ConfidenceInterval) GetLower() float64 {
+	if x != nil {
+		return x.Lower
+	}
+	return 0
+}
+
+func (x *ConfidenceInterval) GetUpper() float64 {
+	if x != nil {
+		return x.Upper
+	}
+	return 0
+}
+
+// ForecastMeta describes how a forecast was produced
+type ForecastMeta struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// source is llm, fallback, or deterministic
+	Source         string `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Provider       string `protobuf:"bytes,2,opt,name=provider,proto3" json:"provider,omitempty"`
+	Model          string `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	PromptVersion  string `protobuf:"bytes,4,opt,name=prompt_version,json=promptVersion,proto3" json:"prompt_version,omitempty"`
+	FallbackReason string `protobuf:"bytes,5,opt,name=fallback_reason,json=fallbackReason,proto3" json:"fallback_reason,omitempty"`
+	DurationMs     int64  `protobuf:"varint,6,opt,name=duration_ms,json=durationMs,proto3" json:"duration_ms,omitempty"`
+	CacheHit       bool   `protobuf:"varint,7,opt,name=cache_hit,json=cacheHit,proto3" json:"cache_hit,omitempty"`
+	// method is the statistical method of fallback and deterministic forecasts
+	Method string         `protobuf:"bytes,8,opt,name=method,proto3" json:"method,omitempty"`
+	Demand *DemandPattern `protobuf:"bytes,9,opt,name=demand,proto3" json:"demand,omitempty"`
+	// trading_days is set when the forecast was adjusted for trading days
+	TradingDays bool `protobuf:"varint,10,opt,name=trading_days,json=tradingDays,proto3" json:"trading_days,omitempty"`
+	// llm_status is the cached LLM health when the forecast was requested
+	LlmStatus string `protobuf:"bytes,11,opt,name=llm_status,json=llmStatus,proto3" json:"llm_status,omitempty"`
+	// usage is the token usage and estimated cost of the LLM request
+	Usage *LLMUsage `protobuf:"bytes,12,opt,name=usage,proto3" json:"usage,omitempty"`
+	// repairs lists the LLM forecast points that failed validation
+	Repairs       []*ForecastRepair `protobuf:"bytes,13,rep,name=repairs,proto3" json:"repairs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForecastMeta) Reset() {
+	*x = ForecastMeta{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForecastMeta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForecastMeta) ProtoMessage() {}
+
+func (x *ForecastMeta) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForecastMeta.ProtoReflect.Descriptor instead.
+func (*ForecastMeta) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ForecastMeta) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *ForecastMeta) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *ForecastMeta) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *ForecastMeta) GetPromptVersion() string {
+	if x != nil {
+		return x.PromptVersion
+	}
+	return ""
+}
+
+func (x *ForecastMeta) GetFallbackReason() string {
+	if x != nil {
+		return x.FallbackReason
+	}
+	return ""
+}
+
+func (x *ForecastMeta) GetDurationMs() int64 {
+	if x != nil {
+		return x.DurationMs
+	}
+	return 0
+}
+
+func (x *ForecastMeta) GetCacheHit() bool {
+	if x != nil {
+		return x.CacheHit
+	}
+	return false
+}
+
+func (x *ForecastMeta) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *ForecastMeta) GetDemand() *DemandPattern {
+	if x != nil {
+		return x.Demand
+	}
+	return nil
+}
+
+func (x *ForecastMeta) GetTradingDays() bool {
+	if x != nil {
+		return x.TradingDays
+	}
+	return false
+}
+
+func (x *ForecastMeta) GetLlmStatus() string {
+	if x != nil {
+		return x.LlmStatus
+	}
+	return ""
+}
+
+func (x *ForecastMeta) GetUsage() *LLMUsage {
+	if x != nil {
+		return x.Usage
+	}
+	return nil
+}
+
+func (x *ForecastMeta) GetRepairs() []*ForecastRepair {
+	if x != nil {
+		return x.Repairs
+	}
+	return nil
+}
+
+// ForecastRepair is an LLM forecast point replaced by the statistical
+// forecast (missing, invalid, negative) or dropped (duplicate, unexpected)
+type ForecastRepair struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Period        string                 `protobuf:"bytes,1,opt,name=period,proto3" json:"period,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForecastRepair) Reset() {
+	*x = ForecastRepair{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForecastRepair) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForecastRepair) ProtoMessage() {}
+
+func (x *ForecastRepair) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForecastRepair.ProtoReflect.Descriptor instead.
+func (*ForecastRepair) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ForecastRepair) GetPeriod() string {
+	if x != nil {
+		return x.Period
+	}
+	return ""
+}
+
+func (x *ForecastRepair) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// LLMUsage is the token usage and estimated cost of an LLM request
+type LLMUsage struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	PromptTokens     int32                  `protobuf:"varint,1,opt,name=prompt_tokens,json=promptTokens,proto3" json:"prompt_tokens,omitempty"`
+	CompletionTokens int32                  `protobuf:"varint,2,opt,name=completion_tokens,json=completionTokens,proto3" json:"completion_tokens,omitempty"`
+	TotalTokens      int32                  `protobuf:"varint,3,opt,name=total_tokens,json=totalTokens,proto3" json:"total_tokens,omitempty"`
+	// cost_usd is unset when the model's price isn't known
+	CostUsd       *float64 `protobuf:"fixed64,4,opt,name=cost_usd,json=costUsd,proto3,oneof" json:"cost_usd,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LLMUsage) Reset() {
+	*x = LLMUsage{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LLMUsage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LLMUsage) ProtoMessage() {}
+
+func (x *LLMUsage) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LLMUsage.ProtoReflect.Descriptor instead.
+func (*LLMUsage) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *LLMUsage) GetPromptTokens() int32 {
+	if x != nil {
+		return x.PromptTokens
+	}
+	return 0
+}
+
+func (x *LLMUsage) GetCompletionTokens() int32 {
+	if x != nil {
+		return x.CompletionTokens
+	}
+	return 0
+}
+
+func (x *LLMUsage) GetTotalTokens() int32 {
+	if x != nil {
+		return x.TotalTokens
+	}
+	return 0
+}
+
+func (x *LLMUsage) GetCostUsd() float64 {
+	if x != nil && x.CostUsd != nil {
+		return *x.CostUsd
+	}
+	return 0
+}
+
+// DemandPattern classifies the forecast history by demand frequency and
+// variability
+type DemandPattern struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Adi       float64                `protobuf:"fixed64,1,opt,name=adi,proto3" json:"adi,omitempty"`
+	Cv2       float64                `protobuf:"fixed64,2,opt,name=cv2,proto3" json:"cv2,omitempty"`
+	ZeroShare float64                `protobuf:"fixed64,3,opt,name=zero_share,json=zeroShare,proto3" json:"zero_share,omitempty"`
+	// class is smooth, erratic, intermittent, or lumpy
+	Class         string `protobuf:"bytes,4,opt,name=class,proto3" json:"class,omitempty"`
+	Intermittent  bool   `protobuf:"varint,5,opt,name=intermittent,proto3" json:"intermittent,omitempty"`
+	Method        string `protobuf:"bytes,6,opt,name=method,proto3" json:"method,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DemandPattern) Reset() {
+	*x = DemandPattern{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DemandPattern) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DemandPattern) ProtoMessage() {}
+
+func (x *DemandPattern) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DemandPattern.ProtoReflect.Descriptor instead.
+func (*DemandPattern) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DemandPattern) GetAdi() float64 {
+	if x != nil {
+		return x.Adi
+	}
+	return 0
+}
+
+func (x *DemandPattern) GetCv2() float64 {
+	if x != nil {
+		return x.Cv2
+	}
+	return 0
+}
+
+func (x *DemandPattern) GetZeroShare() float64 {
+	if x != nil {
+		return x.ZeroShare
+	}
+	return 0
+}
+
+func (x *DemandPattern) GetClass() string {
+	if x != nil {
+		return x.Class
+	}
+	return ""
+}
+
+func (x *DemandPattern) GetIntermittent() bool {
+	if x != nil {
+		return x.Intermittent
+	}
+	return false
+}
+
+func (x *DemandPattern) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+// ForecastResponse is POST /sales/forecast
+type ForecastResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Forecast    []*TimeSeriesPoint     `protobuf:"bytes,1,rep,name=forecast,proto3" json:"forecast,omitempty"`
+	TimePeriod  string                 `protobuf:"bytes,2,opt,name=time_period,json=timePeriod,proto3" json:"time_period,omitempty"`
+	Message     string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	RawResponse string                 `protobuf:"bytes,4,opt,name=raw_response,json=rawResponse,proto3" json:"raw_response,omitempty"`
+	Meta        *ForecastMeta          `protobuf:"bytes,5,opt,name=meta,proto3" json:"meta,omitempty"`
+	Outliers    []*OutlierPoint        `protobuf:"bytes,6,rep,name=outliers,proto3" json:"outliers,omitempty"`
+	// imputed lists the missing history periods filled in before forecasting
+	Imputed []*TimeSeriesPoint `protobuf:"bytes,7,rep,name=imputed,proto3" json:"imputed,omitempty"`
+	// forecast_id is set for tracked forecasts
+	ForecastId int64 `protobuf:"varint,8,opt,name=forecast_id,json=forecastId,proto3" json:"forecast_id,omitempty"`
+	// baseline is the statistical forecast without the request's scenario
+	Baseline      []*TimeSeriesPoint `protobuf:"bytes,9,rep,name=baseline,proto3" json:"baseline,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForecastResponse) Reset() {
+	*x = ForecastResponse{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForecastResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForecastResponse) ProtoMessage() {}
+
+func (x *ForecastResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForecastResponse.ProtoReflect.Descriptor instead.
+func (*ForecastResponse) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ForecastResponse) GetForecast() []*TimeSeriesPoint {
+	if x != nil {
+		return x.Forecast
+	}
+	return nil
+}
+
+func (x *ForecastResponse) GetTimePeriod() string {
+	if x != nil {
+		return x.TimePeriod
+	}
+	return ""
+}
+
+func (x *ForecastResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ForecastResponse) GetRawResponse() string {
+	if x != nil {
+		return x.RawResponse
+	}
+	return ""
+}
+
+func (x *ForecastResponse) GetMeta() *ForecastMeta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *ForecastResponse) GetOutliers() []*OutlierPoint {
+	if x != nil {
+		return x.Outliers
+	}
+	return nil
+}
+
+func (x *ForecastResponse) GetImputed() []*TimeSeriesPoint {
+	if x != nil {
+		return x.Imputed
+	}
+	return nil
+}
+
+func (x *ForecastResponse) GetForecastId() int64 {
+	if x != nil {
+		return x.ForecastId
+	}
+	return 0
+}
+
+func (x *ForecastResponse) GetBaseline() []*TimeSeriesPoint {
+	if x != nil {
+		return x.Baseline
+	}
+	return nil
+}
+
+// CategoryForecasts is the response to a forecast request with categories
+type CategoryForecasts struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// forecasts is keyed by category name
+	Forecasts     map[string]*ForecastResponse `protobuf:"bytes,1,rep,name=forecasts,proto3" json:"forecasts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Message       string                       `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CategoryForecasts) Reset() {
+	*x = CategoryForecasts{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CategoryForecasts) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CategoryForecasts) ProtoMessage() {}
+
+func (x *CategoryForecasts) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CategoryForecasts.ProtoReflect.Descriptor instead.
+func (*CategoryForecasts) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *CategoryForecasts) GetForecasts() map[string]*ForecastResponse {
+	if x != nil {
+		return x.Forecasts
+	}
+	return nil
+}
+
+func (x *CategoryForecasts) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// OutlierPoint is a history point flagged by outlier detection
+type OutlierPoint struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Period string                 `protobuf:"bytes,1,opt,name=period,proto3" json:"period,omitempty"`
+	Total  float64                `protobuf:"fixed64,2,opt,name=total,proto3" json:"total,omitempty"`
+	Lower  float64                `protobuf:"fixed64,3,opt,name=lower,proto3" json:"lower,omitempty"`
+	Upper  float64                `protobuf:"fixed64,4,opt,name=upper,proto3" json:"upper,omitempty"`
+	// treated is the total used for forecasting
+	Treated       float64 `protobuf:"fixed64,5,opt,name=treated,proto3" json:"treated,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OutlierPoint) Reset() {
+	*x = OutlierPoint{}
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OutlierPoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutlierPoint) ProtoMessage() {}
+
+func (x *OutlierPoint) ProtoReflect() protoreflect.Message {
+	mi := &file_craftdemo_v1_reports_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutlierPoint.ProtoReflect.Descriptor instead.
+func (*OutlierPoint) Descriptor() ([]byte, []int) {
+	return file_craftdemo_v1_reports_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *OutlierPoint) GetPeriod() string {
+	if x != nil {
+		return x.Period
+	}
+	return ""
+}
+
+func (x *OutlierPoint) GetTotal() float64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *OutlierPoint) GetLower() float64 {
+	if x != nil {
+		return x.Lower
+	}
+	return 0
+}
+
+func (x *OutlierPoint) GetUpper() float64 {
+	if x != nil {
+		return x.Upper
+	}
+	return 0
+}
+
+func (x *OutlierPoint) GetTreated() float64 {
+	if x != nil {
+		return x.Treated
+	}
+	return 0
+}
+
+var File_craftdemo_v1_reports_proto protoreflect.FileDescriptor
+
+const file_craftdemo_v1_reports_proto_rawDesc = "" +
+	"\n" +
+	"\x1acraftdemo/v1/reports.proto\x12\fcraftdemo.v1\"\xa3\x02\n" +
+	"\rCategoryTotal\x12#\n" +
+	"\rcategory_name\x18\x01 \x01(\tR\fcategoryName\x12!\n" +
+	"\ftotal_amount\x18\x02 \x01(\x01R\vtotalAmount\x12\x1f\n" +
+	"\vgross_sales\x18\x03 \x01(\x01R\n" +
+	"grossSales\x12\x1c\n" +
+	"\tdiscounts\x18\x04 \x01(\x01R\tdiscounts\x12\x18\n" +
+	"\arefunds\x18\x05 \x01(\x01R\arefunds\x12\x1d\n" +
+	"\n" +
+	"units_sold\x18\x06 \x01(\x03R\tunitsSold\x12\"\n" +
+	"\ftransactions\x18\a \x01(\x03R\ftransactions\x12.\n" +
+	"\x13average_order_value\x18\b \x01(\x01R\x11averageOrderValue\"d\n" +
+	"\x11CategoryReportDay\x12\x12\n" +
+	"\x04date\x18\x01 \x01(\tR\x04date\x12;\n" +
+	"\n" +
+	"categories\x18\x02 \x03(\v2\x1b.craftdemo.v1.CategoryTotalR\n" +
+	"categories\"L\n" +
+	"\x15SalesReportByCategory\x123\n" +
+	"\x04days\x18\x01 \x03(\v2\x1f.craftdemo.v1.CategoryReportDayR\x04days\"\xd8\x01\n" +
+	"\x11CustomerTypeTotal\x12#\n" +
+	"\rcategory_name\x18\x01 \x01(\tR\fcategoryName\x12\x1d\n" +
+	"\n" +
+	"new_amount\x18\x02 \x01(\x01R\tnewAmount\x12)\n" +
+	"\x10returning_amount\x18\x03 \x01(\x01R\x0freturningAmount\x12#\n" +
+	"\rnew_customers\x18\x04 \x01(\x03R\fnewCustomers\x12/\n" +
+	"\x13returning_customers\x18\x05 \x01(\x03R\x12returningCustomers\"s\n" +
+	"\x18CustomerTypeReportPeriod\x12\x16\n" +
+	"\x06period\x18\x01 \x01(\tR\x06period\x12?\n" +
+	"\n" +
+	"categories\x18\x02 \x03(\v2\x1f.craftdemo.v1.CustomerTypeTotalR\n" +
+	"categories\"]\n" +
+	"\x19SalesReportByCustomerType\x12@\n" +
+	"\aperiods\x18\x01 \x03(\v2&.craftdemo.v1.CustomerTypeReportPeriodR\aperiods\"\xb3\x01\n" +
+	"\fGroupedTotal\x12\x1a\n" +
+	"\bcategory\x18\x01 \x01(\tR\bcategory\x12\x14\n" +
+	"\x05store\x18\x02 \x01(\tR\x05store\x12\x1a\n" +
+	"\bcustomer\x18\x03 \x01(\tR\bcustomer\x12!\n" +
+	"\ftotal_amount\x18\x04 \x01(\x01R\vtotalAmount\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\x12\x16\n" +
+	"\x06status\x18\x06 \x01(\tR\x06status\"a\n" +
+	"\x13GroupedReportPeriod\x12\x16\n" +
+	"\x06period\x18\x01 \x01(\tR\x06period\x122\n" +
+	"\x06totals\x18\x02 \x03(\v2\x1a.craftdemo.v1.GroupedTotalR\x06totals\"Q\n" +
+	"\x12SalesReportGrouped\x12;\n" +
+	"\aperiods\x18\x01 \x03(\v2!.craftdemo.v1.GroupedReportPeriodR\aperiods\"\x7f\n" +
+	"\x0fTimeSeriesPoint\x12\x16\n" +
+	"\x06period\x18\x01 \x01(\tR\x06period\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x01R\x05total\x12>\n" +
+	"\tintervals\x18\x03 \x03(\v2 .craftdemo.v1.ConfidenceIntervalR\tintervals\"V\n" +
+	"\x12ConfidenceInterval\x12\x14\n" +
+	"\x05level\x18\x01 \x01(\x05R\x05level\x12\x14\n" +
+	"\x05lower\x18\x02 \x01(\x01R\x05lower\x12\x14\n" +
+	"\x05upper\x18\x03 \x01(\x01R\x05upper\"\xdb\x03\n" +
+	"\fForecastMeta\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x1a\n" +
+	"\bprovider\x18\x02 \x01(\tR\bprovider\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\x12%\n" +
+	"\x0eprompt_version\x18\x04 \x01(\tR\rpromptVersion\x12'\n" +
+	"\x0ffallback_reason\x18\x05 \x01(\tR\x0efallbackReason\x12\x1f\n" +
+	"\vduration_ms\x18\x06 \x01(\x03R\n" +
+	"durationMs\x12\x1b\n" +
+	"\tcache_hit\x18\a \x01(\bR\bcacheHit\x12\x16\n" +
+	"\x06method\x18\b \x01(\tR\x06method\x123\n" +
+	"\x06demand\x18\t \x01(\v2\x1b.craftdemo.v1.DemandPatternR\x06demand\x12!\n" +
+	"\ftrading_days\x18\n" +
+	" \x01(\bR\vtradingDays\x12\x1d\n" +
+	"\n" +
+	"llm_status\x18\v \x01(\tR\tllmStatus\x12,\n" +
+	"\x05usage\x18\f \x01(\v2\x16.craftdemo.v1.LLMUsageR\x05usage\x126\n" +
+	"\arepairs\x18\r \x03(\v2\x1c.craftdemo.v1.ForecastRepairR\arepairs\"@\n" +
+	"\x0eForecastRepair\x12\x16\n" +
+	"\x06period\x18\x01 \x01(\tR\x06period\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"\xac\x01\n" +
+	"\bLLMUsage\x12#\n" +
+	"\rprompt_tokens\x18\x01 \x01(\x05R\fpromptTokens\x12+\n" +
+	"\x11completion_tokens\x18\x02 \x01(\x05R\x10completionTokens\x12!\n" +
+	"\ftotal_tokens\x18\x03 \x01(\x05R\vtotalTokens\x12\x1e\n" +
+	"\bcost_usd\x18\x04 \x01(\x01H\x00R\acostUsd\x88\x01\x01B\v\n" +
+	"\t_cost_usd\"\xa4\x01\n" +
+	"\rDemandPattern\x12\x10\n" +
+	"\x03adi\x18\x01 \x01(\x01R\x03adi\x12\x10\n" +
+	"\x03cv2\x18\x02 \x01(\x01R\x03cv2\x12\x1d\n" +
+	"\n" +
+	"zero_share\x18\x03 \x01(\x01R\tzeroShare\x12\x14\n" +
+	"\x05class\x18\x04 \x01(\tR\x05class\x12\"\n" +
+	"\fintermittent\x18\x05 \x01(\bR\fintermittent\x12\x16\n" +
+	"\x06method\x18\x06 \x01(\tR\x06method\"\xa8\x03\n" +
+	"\x10ForecastResponse\x129\n" +
+	"\bforecast\x18\x01 \x03(\v2\x1d.craftdemo.v1.TimeSeriesPointR\bforecast\x12\x1f\n" +
+	"\vtime_period\x18\x02 \x01(\tR\n" +
+	"timePeriod\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12!\n" +
+	"\fraw_response\x18\x04 \x01(\tR\vrawResponse\x12.\n" +
+	"\x04meta\x18\x05 \x01(\v2\x1a.craftdemo.v1.ForecastMetaR\x04meta\x126\n" +
+	"\boutliers\x18\x06 \x03(\v2\x1a.craftdemo.v1.OutlierPointR\boutliers\x127\n" +
+	"\aimputed\x18\a \x03(\v2\x1d.craftdemo.v1.TimeSeriesPointR\aimputed\x12\x1f\n" +
+	"\vforecast_id\x18\b \x01(\x03R\n" +
+	"forecastId\x129\n" +
+	"\bbaseline\x18\t \x03(\v2\x1d.craftdemo.v1.TimeSeriesPointR\bbaseline\"\xd9\x01\n" +
+	"\x11CategoryForecasts\x12L\n" +
+	"\tforecasts\x18\x01 \x03(\v2..craftdemo.v1.CategoryForecasts.ForecastsEntryR\tforecasts\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x1a\\\n" +
+	"\x0eForecastsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x124\n" +
+	"\x05value\x18\x02 \x01(\v2\x1e.craftdemo.v1.ForecastResponseR\x05value:\x028\x01\"\x82\x01\n" +
+	"\fOutlierPoint\x12\x16\n" +
+	"\x06period\x18\x01 \x01(\tR\x06period\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x01R\x05total\x12\x14\n" +
+	"\x05lower\x18\x03 \x01(\x01R\x05lower\x12\x14\n" +
+	"\x05upper\x18\x04 \x01(\x01R\x05upper\x12\x18\n" +
+	"\atreated\x18\x05 \x01(\x01R\atreatedB)Z'github.com/bokor/craft-demo/internal/pbb\x06proto3"
+
+var (
+	file_craftdemo_v1_reports_proto_rawDescOnce sync.Once
+	file_craftdemo_v1_reports_proto_rawDescData []byte
+)
+
+func file_craftdemo_v1_reports_proto_rawDescGZIP() []byte {
+	file_craftdemo_v1_reports_proto_rawDescOnce.Do(func() {
+		file_craftdemo_v1_reports_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_craftdemo_v1_reports_proto_rawDesc), len(file_craftdemo_v1_reports_proto_rawDesc)))
+	})
+	return file_craftdemo_v1_reports_proto_rawDescData
+}
+
+var file_craftdemo_v1_reports_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_craftdemo_v1_reports_proto_goTypes = []any{
+	(*CategoryTotal)(nil),             // 0: craftdemo.v1.CategoryTotal
+	(*CategoryReportDay)(nil),         // 1: craftdemo.v1.CategoryReportDay
+	(*SalesReportByCategory)(nil),     // 2: craftdemo.v1.SalesReportByCategory
+	(*CustomerTypeTotal)(nil),         // 3: craftdemo.v1.CustomerTypeTotal
+	(*CustomerTypeReportPeriod)(nil),  // 4: craftdemo.v1.CustomerTypeReportPeriod
+	(*SalesReportByCustomerType)(nil), // 5: craftdemo.v1.SalesReportByCustomerType
+	(*GroupedTotal)(nil),              // 6: craftdemo.v1.GroupedTotal
+	(*GroupedReportPeriod)(nil),       // 7: craftdemo.v1.GroupedReportPeriod
+	(*SalesReportGrouped)(nil),        // 8: craftdemo.v1.SalesReportGrouped
+	(*TimeSeriesPoint)(nil),           // 9: craftdemo.v1.TimeSeriesPoint
+	(*ConfidenceInterval)(nil),        // 10: craftdemo.v1.ConfidenceInterval
+	(*ForecastMeta)(nil),              // 11: craftdemo.v1.ForecastMeta
+	(*ForecastRepair)(nil),            // 12: craftdemo.v1.ForecastRepair
+	(*LLMUsage)(nil),                  // 13: craftdemo.v1.LLMUsage
+	(*DemandPattern)(nil),             // 14: craftdemo.v1.DemandPattern
+	(*ForecastResponse)(nil),          // 15: craftdemo.v1.ForecastResponse
+	(*CategoryForecasts)(nil),         // 16: craftdemo.v1.CategoryForecasts
+	(*OutlierPoint)(nil),              // 17: craftdemo.v1.OutlierPoint
+	nil,                               // 18: craftdemo.v1.CategoryForecasts.ForecastsEntry
+}
+var file_craftdemo_v1_reports_proto_depIdxs = []int32{
+	0,  // 0: craftdemo.v1.CategoryReportDay.categories:type_name -> craftdemo.v1.CategoryTotal
+	1,  // 1: craftdemo.v1.SalesReportByCategory.days:type_name -> craftdemo.v1.CategoryReportDay
+	3,  // 2: craftdemo.v1.CustomerTypeReportPeriod.categories:type_name -> craftdemo.v1.CustomerTypeTotal
+	4,  // 3: craftdemo.v1.SalesReportByCustomerType.periods:type_name -> craftdemo.v1.CustomerTypeReportPeriod
+	6,  // 4: craftdemo.v1.GroupedReportPeriod.totals:type_name -> craftdemo.v1.GroupedTotal
+	7,  // 5: craftdemo.v1.SalesReportGrouped.periods:type_name -> craftdemo.v1.GroupedReportPeriod
+	10, // 6: craftdemo.v1.TimeSeriesPoint.intervals:type_name -> craftdemo.v1.ConfidenceInterval
+	14, // 7: craftdemo.v1.ForecastMeta.demand:type_name -> craftdemo.v1.DemandPattern
+	13, // 8: craftdemo.v1.ForecastMeta.usage:type_name -> craftdemo.v1.LLMUsage
+	12, // 9: craftdemo.v1.ForecastMeta.repairs:type_name -> craftdemo.v1.ForecastRepair
+	9,  // 10: craftdemo.v1.ForecastResponse.forecast:type_name -> craftdemo.v1.TimeSeriesPoint
+	11, // 11: craftdemo.v1.ForecastResponse.meta:type_name -> craftdemo.v1.ForecastMeta
+	17, // 12: craftdemo.v1.ForecastResponse.outliers:type_name -> craftdemo.v1.OutlierPoint
+	9,  // 13: craftdemo.v1.ForecastResponse.imputed:type_name -> craftdemo.v1.TimeSeriesPoint
+	9,  // 14: craftdemo.v1.ForecastResponse.baseline:type_name -> craftdemo.v1.TimeSeriesPoint
+	18, // 15: craftdemo.v1.CategoryForecasts.forecasts:type_name -> craftdemo.v1.CategoryForecasts.ForecastsEntry
+	15, // 16: craftdemo.v1.CategoryForecasts.ForecastsEntry.value:type_name -> craftdemo.v1.ForecastResponse
+	17, // [17:17] is the sub-list for method output_type
+	17, // [17:17] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_craftdemo_v1_reports_proto_init() }
+func file_craftdemo_v1_reports_proto_init() {
+	if File_craftdemo_v1_reports_proto != nil {
+		return
+	}
+	file_craftdemo_v1_reports_proto_msgTypes[13].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_craftdemo_v1_reports_proto_rawDesc), len(file_craftdemo_v1_reports_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_craftdemo_v1_reports_proto_goTypes,
+		DependencyIndexes: file_craftdemo_v1_reports_proto_depIdxs,
+		MessageInfos:      file_craftdemo_v1_reports_proto_msgTypes,
+	}.Build()
+	File_craftdemo_v1_reports_proto = out.File
+	file_craftdemo_v1_reports_proto_goTypes = nil
+	file_craftdemo_v1_reports_proto_depIdxs = nil
+}
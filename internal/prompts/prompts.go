@@ -0,0 +1,271 @@
+package prompts
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/bokor/craft-demo/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Holiday is a single entry in the holiday calendar
+type Holiday struct {
+	Date string `yaml:"date" json:"date"`
+	Name string `yaml:"name" json:"name"`
+}
+
+// Point is a historical data point rendered into the forecast prompt
+type Point struct {
+	Period string
+	Total  float64
+}
+
+// ForecastData is the data the forecast prompt template is rendered with
+type ForecastData struct {
+	// PeriodLabel is daily, weekly, ISO-week, monthly, or fiscal-period
+	PeriodLabel string
+	Periods     int
+	History     []Point
+	Holidays    []Holiday
+	// Adjustments are the planned changes of a what-if scenario
+	Adjustments []Adjustment
+}
+
+// Adjustment is a planned change to sales the forecast prompt asks the LLM
+// to include
+type Adjustment struct {
+	Name  string
+	Start string
+	End   string
+	// Percent is the change in sales, e.g. 15 for +15%
+	Percent float64
+}
+
+// Status describes the loaded prompt template and holiday calendar
+type Status struct {
+	PromptVersion  string    `json:"prompt_version"`
+	PromptSource   string    `json:"prompt_source"`
+	HolidaysSource string    `json:"holidays_source"`
+	Holidays       int       `json:"holidays"`
+	LoadedAt       time.Time `json:"loaded_at"`
+}
+
+// Store holds the forecast prompt template and holiday calendar and reloads
+// them when their files change. Files that don't exist fall back to the
+// defaults embedded from config/.
+type Store struct {
+	promptPath   string
+	holidaysPath string
+
+	mu       sync.RWMutex
+	template *template.Template
+	holidays []Holiday
+	status   Status
+	modTimes map[string]time.Time
+}
+
+// NewStore loads the prompt template and holiday calendar from the given paths
+func NewStore(promptPath, holidaysPath string) (*Store, error) {
+	store := &Store{promptPath: promptPath, holidaysPath: holidaysPath}
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+var (
+	defaultStore     *Store
+	defaultStoreErr  error
+	defaultStoreOnce sync.Once
+)
+
+// Default returns the store for PROMPT_TEMPLATE_PATH and HOLIDAY_CALENDAR_PATH,
+// which default to the files in config/
+func Default() (*Store, error) {
+	defaultStoreOnce.Do(func() {
+		promptPath := os.Getenv("PROMPT_TEMPLATE_PATH")
+		if promptPath == "" {
+			promptPath = "config/forecast_prompt.tmpl"
+		}
+		holidaysPath := os.Getenv("HOLIDAY_CALENDAR_PATH")
+		if holidaysPath == "" {
+			holidaysPath = "config/holidays.yaml"
+		}
+		defaultStore, defaultStoreErr = NewStore(promptPath, holidaysPath)
+	})
+	return defaultStore, defaultStoreErr
+}
+
+// Reload reads both files again. If either is invalid the previously loaded
+// configuration is kept and the error is returned.
+func (s *Store) Reload() error {
+	promptBytes, promptSource, promptModTime, err := readFile(s.promptPath)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(path.Base(s.promptPath)).Option("missingkey=error").Parse(string(promptBytes))
+	if err != nil {
+		return fmt.Errorf("invalid prompt template %s: %v", promptSource, err)
+	}
+
+	holidayBytes, holidaysSource, holidaysModTime, err := readFile(s.holidaysPath)
+	if err != nil {
+		return err
+	}
+	var holidays []Holiday
+	if err := yaml.Unmarshal(holidayBytes, &holidays); err != nil {
+		return fmt.Errorf("invalid holiday calendar %s: %v", holidaysSource, err)
+	}
+	for _, holiday := range holidays {
+		if _, err := time.Parse("2006-01-02", holiday.Date); err != nil || holiday.Name == "" {
+			return fmt.Errorf("invalid holiday calendar %s: entries need a YYYY-MM-DD date and a name, got %+v", holidaysSource, holiday)
+		}
+	}
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date < holidays[j].Date })
+
+	hash := sha256.Sum256(promptBytes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.template = tmpl
+	s.holidays = holidays
+	s.modTimes = map[string]time.Time{s.promptPath: promptModTime, s.holidaysPath: holidaysModTime}
+	s.status = Status{
+		PromptVersion:  "forecast-" + hex.EncodeToString(hash[:])[:12],
+		PromptSource:   promptSource,
+		HolidaysSource: holidaysSource,
+		Holidays:       len(holidays),
+		LoadedAt:       time.Now().UTC(),
+	}
+	return nil
+}
+
+// readFile reads a file from disk, or its embedded default if it doesn't
+// exist. It returns the contents, where they came from, and the modification
+// time (zero for embedded defaults).
+func readFile(name string) ([]byte, string, time.Time, error) {
+	info, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		contents, err := config.Defaults.ReadFile(path.Base(name))
+		if err != nil {
+			return nil, "", time.Time{}, fmt.Errorf("%s does not exist and has no embedded default", name)
+		}
+		return contents, "embedded:" + path.Base(name), time.Time{}, nil
+	}
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	contents, err := os.ReadFile(name)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	return contents, name, info.ModTime(), nil
+}
+
+// Watch reloads the store whenever one of its files changes, checking every
+// interval until stop is closed. Failed reloads are logged and the previous
+// configuration stays in use.
+func (s *Store) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !s.changed() {
+				continue
+			}
+			if err := s.Reload(); err != nil {
+				log.Printf("Failed to reload prompt configuration: %v", err)
+				// Don't retry until the files change again
+				s.markSeen()
+				continue
+			}
+			log.Printf("Reloaded prompt configuration: %+v", s.Status())
+		}
+	}
+}
+
+// changed reports whether either file's modification time differs from the
+// one last loaded, including files appearing or disappearing
+func (s *Store) changed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for name, loaded := range s.modTimes {
+		var modTime time.Time
+		if info, err := os.Stat(name); err == nil {
+			modTime = info.ModTime()
+		}
+		if !modTime.Equal(loaded) {
+			return true
+		}
+	}
+	return false
+}
+
+// markSeen records the files' current modification times without reloading
+func (s *Store) markSeen() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name := range s.modTimes {
+		var modTime time.Time
+		if info, err := os.Stat(name); err == nil {
+			modTime = info.ModTime()
+		}
+		s.modTimes[name] = modTime
+	}
+}
+
+// Status returns the currently loaded configuration
+func (s *Store) Status() Status {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+// Holidays returns the holidays between start and end, inclusive
+func (s *Store) Holidays(start, end time.Time) []Holiday {
+	from, to := start.Format("2006-01-02"), end.Format("2006-01-02")
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var holidays []Holiday
+	for _, holiday := range s.holidays {
+		if holiday.Date >= from && holiday.Date <= to {
+			holidays = append(holidays, holiday)
+		}
+	}
+	return holidays
+}
+
+// RenderForecast renders the forecast prompt and returns it with the version
+// of the template used
+func (s *Store) RenderForecast(data ForecastData) (string, string, error) {
+	s.mu.RLock()
+	tmpl, version := s.template, s.status.PromptVersion
+	s.mu.RUnlock()
+
+	var prompt bytes.Buffer
+	if err := tmpl.Execute(&prompt, data); err != nil {
+		return "", "", fmt.Errorf("failed to render prompt template: %v", err)
+	}
+	return strings.TrimRight(prompt.String(), "\n"), version, nil
+}
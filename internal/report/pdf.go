@@ -0,0 +1,320 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Page layout of the summary, in millimetres on A4 portrait
+const (
+	pageMargin  = 15.0
+	pageWidth   = 210.0
+	contentWide = pageWidth - 2*pageMargin
+)
+
+// color is an RGB color
+type color struct{ r, g, b int }
+
+// Summary colors
+var (
+	colorAccent = color{31, 78, 121}
+	colorMuted  = color{110, 110, 110}
+	colorRule   = color{210, 214, 220}
+	colorPanel  = color{242, 245, 249}
+	colorUp     = color{0, 128, 64}
+	colorDown   = color{192, 0, 0}
+)
+
+// pdfPage wraps a gofpdf document with the summary's text styles. Text is
+// translated from UTF-8 to the core fonts' encoding.
+type pdfPage struct {
+	pdf       *gofpdf.Fpdf
+	translate func(string) string
+}
+
+func newPDFPage() *pdfPage {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	// The summary is one page; content that doesn't fit is cut rather than
+	// spilling onto a second page
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	return &pdfPage{pdf: pdf, translate: pdf.UnicodeTranslatorFromDescriptor("")}
+}
+
+func (p *pdfPage) font(style string, size float64, c color) {
+	p.pdf.SetFont("Helvetica", style, size)
+	p.pdf.SetTextColor(c.r, c.g, c.b)
+}
+
+// text writes a line of text in a cell of width w at the current position
+func (p *pdfPage) text(w, h float64, text, align string, newLine bool) {
+	ln := 0
+	if newLine {
+		ln = 1
+	}
+	p.pdf.CellFormat(w, h, p.translate(text), "", ln, align, false, 0, "")
+}
+
+// heading writes a section heading with a rule under it at y and returns the
+// y below it
+func (p *pdfPage) heading(y float64, title string) float64 {
+	p.pdf.SetXY(pageMargin, y)
+	p.font("B", 12, colorAccent)
+	p.text(contentWide, 7, title, "L", true)
+	p.pdf.SetDrawColor(colorRule.r, colorRule.g, colorRule.b)
+	p.pdf.SetLineWidth(0.3)
+	p.pdf.Line(pageMargin, y+7.5, pageMargin+contentWide, y+7.5)
+	return y + 10
+}
+
+// changeColor returns the color of a positive or negative change
+func changeColor(change float64) color {
+	if change < 0 {
+		return colorDown
+	}
+	return colorUp
+}
+
+// RenderSummaryPDF writes the summary as a one-page A4 PDF
+func RenderSummaryPDF(w io.Writer, summary Summary) error {
+	p := newPDFPage()
+	p.pdf.SetTitle(summary.Title, true)
+	p.pdf.SetCreator("craft-demo", true)
+	p.pdf.SetCreationDate(summary.GeneratedAt)
+
+	y := p.header(summary)
+	y = p.totals(y, summary)
+	y = p.trend(y, summary)
+	y = p.categories(y, summary)
+	p.forecast(y, summary)
+	p.footer(summary)
+
+	if err := p.pdf.Error(); err != nil {
+		return fmt.Errorf("failed to render summary: %v", err)
+	}
+	return p.pdf.Output(w)
+}
+
+// header writes the title band and returns the y below it
+func (p *pdfPage) header(summary Summary) float64 {
+	p.pdf.SetFillColor(colorAccent.r, colorAccent.g, colorAccent.b)
+	p.pdf.Rect(0, 0, pageWidth, 32, "F")
+
+	p.pdf.SetXY(pageMargin, 9)
+	p.font("B", 20, color{255, 255, 255})
+	p.text(contentWide, 9, summary.Title, "L", true)
+	p.pdf.SetX(pageMargin)
+	p.font("", 11, color{220, 230, 240})
+	p.text(contentWide, 6, fmt.Sprintf("%s to %s",
+		summary.StartDate.Format("January 2, 2006"), summary.EndDate.Format("January 2, 2006")), "L", true)
+	return 40
+}
+
+// totals writes the headline figures as panels and returns the y below them
+func (p *pdfPage) totals(y float64, summary Summary) float64 {
+	days := int(summary.EndDate.Sub(summary.StartDate).Hours()/24) + 1
+	panels := []struct {
+		label, value, note string
+		noteColor          color
+	}{
+		{"Revenue", Money(summary.Revenue), "", colorMuted},
+		{"Previous period", Money(summary.PreviousRevenue), fmt.Sprintf("%d days before", days), colorMuted},
+		{"Change", "n/a", "no revenue before", colorMuted},
+		{"Daily average", Money(summary.Revenue / float64(max(days, 1))), fmt.Sprintf("over %d days", days), colorMuted},
+	}
+	if change, ok := Change(summary.Revenue, summary.PreviousRevenue); ok {
+		panels[2].value = Percent(change)
+		panels[2].note = Money(summary.Revenue - summary.PreviousRevenue)
+		panels[2].noteColor = changeColor(change)
+	}
+
+	gap := 4.0
+	width := (contentWide - gap*float64(len(panels)-1)) / float64(len(panels))
+	for i, panel := range panels {
+		x := pageMargin + float64(i)*(width+gap)
+		p.pdf.SetFillColor(colorPanel.r, colorPanel.g, colorPanel.b)
+		p.pdf.Rect(x, y, width, 24, "F")
+
+		p.pdf.SetXY(x+3, y+3)
+		p.font("", 8, colorMuted)
+		p.text(width-6, 4, panel.label, "L", false)
+		p.pdf.SetXY(x+3, y+8)
+		p.font("B", 13, color{0, 0, 0})
+		p.text(width-6, 7, panel.value, "L", false)
+		p.pdf.SetXY(x+3, y+16)
+		p.font("", 8, panel.noteColor)
+		p.text(width-6, 4, panel.note, "L", false)
+	}
+	return y + 30
+}
+
+// trend draws a sparkline of the daily revenue and returns the y below it
+func (p *pdfPage) trend(y float64, summary Summary) float64 {
+	y = p.heading(y, "Daily revenue")
+	height := 32.0
+	if len(summary.Trend) < 2 {
+		p.pdf.SetXY(pageMargin, y)
+		p.font("I", 9, colorMuted)
+		p.text(contentWide, 6, "Not enough days to show a trend.", "L", true)
+		return y + 12
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, point := range summary.Trend {
+		low = math.Min(low, point.Amount)
+		high = math.Max(high, point.Amount)
+	}
+	span := high - low
+	if span == 0 {
+		span = 1
+	}
+
+	// The line spans the content width less room for the min and max labels
+	labelWidth := 28.0
+	left, width := pageMargin, contentWide-labelWidth
+	step := width / float64(len(summary.Trend)-1)
+	point := func(i int) (float64, float64) {
+		return left + float64(i)*step, y + height - (summary.Trend[i].Amount-low)/span*height
+	}
+
+	p.pdf.SetDrawColor(colorRule.r, colorRule.g, colorRule.b)
+	p.pdf.SetLineWidth(0.2)
+	p.pdf.Line(left, y+height, left+width, y+height)
+
+	p.pdf.SetDrawColor(colorAccent.r, colorAccent.g, colorAccent.b)
+	p.pdf.SetLineWidth(0.5)
+	for i := 1; i < len(summary.Trend); i++ {
+		x1, y1 := point(i - 1)
+		x2, y2 := point(i)
+		p.pdf.Line(x1, y1, x2, y2)
+	}
+	lastX, lastY := point(len(summary.Trend) - 1)
+	p.pdf.SetFillColor(colorAccent.r, colorAccent.g, colorAccent.b)
+	p.pdf.Circle(lastX, lastY, 0.9, "F")
+
+	p.font("", 8, colorMuted)
+	p.pdf.SetXY(left+width+2, y-2)
+	p.text(labelWidth-2, 4, "High "+Money(high), "L", false)
+	p.pdf.SetXY(left+width+2, y+height-2)
+	p.text(labelWidth-2, 4, "Low "+Money(low), "L", false)
+	p.pdf.SetXY(left, y+height+1)
+	p.text(width/2, 4, summary.Trend[0].Date.Format("Jan 2"), "L", false)
+	p.text(width/2, 4, summary.Trend[len(summary.Trend)-1].Date.Format("Jan 2"), "R", false)
+	return y + height + 9
+}
+
+// categories writes the top categories as a table with share bars and
+// returns the y below it
+func (p *pdfPage) categories(y float64, summary Summary) float64 {
+	y = p.heading(y, "Top categories")
+	columns := []struct {
+		title string
+		width float64
+		align string
+	}{
+		{"Category", 56, "L"},
+		{"Revenue", 34, "R"},
+		{"Share", 52, "L"},
+		{"vs previous", 38, "R"},
+	}
+
+	p.pdf.SetXY(pageMargin, y)
+	p.font("B", 9, colorMuted)
+	for _, column := range columns {
+		p.text(column.width, 6, column.title, column.align, false)
+	}
+	y += 7
+
+	top := summary.Categories
+	if len(top) > TopCategories {
+		top = top[:TopCategories]
+	}
+	if len(top) == 0 {
+		p.pdf.SetXY(pageMargin, y)
+		p.font("I", 9, colorMuted)
+		p.text(contentWide, 6, "No category revenue in the range.", "L", true)
+		return y + 10
+	}
+
+	for _, category := range top {
+		p.pdf.SetXY(pageMargin, y)
+		p.font("", 10, color{0, 0, 0})
+		p.text(columns[0].width, 7, category.Name, "L", false)
+		p.text(columns[1].width, 7, Money(category.Amount), "R", false)
+
+		// The share bar, scaled to the whole range's revenue
+		share := 0.0
+		if summary.Revenue > 0 {
+			share = math.Max(0, category.Amount/summary.Revenue)
+		}
+		barX := p.pdf.GetX() + 4
+		barWidth := columns[2].width - 22
+		p.pdf.SetFillColor(colorPanel.r, colorPanel.g, colorPanel.b)
+		p.pdf.Rect(barX, y+2, barWidth, 3, "F")
+		p.pdf.SetFillColor(colorAccent.r, colorAccent.g, colorAccent.b)
+		p.pdf.Rect(barX, y+2, barWidth*math.Min(share, 1), 3, "F")
+		p.pdf.SetXY(barX+barWidth+1, y)
+		p.font("", 9, colorMuted)
+		p.text(17, 7, fmt.Sprintf("%.1f%%", share*100), "R", false)
+
+		change, ok := Change(category.Amount, category.Previous)
+		if ok {
+			p.font("", 10, changeColor(change))
+			p.text(columns[3].width, 7, Percent(change), "R", false)
+		} else {
+			p.font("", 10, colorMuted)
+			p.text(columns[3].width, 7, "new", "R", false)
+		}
+		y += 8
+	}
+	return y + 3
+}
+
+// forecast writes the forecast snapshot and returns the y below it
+func (p *pdfPage) forecast(y float64, summary Summary) float64 {
+	y = p.heading(y, "Forecast")
+	if len(summary.Forecast) == 0 {
+		p.pdf.SetXY(pageMargin, y)
+		p.font("I", 9, colorMuted)
+		p.text(contentWide, 6, "Not enough history to forecast.", "L", true)
+		return y + 10
+	}
+
+	gap := 4.0
+	width := (contentWide - gap*float64(len(summary.Forecast)-1)) / float64(len(summary.Forecast))
+	for i, point := range summary.Forecast {
+		x := pageMargin + float64(i)*(width+gap)
+		p.pdf.SetFillColor(colorPanel.r, colorPanel.g, colorPanel.b)
+		p.pdf.Rect(x, y, width, 22, "F")
+
+		p.pdf.SetXY(x+3, y+3)
+		p.font("", 8, colorMuted)
+		p.text(width-6, 4, point.Period, "L", false)
+		p.pdf.SetXY(x+3, y+8)
+		p.font("B", 12, color{0, 0, 0})
+		p.text(width-6, 6, Money(point.Amount), "L", false)
+		p.pdf.SetXY(x+3, y+15)
+		p.font("", 8, colorMuted)
+		p.text(width-6, 4, fmt.Sprintf("80%%: %s - %s", Money(point.Lower), Money(point.Upper)), "L", false)
+	}
+	y += 25
+
+	if summary.ForecastNote != "" {
+		p.pdf.SetXY(pageMargin, y)
+		p.font("I", 8, colorMuted)
+		p.text(contentWide, 4, summary.ForecastNote, "L", true)
+		y += 6
+	}
+	return y
+}
+
+// footer writes when the summary was generated at the bottom of the page
+func (p *pdfPage) footer(summary Summary) {
+	p.pdf.SetXY(pageMargin, 297-pageMargin-4)
+	p.font("", 8, colorMuted)
+	p.text(contentWide, 4, "Generated "+summary.GeneratedAt.UTC().Format("2006-01-02 15:04 MST"), "L", false)
+}
@@ -0,0 +1,93 @@
+// Package report renders documents that are shared outside the API, such as
+// the one-page sales summary PDF. The services fill in a view model, like
+// Summary, and the renderers lay it out; they don't query anything.
+package report
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Summary is the content of the one-page sales summary
+type Summary struct {
+	Title string
+	// StartDate and EndDate bound the summarized range, inclusive
+	StartDate   time.Time
+	EndDate     time.Time
+	GeneratedAt time.Time
+
+	// Revenue is the total of the range and PreviousRevenue the total of the
+	// same number of days before it
+	Revenue         float64
+	PreviousRevenue float64
+	// Categories are the categories' totals, highest first; the summary
+	// shows the first TopCategories
+	Categories []CategoryAmount
+	// Trend is the daily revenue of the range, oldest first
+	Trend []TrendPoint
+	// Forecast is the forecast of the periods after the range
+	Forecast []ForecastPoint
+	// ForecastNote says how the forecast was made
+	ForecastNote string
+}
+
+// TopCategories is how many categories the summary lists
+const TopCategories = 5
+
+// CategoryAmount is a category's revenue in the range and the previous one
+type CategoryAmount struct {
+	Name     string
+	Amount   float64
+	Previous float64
+}
+
+// TrendPoint is the revenue of a day
+type TrendPoint struct {
+	Date   time.Time
+	Amount float64
+}
+
+// ForecastPoint is the forecast of a period with its 80% band
+type ForecastPoint struct {
+	Period string
+	Amount float64
+	Lower  float64
+	Upper  float64
+}
+
+// Change returns the change from previous to current as a fraction of
+// previous, and false when previous is zero
+func Change(current, previous float64) (float64, bool) {
+	if previous == 0 {
+		return 0, false
+	}
+	return (current - previous) / previous, true
+}
+
+// Money formats an amount in dollars with thousands separators, e.g.
+// $12,345.67 or -$8.50
+func Money(amount float64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	cents := int64(math.Round(amount * 100))
+	whole := fmt.Sprintf("%d", cents/100)
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	return fmt.Sprintf("%s$%s.%02d", sign, grouped.String(), cents%100)
+}
+
+// Percent formats a fraction as a signed percentage, e.g. +12.5%
+func Percent(fraction float64) string {
+	return fmt.Sprintf("%+.1f%%", fraction*100)
+}
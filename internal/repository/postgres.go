@@ -0,0 +1,420 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Postgres implements the repositories on a Postgres connection pool
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres returns the repositories on db, which the caller keeps open
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// SalesByCategory implements SalesReportRepository
+func (p *Postgres) SalesByCategory(ctx context.Context, filter SalesReportFilter) ([]CategorySales, error) {
+	var result []CategorySales
+	err := p.EachSalesByCategory(ctx, filter, func(sales CategorySales) error {
+		result = append(result, sales)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// EachSalesByCategory implements SalesReportRepository
+func (p *Postgres) EachSalesByCategory(ctx context.Context, filter SalesReportFilter, fn func(CategorySales) error) error {
+	date := salesDateIn(filter.TimeZone, "$5")
+	query := fmt.Sprintf(`
+		SELECT
+			%s as date_recorded,
+			c.name as category_name,
+			st.currency,
+			SUM(st.total_amount) as total_amount,
+			SUM(st.gross_amount) as gross_amount,
+			SUM(st.discount_amount) as discount_amount,
+			SUM(st.refund_amount) as refund_amount,
+			SUM(st.quantity) as units,
+			COUNT(DISTINCT st.sale_transaction_id) FILTER (WHERE st.status <> 'refund') as transactions
+		FROM sales_totals_by_category_dw st
+		%s
+		%s
+		WHERE %s
+			AND ($3 OR st.deleted_at IS NULL)
+			AND %s
+		GROUP BY 1, 2, 3
+		ORDER BY 1, 2, 3
+	`, date.column, date.join, categoryJoin("st.category_id", filter.Rollup), date.between, statusIn("$4"))
+
+	args := []any{filter.StartDate, filter.EndDate, filter.IncludeDeleted, pq.Array(filter.Statuses)}
+	if filter.TimeZone != "" {
+		args = append(args, filter.TimeZone)
+	}
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query sales data: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sales CategorySales
+		if err := rows.Scan(&sales.Date, &sales.Category, &sales.Currency, &sales.Total, &sales.Gross, &sales.Discounts, &sales.Refunds, &sales.Units, &sales.Transactions); err != nil {
+			return fmt.Errorf("failed to scan row: %v", err)
+		}
+		if err := fn(sales); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %v", err)
+	}
+	return nil
+}
+
+// TopSellers implements SalesReportRepository. Product totals are summed
+// from the sale transaction items, with refunds subtracted, and category
+// totals from the data warehouse table.
+func (p *Postgres) TopSellers(ctx context.Context, filter TopSellersFilter) ([]Seller, float64, error) {
+	var query string
+	args := []any{filter.StartDate, filter.EndDate, filter.Limit}
+	switch filter.Dimension {
+	case DimensionProduct:
+		query = `
+			SELECT
+				p.id,
+				p.name,
+				SUM(CASE WHEN LOWER(st.status) = 'refund' THEN -sti.total_amount ELSE sti.total_amount END) AS total_amount,
+				SUM(SUM(CASE WHEN LOWER(st.status) = 'refund' THEN -sti.total_amount ELSE sti.total_amount END)) OVER () AS grand_total
+			FROM sale_transaction_items sti
+			JOIN sale_transactions st ON st.id = sti.sale_transaction_id
+			JOIN products p ON sti.product_id = p.id
+			WHERE st.date_recorded >= $1 AND st.date_recorded < $2::date + 1
+			GROUP BY p.id, p.name
+			ORDER BY total_amount DESC, p.name
+			LIMIT $3
+		`
+	case DimensionCategory:
+		query = fmt.Sprintf(`
+			SELECT
+				c.id,
+				c.name,
+				SUM(st.total_amount) AS total_amount,
+				SUM(SUM(st.total_amount)) OVER () AS grand_total
+			FROM sales_totals_by_category_dw st
+			%s
+			WHERE st.date_recorded >= $1 AND st.date_recorded <= $2
+				AND ($4 OR st.deleted_at IS NULL)
+			GROUP BY c.id, c.name
+			ORDER BY total_amount DESC, c.name
+			LIMIT $3
+		`, categoryJoin("st.category_id", filter.Rollup))
+		args = append(args, filter.IncludeDeleted)
+	default:
+		return nil, 0, fmt.Errorf("unknown top sellers dimension %q", filter.Dimension)
+	}
+
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query top sellers: %v", err)
+	}
+	defer rows.Close()
+
+	var (
+		sellers    []Seller
+		grandTotal float64
+	)
+	for rows.Next() {
+		var seller Seller
+		if err := rows.Scan(&seller.ID, &seller.Name, &seller.Total, &grandTotal); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan row: %v", err)
+		}
+		sellers = append(sellers, seller)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return sellers, grandTotal, nil
+}
+
+// groupLabels are the SQL naming a DW row st's value of each dimension the
+// grouped sales report groups by, and the joins of the tables they come from
+var groupLabels = map[string]struct{ label, join string }{
+	GroupByCategory: {label: "c.name"},
+	GroupByStore:    {label: "COALESCE(co.name, 'Unknown')", join: "LEFT JOIN companies co ON st.company_id = co.id"},
+	GroupByCustomer: {label: "COALESCE(NULLIF(TRIM(CONCAT(cu.first_name, ' ', cu.last_name)), ''), 'Unknown')", join: "LEFT JOIN customers cu ON st.customer_id = cu.id"},
+	GroupByCurrency: {label: "st.currency"},
+	GroupByStatus:   {label: "st.status"},
+}
+
+// GroupedSales implements SalesReportRepository
+func (p *Postgres) GroupedSales(ctx context.Context, filter SalesReportFilter, groupBy []string) ([]GroupedSales, error) {
+	date := salesDateIn(filter.TimeZone, "$5")
+	labels := make([]string, len(groupBy))
+	var joins []string
+	if date.join != "" {
+		joins = append(joins, date.join)
+	}
+	groups := []string{"1"}
+	for i, dimension := range groupBy {
+		group, ok := groupLabels[dimension]
+		if !ok {
+			return nil, fmt.Errorf("unknown group_by dimension %q", dimension)
+		}
+		labels[i] = group.label
+		join := group.join
+		if dimension == GroupByCategory {
+			join = categoryJoin("st.category_id", filter.Rollup)
+		}
+		if join != "" {
+			joins = append(joins, join)
+		}
+		groups = append(groups, fmt.Sprint(i+2))
+	}
+	groups = append(groups, fmt.Sprint(len(groupBy)+2))
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s, st.currency, SUM(st.total_amount)
+		FROM sales_totals_by_category_dw st
+		%s
+		WHERE %s
+			AND ($3 OR st.deleted_at IS NULL)
+			AND %s
+		GROUP BY %s
+	`, date.column, strings.Join(labels, ", "), strings.Join(joins, "\n\t\t"), date.between, statusIn("$4"), strings.Join(groups, ", "))
+
+	args := []any{filter.StartDate, filter.EndDate, filter.IncludeDeleted, pq.Array(filter.Statuses)}
+	if filter.TimeZone != "" {
+		args = append(args, filter.TimeZone)
+	}
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sales data: %v", err)
+	}
+	defer rows.Close()
+
+	var result []GroupedSales
+	for rows.Next() {
+		sales := GroupedSales{Values: make([]string, len(groupBy))}
+		dest := []any{&sales.Date}
+		for i := range sales.Values {
+			dest = append(dest, &sales.Values[i])
+		}
+		dest = append(dest, &sales.Currency, &sales.Total)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		result = append(result, sales)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return result, nil
+}
+
+// EachCustomerTypeSales implements SalesReportRepository. Customers' first
+// purchases are found among all their live sales, not only those in the
+// range, so a customer who bought before the range is returning in it.
+func (p *Postgres) EachCustomerTypeSales(ctx context.Context, filter CustomerTypeFilter, fn func(CustomerTypeSales) error) error {
+	query := fmt.Sprintf(`
+		WITH first_purchase AS (
+			SELECT customer_id, MIN(date_recorded) AS first_date
+			FROM sales_totals_by_category_dw
+			WHERE deleted_at IS NULL AND customer_id IS NOT NULL
+			GROUP BY customer_id
+		),
+		periods AS (
+			SELECT
+				CASE WHEN $3 = '' THEN (
+					SELECT MAX(b) FROM UNNEST($4::date[]) b WHERE b <= st.date_recorded
+				) ELSE DATE_TRUNC($3, st.date_recorded)::date END AS period,
+				st.category_id,
+				st.customer_id,
+				st.total_amount,
+				fp.first_date
+			FROM sales_totals_by_category_dw st
+			JOIN first_purchase fp ON fp.customer_id = st.customer_id
+			WHERE st.date_recorded >= $1 AND st.date_recorded <= $2
+				AND st.deleted_at IS NULL
+		),
+		classified AS (
+			-- A customer is new in the period containing their first purchase
+			SELECT *, first_date >= period AS is_new FROM periods
+		)
+		SELECT
+			cl.period,
+			c.name AS category_name,
+			COALESCE(SUM(cl.total_amount) FILTER (WHERE cl.is_new), 0) AS new_amount,
+			COALESCE(SUM(cl.total_amount) FILTER (WHERE NOT cl.is_new), 0) AS returning_amount,
+			COUNT(DISTINCT cl.customer_id) FILTER (WHERE cl.is_new) AS new_customers,
+			COUNT(DISTINCT cl.customer_id) FILTER (WHERE NOT cl.is_new) AS returning_customers
+		FROM classified cl
+		%s
+		GROUP BY cl.period, c.name
+		ORDER BY cl.period, c.name
+	`, categoryJoin("cl.category_id", filter.Rollup))
+
+	rows, err := p.db.QueryContext(ctx, query, filter.StartDate, filter.EndDate, filter.Unit, pq.Array(filter.PeriodStarts))
+	if err != nil {
+		return fmt.Errorf("failed to query customer type data: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sales CustomerTypeSales
+		if err := rows.Scan(&sales.PeriodStart, &sales.Category, &sales.NewAmount, &sales.ReturningAmount, &sales.NewCustomers, &sales.ReturningCustomers); err != nil {
+			return fmt.Errorf("failed to scan row: %v", err)
+		}
+		if err := fn(sales); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %v", err)
+	}
+	return nil
+}
+
+// CompareSales implements SalesReportRepository
+func (p *Postgres) CompareSales(ctx context.Context, filter ComparisonFilter) ([]ComparedSales, error) {
+	rows, err := p.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT
+			c.name,
+			COALESCE(SUM(st.total_amount) FILTER (WHERE st.date_recorded BETWEEN $1 AND $2), 0),
+			COALESCE(SUM(st.total_amount) FILTER (WHERE st.date_recorded BETWEEN $3 AND $4), 0)
+		FROM sales_totals_by_category_dw st
+		%s
+		WHERE (st.date_recorded BETWEEN $1 AND $2 OR st.date_recorded BETWEEN $3 AND $4)
+			AND ($5 OR st.deleted_at IS NULL)
+		GROUP BY c.name
+		ORDER BY c.name
+	`, categoryJoin("st.category_id", filter.Rollup)), filter.StartA, filter.EndA, filter.StartB, filter.EndB, filter.IncludeDeleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comparison: %v", err)
+	}
+	defer rows.Close()
+
+	var result []ComparedSales
+	for rows.Next() {
+		var sales ComparedSales
+		if err := rows.Scan(&sales.Category, &sales.TotalA, &sales.TotalB); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		result = append(result, sales)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return result, nil
+}
+
+// SoftDelete implements SalesTotalsRepository
+func (p *Postgres) SoftDelete(ctx context.Context, filter SalesTotalsFilter) (int64, error) {
+	return p.updateSalesTotals(ctx, `
+		UPDATE sales_totals_by_category_dw
+		SET deleted_at = NOW()
+		WHERE deleted_at IS NULL
+	`, filter)
+}
+
+// Restore implements SalesTotalsRepository
+func (p *Postgres) Restore(ctx context.Context, filter SalesTotalsFilter) (int64, error) {
+	return p.updateSalesTotals(ctx, `
+		UPDATE sales_totals_by_category_dw
+		SET deleted_at = NULL
+		WHERE deleted_at IS NOT NULL
+	`, filter)
+}
+
+// updateSalesTotals runs an update statement restricted to the rows matching
+// filter
+func (p *Postgres) updateSalesTotals(ctx context.Context, update string, filter SalesTotalsFilter) (int64, error) {
+	query := update + `
+		AND date_recorded >= $1 AND date_recorded <= $2
+		AND ($3 = 0 OR category_id = $3)
+		AND ($4 = 0 OR sale_transaction_id = $4)
+	`
+
+	result, err := p.db.ExecContext(ctx, query, filter.StartDate, filter.EndDate, filter.CategoryID, filter.SaleTransactionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update sales totals: %v", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// statusIn returns the SQL condition that a DW row, aliased st, has one of
+// the statuses in the text array query parameter statusParam, or any status
+// when the array is empty
+func statusIn(statusParam string) string {
+	return fmt.Sprintf("(cardinality(%[1]s::text[]) = 0 OR st.status = ANY(%[1]s))", statusParam)
+}
+
+// categoryJoin returns the SQL joining the category rows are reported under
+// as c, by their category ID column categoryColumn: their own category, or
+// with rollup its top-level ancestor, so subcategories total into their
+// category and categories into their department. Categories whose parents
+// form a cycle have no top level and are reported under themselves.
+func categoryJoin(categoryColumn string, rollup bool) string {
+	if !rollup {
+		return fmt.Sprintf("JOIN categories c ON c.id = %s", categoryColumn)
+	}
+	return fmt.Sprintf(`LEFT JOIN (
+			WITH RECURSIVE category_roots AS (
+				SELECT id, id AS root_id FROM categories WHERE parent_id IS NULL
+				UNION ALL
+				SELECT child.id, roots.root_id
+				FROM categories child
+				JOIN category_roots roots ON child.parent_id = roots.id
+			)
+			SELECT id, root_id FROM category_roots
+		) category_root ON category_root.id = %[1]s
+		JOIN categories c ON c.id = COALESCE(category_root.root_id, %[1]s)`, categoryColumn)
+}
+
+// salesDate is the SQL of the date a DW row, aliased st, is reported on
+type salesDate struct {
+	// column is the date, join joins the table it needs, and between is the
+	// condition that it's from $1 to $2
+	column  string
+	join    string
+	between string
+}
+
+// salesDateIn returns the SQL of the date of DW rows in the time zone named
+// by the query parameter zoneParam, or of their stored UTC date when
+// timeZone is empty. The DW table only keeps the UTC date, so other time
+// zones date rows by their sale transaction's timestamp, which is stored in
+// UTC; rows whose transaction no longer exists are left out.
+func salesDateIn(timeZone, zoneParam string) salesDate {
+	if timeZone == "" {
+		return salesDate{
+			column:  "DATE(st.date_recorded)",
+			between: "st.date_recorded >= $1 AND st.date_recorded <= $2",
+		}
+	}
+
+	local := fmt.Sprintf("DATE(sale.date_recorded AT TIME ZONE 'UTC' AT TIME ZONE %s)", zoneParam)
+	return salesDate{
+		column: local,
+		join:   "JOIN sale_transactions sale ON sale.id = st.sale_transaction_id",
+		// UTC offsets are within a day, so the indexed timestamp range
+		// around the dates narrows the rows before they're converted
+		between: fmt.Sprintf("sale.date_recorded >= $1::date - 1 AND sale.date_recorded < $2::date + 2 AND %s BETWEEN $1 AND $2", local),
+	}
+}
@@ -0,0 +1,184 @@
+// Package repository holds the SQL behind the services' handlers, behind
+// interfaces the handlers can be tested against without a database
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// SalesReportFilter selects the warehouse rows a sales report totals
+type SalesReportFilter struct {
+	// StartDate and EndDate bound the report, inclusive, as YYYY-MM-DD
+	StartDate string
+	EndDate   string
+	// IncludeDeleted includes soft-deleted warehouse rows in the totals
+	IncludeDeleted bool
+	// TimeZone is the IANA name of the time zone sales are dated in, or
+	// empty for the UTC dates stored in the DW table
+	TimeZone string
+	// Statuses are the transaction statuses to total, lower-cased, or empty
+	// for every status
+	Statuses []string
+	// Rollup totals each category under its top-level ancestor instead of
+	// under itself
+	Rollup bool
+}
+
+// CategorySales is a category's sales total for a day in one currency
+type CategorySales struct {
+	Date     time.Time
+	Category string
+	// Currency is the ISO 4217 code of the currency of the amounts
+	Currency string
+	// Total is the net revenue: Gross less Discounts and Refunds
+	Total     float64
+	Gross     float64
+	Discounts float64
+	Refunds   float64
+	// Units is the units sold net of refunds, and Transactions the number of
+	// sale transactions that aren't refunds
+	Units        int
+	Transactions int
+}
+
+// SalesReportRepository reads the sales reports
+type SalesReportRepository interface {
+	// SalesByCategory returns the daily total of each category in each
+	// currency, ordered by date, category name, and currency
+	SalesByCategory(ctx context.Context, filter SalesReportFilter) ([]CategorySales, error)
+	// EachSalesByCategory calls fn with the same rows as SalesByCategory as
+	// they're read, without holding them all, and stops at fn's first error
+	EachSalesByCategory(ctx context.Context, filter SalesReportFilter, fn func(CategorySales) error) error
+	// TopSellers returns the filter.Limit products or categories with the
+	// highest sales totals, highest first, and the total of all of them
+	TopSellers(ctx context.Context, filter TopSellersFilter) ([]Seller, float64, error)
+	// GroupedSales returns the daily total of each combination of the
+	// groupBy dimensions' values in each currency, in no particular order
+	GroupedSales(ctx context.Context, filter SalesReportFilter, groupBy []string) ([]GroupedSales, error)
+	// EachCustomerTypeSales calls fn with each period and category's revenue
+	// split between new and returning customers as it's read, ordered by
+	// period and category name, and stops at fn's first error
+	EachCustomerTypeSales(ctx context.Context, filter CustomerTypeFilter, fn func(CustomerTypeSales) error) error
+	// CompareSales returns each category's total in both of filter's
+	// ranges, ordered by category name
+	CompareSales(ctx context.Context, filter ComparisonFilter) ([]ComparedSales, error)
+}
+
+// Dimensions the grouped sales report groups by. Sales without a store or
+// customer are grouped under Unknown.
+const (
+	GroupByCategory = "category"
+	GroupByStore    = "store"
+	GroupByCustomer = "customer"
+	GroupByCurrency = "currency"
+	GroupByStatus   = "status"
+)
+
+// GroupedSales is a day's total for one combination of dimension values in
+// one currency
+type GroupedSales struct {
+	Date time.Time
+	// Values are the values of the dimensions grouped by, in their order
+	Values   []string
+	Currency string
+	Total    float64
+}
+
+// CustomerTypeFilter selects the sales the new vs returning customer report
+// splits. Soft-deleted warehouse rows are always left out.
+type CustomerTypeFilter struct {
+	// StartDate and EndDate bound the report, inclusive, as YYYY-MM-DD
+	StartDate string
+	EndDate   string
+	// Unit is the date_trunc unit periods are truncated to, or empty for
+	// periods starting on PeriodStarts
+	Unit string
+	// PeriodStarts are the YYYY-MM-DD starts of the periods when Unit is
+	// empty, such as fiscal periods
+	PeriodStarts []string
+	// Rollup totals each category under its top-level ancestor
+	Rollup bool
+}
+
+// CustomerTypeSales is a category's revenue in a period split between
+// customers new in that period and customers who bought before it
+type CustomerTypeSales struct {
+	PeriodStart        time.Time
+	Category           string
+	NewAmount          float64
+	ReturningAmount    float64
+	NewCustomers       int
+	ReturningCustomers int
+}
+
+// ComparisonFilter selects the sales compared between two date ranges
+type ComparisonFilter struct {
+	// StartA, EndA, StartB, and EndB bound the two ranges, inclusive, as
+	// YYYY-MM-DD. The ranges may overlap.
+	StartA string
+	EndA   string
+	StartB string
+	EndB   string
+	// IncludeDeleted includes soft-deleted warehouse rows in the totals
+	IncludeDeleted bool
+	// Rollup totals each category under its top-level ancestor
+	Rollup bool
+}
+
+// ComparedSales is a category's total in each of the compared ranges, zero
+// where it had no sales
+type ComparedSales struct {
+	Category string
+	TotalA   float64
+	TotalB   float64
+}
+
+// Dimensions top sellers are ranked by
+const (
+	DimensionProduct  = "product"
+	DimensionCategory = "category"
+)
+
+// TopSellersFilter selects the sales top sellers are ranked by
+type TopSellersFilter struct {
+	// Dimension is DimensionProduct or DimensionCategory
+	Dimension string
+	// StartDate and EndDate bound the sales, inclusive, as YYYY-MM-DD
+	StartDate string
+	EndDate   string
+	Limit     int
+	// IncludeDeleted includes soft-deleted warehouse rows in category
+	// totals. Product totals come from the sale transactions, which have no
+	// soft-deleted rows.
+	IncludeDeleted bool
+	// Rollup ranks categories with their subcategories' sales, leaving out
+	// the subcategories. It only applies to DimensionCategory.
+	Rollup bool
+}
+
+// Seller is a product or category and its sales total, net of refunds
+type Seller struct {
+	ID    int
+	Name  string
+	Total float64
+}
+
+// SalesTotalsFilter selects warehouse rows to soft delete or restore. A zero
+// CategoryID or SaleTransactionID matches every category or transaction.
+type SalesTotalsFilter struct {
+	StartDate         string
+	EndDate           string
+	CategoryID        int
+	SaleTransactionID int
+}
+
+// SalesTotalsRepository changes the rows of the data warehouse table
+type SalesTotalsRepository interface {
+	// SoftDelete marks the live rows matching filter as deleted and returns
+	// how many it marked
+	SoftDelete(ctx context.Context, filter SalesTotalsFilter) (int64, error)
+	// Restore clears the deleted mark of the rows matching filter and
+	// returns how many it restored
+	Restore(ctx context.Context, filter SalesTotalsFilter) (int64, error)
+}
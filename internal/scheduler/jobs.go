@@ -0,0 +1,218 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/alerts"
+	"github.com/bokor/craft-demo/internal/batch"
+	"github.com/bokor/craft-demo/internal/export"
+	"github.com/bokor/craft-demo/internal/integrations/shopify"
+	"github.com/bokor/craft-demo/internal/integrations/square"
+	"github.com/bokor/craft-demo/internal/notify"
+	"github.com/bokor/craft-demo/internal/services"
+	"github.com/robfig/cron/v3"
+)
+
+// scheduledJob is a job whose interval is read from env, defaulting to fallback
+type scheduledJob struct {
+	env      string
+	fallback time.Duration
+	job      Job
+}
+
+// JobsFromEnv returns the scheduled jobs with intervals from the environment.
+// Intervals are Go durations (e.g. 30m); 0 disables a job. Each job's
+// SCHEDULE_*_CRON variable, such as SCHEDULE_SALES_TOTALS_CRON, sets a cron
+// expression (e.g. "0 2 * * *", or "CRON_TZ=Australia/Sydney 0 2 * * *" for
+// another time zone than the server's) to run it on instead.
+//
+//	SCHEDULE_SALES_TOTALS_INTERVAL       regenerate the DW table (default 1h)
+//	SCHEDULE_CUSTOMER_SEGMENTS_INTERVAL  recompute RFM segments (default 24h)
+//	SCHEDULE_FORECAST_ACCURACY_INTERVAL  match tracked forecasts against actuals
+//	                                     (default 1h)
+//	SCHEDULE_ALERTS_INTERVAL             evaluate deviation alert rules (default 1h)
+//	SCHEDULE_FORECAST_REFRESH_INTERVAL   precompute every category's forecasts
+//	                                     (default 24h)
+//	SCHEDULE_FORECAST_STALENESS_INTERVAL queue stale precomputed forecasts
+//	                                     (default 15m)
+//	SCHEDULE_FORECAST_QUEUE_INTERVAL     regenerate queued forecasts (default 1m)
+//	SCHEDULE_REPORT_SUBSCRIPTIONS_INTERVAL
+//	                                     email due saved report subscriptions
+//	                                     (default 1h)
+//	SCHEDULE_BIGQUERY_EXPORT_INTERVAL    export the DW table to BigQuery (default 1h,
+//	                                     only when BIGQUERY_PROJECT is set)
+//	SCHEDULE_SNOWFLAKE_EXPORT_INTERVAL   export the DW table to Snowflake (default 1h,
+//	                                     only when SNOWFLAKE_ACCOUNT is set)
+//	SCHEDULE_SHOPIFY_SYNC_INTERVAL       sync orders updated in Shopify (default 15m,
+//	                                     only when SHOPIFY_SHOP is set)
+//	SCHEDULE_SQUARE_SYNC_INTERVAL        sync orders updated in Square (default 15m,
+//	                                     only when SQUARE_APPLICATION_ID is set)
+func JobsFromEnv(db *sql.DB) ([]Job, error) {
+	bands, err := batch.RFMBandsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	bigQuery, bigQueryEnabled, err := export.BigQueryConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	snowflake, snowflakeEnabled, err := export.SnowflakeConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	shopifyConfig, shopifyEnabled, err := shopify.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	squareConfig, squareEnabled, err := square.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	// Rules are read again on every run so edits apply without a restart
+	if _, err := alerts.RulesFromEnv(); err != nil {
+		return nil, err
+	}
+	channels, err := notify.ChannelsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	forecastQueue, err := services.ForecastQueueConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []scheduledJob{
+		{"SCHEDULE_SALES_TOTALS_INTERVAL", time.Hour, Job{
+			Name: "sales-totals",
+			Run: func(ctx context.Context) error {
+				// A manual run in progress means the data is being refreshed anyway
+				_, err := batch.RunSalesTotals(db, batch.TriggerScheduler, batch.LockOptions{Mode: batch.LockSkip}, batch.RegenerateOptions{})
+				if errors.Is(err, batch.ErrLockHeld) {
+					return nil
+				}
+				return err
+			},
+		}},
+		{"SCHEDULE_CUSTOMER_SEGMENTS_INTERVAL", 24 * time.Hour, Job{
+			Name: "customer-segments",
+			Run: func(ctx context.Context) error {
+				return batch.GenerateCustomerSegments(db, bands, time.Now())
+			},
+		}},
+		{"SCHEDULE_FORECAST_ACCURACY_INTERVAL", time.Hour, Job{
+			Name: "forecast-accuracy",
+			Run: func(ctx context.Context) error {
+				_, err := batch.MatchForecastActuals(db, time.Now())
+				return err
+			},
+		}},
+		{"SCHEDULE_ALERTS_INTERVAL", time.Hour, Job{
+			Name: "deviation-alerts",
+			Run: func(ctx context.Context) error {
+				rules, err := alerts.RulesFromEnv()
+				if err != nil {
+					return err
+				}
+				return alerts.Run(ctx, db, rules, channels, time.Now())
+			},
+		}},
+		{"SCHEDULE_FORECAST_REFRESH_INTERVAL", 24 * time.Hour, Job{
+			Name: "forecast-refresh",
+			Run: func(ctx context.Context) error {
+				return services.RefreshForecasts(ctx, db, time.Now())
+			},
+		}},
+		{"SCHEDULE_FORECAST_STALENESS_INTERVAL", 15 * time.Minute, Job{
+			Name: "forecast-staleness",
+			Run: func(ctx context.Context) error {
+				return services.EnqueueStaleForecasts(ctx, db, forecastQueue, time.Now())
+			},
+		}},
+		{"SCHEDULE_FORECAST_QUEUE_INTERVAL", time.Minute, Job{
+			Name: "forecast-queue",
+			Run: func(ctx context.Context) error {
+				return services.ProcessForecastQueue(ctx, db, forecastQueue, time.Now())
+			},
+		}},
+		{"SCHEDULE_REPORT_SUBSCRIPTIONS_INTERVAL", time.Hour, Job{
+			Name: "report-subscriptions",
+			Run: func(ctx context.Context) error {
+				return services.SendReportSubscriptions(ctx, db, channels, time.Now())
+			},
+		}},
+	}
+
+	if bigQueryEnabled {
+		candidates = append(candidates, scheduledJob{"SCHEDULE_BIGQUERY_EXPORT_INTERVAL", time.Hour, Job{
+			Name: "bigquery-export",
+			Run: func(ctx context.Context) error {
+				return export.ExportToBigQuery(ctx, db, bigQuery)
+			},
+		}})
+	}
+	if snowflakeEnabled {
+		candidates = append(candidates, scheduledJob{"SCHEDULE_SNOWFLAKE_EXPORT_INTERVAL", time.Hour, Job{
+			Name: "snowflake-export",
+			Run: func(ctx context.Context) error {
+				return export.ExportToSnowflake(ctx, db, snowflake)
+			},
+		}})
+	}
+
+	if shopifyEnabled {
+		syncer := shopify.NewSyncer(db, shopifyConfig)
+		candidates = append(candidates, scheduledJob{"SCHEDULE_SHOPIFY_SYNC_INTERVAL", 15 * time.Minute, Job{
+			Name: "shopify-sync",
+			Run:  syncer.Sync,
+		}})
+	}
+
+	if squareEnabled {
+		syncer := square.NewSyncer(db, squareConfig)
+		candidates = append(candidates, scheduledJob{"SCHEDULE_SQUARE_SYNC_INTERVAL", 15 * time.Minute, Job{
+			Name: "square-sync",
+			Run:  syncer.Sync,
+		}})
+	}
+
+	var jobs []Job
+	for _, candidate := range candidates {
+		cronEnv := strings.TrimSuffix(candidate.env, "_INTERVAL") + "_CRON"
+		if value := os.Getenv(cronEnv); value != "" {
+			schedule, err := cron.ParseStandard(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %v", cronEnv, value, err)
+			}
+			candidate.job.Schedule = schedule
+			jobs = append(jobs, candidate.job)
+			continue
+		}
+
+		interval := candidate.fallback
+		if value := os.Getenv(candidate.env); value != "" {
+			interval, err = time.ParseDuration(value)
+			if err != nil || interval < 0 {
+				return nil, fmt.Errorf("invalid %s %q", candidate.env, value)
+			}
+		}
+		if interval == 0 {
+			continue
+		}
+
+		candidate.job.Interval = interval
+		jobs = append(jobs, candidate.job)
+	}
+
+	return jobs, nil
+}
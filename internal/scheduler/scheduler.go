@@ -0,0 +1,208 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// leaderLockKey is the Postgres advisory lock key held by the leader replica
+const leaderLockKey int64 = 0x5c4ed01e
+
+// Job is a task run on a fixed interval or a cron schedule
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Schedule, when set, runs the job at the times of a cron expression
+	// instead of every Interval
+	Schedule cron.Schedule
+	Run      func(ctx context.Context) error
+}
+
+// Scheduler runs jobs on the replica that holds the leader lock. Leadership
+// is a session-level advisory lock on a dedicated connection, so it moves to
+// another replica as soon as the leader's connection drops. Each run is also
+// claimed in scheduled_job_runs, so a job runs at most once per interval even
+// right after a failover. The leader runs jobs one at a time, so a job never
+// overlaps its own previous run.
+type Scheduler struct {
+	db       *sql.DB
+	jobs     []Job
+	identity string
+	// tick is how often the leader checks for due jobs and followers retry
+	// the leader lock
+	tick time.Duration
+}
+
+// New returns a scheduler for the given jobs
+func New(db *sql.DB, jobs []Job) *Scheduler {
+	identity, err := os.Hostname()
+	if err != nil {
+		identity = "unknown"
+	}
+	identity = fmt.Sprintf("%s/%d", identity, os.Getpid())
+
+	return &Scheduler{db: db, jobs: jobs, identity: identity, tick: 15 * time.Second}
+}
+
+// Run campaigns for leadership and runs due jobs while leader, until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("Scheduler started as %s with %d jobs", s.identity, len(s.jobs))
+
+	for {
+		if err := s.lead(ctx); err != nil {
+			log.Printf("Scheduler: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.tick):
+		}
+	}
+}
+
+// lead acquires the leader lock if it's free and runs due jobs for as long as
+// the lock's connection stays healthy
+func (s *Scheduler) lead(ctx context.Context) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open leader connection: %v", err)
+	}
+	defer conn.Close()
+
+	var leader bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", leaderLockKey).Scan(&leader); err != nil {
+		return fmt.Errorf("failed to acquire leader lock: %v", err)
+	}
+	if !leader {
+		return nil
+	}
+	log.Printf("Scheduler: %s is now the leader", s.identity)
+
+	defer func() {
+		// Use a fresh context so the lock is released on shutdown too
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", leaderLockKey); err != nil {
+			log.Printf("Scheduler: failed to release leader lock: %v", err)
+		}
+	}()
+
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	for {
+		for _, job := range s.jobs {
+			s.runIfDue(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		// Stop leading if the lock's session is gone
+		if err := conn.PingContext(ctx); err != nil {
+			return fmt.Errorf("lost leader connection: %v", err)
+		}
+	}
+}
+
+// runIfDue claims and runs a job if its interval has passed, or its cron
+// schedule has come round, since the last run by any replica
+func (s *Scheduler) runIfDue(ctx context.Context, job Job) {
+	claim := s.claim
+	if job.Schedule != nil {
+		claim = s.claimScheduled
+	}
+	claimed, err := claim(ctx, job)
+	if err != nil {
+		log.Printf("Scheduler: failed to claim %s: %v", job.Name, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	log.Printf("Scheduler: running %s", job.Name)
+	started := time.Now()
+	if err := job.Run(ctx); err != nil {
+		log.Printf("Scheduler: %s failed after %v: %v", job.Name, time.Since(started), err)
+		return
+	}
+	log.Printf("Scheduler: %s completed in %v", job.Name, time.Since(started))
+}
+
+// claim records a run of the job if none happened within its interval. The
+// conditional upsert makes the claim atomic across replicas.
+func (s *Scheduler) claim(ctx context.Context, job Job) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO scheduled_job_runs (job_name, last_run_at, run_by)
+		VALUES ($1, NOW(), $2)
+		ON CONFLICT (job_name) DO UPDATE
+			SET last_run_at = NOW(), run_by = EXCLUDED.run_by
+			WHERE scheduled_job_runs.last_run_at <= NOW() - make_interval(secs => $3)
+	`, job.Name, s.identity, job.Interval.Seconds())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// claimScheduled records a run of a cron job if a scheduled time has passed
+// since its last run. The row is locked so replicas can't both claim it. A
+// job without a row yet starts its schedule instead of running right away,
+// so a nightly job doesn't also run when it's first deployed.
+func (s *Scheduler) claimScheduled(ctx context.Context, job Job) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// last_run_at is stored in the session time zone, as NOW() was
+	var lastRun, now time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT last_run_at::timestamptz, NOW()
+		FROM scheduled_job_runs
+		WHERE job_name = $1
+		FOR UPDATE
+	`, job.Name).Scan(&lastRun, &now)
+	if err == sql.ErrNoRows {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO scheduled_job_runs (job_name, last_run_at, run_by)
+			VALUES ($1, NOW(), $2)
+			ON CONFLICT (job_name) DO NOTHING
+		`, job.Name, s.identity)
+		if err != nil {
+			return false, err
+		}
+		return false, tx.Commit()
+	}
+	if err != nil {
+		return false, err
+	}
+	// Cron expressions without CRON_TZ are in the server's time zone, which
+	// robfig/cron takes from the time passed to Next, not the session's
+	if job.Schedule.Next(lastRun.In(time.Local)).After(now) {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE scheduled_job_runs SET last_run_at = NOW(), run_by = $2 WHERE job_name = $1
+	`, job.Name, s.identity)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,243 @@
+package seeder
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Seed describes the rows to insert into a single table
+type Seed struct {
+	Table   string   `json:"table" yaml:"table"`
+	Columns []string `json:"columns" yaml:"columns"`
+	Values  [][]any  `json:"values" yaml:"values"`
+}
+
+// CSVMapping maps a CSV seed file's header to a table's columns. It's read
+// from a YAML file with the CSV file's name and a .yaml extension.
+type CSVMapping struct {
+	// Table defaults to the CSV file's name without its extension and any
+	// NN_ ordering prefix
+	Table string `yaml:"table"`
+	// Columns maps header names to column names. When set, only the mapped
+	// CSV columns are inserted.
+	Columns map[string]string `yaml:"columns"`
+}
+
+// SeedDir seeds the database from every seed file in dir, in file name order:
+// JSON seeds, CSV files with a header row, and raw SQL scripts. A CSV file's
+// header names its columns unless a CSVMapping maps them. Files and rows that
+// fail are logged and don't stop the others.
+func SeedDir(db *sql.DB, dir string) error {
+	return seedDir(db, dir, false)
+}
+
+// SeedDirStrict seeds the database from dir like SeedDir, but stops at the
+// first file or row that fails and returns its error
+func SeedDirStrict(db *sql.DB, dir string) error {
+	return seedDir(db, dir, true)
+}
+
+func seedDir(db *sql.DB, dir string, strict bool) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("error reading seed directory: %v", err)
+	}
+
+	// failed returns err when strict, and logs it otherwise
+	failed := func(err error) error {
+		if strict {
+			return err
+		}
+		log.Println(err)
+		return nil
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+
+		var err error
+		switch filepath.Ext(file.Name()) {
+		case ".json":
+			var content []byte
+			content, err = os.ReadFile(path)
+			if err != nil {
+				err = fmt.Errorf("error reading file %s: %v", file.Name(), err)
+				break
+			}
+			var data Seed
+			if err = json.Unmarshal(content, &data); err != nil {
+				err = fmt.Errorf("error unmarshalling JSON from file %s: %v", file.Name(), err)
+				break
+			}
+			err = insert(db, data, file.Name(), strict)
+		case ".csv":
+			var data Seed
+			data, err = ReadCSV(path)
+			if err != nil {
+				err = fmt.Errorf("error reading CSV from file %s: %v", file.Name(), err)
+				break
+			}
+			err = insert(db, data, file.Name(), strict)
+		case ".sql":
+			var content []byte
+			content, err = os.ReadFile(path)
+			if err != nil {
+				err = fmt.Errorf("error reading file %s: %v", file.Name(), err)
+				break
+			}
+			if _, err = db.Exec(string(content)); err != nil {
+				err = fmt.Errorf("error executing SQL from file %s: %v", file.Name(), err)
+			}
+		}
+		if err != nil {
+			if err := failed(err); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadCSV reads a CSV seed file, with its CSVMapping if it has one. Empty
+// fields are inserted as NULL.
+func ReadCSV(path string) (Seed, error) {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	mapping := CSVMapping{Table: tableName(base)}
+	content, err := os.ReadFile(strings.TrimSuffix(path, filepath.Ext(path)) + ".yaml")
+	if err == nil {
+		if err := yaml.Unmarshal(content, &mapping); err != nil {
+			return Seed{}, fmt.Errorf("invalid mapping: %v", err)
+		}
+		if mapping.Table == "" {
+			mapping.Table = tableName(base)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Seed{}, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return Seed{}, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return Seed{}, fmt.Errorf("failed to read header: %v", err)
+	}
+
+	// fields are the positions of the inserted columns in each record
+	data := Seed{Table: mapping.Table}
+	var fields []int
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if i == 0 {
+			// Spreadsheet exports often start with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		column := name
+		if mapping.Columns != nil {
+			if column = mapping.Columns[name]; column == "" {
+				continue
+			}
+		}
+		data.Columns = append(data.Columns, column)
+		fields = append(fields, i)
+	}
+	if len(data.Columns) == 0 {
+		return Seed{}, fmt.Errorf("no columns to insert")
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Seed{}, err
+		}
+		row := make([]any, len(fields))
+		for i, field := range fields {
+			if record[field] != "" {
+				row[i] = record[field]
+			}
+		}
+		data.Values = append(data.Values, row)
+	}
+
+	return data, nil
+}
+
+// tableName returns the table a seed file's base name seeds, without an NN_
+// ordering prefix
+func tableName(base string) string {
+	prefix, name, found := strings.Cut(base, "_")
+	if _, err := strconv.Atoi(prefix); found && err == nil {
+		return name
+	}
+	return base
+}
+
+// Insert truncates the seed's table and inserts its rows. Row errors are
+// logged with the seed's source name and don't stop the remaining rows.
+func Insert(db *sql.DB, data Seed, source string) {
+	insert(db, data, source, false)
+}
+
+// InsertStrict truncates the seed's table and inserts its rows like Insert,
+// but stops at the first row that fails and returns its error
+func InsertStrict(db *sql.DB, data Seed, source string) error {
+	return insert(db, data, source, true)
+}
+
+func insert(db *sql.DB, data Seed, source string, strict bool) error {
+	if _, err := db.Exec("TRUNCATE TABLE " + data.Table + " RESTART IDENTITY CASCADE"); err != nil && strict {
+		return fmt.Errorf("failed to truncate %s for %s: %v", data.Table, source, err)
+	}
+	// Prepare the SQL statement
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		data.Table,
+		strings.Join(data.Columns, ","),
+		prepareInsertQuery(data.Columns),
+	)
+
+	for i, value := range data.Values {
+		_, err := db.Exec(query, value...)
+		if err != nil {
+			if strict {
+				return fmt.Errorf("failed to insert row %d of %s into %s: %v", i+1, source, data.Table, err)
+			}
+			log.Printf("Error executing query for file %s: %v\n", source, err)
+		}
+	}
+	return nil
+}
+
+func prepareInsertQuery(columns []string) string {
+	var query string
+
+	for i := range columns {
+		if i != len(columns)-1 {
+			query += fmt.Sprintf("$%s,", strconv.Itoa(i+1))
+			continue
+		}
+		query += fmt.Sprintf("$%s", strconv.Itoa(i+1))
+	}
+	return query
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/batch"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// Batch run listing limits
+const (
+	defaultBatchRunsLimit = 50
+	maxBatchRunsLimit     = 500
+)
+
+// batchRuns tracks the runs started through the API so shutdown can wait for
+// them to record their result
+var batchRuns sync.WaitGroup
+
+// BatchRunsResponse represents the recorded batch runs, newest first
+type BatchRunsResponse struct {
+	Runs []batch.Run `json:"runs"`
+}
+
+// TriggerSalesTotalsRun handles the API request to regenerate sales totals
+// @Summary Start a sales totals regeneration
+// @Description Starts regenerating sales_totals_by_category_dw in the background, optionally narrowed to a date range and category, and returns the run recorded in batch_runs. The run is skipped if another regeneration holds the batch lock. Poll GET /admin/batch/runs for its result.
+// @Tags admin
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param start_date query string false "First date regenerated, YYYY-MM-DD"
+// @Param end_date query string false "Last date regenerated, YYYY-MM-DD"
+// @Param category_id query int false "Only regenerate this category"
+// @Success 202 {object} batch.Run "Run started"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid range or category"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /admin/batch/sales-totals [post]
+func TriggerSalesTotalsRun(c echo.Context) error {
+	options, err := parseRegenerateOptions(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		id, err := batch.StartRun(db, batch.SalesTotalsJob, batch.TriggerAPI, options)
+		if err != nil {
+			return err
+		}
+
+		batchRuns.Add(1)
+		go func() {
+			defer batchRuns.Done()
+			result, runErr := batch.RegenerateSalesTotals(db, batch.LockOptions{Mode: batch.LockSkip}, options)
+			if runErr != nil {
+				log.Printf("Batch run %d failed: %v", id, runErr)
+			}
+			if err := batch.FinishRun(db, id, result, runErr); err != nil {
+				log.Printf("Batch run %d: %v", id, err)
+			}
+		}()
+
+		run, err := batch.GetRun(db, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusAccepted, run)
+	})
+}
+
+// ListBatchRuns handles the API request for batch run history
+// @Summary List batch runs
+// @Description Returns recorded batch runs, newest first, with their trigger, status, duration, and row counts. Runs are recorded whether started through the API, by the scheduler, or from the command line.
+// @Tags admin
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param job query string false "Only runs of this job, e.g. sales-totals"
+// @Param status query string false "Only runs with this status" Enums(running, succeeded, failed, skipped)
+// @Param limit query int false "Maximum number of runs (default 50, max 500)"
+// @Success 200 {object} BatchRunsResponse "Batch runs"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid filter"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /admin/batch/runs [get]
+func ListBatchRuns(c echo.Context) error {
+	status := c.QueryParam("status")
+	switch status {
+	case "", batch.RunRunning, batch.RunSucceeded, batch.RunFailed, batch.RunSkipped:
+	default:
+		return httperror.JSON(c, http.StatusBadRequest, "status must be running, succeeded, failed, or skipped")
+	}
+
+	limit := defaultBatchRunsLimit
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxBatchRunsLimit {
+			return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxBatchRunsLimit))
+		}
+		limit = parsed
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		runs, err := batch.ListRuns(db, c.QueryParam("job"), status, limit)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, BatchRunsResponse{Runs: runs})
+	})
+}
+
+// WaitForBatchRuns waits until the runs started through the API have
+// finished, or ctx is done
+func WaitForBatchRuns(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		batchRuns.Wait()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func parseRegenerateOptions(c echo.Context) (batch.RegenerateOptions, error) {
+	options := batch.RegenerateOptions{
+		StartDate: c.QueryParam("start_date"),
+		EndDate:   c.QueryParam("end_date"),
+	}
+
+	var start, end time.Time
+	var err error
+	if options.StartDate != "" {
+		if start, err = time.Parse("2006-01-02", options.StartDate); err != nil {
+			return options, fmt.Errorf("Invalid start_date format. Use YYYY-MM-DD")
+		}
+	}
+	if options.EndDate != "" {
+		if end, err = time.Parse("2006-01-02", options.EndDate); err != nil {
+			return options, fmt.Errorf("Invalid end_date format. Use YYYY-MM-DD")
+		}
+	}
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		return options, fmt.Errorf("end_date must not be before start_date")
+	}
+
+	if value := c.QueryParam("category_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return options, fmt.Errorf("Invalid category_id")
+		}
+		options.CategoryID = id
+	}
+
+	return options, nil
+}
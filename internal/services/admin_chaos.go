@@ -0,0 +1,75 @@
+package services
+
+import (
+	"net/http"
+
+	"github.com/bokor/craft-demo/internal/chaos"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// ChaosConfigResponse represents the current failure-injection configuration
+type ChaosConfigResponse struct {
+	Enabled bool                   `json:"enabled"`
+	Faults  map[string]chaos.Fault `json:"faults"`
+}
+
+// GetChaosConfig handles the API request for the failure-injection configuration
+// @Summary Get failure-injection configuration
+// @Description Returns whether chaos mode is enabled and the faults configured per dependency
+// @Tags admin
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Success 200 {object} ChaosConfigResponse "Current chaos configuration"
+// @Router /admin/chaos [get]
+func GetChaosConfig(c echo.Context) error {
+	return c.JSON(http.StatusOK, ChaosConfigResponse{
+		Enabled: chaos.Enabled(),
+		Faults:  chaos.Faults(),
+	})
+}
+
+// UpdateChaosConfig handles the API request to inject faults into a dependency
+// @Summary Configure failure injection for a dependency
+// @Description Sets the latency and error rates injected into the db or llm dependency. Requires CHAOS_ENABLED=true.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param target path string true "Dependency to inject faults into (db or llm)"
+// @Param fault body chaos.Fault true "Fault configuration"
+// @Success 200 {object} ChaosConfigResponse "Updated chaos configuration"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid fault"
+// @Failure 409 {object} httperror.Envelope "Chaos mode is disabled"
+// @Router /admin/chaos/{target} [put]
+func UpdateChaosConfig(c echo.Context) error {
+	if !chaos.Enabled() {
+		return httperror.JSON(c, http.StatusConflict, "Chaos mode is disabled. Set CHAOS_ENABLED=true to enable it")
+	}
+
+	var fault chaos.Fault
+	if err := c.Bind(&fault); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid request format")
+	}
+
+	if err := chaos.Set(c.Param("target"), fault); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	return GetChaosConfig(c)
+}
+
+// ResetChaosConfig handles the API request to remove all injected faults
+// @Summary Reset failure injection
+// @Description Removes all configured faults
+// @Tags admin
+// @Security BasicAuth
+// @Security BearerAuth
+// @Success 204 "Faults removed"
+// @Router /admin/chaos [delete]
+func ResetChaosConfig(c echo.Context) error {
+	chaos.Reset()
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/batch"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// Data quality issue listing limits
+const (
+	defaultDataQualityLimit = 100
+	maxDataQualityLimit     = 1000
+)
+
+// DataQualityIssuesResponse represents the data quality issues found by the
+// sales totals batch
+type DataQualityIssuesResponse struct {
+	// Counts is the number of matching issues found by each check
+	Counts map[string]int           `json:"counts"`
+	Issues []batch.DataQualityIssue `json:"issues"`
+}
+
+// ListDataQualityIssues handles the API request for data quality issues
+// @Summary List data quality issues
+// @Description Returns the problems the sales totals batch found in the source data before aggregating, newest transactions first, with the number found by each check. Each batch run replaces the issues for the dates it covers, so fixed data drops out after the next run.
+// @Tags admin
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param check query string false "Only issues found by this check" Enums(missing_product, orphaned_category, negative_quantity, future_date)
+// @Param start_date query string false "Only transactions recorded on or after this date, YYYY-MM-DD"
+// @Param end_date query string false "Only transactions recorded on or before this date, YYYY-MM-DD"
+// @Param limit query int false "Maximum number of issues (default 100, max 1000)"
+// @Success 200 {object} DataQualityIssuesResponse "Data quality issues"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid filter"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /admin/data-quality/issues [get]
+func ListDataQualityIssues(c echo.Context) error {
+	filter := batch.DataQualityFilter{
+		Check:     c.QueryParam("check"),
+		StartDate: c.QueryParam("start_date"),
+		EndDate:   c.QueryParam("end_date"),
+	}
+	if filter.Check != "" && !slices.Contains(batch.DataQualityChecks, filter.Check) {
+		return httperror.JSON(c, http.StatusBadRequest, "check must be "+strings.Join(batch.DataQualityChecks, ", "))
+	}
+	if _, err := time.Parse("2006-01-02", filter.StartDate); filter.StartDate != "" && err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid start_date format. Use YYYY-MM-DD")
+	}
+	if _, err := time.Parse("2006-01-02", filter.EndDate); filter.EndDate != "" && err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid end_date format. Use YYYY-MM-DD")
+	}
+
+	limit := defaultDataQualityLimit
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxDataQualityLimit {
+			return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxDataQualityLimit))
+		}
+		limit = parsed
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		counts, err := batch.CountDataQualityIssues(db, filter)
+		if err != nil {
+			return err
+		}
+		issues, err := batch.ListDataQualityIssues(db, filter, limit)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, DataQualityIssuesResponse{Counts: counts, Issues: issues})
+	})
+}
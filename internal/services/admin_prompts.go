@@ -0,0 +1,55 @@
+package services
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/prompts"
+	"github.com/labstack/echo/v4"
+)
+
+// GetPromptConfig handles the API request for the loaded prompt configuration
+// @Summary Get prompt configuration
+// @Description Returns the version and source of the forecast prompt template and holiday calendar currently in use
+// @Tags admin
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Success 200 {object} prompts.Status "Loaded prompt configuration"
+// @Failure 500 {object} httperror.Envelope "Prompt configuration failed to load"
+// @Router /admin/prompts [get]
+func GetPromptConfig(c echo.Context) error {
+	store, err := prompts.Default()
+	if err != nil {
+		log.Printf("Failed to load prompt configuration: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Failed to load prompt configuration")
+	}
+
+	return c.JSON(http.StatusOK, store.Status())
+}
+
+// ReloadPromptConfig handles the API request to reload the prompt configuration
+// @Summary Reload prompt configuration
+// @Description Reloads the forecast prompt template and holiday calendar from disk. If either file is invalid the previous configuration stays in use.
+// @Tags admin
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Success 200 {object} prompts.Status "Reloaded prompt configuration"
+// @Failure 422 {object} httperror.Envelope "Invalid prompt template or holiday calendar"
+// @Failure 500 {object} httperror.Envelope "Prompt configuration failed to load"
+// @Router /admin/prompts/reload [post]
+func ReloadPromptConfig(c echo.Context) error {
+	store, err := prompts.Default()
+	if err != nil {
+		log.Printf("Failed to load prompt configuration: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Failed to load prompt configuration")
+	}
+
+	if err := store.Reload(); err != nil {
+		return httperror.JSON(c, http.StatusUnprocessableEntity, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, store.Status())
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// SalesTotalsChangeResponse represents the number of warehouse rows changed
+// by a soft delete or restore
+type SalesTotalsChangeResponse struct {
+	Affected int64 `json:"affected"`
+}
+
+// SoftDeleteSalesTotals handles the API request to soft delete warehouse rows
+// @Summary Soft delete warehouse rows
+// @Description Marks sales_totals_by_category_dw rows in the date range as deleted so they are excluded from reports. Deleted rows survive batch regeneration until restored.
+// @Tags admin
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param start_date query string true "Start date in YYYY-MM-DD format"
+// @Param end_date query string true "End date in YYYY-MM-DD format"
+// @Param category_id query int false "Only rows for this category"
+// @Param sale_transaction_id query int false "Only rows for this sale transaction"
+// @Success 200 {object} SalesTotalsChangeResponse "Number of rows soft deleted"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid filter"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /admin/sales-totals [delete]
+func SoftDeleteSalesTotals(c echo.Context) error {
+	return changeSalesTotals(c, repository.SalesTotalsRepository.SoftDelete)
+}
+
+// RestoreSalesTotals handles the API request to restore soft-deleted warehouse rows
+// @Summary Restore soft-deleted warehouse rows
+// @Description Clears the deleted flag on sales_totals_by_category_dw rows in the date range so they are included in reports again
+// @Tags admin
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param start_date query string true "Start date in YYYY-MM-DD format"
+// @Param end_date query string true "End date in YYYY-MM-DD format"
+// @Param category_id query int false "Only rows for this category"
+// @Param sale_transaction_id query int false "Only rows for this sale transaction"
+// @Success 200 {object} SalesTotalsChangeResponse "Number of rows restored"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid filter"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /admin/sales-totals/restore [post]
+func RestoreSalesTotals(c echo.Context) error {
+	return changeSalesTotals(c, repository.SalesTotalsRepository.Restore)
+}
+
+// changeSalesTotals applies a sales totals repository change to the rows
+// matching the request's filter
+func changeSalesTotals(c echo.Context, change func(repository.SalesTotalsRepository, context.Context, repository.SalesTotalsFilter) (int64, error)) error {
+	ctx := c.Request().Context()
+	filter, err := parseSalesTotalsFilter(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	// Get database connection
+	db, err := getDB()
+	if err != nil {
+		log.Printf("Database connection failed: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Database connection failed")
+	}
+
+	affected, err := change(salesTotalsRepository(db), ctx, filter)
+	if err != nil {
+		log.Printf("Failed to update sales totals: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Failed to update sales totals")
+	}
+
+	return c.JSON(http.StatusOK, SalesTotalsChangeResponse{Affected: affected})
+}
+
+func parseSalesTotalsFilter(c echo.Context) (repository.SalesTotalsFilter, error) {
+	filter := repository.SalesTotalsFilter{
+		StartDate: c.QueryParam("start_date"),
+		EndDate:   c.QueryParam("end_date"),
+	}
+
+	// A date range is always required so a bare request can't touch the whole table
+	if _, err := time.Parse("2006-01-02", filter.StartDate); err != nil {
+		return filter, fmt.Errorf("Invalid start_date format. Use YYYY-MM-DD")
+	}
+	if _, err := time.Parse("2006-01-02", filter.EndDate); err != nil {
+		return filter, fmt.Errorf("Invalid end_date format. Use YYYY-MM-DD")
+	}
+
+	if value := c.QueryParam("category_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return filter, fmt.Errorf("Invalid category_id")
+		}
+		filter.CategoryID = id
+	}
+	if value := c.QueryParam("sale_transaction_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id <= 0 {
+			return filter, fmt.Errorf("Invalid sale_transaction_id")
+		}
+		filter.SaleTransactionID = id
+	}
+
+	return filter, nil
+}
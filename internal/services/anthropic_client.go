@@ -0,0 +1,299 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/bokor/craft-demo/internal/chaos"
+)
+
+// Anthropic API settings
+const (
+	defaultAnthropicBaseURL = "https://api.anthropic.com/v1"
+	defaultAnthropicModel   = "claude-sonnet-4-5"
+	anthropicVersion        = "2023-06-01"
+	// anthropicMaxTokens bounds a reply unless the request sets max tokens;
+	// forecasts need far fewer
+	anthropicMaxTokens = 4096
+)
+
+// anthropicClient is the LLMClient for the Anthropic Messages API. It is
+// configured from the environment:
+//
+//	ANTHROPIC_API_KEY    API key
+//	ANTHROPIC_BASE_URL   API root (defaults to https://api.anthropic.com/v1)
+//	ANTHROPIC_MODEL      model (defaults to claude-sonnet-4-5)
+type anthropicClient struct {
+	apiKey  string
+	baseURL string
+	model   string
+}
+
+// anthropicRequest is a Messages API request. System messages go in System
+// rather than Messages.
+type anthropicRequest struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	System      string    `json:"system,omitempty"`
+	Messages    []Message `json:"messages"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+// anthropicResponse is the part of a Messages API response the client reads
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage *anthropicUsage `json:"usage"`
+}
+
+// anthropicUsage is the token usage of a Messages API response
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// tokenUsage converts the usage to the chat completions usage block
+func (u anthropicUsage) tokenUsage() *TokenUsage {
+	return &TokenUsage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+// anthropicStreamEvent is the part of a streamed Messages API event the
+// client reads. message_start carries the input tokens, content_block_delta
+// the reply text, and message_delta the output tokens so far.
+type anthropicStreamEvent struct {
+	Message struct {
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage anthropicUsage `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// newAnthropicClient returns an Anthropic client configured from the
+// environment after checking the API key's format
+func newAnthropicClient() (anthropicClient, error) {
+	apiKey := os.Getenv("ANTHROPIC_API_KEY")
+	if apiKey == "" {
+		return anthropicClient{}, fmt.Errorf("no Anthropic API key found")
+	}
+	if !strings.HasPrefix(apiKey, "sk-ant-") {
+		return anthropicClient{}, fmt.Errorf("invalid Anthropic API key format")
+	}
+
+	client := anthropicClient{apiKey: apiKey, baseURL: defaultAnthropicBaseURL, model: defaultAnthropicModel}
+	if baseURL := os.Getenv("ANTHROPIC_BASE_URL"); baseURL != "" {
+		client.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	if model := os.Getenv("ANTHROPIC_MODEL"); model != "" {
+		client.model = model
+	}
+	return client, nil
+}
+
+func (c anthropicClient) Provider() string {
+	return llmProviderAnthropic
+}
+
+func (c anthropicClient) Model() string {
+	return c.model
+}
+
+// Chat sends a request to the Messages API, giving up when ctx is done, and
+// returns the reply as a single chat choice. Claude accepts temperatures up
+// to 1.
+func (c anthropicClient) Chat(ctx context.Context, request ChatGPTRequest) (*ChatGPTResponse, error) {
+	resp, err := c.post(ctx, request, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var messagesResponse anthropicResponse
+	if err := json.NewDecoder(resp.Body).Decode(&messagesResponse); err != nil {
+		return nil, err
+	}
+
+	var content strings.Builder
+	for _, block := range messagesResponse.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	response := anthropicChatResponse(content.String())
+	if messagesResponse.Usage != nil {
+		response.Usage = messagesResponse.Usage.tokenUsage()
+	}
+	return response, nil
+}
+
+// ChatStream sends a streaming request to the Messages API and returns the
+// assembled reply
+func (c anthropicClient) ChatStream(ctx context.Context, request ChatGPTRequest, onContent func(string)) (*ChatGPTResponse, error) {
+	resp, err := c.post(ctx, request, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		content strings.Builder
+		usage   anthropicUsage
+	)
+	err = readEvents(resp.Body, func(event, data string) error {
+		var streamEvent anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &streamEvent); err != nil {
+			return fmt.Errorf("invalid Anthropic stream event: %v", err)
+		}
+		switch event {
+		case "message_start":
+			usage = streamEvent.Message.Usage
+		case "content_block_delta":
+			if streamEvent.Delta.Type == "text_delta" {
+				content.WriteString(streamEvent.Delta.Text)
+				onContent(streamEvent.Delta.Text)
+			}
+		case "message_delta":
+			usage.OutputTokens = streamEvent.Usage.OutputTokens
+		case "message_stop":
+			return errEndOfEvents
+		case "error":
+			return fmt.Errorf("Anthropic API stream error: %s", streamEvent.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := anthropicChatResponse(content.String())
+	response.Usage = usage.tokenUsage()
+	return response, nil
+}
+
+// anthropicChatResponse returns a reply as a single chat choice, or no
+// choices when it has no text
+func anthropicChatResponse(content string) *ChatGPTResponse {
+	if content == "" {
+		return &ChatGPTResponse{}
+	}
+	return &ChatGPTResponse{Choices: []Choice{{Message: Message{Role: "assistant", Content: content}}}}
+}
+
+// post sends a Messages API request and returns the response when its
+// status is OK. The caller closes its body.
+func (c anthropicClient) post(ctx context.Context, request ChatGPTRequest, stream bool) (*http.Response, error) {
+	if err := chaos.Inject(chaos.TargetLLM); err != nil {
+		return nil, err
+	}
+
+	messagesRequest := anthropicRequest{
+		Model:       request.Model,
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+		Stream:      stream,
+	}
+	if messagesRequest.Model == "" {
+		messagesRequest.Model = c.model
+	}
+	if messagesRequest.MaxTokens == 0 {
+		messagesRequest.MaxTokens = anthropicMaxTokens
+	}
+	var system []string
+	for _, message := range request.Messages {
+		if message.Role == "system" {
+			system = append(system, message.Content)
+			continue
+		}
+		messagesRequest.Messages = append(messagesRequest.Messages, message)
+	}
+	messagesRequest.System = strings.Join(system, "\n\n")
+
+	jsonData, err := json.Marshal(messagesRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/messages", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.setHeaders(req)
+
+	// The caller's context bounds the request instead of a client timeout
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Anthropic API response status: %d", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if body, err := io.ReadAll(io.LimitReader(resp.Body, 1024)); err == nil {
+			log.Printf("Anthropic API error response: %s", body)
+		}
+
+		switch resp.StatusCode {
+		case 401:
+			return nil, fmt.Errorf("Anthropic API authentication failed - check your API key")
+		case 404:
+			return nil, fmt.Errorf("Anthropic API endpoint or model not found - check ANTHROPIC_MODEL")
+		case 429:
+			return nil, fmt.Errorf("Anthropic API rate limit exceeded")
+		case 529:
+			return nil, fmt.Errorf("Anthropic API overloaded")
+		case 500:
+			return nil, fmt.Errorf("Anthropic API server error")
+		default:
+			return nil, fmt.Errorf("Anthropic API returned status: %d", resp.StatusCode)
+		}
+	}
+
+	return resp, nil
+}
+
+func (c anthropicClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/models", nil)
+	if err != nil {
+		return err
+	}
+	c.setHeaders(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("models endpoint returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// setHeaders sets the authentication and version headers on a request
+func (c anthropicClient) setHeaders(req *http.Request) {
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	req.Header.Set("User-Agent", "CraftDemo/1.0")
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/labstack/echo/v4"
+)
+
+// arrowStreamMIMEType is the media type of the Arrow IPC stream format
+const arrowStreamMIMEType = "application/vnd.apache.arrow.stream"
+
+// arrowBatchSize is the number of rows per Arrow record batch
+const arrowBatchSize = 10000
+
+// wantsArrow reports whether the request asked for ?format=arrow
+func wantsArrow(c echo.Context) bool {
+	return c.QueryParam("format") == "arrow"
+}
+
+// validateReportFormat checks that the format of a report is json or one of
+// the other formats it supports
+func validateReportFormat(c echo.Context, formats ...string) error {
+	format := c.QueryParam("format")
+	if format == "" || format == "json" || slices.Contains(formats, format) {
+		return nil
+	}
+
+	all := append([]string{"json"}, formats...)
+	if len(all) == 2 {
+		return fmt.Errorf("Invalid format. Use %s or %s", all[0], all[1])
+	}
+	return fmt.Errorf("Invalid format. Use %s, or %s", strings.Join(all[:len(all)-1], ", "), all[len(all)-1])
+}
+
+// writeArrowStream streams rows as Arrow IPC record batches. fill appends row
+// i to the builder's fields in schema order.
+func writeArrowStream(c echo.Context, filename string, schema *arrow.Schema, rows int, fill func(b *array.RecordBuilder, i int)) error {
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, arrowStreamMIMEType)
+	response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.arrows"`, filename))
+	response.WriteHeader(http.StatusOK)
+
+	writer := ipc.NewWriter(response, ipc.WithSchema(schema))
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+
+	for start := 0; start < rows; start += arrowBatchSize {
+		end := min(start+arrowBatchSize, rows)
+		for i := start; i < end; i++ {
+			fill(builder, i)
+		}
+
+		record := builder.NewRecord()
+		err := writer.Write(record)
+		record.Release()
+		if err != nil {
+			writer.Close()
+			return fmt.Errorf("failed to write arrow record batch: %v", err)
+		}
+		response.Flush()
+	}
+
+	return writer.Close()
+}
+
+// date32 converts a YYYY-MM-DD date to an Arrow date32
+func date32(date string) arrow.Date32 {
+	parsed, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0
+	}
+	return arrow.Date32FromTime(parsed)
+}
+
+// sortedPeriods returns the keys of a period-keyed report in order
+func sortedPeriods[T any](report map[string][]T) []string {
+	periods := make([]string, 0, len(report))
+	for period := range report {
+		periods = append(periods, period)
+	}
+	sort.Strings(periods)
+	return periods
+}
+
+// categoryReportArrowSchema is the Arrow schema of the sales report by category
+var categoryReportArrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "date", Type: arrow.FixedWidthTypes.Date32},
+	{Name: "category_name", Type: arrow.BinaryTypes.String},
+	{Name: "total_amount", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "gross_sales", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "discounts", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "refunds", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "units_sold", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "transactions", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "average_order_value", Type: arrow.PrimitiveTypes.Float64},
+}, nil)
+
+// writeCategoryReportArrow streams the sales report by category as one row
+// per date and category
+func writeCategoryReportArrow(c echo.Context, report map[string][]CategoryTotal) error {
+	type row struct {
+		date  string
+		total CategoryTotal
+	}
+	var rows []row
+	for _, date := range sortedPeriods(report) {
+		for _, total := range report[date] {
+			rows = append(rows, row{date, total})
+		}
+	}
+
+	return writeArrowStream(c, "sales-report-category", categoryReportArrowSchema, len(rows), func(b *array.RecordBuilder, i int) {
+		total := rows[i].total
+		b.Field(0).(*array.Date32Builder).Append(date32(rows[i].date))
+		b.Field(1).(*array.StringBuilder).Append(total.CategoryName)
+		b.Field(2).(*array.Float64Builder).Append(total.TotalAmount)
+		b.Field(3).(*array.Float64Builder).Append(total.GrossSales)
+		b.Field(4).(*array.Float64Builder).Append(total.Discounts)
+		b.Field(5).(*array.Float64Builder).Append(total.Refunds)
+		b.Field(6).(*array.Int64Builder).Append(int64(total.UnitsSold))
+		b.Field(7).(*array.Int64Builder).Append(int64(total.Transactions))
+		b.Field(8).(*array.Float64Builder).Append(total.AverageOrderValue)
+	})
+}
+
+// customerTypeArrowSchema is the Arrow schema of the new vs returning customer report
+var customerTypeArrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "period", Type: arrow.FixedWidthTypes.Date32},
+	{Name: "category_name", Type: arrow.BinaryTypes.String},
+	{Name: "new_amount", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "returning_amount", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "new_customers", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "returning_customers", Type: arrow.PrimitiveTypes.Int64},
+}, nil)
+
+// writeCustomerTypeReportArrow streams the new vs returning customer report
+// as one row per period and category
+func writeCustomerTypeReportArrow(c echo.Context, report map[string][]CustomerTypeTotal) error {
+	type row struct {
+		period string
+		total  CustomerTypeTotal
+	}
+	var rows []row
+	for _, period := range sortedPeriods(report) {
+		for _, total := range report[period] {
+			rows = append(rows, row{period, total})
+		}
+	}
+
+	return writeArrowStream(c, "sales-report-customers", customerTypeArrowSchema, len(rows), func(b *array.RecordBuilder, i int) {
+		total := rows[i].total
+		b.Field(0).(*array.Date32Builder).Append(date32(rows[i].period))
+		b.Field(1).(*array.StringBuilder).Append(total.CategoryName)
+		b.Field(2).(*array.Float64Builder).Append(total.NewAmount)
+		b.Field(3).(*array.Float64Builder).Append(total.ReturningAmount)
+		b.Field(4).(*array.Int64Builder).Append(int64(total.NewCustomers))
+		b.Field(5).(*array.Int64Builder).Append(int64(total.ReturningCustomers))
+	})
+}
+
+// replenishmentArrowSchema is the Arrow schema of the replenishment report
+var replenishmentArrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "product_id", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "sku", Type: arrow.BinaryTypes.String},
+	{Name: "name", Type: arrow.BinaryTypes.String},
+	{Name: "category", Type: arrow.BinaryTypes.String},
+	{Name: "stock", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "average_daily_units", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "lead_time_demand", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "safety_stock", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "suggested_order_qty", Type: arrow.PrimitiveTypes.Int64},
+}, nil)
+
+// writeReplenishmentArrow streams the replenishment suggestions
+func writeReplenishmentArrow(c echo.Context, suggestions []ReplenishmentSuggestion, asOf time.Time) error {
+	filename := "replenishment-" + asOf.Format("2006-01-02")
+	return writeArrowStream(c, filename, replenishmentArrowSchema, len(suggestions), func(b *array.RecordBuilder, i int) {
+		s := suggestions[i]
+		b.Field(0).(*array.Int64Builder).Append(int64(s.ProductID))
+		b.Field(1).(*array.StringBuilder).Append(s.SKU)
+		b.Field(2).(*array.StringBuilder).Append(s.Name)
+		b.Field(3).(*array.StringBuilder).Append(s.CategoryName)
+		b.Field(4).(*array.Float64Builder).Append(s.Stock)
+		b.Field(5).(*array.Float64Builder).Append(s.AverageDailyUnits)
+		b.Field(6).(*array.Float64Builder).Append(s.LeadTimeDemand)
+		b.Field(7).(*array.Float64Builder).Append(s.SafetyStock)
+		b.Field(8).(*array.Int64Builder).Append(int64(s.SuggestedOrderQty))
+	})
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/pb"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxForecastCategories bounds the categories of one forecast request
+const maxForecastCategories = 50
+
+// CategoryForecasts is the response to a forecast request with categories
+type CategoryForecasts struct {
+	// Forecasts holds each category's ForecastResponse in the requested
+	// schema version, keyed by category name
+	Forecasts map[string]any `json:"forecasts"`
+	Message   string         `json:"message"`
+}
+
+// forecastConcurrency returns how many categories of a request are forecast
+// at once, set with FORECAST_CONCURRENCY (default 4)
+func forecastConcurrency() int {
+	if value := os.Getenv("FORECAST_CONCURRENCY"); value != "" {
+		if workers, err := strconv.Atoi(value); err == nil && workers > 0 {
+			return workers
+		}
+		log.Printf("Warning: invalid FORECAST_CONCURRENCY %q, using 4", value)
+	}
+	return 4
+}
+
+// generateCategoryForecasts validates every category of a request before
+// forecasting them concurrently, and responds with all the forecasts. Each
+// forecast has its own deadline, and the first category to fail fails the
+// request. When FORECAST_FALLBACK is statistical, each falls back on its
+// own instead, so the response is 200 even when some fell back.
+func generateCategoryForecasts(c echo.Context, request ForecastRequest, timePeriod string, schemaVersion int, stream bool) error {
+	switch {
+	case len(request.TimeSeriesData) > 0:
+		return httperror.JSON(c, http.StatusBadRequest, "Send timeSeriesData or categories, not both")
+	case len(request.Categories) == 0:
+		return httperror.JSON(c, http.StatusBadRequest, "No categories provided")
+	case len(request.Categories) > maxForecastCategories:
+		return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("At most %d categories can be forecast at once", maxForecastCategories))
+	case request.Track:
+		return httperror.JSON(c, http.StatusBadRequest, "track isn't supported with categories; forecast each category with categoryId to track it")
+	case stream:
+		return httperror.JSON(c, http.StatusBadRequest, "stream isn't supported with categories")
+	}
+
+	names := make([]string, 0, len(request.Categories))
+	for name := range request.Categories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	prepared := make(map[string]*preparedForecast, len(names))
+	for _, name := range names {
+		categoryRequest := request
+		categoryRequest.Categories = nil
+		categoryRequest.TimeSeriesData = request.Categories[name]
+		forecast, err := prepareForecast(categoryRequest, timePeriod)
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("Category %q: %v", name, err))
+		}
+		prepared[name] = forecast
+	}
+
+	responses, status, err := runForecasts(c.Request().Context(), names, prepared)
+	if err != nil {
+		return httperror.JSON(c, status, err.Error())
+	}
+	if wantsXLSX(c) {
+		workbook, err := buildCategoryForecastsXLSX(responses)
+		if err != nil {
+			log.Printf("Failed to build workbook: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to build workbook")
+		}
+		defer workbook.Close()
+		return writeXLSX(c, http.StatusOK, "forecast-categories-"+timePeriod, workbook)
+	}
+
+	body := CategoryForecasts{
+		Forecasts: make(map[string]any, len(responses)),
+		Message:   fmt.Sprintf("Forecasts generated for %d categories", len(responses)),
+	}
+	for name, response := range responses {
+		body.Forecasts[name] = versionedForecast(schemaVersion, response)
+	}
+	setForecastSchemaHeaders(c, schemaVersion)
+	return respondNegotiated(c, http.StatusOK, body, func() proto.Message {
+		message := &pb.CategoryForecasts{
+			Forecasts: make(map[string]*pb.ForecastResponse, len(responses)),
+			Message:   body.Message,
+		}
+		for name, response := range responses {
+			message.Forecasts[name] = forecastResponseProto(response)
+		}
+		return message
+	})
+}
+
+// runForecasts runs the prepared forecasts on a pool of
+// FORECAST_CONCURRENCY workers, starting them in the order of names. It
+// returns the first forecast to fail, if any, with its status.
+func runForecasts(ctx context.Context, names []string, prepared map[string]*preparedForecast) (map[string]ForecastResponse, int, error) {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		responses = make(map[string]ForecastResponse, len(names))
+		jobs      = make(chan string)
+		status    int
+		failed    error
+	)
+	for range min(forecastConcurrency(), len(names)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				response, code, err := prepared[name].run(ctx, nil)
+				mu.Lock()
+				responses[name] = response
+				if err != nil && failed == nil {
+					status, failed = code, fmt.Errorf("Category %q: %v", name, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, name := range names {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+	return responses, status, failed
+}
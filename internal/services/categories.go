@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// Category represents a product category
+type Category struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id"`
+	Archived bool   `json:"archived"`
+}
+
+// CategoryRequest represents the request body for creating or updating a category
+type CategoryRequest struct {
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id"`
+}
+
+// Category status values stored in categories.status
+const (
+	categoryStatusArchived = 0
+	categoryStatusActive   = 1
+)
+
+// errCategoryNotFound is returned when a category ID doesn't exist
+var errCategoryNotFound = &notFoundError{resource: "Category"}
+
+const categoryColumns = "id, name, parent_id, status"
+
+// ListCategories handles the API request for listing categories
+// @Summary List categories
+// @Description Returns product categories ordered by name, optionally only those whose name contains q (case-insensitive). Archived categories are excluded unless include_archived is set.
+// @Tags categories
+// @Produce json
+// @Param q query string false "Only categories whose name contains this text"
+// @Param include_archived query bool false "Include archived categories (defaults to false)"
+// @Success 200 {array} Category "Categories"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /categories [get]
+func ListCategories(c echo.Context) error {
+	ctx := c.Request().Context()
+	search := strings.TrimSpace(c.QueryParam("q"))
+	includeArchived := c.QueryParam("include_archived") == "true"
+
+	return withDB(c, func(db *sql.DB) error {
+		categories, err := scanCategories(db.QueryContext(
+			ctx, "SELECT "+categoryColumns+` FROM categories
+			WHERE STRPOS(LOWER(name), LOWER($1)) > 0 AND ($2 OR status <> $3)
+			ORDER BY name`,
+			search, includeArchived, categoryStatusArchived,
+		))
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, categories)
+	})
+}
+
+// GetCategory handles the API request for a single category
+// @Summary Get a category
+// @Description Returns a single product category, including archived ones
+// @Tags categories
+// @Produce json
+// @Param id path int true "Category ID"
+// @Success 200 {object} Category "Category"
+// @Failure 404 {object} httperror.Envelope "Category not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /categories/{id} [get]
+func GetCategory(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid category id")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		category, err := queryCategory(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, category)
+	})
+}
+
+// CreateCategory handles the API request for creating a category
+// @Summary Create a category
+// @Description Creates a product category. Names must be unique (case-insensitive) and the parent must exist.
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param request body CategoryRequest true "Category to create"
+// @Success 201 {object} Category "Created category"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid category"
+// @Failure 409 {object} httperror.Envelope "Category name already exists"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /categories [post]
+func CreateCategory(c echo.Context) error {
+	ctx := c.Request().Context()
+	request, err := bindCategoryRequest(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		if err := validateCategory(ctx, db, 0, request); err != nil {
+			return err
+		}
+
+		category := Category{Name: request.Name, ParentID: request.ParentID}
+		err := db.QueryRowContext(
+			ctx, "INSERT INTO categories (name, parent_id) VALUES ($1, $2) RETURNING id",
+			request.Name, request.ParentID,
+		).Scan(&category.ID)
+		if err != nil {
+			return fmt.Errorf("failed to insert category: %v", err)
+		}
+
+		return c.JSON(http.StatusCreated, category)
+	})
+}
+
+// UpdateCategory handles the API request for updating a category
+// @Summary Update a category
+// @Description Renames or re-parents a product category. A category can't become its own ancestor.
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Category ID"
+// @Param request body CategoryRequest true "Updated category"
+// @Success 200 {object} Category "Updated category"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid category"
+// @Failure 404 {object} httperror.Envelope "Category not found"
+// @Failure 409 {object} httperror.Envelope "Name already exists or parent would create a cycle"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /categories/{id} [put]
+func UpdateCategory(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid category id")
+	}
+
+	request, err := bindCategoryRequest(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		category, err := queryCategory(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		if err := validateCategory(ctx, db, id, request); err != nil {
+			return err
+		}
+
+		if _, err := db.ExecContext(
+			ctx, "UPDATE categories SET name = $1, parent_id = $2 WHERE id = $3",
+			request.Name, request.ParentID, id,
+		); err != nil {
+			return fmt.Errorf("failed to update category: %v", err)
+		}
+
+		category.Name, category.ParentID = request.Name, request.ParentID
+		return c.JSON(http.StatusOK, category)
+	})
+}
+
+// DeleteCategory handles the API request for deleting a category
+// @Summary Delete a category
+// @Description Deletes a product category. Categories still referenced by products or warehouse rows can only be deleted with reassign_to, which moves those products and warehouse rows to another category. Child categories are moved to the deleted category's parent.
+// @Tags categories
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Category ID"
+// @Param reassign_to query int false "Category to move products and warehouse rows to"
+// @Success 204 "Category deleted"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid category"
+// @Failure 404 {object} httperror.Envelope "Category not found"
+// @Failure 409 {object} httperror.Envelope "Category is still in use"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /categories/{id} [delete]
+func DeleteCategory(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid category id")
+	}
+
+	reassignTo := 0
+	if value := c.QueryParam("reassign_to"); value != "" {
+		reassignTo, err = strconv.Atoi(value)
+		if err != nil || reassignTo == id {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid reassign_to category")
+		}
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		category, err := queryCategory(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		if reassignTo != 0 {
+			if _, err := queryCategory(ctx, db, reassignTo); err != nil {
+				return &conflictError{message: "reassign_to category does not exist"}
+			}
+		}
+
+		if err := deleteCategory(ctx, db, category, reassignTo); err != nil {
+			return err
+		}
+
+		return c.NoContent(http.StatusNoContent)
+	})
+}
+
+// ArchiveCategory handles the API request for archiving a category
+// @Summary Archive a category
+// @Description Archives a category so it is hidden from category lists and takes no new products, while its history stays in reports. Categories with active products or active child categories can't be archived; archive or move those first.
+// @Tags categories
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Category ID"
+// @Success 200 {object} Category "Archived category"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid category"
+// @Failure 404 {object} httperror.Envelope "Category not found"
+// @Failure 409 {object} httperror.Envelope "Category is still in use"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /categories/{id}/archive [post]
+func ArchiveCategory(c echo.Context) error {
+	return setCategoryStatus(c, categoryStatusArchived)
+}
+
+// RestoreCategory handles the API request for restoring an archived category
+// @Summary Restore a category
+// @Description Makes an archived category active again. Its parent must be active.
+// @Tags categories
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Category ID"
+// @Success 200 {object} Category "Restored category"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid category"
+// @Failure 404 {object} httperror.Envelope "Category not found"
+// @Failure 409 {object} httperror.Envelope "Parent category is archived"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /categories/{id}/restore [post]
+func RestoreCategory(c echo.Context) error {
+	return setCategoryStatus(c, categoryStatusActive)
+}
+
+// setCategoryStatus archives or restores the category in the path, checking
+// the categories and products that depend on it in the same transaction
+func setCategoryStatus(c echo.Context, status int) error {
+	ctx := c.Request().Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid category id")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+		defer tx.Rollback()
+
+		category, err := scanCategory(tx.QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM categories WHERE id = $1 FOR UPDATE", id))
+		if err != nil {
+			return err
+		}
+
+		if status == categoryStatusArchived {
+			var products, children int
+			err := tx.QueryRowContext(ctx, `
+				SELECT
+					(SELECT COUNT(*) FROM products WHERE category_id = $1 AND status <> $2),
+					(SELECT COUNT(*) FROM categories WHERE parent_id = $1 AND status <> $3)
+			`, id, productStatusArchived, categoryStatusArchived).Scan(&products, &children)
+			if err != nil {
+				return fmt.Errorf("failed to count category dependents: %v", err)
+			}
+			if products > 0 || children > 0 {
+				return &conflictError{message: fmt.Sprintf(
+					"Category has %d active products and %d active child categories. Archive or move them first",
+					products, children,
+				)}
+			}
+		} else if category.ParentID != nil {
+			parent, err := scanCategory(tx.QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM categories WHERE id = $1", *category.ParentID))
+			if err != nil {
+				return err
+			}
+			if parent.Archived {
+				return &conflictError{message: "Parent category is archived. Restore it first"}
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE categories SET status = $1 WHERE id = $2", status, id); err != nil {
+			return fmt.Errorf("failed to update category status: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %v", err)
+		}
+
+		category.Archived = status == categoryStatusArchived
+		return c.JSON(http.StatusOK, category)
+	})
+}
+
+func bindCategoryRequest(c echo.Context) (CategoryRequest, error) {
+	var request CategoryRequest
+	if err := c.Bind(&request); err != nil {
+		return request, fmt.Errorf("Invalid request format")
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		return request, fmt.Errorf("Category name is required")
+	}
+	if len(request.Name) > 255 {
+		return request, fmt.Errorf("Category name must be at most 255 characters")
+	}
+
+	return request, nil
+}
+
+// validateCategory checks name uniqueness and the parent, which must be
+// active, for a category being created (id 0) or updated
+func validateCategory(ctx context.Context, db *sql.DB, id int, request CategoryRequest) error {
+	var exists bool
+	err := db.QueryRowContext(
+		ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE LOWER(name) = LOWER($1) AND id <> $2)",
+		request.Name, id,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check category name: %v", err)
+	}
+	if exists {
+		return &conflictError{message: "A category with this name already exists"}
+	}
+
+	if request.ParentID == nil {
+		return nil
+	}
+
+	// Walk up from the parent to make sure the category isn't its own ancestor
+	parentID := *request.ParentID
+	for depth := 0; ; depth++ {
+		if id != 0 && parentID == id {
+			return &conflictError{message: "A category can't be its own ancestor"}
+		}
+		parent, err := queryCategory(ctx, db, parentID)
+		if errors.Is(err, errCategoryNotFound) {
+			return &conflictError{message: "Parent category does not exist"}
+		}
+		if err != nil {
+			return err
+		}
+		if depth == 0 && parent.Archived {
+			return &conflictError{message: "Parent category is archived"}
+		}
+		if parent.ParentID == nil || depth > 100 {
+			return nil
+		}
+		parentID = *parent.ParentID
+	}
+}
+
+// deleteCategory deletes a category, applying the cascade rules described on
+// DeleteCategory in a single transaction
+func deleteCategory(ctx context.Context, db *sql.DB, category *Category, reassignTo int) error {
+	var products, warehouseRows int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products WHERE category_id = $1", category.ID).Scan(&products); err != nil {
+		return fmt.Errorf("failed to count products: %v", err)
+	}
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sales_totals_by_category_dw WHERE category_id = $1", category.ID).Scan(&warehouseRows); err != nil {
+		return fmt.Errorf("failed to count warehouse rows: %v", err)
+	}
+
+	if (products > 0 || warehouseRows > 0) && reassignTo == 0 {
+		return &conflictError{message: fmt.Sprintf(
+			"Category is used by %d products and %d warehouse rows. Use reassign_to to move them to another category",
+			products, warehouseRows,
+		)}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if reassignTo != 0 {
+		if _, err := tx.ExecContext(ctx, "UPDATE products SET category_id = $1 WHERE category_id = $2", reassignTo, category.ID); err != nil {
+			return fmt.Errorf("failed to reassign products: %v", err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE sales_totals_by_category_dw SET category_id = $1 WHERE category_id = $2", reassignTo, category.ID); err != nil {
+			return fmt.Errorf("failed to reassign warehouse rows: %v", err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE categories SET parent_id = $1 WHERE parent_id = $2", category.ParentID, category.ID); err != nil {
+		return fmt.Errorf("failed to re-parent child categories: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", category.ID); err != nil {
+		return fmt.Errorf("failed to delete category: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+func queryCategories(ctx context.Context, db *sql.DB) ([]Category, error) {
+	return scanCategories(db.QueryContext(ctx, "SELECT "+categoryColumns+" FROM categories ORDER BY name"))
+}
+
+// scanCategories scans the rows of a categories query, taking its error so
+// the query can be passed straight in
+func scanCategories(rows *sql.Rows, err error) ([]Category, error) {
+	if err != nil {
+		return nil, fmt.Errorf("failed to query categories: %v", err)
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return categories, nil
+}
+
+func queryCategory(ctx context.Context, db *sql.DB, id int) (*Category, error) {
+	return scanCategory(db.QueryRowContext(ctx, "SELECT "+categoryColumns+" FROM categories WHERE id = $1", id))
+}
+
+func scanCategory(row rowScanner) (*Category, error) {
+	var (
+		category Category
+		status   int
+	)
+	err := row.Scan(&category.ID, &category.Name, &category.ParentID, &status)
+	if err == sql.ErrNoRows {
+		return nil, errCategoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan category: %v", err)
+	}
+
+	category.Archived = status == categoryStatusArchived
+	return &category, nil
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// GenerateCategoryForecast handles the API request for forecasting a category
+// @Summary Forecast a category from its sales history
+// @Description Forecasts a category from its complete periods in the DW table, the same history the scheduled forecast-refresh job uses: 90 days, 52 weeks, or 2 years. The body is optional and takes the same settings as POST /sales/forecast except timeSeriesData and categoryId; timePeriod must be day, week, or month. The same forecast is made by POST /sales/forecast with categoryId and no timeSeriesData. Use format=xlsx for an Excel workbook.
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Produce application/x-protobuf
+// @Produce application/msgpack
+// @Produce text/event-stream
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param categoryId path int true "Category ID"
+// @Param request body ForecastRequest false "Forecast settings"
+// @Param schema query int false "Response schema version: 1 (deprecated) or 2 (default)"
+// @Param X-Schema-Version header int false "Response schema version, when schema isn't set"
+// @Param stream query bool false "Stream the forecast as Server-Sent Events"
+// @Param format query string false "Response format: json or xlsx (defaults to json, or the Accept header)"
+// @Success 200 {object} ForecastResponse "Forecast of the category"
+// @Failure 400 {object} httperror.Envelope "Invalid request, or no history for the category"
+// @Failure 404 {object} httperror.Envelope "Category not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error, or the LLM forecast failed and FORECAST_FALLBACK isn't statistical"
+// @Failure 504 {object} ForecastResponse "Deadline exceeded - an error, or the statistical fallback forecast when FORECAST_FALLBACK is statistical"
+// @Security BearerAuth
+// @Router /sales/forecast/category/{categoryId} [post]
+func GenerateCategoryForecast(c echo.Context) error {
+	categoryID, err := strconv.Atoi(c.Param("categoryId"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid category id")
+	}
+
+	var request ForecastRequest
+	if err := c.Bind(&request); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid request format")
+	}
+	request.CategoryID = &categoryID
+	request.TimeSeriesData = nil
+
+	return generateSalesForecast(c, request)
+}
+
+// loadCategoryHistory returns the complete day, week, or month periods of a
+// category's DW history before now, as far back as the refresh job looks
+func loadCategoryHistory(ctx context.Context, categoryID int, timePeriod string, now time.Time) ([]TimeSeriesPoint, error) {
+	switch timePeriod {
+	case "day", "week", "month":
+	default:
+		return nil, &invalidError{message: "timePeriod must be day, week, or month to forecast a category's history"}
+	}
+
+	db, err := getDB()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := queryCategory(ctx, db, categoryID); err != nil {
+		return nil, err
+	}
+
+	current := periodStart(now.UTC(), timePeriod)
+	history, err := queryPeriodTotals(ctx, db, categoryID, timePeriod, refreshHistoryStart(current, timePeriod), current)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, &invalidError{message: "No sales history for the category"}
+	}
+	return history, nil
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// csvMIMEType is the media type of CSV responses
+const csvMIMEType = "text/csv"
+
+// csvFlushRows is the number of rows written between flushes of a CSV
+// response
+const csvFlushRows = 1000
+
+// wantsCSV reports whether the request asked for CSV with format=csv, or,
+// without a format, with an Accept header preferring text/csv over the other
+// negotiated types. The response then varies on Accept.
+func wantsCSV(c echo.Context) bool {
+	switch c.QueryParam("format") {
+	case "csv":
+		return true
+	case "":
+		varyOnAccept(c)
+		offers := append([]string{}, negotiatedTypes...)
+		return preferredMediaType(c.Request().Header.Get(echo.HeaderAccept), append(offers, csvMIMEType)) == csvMIMEType
+	}
+	return false
+}
+
+// varyOnAccept adds Accept to the Vary header unless it's already listed
+func varyOnAccept(c echo.Context) {
+	header := c.Response().Header()
+	for _, value := range header.Values(echo.HeaderVary) {
+		for _, name := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(name), echo.HeaderAccept) {
+				return
+			}
+		}
+	}
+	header.Add(echo.HeaderVary, echo.HeaderAccept)
+}
+
+// writeCSV streams rows as a CSV attachment named filename.csv, flushing
+// every csvFlushRows rows. record returns the fields of row i in header
+// order.
+func writeCSV(c echo.Context, filename string, header []string, rows int, record func(i int) []string) error {
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, csvMIMEType+"; charset=utf-8")
+	response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+	response.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(response)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for i := 0; i < rows; i++ {
+		if err := writer.Write(record(i)); err != nil {
+			return fmt.Errorf("failed to write csv row: %v", err)
+		}
+		if (i+1)%csvFlushRows == 0 {
+			writer.Flush()
+			response.Flush()
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvAmount formats an amount with two decimals
+func csvAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// csvOptionalAmount formats an optional amount, empty when it's nil
+func csvOptionalAmount(amount *float64) string {
+	if amount == nil {
+		return ""
+	}
+	return csvAmount(*amount)
+}
+
+// writeCategoryReportCSV streams the sales report by category as one row
+// per period and category
+func writeCategoryReportCSV(c echo.Context, report map[string][]CategoryTotal, startDate, endDate string) error {
+	type row struct {
+		period string
+		total  CategoryTotal
+	}
+	var rows []row
+	for _, period := range sortedPeriods(report) {
+		for _, total := range report[period] {
+			rows = append(rows, row{period, total})
+		}
+	}
+
+	filename := fmt.Sprintf("sales-report-category-%s-%s", startDate, endDate)
+	return writeCSV(c, filename, []string{"period", "category_name", "total_amount", "gross_sales", "discounts", "refunds", "units_sold", "transactions", "average_order_value"}, len(rows), func(i int) []string {
+		total := rows[i].total
+		return []string{
+			rows[i].period, total.CategoryName, csvAmount(total.TotalAmount), csvAmount(total.GrossSales), csvAmount(total.Discounts), csvAmount(total.Refunds),
+			strconv.Itoa(total.UnitsSold), strconv.Itoa(total.Transactions), csvAmount(total.AverageOrderValue),
+		}
+	})
+}
+
+// writeGroupedReportCSV streams the grouped sales report as one row per
+// period and combination of dimension values, with a column per dimension
+func writeGroupedReportCSV(c echo.Context, report groupedReport, dimensions []reportDimension, startDate, endDate string) error {
+	type row struct {
+		period string
+		total  GroupedTotal
+	}
+	var rows []row
+	for _, period := range sortedPeriods(report) {
+		for _, total := range report[period] {
+			rows = append(rows, row{period, total})
+		}
+	}
+
+	header := []string{"period"}
+	for _, dimension := range dimensions {
+		header = append(header, dimension.name)
+	}
+	header = append(header, "total_amount")
+
+	filename := fmt.Sprintf("sales-report-category-%s-%s", startDate, endDate)
+	return writeCSV(c, filename, header, len(rows), func(i int) []string {
+		fields := []string{rows[i].period}
+		for _, dimension := range dimensions {
+			fields = append(fields, dimension.get(rows[i].total))
+		}
+		return append(fields, csvAmount(rows[i].total.TotalAmount))
+	})
+}
+
+// writeCustomerTypeReportCSV streams the new vs returning customer report as
+// one row per period and category
+func writeCustomerTypeReportCSV(c echo.Context, report map[string][]CustomerTypeTotal, startDate, endDate string) error {
+	type row struct {
+		period string
+		total  CustomerTypeTotal
+	}
+	var rows []row
+	for _, period := range sortedPeriods(report) {
+		for _, total := range report[period] {
+			rows = append(rows, row{period, total})
+		}
+	}
+
+	header := []string{"period", "category_name", "new_amount", "returning_amount", "new_customers", "returning_customers"}
+	filename := fmt.Sprintf("sales-report-customers-%s-%s", startDate, endDate)
+	return writeCSV(c, filename, header, len(rows), func(i int) []string {
+		total := rows[i].total
+		return []string{
+			rows[i].period,
+			total.CategoryName,
+			csvAmount(total.NewAmount),
+			csvAmount(total.ReturningAmount),
+			strconv.Itoa(total.NewCustomers),
+			strconv.Itoa(total.ReturningCustomers),
+		}
+	})
+}
+
+// writeComparisonCSV streams a period comparison as one row per category,
+// followed by a Total row
+func writeComparisonCSV(c echo.Context, filename string, comparison PeriodComparison) error {
+	header := []string{"category_name", "total_a", "total_b", "delta", "percent_change"}
+	fields := func(name string, totals ComparisonTotals) []string {
+		return []string{
+			name,
+			csvAmount(totals.TotalA),
+			csvAmount(totals.TotalB),
+			csvAmount(totals.Delta),
+			csvOptionalAmount(totals.PercentChange),
+		}
+	}
+
+	categories := comparison.Categories
+	return writeCSV(c, filename, header, len(categories)+1, func(i int) []string {
+		if i == len(categories) {
+			return fields("Total", comparison.Total)
+		}
+		return fields(categories[i].CategoryName, categories[i].ComparisonTotals)
+	})
+}
+
+// writeTopSellersCSV streams the top sellers report, highest total first
+func writeTopSellersCSV(c echo.Context, response TopSellersResponse) error {
+	filename := fmt.Sprintf("sales-report-top-%ss-%s-%s", response.Dimension, response.StartDate, response.EndDate)
+	header := []string{"rank", "id", "name", "total_amount", "share_percent"}
+	return writeCSV(c, filename, header, len(response.Sellers), func(i int) []string {
+		seller := response.Sellers[i]
+		return []string{
+			strconv.Itoa(seller.Rank),
+			strconv.Itoa(seller.ID),
+			seller.Name,
+			csvAmount(seller.TotalAmount),
+			csvOptionalAmount(seller.SharePercent),
+		}
+	})
+}
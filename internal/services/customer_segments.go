@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/bokor/craft-demo/internal/batch"
+	"github.com/labstack/echo/v4"
+)
+
+// SegmentSummary represents the customer count and revenue of a segment
+type SegmentSummary struct {
+	Segment   string  `json:"segment"`
+	Customers int     `json:"customers"`
+	Monetary  float64 `json:"monetary"`
+}
+
+// CustomerSegmentsResponse represents the response for the customer segments endpoint
+type CustomerSegmentsResponse struct {
+	Summary   []SegmentSummary        `json:"summary"`
+	Customers []batch.CustomerSegment `json:"customers"`
+}
+
+// GetCustomerSegments handles the API request for RFM customer segments
+// @Summary Get RFM customer segments
+// @Description Returns the recency/frequency/monetary scores and segment of each customer, as computed by the customer segments batch job, plus a per-segment summary
+// @Tags customers
+// @Produce json
+// @Param segment query string false "Only customers in this segment (e.g. Champions, At Risk)"
+// @Success 200 {object} CustomerSegmentsResponse "Customer segments"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /customers/segments [get]
+func GetCustomerSegments(c echo.Context) error {
+	ctx := c.Request().Context()
+	segment := c.QueryParam("segment")
+
+	return withDB(c, func(db *sql.DB) error {
+		customers, err := queryCustomerSegments(ctx, db, segment)
+		if err != nil {
+			return err
+		}
+
+		// Summarize in the order segments first appear (highest monetary first)
+		summary := []SegmentSummary{}
+		index := map[string]int{}
+		for _, customer := range customers {
+			i, ok := index[customer.Segment]
+			if !ok {
+				i = len(summary)
+				index[customer.Segment] = i
+				summary = append(summary, SegmentSummary{Segment: customer.Segment})
+			}
+			summary[i].Customers++
+			summary[i].Monetary += customer.Monetary
+		}
+
+		return c.JSON(http.StatusOK, CustomerSegmentsResponse{
+			Summary:   summary,
+			Customers: customers,
+		})
+	})
+}
+
+func queryCustomerSegments(ctx context.Context, db *sql.DB, segment string) ([]batch.CustomerSegment, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT customer_id, recency_days, frequency, monetary, recency_score, frequency_score, monetary_score, segment
+		FROM customer_segments
+		WHERE $1 = '' OR segment = $1
+		ORDER BY monetary DESC, customer_id
+	`, segment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer segments: %v", err)
+	}
+	defer rows.Close()
+
+	customers := []batch.CustomerSegment{}
+	for rows.Next() {
+		var customer batch.CustomerSegment
+		if err := rows.Scan(
+			&customer.CustomerID, &customer.RecencyDays, &customer.Frequency, &customer.Monetary,
+			&customer.RecencyScore, &customer.FrequencyScore, &customer.MonetaryScore, &customer.Segment,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		customers = append(customers, customer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return customers, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/bokor/craft-demo/internal/repository"
+)
+
+// sharedDB is the connection pool of the handlers and jobs, set with UseDB
+var sharedDB atomic.Pointer[sql.DB]
+
+// UseDB makes the handlers and jobs use db, the pool opened at startup with
+// database.OpenPool. The caller closes it once the server has shut down.
+func UseDB(db *sql.DB) {
+	sharedDB.Store(db)
+}
+
+// getDB returns the shared connection pool, which callers must not close
+func getDB() (*sql.DB, error) {
+	db := sharedDB.Load()
+	if db == nil {
+		return nil, errors.New("database pool not initialized")
+	}
+	return db, nil
+}
+
+// salesReportRepository and salesTotalsRepository return the repositories the
+// handlers use on db. Tests can replace them with mocks.
+var (
+	salesReportRepository = func(db *sql.DB) repository.SalesReportRepository { return repository.NewPostgres(db) }
+	salesTotalsRepository = func(db *sql.DB) repository.SalesTotalsRepository { return repository.NewPostgres(db) }
+)
+
+// writeDBPoolMetrics writes the shared pool's connection counts in the
+// Prometheus text format, or nothing before the pool is set
+func writeDBPoolMetrics(w io.Writer) error {
+	db := sharedDB.Load()
+	if db == nil {
+		return nil
+	}
+	stats := db.Stats()
+
+	var buf bytes.Buffer
+	for _, metric := range []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"craft_db_max_open_connections", "gauge", "Maximum open connections of the database pool", float64(stats.MaxOpenConnections)},
+		{"craft_db_open_connections", "gauge", "Open connections of the database pool", float64(stats.OpenConnections)},
+		{"craft_db_in_use_connections", "gauge", "Connections of the database pool in use", float64(stats.InUse)},
+		{"craft_db_idle_connections", "gauge", "Idle connections of the database pool", float64(stats.Idle)},
+		{"craft_db_wait_count_total", "counter", "Connections waited for because the pool was full", float64(stats.WaitCount)},
+		{"craft_db_wait_seconds_total", "counter", "Time spent waiting for a connection from the pool", stats.WaitDuration.Seconds()},
+	} {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", metric.name, metric.help, metric.name, metric.kind, metric.name, metric.value)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
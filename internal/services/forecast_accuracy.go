@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// Forecast accuracy listing limits
+const (
+	defaultAccuracyLimit = 100
+	maxAccuracyLimit     = 1000
+	// The method summary covers periods that ended within the window
+	defaultAccuracyWindowDays = 90
+	maxAccuracyWindowDays     = 3650
+)
+
+// ForecastAccuracy is the accuracy of one tracked forecast over the periods
+// that have been matched against actuals
+type ForecastAccuracy struct {
+	ForecastID int64 `json:"forecast_id"`
+	// CategoryID and CategoryName are null for forecasts of total revenue
+	CategoryID     *int      `json:"category_id"`
+	CategoryName   *string   `json:"category_name"`
+	TimePeriod     string    `json:"time_period"`
+	Source         string    `json:"source"`
+	Method         string    `json:"method,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	Periods        int       `json:"periods"`
+	MatchedPeriods int       `json:"matched_periods"`
+	// MAPE is the mean absolute percentage error over matched periods with
+	// non-zero actuals, and null when there are none
+	MAPE *float64 `json:"mape"`
+	// Bias is the total forecast error as a percentage of total actuals:
+	// positive when the forecast ran high
+	Bias *float64 `json:"bias"`
+}
+
+// CategoryAccuracy is the accuracy of all tracked forecasts for a category
+type CategoryAccuracy struct {
+	CategoryID     *int     `json:"category_id"`
+	CategoryName   *string  `json:"category_name"`
+	Forecasts      int      `json:"forecasts"`
+	MatchedPeriods int      `json:"matched_periods"`
+	MAPE           *float64 `json:"mape"`
+	Bias           *float64 `json:"bias"`
+}
+
+// MethodAccuracy is the rolling accuracy of a forecaster, the LLM or a
+// statistical method, over the periods that ended within the window
+type MethodAccuracy struct {
+	Source string `json:"source"`
+	// Method is the statistical method of fallback and deterministic
+	// forecasts
+	Method         string   `json:"method,omitempty"`
+	Forecasts      int      `json:"forecasts"`
+	MatchedPeriods int      `json:"matched_periods"`
+	MAPE           *float64 `json:"mape"`
+	Bias           *float64 `json:"bias"`
+}
+
+// ForecastAccuracyResponse represents the response for the forecast accuracy endpoint
+type ForecastAccuracyResponse struct {
+	Forecasts  []ForecastAccuracy `json:"forecasts"`
+	Categories []CategoryAccuracy `json:"categories"`
+	// Methods ranks the forecasters by MAPE over the last WindowDays days
+	Methods    []MethodAccuracy `json:"methods"`
+	WindowDays int              `json:"window_days"`
+}
+
+// GetForecastAccuracy handles the API request for forecast accuracy
+// @Summary Get forecast accuracy
+// @Description Returns the MAPE and bias of tracked forecasts (sent with track set) and scheduled forecasts against actual revenue from the DW table, newest first, plus a summary per category and a rolling summary per forecaster (the LLM or a statistical method) ranked by MAPE over the periods that ended in the last window_days. Actuals are filled in by the forecast-accuracy job once each forecast period has ended. Also served at /sales/forecast/accuracy.
+// @Tags sales
+// @Produce json
+// @Param category_id query int false "Only forecasts for this category"
+// @Param time_period query string false "Only forecasts of this time period (day, week, iso_week, month, fiscal)"
+// @Param source query string false "Only forecasts from this source (llm, fallback, deterministic)"
+// @Param limit query int false "Maximum number of forecasts (default 100, max 1000)"
+// @Param window_days query int false "Days of ended periods the method summary covers (default 90, max 3650)"
+// @Success 200 {object} ForecastAccuracyResponse "Forecast accuracy"
+// @Failure 400 {object} httperror.Envelope "Invalid parameter"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /forecasts/accuracy [get]
+// @Router /sales/forecast/accuracy [get]
+func GetForecastAccuracy(c echo.Context) error {
+	ctx := c.Request().Context()
+	var categoryID *int
+	if value := c.QueryParam("category_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid category_id")
+		}
+		categoryID = &id
+	}
+
+	timePeriod := c.QueryParam("time_period")
+	switch timePeriod {
+	case "", "day", "week", "iso_week", "month", "fiscal":
+	default:
+		return httperror.JSON(c, http.StatusBadRequest, "time_period must be day, week, iso_week, month, or fiscal")
+	}
+
+	limit := defaultAccuracyLimit
+	if value := c.QueryParam("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxAccuracyLimit {
+			return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAccuracyLimit))
+		}
+		limit = parsed
+	}
+
+	windowDays := defaultAccuracyWindowDays
+	if value := c.QueryParam("window_days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxAccuracyWindowDays {
+			return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("window_days must be between 1 and %d", maxAccuracyWindowDays))
+		}
+		windowDays = parsed
+	}
+
+	filter := accuracyFilter{categoryID: categoryID, timePeriod: timePeriod, source: c.QueryParam("source")}
+	return withDB(c, func(db *sql.DB) error {
+		forecasts, err := queryForecastAccuracy(ctx, db, filter, limit)
+		if err != nil {
+			return err
+		}
+		categories, err := queryCategoryAccuracy(ctx, db, filter)
+		if err != nil {
+			return err
+		}
+		methods, err := queryMethodAccuracy(ctx, db, filter, windowDays)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, ForecastAccuracyResponse{
+			Forecasts:  forecasts,
+			Categories: categories,
+			Methods:    methods,
+			WindowDays: windowDays,
+		})
+	})
+}
+
+// accuracyFilter narrows the tracked forecasts
+type accuracyFilter struct {
+	categoryID *int
+	timePeriod string
+	source     string
+}
+
+// accuracyWhere matches the filter with $1 to $3
+const accuracyWhere = `
+	($1::INTEGER IS NULL OR f.category_id = $1)
+	AND ($2 = '' OR f.time_period = $2)
+	AND ($3 = '' OR f.source = $3)
+`
+
+// accuracyMetrics computes the matched period count, MAPE and bias in percent
+const accuracyMetrics = `
+	COUNT(fp.actual),
+	ROUND(AVG(ABS(fp.predicted - fp.actual) / ABS(fp.actual)) FILTER (WHERE fp.actual <> 0) * 100, 2),
+	ROUND(SUM(fp.predicted - fp.actual) FILTER (WHERE fp.actual IS NOT NULL)
+		/ NULLIF(SUM(ABS(fp.actual)) FILTER (WHERE fp.actual IS NOT NULL), 0) * 100, 2)
+`
+
+func queryForecastAccuracy(ctx context.Context, db *sql.DB, filter accuracyFilter, limit int) ([]ForecastAccuracy, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT f.id, f.category_id, c.name, f.time_period, f.source, COALESCE(f.method, ''), f.created_at,
+			COUNT(*), `+accuracyMetrics+`
+		FROM forecasts f
+		JOIN forecast_points fp ON fp.forecast_id = f.id
+		LEFT JOIN categories c ON c.id = f.category_id
+		WHERE `+accuracyWhere+`
+		GROUP BY f.id, c.name
+		ORDER BY f.created_at DESC, f.id DESC
+		LIMIT $4
+	`, filter.categoryID, filter.timePeriod, filter.source, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query forecast accuracy: %v", err)
+	}
+	defer rows.Close()
+
+	forecasts := []ForecastAccuracy{}
+	for rows.Next() {
+		var accuracy ForecastAccuracy
+		if err := rows.Scan(
+			&accuracy.ForecastID, &accuracy.CategoryID, &accuracy.CategoryName, &accuracy.TimePeriod,
+			&accuracy.Source, &accuracy.Method, &accuracy.CreatedAt,
+			&accuracy.Periods, &accuracy.MatchedPeriods, &accuracy.MAPE, &accuracy.Bias,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		forecasts = append(forecasts, accuracy)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return forecasts, nil
+}
+
+func queryCategoryAccuracy(ctx context.Context, db *sql.DB, filter accuracyFilter) ([]CategoryAccuracy, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT f.category_id, c.name, COUNT(DISTINCT f.id), `+accuracyMetrics+`
+		FROM forecasts f
+		JOIN forecast_points fp ON fp.forecast_id = f.id
+		LEFT JOIN categories c ON c.id = f.category_id
+		WHERE `+accuracyWhere+`
+		GROUP BY f.category_id, c.name
+		ORDER BY c.name NULLS FIRST
+	`, filter.categoryID, filter.timePeriod, filter.source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category accuracy: %v", err)
+	}
+	defer rows.Close()
+
+	categories := []CategoryAccuracy{}
+	for rows.Next() {
+		var accuracy CategoryAccuracy
+		if err := rows.Scan(
+			&accuracy.CategoryID, &accuracy.CategoryName, &accuracy.Forecasts,
+			&accuracy.MatchedPeriods, &accuracy.MAPE, &accuracy.Bias,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		categories = append(categories, accuracy)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return categories, nil
+}
+
+// queryMethodAccuracy summarizes the matched periods that ended within the
+// last windowDays by source and method, most accurate first
+func queryMethodAccuracy(ctx context.Context, db *sql.DB, filter accuracyFilter, windowDays int) ([]MethodAccuracy, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT f.source, COALESCE(f.method, ''), COUNT(DISTINCT f.id), `+accuracyMetrics+` AS metrics
+		FROM forecasts f
+		JOIN forecast_points fp ON fp.forecast_id = f.id
+		WHERE `+accuracyWhere+`
+			AND fp.actual IS NOT NULL
+			AND fp.period_end > NOW() - MAKE_INTERVAL(days => $4)
+		GROUP BY f.source, f.method
+		ORDER BY 5 NULLS LAST, f.source, f.method
+	`, filter.categoryID, filter.timePeriod, filter.source, windowDays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query method accuracy: %v", err)
+	}
+	defer rows.Close()
+
+	methods := []MethodAccuracy{}
+	for rows.Next() {
+		var accuracy MethodAccuracy
+		if err := rows.Scan(
+			&accuracy.Source, &accuracy.Method, &accuracy.Forecasts,
+			&accuracy.MatchedPeriods, &accuracy.MAPE, &accuracy.Bias,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		methods = append(methods, accuracy)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return methods, nil
+}
+
+// saveForecast stores a forecast so its periods can be matched against
+// actuals later, and returns its ID
+func saveForecast(ctx context.Context, categoryID *int, response ForecastResponse) (int64, error) {
+	db, err := getDB()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	id, err := insertForecast(ctx, tx, categoryID, response, false)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit forecast: %v", err)
+	}
+	return id, nil
+}
+
+// insertForecast inserts a forecast and its periods, with their bands, and
+// returns its ID.
+// Scheduled forecasts are the precomputed ones the refresh job makes.
+// Periods that can't be parsed are skipped.
+func insertForecast(ctx context.Context, tx *sql.Tx, categoryID *int, response ForecastResponse, scheduled bool) (int64, error) {
+	var id int64
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO forecasts (category_id, time_period, source, method, scheduled)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+		RETURNING id
+	`, categoryID, response.TimePeriod, response.Meta.Source, response.Meta.Method, scheduled).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save forecast: %v", err)
+	}
+
+	for _, point := range response.Forecast {
+		start, err := forecast.ParsePeriod(point.Period)
+		if err != nil {
+			continue
+		}
+		if response.TimePeriod == "month" {
+			start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+		lower80, upper80 := point.interval(80)
+		lower95, upper95 := point.interval(95)
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO forecast_points (forecast_id, period_start, period_end, predicted, lower_80, upper_80, lower_95, upper_95)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (forecast_id, period_start) DO NOTHING
+		`, id, start, forecast.Step(start, response.TimePeriod, 1), point.Total, lower80, upper80, lower95, upper95); err != nil {
+			return 0, fmt.Errorf("failed to save forecast period: %v", err)
+		}
+	}
+	return id, nil
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+
+	"github.com/bokor/craft-demo/internal/deadline"
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// Backtest limits
+const (
+	defaultBacktestFolds = 3
+	maxBacktestFolds     = 12
+	// minBacktestHistory is the fewest periods a fold trains on
+	minBacktestHistory = 3
+)
+
+// backtestLLM is the backtest method name for ChatGPT forecasts
+const backtestLLM = "llm"
+
+// defaultBacktestMethods compares ChatGPT with the default fallback
+var defaultBacktestMethods = []string{backtestLLM, string(forecast.MethodMovingAverage)}
+
+// BacktestRequest represents the request structure for forecast backtesting.
+// The forecast request fields gapFill, the horizons, and the method
+// parameters apply as in POST /sales/forecast; method, deterministic,
+// tradingDays, outliers, and track are ignored.
+type BacktestRequest struct {
+	ForecastRequest
+	// Methods are the forecasters compared: llm or any statistical method,
+	// defaulting to llm and moving_average
+	Methods []string `json:"methods,omitempty"`
+	// Folds is the number of holdout windows, each starting a period later
+	// (default 3, max 12)
+	Folds int `json:"folds,omitempty"`
+}
+
+// BacktestFold is one train/holdout split
+type BacktestFold struct {
+	TrainPeriods int    `json:"trainPeriods"`
+	TrainEnd     string `json:"trainEnd"`
+	HoldoutStart string `json:"holdoutStart"`
+	HoldoutEnd   string `json:"holdoutEnd"`
+}
+
+// BacktestMetrics are the errors of forecasts against held-out actuals
+type BacktestMetrics struct {
+	MAE  float64 `json:"mae"`
+	RMSE float64 `json:"rmse"`
+	// MAPE is the mean absolute percentage error over non-zero actuals,
+	// null when every actual is zero
+	MAPE *float64 `json:"mape"`
+	// Count is the number of forecast points compared
+	Count int `json:"count"`
+}
+
+// HorizonMetrics are the errors of forecasts the given number of periods ahead
+type HorizonMetrics struct {
+	Horizon int `json:"horizon"`
+	BacktestMetrics
+}
+
+// MethodBacktest is the backtest result of one forecaster
+type MethodBacktest struct {
+	Method string `json:"method"`
+	// Overall is null when every fold failed
+	Overall  *BacktestMetrics `json:"overall"`
+	Horizons []HorizonMetrics `json:"horizons"`
+	// FailedFolds counts folds the forecaster returned no forecast for,
+	// with the last error
+	FailedFolds int    `json:"failedFolds"`
+	Error       string `json:"error,omitempty"`
+}
+
+// BacktestResponse represents the response from the backtest endpoint
+type BacktestResponse struct {
+	TimePeriod string           `json:"timePeriod"`
+	Horizon    int              `json:"horizon"`
+	Folds      []BacktestFold   `json:"folds"`
+	Methods    []MethodBacktest `json:"methods"`
+	// Best is the method with the lowest overall RMSE
+	Best string `json:"best,omitempty"`
+}
+
+// BacktestSalesForecast handles the API request for backtesting forecasters
+// @Summary Backtest forecasters against held-out data
+// @Description Splits the time series into expanding train windows, each followed by a holdout window of the forecast horizon, forecasts every holdout with each method (llm for ChatGPT or a statistical method such as moving_average or arima), and returns the MAE, RMSE, and MAPE per horizon and overall. Statistical methods use a fixed seed. LLM folds that fail are counted in failedFolds.
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param request body BacktestRequest true "Time series, methods, and folds"
+// @Success 200 {object} BacktestResponse "Errors per method and horizon"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid data or too little history"
+// @Security BearerAuth
+// @Router /sales/forecast/backtest [post]
+func BacktestSalesForecast(c echo.Context) error {
+	var request BacktestRequest
+	if err := c.Bind(&request); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid request format")
+	}
+	if len(request.TimeSeriesData) == 0 {
+		return httperror.JSON(c, http.StatusBadRequest, "No time series data provided")
+	}
+	if err := request.validateHorizons(); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if err := request.validateLLM(); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if len(request.Scenario) > 0 {
+		// Holdouts are actual sales, which a planned scenario didn't shape
+		return httperror.JSON(c, http.StatusBadRequest, "scenario isn't supported by backtesting")
+	}
+	timePeriod := request.TimePeriod
+	if timePeriod == "" {
+		timePeriod = "month"
+	}
+
+	folds := request.Folds
+	if folds == 0 {
+		folds = defaultBacktestFolds
+	}
+	if folds < 1 || folds > maxBacktestFolds {
+		return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("folds must be between 1 and %d", maxBacktestFolds))
+	}
+
+	methods := request.Methods
+	if len(methods) == 0 {
+		methods = defaultBacktestMethods
+	}
+	strategies := make(map[string]forecast.Strategy, len(methods))
+	for _, name := range methods {
+		name = strings.ToLower(name)
+		if name == backtestLLM {
+			continue
+		}
+		withMethod := request.ForecastRequest
+		withMethod.Method = name
+		strategy, err := forecast.NewStrategy(forecast.Options{
+			Method:       forecast.Method(name),
+			Alpha:        request.Alpha,
+			Beta:         request.Beta,
+			Gamma:        request.Gamma,
+			Window:       request.Window,
+			SeasonLength: request.SeasonLength,
+			Holidays:     forecastHolidays(withMethod, timePeriod),
+			AROrder:      request.AROrder,
+			Differencing: request.Differencing,
+		}, timePeriod)
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, err.Error())
+		}
+		strategies[name] = strategy
+	}
+
+	gapFill, err := forecast.ParseGapFill(request.GapFill)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	series, _ := fillGaps(request.TimeSeriesData, timePeriod, gapFill)
+
+	horizon := request.forecastPeriods(timePeriod)
+	firstEnd := len(series) - horizon - (folds - 1)
+	if firstEnd < minBacktestHistory {
+		return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf(
+			"%d folds with a horizon of %d need at least %d periods", folds, horizon, minBacktestHistory+horizon+folds-1,
+		))
+	}
+
+	response := BacktestResponse{TimePeriod: timePeriod, Horizon: horizon}
+	for end := firstEnd; end < firstEnd+folds; end++ {
+		response.Folds = append(response.Folds, BacktestFold{
+			TrainPeriods: end,
+			TrainEnd:     series[end-1].Period,
+			HoldoutStart: series[end].Period,
+			HoldoutEnd:   series[end+horizon-1].Period,
+		})
+	}
+
+	for _, name := range methods {
+		name = strings.ToLower(name)
+		result := MethodBacktest{Method: name}
+		errors := make([][]float64, horizon)
+		actuals := make([][]float64, horizon)
+
+		for end := firstEnd; end < firstEnd+folds; end++ {
+			train := request.ForecastRequest
+			train.TimeSeriesData = series[:end]
+
+			var (
+				points []TimeSeriesPoint
+				err    error
+			)
+			if name == backtestLLM {
+				points, err = backtestLLMForecast(c, train, timePeriod)
+			} else {
+				forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(deterministicSeed)), forecast.FixedClock(deterministicEpoch)).WithStrategy(strategies[name])
+				points = generateSimpleForecast(forecaster, train, timePeriod)
+			}
+			if err != nil {
+				result.FailedFolds++
+				result.Error = err.Error()
+				continue
+			}
+
+			// Points are matched to the holdout by position
+			for h := 0; h < horizon && h < len(points); h++ {
+				actual := series[end+h].Total
+				errors[h] = append(errors[h], points[h].Total-actual)
+				actuals[h] = append(actuals[h], actual)
+			}
+		}
+
+		var allErrors, allActuals []float64
+		for h := range errors {
+			if len(errors[h]) == 0 {
+				continue
+			}
+			result.Horizons = append(result.Horizons, HorizonMetrics{Horizon: h + 1, BacktestMetrics: backtestMetrics(errors[h], actuals[h])})
+			allErrors = append(allErrors, errors[h]...)
+			allActuals = append(allActuals, actuals[h]...)
+		}
+		if len(allErrors) > 0 {
+			overall := backtestMetrics(allErrors, allActuals)
+			result.Overall = &overall
+		}
+		if result.Horizons == nil {
+			result.Horizons = []HorizonMetrics{}
+		}
+		response.Methods = append(response.Methods, result)
+	}
+
+	best := math.Inf(1)
+	for _, result := range response.Methods {
+		if result.Overall != nil && result.Overall.RMSE < best {
+			best, response.Best = result.Overall.RMSE, result.Method
+		}
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+// backtestLLMForecast asks the request's LLM provider for one fold's
+// forecast under the usual deadlines, repaired like the forecast endpoint's
+func backtestLLMForecast(c echo.Context, request ForecastRequest, timePeriod string) ([]TimeSeriesPoint, error) {
+	if health := llmHealthFor(request.Provider); health.Status == llmStatusDown {
+		return nil, fmt.Errorf("LLM unavailable: %s", health.Error)
+	}
+	fallback, _, err := refreshFallback(request, timePeriod)
+	if err != nil {
+		return nil, err
+	}
+	timeouts := deadline.FromEnv()
+	ctx, cancel := timeouts.WithTotal(c.Request().Context())
+	defer cancel()
+
+	var meta ForecastMeta
+	points, _, err := generateForecastForPeriod(ctx, timeouts, request, timePeriod, fallback, &meta, nil)
+	return points, err
+}
+
+// backtestMetrics summarizes forecast errors (forecast - actual)
+func backtestMetrics(errors, actuals []float64) BacktestMetrics {
+	var absolute, squared, percentage float64
+	nonZero := 0
+	for i, e := range errors {
+		absolute += math.Abs(e)
+		squared += e * e
+		if actuals[i] != 0 {
+			percentage += math.Abs(e / actuals[i])
+			nonZero++
+		}
+	}
+
+	n := float64(len(errors))
+	metrics := BacktestMetrics{
+		MAE:   math.Round(absolute/n*100) / 100,
+		RMSE:  math.Round(math.Sqrt(squared/n)*100) / 100,
+		Count: len(errors),
+	}
+	if nonZero > 0 {
+		mape := math.Round(percentage/float64(nonZero)*10000) / 100
+		metrics.MAPE = &mape
+	}
+	return metrics
+}
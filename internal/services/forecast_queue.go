@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// Reasons a scheduled forecast is queued for regeneration
+const (
+	queueReasonAge     = "age"
+	queueReasonActuals = "actuals"
+)
+
+// queueRetryDelay is how long a failed regeneration waits per attempt so far
+const queueRetryDelay = 5 * time.Minute
+
+// ForecastQueueConfig controls when scheduled forecasts go stale and how fast
+// the queue regenerates them
+type ForecastQueueConfig struct {
+	// MaxAge is how old a scheduled forecast may get before it is stale
+	MaxAge time.Duration
+	// MaxDeviation is how far a matched actual may be from the forecast, as a
+	// fraction of the forecast, before the forecast is stale
+	MaxDeviation float64
+	// Batch is the most forecasts regenerated per worker run
+	Batch int
+	// LLMBudget is the most LLM forecasts the scheduled jobs may make per
+	// hour; 0 means no limit
+	LLMBudget int
+}
+
+// ForecastQueueConfigFromEnv reads the staleness and queue settings:
+//
+//	FORECAST_MAX_AGE              Go duration (default 36h)
+//	FORECAST_STALE_DEVIATION      fraction (default 0.25)
+//	FORECAST_QUEUE_BATCH          forecasts per worker run (default 5)
+//	FORECAST_LLM_BUDGET_PER_HOUR  LLM forecasts per hour, 0 for no limit (default 60)
+func ForecastQueueConfigFromEnv() (ForecastQueueConfig, error) {
+	config := ForecastQueueConfig{MaxAge: 36 * time.Hour, MaxDeviation: 0.25, Batch: 5, LLMBudget: 60}
+
+	if value := os.Getenv("FORECAST_MAX_AGE"); value != "" {
+		maxAge, err := time.ParseDuration(value)
+		if err != nil || maxAge <= 0 {
+			return config, fmt.Errorf("invalid FORECAST_MAX_AGE %q", value)
+		}
+		config.MaxAge = maxAge
+	}
+	if value := os.Getenv("FORECAST_STALE_DEVIATION"); value != "" {
+		deviation, err := strconv.ParseFloat(value, 64)
+		if err != nil || deviation <= 0 {
+			return config, fmt.Errorf("invalid FORECAST_STALE_DEVIATION %q", value)
+		}
+		config.MaxDeviation = deviation
+	}
+	for _, setting := range []struct {
+		env    string
+		target *int
+		min    int
+	}{{"FORECAST_QUEUE_BATCH", &config.Batch, 1}, {"FORECAST_LLM_BUDGET_PER_HOUR", &config.LLMBudget, 0}} {
+		if value := os.Getenv(setting.env); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < setting.min {
+				return config, fmt.Errorf("invalid %s %q", setting.env, value)
+			}
+			*setting.target = n
+		}
+	}
+	return config, nil
+}
+
+// EnqueueStaleForecasts queues the current scheduled forecasts that are older
+// than the maximum age, or have a matched actual further from the forecast
+// than the maximum deviation. Forecasts already queued keep their place.
+func EnqueueStaleForecasts(ctx context.Context, db *sql.DB, config ForecastQueueConfig, now time.Time) error {
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO forecast_refresh_queue (category_id, time_period, reason, enqueued_at, next_attempt_at)
+		SELECT f.category_id, f.time_period,
+			CASE WHEN f.created_at < $1 THEN $3 ELSE $4 END,
+			$5, $5
+		FROM forecasts f
+		WHERE f.scheduled AND f.superseded_at IS NULL AND f.category_id IS NOT NULL
+			AND (f.created_at < $1 OR EXISTS (
+				SELECT 1 FROM forecast_points fp
+				WHERE fp.forecast_id = f.id AND fp.actual IS NOT NULL
+					AND ABS(fp.actual - fp.predicted) > $2 * GREATEST(ABS(fp.predicted), 0.01)
+			))
+		ON CONFLICT (category_id, time_period) DO NOTHING
+	`, now.Add(-config.MaxAge), config.MaxDeviation, queueReasonAge, queueReasonActuals, now)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue stale forecasts: %v", err)
+	}
+
+	if queued, err := result.RowsAffected(); err == nil && queued > 0 {
+		log.Printf("Queued %d stale forecasts for regeneration", queued)
+	}
+	return nil
+}
+
+// ProcessForecastQueue regenerates up to the batch size of queued forecasts,
+// oldest first. While the LLM is up, it stops short of the hourly LLM budget
+// and leaves the rest queued for a later run. Failed regenerations are
+// retried with a growing delay.
+func ProcessForecastQueue(ctx context.Context, db *sql.DB, config ForecastQueueConfig, now time.Time) error {
+	limit := config.Batch
+	if config.LLMBudget > 0 && currentLLMHealth().Status != llmStatusDown {
+		var used int
+		if err := db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM forecasts
+			WHERE scheduled AND source = $1 AND created_at > $2
+		`, forecastSourceLLM, now.Add(-time.Hour)).Scan(&used); err != nil {
+			return fmt.Errorf("failed to count LLM forecasts: %v", err)
+		}
+		limit = min(limit, config.LLMBudget-used)
+		if limit <= 0 {
+			log.Printf("Forecast queue waiting: %d of %d LLM forecasts used this hour", used, config.LLMBudget)
+			return nil
+		}
+	}
+
+	type queued struct {
+		categoryID int
+		timePeriod string
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT category_id, time_period FROM forecast_refresh_queue
+		WHERE next_attempt_at <= $1
+		ORDER BY enqueued_at, category_id, time_period
+		LIMIT $2
+	`, now, limit)
+	if err != nil {
+		return fmt.Errorf("failed to query forecast queue: %v", err)
+	}
+	var batch []queued
+	for rows.Next() {
+		var item queued
+		if err := rows.Scan(&item.categoryID, &item.timePeriod); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan row: %v", err)
+		}
+		batch = append(batch, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	failed := 0
+	for _, item := range batch {
+		err := regenerateQueued(ctx, db, item.categoryID, item.timePeriod, now)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			continue
+		}
+
+		failed++
+		log.Printf("Failed to regenerate queued %s forecast for category %d: %v", item.timePeriod, item.categoryID, err)
+		if _, err := db.ExecContext(ctx, `
+			UPDATE forecast_refresh_queue
+			SET attempts = attempts + 1, last_error = $3,
+				next_attempt_at = $4::timestamp + (attempts + 1) * $5 * INTERVAL '1 second'
+			WHERE category_id = $1 AND time_period = $2
+		`, item.categoryID, item.timePeriod, err.Error(), now, queueRetryDelay.Seconds()); err != nil {
+			return fmt.Errorf("failed to reschedule queued forecast: %v", err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d queued forecasts failed", failed, len(batch))
+	}
+	return nil
+}
+
+// regenerateQueued refreshes a queued forecast and removes it from the queue.
+// A category deleted since it was queued is just removed.
+func regenerateQueued(ctx context.Context, db *sql.DB, categoryID int, timePeriod string, now time.Time) error {
+	category, err := queryCategory(ctx, db, categoryID)
+	if err != nil && err != errCategoryNotFound {
+		return err
+	}
+	if category != nil {
+		if err := refreshForecast(ctx, db, *category, timePeriod, now); err != nil {
+			return err
+		}
+	}
+	return dequeueForecast(ctx, db, categoryID, timePeriod)
+}
+
+// queueExecer is satisfied by both *sql.DB and *sql.Tx
+type queueExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// dequeueForecast removes a forecast from the refresh queue
+func dequeueForecast(ctx context.Context, db queueExecer, categoryID int, timePeriod string) error {
+	if _, err := db.ExecContext(
+		ctx, "DELETE FROM forecast_refresh_queue WHERE category_id = $1 AND time_period = $2",
+		categoryID, timePeriod,
+	); err != nil {
+		return fmt.Errorf("failed to dequeue forecast: %v", err)
+	}
+	return nil
+}
+
+// ForecastFreshness is the age and queue state of a category's scheduled
+// forecast for a time period
+type ForecastFreshness struct {
+	CategoryID   int        `json:"category_id"`
+	CategoryName string     `json:"category_name"`
+	TimePeriod   string     `json:"time_period"`
+	ForecastID   *int64     `json:"forecast_id"`
+	GeneratedAt  *time.Time `json:"generated_at"`
+	AgeSeconds   *int64     `json:"age_seconds"`
+	// Stale is true when the forecast is missing or older than FORECAST_MAX_AGE
+	Stale       bool       `json:"stale"`
+	Queued      bool       `json:"queued"`
+	QueueReason *string    `json:"queue_reason"`
+	QueuedAt    *time.Time `json:"queued_at"`
+	Attempts    int        `json:"attempts"`
+	LastError   *string    `json:"last_error"`
+}
+
+// GetForecastFreshness handles the API request for scheduled forecast ages
+// @Summary Get the freshness of precomputed forecasts
+// @Description Lists every category's precomputed forecast for each time period with its age, whether it is stale, and whether it is queued for regeneration (reason age or actuals).
+// @Tags sales
+// @Produce json
+// @Success 200 {array} ForecastFreshness "Forecast freshness per category and time period"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /forecasts/freshness [get]
+func GetForecastFreshness(c echo.Context) error {
+	ctx := c.Request().Context()
+	config, err := ForecastQueueConfigFromEnv()
+	if err != nil {
+		return httperror.JSON(c, http.StatusInternalServerError, err.Error())
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		freshness, err := queryForecastFreshness(ctx, db, config.MaxAge, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, freshness)
+	})
+}
+
+func queryForecastFreshness(ctx context.Context, db *sql.DB, maxAge time.Duration, now time.Time) ([]ForecastFreshness, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.id, c.name, p.time_period, f.id, f.created_at,
+			q.reason, q.enqueued_at, COALESCE(q.attempts, 0), q.last_error
+		FROM categories c
+		CROSS JOIN UNNEST($1::text[]) WITH ORDINALITY AS p(time_period, position)
+		LEFT JOIN forecasts f ON f.category_id = c.id AND f.time_period = p.time_period
+			AND f.scheduled AND f.superseded_at IS NULL
+		LEFT JOIN forecast_refresh_queue q ON q.category_id = c.id AND q.time_period = p.time_period
+		ORDER BY c.name, p.position
+	`, pq.Array(refreshPeriods))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query forecast freshness: %v", err)
+	}
+	defer rows.Close()
+
+	freshness := []ForecastFreshness{}
+	for rows.Next() {
+		var row ForecastFreshness
+		if err := rows.Scan(&row.CategoryID, &row.CategoryName, &row.TimePeriod, &row.ForecastID, &row.GeneratedAt,
+			&row.QueueReason, &row.QueuedAt, &row.Attempts, &row.LastError); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		row.Stale = true
+		if row.GeneratedAt != nil {
+			age := int64(now.Sub(*row.GeneratedAt).Seconds())
+			row.AgeSeconds = &age
+			row.Stale = now.Sub(*row.GeneratedAt) > maxAge
+		}
+		row.Queued = row.QueueReason != nil
+		freshness = append(freshness, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return freshness, nil
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/deadline"
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// refreshPeriods are the time periods the refresh job forecasts
+var refreshPeriods = []string{"day", "week", "month"}
+
+// refreshHistoryStart returns the start of the DW history a scheduled
+// forecast is made from, given the start of the current period
+func refreshHistoryStart(current time.Time, timePeriod string) time.Time {
+	switch timePeriod {
+	case "day":
+		return current.AddDate(0, 0, -90)
+	case "week":
+		return current.AddDate(0, 0, -52*7)
+	default:
+		return current.AddDate(-2, 0, 0)
+	}
+}
+
+// periodStart returns the start of the day, week (Monday), or month containing t
+func periodStart(t time.Time, timePeriod string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch timePeriod {
+	case "day":
+		return day
+	case "week":
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// RefreshForecasts makes fresh daily, weekly, and monthly forecasts for every
+// category from its complete periods in the DW table and stores them as the
+// current scheduled forecasts, superseding the previous ones. The LLM is used
+// unless its health check reports it down, with the statistical fallback
+// otherwise. Archived categories and categories without history are skipped.
+func RefreshForecasts(ctx context.Context, db *sql.DB, now time.Time) error {
+	categories, err := queryCategories(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, category := range categories {
+		if category.Archived {
+			continue
+		}
+		for _, timePeriod := range refreshPeriods {
+			if err := refreshForecast(ctx, db, category, timePeriod, now); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				log.Printf("Failed to refresh %s forecast for %s: %v", timePeriod, category.Name, err)
+				failed = append(failed, fmt.Sprintf("%s/%s", category.Name, timePeriod))
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("forecast refresh failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func refreshForecast(ctx context.Context, db *sql.DB, category Category, timePeriod string, now time.Time) error {
+	current := periodStart(now.UTC(), timePeriod)
+	history, err := queryPeriodTotals(ctx, db, category.ID, timePeriod, refreshHistoryStart(current, timePeriod), current)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return nil
+	}
+
+	request := ForecastRequest{TimeSeriesData: history, TimePeriod: timePeriod}
+	request.TimeSeriesData, _ = fillGaps(request.TimeSeriesData, timePeriod, forecast.GapFillZero)
+	response := ForecastResponse{TimePeriod: timePeriod, Meta: ForecastMeta{Provider: "statistical"}}
+	fallback, method, err := refreshFallback(request, timePeriod)
+	if err != nil {
+		return err
+	}
+
+	health := currentLLMHealth()
+	response.Meta.LLMStatus = health.Status
+	if health.Status == llmStatusDown {
+		err = fmt.Errorf("LLM unavailable: %s", health.Error)
+	} else {
+		timeouts := deadline.FromEnv()
+		llmCtx, cancel := timeouts.WithTotal(ctx)
+		response.Forecast, _, err = generateForecastForPeriod(llmCtx, timeouts, request, timePeriod, fallback, &response.Meta, nil)
+		cancel()
+	}
+	if err == nil {
+		response.Meta.Source = forecastSourceLLM
+		response.Meta.Provider, _ = llmProviderName("")
+	} else {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// The previous forecast stays current rather than being replaced
+		// by a statistical one
+		if forecastFallback() == forecastFallbackError {
+			return err
+		}
+		response.Forecast, response.Meta.Method = fallback, method
+		response.Meta.Source = forecastSourceFallback
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	id, err := insertForecast(ctx, tx, &category.ID, response, true)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE forecasts SET superseded_at = NOW()
+		WHERE scheduled AND superseded_at IS NULL AND category_id = $1 AND time_period = $2 AND id <> $3
+	`, category.ID, timePeriod, id); err != nil {
+		return fmt.Errorf("failed to supersede forecasts: %v", err)
+	}
+	// A fresh forecast no longer needs regenerating
+	if err := dequeueForecast(ctx, tx, category.ID, timePeriod); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit forecast: %v", err)
+	}
+	return nil
+}
+
+// refreshFallback forecasts with the statistical method the forecast
+// endpoint would choose by default, with a fixed seed
+func refreshFallback(request ForecastRequest, timePeriod string) ([]TimeSeriesPoint, string, error) {
+	totals := make([]float64, len(request.TimeSeriesData))
+	for i, point := range request.TimeSeriesData {
+		totals[i] = point.Total
+	}
+	method := forecast.MethodMovingAverage
+	if demand := forecast.ClassifyDemand(totals); demand.Intermittent {
+		method = demand.Method
+	}
+
+	strategy, err := forecast.NewStrategy(forecast.Options{Method: method}, timePeriod)
+	if err != nil {
+		return nil, "", err
+	}
+	forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(deterministicSeed)), nil).WithStrategy(strategy)
+	return generateSimpleForecast(forecaster, request, timePeriod), string(method), nil
+}
+
+// queryPeriodTotals returns a category's totals per day, week, or month from
+// start up to but not including end, oldest first. Days and weeks are
+// labelled YYYY-MM-DD and months YYYY-MM.
+func queryPeriodTotals(ctx context.Context, db *sql.DB, categoryID int, timePeriod string, start, end time.Time) ([]TimeSeriesPoint, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DATE_TRUNC($1, date_recorded) AS period, SUM(total_amount)
+		FROM sales_totals_by_category_dw
+		WHERE category_id = $2 AND date_recorded >= $3 AND date_recorded < $4 AND deleted_at IS NULL
+		GROUP BY period
+		ORDER BY period
+	`, timePeriod, categoryID, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category totals: %w", err)
+	}
+	defer rows.Close()
+
+	layout := "2006-01-02"
+	if timePeriod == "month" {
+		layout = "2006-01"
+	}
+
+	var points []TimeSeriesPoint
+	for rows.Next() {
+		var (
+			period time.Time
+			total  float64
+		)
+		if err := rows.Scan(&period, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		points = append(points, TimeSeriesPoint{Period: period.Format(layout), Total: total})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return points, nil
+}
+
+// GetLatestForecast handles the API request for a precomputed forecast
+// @Summary Get the precomputed forecast for a category
+// @Description Returns the current forecast made by the scheduled forecast-refresh job for a category and time period, without calling the LLM. The forecast ID can be looked up in the accuracy endpoint.
+// @Tags sales
+// @Produce json
+// @Produce application/x-protobuf
+// @Produce application/msgpack
+// @Param category_id query int true "Category ID"
+// @Param time_period query string false "Time period: day, week, or month (defaults to month)"
+// @Param schema query int false "Response schema version: 1 (deprecated) or 2 (default)"
+// @Param X-Schema-Version header int false "Response schema version, when schema isn't set"
+// @Success 200 {object} ForecastResponse "Precomputed forecast"
+// @Failure 400 {object} httperror.Envelope "Invalid parameter"
+// @Failure 404 {object} httperror.Envelope "No precomputed forecast"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /forecasts/latest [get]
+func GetLatestForecast(c echo.Context) error {
+	ctx := c.Request().Context()
+	categoryID, err := strconv.Atoi(c.QueryParam("category_id"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "category_id is required")
+	}
+
+	timePeriod := c.QueryParam("time_period")
+	switch timePeriod {
+	case "":
+		timePeriod = "month"
+	case "day", "week", "month":
+	default:
+		return httperror.JSON(c, http.StatusBadRequest, "time_period must be day, week, or month")
+	}
+
+	schemaVersion, err := forecastSchemaVersion(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		response, err := queryLatestForecast(ctx, db, categoryID, timePeriod)
+		if err != nil {
+			return err
+		}
+		c.Response().Header().Set("X-Forecast-Source", response.Meta.Source)
+		return respondForecast(c, http.StatusOK, schemaVersion, response)
+	})
+}
+
+func queryLatestForecast(ctx context.Context, db *sql.DB, categoryID int, timePeriod string) (ForecastResponse, error) {
+	response := ForecastResponse{TimePeriod: timePeriod, Meta: ForecastMeta{Provider: "statistical", CacheHit: true}}
+
+	var createdAt time.Time
+	err := db.QueryRowContext(ctx, `
+		SELECT id, source, COALESCE(method, ''), created_at
+		FROM forecasts
+		WHERE scheduled AND superseded_at IS NULL AND category_id = $1 AND time_period = $2
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, categoryID, timePeriod).Scan(&response.ForecastID, &response.Meta.Source, &response.Meta.Method, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return response, &notFoundError{resource: "Forecast"}
+	}
+	if err != nil {
+		return response, fmt.Errorf("failed to query forecast: %v", err)
+	}
+	if response.Meta.Source == forecastSourceLLM {
+		response.Meta.Provider, _ = llmProviderName("")
+	}
+	response.Message = fmt.Sprintf("Precomputed forecast generated at %s", createdAt.UTC().Format(time.RFC3339))
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT period_start, predicted, lower_80, upper_80, lower_95, upper_95
+		FROM forecast_points
+		WHERE forecast_id = $1
+		ORDER BY period_start
+	`, response.ForecastID)
+	if err != nil {
+		return response, fmt.Errorf("failed to query forecast periods: %v", err)
+	}
+	defer rows.Close()
+
+	layout := "2006-01-02"
+	if timePeriod == "month" {
+		layout = "2006-01"
+	}
+	response.Forecast = []TimeSeriesPoint{}
+	for rows.Next() {
+		var (
+			start                              time.Time
+			point                              TimeSeriesPoint
+			lower80, upper80, lower95, upper95 *float64
+		)
+		if err := rows.Scan(&start, &point.Total, &lower80, &upper80, &lower95, &upper95); err != nil {
+			return response, fmt.Errorf("failed to scan row: %v", err)
+		}
+		point.Period = start.Format(layout)
+		// Forecasts stored before bands were added have none
+		if lower80 != nil && upper80 != nil && lower95 != nil && upper95 != nil {
+			point.Intervals = []ConfidenceInterval{
+				{Level: 80, Lower: *lower80, Upper: *upper80},
+				{Level: 95, Lower: *lower95, Upper: *upper95},
+			}
+		}
+		response.Forecast = append(response.Forecast, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return response, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return response, nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/prompts"
+)
+
+// maxScenarioAdjustments bounds the adjustments of one scenario
+const maxScenarioAdjustments = 20
+
+// maxScenarioMultiplier bounds how far an adjustment can scale sales
+const maxScenarioMultiplier = 10
+
+// ScenarioAdjustment is a planned change to sales over a range of days, such
+// as "+15% marketing uplift in November" or a promotion
+type ScenarioAdjustment struct {
+	// Name describes the adjustment in the prompt, e.g. Black Friday promo
+	Name string `json:"name,omitempty"`
+	// Start and End are the first and last day as YYYY-MM-DD, inclusive
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Multiplier scales sales on the covered days, e.g. 1.15 for +15% or 0.9
+	// for -10%
+	Multiplier float64 `json:"multiplier"`
+}
+
+// scenario validates the request's scenario adjustments and returns them
+// for the statistical forecast
+func (r ForecastRequest) scenario() (forecast.Scenario, error) {
+	if len(r.Scenario) > maxScenarioAdjustments {
+		return nil, fmt.Errorf("scenario can have at most %d adjustments", maxScenarioAdjustments)
+	}
+
+	scenario := make(forecast.Scenario, 0, len(r.Scenario))
+	for i, adjustment := range r.Scenario {
+		start, err := time.Parse("2006-01-02", adjustment.Start)
+		if err != nil {
+			return nil, fmt.Errorf("scenario[%d].start must be a date as YYYY-MM-DD", i)
+		}
+		end, err := time.Parse("2006-01-02", adjustment.End)
+		if err != nil {
+			return nil, fmt.Errorf("scenario[%d].end must be a date as YYYY-MM-DD", i)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("scenario[%d].end must not be before its start", i)
+		}
+		if adjustment.Multiplier <= 0 || adjustment.Multiplier > maxScenarioMultiplier {
+			return nil, fmt.Errorf("scenario[%d].multiplier must be above 0 and at most %d", i, maxScenarioMultiplier)
+		}
+		scenario = append(scenario, forecast.Adjustment{Start: start, End: end, Multiplier: adjustment.Multiplier})
+	}
+	return scenario, nil
+}
+
+// scenarioPromptAdjustments returns the scenario adjustments as the prompt
+// lists them, with their change in percent
+func scenarioPromptAdjustments(adjustments []ScenarioAdjustment) []prompts.Adjustment {
+	var result []prompts.Adjustment
+	for _, adjustment := range adjustments {
+		result = append(result, prompts.Adjustment{
+			Name:    adjustment.Name,
+			Start:   adjustment.Start,
+			End:     adjustment.End,
+			Percent: math.Round((adjustment.Multiplier-1)*10000) / 100,
+		})
+	}
+	return result
+}
+
+// applyScenario scales forecast points by the scenario
+func applyScenario(points []TimeSeriesPoint, scenario forecast.Scenario, timePeriod string) []TimeSeriesPoint {
+	return forecastPoints(scenario.Apply(forecastHistory(points), timePeriod))
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Forecast stream events
+const (
+	// forecastEventPoint is a forecast point from the LLM reply as soon as
+	// it is complete, before its bands are checked
+	forecastEventPoint = "point"
+	// forecastEventForecast is the finished forecast response, which
+	// replaces the points streamed before it
+	forecastEventForecast = "forecast"
+	// forecastEventError is the error the forecast failed with, in the
+	// error envelope, unless FORECAST_FALLBACK is statistical
+	forecastEventError = "error"
+)
+
+// forecastStreamRequested reports whether the stream query parameter asks
+// for the forecast as Server-Sent Events
+func forecastStreamRequested(c echo.Context) (bool, error) {
+	value := c.QueryParam("stream")
+	if value == "" {
+		return false, nil
+	}
+	stream, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("Invalid stream parameter %q. Use true or false", value)
+	}
+	return stream, nil
+}
+
+// forecastPointStream picks the forecast points out of a streamed LLM reply
+// as they are completed
+type forecastPointStream struct {
+	onPoint func(TimeSeriesPoint)
+	content strings.Builder
+	// next is where the search for the next point starts, or 0 until the
+	// reply's array has started
+	next int
+}
+
+// write adds a piece of the reply and calls onPoint with each point it
+// completes. Objects that aren't forecast points are skipped; the full
+// reply is parsed once it ends.
+func (s *forecastPointStream) write(content string) {
+	s.content.WriteString(content)
+	reply := s.content.String()
+
+	if s.next == 0 {
+		start := strings.IndexByte(reply, '[')
+		if start < 0 {
+			return
+		}
+		s.next = start + 1
+	}
+
+	for {
+		start := strings.IndexByte(reply[s.next:], '{')
+		if start < 0 {
+			return
+		}
+		start += s.next
+		end := matchingBracket(reply, start, '{', '}')
+		if end < 0 {
+			return
+		}
+		s.next = end + 1
+
+		// Points without a usable total are left for the full reply's repair
+		var point llmForecastPoint
+		if err := json.Unmarshal([]byte(reply[start:end+1]), &point); err == nil && point.Period != "" {
+			if converted := point.timeSeriesPoint(); usableTotal(converted.Total) {
+				s.onPoint(converted)
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bokor/craft-demo/internal/forecast"
+)
+
+// Reasons an LLM forecast point was repaired, reported in ForecastMeta.Repairs
+const (
+	// The period was taken from the statistical forecast
+	repairMissing  = "missing"
+	repairInvalid  = "invalid"
+	repairNegative = "negative"
+	// The LLM's point was dropped
+	repairDuplicate  = "duplicate"
+	repairUnexpected = "unexpected"
+)
+
+// ForecastRepair is an LLM forecast point that was replaced by the
+// statistical forecast or dropped
+type ForecastRepair struct {
+	// Period is the forecast period, or the period as the LLM wrote it for a
+	// dropped point
+	Period string `json:"period"`
+	// Reason is missing, invalid (not a number), or negative for periods
+	// taken from the statistical forecast, and duplicate or unexpected (not
+	// a period of the forecast) for dropped points
+	Reason string `json:"reason"`
+}
+
+// validateForecast checks the LLM's forecast points against the statistical
+// forecast, which has the periods the forecast must cover. Points are matched
+// to those periods by date, so 2024-07 matches 2024-07-01, and returned in
+// order with the statistical forecast's labels. A period the LLM left out or
+// gave a total that isn't a non-negative number is taken from the
+// statistical forecast, and points for other periods or repeating a period
+// are dropped; each is reported as a repair. Bands that aren't non-negative
+// numbers are removed so they are filled from the history. It fails when
+// none of the LLM's points can be used.
+func validateForecast(points, fallback []TimeSeriesPoint, timePeriod string) ([]TimeSeriesPoint, []ForecastRepair, error) {
+	expected := make(map[string]int, len(fallback))
+	for i, point := range fallback {
+		expected[periodKey(point.Period, timePeriod)] = i
+	}
+
+	var (
+		repairs []ForecastRepair
+		chosen  = make([]*TimeSeriesPoint, len(fallback))
+		reasons = make([]string, len(fallback))
+	)
+	for _, point := range points {
+		i, ok := expected[periodKey(point.Period, timePeriod)]
+		switch {
+		case !ok:
+			repairs = append(repairs, ForecastRepair{Period: point.Period, Reason: repairUnexpected})
+		case chosen[i] != nil:
+			repairs = append(repairs, ForecastRepair{Period: point.Period, Reason: repairDuplicate})
+		case math.IsNaN(point.Total) || math.IsInf(point.Total, 0):
+			reasons[i] = repairInvalid
+		case point.Total < 0:
+			reasons[i] = repairNegative
+		default:
+			point.Period = fallback[i].Period
+			if !usableIntervals(point.Intervals) {
+				point.Intervals = nil
+			}
+			chosen[i] = &point
+		}
+	}
+
+	result := make([]TimeSeriesPoint, len(fallback))
+	used := 0
+	for i, point := range chosen {
+		if point != nil {
+			result[i] = *point
+			used++
+			continue
+		}
+		reason := reasons[i]
+		if reason == "" {
+			reason = repairMissing
+		}
+		result[i] = fallback[i]
+		repairs = append(repairs, ForecastRepair{Period: fallback[i].Period, Reason: reason})
+	}
+
+	if used == 0 {
+		return nil, repairs, fmt.Errorf("none of the %d forecast points in the response are usable", len(points))
+	}
+	return result, repairs, nil
+}
+
+// periodKey normalizes a period label for matching, using the label the
+// statistical forecast gives its date
+func periodKey(period, timePeriod string) string {
+	period = strings.TrimSpace(period)
+	date, err := forecast.ParsePeriod(period)
+	if err != nil {
+		return period
+	}
+	return forecast.FormatPeriod(date, timePeriod)
+}
+
+// usableTotal reports whether a forecast total is a non-negative number
+func usableTotal(total float64) bool {
+	return total >= 0 && !math.IsInf(total, 1)
+}
+
+// usableIntervals reports whether every bound of the bands is a non-negative
+// number
+func usableIntervals(intervals []ConfidenceInterval) bool {
+	for _, interval := range intervals {
+		if !usableTotal(interval.Lower) || !usableTotal(interval.Upper) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// goalBaselineDays is the daily history the remaining days are forecast from
+const goalBaselineDays = 28
+
+// GoalProgress projects how a sales goal will end the month
+type GoalProgress struct {
+	GoalID       int64   `json:"goal_id"`
+	Month        string  `json:"month"`
+	CategoryName string  `json:"category_name"`
+	Target       float64 `json:"target"`
+	// AsOf is the first day not counted as actual; it and the days after it
+	// are forecast
+	AsOf          string  `json:"as_of"`
+	DaysInMonth   int     `json:"days_in_month"`
+	DaysElapsed   int     `json:"days_elapsed"`
+	DaysRemaining int     `json:"days_remaining"`
+	MonthToDate   float64 `json:"month_to_date"`
+	// RemainingForecast is the baseline forecast of the remaining days
+	RemainingForecast float64 `json:"remaining_forecast"`
+	ProjectedTotal    float64 `json:"projected_total"`
+	// ProjectedAttainment is the projected total as a percentage of target,
+	// and null when the target is zero
+	ProjectedAttainment *float64 `json:"projected_attainment"`
+	CurrentDailyRunRate float64  `json:"current_daily_run_rate"`
+	// RequiredDailyRunRate is the daily revenue needed over the remaining
+	// days to reach the target
+	RequiredDailyRunRate float64 `json:"required_daily_run_rate"`
+	OnTrack              bool    `json:"on_track"`
+	// Actual and Forecast are the daily series for a pacing chart
+	Actual   []TimeSeriesPoint `json:"actual"`
+	Forecast []TimeSeriesPoint `json:"forecast"`
+}
+
+// GetGoalProgress handles the API request for sales goal progress
+// @Summary Get sales goal progress
+// @Description Combines month-to-date actual revenue with a baseline forecast of the remaining days to project end-of-month attainment of a sales goal, and the daily run-rate still needed to reach it. Goals are the monthly revenue targets; the id is the one returned by GET /targets.
+// @Tags sales
+// @Produce json
+// @Param id path int true "Goal (revenue target) ID"
+// @Param as_of query string false "Date in YYYY-MM-DD format; days before it are actual, it and later days are forecast (defaults to today)"
+// @Success 200 {object} GoalProgress "Goal progress"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid id or date"
+// @Failure 404 {object} httperror.Envelope "Goal not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /sales/goals/{id}/progress [get]
+func GetGoalProgress(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid goal id")
+	}
+
+	asOf := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.QueryParam("as_of"); value != "" {
+		asOf, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid as_of format. Use YYYY-MM-DD")
+		}
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		progress, err := queryGoalProgress(c.Request().Context(), db, id, asOf)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, progress)
+	})
+}
+
+// queryGoalProgress loads a goal and projects its month as of a date
+func queryGoalProgress(ctx context.Context, db *sql.DB, id int64, asOf time.Time) (*GoalProgress, error) {
+	var (
+		month      time.Time
+		categoryID int
+	)
+	progress := &GoalProgress{GoalID: id, AsOf: asOf.Format("2006-01-02")}
+	err := db.QueryRowContext(ctx, `
+		SELECT t.month, t.category_id, c.name, t.target
+		FROM revenue_targets t
+		JOIN categories c ON c.id = t.category_id
+		WHERE t.id = $1
+	`, id).Scan(&month, &categoryID, &progress.CategoryName, &progress.Target)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, &notFoundError{resource: "Goal"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goal: %v", err)
+	}
+
+	end := month.AddDate(0, 1, 0)
+	progress.Month = month.Format("2006-01")
+	progress.DaysInMonth = int(end.Sub(month).Hours() / 24)
+
+	// The forecast starts at as_of, clamped to the month, so a past month is
+	// all actual and a future month is all forecast
+	cutoff := asOf
+	if cutoff.After(end) {
+		cutoff = end
+	}
+	historyStart := cutoff.AddDate(0, 0, -goalBaselineDays)
+	if month.Before(historyStart) {
+		historyStart = month
+	}
+	daily, err := queryDailyCategoryTotals(ctx, db, categoryID, historyStart, end.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	progress.Actual = []TimeSeriesPoint{}
+	for day := month; day.Before(cutoff); day = day.AddDate(0, 0, 1) {
+		period := day.Format("2006-01-02")
+		progress.Actual = append(progress.Actual, TimeSeriesPoint{Period: period, Total: daily[period]})
+		progress.MonthToDate += daily[period]
+		progress.DaysElapsed++
+	}
+
+	var history []forecast.Point
+	for day := cutoff.AddDate(0, 0, -goalBaselineDays); day.Before(cutoff); day = day.AddDate(0, 0, 1) {
+		period := day.Format("2006-01-02")
+		history = append(history, forecast.Point{Period: period, Total: daily[period]})
+	}
+
+	// A fixed seed keeps the projection the same on every request
+	progress.Forecast = []TimeSeriesPoint{}
+	forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(deterministicSeed)), forecast.FixedClock(cutoff))
+	days := int(end.Sub(cutoff).Hours() / 24)
+	for _, point := range forecaster.Forecast(history, "day", days) {
+		if date, err := time.Parse("2006-01-02", point.Period); err == nil && date.Before(month) {
+			continue
+		}
+		progress.Forecast = append(progress.Forecast, TimeSeriesPoint{Period: point.Period, Total: point.Total})
+		progress.RemainingForecast += point.Total
+		progress.DaysRemaining++
+	}
+
+	progress.MonthToDate = math.Round(progress.MonthToDate*100) / 100
+	progress.RemainingForecast = math.Round(progress.RemainingForecast*100) / 100
+	progress.ProjectedTotal = math.Round((progress.MonthToDate+progress.RemainingForecast)*100) / 100
+	progress.OnTrack = progress.ProjectedTotal >= progress.Target
+	if progress.Target > 0 {
+		attainment := math.Round(progress.ProjectedTotal/progress.Target*10000) / 100
+		progress.ProjectedAttainment = &attainment
+	}
+	if progress.DaysElapsed > 0 {
+		progress.CurrentDailyRunRate = math.Round(progress.MonthToDate/float64(progress.DaysElapsed)*100) / 100
+	}
+	if remaining := progress.Target - progress.MonthToDate; progress.DaysRemaining > 0 && remaining > 0 {
+		progress.RequiredDailyRunRate = math.Round(remaining/float64(progress.DaysRemaining)*100) / 100
+	}
+	return progress, nil
+}
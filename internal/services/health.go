@@ -0,0 +1,88 @@
+package services
+
+import (
+	"log"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/bokor/craft-demo/internal/database"
+	"github.com/labstack/echo/v4"
+)
+
+// HealthResponse represents the service health
+type HealthResponse struct {
+	// Status is ok, or degraded when the schema check found problems
+	Status string                `json:"status"`
+	Schema database.SchemaReport `json:"schema"`
+}
+
+// GetHealth handles the API request for the service health
+// @Summary Get service health
+// @Description Checks that the database is reachable, the newest migration has been applied, and every table and column the services need exists
+// @Tags health
+// @Produce json
+// @Success 200 {object} HealthResponse "Service is healthy"
+// @Failure 503 {object} HealthResponse "Service is degraded"
+// @Router /health [get]
+func GetHealth(c echo.Context) error {
+	db, err := getDB()
+	if err != nil {
+		log.Printf("Database connection failed: %v", err)
+		return c.JSON(http.StatusServiceUnavailable, HealthResponse{
+			Status: "degraded",
+			Schema: database.SchemaReport{Problems: []string{"database connection failed"}},
+		})
+	}
+
+	report := database.CheckSchema(db)
+	if !report.OK() {
+		return c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: "degraded", Schema: report})
+	}
+	return c.JSON(http.StatusOK, HealthResponse{Status: "ok", Schema: report})
+}
+
+// draining is set once the server starts shutting down
+var draining atomic.Bool
+
+// StartDraining makes the readiness check fail so the load balancer stops
+// sending requests while the ones in flight finish
+func StartDraining() {
+	draining.Store(true)
+}
+
+// ReadinessResponse represents whether the service can take traffic
+type ReadinessResponse struct {
+	// Status is ready, not_ready when the database is unreachable, or
+	// draining while the server shuts down
+	Status   string `json:"status"`
+	Database string `json:"database"`
+	// LLM is the cached LLM health; forecasts fall back to statistical
+	// models while it is down, so it doesn't affect readiness
+	LLM LLMHealth `json:"llm"`
+}
+
+// GetReadiness handles the API request for service readiness
+// @Summary Get service readiness
+// @Description Checks that the database is reachable and reports the cached LLM health from the background probe. The LLM is never called by this check. Reports draining with 503 once the server is shutting down.
+// @Tags health
+// @Produce json
+// @Success 200 {object} ReadinessResponse "Service is ready"
+// @Failure 503 {object} ReadinessResponse "Service is not ready"
+// @Router /readyz [get]
+func GetReadiness(c echo.Context) error {
+	response := ReadinessResponse{Status: "ready", Database: "up", LLM: currentLLMHealth()}
+	db, err := getDB()
+	if err == nil {
+		err = db.PingContext(c.Request().Context())
+	}
+	if err != nil {
+		log.Printf("Database connection failed: %v", err)
+		response.Status, response.Database = "not_ready", "down"
+		return c.JSON(http.StatusServiceUnavailable, response)
+	}
+	if draining.Load() {
+		response.Status = "draining"
+		return c.JSON(http.StatusServiceUnavailable, response)
+	}
+	return c.JSON(http.StatusOK, response)
+}
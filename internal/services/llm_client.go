@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LLM providers selected with LLM_PROVIDER or a request's provider field
+const (
+	llmProviderOpenAI    = "openai"
+	llmProviderAnthropic = "anthropic"
+)
+
+// Limits of the chat settings a request or LLM_TEMPERATURE and
+// LLM_MAX_TOKENS can set
+const (
+	maxLLMTemperature = 2
+	maxLLMTokens      = 16384
+)
+
+// LLMClient sends chat requests to a language model provider
+type LLMClient interface {
+	// Provider is openai, azure, ollama, or anthropic, as reported in ForecastMeta
+	Provider() string
+	// Model is the model chat requests without one are answered by
+	Model() string
+	// Chat sends the request to its model, or the client's, giving up when
+	// ctx is done
+	Chat(ctx context.Context, request ChatGPTRequest) (*ChatGPTResponse, error)
+	// ChatStream is Chat with the reply streamed, calling onContent with each
+	// piece of it as it arrives
+	ChatStream(ctx context.Context, request ChatGPTRequest, onContent func(string)) (*ChatGPTResponse, error)
+	// Ping checks the API is reachable by listing models, which costs nothing
+	Ping(ctx context.Context) error
+}
+
+// newLLMClient returns the client for provider, or for LLM_PROVIDER when
+// provider is empty, after checking its API key. Chat requests get the
+// LLM_TEMPERATURE and LLM_MAX_TOKENS settings unless they set their own, and
+// responses are priced with LLM_PRICES and counted on /metrics.
+func newLLMClient(provider string) (LLMClient, error) {
+	name, err := llmProviderName(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	var client LLMClient
+	if name == llmProviderAnthropic {
+		client, err = newAnthropicClient()
+	} else {
+		client, err = newOpenAIClient()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := configuredClient{LLMClient: client}
+	if value := os.Getenv("LLM_TEMPERATURE"); value != "" {
+		temperature, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LLM_TEMPERATURE %q", value)
+		}
+		defaults.temperature = &temperature
+	}
+	if value := os.Getenv("LLM_MAX_TOKENS"); value != "" {
+		if defaults.maxTokens, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid LLM_MAX_TOKENS %q", value)
+		}
+	}
+	if err := validateChatSettings(defaults.temperature, defaults.maxTokens); err != nil {
+		return nil, fmt.Errorf("invalid LLM_TEMPERATURE or LLM_MAX_TOKENS: %v", err)
+	}
+	if defaults.prices, err = loadLLMPrices(); err != nil {
+		return nil, err
+	}
+	return defaults, nil
+}
+
+// configuredClient fills in the temperature and max tokens of chat requests
+// that don't set them, and prices and counts the tokens of their responses
+type configuredClient struct {
+	LLMClient
+	temperature *float64
+	maxTokens   int
+	prices      map[string]llmPrice
+}
+
+func (c configuredClient) Chat(ctx context.Context, request ChatGPTRequest) (*ChatGPTResponse, error) {
+	return c.send(request, func(request ChatGPTRequest) (*ChatGPTResponse, error) {
+		return c.LLMClient.Chat(ctx, request)
+	})
+}
+
+func (c configuredClient) ChatStream(ctx context.Context, request ChatGPTRequest, onContent func(string)) (*ChatGPTResponse, error) {
+	return c.send(request, func(request ChatGPTRequest) (*ChatGPTResponse, error) {
+		return c.LLMClient.ChatStream(ctx, request, onContent)
+	})
+}
+
+// send fills in the request's defaults, sends it with chat, and prices and
+// counts the tokens of the response
+func (c configuredClient) send(request ChatGPTRequest, chat func(ChatGPTRequest) (*ChatGPTResponse, error)) (*ChatGPTResponse, error) {
+	if request.Temperature == nil {
+		request.Temperature = c.temperature
+	}
+	if request.MaxTokens == 0 {
+		request.MaxTokens = c.maxTokens
+	}
+	response, err := chat(request)
+	if err != nil {
+		return nil, err
+	}
+
+	model := request.Model
+	if model == "" {
+		model = c.Model()
+	}
+	if response.Usage != nil {
+		response.CostUSD = llmCost(c.prices, c.Provider(), model, *response.Usage)
+	}
+	recordLLMUsage(c.Provider(), model, response)
+	return response, nil
+}
+
+// validateChatSettings checks a temperature and max tokens; nil and zero
+// mean the provider's default
+func validateChatSettings(temperature *float64, maxTokens int) error {
+	if temperature != nil && (*temperature < 0 || *temperature > maxLLMTemperature) {
+		return fmt.Errorf("temperature must be between 0 and %d", maxLLMTemperature)
+	}
+	if maxTokens < 0 || maxTokens > maxLLMTokens {
+		return fmt.Errorf("maxTokens must be between 1 and %d", maxLLMTokens)
+	}
+	return nil
+}
+
+// LLMConfigured reports whether LLM_PROVIDER is valid and has an API key
+func LLMConfigured() bool {
+	_, err := newLLMClient("")
+	return err == nil
+}
+
+// llmProviderName resolves provider, or LLM_PROVIDER (default openai) when
+// it is empty, to the provider reported in ForecastMeta: openai, azure or
+// ollama (for OpenAI with that OPENAI_API_TYPE), or anthropic. claude is
+// accepted for anthropic.
+func llmProviderName(provider string) (string, error) {
+	if provider == "" {
+		provider = os.Getenv("LLM_PROVIDER")
+	}
+	switch strings.ToLower(strings.TrimSpace(provider)) {
+	case "", llmProviderOpenAI:
+		return openAIProvider(), nil
+	case llmProviderAnthropic, "claude":
+		return llmProviderAnthropic, nil
+	default:
+		return "", fmt.Errorf("invalid LLM provider %q, use openai or anthropic", provider)
+	}
+}
+
+// llmHealthFor returns the cached LLM health when provider resolves to the
+// default provider the health check probes, and unknown otherwise
+func llmHealthFor(provider string) LLMHealth {
+	name, err := llmProviderName(provider)
+	if defaultName, _ := llmProviderName(""); err == nil && name != defaultName {
+		return LLMHealth{Status: llmStatusUnknown}
+	}
+	return currentLLMHealth()
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// LLM health statuses
+const (
+	// llmStatusUnknown is reported until the first probe finishes; forecasts
+	// still try the LLM
+	llmStatusUnknown      = "unknown"
+	llmStatusUp           = "up"
+	llmStatusDown         = "down"
+	llmStatusUnconfigured = "unconfigured"
+)
+
+// llmProbeTimeout bounds a single health probe
+const llmProbeTimeout = 5 * time.Second
+
+// LLMHealth is the cached result of the latest LLM connectivity probe
+type LLMHealth struct {
+	// Status is unknown, up, down, or unconfigured
+	Status    string     `json:"status"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+	LatencyMs int64      `json:"latency_ms,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+var (
+	llmHealthMu sync.RWMutex
+	llmHealth   = LLMHealth{Status: llmStatusUnknown}
+)
+
+// currentLLMHealth returns the cached LLM health
+func currentLLMHealth() LLMHealth {
+	llmHealthMu.RLock()
+	defer llmHealthMu.RUnlock()
+	return llmHealth
+}
+
+// WatchLLMHealth probes the LLM API now and then every interval, caching the
+// result for forecasts and readiness checks, until ctx is done
+func WatchLLMHealth(ctx context.Context, interval time.Duration) {
+	for {
+		health := CheckLLMHealth(ctx)
+		if health.Status == llmStatusDown && ctx.Err() == nil {
+			log.Printf("LLM health check failed: %s", health.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// CheckLLMHealth probes the LLM_PROVIDER API by listing models, which costs
+// nothing, and caches the result
+func CheckLLMHealth(ctx context.Context) LLMHealth {
+	health := probeLLM(ctx)
+	llmHealthMu.Lock()
+	llmHealth = health
+	llmHealthMu.Unlock()
+	return health
+}
+
+func probeLLM(ctx context.Context) LLMHealth {
+	checkedAt := time.Now().UTC()
+	health := LLMHealth{Status: llmStatusDown, CheckedAt: &checkedAt}
+
+	client, err := newLLMClient("")
+	if err != nil {
+		health.Status = llmStatusUnconfigured
+		health.Error = err.Error()
+		return health
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, llmProbeTimeout)
+	defer cancel()
+	err = client.Ping(ctx)
+	health.LatencyMs = time.Since(checkedAt).Milliseconds()
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.Status = llmStatusUp
+	return health
+}
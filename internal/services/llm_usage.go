@@ -0,0 +1,188 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// TokenUsage is the usage block of a chat completion
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// LLMUsage is the token usage and estimated cost of an LLM call
+type LLMUsage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+	// CostUSD is estimated from the model's price per token, null when the
+	// price isn't known
+	CostUSD *float64 `json:"costUsd"`
+}
+
+// llmPrice is a model's price in US dollars per million tokens
+type llmPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// defaultLLMPrices are the list prices of the default and commonly used
+// models; LLM_PRICES adds to and overrides them
+var defaultLLMPrices = map[string]llmPrice{
+	"gpt-3.5-turbo":     {Prompt: 0.50, Completion: 1.50},
+	"gpt-4o-mini":       {Prompt: 0.15, Completion: 0.60},
+	"gpt-4o":            {Prompt: 2.50, Completion: 10.00},
+	"claude-sonnet-4-5": {Prompt: 3.00, Completion: 15.00},
+	"claude-haiku-4-5":  {Prompt: 1.00, Completion: 5.00},
+}
+
+// loadLLMPrices returns the default prices overridden by LLM_PRICES, a list
+// of model=prompt/completion prices per million tokens, e.g.
+// gpt-4o-mini=0.15/0.60,my-finetune=3/6
+func loadLLMPrices() (map[string]llmPrice, error) {
+	prices := make(map[string]llmPrice, len(defaultLLMPrices))
+	for model, price := range defaultLLMPrices {
+		prices[model] = price
+	}
+
+	value := os.Getenv("LLM_PRICES")
+	if value == "" {
+		return prices, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		model, rates, ok := strings.Cut(entry, "=")
+		prompt, completion, ok2 := strings.Cut(rates, "/")
+		model = strings.TrimSpace(model)
+		promptPrice, err := strconv.ParseFloat(strings.TrimSpace(prompt), 64)
+		completionPrice, err2 := strconv.ParseFloat(strings.TrimSpace(completion), 64)
+		if !ok || !ok2 || model == "" || err != nil || err2 != nil || promptPrice < 0 || completionPrice < 0 {
+			return nil, fmt.Errorf("invalid LLM_PRICES entry %q, use model=prompt/completion", entry)
+		}
+		prices[model] = llmPrice{Prompt: promptPrice, Completion: completionPrice}
+	}
+	return prices, nil
+}
+
+// llmCost estimates the cost of usage on a provider's model. Models served
+// by a local Ollama server cost nothing; the cost is nil when the model has
+// no price.
+func llmCost(prices map[string]llmPrice, provider, model string, usage TokenUsage) *float64 {
+	if provider == "ollama" {
+		cost := 0.0
+		return &cost
+	}
+	price, ok := prices[model]
+	if !ok {
+		return nil
+	}
+	cost := (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+	cost = math.Round(cost*1e6) / 1e6
+	return &cost
+}
+
+// usage returns the token usage and cost of a response, or nil when the
+// provider didn't report it
+func (r *ChatGPTResponse) usage() *LLMUsage {
+	if r.Usage == nil {
+		return nil
+	}
+	return &LLMUsage{
+		PromptTokens:     r.Usage.PromptTokens,
+		CompletionTokens: r.Usage.CompletionTokens,
+		TotalTokens:      r.Usage.TotalTokens,
+		CostUSD:          r.CostUSD,
+	}
+}
+
+// llmUsageKey identifies the counters of a provider's model
+type llmUsageKey struct {
+	provider, model string
+}
+
+// llmUsageTotals are the running totals of a provider's model since startup
+type llmUsageTotals struct {
+	requests         int64
+	promptTokens     int64
+	completionTokens int64
+	costUSD          float64
+}
+
+var llmUsageCounters = struct {
+	sync.Mutex
+	totals map[llmUsageKey]*llmUsageTotals
+}{totals: make(map[llmUsageKey]*llmUsageTotals)}
+
+// recordLLMUsage adds a response's usage to the counters served on /metrics
+func recordLLMUsage(provider, model string, response *ChatGPTResponse) {
+	llmUsageCounters.Lock()
+	defer llmUsageCounters.Unlock()
+
+	key := llmUsageKey{provider: provider, model: model}
+	totals, ok := llmUsageCounters.totals[key]
+	if !ok {
+		totals = &llmUsageTotals{}
+		llmUsageCounters.totals[key] = totals
+	}
+	totals.requests++
+	if response.Usage != nil {
+		totals.promptTokens += int64(response.Usage.PromptTokens)
+		totals.completionTokens += int64(response.Usage.CompletionTokens)
+	}
+	if response.CostUSD != nil {
+		totals.costUSD += *response.CostUSD
+	}
+}
+
+// writeLLMUsageMetrics writes the LLM usage counters in the Prometheus text
+// format, one series per provider and model
+func writeLLMUsageMetrics(w io.Writer) error {
+	llmUsageCounters.Lock()
+	keys := make([]llmUsageKey, 0, len(llmUsageCounters.totals))
+	totals := make(map[llmUsageKey]llmUsageTotals, len(llmUsageCounters.totals))
+	for key, total := range llmUsageCounters.totals {
+		keys = append(keys, key)
+		totals[key] = *total
+	}
+	llmUsageCounters.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].provider != keys[j].provider {
+			return keys[i].provider < keys[j].provider
+		}
+		return keys[i].model < keys[j].model
+	})
+
+	var buf bytes.Buffer
+	for _, counter := range []struct {
+		name, help string
+		value      func(llmUsageTotals) float64
+	}{
+		{"craft_llm_requests_total", "LLM responses received", func(t llmUsageTotals) float64 { return float64(t.requests) }},
+		{"craft_llm_prompt_tokens_total", "Prompt tokens sent to the LLM", func(t llmUsageTotals) float64 { return float64(t.promptTokens) }},
+		{"craft_llm_completion_tokens_total", "Completion tokens received from the LLM", func(t llmUsageTotals) float64 { return float64(t.completionTokens) }},
+		{"craft_llm_cost_usd_total", "Estimated LLM cost in US dollars for models with a known price", func(t llmUsageTotals) float64 { return t.costUSD }},
+	} {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+		for _, key := range keys {
+			fmt.Fprintf(&buf, "%s{provider=\"%s\",model=\"%s\"} %g\n",
+				counter.name, escapePrometheusLabel(key.provider), escapePrometheusLabel(key.model), counter.value(totals[key]))
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// escapePrometheusLabel escapes a Prometheus label value
+func escapePrometheusLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/kpi"
+	"github.com/labstack/echo/v4"
+)
+
+// kpiCache holds the last KPI snapshot so frequent scrapes don't query the DW
+var kpiCache struct {
+	sync.Mutex
+	snapshot kpi.Snapshot
+	valid    bool
+}
+
+// kpiCacheTTL returns how long a snapshot is served, set with KPI_CACHE_SECONDS
+// (default 60)
+func kpiCacheTTL() time.Duration {
+	if value := os.Getenv("KPI_CACHE_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		log.Printf("Warning: invalid KPI_CACHE_SECONDS %q, using 60", value)
+	}
+	return time.Minute
+}
+
+// CurrentKPIs returns the cached KPI snapshot, recomputing it once it expires
+func CurrentKPIs() (kpi.Snapshot, error) {
+	kpiCache.Lock()
+	defer kpiCache.Unlock()
+
+	now := time.Now()
+	if kpiCache.valid && now.Sub(kpiCache.snapshot.CollectedAt) < kpiCacheTTL() {
+		return kpiCache.snapshot, nil
+	}
+
+	db, err := getDB()
+	if err != nil {
+		return kpi.Snapshot{}, err
+	}
+
+	snapshot, err := kpi.Collect(db, now)
+	if err != nil {
+		return kpi.Snapshot{}, err
+	}
+	kpiCache.snapshot, kpiCache.valid = snapshot, true
+	return snapshot, nil
+}
+
+// GetMetrics handles the metrics scrape
+// @Summary Get business KPI metrics
+// @Description Exposes business KPIs as Prometheus gauges: yesterday's net revenue per category and in total, the baseline forecast of that revenue and the relative deviation from it, and the refund rate. Values are cached for KPI_CACHE_SECONDS. LLM requests, prompt and completion tokens, and estimated cost since startup follow as counters per provider and model. The database pool's connection counts and waits close the output.
+// @Tags health
+// @Produce plain
+// @Success 200 {string} string "Metrics in the Prometheus text exposition format"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /metrics [get]
+func GetMetrics(c echo.Context) error {
+	snapshot, err := CurrentKPIs()
+	if err != nil {
+		log.Printf("Failed to collect KPIs: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Failed to collect KPIs")
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+	if err := kpi.WritePrometheus(c.Response(), snapshot); err != nil {
+		return err
+	}
+	if err := writeLLMUsageMetrics(c.Response()); err != nil {
+		return err
+	}
+	return writeDBPoolMetrics(c.Response())
+}
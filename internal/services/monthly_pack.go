@@ -0,0 +1,498 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+	"github.com/xuri/excelize/v2"
+)
+
+// monthlyPackHistoryMonths is how many months before the reported month the
+// forecast is made from
+const monthlyPackHistoryMonths = 6
+
+// Monthly pack sheet names
+const (
+	packSummarySheet  = "Summary"
+	packDailySheet    = "Daily by Category"
+	packForecastSheet = "Forecast vs Actual"
+	packChartsSheet   = "Charts"
+)
+
+// categoryForecast compares a category's forecast and actual revenue for a month
+type categoryForecast struct {
+	CategoryName string
+	Forecast     float64
+	Actual       float64
+}
+
+// Deviation returns (actual - forecast) / forecast, or 0 without a forecast
+func (f categoryForecast) Deviation() float64 {
+	if f.Forecast == 0 {
+		return 0
+	}
+	return (f.Actual - f.Forecast) / f.Forecast
+}
+
+// monthlyPack holds everything in a monthly reporting pack
+type monthlyPack struct {
+	Month      time.Time
+	Categories []string
+	// Daily maps date -> category -> total for every day of the month
+	Daily         map[string]map[string]float64
+	Forecasts     []categoryForecast
+	PreviousTotal float64
+	// MissThreshold is the relative deviation that counts as a forecast miss
+	MissThreshold float64
+}
+
+// GetMonthlyPack handles the API request for the monthly reporting pack
+// @Summary Download the monthly reporting pack
+// @Description Builds an Excel workbook for a month with a summary sheet, a formatted table of daily revenue per category, a forecast vs actual sheet that highlights categories missing the forecast by more than MONTHLY_PACK_MISS_THRESHOLD percent, and a line chart per category. The forecast for each category is made from the six months before.
+// @Tags sales
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param month query string false "Month in YYYY-MM format (defaults to last month)"
+// @Success 200 {file} file "Monthly pack workbook"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid month"
+// @Failure 404 {object} httperror.Envelope "No sales data found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /sales/report/monthly-pack [get]
+func GetMonthlyPack(c echo.Context) error {
+	ctx := c.Request().Context()
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	if value := c.QueryParam("month"); value != "" {
+		parsed, err := time.Parse("2006-01", value)
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid month format. Use YYYY-MM")
+		}
+		month = parsed
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		pack, err := queryMonthlyPack(ctx, db, month)
+		if err != nil {
+			return err
+		}
+		if len(pack.Categories) == 0 {
+			return httperror.JSON(c, http.StatusNotFound, "No sales data found")
+		}
+
+		workbook, err := buildMonthlyPackWorkbook(pack)
+		if err != nil {
+			return err
+		}
+		defer workbook.Close()
+		return writeXLSX(c, http.StatusOK, "monthly-pack-"+month.Format("2006-01"), workbook)
+	})
+}
+
+// monthlyPackMissThreshold reads MONTHLY_PACK_MISS_THRESHOLD, the percentage
+// deviation from the forecast that is highlighted as a miss (default 15)
+func monthlyPackMissThreshold() float64 {
+	if value := os.Getenv("MONTHLY_PACK_MISS_THRESHOLD"); value != "" {
+		if percent, err := strconv.ParseFloat(value, 64); err == nil && percent > 0 {
+			return percent / 100
+		}
+		log.Printf("Warning: invalid MONTHLY_PACK_MISS_THRESHOLD %q, using 15", value)
+	}
+	return 0.15
+}
+
+// queryMonthlyPack loads the daily totals for the month and forecasts each
+// category's month from the months before
+func queryMonthlyPack(ctx context.Context, db *sql.DB, month time.Time) (monthlyPack, error) {
+	pack := monthlyPack{
+		Month:         month,
+		Daily:         make(map[string]map[string]float64),
+		MissThreshold: monthlyPackMissThreshold(),
+	}
+	end := month.AddDate(0, 1, 0)
+
+	daily, err := QuerySalesData(ctx, db, SalesReportQuery{
+		StartDate: month.Format("2006-01-02"),
+		EndDate:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		// The pack reports amounts as recorded, like the other reports
+		SumCurrencies: true,
+	})
+	if err != nil {
+		return pack, err
+	}
+
+	seen := make(map[string]bool)
+	for day := month; day.Before(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		pack.Daily[date] = make(map[string]float64)
+		for _, total := range daily[date] {
+			pack.Daily[date][total.CategoryName] = total.TotalAmount
+			seen[total.CategoryName] = true
+		}
+	}
+	for category := range seen {
+		pack.Categories = append(pack.Categories, category)
+	}
+	sort.Strings(pack.Categories)
+
+	monthly, err := queryMonthlyCategoryTotals(ctx, db, month.AddDate(0, -monthlyPackHistoryMonths, 0), end)
+	if err != nil {
+		return pack, err
+	}
+	previousMonth := month.AddDate(0, -1, 0).Format("2006-01")
+	for _, totals := range monthly {
+		pack.PreviousTotal += totals[previousMonth]
+	}
+
+	for _, category := range pack.Categories {
+		var history []forecast.Point
+		for i := monthlyPackHistoryMonths; i >= 1; i-- {
+			period := month.AddDate(0, -i, 0).Format("2006-01")
+			history = append(history, forecast.Point{Period: period, Total: monthly[category][period]})
+		}
+
+		forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(deterministicSeed)), nil)
+		predicted := forecaster.Forecast(history, "month", 1)
+
+		comparison := categoryForecast{CategoryName: category, Actual: monthly[category][month.Format("2006-01")]}
+		if len(predicted) == 1 {
+			comparison.Forecast = predicted[0].Total
+		}
+		pack.Forecasts = append(pack.Forecasts, comparison)
+	}
+
+	return pack, nil
+}
+
+// queryMonthlyCategoryTotals returns category -> YYYY-MM -> total for the
+// months from start up to but not including end
+func queryMonthlyCategoryTotals(ctx context.Context, db *sql.DB, start, end time.Time) (map[string]map[string]float64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.name, DATE_TRUNC('month', st.date_recorded), SUM(st.total_amount)
+		FROM sales_totals_by_category_dw st
+		JOIN categories c ON st.category_id = c.id
+		WHERE st.date_recorded >= $1 AND st.date_recorded < $2 AND st.deleted_at IS NULL
+		GROUP BY c.name, DATE_TRUNC('month', st.date_recorded)
+	`, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query monthly totals: %v", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]map[string]float64)
+	for rows.Next() {
+		var (
+			category string
+			month    time.Time
+			total    float64
+		)
+		if err := rows.Scan(&category, &month, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if totals[category] == nil {
+			totals[category] = make(map[string]float64)
+		}
+		totals[category][month.Format("2006-01")] = total
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return totals, nil
+}
+
+// buildMonthlyPackWorkbook renders the pack as an Excel workbook
+func buildMonthlyPackWorkbook(pack monthlyPack) (*excelize.File, error) {
+	workbook := excelize.NewFile()
+
+	steps := []func(*excelize.File, monthlyPack) error{
+		writePackSummary,
+		writePackDaily,
+		writePackForecast,
+		writePackCharts,
+	}
+	for _, step := range steps {
+		if err := step(workbook, pack); err != nil {
+			workbook.Close()
+			return nil, fmt.Errorf("failed to build monthly pack: %v", err)
+		}
+	}
+
+	workbook.SetActiveSheet(0)
+	return workbook, nil
+}
+
+// packStyles creates the cell styles shared by the pack sheets
+func packStyles(workbook *excelize.File) (title, currency, percent int, err error) {
+	title, err = workbook.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+	if err != nil {
+		return
+	}
+	currency, err = workbook.NewStyle(&excelize.Style{NumFmt: 4}) // #,##0.00
+	if err != nil {
+		return
+	}
+	percent, err = workbook.NewStyle(&excelize.Style{NumFmt: 10}) // 0.00%
+	return
+}
+
+// writePackSummary fills the first sheet with the month's headline numbers
+func writePackSummary(workbook *excelize.File, pack monthlyPack) error {
+	// A new workbook starts with Sheet1
+	if err := workbook.SetSheetName("Sheet1", packSummarySheet); err != nil {
+		return err
+	}
+	title, currency, percent, err := packStyles(workbook)
+	if err != nil {
+		return err
+	}
+
+	var total, forecastTotal float64
+	for _, comparison := range pack.Forecasts {
+		total += comparison.Actual
+		forecastTotal += comparison.Forecast
+	}
+	overall := categoryForecast{Forecast: forecastTotal, Actual: total}
+	change := 0.0
+	if pack.PreviousTotal != 0 {
+		change = (total - pack.PreviousTotal) / pack.PreviousTotal
+	}
+
+	misses := 0
+	for _, comparison := range pack.Forecasts {
+		if math.Abs(comparison.Deviation()) > pack.MissThreshold {
+			misses++
+		}
+	}
+
+	rows := []struct {
+		label string
+		value any
+		style int
+	}{
+		{"Revenue", total, currency},
+		{"Previous month", pack.PreviousTotal, currency},
+		{"Change vs previous month", change, percent},
+		{"Forecast", forecastTotal, currency},
+		{"Deviation from forecast", overall.Deviation(), percent},
+		{"Categories missing forecast", misses, 0},
+		{"Generated", time.Now().UTC().Format("2006-01-02 15:04 MST"), 0},
+	}
+
+	sheet := packSummarySheet
+	if err := workbook.SetCellValue(sheet, "A1", "Monthly Sales Pack - "+pack.Month.Format("January 2006")); err != nil {
+		return err
+	}
+	if err := workbook.SetCellStyle(sheet, "A1", "A1", title); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		labelCell := fmt.Sprintf("A%d", i+3)
+		valueCell := fmt.Sprintf("B%d", i+3)
+		if err := workbook.SetCellValue(sheet, labelCell, row.label); err != nil {
+			return err
+		}
+		if err := workbook.SetCellValue(sheet, valueCell, row.value); err != nil {
+			return err
+		}
+		if row.style != 0 {
+			if err := workbook.SetCellStyle(sheet, valueCell, valueCell, row.style); err != nil {
+				return err
+			}
+		}
+	}
+	return workbook.SetColWidth(sheet, "A", "B", 28)
+}
+
+// writePackDaily writes the daily revenue per category as an Excel table
+func writePackDaily(workbook *excelize.File, pack monthlyPack) error {
+	sheet := packDailySheet
+	if _, err := workbook.NewSheet(sheet); err != nil {
+		return err
+	}
+	_, currency, _, err := packStyles(workbook)
+	if err != nil {
+		return err
+	}
+
+	header := append([]any{"Date"}, stringsToAny(pack.Categories)...)
+	header = append(header, "Total")
+	if err := workbook.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+
+	dates := make([]string, 0, len(pack.Daily))
+	for date := range pack.Daily {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for i, date := range dates {
+		day, _ := time.Parse("2006-01-02", date)
+		row := []any{day}
+		total := 0.0
+		for _, category := range pack.Categories {
+			row = append(row, pack.Daily[date][category])
+			total += pack.Daily[date][category]
+		}
+		row = append(row, total)
+
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := workbook.SetSheetRow(sheet, cell, &row); err != nil {
+			return err
+		}
+	}
+
+	lastColumn, _ := excelize.ColumnNumberToName(len(header))
+	lastRow := len(dates) + 1
+	dateStyle, err := workbook.NewStyle(&excelize.Style{NumFmt: 14}) // m/d/yy
+	if err != nil {
+		return err
+	}
+	if err := workbook.SetCellStyle(sheet, "A2", fmt.Sprintf("A%d", lastRow), dateStyle); err != nil {
+		return err
+	}
+	if err := workbook.SetCellStyle(sheet, "B2", fmt.Sprintf("%s%d", lastColumn, lastRow), currency); err != nil {
+		return err
+	}
+	if err := workbook.SetColWidth(sheet, "A", lastColumn, 16); err != nil {
+		return err
+	}
+	if err := workbook.SetPanes(sheet, &excelize.Panes{Freeze: true, XSplit: 1, YSplit: 1, TopLeftCell: "B2", ActivePane: "bottomRight"}); err != nil {
+		return err
+	}
+
+	return workbook.AddTable(sheet, &excelize.Table{
+		Range:     fmt.Sprintf("A1:%s%d", lastColumn, lastRow),
+		Name:      "DailyByCategory",
+		StyleName: "TableStyleMedium2",
+	})
+}
+
+// writePackForecast compares each category's forecast and actual revenue and
+// highlights misses beyond the threshold
+func writePackForecast(workbook *excelize.File, pack monthlyPack) error {
+	sheet := packForecastSheet
+	if _, err := workbook.NewSheet(sheet); err != nil {
+		return err
+	}
+	_, currency, percent, err := packStyles(workbook)
+	if err != nil {
+		return err
+	}
+
+	header := []any{"Category", "Forecast", "Actual", "Deviation", "Deviation %"}
+	if err := workbook.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+	for i, comparison := range pack.Forecasts {
+		row := []any{
+			comparison.CategoryName,
+			comparison.Forecast,
+			comparison.Actual,
+			comparison.Actual - comparison.Forecast,
+			comparison.Deviation(),
+		}
+		if err := workbook.SetSheetRow(sheet, fmt.Sprintf("A%d", i+2), &row); err != nil {
+			return err
+		}
+	}
+
+	lastRow := len(pack.Forecasts) + 1
+	if err := workbook.SetCellStyle(sheet, "B2", fmt.Sprintf("D%d", lastRow), currency); err != nil {
+		return err
+	}
+	if err := workbook.SetCellStyle(sheet, "E2", fmt.Sprintf("E%d", lastRow), percent); err != nil {
+		return err
+	}
+	if err := workbook.SetColWidth(sheet, "A", "E", 18); err != nil {
+		return err
+	}
+
+	if err := workbook.AddTable(sheet, &excelize.Table{
+		Range:     fmt.Sprintf("A1:E%d", lastRow),
+		Name:      "ForecastVsActual",
+		StyleName: "TableStyleLight9",
+	}); err != nil {
+		return err
+	}
+
+	// Red below the forecast, green above it
+	under, err := workbook.NewConditionalStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "9C0006"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"FFC7CE"}, Pattern: 1},
+	})
+	if err != nil {
+		return err
+	}
+	over, err := workbook.NewConditionalStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "006100"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"C6EFCE"}, Pattern: 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	threshold := strconv.FormatFloat(pack.MissThreshold, 'f', -1, 64)
+	if err := workbook.SetConditionalFormat(sheet, fmt.Sprintf("E2:E%d", lastRow), []excelize.ConditionalFormatOptions{
+		{Type: "cell", Criteria: "<", Format: &under, Value: "-" + threshold},
+		{Type: "cell", Criteria: ">", Format: &over, Value: threshold},
+	}); err != nil {
+		return err
+	}
+
+	note := fmt.Sprintf("Deviations beyond %.0f%% are highlighted. Forecasts use the %d months before.",
+		pack.MissThreshold*100, monthlyPackHistoryMonths)
+	return workbook.SetCellValue(sheet, fmt.Sprintf("A%d", lastRow+2), note)
+}
+
+// writePackCharts adds a line chart of daily revenue for every category
+func writePackCharts(workbook *excelize.File, pack monthlyPack) error {
+	sheet := packChartsSheet
+	if _, err := workbook.NewSheet(sheet); err != nil {
+		return err
+	}
+
+	lastRow := len(pack.Daily) + 1
+	dates := fmt.Sprintf("'%s'!$A$2:$A$%d", packDailySheet, lastRow)
+	for i, category := range pack.Categories {
+		column, _ := excelize.ColumnNumberToName(i + 2)
+
+		// Two charts per row, each 15 rows tall
+		anchor, _ := excelize.CoordinatesToCellName(1+(i%2)*9, 1+(i/2)*16)
+		err := workbook.AddChart(sheet, anchor, &excelize.Chart{
+			Type: excelize.Line,
+			Series: []excelize.ChartSeries{{
+				Name:       fmt.Sprintf("'%s'!$%s$1", packDailySheet, column),
+				Categories: dates,
+				Values:     fmt.Sprintf("'%s'!$%s$2:$%s$%d", packDailySheet, column, column, lastRow),
+			}},
+			Title:     []excelize.RichTextRun{{Text: category}},
+			Legend:    excelize.ChartLegend{Position: "none"},
+			Dimension: excelize.ChartDimension{Width: 520, Height: 300},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringsToAny converts strings to a row of cell values
+func stringsToAny(values []string) []any {
+	row := make([]any, len(values))
+	for i, value := range values {
+		row[i] = value
+	}
+	return row
+}
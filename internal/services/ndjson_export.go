@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/bokor/craft-demo/internal/chaos"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// ndjsonMIMEType is the media type of newline-delimited JSON
+const ndjsonMIMEType = "application/x-ndjson"
+
+// ndjsonFlushLines is the number of lines written between flushes of an
+// NDJSON response
+const ndjsonFlushLines = 1000
+
+// CategoryReportLine is a line of the category report in NDJSON: a
+// category's total in a period
+type CategoryReportLine struct {
+	Period string `json:"period"`
+	CategoryTotal
+}
+
+// CustomerTypeReportLine is a line of the new vs returning customers report
+// in NDJSON: a category's split in a period
+type CustomerTypeReportLine struct {
+	Period string `json:"period"`
+	CustomerTypeTotal
+}
+
+// wantsNDJSON reports whether the request asked for ?format=ndjson
+func wantsNDJSON(c echo.Context) bool {
+	return c.QueryParam("format") == "ndjson"
+}
+
+// ndjsonStream writes newline-delimited JSON to a response. Like eventStream,
+// the headers are written with the first line, so handlers can still respond
+// with an error until then.
+type ndjsonStream struct {
+	response *echo.Response
+	encoder  *json.Encoder
+	lines    int
+}
+
+// newNDJSONStream returns an NDJSON stream for the response
+func newNDJSONStream(c echo.Context) *ndjsonStream {
+	return &ndjsonStream{response: c.Response(), encoder: json.NewEncoder(c.Response())}
+}
+
+// started reports whether the headers have been written
+func (s *ndjsonStream) started() bool {
+	return s.response.Committed
+}
+
+// begin writes the headers unless they've been written
+func (s *ndjsonStream) begin() {
+	if s.started() {
+		return
+	}
+	header := s.response.Header()
+	header.Set(echo.HeaderContentType, ndjsonMIMEType)
+	// Keep nginx from buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	s.response.WriteHeader(http.StatusOK)
+}
+
+// write writes value as a line, flushing every ndjsonFlushLines lines
+func (s *ndjsonStream) write(value any) error {
+	s.begin()
+	if err := s.encoder.Encode(value); err != nil {
+		return err
+	}
+	s.lines++
+	if s.lines%ndjsonFlushLines == 0 {
+		s.response.Flush()
+	}
+	return nil
+}
+
+// finish ends a report stream. Before the first line, errors and empty
+// reports get their usual JSON responses; afterwards the status has been
+// sent, so an error is written as a last line in the error envelope.
+func (s *ndjsonStream) finish(c echo.Context, err error, zeroFill bool) error {
+	if err != nil {
+		log.Printf("Failed to stream sales report: %v", err)
+		if !s.started() {
+			return respondReportError(c, err)
+		}
+		return s.write(httperror.New(c, http.StatusInternalServerError, "Failed to query sales data"))
+	}
+	if !s.started() && !zeroFill {
+		return httperror.JSON(c, http.StatusNotFound, "No sales data found")
+	}
+	s.begin()
+	return nil
+}
+
+// periodWriter writes a period-keyed report to an NDJSON stream a period at a
+// time, from totals added in period order. With zero fill, periods without
+// totals are written with a zero total for every category, and categories
+// missing from a period get one, as in zeroFillReport.
+type periodWriter[T any] struct {
+	stream *ndjsonStream
+	line   func(key string, total T) any
+	name   func(T) string
+	zero   func(string) T
+
+	fill bool
+	// keys are the periods not yet written, and names the categories
+	keys  []string
+	names []string
+
+	key    string
+	totals []T
+}
+
+// zeroFill makes the writer fill in every period in keys and every category
+// in categories
+func (w *periodWriter[T]) zeroFill(keys []string, categories []Category) {
+	w.fill = true
+	w.keys = keys
+	for _, category := range categories {
+		w.names = append(w.names, category.Name)
+	}
+}
+
+// add adds a total of the period key, writing the previous period when key
+// starts a new one
+func (w *periodWriter[T]) add(key string, total T) error {
+	if len(w.totals) > 0 && key != w.key {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	w.key = key
+	w.totals = append(w.totals, total)
+	return nil
+}
+
+// close writes the last period and, with zero fill, the periods after it
+func (w *periodWriter[T]) close() error {
+	if len(w.totals) > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	if w.fill {
+		for _, key := range w.keys {
+			if err := w.writePeriod(key, nil); err != nil {
+				return err
+			}
+		}
+		w.keys = nil
+	}
+	return nil
+}
+
+// flush writes the current period, after the periods before it when zero
+// filling
+func (w *periodWriter[T]) flush() error {
+	defer func() { w.totals = w.totals[:0] }()
+	if w.fill {
+		for i, key := range w.keys {
+			if key != w.key {
+				continue
+			}
+			for _, before := range w.keys[:i] {
+				if err := w.writePeriod(before, nil); err != nil {
+					return err
+				}
+			}
+			w.keys = w.keys[i+1:]
+			break
+		}
+	}
+	return w.writePeriod(w.key, w.totals)
+}
+
+// writePeriod writes the totals of a period, zero-filled when filling
+func (w *periodWriter[T]) writePeriod(key string, totals []T) error {
+	if w.fill {
+		existing := make(map[string]T, len(totals))
+		for _, total := range totals {
+			existing[w.name(total)] = total
+		}
+		names := append([]string{}, w.names...)
+		for name := range existing {
+			if !slices.Contains(w.names, name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		totals = make([]T, 0, len(names))
+		for _, name := range names {
+			total, ok := existing[name]
+			if !ok {
+				total = w.zero(name)
+			}
+			totals = append(totals, total)
+		}
+	}
+
+	for _, total := range totals {
+		if err := w.stream.write(w.line(key, total)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamCategoryReport streams the category report as NDJSON, one line per
+// period and category, summing each period's daily totals as they're read
+// instead of building the report in memory
+func streamCategoryReport(c echo.Context, db *sql.DB, reportQuery SalesReportQuery, period string, zeroFill bool) error {
+	ctx := c.Request().Context()
+	stream := newNDJSONStream(c)
+	writer := &periodWriter[CategoryTotal]{
+		stream: stream,
+		line: func(key string, total CategoryTotal) any {
+			return CategoryReportLine{Period: key, CategoryTotal: total}
+		},
+		name: func(total CategoryTotal) string { return total.CategoryName },
+		zero: func(name string) CategoryTotal { return CategoryTotal{CategoryName: name} },
+	}
+	if zeroFill {
+		if err := zeroFillWriter(ctx, db, writer, reportQuery.StartDate, reportQuery.EndDate, period, reportQuery.Rollup); err != nil {
+			return stream.finish(c, err, zeroFill)
+		}
+	}
+
+	// Rows are ordered by date, so a period's totals are complete when a row
+	// of the next period is read
+	var key string
+	totals := make(map[string]CategoryTotal)
+	addPeriod := func() error {
+		names := make([]string, 0, len(totals))
+		for name := range totals {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if err := writer.add(key, totals[name].roundCents()); err != nil {
+				return err
+			}
+		}
+		clear(totals)
+		return nil
+	}
+
+	converter := currencyConverter(reportQuery)
+	currencies := newCurrencyCheck(reportQuery)
+	err := chaos.Inject(chaos.TargetDB)
+	if err == nil {
+		err = salesReportRepository(db).EachSalesByCategory(ctx, repository.SalesReportFilter{
+			StartDate:      reportQuery.StartDate,
+			EndDate:        reportQuery.EndDate,
+			IncludeDeleted: reportQuery.IncludeDeleted,
+			TimeZone:       reportQuery.TimeZone,
+			Statuses:       reportQuery.Statuses,
+			Rollup:         reportQuery.Rollup,
+		}, func(sales repository.CategorySales) error {
+			next := reportPeriodKey(reportPeriodStart(sales.Date, period), period)
+			if next != key {
+				if err := addPeriod(); err != nil {
+					return err
+				}
+				key = next
+			}
+			if err := currencies.add(sales.Currency); err != nil {
+				return err
+			}
+			amounts, err := convertCategorySales(ctx, converter, sales)
+			if err != nil {
+				return err
+			}
+			total := totals[sales.Category]
+			total.CategoryName = sales.Category
+			total.add(amounts)
+			totals[sales.Category] = total
+			return nil
+		})
+	}
+	if err == nil {
+		err = addPeriod()
+	}
+	if err == nil {
+		err = writer.close()
+	}
+	return stream.finish(c, err, zeroFill)
+}
+
+// streamCustomerTypeReport streams the new vs returning customers report as
+// NDJSON, one line per period and category as they're read
+func streamCustomerTypeReport(c echo.Context, db *sql.DB, startDate, endDate, period, unit string, zeroFill, rollup bool) error {
+	ctx := c.Request().Context()
+	stream := newNDJSONStream(c)
+	writer := &periodWriter[CustomerTypeTotal]{
+		stream: stream,
+		line: func(key string, total CustomerTypeTotal) any {
+			return CustomerTypeReportLine{Period: key, CustomerTypeTotal: total}
+		},
+		name: func(total CustomerTypeTotal) string { return total.CategoryName },
+		zero: func(name string) CustomerTypeTotal { return CustomerTypeTotal{CategoryName: name} },
+	}
+	if zeroFill {
+		if err := zeroFillWriter(ctx, db, writer, startDate, endDate, period, rollup); err != nil {
+			return stream.finish(c, err, zeroFill)
+		}
+	}
+
+	err := eachCustomerTypeTotal(ctx, db, startDate, endDate, period, unit, rollup, writer.add)
+	if err == nil {
+		err = writer.close()
+	}
+	return stream.finish(c, err, zeroFill)
+}
+
+// zeroFillWriter makes writer fill in every period from startDate to endDate
+// and every category, or top-level category with rollup
+func zeroFillWriter[T any](ctx context.Context, db *sql.DB, writer *periodWriter[T], startDate, endDate, period string, rollup bool) error {
+	keys, err := reportPeriodKeys(startDate, endDate, period)
+	if err != nil {
+		return err
+	}
+	categories, err := queryReportCategories(ctx, db, rollup)
+	if err != nil {
+		return err
+	}
+	writer.zeroFill(keys, categories)
+	return nil
+}
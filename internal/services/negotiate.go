@@ -0,0 +1,232 @@
+package services
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/bokor/craft-demo/internal/pb"
+	"github.com/labstack/echo/v4"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// protobufMIMEType is the media type of protobuf responses
+	protobufMIMEType = "application/x-protobuf"
+	// msgpackMIMEType is the media type of MessagePack responses
+	msgpackMIMEType = "application/msgpack"
+)
+
+// negotiatedTypes are the response media types in order of preference when
+// the client accepts several equally
+var negotiatedTypes = []string{echo.MIMEApplicationJSON, protobufMIMEType, msgpackMIMEType}
+
+// respondNegotiated writes value in the media type the client prefers
+// according to its Accept header: JSON by default, the protobuf message
+// built by toProto for application/x-protobuf, or value encoded as
+// MessagePack with the JSON field names for application/msgpack. Errors are
+// always JSON.
+func respondNegotiated(c echo.Context, status int, value any, toProto func() proto.Message) error {
+	varyOnAccept(c)
+
+	switch preferredMediaType(c.Request().Header.Get(echo.HeaderAccept), negotiatedTypes) {
+	case protobufMIMEType:
+		data, err := proto.Marshal(toProto())
+		if err != nil {
+			return err
+		}
+		return c.Blob(status, protobufMIMEType, data)
+	case msgpackMIMEType:
+		data, err := marshalMsgpack(value)
+		if err != nil {
+			return err
+		}
+		return c.Blob(status, msgpackMIMEType, data)
+	default:
+		return c.JSON(status, value)
+	}
+}
+
+// marshalMsgpack encodes value as MessagePack using its json tags, so the
+// keys match the JSON response
+func marshalMsgpack(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := msgpack.NewEncoder(&buf)
+	encoder.SetCustomStructTag("json")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// preferredMediaType returns the offer with the highest quality in an Accept
+// header, preferring exact matches over wildcards at equal quality, or the
+// first offer when nothing matches
+func preferredMediaType(accept string, offers []string) string {
+	best, bestQuality, bestSpecificity := offers[0], 0.0, 0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, quality := parseAcceptPart(part)
+		for _, offer := range offers {
+			specificity := acceptSpecificity(mediaType, offer)
+			if specificity == 0 || quality <= 0 {
+				continue
+			}
+			if quality > bestQuality || (quality == bestQuality && specificity > bestSpecificity) {
+				best, bestQuality, bestSpecificity = offer, quality, specificity
+			}
+		}
+	}
+	return best
+}
+
+// parseAcceptPart splits an Accept header entry into its media type and
+// quality (q, default 1)
+func parseAcceptPart(part string) (string, float64) {
+	params := strings.Split(part, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+	quality := 1.0
+	for _, param := range params[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok && strings.EqualFold(key, "q") {
+			if q, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = q
+			}
+		}
+	}
+	return mediaType, quality
+}
+
+// acceptSpecificity reports how an accepted media type covers the offer: 3
+// for an exact match, 2 for a type/* wildcard, 1 for */*, and 0 otherwise
+func acceptSpecificity(accepted, offer string) int {
+	switch {
+	// application/protobuf and application/x-msgpack are common aliases
+	case accepted == offer,
+		accepted == "application/protobuf" && offer == protobufMIMEType,
+		accepted == "application/x-msgpack" && offer == msgpackMIMEType:
+		return 3
+	case accepted == "*/*":
+		return 1
+	}
+	if prefix, ok := strings.CutSuffix(accepted, "/*"); ok && strings.HasPrefix(offer, prefix+"/") {
+		return 2
+	}
+	return 0
+}
+
+// categoryReportProto converts the sales report by category
+func categoryReportProto(report map[string][]CategoryTotal) proto.Message {
+	message := &pb.SalesReportByCategory{}
+	for _, date := range sortedPeriods(report) {
+		day := &pb.CategoryReportDay{Date: date}
+		for _, total := range report[date] {
+			day.Categories = append(day.Categories, &pb.CategoryTotal{
+				CategoryName:      total.CategoryName,
+				TotalAmount:       total.TotalAmount,
+				GrossSales:        total.GrossSales,
+				Discounts:         total.Discounts,
+				Refunds:           total.Refunds,
+				UnitsSold:         int64(total.UnitsSold),
+				Transactions:      int64(total.Transactions),
+				AverageOrderValue: total.AverageOrderValue,
+			})
+		}
+		message.Days = append(message.Days, day)
+	}
+	return message
+}
+
+// customerTypeReportProto converts the new vs returning customer report
+func customerTypeReportProto(report map[string][]CustomerTypeTotal) proto.Message {
+	message := &pb.SalesReportByCustomerType{}
+	for _, period := range sortedPeriods(report) {
+		entry := &pb.CustomerTypeReportPeriod{Period: period}
+		for _, total := range report[period] {
+			entry.Categories = append(entry.Categories, &pb.CustomerTypeTotal{
+				CategoryName:       total.CategoryName,
+				NewAmount:          total.NewAmount,
+				ReturningAmount:    total.ReturningAmount,
+				NewCustomers:       int64(total.NewCustomers),
+				ReturningCustomers: int64(total.ReturningCustomers),
+			})
+		}
+		message.Periods = append(message.Periods, entry)
+	}
+	return message
+}
+
+// forecastResponseProto converts a forecast response
+func forecastResponseProto(response ForecastResponse) *pb.ForecastResponse {
+	message := &pb.ForecastResponse{
+		TimePeriod:  response.TimePeriod,
+		Message:     response.Message,
+		RawResponse: response.RawResponse,
+		ForecastId:  response.ForecastID,
+		Meta: &pb.ForecastMeta{
+			Source:         response.Meta.Source,
+			Provider:       response.Meta.Provider,
+			Model:          response.Meta.Model,
+			PromptVersion:  response.Meta.PromptVersion,
+			FallbackReason: response.Meta.FallbackReason,
+			DurationMs:     response.Meta.DurationMs,
+			CacheHit:       response.Meta.CacheHit,
+			Method:         response.Meta.Method,
+			TradingDays:    response.Meta.TradingDays,
+			LlmStatus:      response.Meta.LLMStatus,
+		},
+	}
+	if demand := response.Meta.Demand; demand != nil {
+		message.Meta.Demand = &pb.DemandPattern{
+			Adi:          demand.ADI,
+			Cv2:          demand.CV2,
+			ZeroShare:    demand.ZeroShare,
+			Class:        demand.Class,
+			Intermittent: demand.Intermittent,
+			Method:       string(demand.Method),
+		}
+	}
+	if usage := response.Meta.Usage; usage != nil {
+		message.Meta.Usage = &pb.LLMUsage{
+			PromptTokens:     int32(usage.PromptTokens),
+			CompletionTokens: int32(usage.CompletionTokens),
+			TotalTokens:      int32(usage.TotalTokens),
+			CostUsd:          usage.CostUSD,
+		}
+	}
+	for _, repair := range response.Meta.Repairs {
+		message.Meta.Repairs = append(message.Meta.Repairs, &pb.ForecastRepair{Period: repair.Period, Reason: repair.Reason})
+	}
+	for _, outlier := range response.Outliers {
+		message.Outliers = append(message.Outliers, &pb.OutlierPoint{
+			Period:  outlier.Period,
+			Total:   outlier.Total,
+			Lower:   outlier.Lower,
+			Upper:   outlier.Upper,
+			Treated: outlier.Treated,
+		})
+	}
+	for _, point := range response.Imputed {
+		message.Imputed = append(message.Imputed, &pb.TimeSeriesPoint{Period: point.Period, Total: point.Total})
+	}
+	message.Forecast = forecastPointsProto(response.Forecast)
+	message.Baseline = forecastPointsProto(response.Baseline)
+	return message
+}
+
+// forecastPointsProto converts forecast points with their bands
+func forecastPointsProto(points []TimeSeriesPoint) []*pb.TimeSeriesPoint {
+	var result []*pb.TimeSeriesPoint
+	for _, point := range points {
+		entry := &pb.TimeSeriesPoint{Period: point.Period, Total: point.Total}
+		for _, interval := range point.Intervals {
+			entry.Intervals = append(entry.Intervals, &pb.ConfidenceInterval{
+				Level: int32(interval.Level),
+				Lower: interval.Lower,
+				Upper: interval.Upper,
+			})
+		}
+		result = append(result, entry)
+	}
+	return result
+}
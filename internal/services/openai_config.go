@@ -0,0 +1,328 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/bokor/craft-demo/internal/chaos"
+)
+
+// Default chat completions endpoints and models
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOllamaBaseURL = "http://localhost:11434/v1"
+	defaultOllamaModel   = "llama3"
+)
+
+// openAIConfig describes how to reach the chat completions API. It supports
+// the public OpenAI API, OpenAI-compatible proxies, Azure OpenAI, and Ollama.
+type openAIConfig struct {
+	// BaseURL is the API root, e.g. https://api.openai.com/v1 or
+	// https://my-resource.openai.azure.com
+	BaseURL string
+	// Organization is sent as the OpenAI-Organization header when set
+	Organization string
+	// Azure routes requests to deployments and authenticates with api-key
+	Azure bool
+	// APIVersion is the Azure api-version query parameter
+	APIVersion string
+	// Deployments maps model names to Azure deployment names; models without
+	// an entry use the model name as the deployment name
+	Deployments map[string]string
+	// Ollama talks to a local Ollama server, which needs no API key
+	Ollama bool
+	// Model is the model forecasts are requested from
+	Model string
+}
+
+// loadOpenAIConfig reads the OpenAI settings from the environment:
+//
+//	OPENAI_BASE_URL       API root (defaults to https://api.openai.com/v1, or
+//	                      http://localhost:11434/v1 for ollama)
+//	OPENAI_ORGANIZATION   organization ID sent with every request
+//	OPENAI_API_TYPE       "openai" (default), "azure", or "ollama"
+//	OPENAI_MODEL          model (defaults to gpt-3.5-turbo, or llama3 for ollama)
+//	OPENAI_API_VERSION    Azure api-version (defaults to 2024-02-01)
+//	OPENAI_DEPLOYMENTS    Azure deployments as model=deployment pairs,
+//	                      e.g. gpt-3.5-turbo=forecast-35,gpt-4=forecast-4
+func loadOpenAIConfig() (openAIConfig, error) {
+	config := openAIConfig{
+		BaseURL:      defaultOpenAIBaseURL,
+		Organization: os.Getenv("OPENAI_ORGANIZATION"),
+		APIVersion:   "2024-02-01",
+		Deployments:  make(map[string]string),
+		Model:        forecastModel,
+	}
+
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		config.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	if apiVersion := os.Getenv("OPENAI_API_VERSION"); apiVersion != "" {
+		config.APIVersion = apiVersion
+	}
+
+	switch apiType := strings.ToLower(os.Getenv("OPENAI_API_TYPE")); apiType {
+	case "", "openai":
+	case "azure":
+		config.Azure = true
+		if os.Getenv("OPENAI_BASE_URL") == "" {
+			return config, fmt.Errorf("OPENAI_BASE_URL is required when OPENAI_API_TYPE is azure")
+		}
+	case "ollama":
+		config.Ollama = true
+		config.Model = defaultOllamaModel
+		if os.Getenv("OPENAI_BASE_URL") == "" {
+			config.BaseURL = defaultOllamaBaseURL
+		}
+	default:
+		return config, fmt.Errorf("invalid OPENAI_API_TYPE %q, use openai, azure, or ollama", apiType)
+	}
+	if model := os.Getenv("OPENAI_MODEL"); model != "" {
+		config.Model = model
+	}
+
+	if deployments := os.Getenv("OPENAI_DEPLOYMENTS"); deployments != "" {
+		for _, pair := range strings.Split(deployments, ",") {
+			model, deployment, ok := strings.Cut(pair, "=")
+			model, deployment = strings.TrimSpace(model), strings.TrimSpace(deployment)
+			if !ok || model == "" || deployment == "" {
+				return config, fmt.Errorf("invalid OPENAI_DEPLOYMENTS entry %q, use model=deployment", pair)
+			}
+			config.Deployments[model] = deployment
+		}
+	}
+
+	return config, nil
+}
+
+// openAIProvider returns azure, ollama, or openai depending on OPENAI_API_TYPE
+func openAIProvider() string {
+	switch apiType := strings.ToLower(os.Getenv("OPENAI_API_TYPE")); apiType {
+	case "azure", "ollama":
+		return apiType
+	}
+	return "openai"
+}
+
+// chatCompletionsURL returns the chat completions endpoint for a model
+func (c openAIConfig) chatCompletionsURL(model string) string {
+	if !c.Azure {
+		return c.BaseURL + "/chat/completions"
+	}
+
+	deployment, ok := c.Deployments[model]
+	if !ok {
+		deployment = model
+	}
+	return fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		c.BaseURL, url.PathEscape(deployment), url.QueryEscape(c.APIVersion))
+}
+
+// setHeaders sets the authentication and organization headers on a
+// request. No authentication is sent without an API key.
+func (c openAIConfig) setHeaders(req *http.Request, apiKey string) {
+	switch {
+	case apiKey == "":
+	case c.Azure:
+		req.Header.Set("api-key", apiKey)
+	default:
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	if c.Organization != "" {
+		req.Header.Set("OpenAI-Organization", c.Organization)
+	}
+}
+
+// modelsURL returns the endpoint that lists the available models
+func (c openAIConfig) modelsURL() string {
+	if !c.Azure {
+		return c.BaseURL + "/models"
+	}
+	return fmt.Sprintf("%s/openai/models?api-version=%s", c.BaseURL, url.QueryEscape(c.APIVersion))
+}
+
+// openAIClient is the LLMClient for the OpenAI chat completions API and
+// Azure OpenAI
+type openAIClient struct {
+	apiKey string
+	config openAIConfig
+}
+
+// newOpenAIClient returns an OpenAI client configured from the environment
+func newOpenAIClient() (openAIClient, error) {
+	config, err := loadOpenAIConfig()
+	if err != nil {
+		return openAIClient{}, err
+	}
+	apiKey, err := getOpenAIAPIKey(config)
+	if err != nil {
+		return openAIClient{}, err
+	}
+	return openAIClient{apiKey: apiKey, config: config}, nil
+}
+
+func (c openAIClient) Provider() string {
+	switch {
+	case c.config.Azure:
+		return "azure"
+	case c.config.Ollama:
+		return "ollama"
+	}
+	return llmProviderOpenAI
+}
+
+func (c openAIClient) Model() string {
+	return c.config.Model
+}
+
+// Chat sends a request to the ChatGPT API, giving up when ctx is done
+func (c openAIClient) Chat(ctx context.Context, request ChatGPTRequest) (*ChatGPTResponse, error) {
+	resp, err := c.post(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response ChatGPTResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// openAIStreamOptions asks for the token usage at the end of a stream
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIStreamChunk is a chat completion chunk of a streamed reply
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *TokenUsage `json:"usage"`
+}
+
+// ChatStream sends a streaming request to the ChatGPT API and returns the
+// assembled reply
+func (c openAIClient) ChatStream(ctx context.Context, request ChatGPTRequest, onContent func(string)) (*ChatGPTResponse, error) {
+	request.Stream = true
+	request.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	resp, err := c.post(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	response := &ChatGPTResponse{}
+	var content strings.Builder
+	err = readEvents(resp.Body, func(_, data string) error {
+		if data == "[DONE]" {
+			return errEndOfEvents
+		}
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid ChatGPT stream chunk: %v", err)
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != "" {
+				content.WriteString(choice.Delta.Content)
+				onContent(choice.Delta.Content)
+			}
+		}
+		if chunk.Usage != nil {
+			response.Usage = chunk.Usage
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if content.Len() > 0 {
+		response.Choices = []Choice{{Message: Message{Role: "assistant", Content: content.String()}}}
+	}
+	return response, nil
+}
+
+// post sends a chat completions request and returns the response when its
+// status is OK. The caller closes its body.
+func (c openAIClient) post(ctx context.Context, request ChatGPTRequest) (*http.Response, error) {
+	if err := chaos.Inject(chaos.TargetLLM); err != nil {
+		return nil, err
+	}
+
+	if request.Model == "" {
+		request.Model = c.Model()
+	}
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Log the request for debugging (only first 200 chars to avoid logging sensitive data)
+	requestPreview := string(jsonData)
+	if len(requestPreview) > 200 {
+		requestPreview = requestPreview[:200] + "..."
+	}
+	log.Printf("Sending request to ChatGPT: %s", requestPreview)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.config.chatCompletionsURL(request.Model), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	c.config.setHeaders(req, c.apiKey)
+	req.Header.Set("User-Agent", "CraftDemo/1.0")
+
+	// The caller's context bounds the request instead of a client timeout
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Log response status for debugging
+	log.Printf("ChatGPT API response status: %d", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		apiErr := readOpenAIError(resp)
+		log.Printf("ChatGPT API error response: status=%d type=%q code=%q retryable=%t message=%q",
+			apiErr.StatusCode, apiErr.Type, apiErr.Code, apiErr.Retryable, apiErr.Message)
+		return nil, apiErr
+	}
+
+	return resp, nil
+}
+
+func (c openAIClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.config.modelsURL(), nil)
+	if err != nil {
+		return err
+	}
+	c.config.setHeaders(req, c.apiKey)
+	req.Header.Set("User-Agent", "CraftDemo/1.0")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("models endpoint: %w", readOpenAIError(resp))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxOpenAIErrorBody bounds how much of an error response is read
+const maxOpenAIErrorBody = 64 << 10
+
+// OpenAIError is an error response of the OpenAI API or a compatible one.
+// Callers can tell error classes apart with errors.As, e.g. to retry only
+// retryable errors.
+type OpenAIError struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+	// Type and Code classify the error as the API reports it, e.g. type
+	// requests and code rate_limit_exceeded, or type insufficient_quota.
+	// Either may be empty.
+	Type string
+	Code string
+	// Message is the API's description, or the start of the body when it
+	// isn't an OpenAI error object
+	Message string
+	// Retryable reports whether the same request may succeed later: rate
+	// limits other than an exhausted quota, timeouts, and server errors
+	Retryable bool
+}
+
+func (e *OpenAIError) Error() string {
+	var summary string
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		summary = "OpenAI API authentication failed - check your API key"
+	case http.StatusNotFound:
+		summary = "OpenAI API endpoint not found - check API version"
+	case http.StatusTooManyRequests:
+		summary = "OpenAI API rate limit exceeded"
+		if !e.Retryable {
+			summary = "OpenAI API quota exceeded"
+		}
+	case http.StatusInternalServerError:
+		summary = "OpenAI API server error"
+	default:
+		summary = fmt.Sprintf("OpenAI API returned status: %d", e.StatusCode)
+	}
+
+	if class := e.class(); class != "" {
+		summary += " (" + class + ")"
+	}
+	if e.Message != "" {
+		summary += ": " + e.Message
+	}
+	return summary
+}
+
+// class returns the error's code, type, or both as code/type
+func (e *OpenAIError) class() string {
+	switch {
+	case e.Code != "" && e.Type != "" && e.Code != e.Type:
+		return e.Code + "/" + e.Type
+	case e.Code != "":
+		return e.Code
+	default:
+		return e.Type
+	}
+}
+
+// openAIErrorBody is the body of an OpenAI API error response
+type openAIErrorBody struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		// Code is a string for OpenAI and Azure, but some compatible servers
+		// send a number
+		Code json.RawMessage `json:"code"`
+	} `json:"error"`
+}
+
+// readOpenAIError reads an error response into an OpenAIError
+func readOpenAIError(resp *http.Response) *OpenAIError {
+	apiErr := &OpenAIError{StatusCode: resp.StatusCode}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxOpenAIErrorBody))
+	if err != nil {
+		apiErr.Message = fmt.Sprintf("failed to read error response: %v", err)
+	}
+
+	var parsed openAIErrorBody
+	if err := json.Unmarshal(body, &parsed); err == nil && (parsed.Error.Message != "" || parsed.Error.Type != "") {
+		apiErr.Message = parsed.Error.Message
+		apiErr.Type = parsed.Error.Type
+		apiErr.Code = openAIErrorCode(parsed.Error.Code)
+	} else if text := strings.TrimSpace(string(body)); text != "" {
+		if len(text) > 200 {
+			text = text[:200] + "..."
+		}
+		apiErr.Message = text
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		// An exhausted quota won't come back by waiting
+		apiErr.Retryable = apiErr.Code != "insufficient_quota" && apiErr.Type != "insufficient_quota"
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusConflict, resp.StatusCode >= 500:
+		apiErr.Retryable = true
+	}
+	return apiErr
+}
+
+// openAIErrorCode returns an error code sent as a string or a number, or ""
+// for null
+func openAIErrorCode(raw json.RawMessage) string {
+	var code string
+	if err := json.Unmarshal(raw, &code); err == nil {
+		return code
+	}
+	var number json.Number
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return number.String()
+	}
+	return ""
+}
@@ -0,0 +1,526 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bokor/craft-demo/internal/batch"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// Product status values stored in products.status
+const (
+	productStatusArchived = 0
+	productStatusActive   = 1
+)
+
+// Product represents a product sold in sale transactions
+type Product struct {
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	CategoryID  int     `json:"category_id"`
+	CompanyID   int     `json:"company_id"`
+	SKU         string  `json:"sku"`
+	Quantity    float64 `json:"quantity"`
+	Archived    bool    `json:"archived"`
+}
+
+// ProductRequest represents the request body for creating or updating a product
+type ProductRequest struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+	CategoryID  int     `json:"category_id"`
+	CompanyID   int     `json:"company_id"`
+	SKU         string  `json:"sku"`
+	Quantity    float64 `json:"quantity"`
+}
+
+// ProductCategoryRequest represents the request body for reassigning a product's category
+type ProductCategoryRequest struct {
+	CategoryID int `json:"category_id"`
+}
+
+// errProductNotFound is returned when a product ID doesn't exist
+var errProductNotFound = &notFoundError{resource: "Product"}
+
+const productColumns = "id, name, COALESCE(description, ''), price, category_id, company_id, COALESCE(sku, ''), quantity, status"
+
+// ListProducts handles the API request for listing products
+// @Summary List products
+// @Description Returns products ordered by name, optionally filtered by category and by q, which matches text in the name or SKU (case-insensitive). Archived products are excluded unless include_archived is set.
+// @Tags products
+// @Produce json
+// @Param category_id query int false "Only products in this category"
+// @Param q query string false "Only products whose name or SKU contains this text"
+// @Param include_archived query bool false "Include archived products (defaults to false)"
+// @Success 200 {array} Product "Products"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid filter"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /products [get]
+func ListProducts(c echo.Context) error {
+	ctx := c.Request().Context()
+	categoryID := 0
+	if value := c.QueryParam("category_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid category_id")
+		}
+		categoryID = id
+	}
+	search := strings.TrimSpace(c.QueryParam("q"))
+	includeArchived := c.QueryParam("include_archived") == "true"
+
+	return withDB(c, func(db *sql.DB) error {
+		rows, err := db.QueryContext(
+			ctx, "SELECT "+productColumns+` FROM products
+			WHERE ($1 = 0 OR category_id = $1) AND ($2 OR status <> $3)
+				AND (STRPOS(LOWER(name), LOWER($4)) > 0 OR STRPOS(LOWER(COALESCE(sku, '')), LOWER($4)) > 0)
+			ORDER BY name`,
+			categoryID, includeArchived, productStatusArchived, search,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to query products: %v", err)
+		}
+		defer rows.Close()
+
+		products := []Product{}
+		for rows.Next() {
+			product, err := scanProduct(rows)
+			if err != nil {
+				return err
+			}
+			products = append(products, *product)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating rows: %v", err)
+		}
+
+		return c.JSON(http.StatusOK, products)
+	})
+}
+
+// GetProduct handles the API request for a single product
+// @Summary Get a product
+// @Description Returns a single product, including archived ones
+// @Tags products
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} Product "Product"
+// @Failure 404 {object} httperror.Envelope "Product not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /products/{id} [get]
+func GetProduct(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid product id")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		product, err := queryProduct(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, product)
+	})
+}
+
+// CreateProduct handles the API request for creating a product
+// @Summary Create a product
+// @Description Creates an active product. The category must exist and be active, and the SKU must be unique.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param request body ProductRequest true "Product to create"
+// @Success 201 {object} Product "Created product"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid product"
+// @Failure 409 {object} httperror.Envelope "Unknown or archived category, or duplicate SKU"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /products [post]
+func CreateProduct(c echo.Context) error {
+	ctx := c.Request().Context()
+	request, err := bindProductRequest(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		if err := validateProduct(ctx, db, 0, request); err != nil {
+			return err
+		}
+
+		var id int
+		err := db.QueryRowContext(
+			ctx, `INSERT INTO products (name, description, price, category_id, company_id, sku, quantity, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+			request.Name, request.Description, request.Price, request.CategoryID,
+			request.CompanyID, request.SKU, request.Quantity, productStatusActive,
+		).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("failed to insert product: %v", err)
+		}
+
+		product, err := queryProduct(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, product)
+	})
+}
+
+// UpdateProduct handles the API request for updating a product
+// @Summary Update a product
+// @Description Updates a product. If the category changes, the warehouse rows of every transaction containing the product are re-aggregated in the same transaction.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param request body ProductRequest true "Updated product"
+// @Success 200 {object} Product "Updated product"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid product"
+// @Failure 404 {object} httperror.Envelope "Product not found"
+// @Failure 409 {object} httperror.Envelope "Unknown or archived category, or duplicate SKU"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /products/{id} [put]
+func UpdateProduct(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid product id")
+	}
+
+	request, err := bindProductRequest(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		if err := validateProduct(ctx, db, id, request); err != nil {
+			return err
+		}
+
+		product, err := updateProduct(ctx, db, id, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(
+				ctx, `UPDATE products SET name = $1, description = $2, price = $3, category_id = $4,
+				company_id = $5, sku = $6, quantity = $7 WHERE id = $8`,
+				request.Name, request.Description, request.Price, request.CategoryID,
+				request.CompanyID, request.SKU, request.Quantity, id,
+			)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, product)
+	})
+}
+
+// UpdateProductCategory handles the API request for moving a product to another category
+// @Summary Reassign a product's category
+// @Description Moves a product to another category and re-aggregates the warehouse rows of every transaction containing it, so reports reflect the new category
+// @Tags products
+// @Accept json
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Param request body ProductCategoryRequest true "New category"
+// @Success 200 {object} Product "Updated product"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid category"
+// @Failure 404 {object} httperror.Envelope "Product not found"
+// @Failure 409 {object} httperror.Envelope "Unknown or archived category"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /products/{id}/category [put]
+func UpdateProductCategory(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid product id")
+	}
+
+	var request ProductCategoryRequest
+	if err := c.Bind(&request); err != nil || request.CategoryID <= 0 {
+		return httperror.JSON(c, http.StatusBadRequest, "A valid category_id is required")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		if err := checkCategoryActive(ctx, db, request.CategoryID); err != nil {
+			return err
+		}
+
+		product, err := updateProduct(ctx, db, id, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "UPDATE products SET category_id = $1 WHERE id = $2", request.CategoryID, id)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, product)
+	})
+}
+
+// ArchiveProduct handles the API request for archiving a product
+// @Summary Archive a product
+// @Description Archives a product so it is hidden from product lists. Products are never hard deleted because sale transactions reference them; archived products keep their history in reports and can be restored.
+// @Tags products
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Success 204 "Product archived"
+// @Failure 404 {object} httperror.Envelope "Product not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /products/{id} [delete]
+func ArchiveProduct(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid product id")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		result, err := db.ExecContext(ctx, "UPDATE products SET status = $1 WHERE id = $2", productStatusArchived, id)
+		if err != nil {
+			return fmt.Errorf("failed to archive product: %v", err)
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return errProductNotFound
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+}
+
+// RestoreProduct handles the API request for restoring an archived product
+// @Summary Restore a product
+// @Description Makes an archived product active again. Its category must be active.
+// @Tags products
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Product ID"
+// @Success 200 {object} Product "Restored product"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid product"
+// @Failure 404 {object} httperror.Envelope "Product not found"
+// @Failure 409 {object} httperror.Envelope "Category is archived"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /products/{id}/restore [post]
+func RestoreProduct(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid product id")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		product, err := queryProduct(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		if err := checkCategoryActive(ctx, db, product.CategoryID); err != nil {
+			return err
+		}
+
+		if _, err := db.ExecContext(ctx, "UPDATE products SET status = $1 WHERE id = $2", productStatusActive, id); err != nil {
+			return fmt.Errorf("failed to restore product: %v", err)
+		}
+		product.Archived = false
+		return c.JSON(http.StatusOK, product)
+	})
+}
+
+// updateProduct applies an update to a product and, if its category changed,
+// re-aggregates the DW rows of the transactions containing it in the same
+// transaction so reports never disagree with the product's category
+func updateProduct(ctx context.Context, db *sql.DB, id int, update func(tx *sql.Tx) error) (*Product, error) {
+	before, err := queryProduct(ctx, db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := update(tx); err != nil {
+		return nil, fmt.Errorf("failed to update product: %v", err)
+	}
+
+	var categoryID int
+	if err := tx.QueryRowContext(ctx, "SELECT category_id FROM products WHERE id = $1", id).Scan(&categoryID); err != nil {
+		return nil, fmt.Errorf("failed to query product category: %v", err)
+	}
+
+	if categoryID != before.CategoryID {
+		transactionIDs, err := queryProductTransactionIDs(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := batch.RegenerateTransactions(tx, transactionIDs); err != nil {
+			return nil, fmt.Errorf("failed to re-aggregate sales totals: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return queryProduct(ctx, db, id)
+}
+
+// queryProductTransactionIDs returns the sale transactions containing a product
+func queryProductTransactionIDs(ctx context.Context, tx *sql.Tx, productID int) ([]int, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT sale_transaction_id FROM sale_transaction_items WHERE product_id = $1", productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product transactions: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return ids, nil
+}
+
+func bindProductRequest(c echo.Context) (ProductRequest, error) {
+	var request ProductRequest
+	if err := c.Bind(&request); err != nil {
+		return request, fmt.Errorf("Invalid request format")
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	request.SKU = strings.TrimSpace(request.SKU)
+	switch {
+	case request.Name == "":
+		return request, fmt.Errorf("Product name is required")
+	case request.SKU == "":
+		return request, fmt.Errorf("Product sku is required")
+	case request.Price < 0:
+		return request, fmt.Errorf("Product price must not be negative")
+	case request.Quantity < 0:
+		return request, fmt.Errorf("Product quantity must not be negative")
+	case request.CategoryID <= 0:
+		return request, fmt.Errorf("Product category_id is required")
+	case request.CompanyID <= 0:
+		return request, fmt.Errorf("Product company_id is required")
+	}
+
+	return request, nil
+}
+
+// validateProduct checks the category exists and the SKU is unique for a
+// product being created (id 0) or updated. Products can only be added to
+// active categories, but keep an archived one they are already in.
+func validateProduct(ctx context.Context, db *sql.DB, id int, request ProductRequest) error {
+	check := checkCategoryActive
+	if id != 0 {
+		product, err := queryProduct(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		if product.CategoryID == request.CategoryID {
+			check = checkCategoryExists
+		}
+	}
+
+	if err := check(ctx, db, request.CategoryID); err != nil {
+		return err
+	}
+
+	var exists bool
+	err := db.QueryRowContext(
+		ctx, "SELECT EXISTS (SELECT 1 FROM products WHERE sku = $1 AND id <> $2)",
+		request.SKU, id,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check product sku: %v", err)
+	}
+	if exists {
+		return &conflictError{message: "A product with this sku already exists"}
+	}
+
+	return nil
+}
+
+// checkCategoryExists reports an unknown category as a conflict rather than
+// a missing product
+func checkCategoryExists(ctx context.Context, db *sql.DB, categoryID int) error {
+	_, err := queryCategory(ctx, db, categoryID)
+	if errors.Is(err, errCategoryNotFound) {
+		return &conflictError{message: "Category does not exist"}
+	}
+	return err
+}
+
+// checkCategoryActive is checkCategoryExists for categories taking new
+// products, which mustn't be archived
+func checkCategoryActive(ctx context.Context, db *sql.DB, categoryID int) error {
+	category, err := queryCategory(ctx, db, categoryID)
+	if errors.Is(err, errCategoryNotFound) {
+		return &conflictError{message: "Category does not exist"}
+	}
+	if err != nil {
+		return err
+	}
+	if category.Archived {
+		return &conflictError{message: "Category is archived"}
+	}
+	return nil
+}
+
+func queryProduct(ctx context.Context, db *sql.DB, id int) (*Product, error) {
+	product, err := scanProduct(db.QueryRowContext(ctx, "SELECT "+productColumns+" FROM products WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errProductNotFound
+	}
+	return product, err
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProduct(row rowScanner) (*Product, error) {
+	var (
+		product Product
+		status  float64
+	)
+	err := row.Scan(
+		&product.ID, &product.Name, &product.Description, &product.Price, &product.CategoryID,
+		&product.CompanyID, &product.SKU, &product.Quantity, &status,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan product: %v", err)
+	}
+
+	product.Archived = int(status) == productStatusArchived
+	return &product, nil
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/deadline"
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// PromotionImpactRequest represents the request structure for promotion impact analysis
+type PromotionImpactRequest struct {
+	CategoryID int    `json:"categoryId"`
+	StartDate  string `json:"startDate"`
+	EndDate    string `json:"endDate"`
+	// TrainingDays is the number of days before the promotion used to build
+	// the counterfactual (defaults to 90)
+	TrainingDays int `json:"trainingDays,omitempty"`
+}
+
+// PromotionImpactSummary is the structured summary requested from the LLM
+type PromotionImpactSummary struct {
+	Summary        string   `json:"summary"`
+	Drivers        []string `json:"drivers"`
+	Confidence     string   `json:"confidence"`
+	Recommendation string   `json:"recommendation"`
+}
+
+// PromotionImpactResponse represents the response from the promotion impact analysis
+type PromotionImpactResponse struct {
+	CategoryName   string                  `json:"categoryName"`
+	Actual         []TimeSeriesPoint       `json:"actual"`
+	Counterfactual []TimeSeriesPoint       `json:"counterfactual"`
+	ActualTotal    float64                 `json:"actualTotal"`
+	BaselineTotal  float64                 `json:"baselineTotal"`
+	Lift           float64                 `json:"lift"`
+	LiftPercent    float64                 `json:"liftPercent"`
+	Analysis       *PromotionImpactSummary `json:"analysis,omitempty"`
+	Message        string                  `json:"message"`
+}
+
+// AnalyzePromotionImpact handles the API request for promotion impact analysis
+// @Summary Analyze the sales impact of a promotion
+// @Description Compares actual daily sales of a category during a promotion window with a counterfactual forecast trained on the days before it, and asks ChatGPT for a structured summary of the lift. The numeric lift is returned even if ChatGPT is unavailable.
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Param request body PromotionImpactRequest true "Promotion window and category"
+// @Success 200 {object} PromotionImpactResponse "Actual vs counterfactual sales with lift and analysis"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid data"
+// @Failure 404 {object} httperror.Envelope "Category not found or no training data"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Failure 504 {object} PromotionImpactResponse "Deadline exceeded - lift without analysis"
+// @Security BearerAuth
+// @Router /sales/promotions/impact [post]
+func AnalyzePromotionImpact(c echo.Context) error {
+	var request PromotionImpactRequest
+	if err := c.Bind(&request); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid request format")
+	}
+
+	start, err := time.Parse("2006-01-02", request.StartDate)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid startDate format. Use YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", request.EndDate)
+	if err != nil || end.Before(start) {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid endDate. Use YYYY-MM-DD on or after startDate")
+	}
+	if request.TrainingDays == 0 {
+		request.TrainingDays = 90
+	}
+	if request.TrainingDays < 7 {
+		return httperror.JSON(c, http.StatusBadRequest, "trainingDays must be at least 7")
+	}
+
+	// Get database connection
+	db, err := getDB()
+	if err != nil {
+		log.Printf("Database connection failed: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Database connection failed")
+	}
+
+	timeouts := deadline.FromEnv()
+	ctx, cancel := timeouts.WithTotal(c.Request().Context())
+	defer cancel()
+
+	var category *Category
+	err = timeouts.Run(ctx, deadline.StageDB, func(ctx context.Context) error {
+		var err error
+		category, err = queryCategory(ctx, db, request.CategoryID)
+		return err
+	})
+	if err != nil {
+		if err == errCategoryNotFound {
+			return httperror.JSON(c, http.StatusNotFound, "Category not found")
+		}
+		log.Printf("Failed to query category: %v", err)
+		return httperror.JSON(c, databaseErrorStatus(err), "Failed to query category")
+	}
+
+	trainingStart := start.AddDate(0, 0, -request.TrainingDays)
+	var daily map[string]float64
+	err = timeouts.Run(ctx, deadline.StageDB, func(ctx context.Context) error {
+		var err error
+		daily, err = queryDailyCategoryTotals(ctx, db, request.CategoryID, trainingStart, end)
+		return err
+	})
+	if err != nil {
+		log.Printf("Failed to query category totals: %v", err)
+		return httperror.JSON(c, databaseErrorStatus(err), "Failed to query sales data")
+	}
+
+	// Split into the training history and the promotion window, filling
+	// missing days with zero so the series spacing is consistent
+	var history []forecast.Point
+	response := PromotionImpactResponse{CategoryName: category.Name}
+	for day := trainingStart; !day.After(end); day = day.AddDate(0, 0, 1) {
+		period := day.Format("2006-01-02")
+		if day.Before(start) {
+			history = append(history, forecast.Point{Period: period, Total: daily[period]})
+			continue
+		}
+		response.Actual = append(response.Actual, TimeSeriesPoint{Period: period, Total: daily[period]})
+		response.ActualTotal += daily[period]
+	}
+
+	if len(daily) == 0 {
+		return httperror.JSON(c, http.StatusNotFound, "No sales data found")
+	}
+
+	// The counterfactual uses a fixed seed so repeated analyses agree
+	forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(deterministicSeed)), forecast.FixedClock(start))
+	for _, point := range forecaster.Forecast(history, "day", len(response.Actual)) {
+		response.Counterfactual = append(response.Counterfactual, TimeSeriesPoint{Period: point.Period, Total: point.Total})
+		response.BaselineTotal += point.Total
+	}
+
+	response.ActualTotal = math.Round(response.ActualTotal*100) / 100
+	response.BaselineTotal = math.Round(response.BaselineTotal*100) / 100
+	response.Lift = math.Round((response.ActualTotal-response.BaselineTotal)*100) / 100
+	if response.BaselineTotal != 0 {
+		response.LiftPercent = math.Round(response.Lift/response.BaselineTotal*10000) / 100
+	}
+
+	analysis, err := summarizePromotionImpact(ctx, timeouts, request, response)
+	if err != nil {
+		log.Printf("Failed to generate promotion analysis: %v", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			response.Message = "Lift calculated; LLM analysis deadline exceeded"
+			return c.JSON(http.StatusGatewayTimeout, response)
+		}
+		response.Message = "Lift calculated; LLM analysis unavailable"
+		return c.JSON(http.StatusOK, response)
+	}
+
+	response.Analysis = analysis
+	response.Message = "Promotion impact analyzed successfully"
+	return c.JSON(http.StatusOK, response)
+}
+
+// queryDailyCategoryTotals returns the daily totals of a category keyed by YYYY-MM-DD
+func queryDailyCategoryTotals(ctx context.Context, db *sql.DB, categoryID int, start, end time.Time) (map[string]float64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DATE(date_recorded) AS date_recorded, SUM(total_amount) AS total_amount
+		FROM sales_totals_by_category_dw
+		WHERE category_id = $1 AND date_recorded >= $2 AND date_recorded <= $3
+			AND deleted_at IS NULL
+		GROUP BY DATE(date_recorded)
+	`, categoryID, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query category totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]float64)
+	for rows.Next() {
+		var (
+			dateRecorded string
+			totalAmount  float64
+		)
+		if err := rows.Scan(&dateRecorded, &totalAmount); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		formattedDate, err := formatReportDate(dateRecorded)
+		if err != nil {
+			return nil, err
+		}
+		totals[formattedDate] = totalAmount
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return totals, nil
+}
+
+// summarizePromotionImpact asks ChatGPT for a structured summary of the lift
+func summarizePromotionImpact(ctx context.Context, timeouts deadline.Timeouts, request PromotionImpactRequest, response PromotionImpactResponse) (*PromotionImpactSummary, error) {
+	client, err := newLLMClient("")
+	if err != nil {
+		return nil, err
+	}
+
+	actual, err := json.Marshal(response.Actual)
+	if err != nil {
+		return nil, err
+	}
+	counterfactual, err := json.Marshal(response.Counterfactual)
+	if err != nil {
+		return nil, err
+	}
+
+	prompt := fmt.Sprintf(`
+You are a retail analyst evaluating a promotion for the %s category that ran from %s to %s.
+
+The counterfactual is a forecast trained on the %d days before the promotion and shows expected sales without it.
+
+<actual_daily_sales>
+%s
+</actual_daily_sales>
+
+<counterfactual_daily_sales>
+%s
+</counterfactual_daily_sales>
+
+Actual total: %.2f
+Counterfactual total: %.2f
+Lift: %.2f (%.2f%%)
+
+Respond with a single JSON object in this format:
+{"summary": "...", "drivers": ["..."], "confidence": "low|medium|high", "recommendation": "..."}`,
+		response.CategoryName, request.StartDate, request.EndDate, request.TrainingDays,
+		actual, counterfactual, response.ActualTotal, response.BaselineTotal, response.Lift, response.LiftPercent)
+
+	chatGPTRequest := ChatGPTRequest{
+		Messages: []Message{
+			{
+				Role:    "system",
+				Content: "You are a retail analyst. Respond only with a JSON object containing 'summary', 'drivers', 'confidence', and 'recommendation' fields.",
+			},
+			{
+				Role:    "user",
+				Content: prompt,
+			},
+		},
+	}
+
+	var chatGPTResponse *ChatGPTResponse
+	err = timeouts.Run(ctx, deadline.StageLLM, func(ctx context.Context) error {
+		var err error
+		chatGPTResponse, err = client.Chat(ctx, chatGPTRequest)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("LLM request failed: %w", err)
+	}
+
+	if len(chatGPTResponse.Choices) == 0 {
+		return nil, fmt.Errorf("no choices in ChatGPT response")
+	}
+
+	var summary PromotionImpactSummary
+	err = timeouts.Run(ctx, deadline.StageParse, func(ctx context.Context) error {
+		return decodeJSONObject(chatGPTResponse.Choices[0].Message.Content, &summary)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse promotion analysis: %w", err)
+	}
+
+	return &summary, nil
+}
+
+// databaseErrorStatus returns 504 for queries that ran out of time and 500 otherwise
+func databaseErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
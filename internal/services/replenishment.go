@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// ReplenishmentSuggestion represents the suggested order for a single product
+type ReplenishmentSuggestion struct {
+	ProductID         int     `json:"product_id"`
+	SKU               string  `json:"sku"`
+	Name              string  `json:"name"`
+	CategoryName      string  `json:"category_name"`
+	Stock             float64 `json:"stock"`
+	AverageDailyUnits float64 `json:"average_daily_units"`
+	LeadTimeDemand    float64 `json:"lead_time_demand"`
+	SafetyStock       float64 `json:"safety_stock"`
+	SuggestedOrderQty int     `json:"suggested_order_qty"`
+}
+
+// replenishmentParams holds the settings for a replenishment report
+type replenishmentParams struct {
+	leadTimeDays    int
+	safetyStockDays int
+	historyDays     int
+	asOf            time.Time
+}
+
+// GetReplenishmentSuggestions handles the API request for the replenishment report
+// @Summary Get replenishment suggestions
+// @Description Forecasts each active product's unit demand over the lead time from its recent weekly sales and suggests an order quantity that covers that demand plus safety stock, net of current stock. Use format=csv or send Accept: text/csv to download the report, or format=arrow to stream it as Arrow IPC record batches.
+// @Tags products
+// @Produce json
+// @Produce text/csv
+// @Produce application/vnd.apache.arrow.stream
+// @Param lead_time_days query int false "Days between ordering and receiving stock (defaults to REPLENISHMENT_LEAD_TIME_DAYS or 14)"
+// @Param safety_stock_days query int false "Days of average demand to hold as safety stock (defaults to REPLENISHMENT_SAFETY_STOCK_DAYS or 7)"
+// @Param history_days query int false "Days of sales history to forecast from (defaults to 90)"
+// @Param category_id query int false "Only products in this category"
+// @Param format query string false "Response format: json, csv, or arrow (defaults to json)"
+// @Success 200 {array} ReplenishmentSuggestion "Suggested orders"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid parameters"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /products/replenishment [get]
+func GetReplenishmentSuggestions(c echo.Context) error {
+	ctx := c.Request().Context()
+	params, err := parseReplenishmentParams(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	categoryID := 0
+	if value := c.QueryParam("category_id"); value != "" {
+		categoryID, err = strconv.Atoi(value)
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid category_id")
+		}
+	}
+
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "csv" && format != "arrow" {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid format. Use json, csv, or arrow")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		suggestions, err := queryReplenishmentSuggestions(ctx, db, params, categoryID)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case format == "arrow":
+			return writeReplenishmentArrow(c, suggestions, params.asOf)
+		case wantsCSV(c):
+			return writeReplenishmentCSV(c, suggestions, params.asOf)
+		}
+		return c.JSON(http.StatusOK, suggestions)
+	})
+}
+
+func parseReplenishmentParams(c echo.Context) (replenishmentParams, error) {
+	params := replenishmentParams{asOf: time.Now().UTC().Truncate(24 * time.Hour)}
+
+	settings := []struct {
+		query, env string
+		fallback   int
+		target     *int
+	}{
+		{"lead_time_days", "REPLENISHMENT_LEAD_TIME_DAYS", 14, &params.leadTimeDays},
+		{"safety_stock_days", "REPLENISHMENT_SAFETY_STOCK_DAYS", 7, &params.safetyStockDays},
+		{"history_days", "", 90, &params.historyDays},
+	}
+	for _, setting := range settings {
+		*setting.target = setting.fallback
+
+		value := c.QueryParam(setting.query)
+		source := setting.query
+		if value == "" && setting.env != "" {
+			value = os.Getenv(setting.env)
+			source = setting.env
+		}
+		if value == "" {
+			continue
+		}
+
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return params, fmt.Errorf("Invalid %s. Use a whole number of days", source)
+		}
+		*setting.target = days
+	}
+
+	if params.historyDays < 7 {
+		return params, fmt.Errorf("history_days must be at least 7")
+	}
+
+	return params, nil
+}
+
+// queryReplenishmentSuggestions computes suggestions for all active products
+func queryReplenishmentSuggestions(ctx context.Context, db *sql.DB, params replenishmentParams, categoryID int) ([]ReplenishmentSuggestion, error) {
+	// History is whole weeks so every bucket covers seven days
+	weeks := params.historyDays / 7
+	historyStart := params.asOf.AddDate(0, 0, -7*weeks)
+	weeklyUnits, err := queryWeeklyProductUnits(ctx, db, historyStart, params.asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.id, COALESCE(p.sku, ''), p.name, c.name, p.quantity
+		FROM products p
+		JOIN categories c ON p.category_id = c.id
+		WHERE p.status <> $1 AND ($2 = 0 OR p.category_id = $2)
+		ORDER BY p.name
+	`, productStatusArchived, categoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query products: %v", err)
+	}
+	defer rows.Close()
+
+	suggestions := []ReplenishmentSuggestion{}
+	for rows.Next() {
+		var suggestion ReplenishmentSuggestion
+		if err := rows.Scan(&suggestion.ProductID, &suggestion.SKU, &suggestion.Name, &suggestion.CategoryName, &suggestion.Stock); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		// Build a gap-free weekly series ending at the report date; daily
+		// sales are too sparse per product for a stable moving average
+		var history []forecast.Point
+		var total float64
+		for week := 0; week < weeks; week++ {
+			weekStart := params.asOf.AddDate(0, 0, -7*(weeks-week))
+			units := weeklyUnits[suggestion.ProductID][weekStart.Format("2006-01-02")]
+			history = append(history, forecast.Point{Period: weekStart.Format("2006-01-02"), Total: units})
+			total += units
+		}
+
+		suggestion.AverageDailyUnits = math.Round(total/float64(7*weeks)*100) / 100
+		suggestion.LeadTimeDemand = leadTimeDemand(history, params.leadTimeDays)
+		suggestion.SafetyStock = math.Round(suggestion.AverageDailyUnits*float64(params.safetyStockDays)*100) / 100
+
+		shortfall := suggestion.LeadTimeDemand + suggestion.SafetyStock - suggestion.Stock
+		if shortfall > 0 {
+			suggestion.SuggestedOrderQty = int(math.Ceil(shortfall))
+		}
+
+		suggestions = append(suggestions, suggestion)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return suggestions, nil
+}
+
+// leadTimeDemand forecasts the units sold over the lead time from weekly
+// history. The forecast uses a fixed seed so the report is repeatable.
+func leadTimeDemand(history []forecast.Point, leadTimeDays int) float64 {
+	if leadTimeDays == 0 {
+		return 0
+	}
+
+	weeks := (leadTimeDays + 6) / 7
+	forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(deterministicSeed)), nil)
+
+	var units float64
+	for _, point := range forecaster.Forecast(history, "week", weeks) {
+		units += point.Total
+	}
+
+	// Scale the whole forecast weeks down to the lead time
+	units *= float64(leadTimeDays) / float64(7*weeks)
+	return math.Round(units*100) / 100
+}
+
+// queryWeeklyProductUnits returns net units sold per product and week, keyed
+// by product ID and the YYYY-MM-DD start of each 7-day bucket from start.
+// Refunded units are subtracted.
+func queryWeeklyProductUnits(ctx context.Context, db *sql.DB, start, end time.Time) (map[int]map[string]float64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			sti.product_id,
+			($1::date + ((st.date_recorded::date - $1::date) / 7) * 7) AS week_start,
+			SUM(CASE WHEN LOWER(st.status) = 'refund' THEN -sti.quantity ELSE sti.quantity END) AS units
+		FROM sale_transactions st
+		JOIN sale_transaction_items sti ON st.id = sti.sale_transaction_id
+		WHERE st.date_recorded >= $1 AND st.date_recorded < $2
+		GROUP BY sti.product_id, week_start
+	`, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query product sales: %v", err)
+	}
+	defer rows.Close()
+
+	units := make(map[int]map[string]float64)
+	for rows.Next() {
+		var (
+			productID int
+			weekStart string
+			quantity  float64
+		)
+		if err := rows.Scan(&productID, &weekStart, &quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+
+		formattedWeek, err := formatReportDate(weekStart)
+		if err != nil {
+			return nil, err
+		}
+		if units[productID] == nil {
+			units[productID] = make(map[string]float64)
+		}
+		units[productID][formattedWeek] = quantity
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	return units, nil
+}
+
+// writeReplenishmentCSV writes the suggestions as a CSV attachment
+func writeReplenishmentCSV(c echo.Context, suggestions []ReplenishmentSuggestion, asOf time.Time) error {
+	header := []string{
+		"product_id", "sku", "name", "category", "stock", "average_daily_units",
+		"lead_time_demand", "safety_stock", "suggested_order_qty",
+	}
+	return writeCSV(c, "replenishment-"+asOf.Format("2006-01-02"), header, len(suggestions), func(i int) []string {
+		s := suggestions[i]
+		return []string{
+			strconv.Itoa(s.ProductID),
+			s.SKU,
+			s.Name,
+			s.CategoryName,
+			strconv.FormatFloat(s.Stock, 'f', -1, 64),
+			csvAmount(s.AverageDailyUnits),
+			csvAmount(s.LeadTimeDemand),
+			csvAmount(s.SafetyStock),
+			strconv.Itoa(s.SuggestedOrderQty),
+		}
+	})
+}
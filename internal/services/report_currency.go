@@ -0,0 +1,107 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/fx"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// headerReportCurrency names the currency a report's amounts were converted
+// into
+const headerReportCurrency = "X-Report-Currency"
+
+// reportCurrency reads the currency query parameter, the ISO 4217 currency
+// report amounts are converted into, defaulting to REPORT_CURRENCY. It returns
+// "" when neither is set and amounts stay in the currencies they were
+// recorded in, and whether the request chose the currency itself.
+func reportCurrency(c echo.Context) (string, bool, error) {
+	if value := c.QueryParam("currency"); value != "" {
+		currency, err := fx.ParseCurrency(value)
+		if err != nil {
+			return "", false, fmt.Errorf("Invalid currency. Use an ISO 4217 code such as USD")
+		}
+		return currency, true, nil
+	}
+
+	if value := os.Getenv("REPORT_CURRENCY"); value != "" {
+		currency, err := fx.ParseCurrency(value)
+		if err == nil {
+			return currency, false, nil
+		}
+		log.Printf("Warning: invalid REPORT_CURRENCY %q, reporting recorded currencies", value)
+	}
+	return "", false, nil
+}
+
+// currencyConverter returns a converter into the report currency with the
+// server's FX rates, which prefetches the rates of the report's days, or nil
+// when the report isn't converted
+func currencyConverter(reportQuery SalesReportQuery) *fx.Converter {
+	if reportQuery.Currency == "" {
+		return nil
+	}
+	provider, _ := fx.Default()
+	start, _ := time.Parse("2006-01-02", reportQuery.StartDate)
+	end, _ := time.Parse("2006-01-02", reportQuery.EndDate)
+	return fx.NewConverter(provider, reportQuery.Currency).Prefetching(start, end)
+}
+
+// mixedCurrencyError is returned when a report that isn't converted into one
+// currency would sum amounts recorded in different currencies
+type mixedCurrencyError struct {
+	first, second string
+}
+
+func (e *mixedCurrencyError) Error() string {
+	return fmt.Sprintf("Sales in the range were recorded in %s and %s. Set currency to convert them, or group_by=category,currency to keep them apart", e.first, e.second)
+}
+
+// currencyCheck fails a report on the first amount recorded in a different
+// currency from the amounts before it. A nil currencyCheck accepts every
+// currency.
+type currencyCheck struct {
+	currency string
+}
+
+// newCurrencyCheck returns the check of a report's amounts, or nil when they
+// are converted into one currency or may be summed as recorded
+func newCurrencyCheck(reportQuery SalesReportQuery) *currencyCheck {
+	if reportQuery.Currency != "" || reportQuery.SumCurrencies {
+		return nil
+	}
+	return &currencyCheck{}
+}
+
+// add checks an amount recorded in currency
+func (c *currencyCheck) add(currency string) error {
+	switch {
+	case c == nil || currency == c.currency:
+		return nil
+	case c.currency == "":
+		c.currency = currency
+		return nil
+	default:
+		return &mixedCurrencyError{first: c.currency, second: currency}
+	}
+}
+
+// respondReportError responds to an error querying a sales report: 400 when
+// amounts couldn't be converted into the report currency or are in several
+// currencies without one, 500 otherwise
+func respondReportError(c echo.Context, err error) error {
+	var mixed *mixedCurrencyError
+	switch {
+	case errors.Is(err, fx.ErrNoRate):
+		return httperror.JSON(c, http.StatusBadRequest, "Currency conversion failed: "+err.Error())
+	case errors.As(err, &mixed):
+		return httperror.JSON(c, http.StatusBadRequest, mixed.Error())
+	}
+	return httperror.JSON(c, http.StatusInternalServerError, "Failed to query sales data")
+}
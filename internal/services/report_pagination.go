@@ -0,0 +1,128 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/calendar"
+	"github.com/labstack/echo/v4"
+)
+
+// Limits of the limit query parameter of paginated reports, in periods
+const (
+	defaultReportPageLimit = 100
+	maxReportPageLimit     = 1000
+)
+
+// headerNextCursor carries the cursor of the next page of a paginated
+// report, for formats without the JSON envelope
+const headerNextCursor = "X-Next-Cursor"
+
+// ReportPage is a page of a period-keyed report, returned when the request
+// sends limit or cursor
+type ReportPage struct {
+	// Data holds the page's periods, keyed as in the unpaginated report
+	Data any `json:"data"`
+	// NextCursor is the cursor parameter of the next page, null on the last
+	// page
+	NextCursor *string `json:"next_cursor"`
+}
+
+// reportPagination is the page of a period-keyed report a request asked for
+type reportPagination struct {
+	// enabled is set when the request sent limit or cursor; otherwise the
+	// whole report is returned as before
+	enabled bool
+	limit   int
+	// next is the cursor of the page after this one, or "" on the last page
+	next string
+}
+
+// parseReportPagination reads the limit and cursor query parameters and
+// returns the dates the page covers. A cursor is the first day of the
+// page's first period, and a page covers limit periods from there, so each
+// page only queries its own rows.
+func parseReportPagination(c echo.Context, startDate, endDate, period string) (reportPagination, string, string, error) {
+	limitValue, cursor := c.QueryParam("limit"), c.QueryParam("cursor")
+	if limitValue == "" && cursor == "" {
+		return reportPagination{}, startDate, endDate, nil
+	}
+
+	pagination := reportPagination{enabled: true, limit: defaultReportPageLimit}
+	if limitValue != "" {
+		limit, err := strconv.Atoi(limitValue)
+		if err != nil || limit < 1 || limit > maxReportPageLimit {
+			return reportPagination{}, "", "", fmt.Errorf("limit must be between 1 and %d", maxReportPageLimit)
+		}
+		pagination.limit = limit
+	}
+
+	pageStart, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return reportPagination{}, "", "", fmt.Errorf("failed to parse date %s: %v", startDate, err)
+	}
+	if cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return reportPagination{}, "", "", fmt.Errorf("Invalid cursor")
+		}
+		date, err := time.Parse("2006-01-02", string(decoded))
+		// Cursors are only made for periods that start in the range
+		if err != nil || string(decoded) < startDate || string(decoded) > endDate {
+			return reportPagination{}, "", "", fmt.Errorf("Invalid cursor")
+		}
+		pageStart = date
+	}
+
+	next := stepReportPeriods(reportPeriodStart(pageStart, period), period, pagination.limit)
+	pageEnd := next.AddDate(0, 0, -1).Format("2006-01-02")
+	if pageEnd >= endDate {
+		return pagination, pageStart.Format("2006-01-02"), endDate, nil
+	}
+	pagination.next = base64.RawURLEncoding.EncodeToString([]byte(next.Format("2006-01-02")))
+	return pagination, pageStart.Format("2006-01-02"), pageEnd, nil
+}
+
+// stepReportPeriods returns the first day of the report period n periods
+// after the one starting at start
+func stepReportPeriods(start time.Time, period string, n int) time.Time {
+	switch period {
+	case "week", "iso_week":
+		return start.AddDate(0, 0, 7*n)
+	case "month":
+		return start.AddDate(0, n, 0)
+	case "quarter":
+		return start.AddDate(0, 3*n, 0)
+	case "fiscal":
+		fiscal, _ := calendar.DefaultFiscal()
+		return fiscal.Step(start, n)
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
+
+// envelope returns the response body of a page: the report itself when it
+// isn't paginated, or the report in a ReportPage. It also sets the next
+// cursor header, which formats without the envelope rely on.
+func (p reportPagination) envelope(c echo.Context, data any) any {
+	if !p.enabled {
+		return data
+	}
+
+	page := ReportPage{Data: data}
+	if p.next != "" {
+		next := p.next
+		c.Response().Header().Set(headerNextCursor, next)
+		page.NextCursor = &next
+	}
+	return page
+}
+
+// notFound reports whether an empty report is a 404. Pages cover a fixed
+// number of periods, so one is empty when none of them had sales while
+// other pages may still have some; it's returned without data instead.
+func (p reportPagination) notFound(empty bool) bool {
+	return empty && !p.enabled
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/calendar"
+	"github.com/labstack/echo/v4"
+)
+
+// reportPeriodKey labels the report period starting at start: ISO weeks as
+// 2024-W37, fiscal periods as FY2025-P01, and other periods by their first
+// day
+func reportPeriodKey(start time.Time, period string) string {
+	switch period {
+	case "iso_week":
+		return calendar.ISOWeekKey(start)
+	case "fiscal":
+		fiscal, _ := calendar.DefaultFiscal()
+		return fiscal.Key(start)
+	default:
+		return start.Format("2006-01-02")
+	}
+}
+
+// reportPeriodStart returns the first day of the report period containing
+// date. ISO weeks start on Monday like weeks, and quarters in January, April,
+// July, and October.
+func reportPeriodStart(date time.Time, period string) time.Time {
+	switch period {
+	case "iso_week":
+		return periodStart(date, "week")
+	case "quarter":
+		return time.Date(date.Year(), date.Month()-(date.Month()-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	case "fiscal":
+		fiscal, _ := calendar.DefaultFiscal()
+		_, _, start := fiscal.Period(date)
+		return start
+	default:
+		return periodStart(date, period)
+	}
+}
+
+// reportPeriodRange returns the first and last day of the report period
+// labelled key, the inverse of reportPeriodKey
+func reportPeriodRange(key, period string) (time.Time, time.Time, error) {
+	var start time.Time
+	var err error
+	switch period {
+	case "iso_week":
+		start, err = calendar.ParseISOWeek(key)
+	case "fiscal":
+		fiscal, _ := calendar.DefaultFiscal()
+		if start, err = fiscal.Parse(key); err == nil {
+			return start, fiscal.Step(start, 1).AddDate(0, 0, -1), nil
+		}
+	default:
+		start, err = time.Parse("2006-01-02", key)
+		if err == nil && !reportPeriodStart(start, period).Equal(start) {
+			err = fmt.Errorf("%s doesn't start a %s", key, period)
+		}
+	}
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	switch period {
+	case "week", "iso_week":
+		return start, start.AddDate(0, 0, 6), nil
+	case "month":
+		return start, start.AddDate(0, 1, -1), nil
+	case "quarter":
+		return start, start.AddDate(0, 3, -1), nil
+	default:
+		return start, start, nil
+	}
+}
+
+// fiscalPeriodStarts returns the first day of every fiscal period that
+// overlaps start to end
+func fiscalPeriodStarts(start, end time.Time) []string {
+	fiscal, _ := calendar.DefaultFiscal()
+	var starts []string
+	for _, _, day := fiscal.Period(start); !day.After(end); day = fiscal.Step(day, 1) {
+		starts = append(starts, day.Format("2006-01-02"))
+	}
+	return starts
+}
+
+// checkArrowPeriod rejects Arrow output for periods labelled with keys, as
+// the Arrow date column holds period start dates
+func checkArrowPeriod(format, period string) error {
+	if format == "arrow" && (period == "iso_week" || period == "fiscal") {
+		return fmt.Errorf("format=arrow supports day, week, and month periods")
+	}
+	return nil
+}
+
+// groupCategoryReport sums a daily category report into periods
+func groupCategoryReport(report map[string][]CategoryTotal, period string) (map[string][]CategoryTotal, error) {
+	totals := make(map[string]map[string]CategoryTotal)
+	for date, categories := range report {
+		day, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse date %s: %v", date, err)
+		}
+		key := reportPeriodKey(reportPeriodStart(day, period), period)
+		if totals[key] == nil {
+			totals[key] = make(map[string]CategoryTotal)
+		}
+		for _, category := range categories {
+			total := totals[key][category.CategoryName]
+			total.CategoryName = category.CategoryName
+			total.add(category)
+			totals[key][category.CategoryName] = total
+		}
+	}
+
+	grouped := make(map[string][]CategoryTotal, len(totals))
+	for key, categories := range totals {
+		names := make([]string, 0, len(categories))
+		for name := range categories {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			grouped[key] = append(grouped[key], categories[name].roundCents())
+		}
+	}
+	return grouped, nil
+}
+
+// reportZeroFill reads the fill query parameter: zero fills in every period
+// and category without sales, none (the default) leaves the report sparse
+func reportZeroFill(c echo.Context) (bool, error) {
+	switch c.QueryParam("fill") {
+	case "", "none":
+		return false, nil
+	case "zero":
+		return true, nil
+	}
+	return false, fmt.Errorf("Invalid fill. Use zero or none")
+}
+
+// reportPeriodKeys returns the key of every report period overlapping the
+// dates from startDate to endDate, in order
+func reportPeriodKeys(startDate, endDate, period string) ([]string, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse date %s: %v", startDate, err)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse date %s: %v", endDate, err)
+	}
+
+	var keys []string
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		key := reportPeriodKey(reportPeriodStart(day, period), period)
+		if len(keys) == 0 || keys[len(keys)-1] != key {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// zeroFillReport returns the report with an entry for every key and, under
+// each key, every category in categories or the report, sorted by name.
+// Missing entries are made with zero.
+func zeroFillReport[T any](report map[string][]T, keys []string, categories []Category, name func(T) string, zero func(string) T) map[string][]T {
+	names := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		names[category.Name] = true
+	}
+	for _, totals := range report {
+		for _, total := range totals {
+			names[name(total)] = true
+		}
+	}
+	sorted := make([]string, 0, len(names))
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+
+	filled := make(map[string][]T, len(keys))
+	for _, key := range keys {
+		existing := make(map[string]T, len(report[key]))
+		for _, total := range report[key] {
+			existing[name(total)] = total
+		}
+		totals := make([]T, 0, len(sorted))
+		for _, n := range sorted {
+			total, ok := existing[n]
+			if !ok {
+				total = zero(n)
+			}
+			totals = append(totals, total)
+		}
+		filled[key] = totals
+	}
+	return filled
+}
+
+// fillCategoryReport zero-fills a category report over every period and
+// category, or top-level category with rollup
+func fillCategoryReport(ctx context.Context, db *sql.DB, report map[string][]CategoryTotal, startDate, endDate, period string, rollup bool) (map[string][]CategoryTotal, error) {
+	keys, err := reportPeriodKeys(startDate, endDate, period)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := queryReportCategories(ctx, db, rollup)
+	if err != nil {
+		return nil, err
+	}
+	return zeroFillReport(report, keys, categories,
+		func(total CategoryTotal) string { return total.CategoryName },
+		func(name string) CategoryTotal { return CategoryTotal{CategoryName: name} }), nil
+}
+
+// fillCustomerTypeReport zero-fills a new vs returning customer report over
+// every period and category, or top-level category with rollup
+func fillCustomerTypeReport(ctx context.Context, db *sql.DB, report map[string][]CustomerTypeTotal, startDate, endDate, period string, rollup bool) (map[string][]CustomerTypeTotal, error) {
+	keys, err := reportPeriodKeys(startDate, endDate, period)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := queryReportCategories(ctx, db, rollup)
+	if err != nil {
+		return nil, err
+	}
+	return zeroFillReport(report, keys, categories,
+		func(total CustomerTypeTotal) string { return total.CategoryName },
+		func(name string) CustomerTypeTotal { return CustomerTypeTotal{CategoryName: name} }), nil
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+)
+
+// reportRollup reads the rollup query parameter: true totals each category's
+// sales, and its subcategories', under its top-level category, so reports
+// list departments; false (the default) reports every category by itself
+func reportRollup(c echo.Context) (bool, error) {
+	switch c.QueryParam("rollup") {
+	case "", "false":
+		return false, nil
+	case "true":
+		return true, nil
+	}
+	return false, fmt.Errorf("Invalid rollup. Use true or false")
+}
+
+// queryReportCategories returns the categories a report lists: every
+// category, or with rollup only the top-level ones
+func queryReportCategories(ctx context.Context, db *sql.DB, rollup bool) ([]Category, error) {
+	if !rollup {
+		return queryCategories(ctx, db)
+	}
+	return scanCategories(db.QueryContext(ctx, "SELECT "+categoryColumns+" FROM categories WHERE parent_id IS NULL ORDER BY name"))
+}
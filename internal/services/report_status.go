@@ -0,0 +1,31 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// reportStatuses are the sale transaction statuses reports can be filtered
+// by. Refund totals are negative in the DW table.
+var reportStatuses = []string{"invoice", "refund", "pending"}
+
+// parseReportStatuses reads the comma-separated status query parameter,
+// returning nil to total every status
+func parseReportStatuses(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var statuses []string
+	for _, status := range strings.Split(value, ",") {
+		status = strings.ToLower(strings.TrimSpace(status))
+		if !slices.Contains(reportStatuses, status) {
+			return nil, fmt.Errorf("Invalid status %q. Use invoice, refund, or pending", status)
+		}
+		if !slices.Contains(statuses, status) {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+	// Embed the time zone database so tz works on images without one
+	_ "time/tzdata"
+
+	"github.com/bokor/craft-demo/internal/auth"
+	"github.com/labstack/echo/v4"
+)
+
+// reportTimeZone returns the IANA time zone the request's report dates are
+// bucketed in: the tz query parameter, the time zone of the caller's tenant
+// from its token, or REPORT_TIMEZONE, in that order. It returns "" for UTC,
+// the dates stored in the DW table.
+func reportTimeZone(c echo.Context) (string, error) {
+	if tz := c.QueryParam("tz"); tz != "" {
+		location, err := loadTimeZone(tz)
+		if err != nil {
+			return "", fmt.Errorf("Invalid tz. Use an IANA time zone such as Australia/Sydney")
+		}
+		return timeZoneName(location), nil
+	}
+
+	if principal, ok := auth.FromContext(c); ok && principal.TimeZone != "" {
+		if location, err := loadTimeZone(principal.TimeZone); err == nil {
+			return timeZoneName(location), nil
+		}
+		log.Printf("Warning: invalid time zone %q in the token of %s, using the default", principal.TimeZone, principal.Subject)
+	}
+
+	if value := os.Getenv("REPORT_TIMEZONE"); value != "" {
+		if location, err := loadTimeZone(value); err == nil {
+			return timeZoneName(location), nil
+		}
+		log.Printf("Warning: invalid REPORT_TIMEZONE %q, using UTC", value)
+	}
+	return "", nil
+}
+
+// loadTimeZone loads an IANA time zone. Local is refused, as the database
+// doesn't know the server's time zone by that name.
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %s", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// timeZoneName returns the name of location, or "" for UTC
+func timeZoneName(location *time.Location) string {
+	if location == time.UTC {
+		return ""
+	}
+	return location.String()
+}
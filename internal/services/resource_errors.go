@@ -0,0 +1,63 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// notFoundError is returned when a requested resource doesn't exist
+type notFoundError struct {
+	resource string
+}
+
+func (e *notFoundError) Error() string { return e.resource + " not found" }
+
+// conflictError is returned when a change would break a resource rule
+type conflictError struct {
+	message string
+}
+
+func (e *conflictError) Error() string { return e.message }
+
+// invalidError is returned when a request refers to data that doesn't exist
+// or breaks a rule only the database can check
+type invalidError struct {
+	message string
+}
+
+func (e *invalidError) Error() string { return e.message }
+
+// withDB opens a database connection for a resource handler and maps the
+// errors it returns to responses
+func withDB(c echo.Context, handler func(db *sql.DB) error) error {
+	// Get database connection
+	db, err := getDB()
+	if err != nil {
+		log.Printf("Database connection failed: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Database connection failed")
+	}
+
+	err = handler(db)
+
+	var notFound *notFoundError
+	var conflict *conflictError
+	var invalid *invalidError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &notFound):
+		return httperror.JSON(c, http.StatusNotFound, notFound.Error())
+	case errors.As(err, &conflict):
+		return httperror.JSON(c, http.StatusConflict, conflict.Error())
+	case errors.As(err, &invalid):
+		return httperror.JSON(c, http.StatusBadRequest, invalid.Error())
+	default:
+		log.Printf("Request failed: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Failed to process request")
+	}
+}
@@ -1,29 +1,155 @@
 package services
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/bokor/craft-demo/internal/deadline"
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/outliers"
+	"github.com/bokor/craft-demo/internal/prompts"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 )
 
 // ForecastRequest represents the request structure for forecasting
 type ForecastRequest struct {
+	// TimeSeriesData is the history to forecast. Missing periods are filled
+	// according to GapFill and reported in imputed.
 	TimeSeriesData []TimeSeriesPoint `json:"timeSeriesData"`
 	// TimePeriod is now optional - if not specified, all periods will be generated
 	TimePeriod string `json:"timePeriod,omitempty"`
+	// Deterministic skips the LLM and uses a fixed-seed statistical forecast
+	// so the same input always produces the same output
+	Deterministic bool `json:"deterministic,omitempty"`
+	// Method selects the statistical forecast used for deterministic and
+	// fallback forecasts: moving_average (default), naive, seasonal_naive,
+	// weighted_moving_average, exponential_smoothing,
+	// double_exponential_smoothing, triple_exponential_smoothing, additive,
+	// croston, tsb, or arima. Without one, intermittent series use croston
+	// or tsb.
+	Method string `json:"method,omitempty"`
+	// Alpha, Beta and Gamma are the smoothing factors in (0, 1]
+	Alpha float64 `json:"alpha,omitempty"`
+	Beta  float64 `json:"beta,omitempty"`
+	Gamma float64 `json:"gamma,omitempty"`
+	// Window is the number of periods the weighted moving average covers
+	Window int `json:"window,omitempty"`
+	// SeasonLength is the number of periods per season, defaulting to 7
+	// days, 52 weeks or 12 months
+	SeasonLength int `json:"seasonLength,omitempty"`
+	// AROrder is the number of lagged periods the arima method uses,
+	// chosen by AIC when unset
+	AROrder int `json:"arOrder,omitempty"`
+	// Differencing is how many times the arima method differences the
+	// series (0-2), chosen from the series when unset
+	Differencing *int `json:"differencing,omitempty"`
+	// GapFill imputes missing periods before forecasting: zero (default),
+	// linear, seasonal, or none
+	GapFill string `json:"gapFill,omitempty"`
+	// TradingDays normalizes monthly totals for their weighted trading days
+	// before forecasting and applies each forecast month's trading days to
+	// the result
+	TradingDays bool `json:"tradingDays,omitempty"`
+	// WeekendWeight is how much a weekend day counts relative to a weekday
+	// for TradingDays (default 1)
+	WeekendWeight float64 `json:"weekendWeight,omitempty"`
+	// Track stores the forecast so the forecast-accuracy job can match it
+	// against actuals from the DW table, for GET /forecasts/accuracy
+	Track bool `json:"track,omitempty"`
+	// Categories forecasts several series in one request, keyed by category
+	// name, with the other settings applied to each. It replaces
+	// TimeSeriesData. They are forecast FORECAST_CONCURRENCY at a time.
+	Categories map[string][]TimeSeriesPoint `json:"categories,omitempty"`
+	// CategoryID is the category the tracked series covers; without it the
+	// series is taken to be revenue across all categories. Without
+	// TimeSeriesData, the category's history is loaded from the DW table.
+	CategoryID *int `json:"categoryId,omitempty"`
+	// Scenario lists planned changes, such as promotions, to forecast on top
+	// of the baseline. The LLM is told about them; statistical forecasts are
+	// scaled by them.
+	Scenario []ScenarioAdjustment `json:"scenario,omitempty"`
+	// Outliers flags, and optionally winsorizes, outliers in the history
+	// before it reaches the prompt or the statistical forecast
+	Outliers *OutlierOptions `json:"outliers,omitempty"`
+	// HorizonDays, HorizonWeeks and HorizonMonths are how many periods to
+	// forecast for daily, weekly (and ISO-week), and monthly (and fiscal)
+	// series, defaulting to 14, 4 and 6 when they're 0 or unset
+	HorizonDays   int `json:"horizonDays,omitempty"`
+	HorizonWeeks  int `json:"horizonWeeks,omitempty"`
+	HorizonMonths int `json:"horizonMonths,omitempty"`
+	// Provider is the LLM asked for the forecast: openai or anthropic,
+	// defaulting to LLM_PROVIDER
+	Provider string `json:"provider,omitempty"`
+	// Model overrides the provider's model, e.g. gpt-4o-mini
+	Model string `json:"model,omitempty"`
+	// Temperature (0-2) and MaxTokens (up to 16384) override LLM_TEMPERATURE
+	// and LLM_MAX_TOKENS
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+}
+
+// OutlierOptions configures outlier detection for a forecast request
+type OutlierOptions struct {
+	// Method is zscore, iqr, or hampel
+	Method string `json:"method"`
+	// Threshold defaults to 3 standard deviations for zscore, 1.5
+	// interquartile ranges for iqr, and 3 scaled MADs for hampel
+	Threshold float64 `json:"threshold,omitempty"`
+	// Window is the number of periods in a hampel window (default 7)
+	Window int `json:"window,omitempty"`
+	// Winsorize clips outliers to the nearest bound; otherwise they are
+	// only reported
+	Winsorize bool `json:"winsorize,omitempty"`
+}
+
+// OutlierPoint is a history point flagged as an outlier
+type OutlierPoint struct {
+	Period string  `json:"period"`
+	Total  float64 `json:"total"`
+	Lower  float64 `json:"lower"`
+	Upper  float64 `json:"upper"`
+	// Treated is the total used for forecasting
+	Treated float64 `json:"treated"`
 }
 
 // TimeSeriesPoint represents a single data point in the time series
 type TimeSeriesPoint struct {
 	Period string  `json:"period"`
 	Total  float64 `json:"total"`
+	// Intervals are the 80% and 95% confidence bands of forecast points
+	Intervals []ConfidenceInterval `json:"intervals,omitempty"`
+}
+
+// ConfidenceInterval is the range a forecast total falls in with the given
+// confidence
+type ConfidenceInterval struct {
+	// Level is the confidence level in percent: 80 or 95
+	Level int     `json:"level"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// interval returns the bounds of the point's band at level, or nil
+func (p TimeSeriesPoint) interval(level int) (*float64, *float64) {
+	for _, interval := range p.Intervals {
+		if interval.Level == level {
+			return &interval.Lower, &interval.Upper
+		}
+	}
+	return nil, nil
 }
 
 // ForecastResponse represents the response from the forecast service
@@ -32,12 +158,94 @@ type ForecastResponse struct {
 	TimePeriod  string            `json:"timePeriod"`
 	Message     string            `json:"message"`
 	RawResponse string            `json:"rawResponse,omitempty"`
+	Meta        ForecastMeta      `json:"meta"`
+	// Outliers lists the history points flagged by outlier detection
+	Outliers []OutlierPoint `json:"outliers,omitempty"`
+	// Imputed lists the missing history periods filled in by gap filling
+	Imputed []TimeSeriesPoint `json:"imputed,omitempty"`
+	// ForecastID identifies a tracked forecast in the accuracy endpoint
+	ForecastID int64 `json:"forecastId,omitempty"`
+	// Baseline is the statistical forecast without the request's scenario
+	Baseline []TimeSeriesPoint `json:"baseline,omitempty"`
+}
+
+// ForecastMeta describes how a forecast was produced. The same source is
+// sent in the X-Forecast-Source header.
+type ForecastMeta struct {
+	// Source is llm, fallback, or deterministic
+	Source string `json:"source"`
+	// Provider is openai, azure, ollama, or anthropic for LLM forecasts and
+	// statistical otherwise
+	Provider string `json:"provider"`
+	// Model and PromptVersion are set when the LLM was asked for a forecast
+	Model         string `json:"model,omitempty"`
+	PromptVersion string `json:"promptVersion,omitempty"`
+	// FallbackReason explains why the LLM forecast wasn't used
+	FallbackReason string `json:"fallbackReason,omitempty"`
+	DurationMs     int64  `json:"durationMs"`
+	// CacheHit reports whether the forecast was served from a cache
+	CacheHit bool `json:"cacheHit"`
+	// Method is the statistical method used for fallback and deterministic
+	// forecasts
+	Method string `json:"method,omitempty"`
+	// Demand classifies the history; intermittent series default to
+	// Croston's or TSB's method
+	Demand *forecast.DemandPattern `json:"demand,omitempty"`
+	// TradingDays reports whether the forecast was adjusted for trading days
+	TradingDays bool `json:"tradingDays,omitempty"`
+	// LLMStatus is the cached LLM health when the forecast was requested;
+	// the LLM is skipped while it is down
+	LLMStatus string `json:"llmStatus,omitempty"`
+	// Usage is the token usage and estimated cost of the LLM request, when
+	// the provider reported it
+	Usage *LLMUsage `json:"usage,omitempty"`
+	// Repairs lists the LLM forecast points replaced by the statistical
+	// forecast or dropped because they failed validation
+	Repairs []ForecastRepair `json:"repairs,omitempty"`
+}
+
+// Forecast sources reported in ForecastMeta and X-Forecast-Source
+const (
+	forecastSourceLLM           = "llm"
+	forecastSourceFallback      = "fallback"
+	forecastSourceDeterministic = "deterministic"
+)
+
+// What a forecast does when the LLM fails, chosen with FORECAST_FALLBACK
+const (
+	// forecastFallbackStatistical serves the statistical forecast instead
+	forecastFallbackStatistical = "statistical"
+	// forecastFallbackError fails the request
+	forecastFallbackError = "error"
+)
+
+// forecastFallback returns what a forecast does when the LLM fails,
+// FORECAST_FALLBACK (default error)
+func forecastFallback() string {
+	switch value := strings.ToLower(os.Getenv("FORECAST_FALLBACK")); value {
+	case "", forecastFallbackError:
+		return forecastFallbackError
+	case forecastFallbackStatistical:
+		return forecastFallbackStatistical
+	default:
+		log.Printf("Warning: invalid FORECAST_FALLBACK %q, using %s", value, forecastFallbackError)
+		return forecastFallbackError
+	}
 }
 
-// ChatGPTRequest represents the request to ChatGPT API
+const forecastModel = "gpt-3.5-turbo"
+
+// ChatGPTRequest represents the request to ChatGPT API. LLMClient
+// implementations use their own model when Model is empty and the
+// provider's defaults for an unset temperature or max tokens.
 type ChatGPTRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	// Stream and StreamOptions are set by the OpenAI client's ChatStream
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
 }
 
 // Message represents a message in the ChatGPT conversation
@@ -49,6 +257,11 @@ type Message struct {
 // ChatGPTResponse represents the response from ChatGPT API
 type ChatGPTResponse struct {
 	Choices []Choice `json:"choices"`
+	// Usage is the token usage, when the provider reports it
+	Usage *TokenUsage `json:"usage,omitempty"`
+	// CostUSD is the estimated cost of Usage, set by newLLMClient's clients
+	// when the model's price is known
+	CostUSD *float64 `json:"-"`
 }
 
 // Choice represents a choice in the ChatGPT response
@@ -58,34 +271,59 @@ type Choice struct {
 
 // GenerateSalesForecast handles the API request for sales forecasting
 // @Summary Generate sales forecast using ChatGPT
-// @Description Sends time series data to ChatGPT for forecasting and returns predicted values for daily, weekly, and monthly periods
+// @Description Forecasts the time series with the LLM and returns the predicted values with 80% and 95% confidence bands. LLM failures are errors unless FORECAST_FALLBACK is statistical, which falls back to a statistical forecast; deterministic always uses it. LLM forecasts are checked against the statistical forecast's periods, with repairs listed in meta.repairs. The request fields are described in ForecastRequest.
 // @Tags sales
 // @Accept json
 // @Produce json
+// @Produce application/x-protobuf
+// @Produce application/msgpack
+// @Produce text/event-stream
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
 // @Param request body ForecastRequest true "Forecast request with time series data"
+// @Param schema query int false "Response schema version: 2 (default) is the flat forecast array, 1 (deprecated) keys the forecast by daily, weekly, or monthly"
+// @Param stream query bool false "Stream Server-Sent Events instead: a point event per forecast point as the LLM writes it, then a forecast event with the finished response, which replaces the points"
+// @Param format query string false "Response format: json or xlsx (defaults to json, or the Accept header); the workbook has a summary sheet and a sheet for the horizon, or one per category, each with a Total row"
+// @Param Accept header string false "application/x-protobuf for the craftdemo.v1.ForecastResponse message, or application/msgpack for the JSON body as MessagePack"
+// @Param X-Schema-Version header int false "Response schema version, when schema isn't set"
 // @Success 200 {object} ForecastResponse "Forecast data with predicted values for all time periods"
-// @Failure 400 {object} map[string]string "Bad request - invalid data"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Success 200 {object} CategoryForecasts "With categories, the forecast of each category"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid data"
+// @Failure 500 {object} httperror.Envelope "Internal server error, or the LLM forecast failed and FORECAST_FALLBACK isn't statistical"
+// @Failure 504 {object} ForecastResponse "Deadline exceeded - an error, or the statistical fallback forecast when FORECAST_FALLBACK is statistical"
+// @Security BearerAuth
 // @Router /sales/forecast [post]
 func GenerateSalesForecast(c echo.Context) error {
+	// Parse request body
+	var request ForecastRequest
+	if err := c.Bind(&request); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid request format")
+	}
+
+	return generateSalesForecast(c, request)
+}
+
+// generateSalesForecast validates a forecast request, loading the history of
+// its category when it has no time series data, and responds with the
+// forecast
+func generateSalesForecast(c echo.Context, request ForecastRequest) error {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: .env file not found, using system environment variables")
 	}
 
-	// Parse request body
-	var request ForecastRequest
-	if err := c.Bind(&request); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid request format",
-		})
+	schemaVersion, err := forecastSchemaVersion(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
 	}
-
-	// Validate request
-	if len(request.TimeSeriesData) == 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "No time series data provided",
-		})
+	stream, err := forecastStreamRequested(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if err := validateReportFormat(c, "xlsx"); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if stream && wantsXLSX(c) {
+		return httperror.JSON(c, http.StatusBadRequest, "stream isn't supported with format=xlsx")
 	}
 
 	// Determine the time period to forecast (default to month if not specified)
@@ -94,60 +332,451 @@ func GenerateSalesForecast(c echo.Context) error {
 		timePeriod = "month"
 	}
 
+	// Forecast the category's own history when no series was sent
+	if len(request.TimeSeriesData) == 0 && request.CategoryID != nil {
+		request.TimeSeriesData, err = loadCategoryHistory(c.Request().Context(), *request.CategoryID, timePeriod, time.Now())
+		var notFound *notFoundError
+		var invalid *invalidError
+		switch {
+		case errors.As(err, &notFound):
+			return httperror.JSON(c, http.StatusNotFound, notFound.Error())
+		case errors.As(err, &invalid):
+			return httperror.JSON(c, http.StatusBadRequest, invalid.Error())
+		case err != nil:
+			log.Printf("Failed to load category history: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to load category history")
+		}
+	}
+
+	// Several categories are forecast side by side
+	if request.Categories != nil {
+		return generateCategoryForecasts(c, request, timePeriod, schemaVersion, stream)
+	}
+
+	prepared, err := prepareForecast(request, timePeriod)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	// Streamed forecasts are sent as events from here on; the request errors
+	// above are still plain JSON responses
+	if stream {
+		setForecastSchemaHeaders(c, schemaVersion)
+		events := newEventStream(c)
+		onPoint := func(point TimeSeriesPoint) {
+			// A client that goes away cancels ctx, which ends the LLM request
+			if err := events.send(forecastEventPoint, point); err != nil {
+				log.Printf("Failed to stream forecast point: %v", err)
+			}
+		}
+		// The stream is already under way when the LLM streamed points, so
+		// the status and source are only in the forecast event, or the
+		// error in an error event. Points arriving after it are dropped
+		// rather than written to a response the handler has finished with.
+		response, status, err := prepared.run(c.Request().Context(), onPoint)
+		if err != nil {
+			return events.sendLast(forecastEventError, httperror.New(c, status, err.Error()))
+		}
+		return events.sendLast(forecastEventForecast, versionedForecast(schemaVersion, response))
+	}
+
+	response, status, err := prepared.run(c.Request().Context(), nil)
+	if err != nil {
+		return httperror.JSON(c, status, err.Error())
+	}
+	c.Response().Header().Set("X-Forecast-Source", response.Meta.Source)
+	if wantsXLSX(c) {
+		workbook, err := buildForecastXLSX(response)
+		if err != nil {
+			log.Printf("Failed to build workbook: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to build workbook")
+		}
+		defer workbook.Close()
+		return writeXLSX(c, status, "forecast-"+timePeriod, workbook)
+	}
+	return respondForecast(c, status, schemaVersion, response)
+}
+
+// preparedForecast is a validated forecast request with its history treated
+// and its statistical method chosen
+type preparedForecast struct {
+	request    ForecastRequest
+	timePeriod string
+	method     forecast.Method
+	strategy   forecast.Strategy
+	trading    *forecast.TradingDayAdjustment
+	scenario   forecast.Scenario
+	demand     forecast.DemandPattern
+	imputed    []TimeSeriesPoint
+	flagged    []OutlierPoint
+}
+
+// prepareForecast validates a request with time series data, fills gaps in
+// and treats outliers in its history, and chooses its statistical method.
+// Errors are the request's fault.
+func prepareForecast(request ForecastRequest, timePeriod string) (*preparedForecast, error) {
+	// Validate request
+	if len(request.TimeSeriesData) == 0 {
+		return nil, fmt.Errorf("No time series data provided")
+	}
+	if err := request.validateHorizons(); err != nil {
+		return nil, err
+	}
+	if err := request.validateLLM(); err != nil {
+		return nil, err
+	}
+	scenario, err := request.scenario()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill missing periods so the history is evenly spaced
+	gapFill, err := forecast.ParseGapFill(request.GapFill)
+	if err != nil {
+		return nil, err
+	}
+	prepared := &preparedForecast{timePeriod: timePeriod, scenario: scenario}
+	request.TimeSeriesData, prepared.imputed = fillGaps(request.TimeSeriesData, timePeriod, gapFill)
+
+	// Flag and treat outliers before anything else sees the history
+	if request.Outliers != nil {
+		request.TimeSeriesData, prepared.flagged, err = treatOutliers(request.TimeSeriesData, *request.Outliers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Forecast trading-day-normalized totals and re-apply the effect in run
+	if request.TradingDays {
+		prepared.trading, err = tradingDayAdjustment(request, timePeriod)
+		if err != nil {
+			return nil, err
+		}
+		request.TimeSeriesData = adjustTradingDays(request.TimeSeriesData, prepared.trading.Normalize)
+	}
+
+	// Intermittent series get Croston's or TSB's method unless one was chosen
+	totals := make([]float64, len(request.TimeSeriesData))
+	for i, point := range request.TimeSeriesData {
+		totals[i] = point.Total
+	}
+	prepared.demand = forecast.ClassifyDemand(totals)
+	prepared.method = forecast.Method(strings.ToLower(request.Method))
+	if prepared.method == "" {
+		prepared.method = forecast.MethodMovingAverage
+		if prepared.demand.Intermittent {
+			prepared.method = prepared.demand.Method
+		}
+	}
+
+	prepared.strategy, err = forecast.NewStrategy(forecast.Options{
+		Method:       prepared.method,
+		Alpha:        request.Alpha,
+		Beta:         request.Beta,
+		Gamma:        request.Gamma,
+		Window:       request.Window,
+		SeasonLength: request.SeasonLength,
+		Holidays:     forecastHolidays(request, timePeriod),
+		AROrder:      request.AROrder,
+		Differencing: request.Differencing,
+	}, timePeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	prepared.request = request
+	return prepared, nil
+}
+
+// run makes the forecast within ctx and returns it with the status it is
+// served with. When the LLM fails it returns an error, with status 504
+// after the deadline passed or 500, unless FORECAST_FALLBACK is statistical:
+// then it returns the fallback, with status 504 after the deadline passed or
+// 200. With onPoint,
+// the LLM reply is streamed and onPoint is called with each forecast point
+// as soon as it is complete.
+func (p *preparedForecast) run(ctx context.Context, onPoint func(TimeSeriesPoint)) (ForecastResponse, int, error) {
+	request, timePeriod := p.request, p.timePeriod
+
 	// Generate forecast for the specific time period
+	started := time.Now()
+	demand := p.demand
 	response := ForecastResponse{
 		TimePeriod: timePeriod,
 		Message:    "Forecast generated successfully",
+		Meta:       ForecastMeta{Provider: "statistical", Demand: &demand},
+		Outliers:   p.flagged,
+		Imputed:    p.imputed,
+	}
+	finish := func(status int, source string) (ForecastResponse, int, error) {
+		response.Meta.Source = source
+		if source != forecastSourceLLM {
+			response.Meta.Method = string(p.method)
+		}
+		if p.trading != nil {
+			response.Forecast = adjustTradingDays(response.Forecast, p.trading.Apply)
+			response.Meta.TradingDays = true
+		}
+		// The LLM was told about the scenario; statistical forecasts are the
+		// baseline until scaled by it
+		if len(p.scenario) > 0 && source != forecastSourceLLM {
+			response.Baseline = response.Forecast
+			response.Forecast = applyScenario(response.Forecast, p.scenario, timePeriod)
+		}
+		if request.Track {
+			// Tracking is best effort; the forecast is still returned
+			if id, err := saveForecast(ctx, request.CategoryID, response); err != nil {
+				log.Printf("Failed to track forecast: %v", err)
+			} else {
+				response.ForecastID = id
+			}
+		}
+		response.Meta.DurationMs = time.Since(started).Milliseconds()
+		return response, status, nil
 	}
 
-	// Generate forecast using ChatGPT
-	forecast, rawResponse, err := generateForecastForPeriod(request, timePeriod)
-	if err != nil {
+	// Deterministic requests never go to the LLM
+	if request.Deterministic {
+		forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(deterministicSeed)), forecast.FixedClock(deterministicEpoch)).WithStrategy(p.strategy)
+		response.Forecast = generateSimpleForecast(forecaster, request, timePeriod)
+		response.Message = "Deterministic forecast generated successfully"
+		return finish(http.StatusOK, forecastSourceDeterministic)
+	}
+
+	// Bound the whole LLM pipeline by the total deadline
+	timeouts := deadline.FromEnv()
+	llmCtx, cancel := timeouts.WithTotal(ctx)
+	defer cancel()
+
+	// Streamed points get the trading-day effect the forecast gets in finish
+	if onPoint != nil && p.trading != nil {
+		stream := onPoint
+		onPoint = func(point TimeSeriesPoint) {
+			stream(adjustTradingDays([]TimeSeriesPoint{point}, p.trading.Apply)[0])
+		}
+	}
+
+	// Generate forecast using ChatGPT, unless the health check found it down.
+	// The statistical forecast replaces it if it fails, or the periods it
+	// gets wrong.
+	var (
+		points      []TimeSeriesPoint
+		rawResponse string
+		err         error
+	)
+	fallback := generateSimpleForecast(forecast.NewSimpleForecaster(nil, nil).WithStrategy(p.strategy), request, timePeriod)
+	health := llmHealthFor(request.Provider)
+	response.Meta.LLMStatus = health.Status
+	if health.Status == llmStatusDown {
+		err = fmt.Errorf("LLM unavailable: %s", health.Error)
+	} else {
+		points, rawResponse, err = generateForecastForPeriod(llmCtx, timeouts, request, timePeriod, fallback, &response.Meta, onPoint)
+	}
+	if err != nil && forecastFallback() == forecastFallbackError {
 		log.Printf("Failed to generate forecast: %v", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to generate forecast",
-		})
+		if errors.Is(err, context.DeadlineExceeded) {
+			return response, http.StatusGatewayTimeout, fmt.Errorf("Forecast deadline exceeded: %v", err)
+		}
+		return response, http.StatusInternalServerError, fmt.Errorf("Failed to generate forecast: %v", err)
+	}
+	if err != nil {
+		log.Printf("Failed to generate forecast: %v, falling back to simple forecast", err)
+		response.Forecast = fallback
+		response.Meta.Repairs = nil
+		response.Meta.FallbackReason = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			response.Message = "Forecast deadline exceeded; returning statistical fallback"
+			return finish(http.StatusGatewayTimeout, forecastSourceFallback)
+		}
+		response.Message = "Forecast generated using statistical fallback"
+		return finish(http.StatusOK, forecastSourceFallback)
 	}
 
-	response.Forecast = forecast
+	response.Forecast = points
 	response.RawResponse = rawResponse
+	response.Meta.Provider, _ = llmProviderName(request.Provider)
 
-	return c.JSON(http.StatusOK, response)
+	return finish(http.StatusOK, forecastSourceLLM)
 }
 
-// generateForecastForPeriod sends data to ChatGPT for forecasting a specific time period
-func generateForecastForPeriod(request ForecastRequest, timePeriod string) ([]TimeSeriesPoint, string, error) {
-	// Get ChatGPT API key from environment
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		log.Printf("No OpenAI API key found")
-		return nil, "", fmt.Errorf("no OpenAI API key found")
+const deterministicSeed = 42
+
+// deterministicEpoch anchors deterministic forecasts whose periods can't be parsed
+var deterministicEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// generateSimpleForecast runs the statistical forecaster over the request data
+func generateSimpleForecast(forecaster *forecast.SimpleForecaster, request ForecastRequest, timePeriod string) []TimeSeriesPoint {
+	history := make([]forecast.Point, 0, len(request.TimeSeriesData))
+	for _, point := range request.TimeSeriesData {
+		history = append(history, forecast.Point{Period: point.Period, Total: point.Total})
 	}
 
-	// Check if we have a valid API key
-	if len(apiKey) < 10 {
-		log.Printf("No valid OpenAI API key found")
-		return nil, "", fmt.Errorf("invalid OpenAI API key")
+	return forecastPoints(forecaster.Forecast(history, timePeriod, request.forecastPeriods(timePeriod)))
+}
+
+// forecastPoints converts forecast points with their bands
+func forecastPoints(points []forecast.Point) []TimeSeriesPoint {
+	var result []TimeSeriesPoint
+	for _, point := range points {
+		converted := TimeSeriesPoint{Period: point.Period, Total: point.Total}
+		for _, interval := range point.Intervals {
+			converted.Intervals = append(converted.Intervals, ConfidenceInterval(interval))
+		}
+		result = append(result, converted)
 	}
+	return result
+}
 
-	// Validate API key format (should start with sk-)
-	if len(apiKey) < 3 || apiKey[:3] != "sk-" {
-		log.Printf("Invalid OpenAI API key format")
-		return nil, "", fmt.Errorf("invalid OpenAI API key format")
+// forecastHistory converts points to forecast points with their bands
+func forecastHistory(points []TimeSeriesPoint) []forecast.Point {
+	result := make([]forecast.Point, len(points))
+	for i, point := range points {
+		result[i] = forecast.Point{Period: point.Period, Total: point.Total}
+		for _, interval := range point.Intervals {
+			result[i].Intervals = append(result[i].Intervals, forecast.Interval(interval))
+		}
+	}
+	return result
+}
+
+// fillGaps fills the missing periods in data and returns the filled data
+// with the imputed points
+func fillGaps(data []TimeSeriesPoint, timePeriod string, fill forecast.GapFill) ([]TimeSeriesPoint, []TimeSeriesPoint) {
+	history := make([]forecast.Point, len(data))
+	for i, point := range data {
+		history[i] = forecast.Point{Period: point.Period, Total: point.Total}
+	}
+	filled, missing := forecast.FillGaps(history, timePeriod, fill)
+	if missing == nil {
+		return data, nil
+	}
+
+	result := make([]TimeSeriesPoint, len(filled))
+	for i, point := range filled {
+		result[i] = TimeSeriesPoint{Period: point.Period, Total: point.Total}
+	}
+	imputed := make([]TimeSeriesPoint, len(missing))
+	for i, point := range missing {
+		imputed[i] = TimeSeriesPoint{Period: point.Period, Total: point.Total}
+	}
+	return result, imputed
+}
+
+// treatOutliers flags the outliers in data and returns the data with their
+// treated totals
+func treatOutliers(data []TimeSeriesPoint, options OutlierOptions) ([]TimeSeriesPoint, []OutlierPoint, error) {
+	totals := make([]float64, len(data))
+	for i, point := range data {
+		totals[i] = point.Total
+	}
+	found, err := outliers.Detect(totals, outliers.Options{
+		Method:    outliers.Method(options.Method),
+		Threshold: options.Threshold,
+		Window:    options.Window,
+		Winsorize: options.Winsorize,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	treated := slices.Clone(data)
+	flagged := make([]OutlierPoint, 0, len(found))
+	for _, outlier := range found {
+		point := data[outlier.Index]
+		treated[outlier.Index].Total = math.Round(outlier.Treated*100) / 100
+		flagged = append(flagged, OutlierPoint{
+			Period:  point.Period,
+			Total:   point.Total,
+			Lower:   math.Round(outlier.Lower*100) / 100,
+			Upper:   math.Round(outlier.Upper*100) / 100,
+			Treated: treated[outlier.Index].Total,
+		})
+	}
+	return treated, flagged, nil
+}
+
+// forecastHolidays returns the configured holidays from the start of the
+// request history to the end of the forecast for the additive method, which
+// estimates their effect
+func forecastHolidays(request ForecastRequest, timePeriod string) []forecast.Holiday {
+	if forecast.Method(strings.ToLower(request.Method)) != forecast.MethodAdditive {
+		return nil
+	}
+
+	var first time.Time
+	for _, point := range request.TimeSeriesData {
+		date, err := forecast.ParsePeriod(point.Period)
+		if err == nil && (first.IsZero() || date.Before(first)) {
+			first = date
+		}
+	}
+	history := make([]forecast.Point, 0, len(request.TimeSeriesData))
+	for _, point := range request.TimeSeriesData {
+		history = append(history, forecast.Point{Period: point.Period, Total: point.Total})
+	}
+	latest, ok := forecast.LatestPeriod(history)
+	if !ok {
+		return nil
+	}
+
+	store, err := prompts.Default()
+	if err != nil {
+		log.Printf("Failed to load holidays for the additive forecast: %v", err)
+		return nil
+	}
+
+	var holidays []forecast.Holiday
+	for _, holiday := range store.Holidays(first, forecast.Step(latest, timePeriod, request.forecastPeriods(timePeriod)+1)) {
+		date, err := time.Parse("2006-01-02", holiday.Date)
+		if err != nil {
+			continue
+		}
+		holidays = append(holidays, forecast.Holiday{Date: date, Name: holiday.Name})
 	}
+	return holidays
+}
 
-	log.Printf("Using ChatGPT for %s forecasting with API key: %s...", timePeriod, apiKey[:7])
+// generateForecastForPeriod sends data to the request's LLM provider for
+// forecasting a specific time period, running each stage under its own
+// timeout within ctx. The model and prompt version are recorded in meta once
+// the prompt is built, and the token usage once the LLM replies. The reply
+// is validated against the statistical forecast fallback, which replaces the
+// periods the LLM got wrong, and the repairs are recorded in meta. With
+// onPoint, the reply is streamed and onPoint is called with each forecast
+// point as soon as it is complete.
+func generateForecastForPeriod(ctx context.Context, timeouts deadline.Timeouts, request ForecastRequest, timePeriod string, fallback []TimeSeriesPoint, meta *ForecastMeta, onPoint func(TimeSeriesPoint)) ([]TimeSeriesPoint, string, error) {
+	client, err := newLLMClient(request.Provider)
+	if err != nil {
+		return nil, "", err
+	}
+
+	log.Printf("Using %s %s for %s forecasting", client.Provider(), client.Model(), timePeriod)
 
 	// Prepare the prompt for ChatGPT
-	prompt := buildForecastPromptForPeriod(request, timePeriod)
+	var prompt, promptVersion string
+	err = timeouts.Run(ctx, deadline.StagePrompt, func(ctx context.Context) error {
+		var err error
+		prompt, promptVersion, err = buildForecastPromptForPeriod(request, timePeriod)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	meta.Model = request.Model
+	if meta.Model == "" {
+		meta.Model = client.Model()
+	}
+	meta.PromptVersion = promptVersion
 
 	// Create ChatGPT request
 	chatGPTRequest := ChatGPTRequest{
-		Model: "gpt-3.5-turbo", // Use 3.5-turbo for better compatibility
+		Model:       request.Model,
+		Temperature: request.Temperature,
+		MaxTokens:   request.MaxTokens,
 		Messages: []Message{
 			{
 				Role:    "system",
-				Content: "You are a data analyst specializing in time series forecasting. Provide forecasts in JSON format with an array of objects containing 'period' and 'total' fields.",
+				Content: "You are a data analyst specializing in time series forecasting. Provide forecasts in JSON format with an array of objects containing 'period', 'total', and 80% and 95% confidence bounds in 'lower80', 'upper80', 'lower95', and 'upper95' fields.",
 			},
 			{
 				Role:    "user",
@@ -157,180 +786,308 @@ func generateForecastForPeriod(request ForecastRequest, timePeriod string) ([]Ti
 	}
 
 	// Send request to ChatGPT
-	response, err := sendChatGPTRequest(apiKey, chatGPTRequest)
+	var response *ChatGPTResponse
+	err = timeouts.Run(ctx, deadline.StageLLM, func(ctx context.Context) error {
+		var err error
+		if onPoint != nil {
+			points := &forecastPointStream{onPoint: onPoint}
+			response, err = client.ChatStream(ctx, chatGPTRequest, points.write)
+		} else {
+			response, err = client.Chat(ctx, chatGPTRequest)
+		}
+		return err
+	})
 	if err != nil {
-		log.Printf("ChatGPT request failed: %v", err)
-		return nil, "", fmt.Errorf("ChatGPT request failed: %v", err)
+		log.Printf("LLM request failed: %v", err)
+		return nil, "", fmt.Errorf("LLM request failed: %w", err)
 	}
+	meta.Usage = response.usage()
 
 	// Parse ChatGPT response
-	forecast, rawResponse, err := parseSinglePeriodChatGPTResponse(response)
+	var (
+		forecast    []TimeSeriesPoint
+		rawResponse string
+	)
+	err = timeouts.Run(ctx, deadline.StageParse, func(ctx context.Context) error {
+		var err error
+		forecast, rawResponse, err = parseSinglePeriodChatGPTResponse(response)
+		if err != nil {
+			return err
+		}
+		forecast, meta.Repairs, err = validateForecast(forecast, fallback, timePeriod)
+		return err
+	})
 	if err != nil {
 		log.Printf("Failed to parse ChatGPT response: %v", err)
-		return nil, "", fmt.Errorf("failed to parse ChatGPT response: %v", err)
+		return nil, "", fmt.Errorf("failed to parse ChatGPT response: %w", err)
+	}
+	if len(meta.Repairs) > 0 {
+		log.Printf("Repaired %d points of the %s forecast", len(meta.Repairs), timePeriod)
 	}
 
-	return forecast, rawResponse, nil
+	return fillIntervals(forecast, request.TimeSeriesData), rawResponse, nil
 }
 
-// buildForecastPromptForPeriod creates the prompt for single-period ChatGPT forecasting
-func buildForecastPromptForPeriod(request ForecastRequest, timePeriod string) string {
+// fillIntervals gives forecast points without bands, or with bands that
+// don't contain their total, the statistical bands from the history's spread
+func fillIntervals(points []TimeSeriesPoint, history []TimeSeriesPoint) []TimeSeriesPoint {
+	spread := forecast.Spread(forecastHistory(history))
+	for i, point := range forecastHistory(points) {
+		if forecast.ValidIntervals(point.Total, point.Intervals) {
+			continue
+		}
+		points[i].Intervals = nil
+		for _, interval := range forecast.Intervals(point.Total, spread, i+1) {
+			points[i].Intervals = append(points[i].Intervals, ConfidenceInterval(interval))
+		}
+	}
+	return points
+}
+
+// getOpenAIAPIKey returns the OpenAI API key from the environment after
+// checking its format. Ollama needs no key, and only keys for the public
+// OpenAI API must start with sk-.
+func getOpenAIAPIKey(config openAIConfig) (string, error) {
+	// Get ChatGPT API key from environment
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if config.Ollama {
+		return apiKey, nil
+	}
+	if apiKey == "" {
+		log.Printf("No OpenAI API key found")
+		return "", fmt.Errorf("no OpenAI API key found")
+	}
+
+	// Azure and OpenAI-compatible proxies issue their own key formats
+	if config.Azure || config.BaseURL != defaultOpenAIBaseURL {
+		return apiKey, nil
+	}
+
+	// Check if we have a valid API key
+	if len(apiKey) < 10 {
+		log.Printf("No valid OpenAI API key found")
+		return "", fmt.Errorf("invalid OpenAI API key")
+	}
+
+	// Validate API key format (should start with sk-)
+	if apiKey[:3] != "sk-" {
+		log.Printf("Invalid OpenAI API key format")
+		return "", fmt.Errorf("invalid OpenAI API key format")
+	}
+
+	return apiKey, nil
+}
+
+// buildForecastPromptForPeriod renders the prompt template for single-period
+// ChatGPT forecasting and returns it with the template version
+func buildForecastPromptForPeriod(request ForecastRequest, timePeriod string) (string, string, error) {
+	store, err := prompts.Default()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load prompt configuration: %v", err)
+	}
+
 	// Filter to only include the past 12 months of data
 	filteredData := filterToLast12Months(request.TimeSeriesData)
 
-	// Convert time series data to XML format
-	xmlData := "<historical_data>\n"
+	data := prompts.ForecastData{Periods: request.forecastPeriods(timePeriod)}
 	for _, point := range filteredData {
-		xmlData += fmt.Sprintf("  <data_point>\n    <period>%s</period>\n    <total>%.2f</total>\n  </data_point>\n", point.Period, point.Total)
+		data.History = append(data.History, prompts.Point{Period: point.Period, Total: point.Total})
 	}
-	xmlData += "</historical_data>"
 
-	// Get forecast periods based on time period
-	forecastPeriods := getForecastPeriods(timePeriod)
-	var periodLabel string
 	switch timePeriod {
 	case "day":
-		periodLabel = "daily"
+		data.PeriodLabel = "daily"
 	case "week":
-		periodLabel = "weekly"
+		data.PeriodLabel = "weekly"
+	case "iso_week":
+		data.PeriodLabel = "ISO-week"
 	case "month":
-		periodLabel = "monthly"
+		data.PeriodLabel = "monthly"
+	case "fiscal":
+		data.PeriodLabel = "fiscal-period"
 	default:
-		periodLabel = "period"
+		data.PeriodLabel = "period"
 	}
 
-	prompt := fmt.Sprintf(`
-You are a data analyst specializing in time series forecasting. You are given historical %s sales data for a single category.
-Using this historical data, provide a %s sales forecast for the next %d periods, highlighting potential seasonal fluctuations.
-
-Things to consider:
- - Sales data is for a single category of multiple products.
- - The response should follow the JSON format below.
- - Consider trends, seasonality, and patterns in the data.
- - Remove any data points that are anomalies or outliers.
-
-<historical_data>
-%s
-</historical_data>
-
-Please provide the forecast in JSON response format like this:
-[
-  {"period": "2024-01-01", "total": 1500.00},
-  {"period": "2024-01-02", "total": 1600.00}
-]
-
-Consider trends, seasonality, and patterns in the data.`,
-		periodLabel, periodLabel, forecastPeriods, xmlData)
+	// Include holidays from the start of the history to the end of the forecast
+	var first, latest time.Time
+	for _, point := range filteredData {
+		date, err := forecast.ParsePeriod(point.Period)
+		if err != nil {
+			continue
+		}
+		if first.IsZero() || date.Before(first) {
+			first = date
+		}
+		if date.After(latest) {
+			latest = date
+		}
+	}
+	if !first.IsZero() {
+		data.Holidays = store.Holidays(first, forecast.Step(latest, timePeriod, data.Periods))
+	}
+	data.Adjustments = scenarioPromptAdjustments(request.Scenario)
 
-	return prompt
+	return store.RenderForecast(data)
 }
 
-// sendChatGPTRequest sends a request to the ChatGPT API
-func sendChatGPTRequest(apiKey string, request ChatGPTRequest) (*ChatGPTResponse, error) {
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		return nil, err
+// parseSinglePeriodChatGPTResponse parses the single-period response from ChatGPT
+func parseSinglePeriodChatGPTResponse(response *ChatGPTResponse) ([]TimeSeriesPoint, string, error) {
+	if len(response.Choices) == 0 {
+		return nil, "", fmt.Errorf("no choices in ChatGPT response")
 	}
 
-	// Log the request for debugging (only first 200 chars to avoid logging sensitive data)
-	requestPreview := string(jsonData)
-	if len(requestPreview) > 200 {
-		requestPreview = requestPreview[:200] + "..."
-	}
-	log.Printf("Sending request to ChatGPT: %s", requestPreview)
+	content := response.Choices[0].Message.Content
 
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+	// Decode the JSON array from the content, which may be wrapped in
+	// markdown fences or surrounded by commentary
+	var points []llmForecastPoint
+	if err := decodeJSONArray(content, &points); err != nil {
+		return nil, content, fmt.Errorf("failed to parse single-period JSON: %v", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("User-Agent", "CraftDemo/1.0")
+	if len(points) == 0 {
+		return nil, content, fmt.Errorf("empty forecast in response")
+	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	forecast := make([]TimeSeriesPoint, len(points))
+	for i, point := range points {
+		forecast[i] = point.timeSeriesPoint()
 	}
-	defer resp.Body.Close()
+	return forecast, content, nil
+}
 
-	// Log response status for debugging
-	log.Printf("ChatGPT API response status: %d", resp.StatusCode)
+// llmForecastPoint is a forecast point as the prompt asks ChatGPT to write it
+type llmForecastPoint struct {
+	Period  string     `json:"period"`
+	Total   *llmNumber `json:"total"`
+	Lower80 *llmNumber `json:"lower80"`
+	Upper80 *llmNumber `json:"upper80"`
+	Lower95 *llmNumber `json:"lower95"`
+	Upper95 *llmNumber `json:"upper95"`
+}
 
-	if resp.StatusCode != http.StatusOK {
-		// Read and log the actual error response
-		bodyBytes, err := json.Marshal(resp.Body)
-		if err != nil {
-			log.Printf("Failed to read error response body: %v", err)
-		} else {
-			log.Printf("ChatGPT API error response: %s", string(bodyBytes))
+// timeSeriesPoint converts the point, with its bands when all four are set.
+// A missing total is NaN.
+func (p llmForecastPoint) timeSeriesPoint() TimeSeriesPoint {
+	point := TimeSeriesPoint{Period: p.Period, Total: math.NaN()}
+	if p.Total != nil {
+		point.Total = float64(*p.Total)
+	}
+	if p.Lower80 != nil && p.Upper80 != nil && p.Lower95 != nil && p.Upper95 != nil {
+		point.Intervals = []ConfidenceInterval{
+			{Level: 80, Lower: float64(*p.Lower80), Upper: float64(*p.Upper80)},
+			{Level: 95, Lower: float64(*p.Lower95), Upper: float64(*p.Upper95)},
 		}
+	}
+	return point
+}
 
-		// Check for specific error types
-		switch resp.StatusCode {
-		case 401:
-			return nil, fmt.Errorf("OpenAI API authentication failed - check your API key")
-		case 404:
-			return nil, fmt.Errorf("OpenAI API endpoint not found - check API version")
-		case 429:
-			return nil, fmt.Errorf("OpenAI API rate limit exceeded")
-		case 500:
-			return nil, fmt.Errorf("OpenAI API server error")
-		default:
-			return nil, fmt.Errorf("OpenAI API returned status: %d", resp.StatusCode)
+// llmNumber is a number as the LLM wrote it, also accepted as a numeric
+// string such as "1,234.50". Anything else, such as null or "NaN", decodes
+// as NaN so that only its point is repaired rather than the whole reply
+// failing to parse.
+type llmNumber float64
+
+// UnmarshalJSON decodes a number or numeric string, or NaN
+func (n *llmNumber) UnmarshalJSON(data []byte) error {
+	*n = llmNumber(math.NaN())
+	if string(data) == "null" {
+		return nil
+	}
+	var value float64
+	if err := json.Unmarshal(data, &value); err == nil {
+		*n = llmNumber(value)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		text = strings.ReplaceAll(strings.TrimSpace(text), ",", "")
+		if value, err := strconv.ParseFloat(text, 64); err == nil {
+			*n = llmNumber(value)
 		}
 	}
+	return nil
+}
 
-	var response ChatGPTResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, err
-	}
+// decodeJSONArray decodes the first JSON array in content that unmarshals into v.
+// Brackets inside string literals are ignored so nested or quoted brackets
+// don't cut the array short.
+func decodeJSONArray(content string, v any) error {
+	return decodeJSONValue(content, '[', ']', v)
+}
 
-	return &response, nil
+// decodeJSONObject decodes the first JSON object in content that unmarshals into v
+func decodeJSONObject(content string, v any) error {
+	return decodeJSONValue(content, '{', '}', v)
 }
 
-// parseSinglePeriodChatGPTResponse parses the single-period response from ChatGPT
-func parseSinglePeriodChatGPTResponse(response *ChatGPTResponse) ([]TimeSeriesPoint, string, error) {
-	if len(response.Choices) == 0 {
-		return nil, "", fmt.Errorf("no choices in ChatGPT response")
+// decodeJSONValue decodes the first value delimited by opening/closing in content
+// that unmarshals into v
+func decodeJSONValue(content string, opening, closing byte, v any) error {
+	kind := "array"
+	if opening == '{' {
+		kind = "object"
 	}
 
-	content := response.Choices[0].Message.Content
-
-	// Try to extract JSON from the response
-	// Look for JSON array in the content
-	start := 0
-	end := len(content)
+	start := strings.IndexByte(content, opening)
+	for start >= 0 {
+		end := matchingBracket(content, start, opening, closing)
+		if end < 0 {
+			return fmt.Errorf("unterminated JSON %s in response", kind)
+		}
 
-	// Find the start of JSON array
-	for i := 0; i < len(content)-1; i++ {
-		if content[i] == '[' {
-			start = i
-			break
+		if err := json.Unmarshal([]byte(content[start:end+1]), v); err == nil {
+			return nil
 		}
-	}
 
-	// Find the end of JSON array
-	for i := len(content) - 1; i > start; i-- {
-		if content[i] == ']' {
-			end = i + 1
+		// Not the value we want (e.g. "[1]" in prose), try the next opening bracket
+		next := strings.IndexByte(content[start+1:], opening)
+		if next < 0 {
 			break
 		}
+		start += next + 1
 	}
 
-	if start >= end {
-		return nil, content, fmt.Errorf("could not find JSON array in response")
-	}
+	return fmt.Errorf("could not find JSON %s in response", kind)
+}
 
-	jsonStr := content[start:end]
+// matchingBracket returns the index of the bracket closing the one at start,
+// or -1 if it is never closed
+func matchingBracket(content string, start int, opening, closing byte) int {
+	depth := 0
+	inString := false
+	escaped := false
+
+	for i := start; i < len(content); i++ {
+		ch := content[i]
+
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
 
-	var forecast []TimeSeriesPoint
-	if err := json.Unmarshal([]byte(jsonStr), &forecast); err != nil {
-		return nil, content, fmt.Errorf("failed to parse single-period JSON: %v", err)
+		switch ch {
+		case '"':
+			inString = true
+		case opening:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
 	}
 
-	return forecast, content, nil
+	return -1
 }
 
 // getForecastPeriods returns the number of periods to forecast based on time period
@@ -338,15 +1095,62 @@ func getForecastPeriods(timePeriod string) int {
 	switch timePeriod {
 	case "day":
 		return 14 // 14 days of forecasts
-	case "week":
+	case "week", "iso_week":
 		return 4 // 4 weeks of forecasts
-	case "month":
-		return 6 // 6 months of forecasts
+	case "month", "fiscal":
+		return 6 // 6 months or fiscal periods of forecasts
 	default:
 		return 12 // Default fallback
 	}
 }
 
+// Longest horizons a forecast request may ask for
+const (
+	maxHorizonDays   = 366
+	maxHorizonWeeks  = 104
+	maxHorizonMonths = 36
+)
+
+// validateHorizons checks the requested horizons; zero means the default
+func (r ForecastRequest) validateHorizons() error {
+	for _, horizon := range []struct {
+		name  string
+		value int
+		max   int
+	}{{"horizonDays", r.HorizonDays, maxHorizonDays}, {"horizonWeeks", r.HorizonWeeks, maxHorizonWeeks}, {"horizonMonths", r.HorizonMonths, maxHorizonMonths}} {
+		if horizon.value < 0 || horizon.value > horizon.max {
+			return fmt.Errorf("%s must be between 0 and %d, where 0 uses the default horizon", horizon.name, horizon.max)
+		}
+	}
+	return nil
+}
+
+// validateLLM checks the requested LLM provider and chat settings
+func (r ForecastRequest) validateLLM() error {
+	if _, err := llmProviderName(r.Provider); err != nil {
+		return err
+	}
+	return validateChatSettings(r.Temperature, r.MaxTokens)
+}
+
+// forecastPeriods returns the number of periods to forecast: the request's
+// horizon for the time period, or the default
+func (r ForecastRequest) forecastPeriods(timePeriod string) int {
+	var horizon int
+	switch timePeriod {
+	case "day":
+		horizon = r.HorizonDays
+	case "week", "iso_week":
+		horizon = r.HorizonWeeks
+	case "month", "fiscal":
+		horizon = r.HorizonMonths
+	}
+	if horizon > 0 {
+		return horizon
+	}
+	return getForecastPeriods(timePeriod)
+}
+
 // filterToLast12Months filters time series data to only include the past 12 months
 func filterToLast12Months(data []TimeSeriesPoint) []TimeSeriesPoint {
 	if len(data) == 0 {
@@ -356,19 +1160,10 @@ func filterToLast12Months(data []TimeSeriesPoint) []TimeSeriesPoint {
 	// Find the latest date in the data
 	var latestDate time.Time
 	for _, point := range data {
-		// Try to parse the period as different date formats
-		var date time.Time
-		var err error
-
-		// Try YYYY-MM-DD format first
-		date, err = time.Parse("2006-01-02", point.Period)
+		// Skip this point if we can't parse it
+		date, err := forecast.ParsePeriod(point.Period)
 		if err != nil {
-			// Try YYYY-MM format
-			date, err = time.Parse("2006-01", point.Period)
-			if err != nil {
-				// Skip this point if we can't parse it
-				continue
-			}
+			continue
 		}
 
 		if date.After(latestDate) {
@@ -382,18 +1177,10 @@ func filterToLast12Months(data []TimeSeriesPoint) []TimeSeriesPoint {
 	// Filter data to only include points from the last 12 months
 	var filteredData []TimeSeriesPoint
 	for _, point := range data {
-		var date time.Time
-		var err error
-
-		// Try YYYY-MM-DD format first
-		date, err = time.Parse("2006-01-02", point.Period)
+		// Skip this point if we can't parse it
+		date, err := forecast.ParsePeriod(point.Period)
 		if err != nil {
-			// Try YYYY-MM format
-			date, err = time.Parse("2006-01", point.Period)
-			if err != nil {
-				// Skip this point if we can't parse it
-				continue
-			}
+			continue
 		}
 
 		// Include only data from the last 12 months
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/forecast"
+)
+
+// forecastReplySeeds are LLM replies of the shapes seen in practice:
+// fenced, wrapped in prose, nested, truncated, and with unicode
+var forecastReplySeeds = []string{
+	`[{"period":"2024-01-01","total":100,"lower80":90,"upper80":110,"lower95":80,"upper95":120}]`,
+	"```json\n[{\"period\":\"2024-01-01\",\"total\":100}]\n```",
+	"Here is the forecast [1]:\n```\n[{\"period\":\"2024-01-01\",\"total\":\"1,234.50\"}]\n```\nLet me know [if] you need more.",
+	`[{"period":"2024-01-01","total":100,"notes":{"trend":[1,[2,{"x":"]"}]]}}]`,
+	`[{"period":"2024-01-01","total":100},{"period":"2024-02-01","tot`,
+	`[{"period":"2024-01-01","total":100}`,
+	`[{"period":"2024-01-01","total":null},{"period":"2024-02-01","total":"NaN"}]`,
+	`[{"period":"2024-01-01","total":-5},{"period":"2024-02-01","total":1e400}]`,
+	`[{"period":"2024-W01","total":100},{"period":"FY2024-P01","total":100}]`,
+	`[{"period":"2024年1月","total":100,"note":"prévision \"naïve\" 📈 ]"}]`,
+	"\ufeff[{\"period\":\"2024-01-01\",\"total\":100}]",
+	`{"forecast":[{"period":"2024-01-01","total":100}]}`,
+	`[]`,
+	`[[[[`,
+	`"]"[{"period":"2024-01-01","total":1}]`,
+	``,
+}
+
+func FuzzParseSinglePeriodChatGPTResponse(f *testing.F) {
+	for _, seed := range forecastReplySeeds {
+		f.Add(seed, uint(len(seed)/2))
+	}
+
+	f.Fuzz(func(t *testing.T, content string, split uint) {
+		response := &ChatGPTResponse{Choices: []Choice{{Message: Message{Role: "assistant", Content: content}}}}
+		points, raw, err := parseSinglePeriodChatGPTResponse(response)
+		if err == nil {
+			if len(points) == 0 {
+				t.Fatalf("accepted a reply with no points: %q", content)
+			}
+			if raw != content {
+				t.Fatalf("raw response %q, want %q", raw, content)
+			}
+			for _, point := range points {
+				if len(point.Intervals) != 0 && (len(point.Intervals) != 2 || point.Intervals[0].Level != 80 || point.Intervals[1].Level != 95) {
+					t.Fatalf("point %+v has malformed bands", point)
+				}
+			}
+		}
+
+		// The same reply streamed in two pieces only yields points with a
+		// period and a usable total
+		at := int(split % uint(len(content)+1))
+		stream := &forecastPointStream{onPoint: func(point TimeSeriesPoint) {
+			if point.Period == "" || !usableTotal(point.Total) {
+				t.Fatalf("streamed unusable point %+v from %q", point, content)
+			}
+		}}
+		stream.write(content[:at])
+		stream.write(content[at:])
+	})
+}
+
+func FuzzParseMultiPeriodChatGPTResponse(f *testing.F) {
+	for _, seed := range forecastReplySeeds {
+		f.Add(seed)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fallbacks := make(map[string][]TimeSeriesPoint)
+	for _, timePeriod := range []string{"day", "week", "month", "iso_week", "fiscal"} {
+		for i := range 3 {
+			fallbacks[timePeriod] = append(fallbacks[timePeriod], TimeSeriesPoint{
+				Period: forecast.FormatPeriod(forecast.Step(start, timePeriod, i), timePeriod),
+				Total:  100,
+			})
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, content string) {
+		response := &ChatGPTResponse{Choices: []Choice{{Message: Message{Role: "assistant", Content: content}}}}
+		points, _, err := parseSinglePeriodChatGPTResponse(response)
+		if err != nil {
+			return
+		}
+
+		// Every period's validation repairs the reply into a full forecast
+		// of usable points, or rejects it
+		for timePeriod, fallback := range fallbacks {
+			validated, _, err := validateForecast(points, fallback, timePeriod)
+			if err != nil {
+				continue
+			}
+			if len(validated) != len(fallback) {
+				t.Fatalf("%s: %d points, want %d", timePeriod, len(validated), len(fallback))
+			}
+			for i, point := range validated {
+				if point.Period != fallback[i].Period {
+					t.Fatalf("%s: point %d is for %q, want %q", timePeriod, i, point.Period, fallback[i].Period)
+				}
+				if !usableTotal(point.Total) || !usableIntervals(point.Intervals) {
+					t.Fatalf("%s: unusable point %+v from %q", timePeriod, point, content)
+				}
+			}
+		}
+	})
+}
+
+func TestFilterToLast12Months(t *testing.T) {
+	start := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		timePeriod string
+		periods    int
+	}{
+		{"day", 800},
+		{"week", 104},
+		{"month", 24},
+		{"iso_week", 104},
+		{"fiscal", 24},
+	} {
+		t.Run(test.timePeriod, func(t *testing.T) {
+			var data []TimeSeriesPoint
+			var dates []time.Time
+			for i := range test.periods {
+				date := forecast.Step(start, test.timePeriod, i)
+				dates = append(dates, date)
+				data = append(data, TimeSeriesPoint{Period: forecast.FormatPeriod(date, test.timePeriod), Total: 100})
+			}
+
+			cutoff := dates[len(dates)-1].AddDate(0, -12, 0)
+			want := 0
+			for _, date := range dates {
+				if !date.Before(cutoff) {
+					want++
+				}
+			}
+
+			filtered := filterToLast12Months(data)
+			if len(filtered) != want {
+				t.Fatalf("kept %d points, want %d", len(filtered), want)
+			}
+			if last := filtered[len(filtered)-1].Period; last != data[len(data)-1].Period {
+				t.Errorf("last point is for %s, want %s", last, data[len(data)-1].Period)
+			}
+		})
+	}
+}
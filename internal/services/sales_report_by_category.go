@@ -1,20 +1,90 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
-	"github.com/bokor/craft-demo/internal/database"
+	"github.com/bokor/craft-demo/internal/chaos"
+	"github.com/bokor/craft-demo/internal/fx"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/repository"
 	"github.com/labstack/echo/v4"
+	"google.golang.org/protobuf/proto"
 )
 
 // CategoryTotal represents the total amount for a category
 type CategoryTotal struct {
-	CategoryName string  `json:"category_name"`
-	TotalAmount  float64 `json:"total_amount"`
+	CategoryName string `json:"category_name"`
+	// TotalAmount is the net revenue: GrossSales less Discounts and Refunds
+	TotalAmount float64 `json:"total_amount"`
+	// GrossSales is the list price of the items sold
+	GrossSales float64 `json:"gross_sales"`
+	// Discounts is how much less than list price the items sold for
+	Discounts float64 `json:"discounts"`
+	// Refunds is the amount refunded, as a positive amount
+	Refunds float64 `json:"refunds"`
+	// UnitsSold is the units sold, net of refunded units
+	UnitsSold int `json:"units_sold"`
+	// Transactions is the number of sale transactions, not counting refunds
+	Transactions int `json:"transactions"`
+	// AverageOrderValue is what the transactions sold for on average, after
+	// discounts and before refunds
+	AverageOrderValue float64 `json:"average_order_value"`
+}
+
+// add adds the amounts of other to t
+func (t *CategoryTotal) add(other CategoryTotal) {
+	t.TotalAmount += other.TotalAmount
+	t.GrossSales += other.GrossSales
+	t.Discounts += other.Discounts
+	t.Refunds += other.Refunds
+	t.UnitsSold += other.UnitsSold
+	t.Transactions += other.Transactions
+	t.average()
+}
+
+// average sets AverageOrderValue from the totals
+func (t *CategoryTotal) average() {
+	t.AverageOrderValue = 0
+	if t.Transactions > 0 {
+		t.AverageOrderValue = roundCents((t.GrossSales - t.Discounts) / float64(t.Transactions))
+	}
+}
+
+// roundCents returns t with its amounts rounded to whole cents
+func (t CategoryTotal) roundCents() CategoryTotal {
+	t.TotalAmount = roundCents(t.TotalAmount)
+	t.GrossSales = roundCents(t.GrossSales)
+	t.Discounts = roundCents(t.Discounts)
+	t.Refunds = roundCents(t.Refunds)
+	t.AverageOrderValue = roundCents(t.AverageOrderValue)
+	return t
+}
+
+// SalesReportQuery represents the filters applied to the sales report
+type SalesReportQuery struct {
+	StartDate string
+	EndDate   string
+	// IncludeDeleted includes soft-deleted warehouse rows in the totals
+	IncludeDeleted bool
+	// TimeZone is the IANA time zone sales are dated in, or empty for UTC
+	TimeZone string
+	// Currency is the ISO 4217 currency totals are converted into, or empty
+	// to keep amounts as recorded, which fails when they were recorded in
+	// several currencies unless SumCurrencies is set
+	Currency string
+	// SumCurrencies sums amounts recorded in different currencies as they
+	// are when Currency is empty
+	SumCurrencies bool
+	// Statuses are the transaction statuses totalled, or empty for all of
+	// them, netting refunds against sales
+	Statuses []string
+	// Rollup totals subcategories under their top-level category
+	Rollup bool
 }
 
 // SalesReportResponse represents the response structure
@@ -24,133 +94,318 @@ type SalesReportResponse struct {
 
 // GetSalesReportByCategory handles the API request for sales report by category
 // @Summary Get sales report by category
-// @Description Returns aggregated sales data by date and category with calculated total amounts
+// @Description Returns aggregated sales data by date and category with calculated total amounts: the net revenue in total_amount, the gross sales at list price, discounts, and refunds it's made of, and the units sold, transactions, and average order value. Set period to group by week or month (keyed by first day), ISO week (keyed like 2024-W37), or fiscal period of the configured fiscal calendar (keyed like FY2025-P01). Sales are dated in UTC unless tz, the zoneinfo claim of the token, or REPORT_TIMEZONE names another time zone, in which case they are dated by the local time of their transaction. Set currency to convert totals recorded in other currencies into it at each day's exchange rate; the X-Report-Currency header names the currency converted into. Without currency or REPORT_CURRENCY, a range whose sales were recorded in several currencies is rejected with 400 unless grouped by currency. Set status to total only invoices, refunds (negative totals), or pending sales instead of netting them all. Set rollup=true to total each category and its subcategories under its top-level category (department). Set group_by to group by any combination of category, store, customer, currency (which keeps each currency's totals unconverted), and status, returned flat (an entry per combination) or nested by each dimension in turn. Use format=csv (or Accept: text/csv) to download one row per period and category, or per period and combination with group_by, format=xlsx for an Excel workbook with a summary sheet and a sheet per category, each with a Total row, format=arrow to stream one row per date and category as Arrow IPC record batches, format=ndjson to stream one JSON object per period and category as the rows are read, for exports too large to build in memory, or send Accept: application/x-protobuf for the craftdemo.v1.SalesReportByCategory message or Accept: application/msgpack for the JSON body as MessagePack.
 // @Tags sales
 // @Accept json
 // @Produce json
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce application/vnd.apache.arrow.stream
+// @Produce application/x-ndjson
+// @Produce application/x-protobuf
+// @Produce application/msgpack
 // @Param start_date query string false "Start date in YYYY-MM-DD format (defaults to 30 days ago)"
 // @Param end_date query string false "End date in YYYY-MM-DD format (defaults to today)"
-// @Success 200 {object} map[string][]CategoryTotal "Sales report data with dates as keys and category arrays as values"
-// @Failure 400 {object} map[string]string "Bad request - invalid date format"
-// @Failure 500 {object} map[string]string "Internal server error"
+// @Param include_deleted query bool false "Include soft-deleted warehouse rows (defaults to false)"
+// @Param currency query string false "ISO 4217 currency to convert totals into at each day's exchange rate (defaults to REPORT_CURRENCY; without either, amounts are summed as recorded)"
+// @Param status query string false "Comma-separated transaction statuses to total: invoice, refund, pending (defaults to all, netting refunds against sales)"
+// @Param tz query string false "IANA time zone sales are dated in, e.g. Australia/Sydney (defaults to the token's zoneinfo, then REPORT_TIMEZONE, then UTC)"
+// @Param period query string false "Period to group by: day, week, iso_week, month, quarter, or fiscal (defaults to day)"
+// @Param granularity query string false "Same as period, for clients that name it granularity"
+// @Param fill query string false "zero to include every period and category, with zero totals where there were no sales, or none (default)"
+// @Param rollup query bool false "Total subcategories under their top-level category, reporting departments only (defaults to false)"
+// @Param group_by query string false "Comma-separated dimensions to group by: category, store, customer, currency, status (defaults to category)"
+// @Param shape query string false "Shape of reports grouped by other than category alone: flat (default) or nested"
+// @Param format query string false "Response format: json, csv, xlsx, ndjson, or arrow (defaults to json); arrow supports day, week, and month periods"
+// @Param limit query int false "Periods per page, 1 to 1000 (defaults to 100 with cursor); the response is then a ReportPage"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} map[string][]CategoryTotal "Sales report data with periods as keys and category arrays as values"
+// @Success 200 {object} map[string][]GroupedTotal "With group_by, one entry per combination of dimension values in each period (flat shape)"
+// @Success 200 {object} CategoryReportLine "With format=ndjson, one line per period and category"
+// @Success 200 {object} ReportPage "With limit or cursor, a page of periods and the cursor of the next one"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid parameters, a missing exchange rate, or sales in several currencies without currency"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
 // @Router /sales/report/category [get]
 func GetSalesReportByCategory(c echo.Context) error {
+	ctx := c.Request().Context()
 	// Get query parameters
-	startDate := c.QueryParam("start_date")
-	endDate := c.QueryParam("end_date")
-
-	// Validate date parameters - use a wider default range to ensure we have data
-	if startDate == "" {
-		startDate = time.Now().AddDate(0, -6, 0).Format("2006-01-02") // Default to last 6 months
+	timeZone, err := reportTimeZone(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
 	}
-	if endDate == "" {
-		endDate = time.Now().Format("2006-01-02") // Default to today
+	startDate, endDate, err := parseReportDateRangeIn(c, timeZone)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
 	}
-
-	// Validate date format
-	if _, err := time.Parse("2006-01-02", startDate); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid start_date format. Use YYYY-MM-DD",
-		})
+	includeDeleted := c.QueryParam("include_deleted") == "true"
+	period := c.QueryParam("period")
+	if granularity := c.QueryParam("granularity"); granularity != "" {
+		if period != "" && period != granularity {
+			return httperror.JSON(c, http.StatusBadRequest, "Set period or granularity, not both")
+		}
+		period = granularity
 	}
-	if _, err := time.Parse("2006-01-02", endDate); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{
-			"error": "Invalid end_date format. Use YYYY-MM-DD",
-		})
+	if period == "" {
+		period = "day"
+	}
+	if _, ok := reportPeriods[period]; !ok {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid period. Use day, week, iso_week, month, quarter, or fiscal")
+	}
+	if err := validateReportFormat(c, "csv", "xlsx", "ndjson", "arrow"); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if err := checkArrowPeriod(c.QueryParam("format"), period); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	zeroFill, err := reportZeroFill(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	rollup, err := reportRollup(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	dimensions, err := parseGroupBy(c.QueryParam("group_by"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	statuses, err := parseReportStatuses(c.QueryParam("status"))
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	currency, requested, err := reportCurrency(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if groupsByCurrency(dimensions) {
+		// Totals grouped by currency stay in their own currency
+		if requested {
+			return httperror.JSON(c, http.StatusBadRequest, "Set currency or group_by=currency, not both")
+		}
+		currency = ""
+	}
+	shape := c.QueryParam("shape")
+	switch shape {
+	case "":
+		shape = "flat"
+	case "flat", "nested":
+	default:
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid shape. Use flat or nested")
+	}
+	if !isCategoryOnly(dimensions) && wantsArrow(c) {
+		return httperror.JSON(c, http.StatusBadRequest, "format=arrow supports group_by=category only")
+	}
+	if !isCategoryOnly(dimensions) && wantsXLSX(c) {
+		return httperror.JSON(c, http.StatusBadRequest, "format=xlsx supports group_by=category only")
+	}
+	if !isCategoryOnly(dimensions) && wantsNDJSON(c) {
+		return httperror.JSON(c, http.StatusBadRequest, "format=ndjson supports group_by=category only")
+	}
+	pagination, queryStart, queryEnd, err := parseReportPagination(c, startDate, endDate, period)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if pagination.enabled && wantsNDJSON(c) {
+		return httperror.JSON(c, http.StatusBadRequest, "format=ndjson doesn't support limit or cursor")
 	}
 
 	// Get database connection
-	db, err := database.GetDBConnection()
+	db, err := getDB()
 	if err != nil {
-		log.Printf("Database connection failed: %v, falling back to sample data", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Database connection failed",
-		})
+		log.Printf("Database connection failed: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Database connection failed")
+	}
+
+	reportQuery := SalesReportQuery{
+		StartDate:      queryStart,
+		EndDate:        queryEnd,
+		IncludeDeleted: includeDeleted,
+		TimeZone:       timeZone,
+		Currency:       currency,
+		Statuses:       statuses,
+		Rollup:         rollup,
+	}
+	if currency != "" {
+		c.Response().Header().Set(headerReportCurrency, currency)
+	}
+	if !isCategoryOnly(dimensions) {
+		return getGroupedSalesReport(c, db, reportQuery, dimensions, period, shape, zeroFill, pagination)
+	}
+	if wantsNDJSON(c) {
+		return streamCategoryReport(c, db, reportQuery, period, zeroFill)
 	}
-	defer db.Close()
 
 	// Query sales data
-	salesData, err := querySalesData(db, startDate, endDate)
+	salesData, err := QuerySalesData(ctx, db, reportQuery)
 	if err != nil {
-		log.Printf("Failed to query sales data: %v, falling back to sample data", err)
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Failed to query sales data",
-		})
+		log.Printf("Failed to query sales data: %v", err)
+		return respondReportError(c, err)
+	}
+
+	if pagination.notFound(len(salesData) == 0 && !zeroFill) {
+		return httperror.JSON(c, http.StatusNotFound, "No sales data found")
 	}
 
-	// If no data found, return sample data for testing
-	if len(salesData) == 0 {
-		return c.JSON(http.StatusNotFound, map[string]string{
-			"error": "No sales data found",
-		})
+	if period != "day" {
+		salesData, err = groupCategoryReport(salesData, period)
+		if err != nil {
+			log.Printf("Failed to group sales data: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to query sales data")
+		}
+	}
+
+	if zeroFill {
+		if salesData, err = fillCategoryReport(ctx, db, salesData, queryStart, queryEnd, period, rollup); err != nil {
+			log.Printf("Failed to zero-fill report: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to query sales data")
+		}
+	}
+
+	body := pagination.envelope(c, salesData)
+
+	if wantsArrow(c) {
+		return writeCategoryReportArrow(c, salesData)
+	}
+	if wantsCSV(c) {
+		return writeCategoryReportCSV(c, salesData, startDate, endDate)
+	}
+	if wantsXLSX(c) {
+		workbook, err := buildCategoryReportXLSX(salesData)
+		if err != nil {
+			log.Printf("Failed to build workbook: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to build workbook")
+		}
+		defer workbook.Close()
+		return writeXLSX(c, http.StatusOK, fmt.Sprintf("sales-report-category-%s-%s", startDate, endDate), workbook)
 	}
 
 	// Return the response - each date key directly contains the categories array
-	return c.JSON(http.StatusOK, salesData)
+	return respondNegotiated(c, http.StatusOK, body, func() proto.Message {
+		return categoryReportProto(salesData)
+	})
 }
 
-// querySalesData queries the database and returns aggregated sales data
-func querySalesData(db *sql.DB, startDate, endDate string) (map[string][]CategoryTotal, error) {
-	query := `
-		SELECT
-			DATE(st.date_recorded) as date_recorded,
-			c.name as category_name,
-			SUM(st.total_amount) as total_amount
-		FROM sales_totals_by_category_dw st
-		JOIN categories c ON st.category_id = c.id
-		WHERE st.date_recorded >= $1 AND st.date_recorded <= $2
-		GROUP BY DATE(st.date_recorded), c.name
-		ORDER BY DATE(st.date_recorded), c.name
-	`
-
-	rows, err := db.Query(query, startDate, endDate)
-	if err != nil {
+// QuerySalesData returns the daily total of each category from the sales
+// report repository on db, keyed by date
+func QuerySalesData(ctx context.Context, db *sql.DB, reportQuery SalesReportQuery) (map[string][]CategoryTotal, error) {
+	if err := chaos.Inject(chaos.TargetDB); err != nil {
 		return nil, fmt.Errorf("failed to query sales data: %v", err)
 	}
-	defer rows.Close()
+
+	sales, err := salesReportRepository(db).SalesByCategory(ctx, repository.SalesReportFilter{
+		StartDate:      reportQuery.StartDate,
+		EndDate:        reportQuery.EndDate,
+		IncludeDeleted: reportQuery.IncludeDeleted,
+		TimeZone:       reportQuery.TimeZone,
+		Statuses:       reportQuery.Statuses,
+		Rollup:         reportQuery.Rollup,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	// Map to store results: date -> []CategoryTotal
 	result := make(map[string][]CategoryTotal)
+	converter := currencyConverter(reportQuery)
+	currencies := newCurrencyCheck(reportQuery)
+	for _, row := range sales {
+		if err := currencies.add(row.Currency); err != nil {
+			return nil, err
+		}
+		total, err := convertCategorySales(ctx, converter, row)
+		if err != nil {
+			return nil, err
+		}
 
-	for rows.Next() {
-		var (
-			dateRecorded string
-			categoryName string
-			totalAmount  float64
-		)
+		// A category's rows in each currency follow each other
+		date := row.Date.Format("2006-01-02")
+		totals := result[date]
+		if n := len(totals); n > 0 && totals[n-1].CategoryName == row.Category {
+			totals[n-1].add(total)
+			continue
+		}
+		result[date] = append(totals, total)
+	}
 
-		if err := rows.Scan(&dateRecorded, &categoryName, &totalAmount); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %v", err)
+	if converter != nil {
+		for _, totals := range result {
+			for i := range totals {
+				totals[i] = totals[i].roundCents()
+			}
 		}
+	}
+	return result, nil
+}
 
-		// Parse and format the date to remove timestamp
-		parsedDate, err := time.Parse("2006-01-02T15:04:05Z", dateRecorded)
+// convertCategorySales returns the amounts of a row converted by converter
+func convertCategorySales(ctx context.Context, converter *fx.Converter, row repository.CategorySales) (CategoryTotal, error) {
+	total := CategoryTotal{CategoryName: row.Category, UnitsSold: row.Units, Transactions: row.Transactions}
+	amounts := []struct {
+		from float64
+		to   *float64
+	}{
+		{row.Total, &total.TotalAmount},
+		{row.Gross, &total.GrossSales},
+		{row.Discounts, &total.Discounts},
+		{row.Refunds, &total.Refunds},
+	}
+	for _, amount := range amounts {
+		converted, err := converter.Convert(ctx, amount.from, row.Currency, row.Date)
 		if err != nil {
-			// Try alternative format if the first one fails
-			parsedDate, err = time.Parse("2006-01-02", dateRecorded)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse date %s: %v", dateRecorded, err)
-			}
+			return CategoryTotal{}, err
 		}
+		*amount.to = converted
+	}
+	total.average()
+	return total, nil
+}
 
-		// Format as YYYY-MM-DD
-		formattedDate := parsedDate.Format("2006-01-02")
+// parseReportDateRange reads and validates the start_date and end_date query
+// parameters, defaulting to the last 6 months
+func parseReportDateRange(c echo.Context) (string, string, error) {
+	return parseReportDateRangeIn(c, "")
+}
 
-		// Initialize the date slice if it doesn't exist
-		if result[formattedDate] == nil {
-			result[formattedDate] = []CategoryTotal{}
-		}
+// parseReportDateRangeIn is parseReportDateRange with the default dates in
+// the IANA time zone timeZone, or the server's when it's empty
+func parseReportDateRangeIn(c echo.Context, timeZone string) (string, string, error) {
+	startDate := c.QueryParam("start_date")
+	endDate := c.QueryParam("end_date")
 
-		// Add the category total to the slice
-		result[formattedDate] = append(result[formattedDate], CategoryTotal{
-			CategoryName: categoryName,
-			TotalAmount:  totalAmount,
-		})
+	now := time.Now()
+	if location, err := loadTimeZone(timeZone); timeZone != "" && err == nil {
+		now = now.In(location)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %v", err)
+	// Validate date parameters - use a wider default range to ensure we have data
+	if startDate == "" {
+		startDate = now.AddDate(0, -6, 0).Format("2006-01-02") // Default to last 6 months
+	}
+	if endDate == "" {
+		endDate = now.Format("2006-01-02") // Default to today
 	}
 
-	return result, nil
+	// Validate date format
+	if _, err := time.Parse("2006-01-02", startDate); err != nil {
+		return "", "", fmt.Errorf("Invalid start_date format. Use YYYY-MM-DD")
+	}
+	if _, err := time.Parse("2006-01-02", endDate); err != nil {
+		return "", "", fmt.Errorf("Invalid end_date format. Use YYYY-MM-DD")
+	}
+
+	return startDate, endDate, nil
+}
+
+// formatReportDate formats a scanned date or timestamp as YYYY-MM-DD
+func formatReportDate(dateRecorded string) (string, error) {
+	parsedDate, err := time.Parse("2006-01-02T15:04:05Z", dateRecorded)
+	if err != nil {
+		// Try alternative format if the first one fails
+		parsedDate, err = time.Parse("2006-01-02", dateRecorded)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse date %s: %v", dateRecorded, err)
+		}
+	}
+
+	return parsedDate.Format("2006-01-02"), nil
 }
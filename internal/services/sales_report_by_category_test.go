@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bokor/craft-demo/internal/benchdata"
+)
+
+func BenchmarkQuerySalesData(b *testing.B) {
+	db := benchdata.Open(b)
+	items, days := benchdata.Size(b)
+	benchdata.Quiet(b)
+	benchdata.Load(b, db, benchdata.SalesTotals(items, days))
+
+	query := SalesReportQuery{
+		StartDate: benchdata.Start.Format("2006-01-02"),
+		EndDate:   benchdata.End(days).Format("2006-01-02"),
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := QuerySalesData(context.Background(), db, query); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/repository"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+// CustomerTypeTotal represents the new vs returning customer split for a category
+type CustomerTypeTotal struct {
+	CategoryName       string  `json:"category_name"`
+	NewAmount          float64 `json:"new_amount"`
+	ReturningAmount    float64 `json:"returning_amount"`
+	NewCustomers       int     `json:"new_customers"`
+	ReturningCustomers int     `json:"returning_customers"`
+}
+
+// reportPeriods maps the supported period query values to date_trunc units.
+// ISO weeks are weeks labelled by their ISO key; fiscal periods have no unit
+// and are bounded by the fiscal calendar instead.
+var reportPeriods = map[string]string{
+	"day":      "day",
+	"week":     "week",
+	"iso_week": "week",
+	"month":    "month",
+	"quarter":  "quarter",
+	"fiscal":   "",
+}
+
+// GetSalesReportByCustomerType handles the API request for new vs returning customer revenue
+// @Summary Get new vs returning customer sales report
+// @Description Returns revenue split between new and returning customers per period and category. A customer is new in the period containing their first purchase and returning afterwards. Sales without a customer are excluded. Use format=csv (or Accept: text/csv) to download one row per period and category, format=arrow to stream the same rows as Arrow IPC record batches, format=ndjson to stream them as JSON lines as they are read, or send Accept: application/x-protobuf for the craftdemo.v1.SalesReportByCustomerType message or Accept: application/msgpack for the JSON body as MessagePack.
+// @Tags sales
+// @Accept json
+// @Produce json
+// @Produce text/csv
+// @Produce application/vnd.apache.arrow.stream
+// @Produce application/x-ndjson
+// @Produce application/x-protobuf
+// @Produce application/msgpack
+// @Param start_date query string false "Start date in YYYY-MM-DD format (defaults to 6 months ago)"
+// @Param end_date query string false "End date in YYYY-MM-DD format (defaults to today)"
+// @Param period query string false "Period to group by: day, week, iso_week, month, quarter, or fiscal (defaults to month)"
+// @Param fill query string false "zero to include every period and category, with zero totals where there were no sales, or none (default)"
+// @Param rollup query bool false "Total subcategories under their top-level category, reporting departments only (defaults to false)"
+// @Param format query string false "Response format: json, csv, ndjson, or arrow (defaults to json); arrow supports day, week, and month periods"
+// @Param limit query int false "Periods per page, 1 to 1000 (defaults to 100 with cursor); the response is then a ReportPage"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} map[string][]CustomerTypeTotal "Report data with period start dates (or ISO week and fiscal period keys) as keys and category arrays as values"
+// @Success 200 {object} CustomerTypeReportLine "With format=ndjson, one line per period and category"
+// @Success 200 {object} ReportPage "With limit or cursor, a page of periods and the cursor of the next one"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid parameters"
+// @Failure 404 {object} httperror.Envelope "No sales data found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /sales/report/customers [get]
+func GetSalesReportByCustomerType(c echo.Context) error {
+	ctx := c.Request().Context()
+	startDate, endDate, err := parseReportDateRange(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	period := c.QueryParam("period")
+	if period == "" {
+		period = "month"
+	}
+	unit, ok := reportPeriods[period]
+	if !ok {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid period. Use day, week, iso_week, month, quarter, or fiscal")
+	}
+	if err := validateReportFormat(c, "csv", "ndjson", "arrow"); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if err := checkArrowPeriod(c.QueryParam("format"), period); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	zeroFill, err := reportZeroFill(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	rollup, err := reportRollup(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	pagination, queryStart, queryEnd, err := parseReportPagination(c, startDate, endDate, period)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if pagination.enabled && wantsNDJSON(c) {
+		return httperror.JSON(c, http.StatusBadRequest, "format=ndjson doesn't support limit or cursor")
+	}
+
+	// Get database connection
+	db, err := getDB()
+	if err != nil {
+		log.Printf("Database connection failed: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Database connection failed")
+	}
+
+	if wantsNDJSON(c) {
+		return streamCustomerTypeReport(c, db, startDate, endDate, period, unit, zeroFill, rollup)
+	}
+
+	report, err := queryCustomerTypeData(ctx, db, queryStart, queryEnd, period, unit, rollup)
+	if err != nil {
+		log.Printf("Failed to query customer type data: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Failed to query sales data")
+	}
+
+	if pagination.notFound(len(report) == 0 && !zeroFill) {
+		return httperror.JSON(c, http.StatusNotFound, "No sales data found")
+	}
+
+	if zeroFill {
+		if report, err = fillCustomerTypeReport(ctx, db, report, queryStart, queryEnd, period, rollup); err != nil {
+			log.Printf("Failed to zero-fill report: %v", err)
+			return httperror.JSON(c, http.StatusInternalServerError, "Failed to query sales data")
+		}
+	}
+
+	body := pagination.envelope(c, report)
+
+	if wantsArrow(c) {
+		return writeCustomerTypeReportArrow(c, report)
+	}
+	if wantsCSV(c) {
+		return writeCustomerTypeReportCSV(c, report, startDate, endDate)
+	}
+	return respondNegotiated(c, http.StatusOK, body, func() proto.Message {
+		return customerTypeReportProto(report)
+	})
+}
+
+// queryCustomerTypeData queries the new vs returning revenue split per
+// period and category, or top-level category with rollup
+func queryCustomerTypeData(ctx context.Context, db *sql.DB, startDate, endDate, period, unit string, rollup bool) (map[string][]CustomerTypeTotal, error) {
+	result := make(map[string][]CustomerTypeTotal)
+	err := eachCustomerTypeTotal(ctx, db, startDate, endDate, period, unit, rollup, func(key string, total CustomerTypeTotal) error {
+		result[key] = append(result[key], total)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// eachCustomerTypeTotal calls fn with the new vs returning revenue split of
+// each period and category as it's read, ordered by period key and category
+// name. Periods are truncated to unit, or for fiscal periods (no unit) found
+// among the fiscal period starts of the range. With rollup, categories are
+// totalled under their top-level category.
+func eachCustomerTypeTotal(ctx context.Context, db *sql.DB, startDate, endDate, period, unit string, rollup bool, fn func(key string, total CustomerTypeTotal) error) error {
+	var fiscalStarts []string
+	if unit == "" {
+		start, _ := time.Parse("2006-01-02", startDate)
+		end, _ := time.Parse("2006-01-02", endDate)
+		fiscalStarts = fiscalPeriodStarts(start, end)
+	}
+
+	filter := repository.CustomerTypeFilter{StartDate: startDate, EndDate: endDate, Unit: unit, PeriodStarts: fiscalStarts, Rollup: rollup}
+	return salesReportRepository(db).EachCustomerTypeSales(ctx, filter, func(sales repository.CustomerTypeSales) error {
+		key := sales.PeriodStart.Format("2006-01-02")
+		if period == "iso_week" || period == "fiscal" {
+			key = reportPeriodKey(sales.PeriodStart, period)
+		}
+		return fn(key, CustomerTypeTotal{
+			CategoryName:       sales.Category,
+			NewAmount:          sales.NewAmount,
+			ReturningAmount:    sales.ReturningAmount,
+			NewCustomers:       sales.NewCustomers,
+			ReturningCustomers: sales.ReturningCustomers,
+		})
+	})
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// ComparisonRange is one of the two date ranges compared
+type ComparisonRange struct {
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	Days      int    `json:"days"`
+}
+
+// ComparisonTotals compares revenue in range A with range B
+type ComparisonTotals struct {
+	TotalA float64 `json:"total_a"`
+	TotalB float64 `json:"total_b"`
+	// Delta is total_a - total_b
+	Delta float64 `json:"delta"`
+	// PercentChange is the delta as a percentage of total_b, null when
+	// total_b is zero
+	PercentChange *float64 `json:"percent_change"`
+}
+
+// CategoryComparison compares a category's revenue in the two ranges
+type CategoryComparison struct {
+	CategoryName string `json:"category_name"`
+	ComparisonTotals
+}
+
+// PeriodComparison is the response of the period-over-period comparison
+type PeriodComparison struct {
+	// CompareTo is prior_period or prior_year when range B was derived from
+	// range A
+	CompareTo  string               `json:"compare_to,omitempty"`
+	RangeA     ComparisonRange      `json:"range_a"`
+	RangeB     ComparisonRange      `json:"range_b"`
+	Categories []CategoryComparison `json:"categories"`
+	Total      ComparisonTotals     `json:"total"`
+}
+
+// GetSalesComparison handles the API request for a period-over-period comparison
+// @Summary Compare category revenue between two date ranges
+// @Description Returns each category's revenue from the DW table in two arbitrary date ranges, such as this promo week and the last, with the change from range B to range A. Every category with revenue in either range is listed, with zero where it had none. The ranges may differ in length and overlap. Use format=csv or send Accept: text/csv to download one row per category followed by a Total row.
+// @Tags sales
+// @Produce json
+// @Produce text/csv
+// @Param range_a query string true "Range compared, as YYYY-MM-DD/YYYY-MM-DD (inclusive)"
+// @Param range_b query string true "Range compared against, as YYYY-MM-DD/YYYY-MM-DD (inclusive)"
+// @Param include_deleted query bool false "Include soft-deleted warehouse rows (defaults to false)"
+// @Param rollup query bool false "Total subcategories under their top-level category, comparing departments only (defaults to false)"
+// @Param format query string false "Response format: json or csv (defaults to json)"
+// @Success 200 {object} PeriodComparison "Category totals in both ranges with deltas"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid range"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /sales/report/compare [get]
+func GetSalesComparison(c echo.Context) error {
+	ctx := c.Request().Context()
+	var rangeA, rangeB ComparisonRange
+	for _, param := range []struct {
+		name   string
+		target *ComparisonRange
+	}{{"range_a", &rangeA}, {"range_b", &rangeB}} {
+		parsed, err := parseComparisonRange(param.name, c.QueryParam(param.name))
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, err.Error())
+		}
+		*param.target = parsed
+	}
+	includeDeleted := c.QueryParam("include_deleted") == "true"
+	rollup, err := reportRollup(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if err := validateReportFormat(c, "csv"); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	asCSV := wantsCSV(c)
+
+	return withDB(c, func(db *sql.DB) error {
+		comparison, err := queryComparison(ctx, db, rangeA, rangeB, includeDeleted, rollup)
+		if err != nil {
+			return err
+		}
+		if asCSV {
+			filename := fmt.Sprintf("sales-report-compare-%s-%s", rangeA.StartDate, rangeA.EndDate)
+			return writeComparisonCSV(c, filename, comparison)
+		}
+		return c.JSON(http.StatusOK, comparison)
+	})
+}
+
+// Ranges the comparison report compares against
+const (
+	compareToPriorPeriod = "prior_period"
+	compareToPriorYear   = "prior_year"
+)
+
+// GetPriorComparison handles the API request for comparing a range with the prior period or year
+// @Summary Compare category revenue with the prior period or prior year
+// @Description Returns each category's revenue from the DW table in the selected range and in the equivalent earlier range, with the change from the earlier range. prior_period compares with the same number of days just before the range, and prior_year with the same dates a year earlier (February 29 maps to February 28). The earlier range is returned as range_b. Use format=csv or send Accept: text/csv to download one row per category followed by a Total row.
+// @Tags sales
+// @Produce json
+// @Produce text/csv
+// @Param start_date query string false "Start date in YYYY-MM-DD format (defaults to 6 months ago)"
+// @Param end_date query string false "End date in YYYY-MM-DD format (defaults to today)"
+// @Param compare_to query string false "prior_period (default) or prior_year"
+// @Param include_deleted query bool false "Include soft-deleted warehouse rows (defaults to false)"
+// @Param rollup query bool false "Total subcategories under their top-level category, comparing departments only (defaults to false)"
+// @Param format query string false "Response format: json or csv (defaults to json)"
+// @Success 200 {object} PeriodComparison "Category totals in the range and the earlier range with deltas"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid parameters"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /sales/report/comparison [get]
+func GetPriorComparison(c echo.Context) error {
+	ctx := c.Request().Context()
+	startDate, endDate, err := parseReportDateRange(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	rangeA, err := parseComparisonRange("range", startDate+"/"+endDate)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "end_date must not be before start_date")
+	}
+
+	compareTo := c.QueryParam("compare_to")
+	if compareTo == "" {
+		compareTo = compareToPriorPeriod
+	}
+	rangeB, err := priorComparisonRange(rangeA, compareTo)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	includeDeleted := c.QueryParam("include_deleted") == "true"
+	rollup, err := reportRollup(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if err := validateReportFormat(c, "csv"); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	asCSV := wantsCSV(c)
+
+	return withDB(c, func(db *sql.DB) error {
+		comparison, err := queryComparison(ctx, db, rangeA, rangeB, includeDeleted, rollup)
+		if err != nil {
+			return err
+		}
+		comparison.CompareTo = compareTo
+		if asCSV {
+			filename := fmt.Sprintf("sales-report-comparison-%s-%s-%s", compareTo, rangeA.StartDate, rangeA.EndDate)
+			return writeComparisonCSV(c, filename, comparison)
+		}
+		return c.JSON(http.StatusOK, comparison)
+	})
+}
+
+// priorComparisonRange returns the range compareTo compares rangeA with: the
+// same number of days just before it, or the same dates a year earlier
+func priorComparisonRange(rangeA ComparisonRange, compareTo string) (ComparisonRange, error) {
+	start, _ := time.Parse("2006-01-02", rangeA.StartDate)
+	end, _ := time.Parse("2006-01-02", rangeA.EndDate)
+	switch compareTo {
+	case compareToPriorPeriod:
+		end = start.AddDate(0, 0, -1)
+		start = end.AddDate(0, 0, 1-rangeA.Days)
+	case compareToPriorYear:
+		start, end = yearEarlier(start), yearEarlier(end)
+	default:
+		return ComparisonRange{}, fmt.Errorf("Invalid compare_to. Use prior_period or prior_year")
+	}
+	return ComparisonRange{
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.Format("2006-01-02"),
+		Days:      int(end.Sub(start).Hours()/24) + 1,
+	}, nil
+}
+
+// yearEarlier returns the same date a year earlier, or February 28 for
+// February 29
+func yearEarlier(date time.Time) time.Time {
+	earlier := date.AddDate(-1, 0, 0)
+	if earlier.Day() != date.Day() {
+		earlier = earlier.AddDate(0, 0, -earlier.Day())
+	}
+	return earlier
+}
+
+// parseComparisonRange parses a YYYY-MM-DD/YYYY-MM-DD range
+func parseComparisonRange(name, value string) (ComparisonRange, error) {
+	if value == "" {
+		return ComparisonRange{}, fmt.Errorf("%s is required, as YYYY-MM-DD/YYYY-MM-DD", name)
+	}
+	startValue, endValue, _ := strings.Cut(value, "/")
+	start, err := time.Parse("2006-01-02", startValue)
+	if err != nil {
+		return ComparisonRange{}, fmt.Errorf("Invalid %s format. Use YYYY-MM-DD/YYYY-MM-DD", name)
+	}
+	end, err := time.Parse("2006-01-02", endValue)
+	if err != nil {
+		return ComparisonRange{}, fmt.Errorf("Invalid %s format. Use YYYY-MM-DD/YYYY-MM-DD", name)
+	}
+	if end.Before(start) {
+		return ComparisonRange{}, fmt.Errorf("%s must not end before it starts", name)
+	}
+	return ComparisonRange{
+		StartDate: startValue,
+		EndDate:   endValue,
+		Days:      int(end.Sub(start).Hours()/24) + 1,
+	}, nil
+}
+
+// queryComparison totals each category's revenue in both ranges, or each
+// top-level category's with rollup
+func queryComparison(ctx context.Context, db *sql.DB, rangeA, rangeB ComparisonRange, includeDeleted, rollup bool) (PeriodComparison, error) {
+	comparison := PeriodComparison{RangeA: rangeA, RangeB: rangeB, Categories: []CategoryComparison{}}
+
+	sales, err := salesReportRepository(db).CompareSales(ctx, repository.ComparisonFilter{
+		StartA:         rangeA.StartDate,
+		EndA:           rangeA.EndDate,
+		StartB:         rangeB.StartDate,
+		EndB:           rangeB.EndDate,
+		IncludeDeleted: includeDeleted,
+		Rollup:         rollup,
+	})
+	if err != nil {
+		return comparison, err
+	}
+
+	var totalA, totalB float64
+	for _, row := range sales {
+		totalA += row.TotalA
+		totalB += row.TotalB
+		comparison.Categories = append(comparison.Categories, CategoryComparison{
+			CategoryName:     row.Category,
+			ComparisonTotals: compareTotals(row.TotalA, row.TotalB),
+		})
+	}
+	comparison.Total = compareTotals(totalA, totalB)
+	return comparison, nil
+}
+
+// compareTotals computes the change from b to a
+func compareTotals(a, b float64) ComparisonTotals {
+	totals := ComparisonTotals{
+		TotalA: math.Round(a*100) / 100,
+		TotalB: math.Round(b*100) / 100,
+		Delta:  math.Round((a-b)*100) / 100,
+	}
+	if b != 0 {
+		change := math.Round((a-b)/math.Abs(b)*10000) / 100
+		totals.PercentChange = &change
+	}
+	return totals
+}
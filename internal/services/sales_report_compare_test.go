@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bokor/craft-demo/internal/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// unusedConnector is a connector for pools whose connections are never opened
+type unusedConnector struct{}
+
+func (unusedConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("not connected")
+}
+
+func (unusedConnector) Driver() driver.Driver { return nil }
+
+// mockSalesReports is a sales report repository returning fixed comparison
+// rows. Its other methods aren't implemented.
+type mockSalesReports struct {
+	repository.SalesReportRepository
+	compared []repository.ComparedSales
+	filter   repository.ComparisonFilter
+}
+
+func (m *mockSalesReports) CompareSales(_ context.Context, filter repository.ComparisonFilter) ([]repository.ComparedSales, error) {
+	m.filter = filter
+	return m.compared, nil
+}
+
+func TestGetSalesComparison(t *testing.T) {
+	mock := &mockSalesReports{compared: []repository.ComparedSales{
+		{Category: "Mugs", TotalA: 150, TotalB: 100},
+		{Category: "Prints", TotalA: 20, TotalB: 0},
+	}}
+	previous := salesReportRepository
+	salesReportRepository = func(*sql.DB) repository.SalesReportRepository { return mock }
+	defer func() { salesReportRepository = previous }()
+	db := sql.OpenDB(unusedConnector{})
+	defer db.Close()
+	UseDB(db)
+	defer sharedDB.Store(nil)
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/sales/report/compare?range_a=2026-03-08/2026-03-14&range_b=2026-03-01/2026-03-07&rollup=true", nil)
+	recorder := httptest.NewRecorder()
+	if err := GetSalesComparison(echo.New().NewContext(request, recorder)); err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", recorder.Code, recorder.Body)
+	}
+
+	want := repository.ComparisonFilter{StartA: "2026-03-08", EndA: "2026-03-14", StartB: "2026-03-01", EndB: "2026-03-07", Rollup: true}
+	if mock.filter != want {
+		t.Errorf("got filter %+v, want %+v", mock.filter, want)
+	}
+
+	var comparison PeriodComparison
+	if err := json.Unmarshal(recorder.Body.Bytes(), &comparison); err != nil {
+		t.Fatal(err)
+	}
+	if comparison.RangeA.Days != 7 || len(comparison.Categories) != 2 {
+		t.Fatalf("got %+v", comparison)
+	}
+	if mugs := comparison.Categories[0]; mugs.CategoryName != "Mugs" || mugs.Delta != 50 || mugs.PercentChange == nil || *mugs.PercentChange != 50 {
+		t.Errorf("got Mugs comparison %+v", mugs)
+	}
+	if prints := comparison.Categories[1]; prints.PercentChange != nil {
+		t.Errorf("got a percent change of %v from zero", *prints.PercentChange)
+	}
+	if total := comparison.Total; total.TotalA != 170 || total.TotalB != 100 || total.Delta != 70 {
+		t.Errorf("got total %+v", total)
+	}
+}
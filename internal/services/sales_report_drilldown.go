@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// Drill-down page sizes
+const (
+	defaultDrillDownPageSize = 50
+	maxDrillDownPageSize     = 500
+)
+
+// DrillDownItem is a line item of a transaction in the drilled-down category
+type DrillDownItem struct {
+	ProductID   int     `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	SKU         string  `json:"sku"`
+	Quantity    int     `json:"quantity"`
+	TotalAmount float64 `json:"total_amount"`
+}
+
+// DrillDownTransaction is a transaction's contribution to a report cell
+type DrillDownTransaction struct {
+	SaleTransactionID int    `json:"sale_transaction_id"`
+	DateRecorded      string `json:"date_recorded"`
+	Status            string `json:"status"`
+	CustomerID        *int   `json:"customer_id"`
+	CustomerName      string `json:"customer_name"`
+	StoreID           *int   `json:"store_id"`
+	StoreName         string `json:"store_name"`
+	// TotalAmount is the transaction's DW total in the category, negative
+	// for refunds
+	TotalAmount float64 `json:"total_amount"`
+	// Deleted is true for soft-deleted DW rows, listed with include_deleted
+	Deleted bool            `json:"deleted"`
+	Items   []DrillDownItem `json:"items"`
+}
+
+// CategoryDrillDown lists the transactions making up one category report cell
+type CategoryDrillDown struct {
+	Date         string `json:"date"`
+	Period       string `json:"period"`
+	StartDate    string `json:"start_date"`
+	EndDate      string `json:"end_date"`
+	CategoryName string `json:"category_name"`
+	// TotalAmount is the cell's total over every page, as in the report
+	TotalAmount       float64                `json:"total_amount"`
+	TotalTransactions int                    `json:"total_transactions"`
+	Page              int                    `json:"page"`
+	PageSize          int                    `json:"page_size"`
+	Transactions      []DrillDownTransaction `json:"transactions"`
+}
+
+// drillDownQuery identifies a report cell and the page of it wanted
+type drillDownQuery struct {
+	start, end     time.Time
+	categoryID     int
+	includeDeleted bool
+	page, pageSize int
+}
+
+// GetCategoryDrillDown handles the API request for the transactions behind a report cell
+// @Summary Drill down from a category report cell to its transactions
+// @Description Returns the sale transactions that make up one date and category of the category report, oldest first, with each transaction's line items in the category. Pass the same period and include_deleted as the report; date is then the report's period key (e.g. 2024-11-04 for a week, 2024-W45, or FY2025-P02).
+// @Tags sales
+// @Produce json
+// @Param date path string true "Report key: a date in YYYY-MM-DD format, or the period key for the given period"
+// @Param category path string true "Category name, as in the report"
+// @Param period query string false "Period of the report: day, week, iso_week, month, quarter, or fiscal (defaults to day)"
+// @Param include_deleted query bool false "Include soft-deleted warehouse rows (defaults to false)"
+// @Param page query int false "Page number (defaults to 1)"
+// @Param page_size query int false "Transactions per page (default 50, max 500)"
+// @Success 200 {object} CategoryDrillDown "Transactions making up the report cell"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid date, period, or page"
+// @Failure 404 {object} httperror.Envelope "Category not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /sales/report/category/{date}/{category}/transactions [get]
+func GetCategoryDrillDown(c echo.Context) error {
+	ctx := c.Request().Context()
+	period := c.QueryParam("period")
+	if period == "" {
+		period = "day"
+	}
+	if _, ok := reportPeriods[period]; !ok {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid period. Use day, week, iso_week, month, quarter, or fiscal")
+	}
+	start, end, err := reportPeriodRange(c.Param("date"), period)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("Invalid date for the %s period: %v", period, err))
+	}
+
+	query := drillDownQuery{
+		start:          start,
+		end:            end,
+		includeDeleted: c.QueryParam("include_deleted") == "true",
+		page:           1,
+		pageSize:       defaultDrillDownPageSize,
+	}
+	for _, param := range []struct {
+		name   string
+		target *int
+		max    int
+	}{{"page", &query.page, 0}, {"page_size", &query.pageSize, maxDrillDownPageSize}} {
+		value := c.QueryParam(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || (param.max > 0 && n > param.max) {
+			if param.max > 0 {
+				return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("%s must be between 1 and %d", param.name, param.max))
+			}
+			return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("%s must be a positive integer", param.name))
+		}
+		*param.target = n
+	}
+
+	// Names with a slash arrive escaped
+	categoryName := c.Param("category")
+	if c.Request().URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(categoryName); err == nil {
+			categoryName = unescaped
+		}
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		err := db.QueryRowContext(
+			ctx, "SELECT id, name FROM categories WHERE LOWER(name) = LOWER($1)", strings.TrimSpace(categoryName),
+		).Scan(&query.categoryID, &categoryName)
+		if err == sql.ErrNoRows {
+			return errCategoryNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query category: %v", err)
+		}
+
+		drillDown, err := queryCategoryDrillDown(ctx, db, query)
+		if err != nil {
+			return err
+		}
+		drillDown.Date = c.Param("date")
+		drillDown.Period = period
+		drillDown.CategoryName = categoryName
+		return c.JSON(http.StatusOK, drillDown)
+	})
+}
+
+// queryCategoryDrillDown pages through the DW rows of a report cell and
+// loads each transaction's line items in the category
+func queryCategoryDrillDown(ctx context.Context, db *sql.DB, query drillDownQuery) (CategoryDrillDown, error) {
+	drillDown := CategoryDrillDown{
+		StartDate:    query.start.Format("2006-01-02"),
+		EndDate:      query.end.Format("2006-01-02"),
+		Page:         query.page,
+		PageSize:     query.pageSize,
+		Transactions: []DrillDownTransaction{},
+	}
+
+	args := []any{drillDown.StartDate, drillDown.EndDate, query.categoryID, query.includeDeleted}
+	where := `
+		WHERE st.date_recorded >= $1 AND st.date_recorded <= $2
+			AND st.category_id = $3
+			AND ($4 OR st.deleted_at IS NULL)
+	`
+
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*), COALESCE(SUM(st.total_amount), 0) FROM sales_totals_by_category_dw st"+where, args...).
+		Scan(&drillDown.TotalTransactions, &drillDown.TotalAmount); err != nil {
+		return drillDown, fmt.Errorf("failed to query report cell: %v", err)
+	}
+	drillDown.TotalAmount = roundCents(drillDown.TotalAmount)
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT st.sale_transaction_id, DATE(st.date_recorded), t.status,
+			st.customer_id, COALESCE(NULLIF(TRIM(CONCAT(cu.first_name, ' ', cu.last_name)), ''), 'Unknown'),
+			st.company_id, COALESCE(co.name, 'Unknown'),
+			st.total_amount, st.deleted_at IS NOT NULL
+		FROM sales_totals_by_category_dw st
+		JOIN sale_transactions t ON t.id = st.sale_transaction_id
+		LEFT JOIN customers cu ON st.customer_id = cu.id
+		LEFT JOIN companies co ON st.company_id = co.id
+	`+where+`
+		ORDER BY st.date_recorded, st.sale_transaction_id
+		LIMIT $5 OFFSET $6
+	`, append(args, query.pageSize, (query.page-1)*query.pageSize)...)
+	if err != nil {
+		return drillDown, fmt.Errorf("failed to query transactions: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	index := make(map[int]int)
+	for rows.Next() {
+		var transaction DrillDownTransaction
+		if err := rows.Scan(&transaction.SaleTransactionID, &transaction.DateRecorded, &transaction.Status,
+			&transaction.CustomerID, &transaction.CustomerName, &transaction.StoreID, &transaction.StoreName,
+			&transaction.TotalAmount, &transaction.Deleted); err != nil {
+			return drillDown, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if transaction.DateRecorded, err = formatReportDate(transaction.DateRecorded); err != nil {
+			return drillDown, err
+		}
+		transaction.Items = []DrillDownItem{}
+		index[transaction.SaleTransactionID] = len(drillDown.Transactions)
+		ids = append(ids, transaction.SaleTransactionID)
+		drillDown.Transactions = append(drillDown.Transactions, transaction)
+	}
+	if err := rows.Err(); err != nil {
+		return drillDown, fmt.Errorf("error iterating rows: %v", err)
+	}
+	if len(ids) == 0 {
+		return drillDown, nil
+	}
+
+	items, err := db.QueryContext(ctx, `
+		SELECT sti.sale_transaction_id, p.id, p.name, COALESCE(p.sku, ''), sti.quantity, sti.total_amount
+		FROM sale_transaction_items sti
+		JOIN products p ON sti.product_id = p.id
+		WHERE sti.sale_transaction_id = ANY($1) AND p.category_id = $2
+		ORDER BY sti.sale_transaction_id, p.name, p.id
+	`, pq.Array(ids), query.categoryID)
+	if err != nil {
+		return drillDown, fmt.Errorf("failed to query line items: %v", err)
+	}
+	defer items.Close()
+
+	for items.Next() {
+		var (
+			transactionID int
+			item          DrillDownItem
+		)
+		if err := items.Scan(&transactionID, &item.ProductID, &item.ProductName, &item.SKU, &item.Quantity, &item.TotalAmount); err != nil {
+			return drillDown, fmt.Errorf("failed to scan row: %v", err)
+		}
+		transaction := &drillDown.Transactions[index[transactionID]]
+		transaction.Items = append(transaction.Items, item)
+	}
+	if err := items.Err(); err != nil {
+		return drillDown, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return drillDown, nil
+}
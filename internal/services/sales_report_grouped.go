@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/chaos"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/pb"
+	"github.com/bokor/craft-demo/internal/repository"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+// GroupedTotal is the revenue of one combination of group_by dimensions for
+// one period. Dimensions that aren't grouped by are omitted.
+type GroupedTotal struct {
+	Category    string  `json:"category,omitempty"`
+	Store       string  `json:"store,omitempty"`
+	Customer    string  `json:"customer,omitempty"`
+	Currency    string  `json:"currency,omitempty"`
+	Status      string  `json:"status,omitempty"`
+	TotalAmount float64 `json:"total_amount"`
+}
+
+// reportDimension is a dimension the category report can be grouped by
+type reportDimension struct {
+	name string
+	get  func(total GroupedTotal) string
+	set  func(total *GroupedTotal, value string)
+}
+
+// reportDimensions are the supported group_by dimensions. Sales without a
+// store or customer are grouped under Unknown. Grouping by currency keeps each
+// currency's totals in that currency, and grouping by status splits refunds,
+// which are negative, from the sales they'd otherwise be netted against.
+var reportDimensions = []reportDimension{
+	{
+		name: repository.GroupByCategory,
+		get:  func(total GroupedTotal) string { return total.Category },
+		set:  func(total *GroupedTotal, value string) { total.Category = value },
+	},
+	{
+		name: repository.GroupByStore,
+		get:  func(total GroupedTotal) string { return total.Store },
+		set:  func(total *GroupedTotal, value string) { total.Store = value },
+	},
+	{
+		name: repository.GroupByCustomer,
+		get:  func(total GroupedTotal) string { return total.Customer },
+		set:  func(total *GroupedTotal, value string) { total.Customer = value },
+	},
+	{
+		name: repository.GroupByCurrency,
+		get:  func(total GroupedTotal) string { return total.Currency },
+		set:  func(total *GroupedTotal, value string) { total.Currency = value },
+	},
+	{
+		name: repository.GroupByStatus,
+		get:  func(total GroupedTotal) string { return total.Status },
+		set:  func(total *GroupedTotal, value string) { total.Status = value },
+	},
+}
+
+// parseGroupBy reads the comma-separated group_by query parameter, which
+// defaults to category
+func parseGroupBy(value string) ([]reportDimension, error) {
+	if value == "" {
+		return reportDimensions[:1], nil
+	}
+
+	var dimensions []reportDimension
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if seen[name] {
+			return nil, fmt.Errorf("Duplicate group_by dimension %q", name)
+		}
+		seen[name] = true
+
+		found := false
+		for _, dimension := range reportDimensions {
+			if dimension.name == name {
+				dimensions = append(dimensions, dimension)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("Invalid group_by dimension %q. Use category, store, customer, currency, or status", name)
+		}
+	}
+	return dimensions, nil
+}
+
+// isCategoryOnly reports whether dimensions is the category report's own grouping
+func isCategoryOnly(dimensions []reportDimension) bool {
+	return len(dimensions) == 1 && dimensions[0].name == "category"
+}
+
+// groupedRow is a day's revenue for one combination of dimension values
+type groupedRow struct {
+	date     string
+	values   []string
+	currency string
+	total    float64
+}
+
+// groupsByCurrency reports whether dimensions include currency
+func groupsByCurrency(dimensions []reportDimension) bool {
+	for _, dimension := range dimensions {
+		if dimension.name == "currency" {
+			return true
+		}
+	}
+	return false
+}
+
+// queryGroupedSales returns the daily DW revenue for each combination of
+// dimension values, converted into reportQuery.Currency when it's set and
+// with categories rolled up when reportQuery.Rollup is. Unconverted amounts
+// must be in one currency unless they're grouped by currency.
+func queryGroupedSales(ctx context.Context, db *sql.DB, reportQuery SalesReportQuery, dimensions []reportDimension) ([]groupedRow, error) {
+	if err := chaos.Inject(chaos.TargetDB); err != nil {
+		return nil, fmt.Errorf("failed to query sales data: %v", err)
+	}
+
+	groupBy := make([]string, len(dimensions))
+	for i, dimension := range dimensions {
+		groupBy[i] = dimension.name
+	}
+	sales, err := salesReportRepository(db).GroupedSales(ctx, repository.SalesReportFilter{
+		StartDate:      reportQuery.StartDate,
+		EndDate:        reportQuery.EndDate,
+		IncludeDeleted: reportQuery.IncludeDeleted,
+		TimeZone:       reportQuery.TimeZone,
+		Statuses:       reportQuery.Statuses,
+		Rollup:         reportQuery.Rollup,
+	}, groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	converter := currencyConverter(reportQuery)
+	currencies := newCurrencyCheck(reportQuery)
+	if groupsByCurrency(dimensions) {
+		currencies = nil
+	}
+	result := make([]groupedRow, len(sales))
+	for i, row := range sales {
+		if err := currencies.add(row.Currency); err != nil {
+			return nil, err
+		}
+		total, err := converter.Convert(ctx, row.Total, row.Currency, row.Date)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = groupedRow{date: row.Date.Format("2006-01-02"), values: row.Values, currency: row.Currency, total: total}
+	}
+	return result, nil
+}
+
+// groupedReport is the category report grouped by several dimensions:
+// period key -> combinations sorted by their values
+type groupedReport map[string][]GroupedTotal
+
+// buildGroupedReport sums the daily rows into periods. With keys, every key
+// is in the report even without sales.
+func buildGroupedReport(rows []groupedRow, dimensions []reportDimension, period string, keys []string) (groupedReport, error) {
+	type combination struct {
+		key    string
+		values string
+	}
+	totals := make(map[combination]float64)
+	values := make(map[combination][]string)
+	for _, row := range rows {
+		day, err := time.Parse("2006-01-02", row.date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse date %s: %v", row.date, err)
+		}
+		c := combination{
+			key:    reportPeriodKey(reportPeriodStart(day, period), period),
+			values: strings.Join(row.values, "\x00"),
+		}
+		totals[c] += row.total
+		values[c] = row.values
+	}
+
+	combinations := make([]combination, 0, len(totals))
+	for c := range totals {
+		combinations = append(combinations, c)
+	}
+	sort.Slice(combinations, func(i, j int) bool {
+		if combinations[i].key != combinations[j].key {
+			return combinations[i].key < combinations[j].key
+		}
+		return combinations[i].values < combinations[j].values
+	})
+
+	report := make(groupedReport, len(keys))
+	for _, key := range keys {
+		report[key] = []GroupedTotal{}
+	}
+	for _, c := range combinations {
+		total := GroupedTotal{TotalAmount: roundCents(totals[c])}
+		for i, dimension := range dimensions {
+			dimension.set(&total, values[c][i])
+		}
+		report[c.key] = append(report[c.key], total)
+	}
+	return report, nil
+}
+
+// nested returns the report nested by each dimension's value in group_by
+// order, with the totals as leaves
+func (r groupedReport) nested(dimensions []reportDimension) map[string]any {
+	nested := make(map[string]any, len(r))
+	for key, totals := range r {
+		level := make(map[string]any)
+		nested[key] = level
+		for _, total := range totals {
+			node := level
+			for i, dimension := range dimensions {
+				value := dimension.get(total)
+				if i == len(dimensions)-1 {
+					node[value] = total.TotalAmount
+					break
+				}
+				child, ok := node[value].(map[string]any)
+				if !ok {
+					child = make(map[string]any)
+					node[value] = child
+				}
+				node = child
+			}
+		}
+	}
+	return nested
+}
+
+// getGroupedSalesReport serves the category report grouped by dimensions
+func getGroupedSalesReport(c echo.Context, db *sql.DB, reportQuery SalesReportQuery, dimensions []reportDimension, period, shape string, zeroFill bool, pagination reportPagination) error {
+	rows, err := queryGroupedSales(c.Request().Context(), db, reportQuery, dimensions)
+	if err != nil {
+		log.Printf("Failed to query grouped sales data: %v", err)
+		return respondReportError(c, err)
+	}
+	if pagination.notFound(len(rows) == 0 && !zeroFill) {
+		return httperror.JSON(c, http.StatusNotFound, "No sales data found")
+	}
+
+	var keys []string
+	if zeroFill {
+		if keys, err = reportPeriodKeys(reportQuery.StartDate, reportQuery.EndDate, period); err != nil {
+			return err
+		}
+	}
+	report, err := buildGroupedReport(rows, dimensions, period, keys)
+	if err != nil {
+		log.Printf("Failed to group sales data: %v", err)
+		return httperror.JSON(c, http.StatusInternalServerError, "Failed to query sales data")
+	}
+
+	var value any = report
+	if shape == "nested" {
+		value = report.nested(dimensions)
+	}
+	body := pagination.envelope(c, value)
+	if wantsCSV(c) {
+		return writeGroupedReportCSV(c, report, dimensions, reportQuery.StartDate, reportQuery.EndDate)
+	}
+	return respondNegotiated(c, http.StatusOK, body, func() proto.Message {
+		return groupedReportProto(report)
+	})
+}
+
+// groupedReportProto converts the grouped sales report
+func groupedReportProto(report groupedReport) proto.Message {
+	message := &pb.SalesReportGrouped{}
+	for _, period := range sortedPeriods(report) {
+		entry := &pb.GroupedReportPeriod{Period: period}
+		for _, total := range report[period] {
+			entry.Totals = append(entry.Totals, &pb.GroupedTotal{
+				Category:    total.Category,
+				Store:       total.Store,
+				Customer:    total.Customer,
+				Currency:    total.Currency,
+				Status:      total.Status,
+				TotalAmount: total.TotalAmount,
+			})
+		}
+		message.Periods = append(message.Periods, entry)
+	}
+	return message
+}
+
+// roundCents rounds an amount summed in Go to whole cents
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
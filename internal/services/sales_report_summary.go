@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/report"
+	"github.com/labstack/echo/v4"
+)
+
+// Forecast snapshot of the summary report: months of history and months
+// forecast
+const (
+	summaryHistoryMonths  = 6
+	summaryForecastMonths = 3
+)
+
+// GetSalesSummaryPDF handles the API request for the one-page sales summary
+// @Summary Download the sales summary PDF
+// @Description Renders a one-page PDF for executives: revenue in the date range and the change from the same number of days before, a sparkline of daily revenue, the top categories with their share and change, and a statistical forecast of the three months after the range from the six complete months before them. Totals come from the DW table, without soft-deleted rows.
+// @Tags sales
+// @Produce application/pdf
+// @Param start_date query string false "Start date in YYYY-MM-DD format (defaults to 6 months ago)"
+// @Param end_date query string false "End date in YYYY-MM-DD format (defaults to today)"
+// @Success 200 {file} file "Summary PDF"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid date range"
+// @Failure 404 {object} httperror.Envelope "No sales data found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /sales/report/summary.pdf [get]
+func GetSalesSummaryPDF(c echo.Context) error {
+	ctx := c.Request().Context()
+	startDate, endDate, err := parseReportDateRange(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	rangeA, err := parseComparisonRange("range", startDate+"/"+endDate)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "end_date must not be before start_date")
+	}
+	rangeB, err := priorComparisonRange(rangeA, compareToPriorPeriod)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		comparison, err := queryComparison(ctx, db, rangeA, rangeB, false, false)
+		if err != nil {
+			return err
+		}
+		if comparison.Total.TotalA == 0 && len(comparison.Categories) == 0 {
+			return httperror.JSON(c, http.StatusNotFound, "No sales data found")
+		}
+		daily, err := QuerySalesData(ctx, db, SalesReportQuery{StartDate: startDate, EndDate: endDate, SumCurrencies: true})
+		if err != nil {
+			return err
+		}
+
+		start, _ := time.Parse("2006-01-02", startDate)
+		end, _ := time.Parse("2006-01-02", endDate)
+		// Forecast the months after the one the range ends in, unless it
+		// ends on the last day of a month
+		next := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+		if end.AddDate(0, 0, 1).Day() == 1 {
+			next = end.AddDate(0, 0, 1)
+		}
+		monthly, err := queryMonthlyCategoryTotals(ctx, db, next.AddDate(0, -summaryHistoryMonths, 0), next)
+		if err != nil {
+			return err
+		}
+
+		summary := report.Summary{
+			Title:           "Sales Summary",
+			StartDate:       start,
+			EndDate:         end,
+			GeneratedAt:     time.Now().UTC(),
+			Revenue:         comparison.Total.TotalA,
+			PreviousRevenue: comparison.Total.TotalB,
+			Trend:           summaryTrend(daily, start, end),
+			Forecast:        summaryForecast(monthly, next),
+			ForecastNote: fmt.Sprintf("Statistical forecast from the %d months before %s; bands are 80%% confidence.",
+				summaryHistoryMonths, next.Format("January 2006")),
+		}
+		for _, category := range comparison.Categories {
+			summary.Categories = append(summary.Categories, report.CategoryAmount{
+				Name:     category.CategoryName,
+				Amount:   category.TotalA,
+				Previous: category.TotalB,
+			})
+		}
+		sort.SliceStable(summary.Categories, func(i, j int) bool {
+			return summary.Categories[i].Amount > summary.Categories[j].Amount
+		})
+
+		// Render before responding so a failure is still a JSON error
+		var buf bytes.Buffer
+		if err := report.RenderSummaryPDF(&buf, summary); err != nil {
+			return err
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition,
+			fmt.Sprintf(`attachment; filename="sales-summary-%s-%s.pdf"`, startDate, endDate))
+		return c.Blob(http.StatusOK, "application/pdf", buf.Bytes())
+	})
+}
+
+// summaryTrend returns the daily revenue of every day from start to end,
+// zero on days without sales
+func summaryTrend(daily map[string][]CategoryTotal, start, end time.Time) []report.TrendPoint {
+	var trend []report.TrendPoint
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		point := report.TrendPoint{Date: day}
+		for _, total := range daily[day.Format("2006-01-02")] {
+			point.Amount += total.TotalAmount
+		}
+		trend = append(trend, point)
+	}
+	return trend
+}
+
+// summaryForecast forecasts the months from next on from the monthly totals
+// of every category in the months before it
+func summaryForecast(monthly map[string]map[string]float64, next time.Time) []report.ForecastPoint {
+	var history []forecast.Point
+	found := false
+	for i := summaryHistoryMonths; i >= 1; i-- {
+		month := next.AddDate(0, -i, 0).Format("2006-01")
+		point := forecast.Point{Period: month}
+		for _, totals := range monthly {
+			if total, ok := totals[month]; ok {
+				point.Total += total
+				found = true
+			}
+		}
+		history = append(history, point)
+	}
+	if !found {
+		return nil
+	}
+
+	forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(deterministicSeed)), nil)
+	var points []report.ForecastPoint
+	for _, predicted := range forecaster.Forecast(history, "month", summaryForecastMonths) {
+		point := report.ForecastPoint{Period: predicted.Period, Amount: predicted.Total}
+		if period, err := forecast.ParsePeriod(predicted.Period); err == nil {
+			point.Period = period.Format("January 2006")
+		}
+		for _, interval := range predicted.Intervals {
+			if interval.Level == 80 {
+				point.Lower, point.Upper = interval.Lower, interval.Upper
+			}
+		}
+		points = append(points, point)
+	}
+	return points
+}
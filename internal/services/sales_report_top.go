@@ -0,0 +1,129 @@
+package services
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// Limits of the n query parameter of the top sellers report
+const (
+	defaultTopSellers = 10
+	maxTopSellers     = 100
+)
+
+// TopSeller is a product or category ranked by its sales total
+type TopSeller struct {
+	Rank        int     `json:"rank"`
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	TotalAmount float64 `json:"total_amount"`
+	// SharePercent is the seller's percentage of the total of every product
+	// or category, null when that total isn't positive
+	SharePercent *float64 `json:"share_percent"`
+}
+
+// TopSellersResponse is the response of the top sellers report
+type TopSellersResponse struct {
+	Dimension string `json:"dimension"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+	// TotalAmount is the total of every product or category in the range,
+	// not only the top ones
+	TotalAmount float64     `json:"total_amount"`
+	Sellers     []TopSeller `json:"sellers"`
+}
+
+// GetTopSellers handles the API request for the top products or categories
+// @Summary Get top selling products or categories
+// @Description Returns the products or categories with the highest sales totals in the date range, net of refunds, with each one's share of the total of all of them. Product totals come from the sale transactions and category totals from the DW table. Use format=csv or send Accept: text/csv to download the sellers.
+// @Tags sales
+// @Produce json
+// @Produce text/csv
+// @Param dimension query string false "Rank product or category (defaults to product)"
+// @Param n query int false "Number of sellers to return, 1 to 100 (defaults to 10)"
+// @Param start_date query string false "Start date in YYYY-MM-DD format (defaults to 6 months ago)"
+// @Param end_date query string false "End date in YYYY-MM-DD format (defaults to today)"
+// @Param include_deleted query bool false "Include soft-deleted warehouse rows in category totals (defaults to false)"
+// @Param rollup query bool false "Rank top-level categories with their subcategories' sales, with dimension=category (defaults to false)"
+// @Param format query string false "Response format: json or csv (defaults to json)"
+// @Success 200 {object} TopSellersResponse "Top sellers, highest total first"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid parameters"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /sales/report/top [get]
+func GetTopSellers(c echo.Context) error {
+	ctx := c.Request().Context()
+	startDate, endDate, err := parseReportDateRange(c)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	filter := repository.TopSellersFilter{
+		Dimension:      c.QueryParam("dimension"),
+		StartDate:      startDate,
+		EndDate:        endDate,
+		Limit:          defaultTopSellers,
+		IncludeDeleted: c.QueryParam("include_deleted") == "true",
+	}
+	switch filter.Dimension {
+	case "":
+		filter.Dimension = repository.DimensionProduct
+	case repository.DimensionProduct, repository.DimensionCategory:
+	default:
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid dimension. Use product or category")
+	}
+	if filter.Rollup, err = reportRollup(c); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if filter.Rollup && filter.Dimension != repository.DimensionCategory {
+		return httperror.JSON(c, http.StatusBadRequest, "rollup requires dimension=category")
+	}
+	if value := c.QueryParam("n"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxTopSellers {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid n. Use a number from 1 to 100")
+		}
+		filter.Limit = n
+	}
+	if err := validateReportFormat(c, "csv"); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	asCSV := wantsCSV(c)
+
+	return withDB(c, func(db *sql.DB) error {
+		sellers, total, err := salesReportRepository(db).TopSellers(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		response := TopSellersResponse{
+			Dimension:   filter.Dimension,
+			StartDate:   startDate,
+			EndDate:     endDate,
+			TotalAmount: math.Round(total*100) / 100,
+			Sellers:     make([]TopSeller, 0, len(sellers)),
+		}
+		for i, seller := range sellers {
+			top := TopSeller{
+				Rank:        i + 1,
+				ID:          seller.ID,
+				Name:        seller.Name,
+				TotalAmount: math.Round(seller.Total*100) / 100,
+			}
+			if total > 0 {
+				share := math.Round(seller.Total/total*10000) / 100
+				top.SharePercent = &share
+			}
+			response.Sellers = append(response.Sellers, top)
+		}
+		if asCSV {
+			return writeTopSellersCSV(c, response)
+		}
+		return c.JSON(http.StatusOK, response)
+	})
+}
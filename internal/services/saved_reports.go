@@ -0,0 +1,640 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/auth"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/bokor/craft-demo/internal/notify"
+	"github.com/labstack/echo/v4"
+)
+
+// savedReportType is a report that can be saved, with the query parameters
+// its definition may set
+type savedReportType struct {
+	name    string
+	handler echo.HandlerFunc
+	params  []string
+	// dateParams are the range parameters last_days replaces; none means
+	// last_days isn't supported
+	dateParams []string
+}
+
+// savedReportTypes are the reports that can be saved, keyed by name in the
+// definition's report field
+var savedReportTypes = []savedReportType{
+	{
+		name:       "category",
+		handler:    GetSalesReportByCategory,
+		params:     []string{"start_date", "end_date", "include_deleted", "tz", "currency", "status", "period", "granularity", "fill", "rollup", "group_by", "shape", "format", "limit"},
+		dateParams: []string{"start_date", "end_date"},
+	},
+	{
+		name:       "customers",
+		handler:    GetSalesReportByCustomerType,
+		params:     []string{"start_date", "end_date", "period", "fill", "rollup", "format", "limit"},
+		dateParams: []string{"start_date", "end_date"},
+	},
+	{
+		name:       "compare",
+		handler:    GetSalesComparison,
+		params:     []string{"range_a", "range_b", "include_deleted", "rollup", "format"},
+		dateParams: []string{"range_a", "range_b"},
+	},
+	{
+		name:       "comparison",
+		handler:    GetPriorComparison,
+		params:     []string{"start_date", "end_date", "compare_to", "include_deleted", "rollup", "format"},
+		dateParams: []string{"start_date", "end_date"},
+	},
+	{
+		name:    "variance",
+		handler: GetVarianceReport,
+		params:  []string{"start_month", "end_month", "format"},
+	},
+}
+
+// Subscription frequencies and how long after a delivery the next is due.
+// The slack lets a job running on the same interval deliver on time.
+var subscriptionFrequencies = map[string]time.Duration{
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+const subscriptionSlack = 10 * time.Minute
+
+// SavedReport is a named report configuration
+type SavedReport struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Report is category, customers, compare, comparison, or variance
+	Report string `json:"report"`
+	// Params are the report's query parameters
+	Params map[string]string `json:"params"`
+	// LastDays, when set, makes the range the last N days up to the day the
+	// report runs; compare reports compare them with the N days before
+	LastDays  *int      `json:"last_days"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SavedReportRequest represents the request body for saving a report
+type SavedReportRequest struct {
+	Name     string            `json:"name"`
+	Report   string            `json:"report"`
+	Params   map[string]string `json:"params"`
+	LastDays *int              `json:"last_days"`
+}
+
+// ReportSubscription emails a saved report to an address
+type ReportSubscription struct {
+	ID            int64  `json:"id"`
+	SavedReportID int64  `json:"saved_report_id"`
+	Email         string `json:"email"`
+	// Frequency is daily or weekly
+	Frequency  string     `json:"frequency"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ReportSubscriptionRequest represents the request body for subscribing to a saved report
+type ReportSubscriptionRequest struct {
+	Email     string `json:"email"`
+	Frequency string `json:"frequency"`
+}
+
+// errSavedReportNotFound is returned when a saved report ID doesn't exist
+var errSavedReportNotFound = &notFoundError{resource: "Saved report"}
+
+// CreateSavedReport handles the API request for saving a report definition
+// @Summary Save a report definition
+// @Description Saves a named report configuration: the report (category, customers, compare, comparison, or variance) and its query parameters, such as group_by, period, fill, and format. Set last_days instead of fixed dates to always cover the last N days. Parameter values are checked when the report runs.
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param request body SavedReportRequest true "Report definition"
+// @Success 201 {object} SavedReport "Saved report"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid definition"
+// @Failure 409 {object} httperror.Envelope "Name already exists"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /reports/saved [post]
+func CreateSavedReport(c echo.Context) error {
+	ctx := c.Request().Context()
+	var request SavedReportRequest
+	if err := c.Bind(&request); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid request format")
+	}
+	if err := validateSavedReport(&request); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		params, err := json.Marshal(request.Params)
+		if err != nil {
+			return err
+		}
+
+		report := SavedReport{Name: request.Name, Report: request.Report, Params: request.Params, LastDays: request.LastDays}
+		err = db.QueryRowContext(ctx, `
+			INSERT INTO saved_reports (name, report, params, last_days)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (name) DO NOTHING
+			RETURNING id, created_at
+		`, request.Name, request.Report, params, request.LastDays).Scan(&report.ID, &report.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &conflictError{message: fmt.Sprintf("A saved report named %q already exists", request.Name)}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to insert saved report: %v", err)
+		}
+		return c.JSON(http.StatusCreated, report)
+	})
+}
+
+// validateSavedReport checks a definition against its report's parameters
+func validateSavedReport(request *SavedReportRequest) error {
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		return fmt.Errorf("Saved report name is required")
+	}
+	if len(request.Name) > 100 {
+		return fmt.Errorf("Saved report name must be at most 100 characters")
+	}
+
+	reportType, ok := findSavedReportType(request.Report)
+	if !ok {
+		return fmt.Errorf("Invalid report %q. Use category, customers, compare, comparison, or variance", request.Report)
+	}
+	if request.Params == nil {
+		request.Params = map[string]string{}
+	}
+	for name := range request.Params {
+		if !slices.Contains(reportType.params, name) {
+			return fmt.Errorf("The %s report has no %q parameter. Use %s", reportType.name, name, strings.Join(reportType.params, ", "))
+		}
+	}
+
+	if request.LastDays != nil {
+		if len(reportType.dateParams) == 0 {
+			return fmt.Errorf("last_days isn't supported by the %s report", reportType.name)
+		}
+		if *request.LastDays < 1 || *request.LastDays > 366 {
+			return fmt.Errorf("last_days must be between 1 and 366")
+		}
+		for _, name := range reportType.dateParams {
+			if _, ok := request.Params[name]; ok {
+				return fmt.Errorf("Set either last_days or %s", strings.Join(reportType.dateParams, " and "))
+			}
+		}
+	}
+	return nil
+}
+
+func findSavedReportType(name string) (savedReportType, bool) {
+	for _, reportType := range savedReportTypes {
+		if reportType.name == name {
+			return reportType, true
+		}
+	}
+	return savedReportType{}, false
+}
+
+// ListSavedReports handles the API request for listing saved reports
+// @Summary List saved reports
+// @Description Returns all saved report definitions ordered by name
+// @Tags reports
+// @Produce json
+// @Success 200 {array} SavedReport "Saved reports"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /reports/saved [get]
+func ListSavedReports(c echo.Context) error {
+	ctx := c.Request().Context()
+	return withDB(c, func(db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, "SELECT "+savedReportColumns+" FROM saved_reports ORDER BY name")
+		if err != nil {
+			return fmt.Errorf("failed to query saved reports: %v", err)
+		}
+		defer rows.Close()
+
+		reports := []SavedReport{}
+		for rows.Next() {
+			report, err := scanSavedReport(rows)
+			if err != nil {
+				return err
+			}
+			reports = append(reports, *report)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating rows: %v", err)
+		}
+		return c.JSON(http.StatusOK, reports)
+	})
+}
+
+// GetSavedReport handles the API request for a single saved report
+// @Summary Get a saved report
+// @Description Returns a saved report definition
+// @Tags reports
+// @Produce json
+// @Param id path int true "Saved report ID"
+// @Success 200 {object} SavedReport "Saved report"
+// @Failure 404 {object} httperror.Envelope "Saved report not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /reports/saved/{id} [get]
+func GetSavedReport(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid saved report id")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		report, err := querySavedReport(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, report)
+	})
+}
+
+// DeleteSavedReport handles the API request for deleting a saved report
+// @Summary Delete a saved report
+// @Description Deletes a saved report definition and its subscriptions
+// @Tags reports
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Saved report ID"
+// @Success 204 "Saved report deleted"
+// @Failure 404 {object} httperror.Envelope "Saved report not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /reports/saved/{id} [delete]
+func DeleteSavedReport(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid saved report id")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		result, err := db.ExecContext(ctx, "DELETE FROM saved_reports WHERE id = $1", id)
+		if err != nil {
+			return fmt.Errorf("failed to delete saved report: %v", err)
+		}
+		if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+			return errSavedReportNotFound
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+}
+
+// RunSavedReport handles the API request for running a saved report
+// @Summary Run a saved report
+// @Description Runs a saved report with its saved parameters and returns the report's own response. Query parameters given here override the saved ones, e.g. format=arrow.
+// @Tags reports
+// @Produce json
+// @Param id path int true "Saved report ID"
+// @Success 200 {object} map[string]interface{} "The report's response"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid saved parameter"
+// @Failure 404 {object} httperror.Envelope "Saved report not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /reports/saved/{id}/run [get]
+func RunSavedReport(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid saved report id")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		report, err := querySavedReport(ctx, db, id)
+		if err != nil {
+			return err
+		}
+		reportType, ok := findSavedReportType(report.Report)
+		if !ok {
+			return fmt.Errorf("saved report %d has unknown report %q", report.ID, report.Report)
+		}
+
+		query := savedReportQuery(*report, time.Now())
+		for name, values := range c.Request().URL.Query() {
+			query[name] = values
+		}
+
+		// Run the report's handler on a copy of the request with the saved
+		// parameters, writing to this response as the same principal
+		request := c.Request().Clone(c.Request().Context())
+		request.URL.RawQuery = query.Encode()
+		return reportType.handler(auth.WithPrincipal(c.Echo().NewContext(request, c.Response()), c))
+	})
+}
+
+// savedReportQuery returns the query parameters of a saved report run on
+// the given day
+func savedReportQuery(report SavedReport, now time.Time) url.Values {
+	query := url.Values{}
+	for name, value := range report.Params {
+		query.Set(name, value)
+	}
+	if report.LastDays == nil {
+		return query
+	}
+
+	days := *report.LastDays
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, 0, 1-days)
+	if report.Report == "compare" {
+		previousEnd := start.AddDate(0, 0, -1)
+		query.Set("range_a", start.Format("2006-01-02")+"/"+end.Format("2006-01-02"))
+		query.Set("range_b", previousEnd.AddDate(0, 0, 1-days).Format("2006-01-02")+"/"+previousEnd.Format("2006-01-02"))
+		return query
+	}
+	query.Set("start_date", start.Format("2006-01-02"))
+	query.Set("end_date", end.Format("2006-01-02"))
+	return query
+}
+
+const savedReportColumns = "id, name, report, params, last_days, created_at"
+
+func querySavedReport(ctx context.Context, db *sql.DB, id int64) (*SavedReport, error) {
+	report, err := scanSavedReport(db.QueryRowContext(ctx, "SELECT "+savedReportColumns+" FROM saved_reports WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errSavedReportNotFound
+	}
+	return report, err
+}
+
+func scanSavedReport(row rowScanner) (*SavedReport, error) {
+	var (
+		report SavedReport
+		params []byte
+	)
+	if err := row.Scan(&report.ID, &report.Name, &report.Report, &params, &report.LastDays, &report.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan saved report: %v", err)
+	}
+	if err := json.Unmarshal(params, &report.Params); err != nil {
+		return nil, fmt.Errorf("failed to parse saved report params: %v", err)
+	}
+	return &report, nil
+}
+
+// CreateReportSubscription handles the API request for subscribing to a saved report
+// @Summary Subscribe to a saved report
+// @Description Emails a link to run the saved report to an address daily or weekly. Delivery needs ALERT_SMTP_ADDR and ALERT_EMAIL_FROM.
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Saved report ID"
+// @Param request body ReportSubscriptionRequest true "Subscription"
+// @Success 201 {object} ReportSubscription "Created subscription"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid email or frequency"
+// @Failure 404 {object} httperror.Envelope "Saved report not found"
+// @Failure 409 {object} httperror.Envelope "Address already subscribed"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /reports/saved/{id}/subscriptions [post]
+func CreateReportSubscription(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid saved report id")
+	}
+
+	var request ReportSubscriptionRequest
+	if err := c.Bind(&request); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid request format")
+	}
+	address, err := mail.ParseAddress(request.Email)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("Invalid email %q", request.Email))
+	}
+	if _, ok := subscriptionFrequencies[request.Frequency]; !ok {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid frequency. Use daily or weekly")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		if _, err := querySavedReport(ctx, db, id); err != nil {
+			return err
+		}
+
+		subscription := ReportSubscription{SavedReportID: id, Email: address.Address, Frequency: request.Frequency}
+		err := db.QueryRowContext(ctx, `
+			INSERT INTO saved_report_subscriptions (saved_report_id, email, frequency)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (saved_report_id, email) DO NOTHING
+			RETURNING id, created_at
+		`, id, subscription.Email, subscription.Frequency).Scan(&subscription.ID, &subscription.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return &conflictError{message: fmt.Sprintf("%s is already subscribed to this report", subscription.Email)}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to insert subscription: %v", err)
+		}
+		return c.JSON(http.StatusCreated, subscription)
+	})
+}
+
+// ListReportSubscriptions handles the API request for a saved report's subscriptions
+// @Summary List a saved report's subscriptions
+// @Description Returns the email subscriptions of a saved report
+// @Tags reports
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Saved report ID"
+// @Success 200 {array} ReportSubscription "Subscriptions"
+// @Failure 404 {object} httperror.Envelope "Saved report not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /reports/saved/{id}/subscriptions [get]
+func ListReportSubscriptions(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid saved report id")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		if _, err := querySavedReport(ctx, db, id); err != nil {
+			return err
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT id, saved_report_id, email, frequency, last_sent_at, created_at
+			FROM saved_report_subscriptions
+			WHERE saved_report_id = $1
+			ORDER BY email
+		`, id)
+		if err != nil {
+			return fmt.Errorf("failed to query subscriptions: %v", err)
+		}
+		defer rows.Close()
+
+		subscriptions := []ReportSubscription{}
+		for rows.Next() {
+			var s ReportSubscription
+			if err := rows.Scan(&s.ID, &s.SavedReportID, &s.Email, &s.Frequency, &s.LastSentAt, &s.CreatedAt); err != nil {
+				return fmt.Errorf("failed to scan row: %v", err)
+			}
+			subscriptions = append(subscriptions, s)
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating rows: %v", err)
+		}
+		return c.JSON(http.StatusOK, subscriptions)
+	})
+}
+
+// DeleteReportSubscription handles the API request for unsubscribing from a saved report
+// @Summary Delete a report subscription
+// @Description Stops emailing a saved report to an address
+// @Tags reports
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param id path int true "Saved report ID"
+// @Param subscription_id path int true "Subscription ID"
+// @Success 204 "Subscription deleted"
+// @Failure 404 {object} httperror.Envelope "Subscription not found"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /reports/saved/{id}/subscriptions/{subscription_id} [delete]
+func DeleteReportSubscription(c echo.Context) error {
+	ctx := c.Request().Context()
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid saved report id")
+	}
+	subscriptionID, err := strconv.ParseInt(c.Param("subscription_id"), 10, 64)
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, "Invalid subscription id")
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		result, err := db.ExecContext(
+			ctx, "DELETE FROM saved_report_subscriptions WHERE id = $1 AND saved_report_id = $2",
+			subscriptionID, id,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to delete subscription: %v", err)
+		}
+		if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+			return &notFoundError{resource: "Subscription"}
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+}
+
+// SendReportSubscriptions emails every due subscription a link to run its
+// saved report. The link is relative to ALERT_LINK_BASE_URL (default
+// http://localhost:8080).
+func SendReportSubscriptions(ctx context.Context, db *sql.DB, channels notify.Channels, now time.Time) error {
+	rows, err := db.QueryContext(ctx, `
+		SELECT s.id, s.email, s.frequency, `+prefixColumns("r.", savedReportColumns)+`
+		FROM saved_report_subscriptions s
+		JOIN saved_reports r ON r.id = s.saved_report_id
+		WHERE s.last_sent_at IS NULL
+			OR (s.frequency = 'daily' AND s.last_sent_at <= $1)
+			OR (s.frequency = 'weekly' AND s.last_sent_at <= $2)
+		ORDER BY s.id
+	`, now.Add(subscriptionSlack-subscriptionFrequencies["daily"]), now.Add(subscriptionSlack-subscriptionFrequencies["weekly"]))
+	if err != nil {
+		return fmt.Errorf("failed to query due subscriptions: %v", err)
+	}
+
+	type due struct {
+		subscription ReportSubscription
+		report       SavedReport
+	}
+	var subscriptions []due
+	for rows.Next() {
+		var (
+			d      due
+			params []byte
+		)
+		if err := rows.Scan(&d.subscription.ID, &d.subscription.Email, &d.subscription.Frequency,
+			&d.report.ID, &d.report.Name, &d.report.Report, &params, &d.report.LastDays, &d.report.CreatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan row: %v", err)
+		}
+		if err := json.Unmarshal(params, &d.report.Params); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to parse saved report params: %v", err)
+		}
+		subscriptions = append(subscriptions, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %v", err)
+	}
+
+	var failed []string
+	for _, d := range subscriptions {
+		if err := channels.SendEmail(ctx, []string{d.subscription.Email}, savedReportMessage(d.report, d.subscription.Frequency)); err != nil {
+			log.Printf("Failed to email saved report %d to %s: %v", d.report.ID, d.subscription.Email, err)
+			failed = append(failed, d.subscription.Email)
+			continue
+		}
+		if _, err := db.ExecContext(ctx, "UPDATE saved_report_subscriptions SET last_sent_at = $2 WHERE id = $1", d.subscription.ID, now); err != nil {
+			return fmt.Errorf("failed to record delivery: %v", err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to email saved reports to %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// savedReportMessage describes a saved report delivery with a link to run it
+func savedReportMessage(report SavedReport, frequency string) notify.Message {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Your %s copy of the saved %s report %q.", frequency, report.Report, report.Name)
+	if report.LastDays != nil {
+		fmt.Fprintf(&text, "\nCovers the last %d days.", *report.LastDays)
+	}
+	names := make([]string, 0, len(report.Params))
+	for name := range report.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&text, "\n%s: %s", name, report.Params[name])
+	}
+
+	base := os.Getenv("ALERT_LINK_BASE_URL")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return notify.Message{
+		Title: fmt.Sprintf("Saved report: %s", report.Name),
+		Text:  text.String(),
+		Link:  fmt.Sprintf("%s/api/v1/reports/saved/%d/run", strings.TrimRight(base, "/"), report.ID),
+	}
+}
+
+// prefixColumns qualifies a comma-separated column list with a table alias
+func prefixColumns(prefix, columns string) string {
+	names := strings.Split(columns, ", ")
+	for i, name := range names {
+		names[i] = prefix + name
+	}
+	return strings.Join(names, ", ")
+}
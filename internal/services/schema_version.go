@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+// headerSchemaVersion selects the forecast response schema version on
+// requests and reports the version served on responses
+const headerSchemaVersion = "X-Schema-Version"
+
+// Forecast response schema versions
+const (
+	// forecastSchemaV1 keys the forecast by daily, weekly, or monthly
+	forecastSchemaV1 = 1
+	// forecastSchemaV2 is the flat forecast array with timePeriod
+	forecastSchemaV2 = 2
+
+	latestForecastSchema = forecastSchemaV2
+)
+
+// forecastSchemaSunsets are the dates after which deprecated schema versions
+// may be removed; responses in them carry Deprecation and Sunset headers
+var forecastSchemaSunsets = map[int]string{
+	forecastSchemaV1: "Fri, 30 Apr 2027 00:00:00 GMT",
+}
+
+// forecastConverters convert a response to each older schema version
+var forecastConverters = map[int]func(ForecastResponse) any{
+	forecastSchemaV1: forecastResponseV1From,
+}
+
+// ForecastResponseV1 is the deprecated forecast response schema, with the
+// forecast under the key of its time period
+type ForecastResponseV1 struct {
+	Daily       []TimeSeriesPoint `json:"daily,omitempty"`
+	Weekly      []TimeSeriesPoint `json:"weekly,omitempty"`
+	Monthly     []TimeSeriesPoint `json:"monthly,omitempty"`
+	Message     string            `json:"message"`
+	RawResponse string            `json:"rawResponse,omitempty"`
+	Meta        ForecastMeta      `json:"meta"`
+}
+
+// forecastResponseV1From converts a response to schema version 1
+func forecastResponseV1From(response ForecastResponse) any {
+	v1 := ForecastResponseV1{Message: response.Message, RawResponse: response.RawResponse, Meta: response.Meta}
+	switch response.TimePeriod {
+	case "day":
+		v1.Daily = response.Forecast
+	case "week":
+		v1.Weekly = response.Forecast
+	default:
+		v1.Monthly = response.Forecast
+	}
+	return v1
+}
+
+// forecastSchemaVersion returns the schema version requested with the
+// schema query parameter or the X-Schema-Version header, defaulting to the
+// latest
+func forecastSchemaVersion(c echo.Context) (int, error) {
+	value := c.QueryParam("schema")
+	if value == "" {
+		value = c.Request().Header.Get(headerSchemaVersion)
+	}
+	if value == "" {
+		return latestForecastSchema, nil
+	}
+
+	version, err := strconv.Atoi(value)
+	if err != nil || version < forecastSchemaV1 || version > latestForecastSchema {
+		return 0, fmt.Errorf("Unsupported schema version %q. Use 1 or 2", value)
+	}
+	return version, nil
+}
+
+// respondForecast writes a forecast response in the requested schema
+// version. Protobuf responses always use the current message.
+func respondForecast(c echo.Context, status int, version int, response ForecastResponse) error {
+	setForecastSchemaHeaders(c, version)
+	return respondNegotiated(c, status, versionedForecast(version, response), func() proto.Message {
+		return forecastResponseProto(response)
+	})
+}
+
+// setForecastSchemaHeaders reports the schema version served and whether it
+// is deprecated
+func setForecastSchemaHeaders(c echo.Context, version int) {
+	header := c.Response().Header()
+	header.Set(headerSchemaVersion, strconv.Itoa(version))
+	header.Add(echo.HeaderVary, headerSchemaVersion)
+	if sunset, ok := forecastSchemaSunsets[version]; ok {
+		header.Set("Deprecation", "true")
+		header.Set("Sunset", sunset)
+	}
+}
+
+// versionedForecast converts a response to the schema version
+func versionedForecast(version int, response ForecastResponse) any {
+	if convert, ok := forecastConverters[version]; ok {
+		return convert(response)
+	}
+	return response
+}
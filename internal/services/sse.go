@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// eventStreamMIMEType is the media type of Server-Sent Events
+const eventStreamMIMEType = "text/event-stream"
+
+// eventStream writes Server-Sent Events to a response. The headers are
+// written with the first event, so handlers can still set their own until
+// then. Events may be sent from several goroutines; none are written after
+// the last.
+type eventStream struct {
+	response *echo.Response
+	mu       sync.Mutex
+	closed   bool
+}
+
+// errEventStreamClosed is returned for events sent after the last
+var errEventStreamClosed = errors.New("event stream closed")
+
+// newEventStream returns an event stream for the response
+func newEventStream(c echo.Context) *eventStream {
+	return &eventStream{response: c.Response()}
+}
+
+// send writes an event with data encoded as JSON and flushes it to the client
+func (s *eventStream) send(event string, data any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write(event, data)
+}
+
+// sendLast sends the stream's last event; later events are dropped, so a
+// handler can respond after its senders are stopped or abandoned
+func (s *eventStream) sendLast(event string, data any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.write(event, data)
+	s.closed = true
+	return err
+}
+
+// write sends an event, holding mu
+func (s *eventStream) write(event string, data any) error {
+	if s.closed {
+		return errEventStreamClosed
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if !s.response.Committed {
+		header := s.response.Header()
+		header.Set(echo.HeaderContentType, eventStreamMIMEType)
+		header.Set(echo.HeaderCacheControl, "no-cache")
+		// Keep nginx from buffering the stream
+		header.Set("X-Accel-Buffering", "no")
+		s.response.WriteHeader(http.StatusOK)
+	}
+	if _, err := fmt.Fprintf(s.response, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	s.response.Flush()
+	return nil
+}
+
+// errEndOfEvents is returned by a readEvents handler at the stream's last
+// event, so reading doesn't wait for the server to close the connection
+var errEndOfEvents = errors.New("end of events")
+
+// readEvents calls handle with the event type and data of each Server-Sent
+// Event read from r, stopping at the first error or errEndOfEvents
+func readEvents(r io.Reader, handle func(event, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var (
+		event string
+		data  []string
+	)
+	dispatch := func() error {
+		defer func() { event, data = "", nil }()
+		if len(data) == 0 {
+			return nil
+		}
+		return handle(event, strings.Join(data, "\n"))
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := dispatch(); errors.Is(err, errEndOfEvents) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Lines starting with a colon are comments, e.g. keep-alives
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := dispatch(); !errors.Is(err, errEndOfEvents) {
+		return err
+	}
+	return nil
+}
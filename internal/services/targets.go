@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/forecast"
+	"github.com/bokor/craft-demo/internal/httperror"
+	"github.com/labstack/echo/v4"
+)
+
+// maxVarianceMonths is the longest range the variance report covers
+const maxVarianceMonths = 24
+
+// RevenueTarget is a category's revenue target for a month
+type RevenueTarget struct {
+	// ID identifies the target as a sales goal; it is ignored when uploading
+	ID int64 `json:"id,omitempty"`
+	// Month is YYYY-MM
+	Month string `json:"month"`
+	// Category is the category name; CategoryID may be given instead when
+	// uploading
+	Category   string  `json:"category"`
+	CategoryID int     `json:"category_id,omitempty"`
+	Target     float64 `json:"target"`
+}
+
+// TargetUploadResponse represents the response for uploading targets
+type TargetUploadResponse struct {
+	Saved int `json:"saved"`
+}
+
+// VarianceRow compares a category's target, actual, and forecast revenue for a month
+type VarianceRow struct {
+	Month        string `json:"month"`
+	CategoryName string `json:"category_name"`
+	// Target and the values derived from it are null without a target
+	Target *float64 `json:"target"`
+	Actual float64  `json:"actual"`
+	// Forecast is the baseline forecast of the month from the six months before
+	Forecast float64 `json:"forecast"`
+	// Variance is actual - target
+	Variance *float64 `json:"variance"`
+	// Attainment is actual as a percentage of target
+	Attainment *float64 `json:"attainment"`
+	// ForecastVariance is forecast - target
+	ForecastVariance *float64 `json:"forecast_variance"`
+	// Complete is false for the current month, whose actual is month-to-date
+	Complete bool `json:"complete"`
+}
+
+// UploadTargets handles the API request for uploading revenue targets
+// @Summary Upload revenue targets
+// @Description Creates or replaces monthly revenue targets per category. Send a JSON array, or text/csv with a month,category,target header row where category is the category name. Months are YYYY-MM. Targets not in the upload are left unchanged.
+// @Tags targets
+// @Accept json
+// @Accept text/csv
+// @Produce json
+// @Security BasicAuth
+// @Security BearerAuth
+// @Param request body []RevenueTarget true "Targets"
+// @Success 200 {object} TargetUploadResponse "Number of targets saved"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid target or unknown category"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /targets [put]
+func UploadTargets(c echo.Context) error {
+	ctx := c.Request().Context()
+	var (
+		targets []RevenueTarget
+		err     error
+	)
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		targets, err = parseTargetsCSV(c.Request().Body)
+	} else if bindErr := c.Bind(&targets); bindErr != nil {
+		err = fmt.Errorf("Invalid request format")
+	}
+	if err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	if len(targets) == 0 {
+		return httperror.JSON(c, http.StatusBadRequest, "No targets provided")
+	}
+	for _, target := range targets {
+		if _, err := time.Parse("2006-01", target.Month); err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("Invalid month %q. Use YYYY-MM", target.Month))
+		}
+		if target.Target < 0 || math.IsNaN(target.Target) {
+			return httperror.JSON(c, http.StatusBadRequest, "Targets must not be negative")
+		}
+		if target.CategoryID == 0 && strings.TrimSpace(target.Category) == "" {
+			return httperror.JSON(c, http.StatusBadRequest, "Each target needs a category or category_id")
+		}
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		saved, err := saveTargets(ctx, db, targets)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, TargetUploadResponse{Saved: saved})
+	})
+}
+
+// parseTargetsCSV reads targets from CSV with a month,category,target header
+func parseTargetsCSV(body io.Reader) ([]RevenueTarget, error) {
+	records, err := csv.NewReader(body).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"month", "category", "target"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV header must include month, category, and target")
+		}
+	}
+
+	targets := make([]RevenueTarget, 0, len(records)-1)
+	for line, record := range records[1:] {
+		value := strings.TrimSpace(record[columns["target"]])
+		target, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid target %q on line %d", value, line+2)
+		}
+		targets = append(targets, RevenueTarget{
+			Month:    strings.TrimSpace(record[columns["month"]]),
+			Category: strings.TrimSpace(record[columns["category"]]),
+			Target:   target,
+		})
+	}
+	return targets, nil
+}
+
+// saveTargets upserts targets in one transaction, resolving category names
+func saveTargets(ctx context.Context, db *sql.DB, targets []RevenueTarget) (int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, target := range targets {
+		categoryID := target.CategoryID
+		if categoryID == 0 {
+			err := tx.QueryRowContext(ctx, "SELECT id FROM categories WHERE LOWER(name) = LOWER($1)", target.Category).Scan(&categoryID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, &invalidError{message: fmt.Sprintf("Unknown category %q", target.Category)}
+			}
+			if err != nil {
+				return 0, fmt.Errorf("failed to look up category: %v", err)
+			}
+		} else {
+			var exists bool
+			if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)", categoryID).Scan(&exists); err != nil {
+				return 0, fmt.Errorf("failed to look up category: %v", err)
+			}
+			if !exists {
+				return 0, &invalidError{message: fmt.Sprintf("Unknown category_id %d", categoryID)}
+			}
+		}
+
+		month, _ := time.Parse("2006-01", target.Month)
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO revenue_targets (month, category_id, target, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (month, category_id) DO UPDATE SET target = EXCLUDED.target, updated_at = EXCLUDED.updated_at
+		`, month, categoryID, target.Target); err != nil {
+			return 0, fmt.Errorf("failed to save target: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit targets: %v", err)
+	}
+	return len(targets), nil
+}
+
+// ListTargets handles the API request for listing revenue targets
+// @Summary List revenue targets
+// @Description Returns the monthly revenue targets per category, optionally for one month
+// @Tags targets
+// @Produce json
+// @Param month query string false "Only targets for this month (YYYY-MM)"
+// @Success 200 {array} RevenueTarget "Targets ordered by month and category"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid month"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /targets [get]
+func ListTargets(c echo.Context) error {
+	ctx := c.Request().Context()
+	var month *time.Time
+	if value := c.QueryParam("month"); value != "" {
+		parsed, err := time.Parse("2006-01", value)
+		if err != nil {
+			return httperror.JSON(c, http.StatusBadRequest, "Invalid month format. Use YYYY-MM")
+		}
+		month = &parsed
+	}
+
+	return withDB(c, func(db *sql.DB) error {
+		targets, err := queryTargets(ctx, db, month, month)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, targets)
+	})
+}
+
+// queryTargets returns the targets from start to end inclusive; nil bounds
+// are open
+func queryTargets(ctx context.Context, db *sql.DB, start, end *time.Time) ([]RevenueTarget, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT t.id, t.month, c.name, t.category_id, t.target
+		FROM revenue_targets t
+		JOIN categories c ON c.id = t.category_id
+		WHERE ($1::DATE IS NULL OR t.month >= $1) AND ($2::DATE IS NULL OR t.month <= $2)
+		ORDER BY t.month, c.name
+	`, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query targets: %v", err)
+	}
+	defer rows.Close()
+
+	targets := []RevenueTarget{}
+	for rows.Next() {
+		var (
+			target RevenueTarget
+			month  time.Time
+		)
+		if err := rows.Scan(&target.ID, &month, &target.Category, &target.CategoryID, &target.Target); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		target.Month = month.Format("2006-01")
+		targets = append(targets, target)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %v", err)
+	}
+	return targets, nil
+}
+
+// GetVarianceReport handles the API request for the target variance report
+// @Summary Get target vs actual vs forecast variance
+// @Description Compares each category's monthly revenue target with actual revenue from the DW table and the baseline forecast made from the six months before, with the variance and attainment against target. Use format=csv or send Accept: text/csv to download the report.
+// @Tags sales
+// @Produce json
+// @Produce text/csv
+// @Param start_month query string false "First month in YYYY-MM format (defaults to January of the current year)"
+// @Param end_month query string false "Last month in YYYY-MM format (defaults to the current month)"
+// @Param format query string false "Response format: json or csv (defaults to json)"
+// @Success 200 {array} VarianceRow "Variance per month and category"
+// @Failure 400 {object} httperror.Envelope "Bad request - invalid month range"
+// @Failure 500 {object} httperror.Envelope "Internal server error"
+// @Router /sales/report/variance [get]
+func GetVarianceReport(c echo.Context) error {
+	ctx := c.Request().Context()
+	now := time.Now().UTC()
+	currentMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	start, end := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC), currentMonth
+	for _, param := range []struct {
+		name  string
+		month *time.Time
+	}{{"start_month", &start}, {"end_month", &end}} {
+		if value := c.QueryParam(param.name); value != "" {
+			parsed, err := time.Parse("2006-01", value)
+			if err != nil {
+				return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("Invalid %s format. Use YYYY-MM", param.name))
+			}
+			*param.month = parsed
+		}
+	}
+	if end.Before(start) {
+		return httperror.JSON(c, http.StatusBadRequest, "end_month must not be before start_month")
+	}
+	if end.After(start.AddDate(0, maxVarianceMonths-1, 0)) {
+		return httperror.JSON(c, http.StatusBadRequest, fmt.Sprintf("The range can cover at most %d months", maxVarianceMonths))
+	}
+
+	if err := validateReportFormat(c, "csv"); err != nil {
+		return httperror.JSON(c, http.StatusBadRequest, err.Error())
+	}
+	asCSV := wantsCSV(c)
+
+	return withDB(c, func(db *sql.DB) error {
+		rows, err := queryVariance(ctx, db, start, end, currentMonth)
+		if err != nil {
+			return err
+		}
+		if asCSV {
+			return writeVarianceCSV(c, rows, start, end)
+		}
+		return c.JSON(http.StatusOK, rows)
+	})
+}
+
+// queryVariance builds the variance rows for every category with a target
+// or revenue in the range
+func queryVariance(ctx context.Context, db *sql.DB, start, end, currentMonth time.Time) ([]VarianceRow, error) {
+	monthly, err := queryMonthlyCategoryTotals(ctx, db, start.AddDate(0, -monthlyPackHistoryMonths, 0), end.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	targets, err := queryTargets(ctx, db, &start, &end)
+	if err != nil {
+		return nil, err
+	}
+
+	targetsByKey := make(map[string]float64, len(targets))
+	categories := make(map[string]bool)
+	for _, target := range targets {
+		targetsByKey[target.Month+"|"+target.Category] = target.Target
+		categories[target.Category] = true
+	}
+	for category, totals := range monthly {
+		for month := start; !month.After(end); month = month.AddDate(0, 1, 0) {
+			if _, ok := totals[month.Format("2006-01")]; ok {
+				categories[category] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := []VarianceRow{}
+	for month := start; !month.After(end); month = month.AddDate(0, 1, 0) {
+		period := month.Format("2006-01")
+		for _, category := range names {
+			row := VarianceRow{
+				Month:        period,
+				CategoryName: category,
+				Actual:       math.Round(monthly[category][period]*100) / 100,
+				Complete:     month.Before(currentMonth),
+			}
+
+			var history []forecast.Point
+			for i := monthlyPackHistoryMonths; i >= 1; i-- {
+				previous := month.AddDate(0, -i, 0).Format("2006-01")
+				history = append(history, forecast.Point{Period: previous, Total: monthly[category][previous]})
+			}
+			forecaster := forecast.NewSimpleForecaster(rand.New(rand.NewSource(deterministicSeed)), nil)
+			if predicted := forecaster.Forecast(history, "month", 1); len(predicted) == 1 {
+				row.Forecast = predicted[0].Total
+			}
+
+			if target, ok := targetsByKey[period+"|"+category]; ok {
+				variance := math.Round((row.Actual-target)*100) / 100
+				forecastVariance := math.Round((row.Forecast-target)*100) / 100
+				row.Target, row.Variance, row.ForecastVariance = &target, &variance, &forecastVariance
+				if target > 0 {
+					attainment := math.Round(row.Actual/target*10000) / 100
+					row.Attainment = &attainment
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func writeVarianceCSV(c echo.Context, rows []VarianceRow, start, end time.Time) error {
+	filename := fmt.Sprintf("variance-%s-%s", start.Format("2006-01"), end.Format("2006-01"))
+	header := []string{
+		"month", "category", "target", "actual", "forecast",
+		"variance", "attainment", "forecast_variance", "complete",
+	}
+	return writeCSV(c, filename, header, len(rows), func(i int) []string {
+		row := rows[i]
+		return []string{
+			row.Month,
+			row.CategoryName,
+			csvOptionalAmount(row.Target),
+			csvAmount(row.Actual),
+			csvAmount(row.Forecast),
+			csvOptionalAmount(row.Variance),
+			csvOptionalAmount(row.Attainment),
+			csvOptionalAmount(row.ForecastVariance),
+			strconv.FormatBool(row.Complete),
+		}
+	})
+}
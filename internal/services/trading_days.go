@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bokor/craft-demo/config"
+	"github.com/bokor/craft-demo/internal/forecast"
+	"gopkg.in/yaml.v3"
+)
+
+// storeClosure is an entry in the store closures file
+type storeClosure struct {
+	Date   string `yaml:"date"`
+	Reason string `yaml:"reason"`
+}
+
+// storeClosures reads the closure dates from STORE_CLOSURES_PATH, which
+// defaults to config/store_closures.yaml, using the embedded copy when the
+// file doesn't exist
+func storeClosures() (map[string]bool, error) {
+	name := os.Getenv("STORE_CLOSURES_PATH")
+	if name == "" {
+		name = "config/store_closures.yaml"
+	}
+
+	contents, err := config.Read(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store closures: %v", err)
+	}
+
+	var entries []storeClosure
+	if err := yaml.Unmarshal(contents, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse store closures: %v", err)
+	}
+	closures := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if _, err := time.Parse("2006-01-02", entry.Date); err != nil {
+			return nil, fmt.Errorf("invalid store closure date %q", entry.Date)
+		}
+		closures[entry.Date] = true
+	}
+	return closures, nil
+}
+
+// tradingDayAdjustment returns the trading-day adjustment for a monthly
+// forecast request
+func tradingDayAdjustment(request ForecastRequest, timePeriod string) (*forecast.TradingDayAdjustment, error) {
+	if timePeriod != "month" {
+		return nil, fmt.Errorf("tradingDays requires the month time period")
+	}
+	if request.WeekendWeight < 0 {
+		return nil, fmt.Errorf("weekendWeight must not be negative")
+	}
+
+	closures, err := storeClosures()
+	if err != nil {
+		return nil, err
+	}
+	calendar := forecast.TradingCalendar{WeekendWeight: 1, Closures: closures}
+	if request.WeekendWeight > 0 {
+		calendar.WeekendWeight = request.WeekendWeight
+	}
+
+	history := make([]forecast.Point, len(request.TimeSeriesData))
+	for i, point := range request.TimeSeriesData {
+		history[i] = forecast.Point{Period: point.Period, Total: point.Total}
+	}
+	adjustment := forecast.NewTradingDayAdjustment(calendar, history, request.forecastPeriods(timePeriod))
+	return &adjustment, nil
+}
+
+// adjustTradingDays converts points with the given adjustment step
+func adjustTradingDays(points []TimeSeriesPoint, convert func([]forecast.Point) []forecast.Point) []TimeSeriesPoint {
+	return forecastPoints(convert(forecastHistory(points)))
+}
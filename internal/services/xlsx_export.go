@@ -0,0 +1,399 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxMIMEType is the media type of an Excel workbook
+const xlsxMIMEType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// xlsxMaxSheetName is the longest sheet name Excel accepts
+const xlsxMaxSheetName = 31
+
+// wantsXLSX reports whether the request asked for ?format=xlsx
+func wantsXLSX(c echo.Context) bool {
+	return c.QueryParam("format") == "xlsx"
+}
+
+// writeXLSX writes a workbook as an attachment named filename.xlsx
+func writeXLSX(c echo.Context, status int, filename string, workbook *excelize.File) error {
+	response := c.Response()
+	response.Header().Set(echo.HeaderContentType, xlsxMIMEType)
+	response.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.xlsx"`, filename))
+	response.WriteHeader(status)
+	return workbook.Write(response)
+}
+
+// xlsxTable is a sheet with a header row, a row per entry, and a Total row
+type xlsxTable struct {
+	sheet  string
+	header []string
+	rows   [][]any
+	// amounts are the 0-based columns formatted as amounts
+	amounts []int
+	// totals are the columns summed in the Total row
+	totals []int
+}
+
+// xlsxWorkbook builds a workbook of tables with shared styles
+type xlsxWorkbook struct {
+	file *excelize.File
+	// names are the sheet names taken, lowercased as Excel compares them
+	names                                  map[string]bool
+	header, amount, date, total, totalText int
+}
+
+// newXLSXWorkbook creates an empty workbook and its styles
+func newXLSXWorkbook() (*xlsxWorkbook, error) {
+	file := excelize.NewFile()
+	workbook := &xlsxWorkbook{file: file, names: make(map[string]bool)}
+
+	styles := []struct {
+		target *int
+		style  *excelize.Style
+	}{
+		{&workbook.header, &excelize.Style{
+			Font:   &excelize.Font{Bold: true},
+			Fill:   excelize.Fill{Type: "pattern", Color: []string{"D9E1F2"}, Pattern: 1},
+			Border: []excelize.Border{{Type: "bottom", Color: "000000", Style: 1}},
+		}},
+		{&workbook.amount, &excelize.Style{NumFmt: 4}}, // #,##0.00
+		{&workbook.date, &excelize.Style{NumFmt: 14}},  // m/d/yy
+		{&workbook.total, &excelize.Style{
+			NumFmt: 4,
+			Font:   &excelize.Font{Bold: true},
+			Border: []excelize.Border{{Type: "top", Color: "000000", Style: 1}},
+		}},
+		{&workbook.totalText, &excelize.Style{
+			Font:   &excelize.Font{Bold: true},
+			Border: []excelize.Border{{Type: "top", Color: "000000", Style: 1}},
+		}},
+	}
+	for _, s := range styles {
+		style, err := file.NewStyle(s.style)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		*s.target = style
+	}
+	return workbook, nil
+}
+
+// sheetName returns name as a valid sheet name not yet taken: without the
+// characters Excel forbids, at most 31 characters, and numbered when a sheet
+// already has it
+func (w *xlsxWorkbook) sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?*[]`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(strings.TrimSpace(name), "'")
+	if name == "" {
+		name = "Sheet"
+	}
+
+	candidate := truncateRunes(name, xlsxMaxSheetName)
+	for i := 2; w.names[strings.ToLower(candidate)]; i++ {
+		suffix := fmt.Sprintf(" (%d)", i)
+		candidate = truncateRunes(name, xlsxMaxSheetName-len(suffix)) + suffix
+	}
+	w.names[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// truncateRunes returns the first n runes of s
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// addTable adds table as a new sheet. time.Time values are written as dates.
+func (w *xlsxWorkbook) addTable(table xlsxTable) error {
+	sheet := w.sheetName(table.sheet)
+	if _, err := w.file.NewSheet(sheet); err != nil {
+		return err
+	}
+
+	lastColumn, _ := excelize.ColumnNumberToName(len(table.header))
+	header := stringsToAny(table.header)
+	if err := w.file.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+	if err := w.file.SetCellStyle(sheet, "A1", lastColumn+"1", w.header); err != nil {
+		return err
+	}
+
+	for i, row := range table.rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := w.file.SetSheetRow(sheet, cell, &row); err != nil {
+			return err
+		}
+		for j, value := range row {
+			if _, ok := value.(time.Time); ok {
+				cell, _ := excelize.CoordinatesToCellName(j+1, i+2)
+				if err := w.file.SetCellStyle(sheet, cell, cell, w.date); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	lastRow := len(table.rows) + 1
+	totalRow := lastRow + 1
+	for _, column := range table.amounts {
+		name, _ := excelize.ColumnNumberToName(column + 1)
+		if lastRow > 1 {
+			if err := w.file.SetCellStyle(sheet, fmt.Sprintf("%s2", name), fmt.Sprintf("%s%d", name, lastRow), w.amount); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The Total row sums with formulas, so it follows edits to the rows
+	totalEnd := fmt.Sprintf("%s%d", lastColumn, totalRow)
+	if err := w.file.SetCellValue(sheet, fmt.Sprintf("A%d", totalRow), "Total"); err != nil {
+		return err
+	}
+	if err := w.file.SetCellStyle(sheet, fmt.Sprintf("A%d", totalRow), totalEnd, w.totalText); err != nil {
+		return err
+	}
+	for _, column := range table.totals {
+		name, _ := excelize.ColumnNumberToName(column + 1)
+		cell := fmt.Sprintf("%s%d", name, totalRow)
+		formula := "0"
+		if lastRow > 1 {
+			formula = fmt.Sprintf("SUM(%s2:%s%d)", name, name, lastRow)
+		}
+		if err := w.file.SetCellFormula(sheet, cell, formula); err != nil {
+			return err
+		}
+		if err := w.file.SetCellStyle(sheet, cell, cell, w.total); err != nil {
+			return err
+		}
+	}
+
+	if err := w.file.SetColWidth(sheet, "A", lastColumn, 16); err != nil {
+		return err
+	}
+	return w.file.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+}
+
+// finish removes the default sheet of a new workbook and makes the first
+// table the active sheet
+func (w *xlsxWorkbook) finish() (*excelize.File, error) {
+	if err := w.file.DeleteSheet("Sheet1"); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+	w.file.SetActiveSheet(0)
+	return w.file, nil
+}
+
+// xlsxPeriod returns a period key as a date cell when it's a date, and as
+// text otherwise, e.g. for ISO week and fiscal period keys
+func xlsxPeriod(period string) any {
+	if date, err := time.Parse("2006-01-02", period); err == nil {
+		return date
+	}
+	return period
+}
+
+// buildCategoryReportXLSX builds the sales report by category as a summary
+// sheet of category totals and a sheet per category of its period totals,
+// each with the gross sales, discounts, and refunds behind it and the units
+// and transactions sold
+func buildCategoryReportXLSX(report map[string][]CategoryTotal) (*excelize.File, error) {
+	periods := sortedPeriods(report)
+	byCategory := make(map[string][][]any)
+	totals := make(map[string]CategoryTotal)
+	for _, period := range periods {
+		for _, total := range report[period] {
+			byCategory[total.CategoryName] = append(byCategory[total.CategoryName], []any{xlsxPeriod(period), total.TotalAmount, total.GrossSales, total.Discounts, total.Refunds, total.UnitsSold, total.Transactions})
+			sum := totals[total.CategoryName]
+			sum.add(total)
+			totals[total.CategoryName] = sum
+		}
+	}
+	categories := make([]string, 0, len(byCategory))
+	for category := range byCategory {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	workbook, err := newXLSXWorkbook()
+	if err != nil {
+		return nil, err
+	}
+
+	amounts, sums := []int{1, 2, 3, 4}, []int{1, 2, 3, 4, 5, 6}
+	summary := xlsxTable{sheet: "Summary", header: []string{"Category", "Total Amount", "Gross Sales", "Discounts", "Refunds", "Units Sold", "Transactions"}, amounts: amounts, totals: sums}
+	for _, category := range categories {
+		total := totals[category].roundCents()
+		summary.rows = append(summary.rows, []any{category, total.TotalAmount, total.GrossSales, total.Discounts, total.Refunds, total.UnitsSold, total.Transactions})
+	}
+	tables := []xlsxTable{summary}
+	for _, category := range categories {
+		tables = append(tables, xlsxTable{
+			sheet:   category,
+			header:  []string{"Period", "Total Amount", "Gross Sales", "Discounts", "Refunds", "Units Sold", "Transactions"},
+			rows:    byCategory[category],
+			amounts: amounts,
+			totals:  sums,
+		})
+	}
+
+	for _, table := range tables {
+		if err := workbook.addTable(table); err != nil {
+			workbook.file.Close()
+			return nil, fmt.Errorf("failed to build category report workbook: %v", err)
+		}
+	}
+	return workbook.finish()
+}
+
+// forecastXLSXHeader is the header of a forecast sheet; the baseline column
+// is only added for scenario forecasts
+var forecastXLSXHeader = []string{"Period", "Forecast", "80% Lower", "80% Upper", "95% Lower", "95% Upper"}
+
+// forecastXLSXTable lays out a forecast's points with their confidence bands
+// and, for scenario forecasts, the baseline
+func forecastXLSXTable(sheet string, response ForecastResponse) xlsxTable {
+	table := xlsxTable{
+		sheet:   sheet,
+		header:  append([]string{}, forecastXLSXHeader...),
+		amounts: []int{1, 2, 3, 4, 5},
+		totals:  []int{1},
+	}
+	baseline := make(map[string]float64, len(response.Baseline))
+	for _, point := range response.Baseline {
+		baseline[point.Period] = point.Total
+	}
+	if len(response.Baseline) > 0 {
+		table.header = append(table.header, "Baseline")
+		table.amounts = append(table.amounts, 6)
+		table.totals = append(table.totals, 6)
+	}
+
+	for _, point := range response.Forecast {
+		row := []any{xlsxPeriod(point.Period), point.Total}
+		for _, level := range []int{80, 95} {
+			lower, upper := point.interval(level)
+			if lower == nil {
+				row = append(row, nil, nil)
+				continue
+			}
+			row = append(row, *lower, *upper)
+		}
+		if len(response.Baseline) > 0 {
+			row = append(row, baseline[point.Period])
+		}
+		table.rows = append(table.rows, row)
+	}
+	return table
+}
+
+// forecastHorizonSheet names the sheet of a forecast for its time period
+func forecastHorizonSheet(timePeriod string) string {
+	switch timePeriod {
+	case "day":
+		return "Daily Forecast"
+	case "week":
+		return "Weekly Forecast"
+	case "month":
+		return "Monthly Forecast"
+	}
+	return "Forecast"
+}
+
+// buildForecastXLSX builds a forecast as a summary sheet of how it was made
+// and a sheet of the forecast for its horizon
+func buildForecastXLSX(response ForecastResponse) (*excelize.File, error) {
+	workbook, err := newXLSXWorkbook()
+	if err != nil {
+		return nil, err
+	}
+
+	sheet := workbook.sheetName("Summary")
+	if _, err := workbook.file.NewSheet(sheet); err != nil {
+		workbook.file.Close()
+		return nil, err
+	}
+	rows := [][]any{
+		{"Time period", response.TimePeriod},
+		{"Source", response.Meta.Source},
+		{"Provider", response.Meta.Provider},
+		{"Model", response.Meta.Model},
+		{"Method", response.Meta.Method},
+		{"Fallback reason", response.Meta.FallbackReason},
+		{"Message", response.Message},
+		{"Generated", time.Now().UTC().Format("2006-01-02 15:04 MST")},
+	}
+	for i, row := range rows {
+		if err := workbook.file.SetSheetRow(sheet, fmt.Sprintf("A%d", i+1), &row); err != nil {
+			workbook.file.Close()
+			return nil, err
+		}
+	}
+	if err := workbook.file.SetCellStyle(sheet, "A1", fmt.Sprintf("A%d", len(rows)), workbook.totalText); err != nil {
+		workbook.file.Close()
+		return nil, err
+	}
+	if err := workbook.file.SetColWidth(sheet, "A", "B", 24); err != nil {
+		workbook.file.Close()
+		return nil, err
+	}
+
+	if err := workbook.addTable(forecastXLSXTable(forecastHorizonSheet(response.TimePeriod), response)); err != nil {
+		workbook.file.Close()
+		return nil, fmt.Errorf("failed to build forecast workbook: %v", err)
+	}
+	return workbook.finish()
+}
+
+// buildCategoryForecastsXLSX builds forecasts of several categories as a
+// summary sheet of each category's forecast total and a sheet per category
+func buildCategoryForecastsXLSX(responses map[string]ForecastResponse) (*excelize.File, error) {
+	names := make([]string, 0, len(responses))
+	for name := range responses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	workbook, err := newXLSXWorkbook()
+	if err != nil {
+		return nil, err
+	}
+
+	summary := xlsxTable{sheet: "Summary", header: []string{"Category", "Source", "Forecast"}, amounts: []int{2}, totals: []int{2}}
+	for _, name := range names {
+		total := 0.0
+		for _, point := range responses[name].Forecast {
+			total += point.Total
+		}
+		summary.rows = append(summary.rows, []any{name, responses[name].Meta.Source, roundCents(total)})
+	}
+	tables := []xlsxTable{summary}
+	for _, name := range names {
+		tables = append(tables, forecastXLSXTable(name, responses[name]))
+	}
+
+	for _, table := range tables {
+		if err := workbook.addTable(table); err != nil {
+			workbook.file.Close()
+			return nil, fmt.Errorf("failed to build forecast workbook: %v", err)
+		}
+	}
+	return workbook.finish()
+}
@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/bokor/craft-demo/internal/batch"
+	"github.com/bokor/craft-demo/internal/database"
+	"github.com/bokor/craft-demo/internal/seeder"
+	"github.com/bokor/craft-demo/internal/services"
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario runner executing full pipeline flows (seed, aggregate, report,
+// forecast, persist, compare) against a disposable database.
+//
+// Each scenario gets a fresh database cloned from the template database
+// (SCENARIO_TEMPLATE_DB, defaulting to DB_NAME), which must already have the
+// schema applied. All data tables are truncated before the scenario seeds
+// its own rows, and the database is dropped afterwards.
+func main() {
+	dir := flag.String("dir", "scenarios", "directory containing scenario YAML files")
+	run := flag.String("run", "", "only run the scenario file with this name (without extension)")
+	keep := flag.Bool("keep", false, "keep the disposable databases for debugging")
+	flag.Parse()
+
+	// GetDBConnection loads .env, so call it first to pick up DB_NAME
+	admin, err := database.GetDBConnection()
+	if err != nil {
+		log.Fatalf("Error connecting to the database: %v", err)
+	}
+	defer admin.Close()
+
+	template := os.Getenv("SCENARIO_TEMPLATE_DB")
+	if template == "" {
+		template = os.Getenv("DB_NAME")
+	}
+
+	files, err := filepath.Glob(filepath.Join(*dir, "*.yaml"))
+	if err != nil {
+		log.Fatalf("Failed to list scenarios: %v", err)
+	}
+
+	failed := 0
+	for _, file := range files {
+		name := filepath.Base(file[:len(file)-len(filepath.Ext(file))])
+		if *run != "" && *run != name {
+			continue
+		}
+
+		scenario, err := loadScenario(file)
+		if err != nil {
+			log.Printf("FAIL %s: %v", name, err)
+			failed++
+			continue
+		}
+
+		start := time.Now()
+		if err := runScenario(admin, template, name, scenario, *keep); err != nil {
+			log.Printf("FAIL %s (%s): %v", name, scenario.Name, err)
+			failed++
+			continue
+		}
+		log.Printf("PASS %s (%s) in %s", name, scenario.Name, time.Since(start).Round(time.Millisecond))
+	}
+
+	if failed > 0 {
+		log.Fatalf("%d scenario(s) failed", failed)
+	}
+}
+
+// Scenario describes a pipeline flow and its expectations
+type Scenario struct {
+	Name string `yaml:"name"`
+	Seed struct {
+		Dir    string        `yaml:"dir"`
+		Tables []seeder.Seed `yaml:"tables"`
+	} `yaml:"seed"`
+	Steps []Step `yaml:"steps"`
+}
+
+// Step is a single action in a scenario. Type selects the action; the other
+// fields apply to the aggregate, report, forecast, persist and compare steps.
+type Step struct {
+	Type string `yaml:"type"`
+
+	// aggregate and report
+	StartDate string `yaml:"start_date"`
+	EndDate   string `yaml:"end_date"`
+
+	// aggregate
+	CategoryID    int  `yaml:"category_id"`
+	DryRun        bool `yaml:"dry_run"`
+	ExpectWritten *int `yaml:"expect_written"`
+	ExpectIssues  *int `yaml:"expect_issues"`
+
+	// report
+	ExpectStatus int                           `yaml:"expect_status"`
+	Expect       map[string]map[string]float64 `yaml:"expect"`
+
+	// forecast and persist
+	Category     string `yaml:"category"`
+	TimePeriod   string `yaml:"time_period"`
+	ExpectPoints int    `yaml:"expect_points"`
+
+	// compare
+	ExpectMatched *int `yaml:"expect_matched"`
+}
+
+// dataTables are truncated before each scenario seeds its own data
+var dataTables = []string{
+	"forecast_points",
+	"forecasts",
+	"batch_runs",
+	"data_quality_issues",
+	"sales_totals_by_category_dw",
+	"sales_totals_by_product_dw",
+	"sales_totals_by_hour_dw",
+	"sale_transaction_items",
+	"sale_transactions",
+	"products",
+	"customers",
+	"companies",
+	"categories",
+}
+
+func loadScenario(file string) (*Scenario, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %v", err)
+	}
+
+	var scenario Scenario
+	if err := yaml.Unmarshal(content, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario: %v", err)
+	}
+	return &scenario, nil
+}
+
+func runScenario(admin *sql.DB, template, name string, scenario *Scenario, keep bool) error {
+	dbName := fmt.Sprintf("scenario_%s_%d", name, time.Now().UnixNano())
+	if _, err := admin.Exec(fmt.Sprintf("CREATE DATABASE %q TEMPLATE %q", dbName, template)); err != nil {
+		return fmt.Errorf("failed to create disposable database: %v", err)
+	}
+	if !keep {
+		defer func() {
+			if _, err := admin.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %q", dbName)); err != nil {
+				log.Printf("Warning: failed to drop %s: %v", dbName, err)
+			}
+		}()
+	}
+
+	// Connect to the disposable database and have the handlers use it for
+	// the duration of the scenario
+	previous := os.Getenv("DB_NAME")
+	os.Setenv("DB_NAME", dbName)
+	db, err := database.GetDBConnection()
+	os.Setenv("DB_NAME", previous)
+	if err != nil {
+		return fmt.Errorf("failed to connect to disposable database: %v", err)
+	}
+	defer db.Close()
+	services.UseDB(db)
+
+	for _, table := range dataTables {
+		if _, err := db.Exec("TRUNCATE TABLE " + table + " RESTART IDENTITY CASCADE"); err != nil {
+			return fmt.Errorf("failed to truncate %s: %v", table, err)
+		}
+	}
+
+	if scenario.Seed.Dir != "" {
+		if err := seeder.SeedDirStrict(db, scenario.Seed.Dir); err != nil {
+			return fmt.Errorf("failed to seed: %v", err)
+		}
+	}
+	// A seed row that fails would leave the steps asserting on partial data
+	for _, table := range scenario.Seed.Tables {
+		if err := seeder.InsertStrict(db, table, name); err != nil {
+			return fmt.Errorf("failed to seed: %v", err)
+		}
+	}
+
+	var (
+		report    map[string][]services.CategoryTotal
+		persisted []int64
+	)
+	for i, step := range scenario.Steps {
+		var err error
+		switch step.Type {
+		case "aggregate":
+			err = runAggregate(db, step)
+		case "report":
+			report, err = runReport(step)
+		case "forecast":
+			err = runForecast(step, report)
+		case "persist":
+			var id int64
+			id, err = runPersist(db, step, report)
+			persisted = append(persisted, id)
+		case "compare":
+			err = runCompare(db, step, persisted)
+		default:
+			err = fmt.Errorf("unknown step type %q; steps are aggregate, report, forecast, persist and compare", step.Type)
+		}
+		if err != nil {
+			return fmt.Errorf("step %d (%s): %v", i+1, step.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// runAggregate runs the sales totals job the way the scheduler and CLI do,
+// and checks the records it wrote and issues it found
+func runAggregate(db *sql.DB, step Step) error {
+	result, err := batch.RunSalesTotals(db, batch.TriggerScenario, batch.LockOptions{Mode: batch.LockSkip}, batch.RegenerateOptions{
+		StartDate:  step.StartDate,
+		EndDate:    step.EndDate,
+		CategoryID: step.CategoryID,
+		DryRun:     step.DryRun,
+	})
+	if err != nil {
+		return err
+	}
+	if step.ExpectWritten != nil && result.Written != *step.ExpectWritten {
+		return fmt.Errorf("wrote %d records, expected %d", result.Written, *step.ExpectWritten)
+	}
+	if step.ExpectIssues != nil && result.Issues != *step.ExpectIssues {
+		return fmt.Errorf("found %d data quality issues, expected %d", result.Issues, *step.ExpectIssues)
+	}
+	return nil
+}
+
+// runReport calls the category report handler and checks its output
+func runReport(step Step) (map[string][]services.CategoryTotal, error) {
+	params := url.Values{}
+	if step.StartDate != "" {
+		params.Set("start_date", step.StartDate)
+	}
+	if step.EndDate != "" {
+		params.Set("end_date", step.EndDate)
+	}
+
+	rec, err := call(http.MethodGet, "/?"+params.Encode(), nil, services.GetSalesReportByCategory)
+	if err != nil {
+		return nil, err
+	}
+
+	expectStatus := step.ExpectStatus
+	if expectStatus == 0 {
+		expectStatus = http.StatusOK
+	}
+	if rec.Code != expectStatus {
+		return nil, fmt.Errorf("expected status %d, got %d: %s", expectStatus, rec.Code, rec.Body.String())
+	}
+	if rec.Code != http.StatusOK {
+		return nil, nil
+	}
+
+	var report map[string][]services.CategoryTotal
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		return nil, fmt.Errorf("failed to decode report: %v", err)
+	}
+
+	for date, categories := range step.Expect {
+		for category, expected := range categories {
+			actual, ok := findTotal(report[date], category)
+			if !ok {
+				return nil, fmt.Errorf("missing %s on %s", category, date)
+			}
+			if math.Abs(actual-expected) > 0.01 {
+				return nil, fmt.Errorf("%s on %s: expected %.2f, got %.2f", category, date, expected, actual)
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// runForecast forecasts the category series from the previous report step
+// twice with deterministic=true and checks the output is stable
+func runForecast(step Step, report map[string][]services.CategoryTotal) error {
+	request, err := forecastRequest(step, report)
+	if err != nil {
+		return err
+	}
+
+	var responses [2]services.ForecastResponse
+	for i := range responses {
+		if responses[i], err = postForecast(request); err != nil {
+			return err
+		}
+	}
+
+	if step.ExpectPoints > 0 && len(responses[0].Forecast) != step.ExpectPoints {
+		return fmt.Errorf("expected %d forecast points, got %d", step.ExpectPoints, len(responses[0].Forecast))
+	}
+	if !reflect.DeepEqual(responses[0].Forecast, responses[1].Forecast) {
+		return fmt.Errorf("deterministic forecast differed between runs")
+	}
+
+	return nil
+}
+
+// runPersist forecasts the category series from the previous report step
+// with deterministic=true and track=true, checks the forecast and its points
+// were stored, and returns its ID for the compare step
+func runPersist(db *sql.DB, step Step, report map[string][]services.CategoryTotal) (int64, error) {
+	request, err := forecastRequest(step, report)
+	if err != nil {
+		return 0, err
+	}
+
+	var categoryID int
+	if err := db.QueryRow("SELECT id FROM categories WHERE name = $1", step.Category).Scan(&categoryID); err != nil {
+		return 0, fmt.Errorf("failed to look up category %s: %v", step.Category, err)
+	}
+	request.Track = true
+	request.CategoryID = &categoryID
+
+	response, err := postForecast(request)
+	if err != nil {
+		return 0, err
+	}
+	if response.ForecastID == 0 {
+		return 0, fmt.Errorf("forecast was not stored")
+	}
+
+	var points int
+	if err := db.QueryRow("SELECT COUNT(*) FROM forecast_points WHERE forecast_id = $1", response.ForecastID).Scan(&points); err != nil {
+		return 0, fmt.Errorf("failed to count stored forecast points: %v", err)
+	}
+	if points != len(response.Forecast) {
+		return 0, fmt.Errorf("stored %d forecast points, returned %d", points, len(response.Forecast))
+	}
+	if step.ExpectPoints > 0 && points != step.ExpectPoints {
+		return 0, fmt.Errorf("expected %d stored forecast points, got %d", step.ExpectPoints, points)
+	}
+
+	return response.ForecastID, nil
+}
+
+// runCompare matches the persisted forecasts against actuals the way the
+// forecast-accuracy job does and checks the accuracy endpoint reports them
+func runCompare(db *sql.DB, step Step, persisted []int64) error {
+	if len(persisted) == 0 {
+		return fmt.Errorf("compare step requires a preceding persist step")
+	}
+
+	if _, err := batch.MatchForecastActuals(db, time.Now()); err != nil {
+		return err
+	}
+
+	rec, err := call(http.MethodGet, "/", nil, services.GetForecastAccuracy)
+	if err != nil {
+		return err
+	}
+	if rec.Code != http.StatusOK {
+		return fmt.Errorf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var accuracy services.ForecastAccuracyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &accuracy); err != nil {
+		return fmt.Errorf("failed to decode forecast accuracy: %v", err)
+	}
+
+	for _, id := range persisted {
+		var found *services.ForecastAccuracy
+		for i := range accuracy.Forecasts {
+			if accuracy.Forecasts[i].ForecastID == id {
+				found = &accuracy.Forecasts[i]
+			}
+		}
+		if found == nil {
+			return fmt.Errorf("forecast %d missing from the accuracy report", id)
+		}
+		if step.ExpectMatched != nil && found.MatchedPeriods != *step.ExpectMatched {
+			return fmt.Errorf("forecast %d: expected %d matched periods, got %d", id, *step.ExpectMatched, found.MatchedPeriods)
+		}
+		if found.MatchedPeriods > 0 && found.Bias == nil {
+			return fmt.Errorf("forecast %d: matched %d periods but has no bias", id, found.MatchedPeriods)
+		}
+	}
+
+	return nil
+}
+
+// forecastRequest builds a deterministic forecast request for the category
+// series from the previous report step
+func forecastRequest(step Step, report map[string][]services.CategoryTotal) (services.ForecastRequest, error) {
+	if report == nil {
+		return services.ForecastRequest{}, fmt.Errorf("%s step requires a preceding report step", step.Type)
+	}
+
+	dates := make([]string, 0, len(report))
+	for date := range report {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	request := services.ForecastRequest{
+		TimePeriod:    step.TimePeriod,
+		Deterministic: true,
+	}
+	for _, date := range dates {
+		if total, ok := findTotal(report[date], step.Category); ok {
+			request.TimeSeriesData = append(request.TimeSeriesData, services.TimeSeriesPoint{Period: date, Total: total})
+		}
+	}
+	if len(request.TimeSeriesData) == 0 {
+		return services.ForecastRequest{}, fmt.Errorf("no report data for category %s", step.Category)
+	}
+	return request, nil
+}
+
+// postForecast calls the forecast handler and decodes its response
+func postForecast(request services.ForecastRequest) (services.ForecastResponse, error) {
+	var response services.ForecastResponse
+	body, err := json.Marshal(request)
+	if err != nil {
+		return response, err
+	}
+	rec, err := call(http.MethodPost, "/", body, services.GenerateSalesForecast)
+	if err != nil {
+		return response, err
+	}
+	if rec.Code != http.StatusOK {
+		return response, fmt.Errorf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		return response, fmt.Errorf("failed to decode forecast: %v", err)
+	}
+	return response, nil
+}
+
+// call invokes a handler directly with an in-memory request
+func call(method, target string, body []byte, handler echo.HandlerFunc) (*httptest.ResponseRecorder, error) {
+	e := echo.New()
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	if body != nil {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	rec := httptest.NewRecorder()
+	if err := handler(e.NewContext(req, rec)); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func findTotal(categories []services.CategoryTotal, name string) (float64, bool) {
+	for _, category := range categories {
+		if category.CategoryName == name {
+			return category.TotalAmount, true
+		}
+	}
+	return 0, false
+}